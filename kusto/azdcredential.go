@@ -0,0 +1,91 @@
+package kusto
+
+// azdcredential.go implements the credential of WithAzureDeveloperCli(), which azidentity does not provide in the version
+// this module uses.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// azdTimeout bounds a run of the Azure Developer CLI.
+const azdTimeout = 10 * time.Second
+
+// cliRunner runs a command line tool and returns its standard output.
+type cliRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// azureDeveloperCLICredential is an azcore.TokenCredential that gets its tokens from the Azure Developer CLI, as the user
+// logged in with "azd auth login".
+type azureDeveloperCLICredential struct {
+	tenantID string
+	// run runs azd, replaced in tests.
+	run cliRunner
+}
+
+func newAzureDeveloperCLICredential(tenantID string) *azureDeveloperCLICredential {
+	return &azureDeveloperCLICredential{tenantID: tenantID, run: runCLI}
+}
+
+// GetToken implements azcore.TokenCredential.
+func (c *azureDeveloperCLICredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if len(opts.Scopes) == 0 {
+		return azcore.AccessToken{}, fmt.Errorf("error: Azure Developer CLI: no scope requested")
+	}
+	args := []string{"auth", "token", "--output", "json"}
+	for _, scope := range opts.Scopes {
+		args = append(args, "--scope", scope)
+	}
+	if !isEmpty(c.tenantID) {
+		args = append(args, "--tenant-id", c.tenantID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, azdTimeout)
+	defer cancel()
+	out, err := c.run(ctx, "azd", args...)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: Azure Developer CLI: %w", err)
+	}
+
+	var token struct {
+		Token     string `json:"token"`
+		ExpiresOn string `json:"expiresOn"`
+	}
+	if err := json.Unmarshal(out, &token); err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: Azure Developer CLI: could not parse the token: %w", err)
+	}
+	if isEmpty(token.Token) {
+		return azcore.AccessToken{}, fmt.Errorf("error: Azure Developer CLI: no token returned")
+	}
+	expiresOn, err := time.Parse(time.RFC3339, token.ExpiresOn)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: Azure Developer CLI: could not parse the expiry of the token: %w", err)
+	}
+	return azcore.AccessToken{Token: token.Token, ExpiresOn: expiresOn}, nil
+}
+
+// runCLI is the cliRunner that runs the tool from the PATH.
+func runCLI(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if goErrors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s was not found on the PATH: %w", name, err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,165 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner is a cliRunner that records its arguments and returns out or err.
+type fakeRunner struct {
+	out  string
+	err  error
+	name string
+	args []string
+}
+
+func (f *fakeRunner) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	f.name, f.args = name, args
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, fmt.Errorf("no deadline")
+	}
+	return []byte(f.out), f.err
+}
+
+func TestAzureDeveloperCLICredential(t *testing.T) {
+	t.Parallel()
+
+	expiresOn := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		desc     string
+		tenantID string
+		scopes   []string
+		runner   *fakeRunner
+		wantArgs []string
+		wantErr  string
+	}{
+		{
+			desc:     "Token",
+			scopes:   []string{"https://kusto.kusto.windows.net/.default"},
+			runner:   &fakeRunner{out: `{"token": "azd-token", "expiresOn": "2030-01-02T03:04:05Z"}`},
+			wantArgs: []string{"auth", "token", "--output", "json", "--scope", "https://kusto.kusto.windows.net/.default"},
+		},
+		{
+			desc:     "Tenant",
+			tenantID: "tenant",
+			scopes:   []string{"https://kusto.kusto.windows.net/.default"},
+			runner:   &fakeRunner{out: `{"token": "azd-token", "expiresOn": "2030-01-02T03:04:05Z"}`},
+			wantArgs: []string{"auth", "token", "--output", "json", "--scope", "https://kusto.kusto.windows.net/.default", "--tenant-id", "tenant"},
+		},
+		{
+			desc:    "No scope",
+			runner:  &fakeRunner{},
+			wantErr: "no scope",
+		},
+		{
+			desc:    "Not logged in",
+			scopes:  []string{"scope"},
+			runner:  &fakeRunner{err: fmt.Errorf("exit status 1: not logged in, run `azd auth login` to login")},
+			wantErr: "azd auth login",
+		},
+		{
+			desc:    "Invalid output",
+			scopes:  []string{"scope"},
+			runner:  &fakeRunner{out: `not json`},
+			wantErr: "could not parse the token",
+		},
+		{
+			desc:    "No token",
+			scopes:  []string{"scope"},
+			runner:  &fakeRunner{out: `{"expiresOn": "2030-01-02T03:04:05Z"}`},
+			wantErr: "no token",
+		},
+		{
+			desc:    "Invalid expiry",
+			scopes:  []string{"scope"},
+			runner:  &fakeRunner{out: `{"token": "azd-token", "expiresOn": "tomorrow"}`},
+			wantErr: "expiry",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cred := &azureDeveloperCLICredential{tenantID: test.tenantID, run: test.runner.run}
+			token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: test.scopes})
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "azd-token", token.Token)
+			assert.True(t, expiresOn.Equal(token.ExpiresOn), token.ExpiresOn)
+			assert.Equal(t, "azd", test.runner.name)
+			assert.Equal(t, test.wantArgs, test.runner.args)
+		})
+	}
+
+	// The credential is used by the TokenProvider as any other.
+	runner := &fakeRunner{out: `{"token": "azd-token", "expiresOn": "2030-01-02T03:04:05Z"}`}
+	tkp := &TokenProvider{tokenCred: &azureDeveloperCLICredential{run: runner.run}, tokenScheme: BEARER_TYPE, scopes: []string{"scope"}}
+	token, scheme, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "azd-token", token)
+	assert.Equal(t, BEARER_TYPE, scheme)
+}
+
+func TestCLICredentials(t *testing.T) {
+	t.Parallel()
+
+	const endpoint = "https://cli.kusto.windows.net"
+	tests := []struct {
+		desc string
+		kcsb *ConnectionStringBuilder
+		want ConnectionStringBuilder
+	}{
+		{
+			desc: "Azure CLI",
+			kcsb: (&ConnectionStringBuilder{}).WithAzCli(endpoint),
+			want: ConnectionStringBuilder{DataSource: endpoint, AzCli: true},
+		},
+		{
+			desc: "Azure CLI with the builder endpoint",
+			kcsb: NewConnectionStringBuilder(endpoint).WithAadAppKey("app", "key", "tenant").WithAzCli(""),
+			want: ConnectionStringBuilder{DataSource: endpoint, AzCli: true},
+		},
+		{
+			desc: "Azure Developer CLI",
+			kcsb: (&ConnectionStringBuilder{}).WithAzureDeveloperCli(endpoint),
+			want: ConnectionStringBuilder{DataSource: endpoint, AzureDeveloperCli: true},
+		},
+		{
+			desc: "Azure Developer CLI replaces Azure CLI",
+			kcsb: NewConnectionStringBuilder(endpoint).WithAzCli("").WithAzureDeveloperCli(""),
+			want: ConnectionStringBuilder{DataSource: endpoint, AzureDeveloperCli: true},
+		},
+		{
+			desc: "DefaultAzureCredential",
+			kcsb: NewConnectionStringBuilder(endpoint).WithAzureDeveloperCli("").WithDefaultAzureCredential(),
+			want: ConnectionStringBuilder{DataSource: endpoint, DefaultAuth: true},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, *test.kcsb)
+			tkp, err := test.kcsb.newTokenProvider()
+			require.NoError(t, err)
+			assert.True(t, tkp.AuthorizationRequired())
+		})
+	}
+
+	assert.Panics(t, func() { (&ConnectionStringBuilder{}).WithAzCli("") })
+	assert.Panics(t, func() { (&ConnectionStringBuilder{}).WithAzureDeveloperCli("") })
+}
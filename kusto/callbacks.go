@@ -0,0 +1,101 @@
+package kusto
+
+// callbacks.go implements WithCallbacks(), which reports every request sent to the service, for example for audit logs.
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// RedactedCSL replaces the text of the statement in a RequestInfo when Callbacks.RedactCSL is set.
+const RedactedCSL = "<redacted>"
+
+// RequestInfo describes a request about to be sent to the service, see WithCallbacks().
+type RequestInfo struct {
+	// Op is the operation of the request: errors.OpQuery for Query() and QueryToJson() calls, errors.OpMgmt for
+	// Mgmt() and MgmtToJson() calls.
+	Op errors.Op
+	// URL is the endpoint the request is sent to.
+	URL string
+	// DB is the database of the request.
+	DB string
+	// CSL is the text of the statement, or RedactedCSL if Callbacks.RedactCSL is set.
+	CSL string
+	// ClientRequestID is the client request ID sent with the request, see ClientRequestID().
+	ClientRequestID string
+	// Attempt is the number of the attempt, starting at 1, which is above 1 for a request retried after a
+	// transient error, see WithRetryOptions().
+	Attempt int
+}
+
+// ResponseInfo describes the response to a request, see WithCallbacks().
+type ResponseInfo struct {
+	RequestInfo
+	// Duration is the time from sending the request to receiving the headers of the response. The rows are read
+	// afterwards.
+	Duration time.Duration
+	// StatusCode is the HTTP status of the response, or 0 if no response was received.
+	StatusCode int
+	// ActivityID is the ID the service gave the request, see RowIterator.ActivityID().
+	ActivityID string
+}
+
+// Callbacks are called around every request the Client sends, see WithCallbacks(). Nil funcs are not called.
+type Callbacks struct {
+	// OnRequest is called before a request is sent.
+	OnRequest func(ctx context.Context, info RequestInfo)
+	// OnResponse is called once the response to a request was received, or the request failed, in which case err is
+	// the error of the attempt.
+	OnResponse func(ctx context.Context, info ResponseInfo, err error)
+	// RedactCSL replaces the text of the statements by RedactedCSL in the RequestInfo, for statements that hold
+	// secrets in their literals.
+	RedactCSL bool
+}
+
+// WithCallbacks sets callbacks that are called around every request the Client sends for Query(), Mgmt(),
+// QueryToJson() and MgmtToJson() calls, including every retry. They are called synchronously by the calls, and
+// concurrently by concurrent calls, so they should return quickly.
+func WithCallbacks(cb Callbacks) Option {
+	return func(c *Client) {
+		c.callbacks = &cb
+	}
+}
+
+// requestInfo returns the RequestInfo of a request, whose header was set.
+func (cb *Callbacks) requestInfo(op errors.Op, req *http.Request, db string, query Stmt) RequestInfo {
+	info := RequestInfo{Op: op, URL: req.URL.String(), DB: db, CSL: query.String(), ClientRequestID: req.Header.Get(clientRequestIDHeader)}
+	if cb.RedactCSL {
+		info.CSL = RedactedCSL
+	}
+	return info
+}
+
+// csl returns the text of query to put in the errors of its requests, which is RedactedCSL if RedactCSL is set.
+func (cb *Callbacks) csl(query Stmt) string {
+	if cb != nil && cb.RedactCSL {
+		return RedactedCSL
+	}
+	return query.String()
+}
+
+func (cb *Callbacks) onRequest(ctx context.Context, info RequestInfo) {
+	if cb != nil && cb.OnRequest != nil {
+		cb.OnRequest(ctx, info)
+	}
+}
+
+// onResponse reports the response to the request of info, sent at start. resp is nil if no response was received.
+func (cb *Callbacks) onResponse(ctx context.Context, info RequestInfo, start time.Time, resp *http.Response, err error) {
+	if cb == nil || cb.OnResponse == nil {
+		return
+	}
+	r := ResponseInfo{RequestInfo: info, Duration: nower().Sub(start)}
+	if resp != nil {
+		r.StatusCode = resp.StatusCode
+		r.ActivityID = resp.Header.Get(activityIDHeader)
+	}
+	cb.OnResponse(ctx, r, err)
+}
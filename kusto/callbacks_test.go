@@ -0,0 +1,213 @@
+package kusto
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callbackRecorder records the calls of the Callbacks it returns.
+type callbackRecorder struct {
+	mu        sync.Mutex
+	requests  []RequestInfo
+	responses []ResponseInfo
+	errs      []error
+}
+
+func (c *callbackRecorder) callbacks(redact bool) Callbacks {
+	return Callbacks{
+		OnRequest: func(ctx context.Context, info RequestInfo) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.requests = append(c.requests, info)
+		},
+		OnResponse: func(ctx context.Context, info ResponseInfo, err error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.responses = append(c.responses, info)
+			c.errs = append(c.errs, err)
+		},
+		RedactCSL: redact,
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	t.Parallel()
+
+	query := func(ctx context.Context, client *Client) error {
+		iter, err := client.Query(ctx, "db", NewStmt("T | take 1"), ClientRequestID("my-request"))
+		if err != nil {
+			return err
+		}
+		iter.Stop()
+		return nil
+	}
+
+	tests := []struct {
+		desc      string
+		transport http.RoundTripper
+		redact    bool
+		call      func(ctx context.Context, client *Client) error
+		wantOp    errors.Op
+		wantCSL   string
+		wantPath  string
+		// wantStatus are the statuses of the attempts, 0 for a failure to connect.
+		wantStatus []int
+		wantErr    bool
+	}{
+		{
+			desc:       "Query",
+			transport:  activityTransport{},
+			call:       query,
+			wantOp:     errors.OpQuery,
+			wantCSL:    "T | take 1",
+			wantPath:   "/v2/rest/query",
+			wantStatus: []int{200},
+		},
+		{
+			desc:       "Redacted",
+			transport:  activityTransport{},
+			redact:     true,
+			call:       query,
+			wantOp:     errors.OpQuery,
+			wantCSL:    RedactedCSL,
+			wantPath:   "/v2/rest/query",
+			wantStatus: []int{200},
+		},
+		{
+			desc:      "QueryToJson",
+			transport: activityTransport{},
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("T | take 1"), ClientRequestID("my-request"))
+				return err
+			},
+			wantOp:     errors.OpQuery,
+			wantCSL:    "T | take 1",
+			wantPath:   "/v2/rest/query",
+			wantStatus: []int{200},
+		},
+		{
+			desc:      "Mgmt retried",
+			transport: &retryTransport{responses: []retryResponse{{status: 503}}},
+			call: func(ctx context.Context, client *Client) error {
+				iter, err := client.Mgmt(ctx, "db", NewStmt(".show tables"))
+				if err != nil {
+					return err
+				}
+				iter.Stop()
+				return nil
+			},
+			wantOp:     errors.OpMgmt,
+			wantCSL:    ".show tables",
+			wantPath:   "/v1/rest/mgmt",
+			wantStatus: []int{503, 200},
+		},
+		{
+			desc:       "Failure to connect",
+			transport:  &retryTransport{responses: []retryResponse{{status: 0}, {status: 0}, {status: 0}}},
+			call:       query,
+			wantOp:     errors.OpQuery,
+			wantCSL:    "T | take 1",
+			wantPath:   "/v2/rest/query",
+			wantStatus: []int{0, 0},
+			wantErr:    true,
+		},
+		{
+			desc:       "Redacted failure to connect",
+			transport:  &retryTransport{responses: []retryResponse{{status: 0}, {status: 0}, {status: 0}}},
+			redact:     true,
+			call:       query,
+			wantOp:     errors.OpQuery,
+			wantCSL:    RedactedCSL,
+			wantPath:   "/v2/rest/query",
+			wantStatus: []int{0, 0},
+			wantErr:    true,
+		},
+		{
+			desc:       "Redacted error status",
+			transport:  &retryTransport{responses: []retryResponse{{status: 503}, {status: 503}, {status: 503}}},
+			redact:     true,
+			call:       query,
+			wantOp:     errors.OpQuery,
+			wantCSL:    RedactedCSL,
+			wantPath:   "/v2/rest/query",
+			wantStatus: []int{503, 503},
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &callbackRecorder{}
+			client := &Client{endpoint: "https://callbacks.kusto.windows.net", http: &http.Client{Transport: test.transport}}
+			WithCallbacks(recorder.callbacks(test.redact))(client)
+			WithRetryOptions(1, time.Millisecond, time.Millisecond)(client)
+			conn, err := newConn("https://callbacks.kusto.windows.net", Authorization{}, client.http, NewClientDetails("", ""))
+			require.NoError(t, err)
+			conn.callbacks = client.callbacks
+			conn.retry = client.retry
+			client.conn = conn
+
+			err = test.call(context.Background(), client)
+			if test.wantErr {
+				require.Error(t, err)
+				if test.redact {
+					assert.NotContains(t, err.Error(), "take 1")
+				}
+			} else {
+				require.NoError(t, err)
+			}
+
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			require.Len(t, recorder.requests, len(test.wantStatus))
+			require.Len(t, recorder.responses, len(test.wantStatus))
+			for i, status := range test.wantStatus {
+				req := recorder.requests[i]
+				assert.Equal(t, test.wantOp, req.Op)
+				assert.Equal(t, "db", req.DB)
+				assert.Equal(t, test.wantCSL, req.CSL)
+				if test.wantOp == errors.OpMgmt {
+					// The ID generated by the client is reported.
+					assert.NotEmpty(t, req.ClientRequestID)
+				} else {
+					assert.Equal(t, "my-request", req.ClientRequestID)
+				}
+				assert.Equal(t, i+1, req.Attempt)
+				assert.True(t, strings.HasSuffix(req.URL, test.wantPath), req.URL)
+
+				resp := recorder.responses[i]
+				assert.Equal(t, req, resp.RequestInfo)
+				assert.Equal(t, status, resp.StatusCode)
+				assert.GreaterOrEqual(t, resp.Duration, time.Duration(0))
+				if status == http.StatusOK {
+					assert.NoError(t, recorder.errs[i])
+				} else {
+					require.Error(t, recorder.errs[i])
+					if test.redact {
+						assert.NotContains(t, recorder.errs[i].Error(), "take 1", "the error passed to OnResponse has the query")
+					}
+				}
+				if _, ok := test.transport.(activityTransport); ok {
+					assert.Equal(t, "activity-of-my-request", resp.ActivityID)
+				}
+			}
+		})
+	}
+
+	// The callbacks are used by the connections of a new Client.
+	client, err := New(NewConnectionStringBuilder("https://callbacks.kusto.windows.net"), WithCallbacks(Callbacks{RedactCSL: true}))
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Same(t, client.callbacks, client.conn.(*conn).callbacks)
+}
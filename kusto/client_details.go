@@ -65,10 +65,18 @@ func getOsUser() string {
 
 var escapeRegex = regexp.MustCompile("[\\r\\n\\s{}|]+")
 
+// escapeKeyRegex also matches ":", which separates a key from its value.
+var escapeKeyRegex = regexp.MustCompile("[\\r\\n\\s{}|:]+")
+
 func escape(s string) string {
 	return "{" + escapeRegex.ReplaceAllString(s, "_") + "}"
 }
 
+// escapeKey replaces the characters that delimit the fields of the header format in the key s.
+func escapeKey(s string) string {
+	return escapeKeyRegex.ReplaceAllString(s, "_")
+}
+
 func defaultTracingValues() ClientDetails {
 	r, _ := defaultTracingValuesOnce.DoWithInit()
 	return r
@@ -92,6 +100,8 @@ func (c *ClientDetails) ClientVersionForTracing() string {
 	return defaultTracingValues().clientVersionForTracing
 }
 
+// buildHeaderFormat returns args in the format "key:{value}|key:{value}". The keys must already be escaped with
+// escapeKey(), and the values are escaped with escape().
 func buildHeaderFormat(args ...StringPair) string {
 	return strings.Join(lo.Map(args, func(arg StringPair, _ int) string {
 		return fmt.Sprintf("%s:%s", arg.Key, escape(arg.Value))
@@ -101,7 +111,7 @@ func buildHeaderFormat(args ...StringPair) string {
 func setConnectorDetails(name, version, appName, appVersion string, sendUser bool, overrideUser string, additionalFields ...StringPair) (string, string) {
 	var additionalFieldsList []StringPair
 
-	additionalFieldsList = append(additionalFieldsList, StringPair{Key: "Kusto." + name, Value: version})
+	additionalFieldsList = append(additionalFieldsList, StringPair{Key: "Kusto." + escapeKey(name), Value: version})
 
 	if appName == "" {
 		appName = defaultTracingValues().applicationForTracing
@@ -110,9 +120,9 @@ func setConnectorDetails(name, version, appName, appVersion string, sendUser boo
 		appVersion = NONE
 	}
 
-	additionalFieldsList = append(additionalFieldsList, StringPair{Key: "App.{" + appName + "}", Value: appVersion})
-	if additionalFields != nil {
-		additionalFieldsList = append(additionalFieldsList, additionalFields...)
+	additionalFieldsList = append(additionalFieldsList, StringPair{Key: "App." + escape(appName), Value: appVersion})
+	for _, field := range additionalFields {
+		additionalFieldsList = append(additionalFieldsList, StringPair{Key: escapeKey(field.Key), Value: field.Value})
 	}
 
 	app := buildHeaderFormat(additionalFieldsList...)
@@ -129,3 +139,21 @@ func setConnectorDetails(name, version, appName, appVersion string, sendUser boo
 
 	return app, user
 }
+
+// WithConnectorDetails sets the application and user sent in the x-ms-app and x-ms-user headers of the requests of the
+// Client for a connector built on top of it, replacing ConnectionStringBuilder.ApplicationForTracing and
+// ConnectionStringBuilder.UserForTracing. The application is in the connector format:
+//
+//	Kusto.name:{version}|App.{appName}:{appVersion}|key:{value}
+//
+// with one key:{value} per additional field. appName defaults to the name of the executable and appVersion to
+// "[none]". The user is "[none]" unless sendUser is set, in which case it is overrideUser, or the current user if it is
+// empty. In names and keys, whitespace and the "|", ":", "{" and "}" characters are replaced with "_", and in versions
+// and values, which are within braces, whitespace and the "|", "{" and "}" characters. This is the equivalent of
+// ConnectionStringBuilder.SetConnectorDetails().
+func WithConnectorDetails(name, version, appName, appVersion string, sendUser bool, overrideUser string, additional ...StringPair) Option {
+	return func(c *Client) {
+		app, user := setConnectorDetails(name, version, appName, appVersion, sendUser, overrideUser, additional...)
+		c.clientDetails = NewClientDetails(app, user)
+	}
+}
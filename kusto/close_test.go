@@ -0,0 +1,220 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientClose(t *testing.T) {
+	t.Parallel()
+
+	transport := &recordTransport{}
+	client := newTestClient(t, "https://close.kusto.windows.net", transport)
+
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	iter.Stop()
+
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Close())
+
+	calls := map[string]func() error{
+		"Query": func() error {
+			_, err := client.Query(context.Background(), "db", NewStmt("T"))
+			return err
+		},
+		"QueryToJson": func() error {
+			_, err := client.QueryToJson(context.Background(), "db", NewStmt("T"))
+			return err
+		},
+		"Mgmt": func() error {
+			_, err := client.Mgmt(context.Background(), "db", NewStmt(".show tables"))
+			return err
+		},
+		"Mgmt on the ingestion endpoint": func() error {
+			_, err := client.Mgmt(context.Background(), "db", NewStmt(".show ingestion mappings"), IngestionEndpoint())
+			return err
+		},
+	}
+	for name, call := range calls {
+		assert.ErrorIs(t, call(), ClientClosedErr, name)
+	}
+	assert.Len(t, transport.sent(), 1)
+	assert.Nil(t, client.ingestConn)
+}
+
+func TestClientCloseConcurrent(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, "https://close.kusto.windows.net", &recordTransport{})
+
+	// The calls made while the Client is closed either succeed or fail with ClientClosedErr, and Close() can run
+	// concurrently with itself.
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			switch i % 5 {
+			case 0:
+				assert.NoError(t, client.Close())
+			case 1:
+				iter, err := client.Mgmt(context.Background(), "db", NewStmt(".show ingestion mappings"), IngestionEndpoint())
+				if err != nil {
+					assert.ErrorIs(t, err, ClientClosedErr)
+					return
+				}
+				iter.Stop()
+			default:
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+				if err != nil {
+					assert.ErrorIs(t, err, ClientClosedErr)
+					return
+				}
+				// Close() cancels the queries in flight.
+				if err := iter.Do(func(*table.Row) error { return nil }); err != nil {
+					assert.ErrorIs(t, err, ClientClosedErr)
+				}
+				iter.Stop()
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	require.NoError(t, client.Close())
+	_, err := client.Query(context.Background(), "db", NewStmt("T"))
+	assert.ErrorIs(t, err, ClientClosedErr)
+}
+
+// blockingTransport is a fake http.RoundTripper whose requests wait for their context to be done.
+type blockingTransport struct {
+	// started receives a value once a request is waiting.
+	started chan struct{}
+}
+
+func (b blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") || strings.HasSuffix(req.URL.Path, "/auth/metadata") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	b.started <- struct{}{}
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestClientCloseCancelsInFlight(t *testing.T) {
+	t.Parallel()
+
+	calls := map[string]func(client *Client) error{
+		"Query": func(client *Client) error {
+			_, err := client.Query(context.Background(), "db", NewStmt("T"))
+			return err
+		},
+		"QueryToJson": func(client *Client) error {
+			_, err := client.QueryToJson(context.Background(), "db", NewStmt("T"))
+			return err
+		},
+		"Mgmt": func(client *Client) error {
+			_, err := client.Mgmt(context.Background(), "db", NewStmt(".show tables"))
+			return err
+		},
+		"MgmtToJson": func(client *Client) error {
+			_, err := client.MgmtToJson(context.Background(), "db", NewStmt(".show tables"))
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		name, call := name, call // Capture
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			transport := blockingTransport{started: make(chan struct{}, 1)}
+			client := newTestClient(t, "https://close.kusto.windows.net", transport)
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- call(client) }()
+			<-transport.started
+
+			require.NoError(t, client.Close())
+			select {
+			case err := <-errCh:
+				assert.ErrorIs(t, err, ClientClosedErr)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the call was not cancelled by Close()")
+			}
+		})
+	}
+
+	t.Run("RowIterator", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &endlessTransport{stallAfter: 1, closed: make(chan struct{}), stopped: make(chan struct{})}
+		client := newTestClient(t, "https://close.kusto.windows.net", transport)
+
+		iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+		require.NoError(t, err)
+		defer iter.Stop()
+
+		// The stream stalls after the first rows, until Close() tears it down.
+		rows := 0
+		err = iter.Do(func(*table.Row) error {
+			rows++
+			if rows == 2 {
+				require.NoError(t, client.Close())
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, ClientClosedErr)
+		<-transport.closed
+	})
+}
+
+func TestClientCloseCaches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		option  Option
+		options []QueryOption
+	}{
+		{desc: "Results cache", option: WithClientResultsCache(1<<20, time.Minute), options: []QueryOption{Cacheable()}},
+		{desc: "Query cache", option: WithQueryCache(&mapCache{}, time.Minute)},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &recordTransport{}
+			client := newTestClient(t, "https://close.kusto.windows.net", transport)
+			test.option(client)
+
+			for i := 0; i < 2; i++ {
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"), test.options...)
+				require.NoError(t, err)
+				require.NoError(t, iter.Do(func(*table.Row) error { return nil }))
+				iter.Stop()
+			}
+			require.Len(t, transport.sent(), 1, "the second query is answered from the cache")
+
+			require.NoError(t, client.Close())
+			_, err := client.Query(context.Background(), "db", NewStmt("T"), test.options...)
+			assert.ErrorIs(t, err, ClientClosedErr)
+			assert.Len(t, transport.sent(), 1)
+		})
+	}
+}
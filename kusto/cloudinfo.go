@@ -4,12 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/utils"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"sync"
+	"time"
 )
 
 // abstraction to query metadata and use this information for providing all
@@ -27,19 +28,40 @@ const (
 
 // retrieved metadata
 type metaResp struct {
-	AzureAD CloudInfo
+	AzureAD CloudSettings
 }
 
-type CloudInfo struct {
-	LoginEndpoint          string `json:"LoginEndpoint"`
-	LoginMfaRequired       bool   `json:"LoginMfaRequired"`
-	KustoClientAppID       string `json:"KustoClientAppId"`
+// CloudSettings is the metadata of the cloud of a cluster, such as the public cloud, Azure China, Azure Government or an
+// air-gapped cloud, which the cluster serves at /v1/rest/auth/metadata. It tells how to get tokens for the cluster.
+type CloudSettings struct {
+	// LoginEndpoint is the authority host of Microsoft Entra ID, such as "https://login.microsoftonline.com".
+	LoginEndpoint string `json:"LoginEndpoint"`
+	// LoginMfaRequired is true if the tokens must be issued for the MFA resource of the cluster, see Scopes().
+	LoginMfaRequired bool `json:"LoginMfaRequired"`
+	// KustoClientAppID is the application ID of the Kusto client, used by the interactive and device code logins.
+	KustoClientAppID string `json:"KustoClientAppId"`
+	// KustoClientRedirectURI is the redirect URI of the Kusto client application.
 	KustoClientRedirectURI string `json:"KustoClientRedirectUri"`
+	// KustoServiceResourceID is the resource the tokens are issued for, such as "https://kusto.kusto.windows.net".
 	KustoServiceResourceID string `json:"KustoServiceResourceId"`
+	// FirstPartyAuthorityURL is the authority of first party applications.
 	FirstPartyAuthorityURL string `json:"FirstPartyAuthorityUrl"`
 }
 
-var defaultCloudInfo = CloudInfo{
+// CloudInfo is the former name of CloudSettings.
+type CloudInfo = CloudSettings
+
+// Scopes returns the scopes of the tokens for the clusters of the cloud, which are the ones the clients of this package
+// request. When LoginMfaRequired is set, the resource is the MFA one, such as "https://kusto.kustomfa.windows.net".
+func (c CloudSettings) Scopes() []string {
+	resourceURI := c.KustoServiceResourceID
+	if c.LoginMfaRequired {
+		resourceURI = strings.Replace(resourceURI, ".kusto.", ".kustomfa.", 1)
+	}
+	return []string{fmt.Sprintf("%s/.default", resourceURI)}
+}
+
+var defaultCloudInfo = CloudSettings{
 	LoginEndpoint:          getEnvOrDefault(defaultAuthEnvVarName, defaultPublicLoginUrl),
 	LoginMfaRequired:       false,
 	KustoClientAppID:       defaultKustoClientAppId,
@@ -48,63 +70,126 @@ var defaultCloudInfo = CloudInfo{
 	FirstPartyAuthorityURL: defaultFirstPartyAuthorityUrl,
 }
 
-// cache to query it once per instance
-var cloudInfoCache sync.Map
+// MetadataFailureBackoff is how long a failure to get the metadata of an endpoint is returned by GetMetadata() before
+// it is requested again, so that a failing endpoint is not asked on every request.
+const MetadataFailureBackoff = 30 * time.Second
+
+// cloudInfoEntry is the metadata of an endpoint in cloudInfoCache.
+type cloudInfoEntry struct {
+	// mu is held while the metadata is requested, so that the concurrent calls for an endpoint share the request.
+	mu   sync.Mutex
+	done bool
+	info CloudSettings
+	err  error
+	// retryAt is when the metadata is requested again after err.
+	retryAt time.Time
+}
 
-func GetMetadata(kustoUri string, httpClient *http.Client) (CloudInfo, error) {
-	// retrieve &return if exists
-	once, ok := cloudInfoCache.Load(kustoUri)
+// cloudInfoCache holds the metadata of the endpoints, shared by all clients: the successes are kept for the lifetime of
+// the process, and the failures for MetadataFailureBackoff.
+var cloudInfoCache = struct {
+	mu      sync.Mutex
+	entries map[string]*cloudInfoEntry
+}{entries: map[string]*cloudInfoEntry{}}
+
+// cloudInfoCacheEntry returns the entry of kustoUri in cloudInfoCache, adding it if needed.
+func cloudInfoCacheEntry(kustoUri string) *cloudInfoEntry {
+	kustoUri = strings.TrimSuffix(kustoUri, "/")
+	cloudInfoCache.mu.Lock()
+	defer cloudInfoCache.mu.Unlock()
+	entry, ok := cloudInfoCache.entries[kustoUri]
 	if !ok {
-		once = utils.NewOnce[CloudInfo]()
-		cloudInfoCache.Store(kustoUri, once)
+		entry = &cloudInfoEntry{}
+		cloudInfoCache.entries[kustoUri] = entry
+	}
+	return entry
+}
+
+// AddCloudInfoToCache sets the metadata of the cloud of the cluster at endpoint, such as
+// "https://mycluster.kusto.chinacloudapi.cn", which GetMetadata() then returns instead of requesting it from the cluster.
+// The token providers and the check of the trusted endpoints get it from GetMetadata(). This is needed for clusters that
+// cannot serve /v1/rest/auth/metadata, such as in some air-gapped clouds. It replaces the metadata that was already in
+// the cache, and only affects the clients that did not get it yet, so it should be called before creating them.
+func AddCloudInfoToCache(endpoint string, settings CloudSettings) {
+	entry := cloudInfoCacheEntry(endpoint)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.done, entry.info, entry.err, entry.retryAt = true, settings, nil, time.Time{}
+}
+
+// GetMetadata returns the metadata of the cloud of the cluster at kustoUri, or the one of the public cloud if the
+// cluster has none. The result is cached, see MetadataFailureBackoff for the failures.
+func GetMetadata(kustoUri string, httpClient *http.Client) (CloudSettings, error) {
+	entry := cloudInfoCacheEntry(kustoUri)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.done {
+		return entry.info, nil
+	}
+	if entry.err != nil && nower().Before(entry.retryAt) {
+		return CloudSettings{}, entry.err
+	}
+
+	info, err := fetchMetadata(kustoUri, httpClient)
+	if err != nil {
+		entry.err = err
+		entry.retryAt = nower().Add(MetadataFailureBackoff)
+		return CloudSettings{}, err
+	}
+	entry.done, entry.info, entry.err = true, info, nil
+	return info, nil
+}
+
+// fetchMetadata requests the metadata of the cloud of the cluster at kustoUri.
+func fetchMetadata(kustoUri string, httpClient *http.Client) (CloudSettings, error) {
+	u, err := url.Parse(kustoUri)
+	if err != nil {
+		return CloudSettings{}, err
+	}
+
+	u.Path = metadataPath
+	// TODO should we make this timeout configurable.
+	req, err := http.NewRequest("GET", u.String(), nil)
+
+	if err != nil {
+		return CloudSettings{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
+	}
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return CloudSettings{}, err
+	}
+	defer resp.Body.Close()
+
+	// Handle internal server error as a special case and return as an error (to be consistent with other SDK's)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return CloudSettings{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, fmt.Errorf("error %s when querying endpoint %s",
+			resp.Status, u.String()),
+		)
 	}
 
-	return once.(utils.Once[CloudInfo]).Do(func() (CloudInfo, error) {
-		u, err := url.Parse(kustoUri)
-		if err != nil {
-			return CloudInfo{}, err
-		}
-
-		u.Path = metadataPath
-		// TODO should we make this timeout configurable.
-		req, err := http.NewRequest("GET", u.String(), nil)
-
-		if err != nil {
-			return CloudInfo{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
-		}
-		resp, err := httpClient.Do(req)
-
-		if err != nil {
-			return CloudInfo{}, err
-		}
-
-		// Handle internal server error as a special case and return as an error (to be consistent with other SDK's)
-		if resp.StatusCode >= http.StatusInternalServerError {
-			return CloudInfo{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, fmt.Errorf("error %s when querying endpoint %s",
-				resp.Status, u.String()),
-			)
-		}
-
-		defer resp.Body.Close()
-
-		b, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return CloudInfo{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
-		}
-
-		// Covers scenarios of 200/OK with no body or a 404 where there is no body
-		if len(b) == 0 {
-			return defaultCloudInfo, nil
-		}
-
-		md := metaResp{}
-
-		if err := json.Unmarshal(b, &md); err != nil {
-			return CloudInfo{}, err
-		}
-		// this should be set in the map by now
-		return md.AzureAD, nil
-	})
+	// A cluster without metadata is in the public cloud, whatever the body of the 404 is.
+	if resp.StatusCode == http.StatusNotFound {
+		return defaultCloudInfo, nil
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return CloudSettings{}, kustoErrors.E(kustoErrors.OpCloudInfo, kustoErrors.KHTTPError, err)
+	}
+
+	// Covers scenarios of 200/OK with no body
+	if len(b) == 0 {
+		return defaultCloudInfo, nil
+	}
+
+	md := metaResp{}
+
+	if err := json.Unmarshal(b, &md); err != nil {
+		return CloudSettings{}, err
+	}
+	return md.AzureAD, nil
 }
 
 func getEnvOrDefault(key, fallback string) string {
@@ -1,13 +1,18 @@
 package kusto
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type server struct {
@@ -145,3 +150,163 @@ func TestGetMetadata(t *testing.T) {
 		})
 	}
 }
+
+// countingServer answers the metadata requests with code and counts them.
+type countingServer struct {
+	code     int32
+	payload  string
+	requests atomic.Int32
+	http     *httptest.Server
+}
+
+func newCountingServer(code int, payload string) *countingServer {
+	s := &countingServer{code: int32(code), payload: payload}
+	s.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		w.WriteHeader(int(atomic.LoadInt32(&s.code)))
+		_, _ = w.Write([]byte(s.payload))
+	}))
+	return s
+}
+
+func TestGetMetadataCache(t *testing.T) {
+	t.Parallel()
+
+	const payload = `{"AzureAD": {"LoginEndpoint": "https://login.microsoftonline.com", "KustoServiceResourceId": "https://kusto.dev.kusto.windows.net"}}`
+
+	t.Run("success is cached", func(t *testing.T) {
+		t.Parallel()
+		s := newCountingServer(http.StatusOK, payload)
+		defer s.http.Close()
+
+		for i := 0; i < 3; i++ {
+			info, err := GetMetadata(s.http.URL, http.DefaultClient)
+			assert.NoError(t, err)
+			assert.Equal(t, "https://login.microsoftonline.com", info.LoginEndpoint)
+		}
+		assert.EqualValues(t, 1, s.requests.Load())
+	})
+
+	t.Run("concurrent calls share the request", func(t *testing.T) {
+		t.Parallel()
+		s := newCountingServer(http.StatusOK, payload)
+		defer s.http.Close()
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := GetMetadata(s.http.URL, http.DefaultClient)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, s.requests.Load())
+	})
+
+	t.Run("404 with a body is the default cloud", func(t *testing.T) {
+		t.Parallel()
+		s := newCountingServer(http.StatusNotFound, "<html>not found</html>")
+		defer s.http.Close()
+
+		info, err := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.NoError(t, err)
+		assert.Equal(t, defaultCloudInfo, info)
+	})
+
+	t.Run("failure is retried after the backoff", func(t *testing.T) {
+		t.Parallel()
+		s := newCountingServer(http.StatusServiceUnavailable, "")
+		defer s.http.Close()
+
+		_, err := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.Error(t, err)
+		_, err2 := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.Equal(t, err, err2)
+		assert.EqualValues(t, 1, s.requests.Load())
+
+		// The endpoint recovers and the backoff ends.
+		atomic.StoreInt32(&s.code, http.StatusOK)
+		s.payload = payload
+		entry := cloudInfoCacheEntry(s.http.URL)
+		entry.mu.Lock()
+		entry.retryAt = time.Time{}
+		entry.mu.Unlock()
+
+		info, err := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://login.microsoftonline.com", info.LoginEndpoint)
+		assert.EqualValues(t, 2, s.requests.Load())
+	})
+}
+
+func TestAddCloudInfoToCache(t *testing.T) {
+	t.Parallel()
+
+	sovereign := CloudSettings{
+		LoginEndpoint:          "https://login.partner.microsoftonline.cn",
+		KustoClientAppID:       defaultKustoClientAppId,
+		KustoClientRedirectURI: defaultRedirectUri,
+		KustoServiceResourceID: "https://kusto.kusto.chinacloudapi.cn",
+	}
+
+	t.Run("metadata is not requested", func(t *testing.T) {
+		t.Parallel()
+		s := newCountingServer(http.StatusServiceUnavailable, "")
+		defer s.http.Close()
+
+		// The endpoint is the same with or without a trailing slash.
+		AddCloudInfoToCache(s.http.URL+"/", sovereign)
+		info, err := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.NoError(t, err)
+		assert.Equal(t, sovereign, info)
+		assert.EqualValues(t, 0, s.requests.Load())
+	})
+
+	t.Run("replaces a failure", func(t *testing.T) {
+		t.Parallel()
+		s := newCountingServer(http.StatusServiceUnavailable, "")
+		defer s.http.Close()
+
+		_, err := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.Error(t, err)
+
+		AddCloudInfoToCache(s.http.URL, sovereign)
+		info, err := GetMetadata(s.http.URL, http.DefaultClient)
+		assert.NoError(t, err)
+		assert.Equal(t, sovereign, info)
+		assert.EqualValues(t, 1, s.requests.Load())
+	})
+
+	t.Run("token provider", func(t *testing.T) {
+		t.Parallel()
+		const endpoint = "https://sovereign-cache.kusto.chinacloudapi.cn"
+		mfa := sovereign
+		mfa.LoginMfaRequired = true
+		AddCloudInfoToCache(endpoint, mfa)
+
+		var resources []string
+		callback := func(ctx context.Context, resource string) (string, error) {
+			resources = append(resources, resource)
+			return "token", nil
+		}
+		client, err := New(NewConnectionStringBuilder(endpoint).WithTokenCallback(callback), WithHttpClient(&http.Client{Transport: &oboTransport{}}))
+		require.NoError(t, err)
+		defer client.Close()
+
+		iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+		require.NoError(t, err)
+		iter.Stop()
+		assert.Equal(t, []string{"https://kusto.kustomfa.chinacloudapi.cn"}, resources)
+	})
+}
+
+func TestCloudSettingsScopes(t *testing.T) {
+	t.Parallel()
+
+	settings := CloudSettings{KustoServiceResourceID: "https://kusto.kusto.usgovcloudapi.net"}
+	assert.Equal(t, []string{"https://kusto.kusto.usgovcloudapi.net/.default"}, settings.Scopes())
+	settings.LoginMfaRequired = true
+	assert.Equal(t, []string{"https://kusto.kustomfa.usgovcloudapi.net/.default"}, settings.Scopes())
+}
@@ -0,0 +1,199 @@
+package kusto
+
+// completion.go parses the statistics of a query, which the service reports in the QueryCompletionInformation table.
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+)
+
+// resourceConsumptionEvent is the EventTypeName of the row of the QueryCompletionInformation table that holds the
+// statistics of the query.
+const resourceConsumptionEvent = "QueryResourceConsumption"
+
+// QueryStats are the statistics of a query, as reported by the service once the query completed.
+type QueryStats struct {
+	// ExecutionTime is how long the query ran on the service.
+	ExecutionTime time.Duration
+	// CPUUser is the CPU time spent in user mode, on all the nodes.
+	CPUUser time.Duration
+	// CPUKernel is the CPU time spent in kernel mode, on all the nodes.
+	CPUKernel time.Duration
+	// CPUTotal is the total CPU time, on all the nodes.
+	CPUTotal time.Duration
+	// MemoryPeakPerNode is the peak memory used by the query on a node, in bytes.
+	MemoryPeakPerNode int64
+	// ExtentsTotal is the number of extents of the tables the query read.
+	ExtentsTotal int64
+	// ExtentsScanned is the number of extents the query scanned, after the ones that could not match were skipped.
+	ExtentsScanned int64
+	// RowsTotal is the number of rows of the tables the query read.
+	RowsTotal int64
+	// RowsScanned is the number of rows the query scanned.
+	RowsScanned int64
+	// Tables are the statistics of the tables of the results, in order.
+	Tables []TableStats
+	// Payload is the JSON the statistics were parsed from, which has more details.
+	Payload string
+}
+
+// TableStats are the statistics of a table of the results of a query.
+type TableStats struct {
+	// RowCount is the number of rows of the table.
+	RowCount int64
+	// Size is the size of the table, in bytes.
+	Size int64
+}
+
+// StatsUnavailableError is returned by RowIterator.CompletionInformation() when the statistics of the query are not
+// available.
+type StatsUnavailableError struct {
+	// Reason describes why the statistics are not available.
+	Reason string
+}
+
+// Error implements error.
+func (s *StatsUnavailableError) Error() string {
+	return "query statistics are not available: " + s.Reason
+}
+
+// CompletionInformation returns the statistics of the query, which the service sends at the end of the response,
+// in progressive and non-progressive modes alike. They are only available once the RowIterator has reached io.EOF:
+// before that, or if the RowIterator was stopped before the end of the response, a *StatsUnavailableError is
+// returned. The error of the query is returned if it failed, and NonPrimarySuppressedErr if the query was made with
+// the PrimaryResultsOnly() option.
+func (r *RowIterator) CompletionInformation() (QueryStats, error) {
+	if r.primaryResultsOnly {
+		return QueryStats{}, NonPrimarySuppressedErr
+	}
+
+	r.mu.Lock()
+	finished, err := r.finished, r.error
+	dt, ok := r.nonPrimary[frames.QueryCompletionInformation]
+	r.mu.Unlock()
+
+	switch {
+	case err != nil:
+		return QueryStats{}, err
+	case finished:
+	case r.ctx.Err() != nil:
+		return QueryStats{}, &StatsUnavailableError{Reason: "the RowIterator was stopped before the end of the response"}
+	default:
+		return QueryStats{}, &StatsUnavailableError{Reason: "the RowIterator has not reached io.EOF"}
+	}
+
+	if !ok {
+		return QueryStats{}, &StatsUnavailableError{Reason: "the response has no QueryCompletionInformation table"}
+	}
+	payload, ok := resourceConsumption(dt)
+	if !ok {
+		return QueryStats{}, &StatsUnavailableError{Reason: "the QueryCompletionInformation table has no " + resourceConsumptionEvent + " event"}
+	}
+	stats, err := parseQueryStats(payload)
+	if err != nil {
+		return QueryStats{}, &StatsUnavailableError{Reason: err.Error()}
+	}
+	return stats, nil
+}
+
+// resourceConsumption returns the payload of the resourceConsumptionEvent row of a QueryCompletionInformation table.
+func resourceConsumption(dt frames.DataTable) (string, bool) {
+	eventCol, payloadCol := -1, -1
+	for i, c := range dt.Columns {
+		switch c.Name {
+		case "EventTypeName":
+			eventCol = i
+		case "Payload":
+			payloadCol = i
+		}
+	}
+	if eventCol < 0 || payloadCol < 0 {
+		return "", false
+	}
+
+	for _, row := range dt.KustoRows {
+		if eventCol >= len(row) || payloadCol >= len(row) {
+			continue
+		}
+		event, ok := row[eventCol].(value.String)
+		if !ok || event.Value != resourceConsumptionEvent {
+			continue
+		}
+		if payload, ok := row[payloadCol].(value.String); ok && payload.Valid {
+			return payload.Value, true
+		}
+	}
+	return "", false
+}
+
+// queryStatsPayload is the JSON payload of the resourceConsumptionEvent row.
+type queryStatsPayload struct {
+	ExecutionTime float64 `json:"ExecutionTime"`
+	ResourceUsage struct {
+		CPU struct {
+			User   string `json:"user"`
+			Kernel string `json:"kernel"`
+			Total  string `json:"total cpu"`
+		} `json:"cpu"`
+		Memory struct {
+			PeakPerNode int64 `json:"peak_per_node"`
+		} `json:"memory"`
+	} `json:"resource_usage"`
+	InputDatasetStatistics struct {
+		Extents struct {
+			Total   int64 `json:"total"`
+			Scanned int64 `json:"scanned"`
+		} `json:"extents"`
+		Rows struct {
+			Total   int64 `json:"total"`
+			Scanned int64 `json:"scanned"`
+		} `json:"rows"`
+	} `json:"input_dataset_statistics"`
+	DatasetStatistics []struct {
+		TableRowCount int64 `json:"table_row_count"`
+		TableSize     int64 `json:"table_size"`
+	} `json:"dataset_statistics"`
+}
+
+// parseQueryStats parses the payload of the resourceConsumptionEvent row.
+func parseQueryStats(payload string) (QueryStats, error) {
+	var p queryStatsPayload
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return QueryStats{}, fmt.Errorf("could not decode the %s payload: %w", resourceConsumptionEvent, err)
+	}
+
+	stats := QueryStats{
+		ExecutionTime:     time.Duration(p.ExecutionTime * float64(time.Second)),
+		MemoryPeakPerNode: p.ResourceUsage.Memory.PeakPerNode,
+		ExtentsTotal:      p.InputDatasetStatistics.Extents.Total,
+		ExtentsScanned:    p.InputDatasetStatistics.Extents.Scanned,
+		RowsTotal:         p.InputDatasetStatistics.Rows.Total,
+		RowsScanned:       p.InputDatasetStatistics.Rows.Scanned,
+		Payload:           payload,
+	}
+	for _, cpu := range []struct {
+		s string
+		d *time.Duration
+	}{
+		{p.ResourceUsage.CPU.User, &stats.CPUUser},
+		{p.ResourceUsage.CPU.Kernel, &stats.CPUKernel},
+		{p.ResourceUsage.CPU.Total, &stats.CPUTotal},
+	} {
+		if cpu.s == "" {
+			continue
+		}
+		var ts value.Timespan
+		if err := ts.Unmarshal(cpu.s); err != nil {
+			return QueryStats{}, fmt.Errorf("could not decode the CPU time %q: %w", cpu.s, err)
+		}
+		*cpu.d = ts.Value
+	}
+	for _, t := range p.DatasetStatistics {
+		stats.Tables = append(stats.Tables, TableStats{RowCount: t.TableRowCount, Size: t.TableSize})
+	}
+	return stats, nil
+}
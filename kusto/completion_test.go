@@ -0,0 +1,110 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionInformation(t *testing.T) {
+	t.Parallel()
+
+	want := QueryStats{
+		ExecutionTime:     1250000100 * time.Nanosecond,
+		CPUUser:           1500 * time.Millisecond,
+		CPUKernel:         15625 * time.Microsecond,
+		CPUTotal:          1515625 * time.Microsecond,
+		MemoryPeakPerNode: 16777312,
+		ExtentsTotal:      12,
+		ExtentsScanned:    4,
+		RowsTotal:         100000,
+		RowsScanned:       25000,
+		Tables:            []TableStats{{RowCount: 2, Size: 34}},
+	}
+
+	tests := []struct {
+		desc    string
+		fixture string
+		options []QueryOption
+		want    QueryStats
+		// wantErr is the error returned after the RowIterator reached io.EOF, if any.
+		wantErr error
+		// wantUnavailable indicates that a *StatsUnavailableError is returned after io.EOF.
+		wantUnavailable bool
+	}{
+		{desc: "Non-progressive", fixture: "completion.json", want: want},
+		{desc: "Progressive", fixture: "completion_progressive.json", want: want},
+		{desc: "PrimaryResultsOnly", fixture: "completion.json", options: []QueryOption{PrimaryResultsOnly()}, wantErr: NonPrimarySuppressedErr},
+		{desc: "No statistics", fixture: "warnings.json", wantUnavailable: true},
+		{desc: "No completion information", fixture: "datetime.json", wantUnavailable: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := os.ReadFile(filepath.Join("testdata", test.fixture))
+			require.NoError(t, err)
+			client := newTestClient(t, "https://completion.kusto.windows.net", fixtureTransport{body: body})
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"), test.options...)
+			require.NoError(t, err)
+			defer iter.Stop()
+			require.NoError(t, iter.Do(func(*table.Row) error { return nil }))
+
+			got, err := iter.CompletionInformation()
+			switch {
+			case test.wantErr != nil:
+				assert.Equal(t, test.wantErr, err)
+			case test.wantUnavailable:
+				var unavailable *StatsUnavailableError
+				assert.True(t, goErrors.As(err, &unavailable), "got %T: %v", err, err)
+			default:
+				require.NoError(t, err)
+				assert.NotEmpty(t, got.Payload)
+				got.Payload = ""
+				assert.Equal(t, test.want, got)
+			}
+		})
+	}
+}
+
+func TestCompletionInformationStopped(t *testing.T) {
+	t.Parallel()
+
+	r, w := io.Pipe()
+	defer w.Close()
+	client := newTestClient(t, "https://completion.kusto.windows.net", &pipeTransport{r: r})
+
+	// The rest of the response is never sent.
+	go func() {
+		_, _ = io.WriteString(w, "[\n"+`{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},`+"\n"+
+			`{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"x","ColumnType":"long"}]},`+"\n"+
+			`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[[1]]},`+"\n")
+	}()
+
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	row, _, err := iter.NextRowOrError()
+	require.NoError(t, err)
+	require.NotNil(t, row)
+
+	var unavailable *StatsUnavailableError
+	_, err = iter.CompletionInformation()
+	require.True(t, goErrors.As(err, &unavailable), "got %T: %v", err, err)
+	assert.Contains(t, unavailable.Reason, "io.EOF")
+
+	iter.Stop()
+	_, err = iter.CompletionInformation()
+	require.True(t, goErrors.As(err, &unavailable), "got %T: %v", err, err)
+	assert.Contains(t, unavailable.Reason, "stopped")
+}
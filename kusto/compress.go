@@ -0,0 +1,62 @@
+package kusto
+
+// compress.go implements WithRequestCompression(), which gzips the body of large requests.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// WithRequestCompression gzips the body of the requests that are at least minBytes long, and sends them with the
+// Content-Encoding: gzip header. This reduces the upload time of statements with large inline data, such as a
+// datatable or long let statements, at the cost of the CPU time to compress them. A body shorter than minBytes is
+// sent uncompressed, as compressing it saves little. minBytes must be at least 1, otherwise the option is ignored.
+// By default, requests are not compressed.
+func WithRequestCompression(minBytes int) Option {
+	return func(c *Client) {
+		if minBytes > 0 {
+			c.compressMin = minBytes
+		}
+	}
+}
+
+// compresses reports if a request body of n bytes is compressed.
+func (c *conn) compresses(n int) bool {
+	return c.compressMin > 0 && n >= c.compressMin
+}
+
+// compressRequest gzips the body of req, which is the content of buff, into zbuff if it is long enough.
+// zbuff must be kept until the request is sent.
+func (c *conn) compressRequest(req *http.Request, buff, zbuff *bytes.Buffer) error {
+	if !c.compresses(buff.Len()) {
+		return nil
+	}
+
+	zw := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(zw)
+	zw.Reset(zbuff)
+	if _, err := zw.Write(buff.Bytes()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	b := zbuff.Bytes()
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.ContentLength = int64(len(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package kusto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gzipRequest is a request received by a gzipHandler.
+type gzipRequest struct {
+	encoding      string
+	contentLength int64
+	msg           queryMsg
+}
+
+// gzipHandler is a fake service, which decompresses the body of the requests with the Content-Encoding: gzip header.
+// It answers the first unavailable requests with a 503.
+type gzipHandler struct {
+	unavailable int
+
+	mu       sync.Mutex
+	requests []gzipRequest
+}
+
+func (g *gzipHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	var msg queryMsg
+	if err := json.NewDecoder(body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g.mu.Lock()
+	n := len(g.requests)
+	g.requests = append(g.requests, gzipRequest{encoding: req.Header.Get("Content-Encoding"), contentLength: req.ContentLength, msg: msg})
+	g.mu.Unlock()
+
+	if n < g.unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":{"code":"ServiceUnavailable","message":"Try again later","@permanent":false}}`))
+		return
+	}
+	_, _ = w.Write([]byte(`[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`))
+}
+
+func (g *gzipHandler) received() []gzipRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]gzipRequest(nil), g.requests...)
+}
+
+// handlerTransport is a fake http.RoundTripper that serves the requests with an http.Handler.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (h handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func TestRequestCompression(t *testing.T) {
+	t.Parallel()
+
+	long := "T | where Name in (" + strings.Repeat(`"a name", `, 500) + `"a name")`
+
+	tests := []struct {
+		desc        string
+		options     []Option
+		query       string
+		unavailable int
+		wantGzip    bool
+	}{
+		{desc: "Not compressed by default", query: long},
+		{desc: "Short query", options: []Option{WithRequestCompression(1024)}, query: "T"},
+		{desc: "Long query", options: []Option{WithRequestCompression(1024)}, query: long, wantGzip: true},
+		{desc: "Invalid threshold", options: []Option{WithRequestCompression(0)}, query: long},
+		{
+			desc:        "Retried",
+			options:     []Option{WithRequestCompression(1024), WithRetryOptions(2, time.Millisecond, time.Millisecond)},
+			query:       long,
+			unavailable: 1,
+			wantGzip:    true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler := &gzipHandler{unavailable: test.unavailable}
+			client := &Client{endpoint: "https://gzip.kusto.windows.net", http: &http.Client{Transport: handlerTransport{handler}}}
+			for _, o := range test.options {
+				o(client)
+			}
+			conn, err := newConn("https://gzip.kusto.windows.net", Authorization{}, client.http, NewClientDetails("", ""))
+			require.NoError(t, err)
+			conn.retry = client.retry
+			conn.compressMin = client.compressMin
+			client.conn = conn
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("", UnsafeStmt(unsafe.Stmt{Add: true})).UnsafeAdd(test.query))
+			require.NoError(t, err)
+			iter.Stop()
+
+			received := handler.received()
+			require.Len(t, received, test.unavailable+1)
+			for _, got := range received {
+				assert.Equal(t, "db", got.msg.DB)
+				assert.Equal(t, test.query, got.msg.CSL)
+				if test.wantGzip {
+					assert.Equal(t, "gzip", got.encoding)
+					// The compressed body is much shorter than the query.
+					assert.Less(t, got.contentLength, int64(len(test.query)/4))
+				} else {
+					assert.Empty(t, got.encoding)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestCompressionPreview(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{endpoint: "https://gzip.kusto.windows.net", http: &http.Client{Transport: handlerTransport{&gzipHandler{}}}}
+	WithRequestCompression(10)(client)
+	c, err := newConn("https://gzip.kusto.windows.net", Authorization{}, client.http, NewClientDetails("", ""))
+	require.NoError(t, err)
+	c.compressMin = client.compressMin
+	client.conn = c
+
+	// The preview has the header of a compressed request, but shows the body uncompressed.
+	preview, err := client.RequestPreview("db", NewStmt("T | take 1000"))
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", preview.Header.Get("Content-Encoding"))
+	var msg queryMsg
+	require.NoError(t, json.Unmarshal([]byte(preview.Body), &msg))
+	assert.Equal(t, "T | take 1000", msg.CSL)
+
+	// The option is used by the connections of a new Client.
+	client, err = New(NewConnectionStringBuilder("https://gzip.kusto.windows.net"), WithRequestCompression(2048))
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, 2048, client.conn.(*conn).compressMin)
+}
+
+/*
+BenchmarkRequestCompression/uncompressed         	   49354	     26852 ns/op	    6521 B/op	      23 allocs/op
+BenchmarkRequestCompression/compressed           	   16002	     73470 ns/op	    7380 B/op	      30 allocs/op
+*/
+func BenchmarkRequestCompression(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stmt := NewStmt("", UnsafeStmt(unsafe.Stmt{Add: true})).UnsafeAdd("T | where Name in (" + strings.Repeat(`"a name", `, 500) + `"a name")`)
+	opts, err := setQueryOptions(ctx, errors.OpQuery, stmt, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name        string
+		compressMin int
+	}{
+		{name: "uncompressed"},
+		{name: "compressed", compressMin: 1024},
+	} {
+		bench := bench
+		b.Run(bench.name, func(b *testing.B) {
+			c, err := newConn("https://gzip.kusto.windows.net", Authorization{}, &http.Client{}, NewClientDetails("", ""))
+			if err != nil {
+				b.Fatal(err)
+			}
+			c.compressMin = bench.compressMin
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// This follows doRequest(), whose buffers and gzip.Writer come from pools.
+				buff := bufferPool.Get().(*bytes.Buffer)
+				buff.Reset()
+				zbuff := bufferPool.Get().(*bytes.Buffer)
+				zbuff.Reset()
+
+				_, req, err := c.newRequest(execQuery, "db", stmt, *opts.requestProperties, buff)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := c.compressRequest(req, buff, zbuff); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(io.Discard, req.Body); err != nil {
+					b.Fatal(err)
+				}
+
+				bufferPool.Put(zbuff)
+				bufferPool.Put(buff)
+			}
+		})
+	}
+}
@@ -6,22 +6,22 @@ package kusto
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
 	v1 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v1"
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
 	"github.com/Azure/azure-kusto-go/kusto/internal/response"
-	truestedEndpoints "github.com/Azure/azure-kusto-go/kusto/trusted_endpoints"
 	"github.com/google/uuid"
 )
 
@@ -41,31 +41,65 @@ type conn struct {
 	client                         *http.Client
 	endpointValidated              atomic.Bool
 	clientDetails                  *ClientDetails
+	hedgeStats                     hedgeCounters
+	decompressors                  response.Decompressors
+	// retry is set by WithRetryOptions(), nil to never retry.
+	retry *retryPolicy
+	// compressMin is set by WithRequestCompression(), 0 to never compress.
+	compressMin int
+	// callbacks are set by WithCallbacks(), nil if there are none.
+	callbacks *Callbacks
+	// insecure is set for plain http endpoints, which are not checked against the trusted endpoints.
+	insecure bool
+	// trustedEndpointPolicy is set by WithTrustedEndpointPolicy(), nil to use the trusted endpoints.
+	trustedEndpointPolicy func(host string) bool
 }
 
 // newConn returns a new conn object with an injected http.Client
 func newConn(endpoint string, auth Authorization, client *http.Client, clientDetails *ClientDetails) (*conn, error) {
-	if !validURL.MatchString(endpoint) {
-		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "endpoint is not valid(%s), should be https://<cluster name>.*", endpoint).SetNoRetry()
-	}
+	return newConnAllowInsecure(endpoint, false, auth, client, clientDetails)
+}
 
+// newConnAllowInsecure is newConn, also accepting plain http endpoints on any host if allowInsecure is set, see
+// ConnectionStringBuilder.AllowInsecure(). Plain http endpoints on the local host are always accepted, for the emulator.
+func newConnAllowInsecure(endpoint string, allowInsecure bool, auth Authorization, client *http.Client, clientDetails *ClientDetails) (*conn, error) {
 	u, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "could not parse the endpoint(%s): %s", endpoint, err).SetNoRetry()
 	}
 
+	insecure := u.Scheme == "http" && u.Host != ""
+	switch {
+	case insecure && !allowInsecure && !isLocalHost(u.Hostname()):
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "endpoint is not valid(%s), plain http is only accepted for the local "+
+			"host, unless ConnectionStringBuilder.AllowInsecure() is set", endpoint).SetNoRetry()
+	case !insecure && !validURL.MatchString(endpoint):
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "endpoint is not valid(%s), should be https://<cluster name>.*", endpoint).SetNoRetry()
+	}
+
 	c := &conn{
+		endpoint:      endpoint,
 		auth:          auth,
-		endMgmt:       &url.URL{Scheme: "https", Host: u.Host, Path: "/v1/rest/mgmt"},
-		endQuery:      &url.URL{Scheme: "https", Host: u.Host, Path: "/v2/rest/query"},
-		streamQuery:   &url.URL{Scheme: "https", Host: u.Host, Path: "/v1/rest/ingest/"},
+		endMgmt:       &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/v1/rest/mgmt"},
+		endQuery:      &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/v2/rest/query"},
+		streamQuery:   &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/v1/rest/ingest/"},
 		client:        client,
 		clientDetails: clientDetails,
+		insecure:      insecure,
 	}
 
 	return c, nil
 }
 
+// isLocalHost reports whether host, without its port, is the local host.
+func isLocalHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 type queryMsg struct {
 	DB         string            `json:"db"`
 	CSL        string            `json:"csl"`
@@ -84,23 +118,59 @@ func (c *conn) query(ctx context.Context, db string, query Stmt, options *queryO
 		return execResp{}, errors.ES(errors.OpQuery, errors.KClientArgs, "a Stmt to Query() cannot begin with a period(.), only Mgmt() calls can do that").SetNoRetry()
 	}
 
-	return c.execute(ctx, execQuery, db, query, *options.requestProperties)
+	ctx = withUserAssertion(ctx, options.userAssertion)
+	decoded := &atomic.Int64{}
+	dec := &v2.Decoder{PrimaryResultsOnly: options.primaryResultsOnly, Bytes: decoded}
+
+	var resp execResp
+	var err error
+	if options.hedge != nil {
+		resp, err = c.executeHedged(ctx, db, query, *options.requestProperties, *options.hedge, dec)
+	} else {
+		resp, err = c.execute(ctx, execQuery, db, query, *options.requestProperties, dec)
+	}
+	if err != nil {
+		return execResp{}, err
+	}
+	resp.bytes = decoded
+	return resp, nil
 }
 
 // mgmt is used to do management queries to Kusto.
 func (c *conn) mgmt(ctx context.Context, db string, query Stmt, options *mgmtOptions) (execResp, error) {
-	return c.execute(ctx, execMgmt, db, query, *options.requestProperties)
+	decoded := &atomic.Int64{}
+	resp, err := c.execute(withUserAssertion(ctx, options.userAssertion), execMgmt, db, query, *options.requestProperties, &v1.Decoder{Bytes: decoded})
+	if err != nil {
+		return execResp{}, err
+	}
+	resp.bytes = decoded
+	return resp, nil
+}
+
+func (c *conn) queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (jsonResp, error) {
+	return c.doRequestToJson(withUserAssertion(ctx, options.userAssertion), execQuery, db, query, *options.requestProperties)
 }
 
-func (c *conn) queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (string, error) {
-	_, _, _, body, e := c.doRequest(ctx, execQuery, db, query, *options.requestProperties)
+// doRequestToJson sends the request and returns the response body, which must be complete JSON.
+func (c *conn) doRequestToJson(ctx context.Context, execType int, db string, query Stmt, properties requestProperties) (jsonResp, error) {
+	_, reqHeader, respHeader, body, e := c.doRequest(ctx, execType, db, query, properties)
 	if e != nil {
-		return "", e
+		return jsonResp{}, e
 	}
 
 	defer body.Close()
 	all, e := io.ReadAll(body)
-	return string(all), e
+	if e != nil {
+		return jsonResp{}, e
+	}
+	if e := checkJSON(all); e != nil {
+		return jsonResp{}, e
+	}
+	return jsonResp{reqHeader: reqHeader, respHeader: respHeader, body: string(all)}, nil
+}
+
+func (c *conn) mgmtToJson(ctx context.Context, db string, query Stmt, options *mgmtOptions) (jsonResp, error) {
+	return c.doRequestToJson(withUserAssertion(ctx, options.userAssertion), execMgmt, db, query, *options.requestProperties)
 }
 
 const (
@@ -111,25 +181,25 @@ const (
 type execResp struct {
 	reqHeader  http.Header
 	respHeader http.Header
-	frameCh    chan frames.Frame
+	frameCh    <-chan frames.Frame
+	// bytes is the size of the response decoded so far, nil if unknown. See RowIterator.Stats().
+	bytes *atomic.Int64
+}
+
+// jsonResp is the response of a queryToJson() or mgmtToJson() call.
+type jsonResp struct {
+	reqHeader  http.Header
+	respHeader http.Header
+	body       string
 }
 
-func (c *conn) execute(ctx context.Context, execType int, db string, query Stmt, properties requestProperties) (execResp, error) {
+// execute sends the request and decodes the response body with dec, which must match the framing used by execType.
+func (c *conn) execute(ctx context.Context, execType int, db string, query Stmt, properties requestProperties, dec frames.Decoder) (execResp, error) {
 	op, reqHeader, respHeader, body, e := c.doRequest(ctx, execType, db, query, properties)
 	if e != nil {
 		return execResp{}, e
 	}
 
-	var dec frames.Decoder
-	switch execType {
-	case execMgmt:
-		dec = &v1.Decoder{}
-	case execQuery:
-		dec = &v2.Decoder{}
-	default:
-		return execResp{}, errors.ES(op, errors.KInternal, "unknown execution type was %v", execType).SetNoRetry()
-	}
-
 	frameCh := dec.Decode(ctx, body, op)
 
 	return execResp{reqHeader: reqHeader, respHeader: respHeader, frameCh: frameCh}, nil
@@ -137,7 +207,99 @@ func (c *conn) execute(ctx context.Context, execType int, db string, query Stmt,
 
 func (c *conn) doRequest(ctx context.Context, execType int, db string, query Stmt, properties requestProperties) (errors.Op, http.Header, http.Header,
 	io.ReadCloser, error) {
-	err := c.validateEndpoint()
+	buff := bufferPool.Get().(*bytes.Buffer)
+	buff.Reset()
+	defer bufferPool.Put(buff)
+
+	op, req, err := c.newRequest(execType, db, query, properties, buff)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	zbuff := bufferPool.Get().(*bytes.Buffer)
+	zbuff.Reset()
+	defer bufferPool.Put(zbuff)
+	if err := c.compressRequest(req, buff, zbuff); err != nil {
+		return 0, nil, nil, nil, errors.E(op, errors.KInternal, fmt.Errorf("could not compress the Query message: %w", err))
+	}
+	header := req.Header
+
+	if c.auth.TokenProvider != nil && c.auth.TokenProvider.AuthorizationRequired() {
+		if err := c.validateEndpoint(); err != nil {
+			return 0, nil, nil, nil, err
+		}
+		c.auth.TokenProvider.SetHttp(c.client)
+		token, tokenType, tkerr := c.auth.TokenProvider.AcquireToken(ctx)
+		if tkerr != nil {
+			return 0, nil, nil, nil, errors.ES(op, errors.KInternal, "Error while getting token : %s", tkerr)
+		}
+		header.Add("Authorization", fmt.Sprintf("%s %s", tokenType, token))
+	}
+
+	var info RequestInfo
+	if c.callbacks != nil {
+		info = c.callbacks.requestInfo(op, req, db, query)
+	}
+
+	// The request is sent again while the policy of the client allows it, see WithRetryOptions().
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			req.Body, _ = req.GetBody()
+		}
+
+		info.Attempt = attempt
+		c.callbacks.onRequest(ctx, info)
+		start := nower()
+		resp, err := c.client.Do(req.WithContext(ctx))
+		if err != nil {
+			// TODO(jdoak): We need a http error unwrap function that pulls out an *errors.Error.
+			e := errors.E(op, errors.KHTTPError, fmt.Errorf("with query %q: %w", c.callbacks.csl(query), err)).SetCorrelation(header.Get(clientRequestIDHeader), "", 0)
+			c.callbacks.onResponse(ctx, info, start, nil, e)
+			if execType != execMgmt && errors.Retry(e) && c.waitRetry(ctx, attempt, e) {
+				continue
+			}
+			return 0, nil, nil, nil, e
+		}
+
+		body, err := c.decompressors.TranslateBody(resp, op)
+		if err != nil {
+			c.callbacks.onResponse(ctx, info, start, resp, err)
+			return 0, nil, nil, nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			httpErr := errors.HTTP(op, resp.Status, resp.StatusCode, body, fmt.Sprintf("error from Kusto endpoint for query %q: ", c.callbacks.csl(query)))
+			httpErr.Header = resp.Header
+			httpErr.SetCorrelation(header.Get(clientRequestIDHeader), resp.Header.Get(activityIDHeader), resp.StatusCode)
+			if d := httpErr.ServiceHints().RetryAfter; d != nil {
+				httpErr.SetRetryAfter(*d)
+			}
+			c.callbacks.onResponse(ctx, info, start, resp, httpErr)
+			if retryableStatus(execType, resp.StatusCode) && errors.Retry(&httpErr.KustoError) && c.waitRetry(ctx, attempt, &httpErr.KustoError) {
+				continue
+			}
+			return 0, nil, nil, nil, httpErr
+		}
+		c.callbacks.onResponse(ctx, info, start, resp, nil)
+		return op, header, resp.Header, body, nil
+	}
+}
+
+// waitRetry waits before the request that failed on attempt with err is retried, and reports if it is to be retried.
+func (c *conn) waitRetry(ctx context.Context, attempt int, err *errors.Error) bool {
+	if c.retry == nil {
+		return false
+	}
+	delay := c.retry.retryAfter(attempt, err)
+	if !c.retry.shouldRetry(ctx, attempt, delay) {
+		return false
+	}
+	return c.retry.sleep(ctx, delay) == nil
+}
+
+// newRequest returns the request of a call without its Authorization header, with the JSON body written to buff.
+// It is shared by doRequest() and Client.RequestPreview(), so that a preview is the request that is sent.
+func (c *conn) newRequest(execType int, db string, query Stmt, properties requestProperties, buff *bytes.Buffer) (errors.Op, *http.Request, error) {
 	var op errors.Op
 	if execType == execQuery {
 		op = errors.OpQuery
@@ -148,91 +310,77 @@ func (c *conn) doRequest(ctx context.Context, execType int, db string, query Stm
 	header := c.getHeaders(properties)
 
 	var endpoint *url.URL
-	buff := bufferPool.Get().(*bytes.Buffer)
-	buff.Reset()
-	defer bufferPool.Put(buff)
-
 	switch execType {
 	case execQuery, execMgmt:
-		err = json.NewEncoder(buff).Encode(
-			queryMsg{
-				DB:         db,
-				CSL:        query.String(),
-				Properties: properties,
-			},
-		)
+		msg := queryMsg{
+			DB:         db,
+			CSL:        query.String(),
+			Properties: properties,
+		}
+		// The pooled buffer's storage is reused by appending to its empty contents.
+		b, err := msg.appendJSON(buff.Bytes()[:0])
 		if err != nil {
-			return 0, nil, nil, nil, errors.E(op, errors.KInternal, fmt.Errorf("could not JSON marshal the Query message: %w", err))
+			return 0, nil, errors.E(op, errors.KInternal, fmt.Errorf("could not JSON marshal the Query message: %w", err))
 		}
+		buff.Write(b)
 		if execType == execQuery {
 			endpoint = c.endQuery
 		} else {
 			endpoint = c.endMgmt
 		}
 	default:
-		return 0, nil, nil, nil, errors.ES(op, errors.KInternal, "internal error: did not understand the type of execType: %d", execType)
-	}
-
-	if c.auth.TokenProvider != nil && c.auth.TokenProvider.AuthorizationRequired() {
-		c.auth.TokenProvider.SetHttp(c.client)
-		token, tokenType, tkerr := c.auth.TokenProvider.AcquireToken(ctx)
-		if tkerr != nil {
-			return 0, nil, nil, nil, errors.ES(op, errors.KInternal, "Error while getting token : %s", tkerr)
-		}
-		header.Add("Authorization", fmt.Sprintf("%s %s", tokenType, token))
+		return 0, nil, errors.ES(op, errors.KInternal, "internal error: did not understand the type of execType: %d", execType)
 	}
 
+	// The body can be read again, so that the request can be retried.
+	b := buff.Bytes()
 	req := &http.Request{
-		Method: http.MethodPost,
-		URL:    endpoint,
-		Header: header,
-		Body:   io.NopCloser(buff),
-	}
-
-	resp, err := c.client.Do(req.WithContext(ctx))
-	if err != nil {
-		// TODO(jdoak): We need a http error unwrap function that pulls out an *errors.Error.
-		return 0, nil, nil, nil, errors.E(op, errors.KHTTPError, fmt.Errorf("with query %q: %w", query.String(), err))
-	}
-
-	body, err := response.TranslateBody(resp, op)
-	if err != nil {
-		return 0, nil, nil, nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, nil, nil, nil, errors.HTTP(op, resp.Status, resp.StatusCode, body, fmt.Sprintf("error from Kusto endpoint for query %q: ", query.String()))
+		Method:        http.MethodPost,
+		URL:           endpoint,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		GetBody: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		},
 	}
-	return op, header, resp.Header, body, nil
+	return op, req, nil
 }
 
+// validateEndpoint returns an *UntrustedEndpointError if the endpoint is not trusted, see WithTrustedEndpointPolicy().
+// It is called before a token is sent, and once the endpoint is trusted, it is not checked again.
 func (c *conn) validateEndpoint() error {
-	if !c.endpointValidated.Load() {
-		var err error
-		if cloud, err := GetMetadata(c.endpoint, c.client); err == nil {
-			err = truestedEndpoints.Instance.ValidateTrustedEndpoint(c.endpoint, cloud.LoginEndpoint)
-			if err == nil {
-				c.endpointValidated.Store(true)
-			}
-		}
+	// The emulator and other plain http endpoints are not among the trusted endpoints.
+	if c.insecure || c.endpointValidated.Load() {
+		return nil
+	}
 
+	loginEndpoint := ""
+	if c.trustedEndpointPolicy == nil {
+		cloud, err := GetMetadata(c.endpoint, c.client)
+		if err != nil {
+			return errors.E(errors.OpServConn, errors.KHTTPError, fmt.Errorf("could not get the metadata to validate the endpoint: %w", err))
+		}
+		loginEndpoint = cloud.LoginEndpoint
+	}
+	if err := checkTrustedEndpoint(c.endpoint, loginEndpoint, c.trustedEndpointPolicy); err != nil {
 		return err
 	}
-
+	c.endpointValidated.Store(true)
 	return nil
 }
 
 func (c *conn) getHeaders(properties requestProperties) http.Header {
 	header := http.Header{}
 	header.Add("Accept", "application/json")
-	header.Add("Accept-Encoding", "gzip")
+	header.Add("Accept-Encoding", c.decompressors.AcceptEncoding())
 	header.Add("Content-Type", "application/json; charset=utf-8")
 	header.Add("x-ms-version", "2019-02-13")
 
 	if properties.ClientRequestID != "" {
-		header.Add("x-ms-client-request-id", properties.ClientRequestID)
+		header.Add(clientRequestIDHeader, properties.ClientRequestID)
 	} else {
-		header.Add("x-ms-client-request-id", "KGC.execute;"+uuid.New().String())
+		header.Add(clientRequestIDHeader, "KGC.execute;"+uuid.New().String())
 	}
 
 	if properties.Application != "" {
@@ -248,10 +396,19 @@ func (c *conn) getHeaders(properties requestProperties) http.Header {
 	}
 
 	header.Add("x-ms-client-version", c.clientDetails.ClientVersionForTracing())
+
+	if properties.hedgeAttempt > 0 {
+		header.Add(hedgeHeader, strconv.Itoa(properties.hedgeAttempt))
+	}
 	return header
 }
 
+// Close closes the idle connections and stops the background refresh of the token, see TokenProvider.Close(). It can be
+// called more than once.
 func (c *conn) Close() error {
 	c.client.CloseIdleConnections()
+	if c.auth.TokenProvider != nil {
+		c.auth.TokenProvider.Close()
+	}
 	return nil
 }
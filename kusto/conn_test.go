@@ -1,12 +1,21 @@
 package kusto
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"strings"
-	"testing"
 )
 
 func TestHeaders(t *testing.T) {
@@ -60,7 +69,7 @@ func TestHeaders(t *testing.T) {
 			queryOptions = append(queryOptions, Application(tt.propApplication))
 			queryOptions = append(queryOptions, User(tt.propUser))
 
-			opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("test"), queryOptions...)
+			opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("test"), nil, queryOptions...)
 			require.NoError(t, err)
 
 			client, err := New(kcsb)
@@ -114,6 +123,13 @@ func TestSetConnectorDetails(t *testing.T) {
 			expectedApp:  "Kusto.testName:{testVersion}|App.{testApp}:{testAppVersion}|testKey:{testValue}",
 			expectedUser: "testUser",
 		},
+		{
+			testName: "TestEscaping",
+			name:     "test:Name|x", version: "1.2 {beta}", appName: "test|App {x}", appVersion: "1:2|3",
+			additionalFields: []StringPair{{"test:Key|{x}", "test|Value {x}"}},
+			expectedApp:      "Kusto.test_Name_x:{1.2_beta_}|App.{test_App_x_}:{1:2_3}|test_Key_x_:{test_Value_x_}",
+			expectedUser:     "[none]",
+		},
 	}
 	for _, tt := range tests {
 		tt := tt // Capture
@@ -136,3 +152,255 @@ func TestSetConnectorDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestWithConnectorDetails(t *testing.T) {
+	t.Parallel()
+
+	kcsb := NewConnectionStringBuilder("https://test.kusto.windows.net")
+	kcsb.ApplicationForTracing = "kcsbApplication"
+	kcsb.UserForTracing = "kcsbUser"
+
+	client, err := New(kcsb, WithConnectorDetails("MyConnector", "1.2.3", "app|x", "4.5", true, "connectorUser", StringPair{Key: "key", Value: "value"}))
+	require.NoError(t, err)
+
+	const wantApp = "Kusto.MyConnector:{1.2.3}|App.{app_x}:{4.5}|key:{value}"
+	assert.Equal(t, wantApp, client.ClientDetails().ApplicationForTracing())
+	assert.Equal(t, "connectorUser", client.ClientDetails().UserNameForTracing())
+
+	opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("test"), nil)
+	require.NoError(t, err)
+	headers := client.conn.(*conn).getHeaders(*opts.requestProperties)
+	assert.Equal(t, wantApp, headers.Get("x-ms-app"))
+	assert.Equal(t, "connectorUser", headers.Get("x-ms-user"))
+}
+
+func TestMgmtParameters(t *testing.T) {
+	t.Parallel()
+
+	stmt := NewStmt(".set-or-append Target <| Source | where Timestamp > since and Name == name").
+		MustDefinitions(NewDefinitions().Must(ParamTypes{
+			"since": ParamType{Type: types.DateTime},
+			"name":  ParamType{Type: types.String},
+		})).
+		MustParameters(NewParameters().Must(QueryValues{
+			"since": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			"name":  "x",
+		}))
+
+	tests := []struct {
+		desc    string
+		stmt    Stmt
+		options []MgmtOption
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			desc: "No parameters",
+			stmt: NewStmt(".show tables"),
+		},
+		{
+			desc: "Parameters",
+			stmt: stmt,
+			want: map[string]string{"since": "datetime(2024-01-02T00:00:00Z)", "name": "x"},
+		},
+		{
+			desc: "Definitions without values",
+			stmt: NewStmt(".show table T").MustDefinitions(NewDefinitions().Must(ParamTypes{"T": ParamType{Type: types.String}})),
+		},
+		{
+			desc:    "Ingestion endpoint",
+			stmt:    stmt,
+			options: []MgmtOption{IngestionEndpoint()},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &captureTransport{}
+			client := newTestClient(t, "https://mgmt.kusto.windows.net", transport)
+
+			iter, err := client.Mgmt(context.Background(), "db", test.stmt, test.options...)
+			if test.wantErr {
+				assert.Error(t, err)
+				assert.Empty(t, transport.sent())
+				return
+			}
+			require.NoError(t, err)
+			iter.Stop()
+
+			sent := transport.sent()
+			require.Len(t, sent, 1)
+			var msg queryMsg
+			require.NoError(t, json.Unmarshal([]byte(sent[0].Body), &msg))
+			assert.Equal(t, test.stmt.String(), msg.CSL)
+			assert.Equal(t, test.want, msg.Properties.Parameters)
+			assert.Equal(t, test.want, iter.RequestProperties().Parameters)
+		})
+	}
+}
+
+// mgmtJSONTransport is a fake http.RoundTripper that answers every management command with body, gzipped if zip is set.
+type mgmtJSONTransport struct {
+	body string
+	zip  bool
+
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (m *mgmtJSONTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v1/rest/mgmt") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	m.mu.Lock()
+	m.hosts = append(m.hosts, req.URL.Host)
+	m.mu.Unlock()
+
+	header := http.Header{}
+	body := []byte(m.body)
+	if m.zip {
+		b := &bytes.Buffer{}
+		zw := gzip.NewWriter(b)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = b.Bytes()
+		header.Set("Content-Encoding", "gzip")
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestMgmtToJson(t *testing.T) {
+	t.Parallel()
+
+	// The spacing of the response is kept.
+	const v1 = `{"Tables": [{"TableName": "Table_0", "Columns": [{"ColumnName": "TableName", "DataType": "String"}], "Rows": [["T"]]}]}`
+
+	tests := []struct {
+		desc     string
+		body     string
+		zip      bool
+		options  []MgmtOption
+		client   []Option
+		want     string
+		wantHost string
+		wantErr  bool
+	}{
+		{desc: "Response", body: v1, want: v1, wantHost: "mgmt.kusto.windows.net"},
+		{desc: "Compressed response", body: v1, zip: true, want: v1, wantHost: "mgmt.kusto.windows.net"},
+		{desc: "Ingestion endpoint", body: v1, options: []MgmtOption{IngestionEndpoint()}, want: v1, wantHost: "ingest-mgmt.kusto.windows.net"},
+		{desc: "Partial response", body: v1[:len(v1)-10], wantHost: "mgmt.kusto.windows.net", wantErr: true},
+		{desc: "Read-only client", body: v1, client: []Option{WithReadOnlyClient()}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &mgmtJSONTransport{body: test.body, zip: test.zip}
+			client := newTestClient(t, "https://mgmt.kusto.windows.net", transport)
+			var kinds []CallKind
+			WithStatementInterceptor(func(ctx context.Context, info CallInfo) (CallInfo, error) {
+				kinds = append(kinds, info.Kind)
+				return info, nil
+			})(client)
+			for _, o := range test.client {
+				o(client)
+			}
+
+			got, err := client.MgmtToJson(context.Background(), "db", NewStmt(".show tables"), test.options...)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.want, got)
+			}
+
+			transport.mu.Lock()
+			defer transport.mu.Unlock()
+			if test.wantHost == "" {
+				assert.Empty(t, transport.hosts)
+				return
+			}
+			assert.Equal(t, []string{test.wantHost}, transport.hosts)
+			assert.Equal(t, []CallKind{CallMgmtToJSON}, kinds)
+		})
+	}
+
+	// The mock connection answers with an empty v1 response.
+	client := &Client{conn: mockConn{}}
+	got, err := client.MgmtToJson(context.Background(), "db", NewStmt(".show tables"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"Tables":[]}`, got)
+}
+
+func TestInsecureEndpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		endpoint      string
+		allowInsecure bool
+		wantQuery     string
+		wantInsecure  bool
+		wantErr       bool
+	}{
+		{desc: "Cluster", endpoint: "https://help.kusto.windows.net", wantQuery: "https://help.kusto.windows.net/v2/rest/query"},
+		{desc: "Localhost", endpoint: "http://localhost:8080", wantQuery: "http://localhost:8080/v2/rest/query", wantInsecure: true},
+		{desc: "Loopback", endpoint: "http://127.0.0.1:8080", wantQuery: "http://127.0.0.1:8080/v2/rest/query", wantInsecure: true},
+		{desc: "IPv6 loopback", endpoint: "http://[::1]:8080", wantQuery: "http://[::1]:8080/v2/rest/query", wantInsecure: true},
+		{desc: "Remote host", endpoint: "http://emulator.corp.net:8080", wantErr: true},
+		{
+			desc:          "Remote host allowed",
+			endpoint:      "http://emulator.corp.net:8080",
+			allowInsecure: true,
+			wantQuery:     "http://emulator.corp.net:8080/v2/rest/query",
+			wantInsecure:  true,
+		},
+		{desc: "Other scheme", endpoint: "ftp://localhost:8080", allowInsecure: true, wantErr: true},
+		{desc: "Localhost on https", endpoint: "https://localhost:8080", wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := newConnAllowInsecure(test.endpoint, test.allowInsecure, Authorization{}, &http.Client{}, NewClientDetails("", ""))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantQuery, c.endQuery.String())
+			assert.Equal(t, test.wantInsecure, c.insecure)
+			if test.wantInsecure {
+				assert.NoError(t, c.validateEndpoint())
+			}
+		})
+	}
+
+	// A Client of the emulator sends its queries on plain http.
+	transport := &captureTransport{}
+	client, err := New(NewConnectionStringBuilder("http://emulator.corp.net:8080").AllowInsecure(true),
+		WithHttpClient(&http.Client{Transport: transport}))
+	require.NoError(t, err)
+	defer client.Close()
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	iter.Stop()
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "http://emulator.corp.net:8080/v2/rest/query", sent[0].URL)
+
+	_, err = New(NewConnectionStringBuilder("http://emulator.corp.net:8080"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,22 @@
+package kusto
+
+import (
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// CreateTableStmt returns a Stmt holding the `.create table` command that creates a table named tableName with the
+// columns, to be run with Client.Mgmt(). The names are quoted as needed, so they can come from a query result:
+//
+//	row, err := iter.Next()
+//	...
+//	stmt, err := CreateTableStmt("Destination", row.ColumnTypes)
+//
+// It returns an error if the columns are not valid, for example if two of them have the same name.
+// See table.Columns.ToCreateTableCommand().
+func CreateTableStmt(tableName string, columns table.Columns) (Stmt, error) {
+	cmd, err := columns.ToCreateTableCommand(tableName)
+	if err != nil {
+		return Stmt{}, err
+	}
+	return Stmt{queryStr: cmd}, nil
+}
@@ -0,0 +1,22 @@
+package kusto
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTableStmt(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "Name", Type: types.String}, {Name: "in", Type: types.Dynamic}}
+	stmt, err := CreateTableStmt("Dest", columns)
+	require.NoError(t, err)
+	assert.Equal(t, ".create table Dest (Name:string, ['in']:dynamic)", stmt.String())
+
+	_, err = CreateTableStmt("Dest", append(columns, table.Column{Name: "Name", Type: types.Long}))
+	assert.EqualError(t, err, "cannot create table Dest: column[2].Name(Name) is already defined")
+}
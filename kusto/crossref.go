@@ -0,0 +1,145 @@
+package kusto
+
+// crossref.go implements the Stmt helpers that refer to other databases and clusters, such as
+// `cluster("help.kusto.windows.net").database("Samples")`.
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// AddDatabaseRef adds a reference to another database of the cluster to the Stmt, such as `database("Other")`.
+// The name is escaped, so it can safely come from untrusted input. Tables are referenced by adding them after it:
+//
+//	stmt, err := NewStmt("union T, ").AddDatabaseRef(db)
+//	stmt = stmt.Add(".T")
+func (s Stmt) AddDatabaseRef(db string) (Stmt, error) {
+	if strings.TrimSpace(db) == "" {
+		return s, fmt.Errorf("database name cannot be empty")
+	}
+	s.queryStr += "database(" + table.QuoteString(db) + ")"
+	return s, nil
+}
+
+// MustAddDatabaseRef is the same as AddDatabaseRef(), but it must succeed or it panics.
+func (s Stmt) MustAddDatabaseRef(db string) Stmt {
+	s, err := s.AddDatabaseRef(db)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// AddClusterRef adds a reference to a database of another cluster to the Stmt, such as
+// `cluster("help.kusto.windows.net").database("Samples")`. endpoint is the URL of the cluster, such as
+// "https://help.kusto.windows.net", or its host name. It must not have credentials, a query or a fragment.
+// Both names are escaped, so they can safely come from untrusted input.
+func (s Stmt) AddClusterRef(endpoint, db string) (Stmt, error) {
+	ref, err := clusterRef(endpoint)
+	if err != nil {
+		return s, err
+	}
+	if strings.TrimSpace(db) == "" {
+		return s, fmt.Errorf("database name cannot be empty")
+	}
+	s.queryStr += "cluster(" + table.QuoteString(ref) + ").database(" + table.QuoteString(db) + ")"
+	return s, nil
+}
+
+// MustAddClusterRef is the same as AddClusterRef(), but it must succeed or it panics.
+func (s Stmt) MustAddClusterRef(endpoint, db string) Stmt {
+	s, err := s.AddClusterRef(endpoint, db)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ClusterRef returns the endpoint of the client in the form used by the cluster() function, which is the host
+// name of the cluster, such as "help.kusto.windows.net". Endpoints with a port other than 443 or a path are returned
+// as a full URL.
+func (c *Client) ClusterRef() string {
+	ref, err := clusterRef(c.endpoint)
+	if err != nil {
+		return c.endpoint
+	}
+	return ref
+}
+
+// clusterRef validates endpoint and returns its canonical form for the cluster() function.
+func clusterRef(endpoint string) (string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return "", fmt.Errorf("cluster endpoint cannot be empty")
+	}
+	raw := endpoint
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("cluster endpoint %q is not a valid URL: %w", endpoint, err)
+	}
+	switch {
+	case u.Scheme != "https" && u.Scheme != "http":
+		return "", fmt.Errorf("cluster endpoint %q must use https", endpoint)
+	case u.User != nil:
+		return "", fmt.Errorf("cluster endpoint %q cannot contain credentials", endpoint)
+	case u.RawQuery != "" || u.ForceQuery || u.Fragment != "":
+		return "", fmt.Errorf("cluster endpoint %q cannot have a query or a fragment", endpoint)
+	case !validHostName(u.Hostname()):
+		return "", fmt.Errorf("cluster endpoint %q does not have a valid host name", endpoint)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("cluster endpoint %q has an invalid port", endpoint)
+		}
+	}
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+	path := strings.TrimRight(u.EscapedPath(), "/")
+
+	if u.Scheme == "https" && port == "" && path == "" && !strings.Contains(host, ":") {
+		return host, nil
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return u.Scheme + "://" + host + path, nil
+}
+
+// validHostName returns true if h is made of DNS labels, or is an IP address.
+func validHostName(h string) bool {
+	if h == "" {
+		return false
+	}
+	if net.ParseIP(h) != nil {
+		return true
+	}
+	for _, label := range strings.Split(h, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
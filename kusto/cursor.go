@@ -0,0 +1,196 @@
+package kusto
+
+// cursor.go holds helpers for reading data incrementally with database cursors.
+// See https://learn.microsoft.com/azure/data-explorer/kusto/management/database-cursor
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// CursorColumn is the column RowIterator.CursorCurrent() reads the cursor from. A query projects it with
+// "| extend kusto_cursor = cursor_current()".
+const CursorColumn = "kusto_cursor"
+
+// CursorAfter sets the cursor used by calls to cursor_after() without arguments in the query, so that it only returns
+// the records ingested after cursor. An empty cursor returns all the records.
+// Unlike splicing the cursor into the query text, the cursor is validated and sent as a request property.
+func CursorAfter(cursor string) QueryOption {
+	return func(q *queryOptions) error {
+		if err := validateCursor(cursor); err != nil {
+			return err
+		}
+		q.requestProperties.setOption(QueryCursorAfterDefaultValue, cursor)
+		return nil
+	}
+}
+
+// validateCursor checks that cursor looks like a database cursor, which is a string of digits.
+func validateCursor(cursor string) error {
+	for _, r := range cursor {
+		if r < '0' || r > '9' {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "%q is not a valid database cursor", cursor).SetNoRetry()
+		}
+	}
+	return nil
+}
+
+// CursorError is returned when the service rejected a cursor, for example because it belongs to another database.
+type CursorError struct {
+	// Cursor is the cursor that was rejected.
+	Cursor string
+	// Err is the error returned by the service.
+	Err error
+}
+
+// Error implements error.
+func (c *CursorError) Error() string {
+	return fmt.Sprintf("cursor %q was rejected: %s", c.Cursor, c.Err)
+}
+
+// Unwrap implements errors.Unwrap().
+func (c *CursorError) Unwrap() error {
+	return c.Err
+}
+
+// cursorTracker records the value of the CursorColumn column of the rows read by a RowIterator.
+type cursorTracker struct {
+	checked bool
+	index   int
+	cursor  string
+}
+
+func (c *cursorTracker) track(columns table.Columns, values value.Values) {
+	if !c.checked {
+		c.checked = true
+		c.index = -1
+		for i, col := range columns {
+			if col.Name == CursorColumn {
+				c.index = i
+				break
+			}
+		}
+	}
+	if c.index < 0 || c.index >= len(values) {
+		return
+	}
+	if s, ok := values[c.index].(value.String); ok && s.Valid {
+		c.cursor = s.Value
+	}
+}
+
+// CursorCurrent returns the value of cursor_current() that the query projected as the CursorColumn column, in the
+// rows read so far. An error is returned if the query does not project the column or if no row was read yet.
+// Use Client.CursorCurrent() to get the current cursor without reading any rows.
+func (r *RowIterator) CursorCurrent() (string, error) {
+	if r.cursor.checked && r.cursor.index < 0 {
+		return "", errors.ES(r.op, errors.KClientArgs, "the query does not project cursor_current() as column %s", CursorColumn)
+	}
+	if r.cursor.cursor == "" {
+		return "", errors.ES(r.op, errors.KClientArgs, "no row with a cursor has been read")
+	}
+	return r.cursor.cursor, nil
+}
+
+// CursorCurrent returns the current cursor of the database db.
+func (c *Client) CursorCurrent(ctx context.Context, db string) (string, error) {
+	iter, err := c.Query(ctx, db, NewStmt("print kusto_cursor = cursor_current()"))
+	if err != nil {
+		return "", err
+	}
+	defer iter.Stop()
+
+	if err := iter.Do(func(*table.Row) error { return nil }); err != nil {
+		return "", err
+	}
+	return iter.CursorCurrent()
+}
+
+// CursorStore persists the cursor of an IncrementalReader between runs.
+type CursorStore interface {
+	// Load returns the last saved cursor, or an empty string if none was saved.
+	Load(ctx context.Context) (string, error)
+	// Save saves the cursor.
+	Save(ctx context.Context, cursor string) error
+}
+
+// IncrementalReader runs a query that only returns the records ingested since its previous run.
+// The query must call cursor_after() without arguments, such as "MyTable | where cursor_after()". The cursor is
+// loaded from and saved to a CursorStore, and the first run returns all the records.
+type IncrementalReader struct {
+	client  *Client
+	db      string
+	query   Stmt
+	store   CursorStore
+	options []QueryOption
+}
+
+// NewIncrementalReader creates an IncrementalReader for query on database db.
+func NewIncrementalReader(client *Client, db string, query Stmt, store CursorStore, options ...QueryOption) *IncrementalReader {
+	return &IncrementalReader{client: client, db: db, query: query, store: store, options: options}
+}
+
+// Run calls f with every row ingested since the previous run. The cursor is only saved if every row was processed
+// without error, so a failed run is read again by the next one.
+// If the service rejects the saved cursor, a *CursorError is returned.
+func (r *IncrementalReader) Run(ctx context.Context, f func(row *table.Row) error) error {
+	cursor, err := r.store.Load(ctx)
+	if err != nil {
+		return errors.ES(errors.OpQuery, errors.KOther, "could not load the cursor: %s", err)
+	}
+
+	options := append(append(make([]QueryOption, 0, len(r.options)+1), r.options...), CursorAfter(cursor))
+	iter, err := r.client.Query(ctx, r.db, r.query.Add(" | extend kusto_cursor = cursor_current()"), options...)
+	if err != nil {
+		return cursorErr(cursor, err)
+	}
+	defer iter.Stop()
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return cursorErr(cursor, err)
+		}
+		if err := f(withoutCursor(row, iter.cursor.index)); err != nil {
+			return err
+		}
+	}
+
+	next, err := iter.CursorCurrent()
+	if err != nil {
+		// No new rows, keep the cursor we have.
+		return nil
+	}
+	if err := r.store.Save(ctx, next); err != nil {
+		return errors.ES(errors.OpQuery, errors.KOther, "could not save the cursor: %s", err)
+	}
+	return nil
+}
+
+// cursorErr returns a *CursorError if err is the service rejecting cursor, as told by the code of its OneApiError.
+func cursorErr(cursor string, err error) error {
+	if cursor != "" && errors.IsInvalidCursor(err) {
+		return &CursorError{Cursor: cursor, Err: err}
+	}
+	return err
+}
+
+// withoutCursor removes the CursorColumn column that IncrementalReader added to the row.
+func withoutCursor(row *table.Row, index int) *table.Row {
+	if index < 0 || index >= len(row.Values) {
+		return row
+	}
+	columns := make(table.Columns, 0, len(row.ColumnTypes)-1)
+	columns = append(append(columns, row.ColumnTypes[:index]...), row.ColumnTypes[index+1:]...)
+	values := make(value.Values, 0, len(row.Values)-1)
+	values = append(append(values, row.Values[:index]...), row.Values[index+1:]...)
+	return &table.Row{ColumnTypes: columns, Values: values, Op: row.Op, Replace: row.Replace}
+}
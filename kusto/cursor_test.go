@@ -0,0 +1,158 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cursorTransport is a fake http.RoundTripper for a table where records 1 and 2 were ingested at cursor 100.
+type cursorTransport struct {
+	queries []queryMsg
+}
+
+func (c *cursorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	c.queries = append(c.queries, msg)
+
+	rows := `[1,"100"],[2,"100"]`
+	switch msg.Properties.Options[QueryCursorAfterDefaultValue] {
+	case "100":
+		rows = ``
+	case "999":
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Header:     http.Header{},
+			Body: io.NopCloser(strings.NewReader(`{"error":{"code":"BadRequest_InvalidDatabaseCursor",` +
+				`"message":"Cursor '999' is not valid for database 'db'","@type":"Kusto.Data.Exceptions.InvalidDatabaseCursorException"}}`)),
+		}, nil
+	case "998":
+		// A syntax error of a query that mentions a cursor is not the service rejecting the cursor.
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Header:     http.Header{},
+			Body: io.NopCloser(strings.NewReader(`{"error":{"code":"General_BadRequest","message":"Syntax error near cursor_after",` +
+				`"@type":"Kusto.Data.Exceptions.SyntaxException","@errorCode":"SyntaxError"}}`)),
+		}, nil
+	}
+
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"},{"ColumnName":"kusto_cursor","ColumnType":"string"}],"Rows":[` + rows + `]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+type memCursorStore struct {
+	cursor string
+	saves  int
+}
+
+func (m *memCursorStore) Load(context.Context) (string, error) {
+	return m.cursor, nil
+}
+
+func (m *memCursorStore) Save(_ context.Context, cursor string) error {
+	m.cursor = cursor
+	m.saves++
+	return nil
+}
+
+func TestIncrementalReader(t *testing.T) {
+	t.Parallel()
+
+	transport := &cursorTransport{}
+	client := newTestClient(t, "https://cursor.kusto.windows.net", transport)
+
+	store := &memCursorStore{}
+	reader := NewIncrementalReader(client, "db", NewStmt("T | where cursor_after()"), store)
+
+	read := func() ([]int64, error) {
+		var got []int64
+		err := reader.Run(context.Background(), func(row *table.Row) error {
+			require.Len(t, row.Values, 1)
+			assert.Equal(t, table.Columns{{Name: "x", Type: "long"}}, row.ColumnTypes)
+			got = append(got, row.Values[0].(value.Long).Value)
+			return nil
+		})
+		return got, err
+	}
+
+	// The first run has no cursor and reads everything.
+	got, err := read()
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, got)
+	assert.Equal(t, "100", store.cursor)
+	assert.Equal(t, "T | where cursor_after() | extend kusto_cursor = cursor_current()", transport.queries[0].CSL)
+	assert.Equal(t, "", transport.queries[0].Properties.Options[QueryCursorAfterDefaultValue])
+
+	// Nothing new, the cursor is kept.
+	got, err = read()
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	assert.Equal(t, "100", store.cursor)
+	assert.Equal(t, 1, store.saves)
+
+	// A cursor from another database is rejected by the service.
+	store.cursor = "999"
+	_, err = read()
+	var cursorErr *CursorError
+	require.True(t, goErrors.As(err, &cursorErr), "got %T: %s", err, err)
+	assert.Equal(t, "999", cursorErr.Cursor)
+
+	// Other errors are returned as is, even if their message mentions the cursor.
+	store.cursor = "998"
+	_, err = read()
+	require.Error(t, err)
+	assert.False(t, goErrors.As(err, &cursorErr), "got %T: %s", err, err)
+	assert.True(t, errors.IsSyntax(err))
+}
+
+func TestCursorCurrent(t *testing.T) {
+	t.Parallel()
+
+	iter := &RowIterator{op: errors.OpQuery}
+	_, err := iter.CursorCurrent()
+	assert.Error(t, err)
+
+	iter.cursor.track(table.Columns{{Name: "x", Type: "long"}}, value.Values{value.Long{Value: 1, Valid: true}})
+	_, err = iter.CursorCurrent()
+	assert.Error(t, err)
+
+	iter = &RowIterator{op: errors.OpQuery}
+	columns := table.Columns{{Name: "x", Type: "long"}, {Name: CursorColumn, Type: "string"}}
+	iter.cursor.track(columns, value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "42", Valid: true}})
+	cursor, err := iter.CursorCurrent()
+	require.NoError(t, err)
+	assert.Equal(t, "42", cursor)
+}
+
+func TestCursorAfter(t *testing.T) {
+	t.Parallel()
+
+	opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, CursorAfter("636040929866477946"))
+	require.NoError(t, err)
+	assert.Equal(t, "636040929866477946", opts.requestProperties.options()[QueryCursorAfterDefaultValue])
+
+	_, err = setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, CursorAfter("1') | take 1 //"))
+	assert.Error(t, err)
+}
@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Separator is the string used to separate nested errors. By
@@ -68,11 +69,30 @@ type Error struct {
 	// Err is the error message. This may be of any error type and may also wrap errors.
 	Err error
 
+	// Code is the code of the OneApiError the service returned in the body of an HTTP error, such as "LimitsExceeded"
+	// or "General_BadRequest". It is empty if the body was not a OneApiError.
+	Code string
+	// Type is the "@type" of the OneApiError, the type of the exception of the service, such as
+	// "Kusto.Data.Exceptions.SyntaxException".
+	Type string
+	// ErrorCode is the "@errorCode" of the OneApiError, a more precise code than Code, such as "SyntaxError".
+	ErrorCode string
+	// IsPermanent is the "@permanent" of the OneApiError. It is true if the service says that the request will fail
+	// again if retried, in which case Retry() returns false.
+	IsPermanent bool
+
 	// restErrMsg holds the body of an error messsage that was from a REST endpoint.
 	restErrMsg []byte
 	decoded    map[string]interface{}
 	permanent  bool
 
+	// clientRequestID, activityID and statusCode correlate the error with the logs of the service, see SetCorrelation().
+	clientRequestID string
+	activityID      string
+	statusCode      int
+	// retryAfter is the time the service asked to wait before retrying, see SetRetryAfter().
+	retryAfter *time.Duration
+
 	inner *Error
 }
 
@@ -81,6 +101,8 @@ type KustoError = Error
 type HttpError struct {
 	KustoError
 	StatusCode int
+	// Header is the header of the response, if it is known. See ServiceHints().
+	Header http.Header
 }
 
 // UnmarshalREST will unmarshal an error message from the server if the message is in
@@ -99,11 +121,7 @@ func (e *Error) UnmarshalREST() map[string]interface{} {
 	if m != nil {
 		if v, ok := m["error"]; ok {
 			if errMap, ok := v.(map[string]interface{}); ok {
-				if v, ok := errMap["@permanent"]; ok {
-					if b, ok := v.(bool); ok {
-						e.permanent = b
-					}
-				}
+				e.setOneAPI(errMap)
 			}
 		}
 	}
@@ -112,12 +130,98 @@ func (e *Error) UnmarshalREST() map[string]interface{} {
 	return m
 }
 
+// setOneAPI sets Code, Type, ErrorCode and IsPermanent from the "error" object of a OneApiError. The fields that are
+// missing or not strings, or not a bool for "@permanent", are left unset.
+func (e *Error) setOneAPI(errMap map[string]interface{}) {
+	str := func(key string) string {
+		s, _ := errMap[key].(string)
+		return s
+	}
+	e.Code = str("code")
+	e.Type = str("@type")
+	e.ErrorCode = str("@errorCode")
+	if b, ok := errMap["@permanent"].(bool); ok {
+		e.IsPermanent = b
+		e.permanent = b
+	}
+}
+
 // SetNoRetry sets this error so that Retry() will always return false.
 func (e *Error) SetNoRetry() *Error {
 	e.permanent = true
 	return e
 }
 
+// SetCorrelation sets the client request ID, the x-ms-client-request-id header of the request, the activity ID the
+// service gave it, and the HTTP status code of its response, which are then in the message of the error. The empty
+// ones and a zero statusCode are left unset.
+func (e *Error) SetCorrelation(clientRequestID, activityID string, statusCode int) *Error {
+	if clientRequestID != "" {
+		e.clientRequestID = clientRequestID
+	}
+	if activityID != "" {
+		e.activityID = activityID
+	}
+	if statusCode != 0 {
+		e.statusCode = statusCode
+	}
+	return e
+}
+
+// ClientRequestID returns the x-ms-client-request-id header of the request that failed, or of the request of a wrapped
+// *Error, or "" if it is not known.
+func (e *Error) ClientRequestID() string {
+	for ; e != nil; e = e.inner {
+		if e.clientRequestID != "" {
+			return e.clientRequestID
+		}
+	}
+	return ""
+}
+
+// ActivityID returns the ID the service gave the request that failed, or the request of a wrapped *Error, or "" if it
+// is not known.
+func (e *Error) ActivityID() string {
+	for ; e != nil; e = e.inner {
+		if e.activityID != "" {
+			return e.activityID
+		}
+	}
+	return ""
+}
+
+// StatusCode returns the HTTP status code of the response that failed, or of the response of a wrapped *Error, or 0
+// if there was none, such as when the request could not be sent.
+func (e *Error) StatusCode() int {
+	for ; e != nil; e = e.inner {
+		if e.statusCode != 0 {
+			return e.statusCode
+		}
+	}
+	return 0
+}
+
+// SetRetryAfter sets the time the service asked to wait before the request is retried, from the Retry-After or the
+// x-ms-retry-after-ms header of a throttled response. A negative d is ignored.
+func (e *Error) SetRetryAfter(d time.Duration) *Error {
+	if d >= 0 {
+		e.retryAfter = &d
+	}
+	return e
+}
+
+// RetryAfter returns the time the service asked to wait before retrying the request that failed, or the request of
+// a wrapped *Error, and true, or false if the service did not say. An application that retries the request itself
+// should wait for at least that long.
+func (e *Error) RetryAfter() (time.Duration, bool) {
+	for ; e != nil; e = e.inner {
+		if e.retryAfter != nil {
+			return *e.retryAfter, true
+		}
+	}
+	return 0, false
+}
+
 // Unwrap implements "interface {Unwrap() error}" as defined internally by the go stdlib errors package.
 func (e *Error) Unwrap() error {
 	if e == nil {
@@ -149,6 +253,18 @@ func (e *Error) Error() string {
 		pad(b, ": ")
 		b.WriteString(fmt.Sprintf("Kind(%s)", e.Kind.String()))
 	}
+	if e.statusCode != 0 {
+		pad(b, ": ")
+		b.WriteString(fmt.Sprintf("StatusCode(%d)", e.statusCode))
+	}
+	if e.clientRequestID != "" {
+		pad(b, ": ")
+		b.WriteString(fmt.Sprintf("ClientRequestID(%s)", e.clientRequestID))
+	}
+	if e.activityID != "" {
+		pad(b, ": ")
+		b.WriteString(fmt.Sprintf("ActivityID(%s)", e.activityID))
+	}
 
 	if e.Err != nil {
 		pad(b, ": ")
@@ -173,6 +289,11 @@ func (e *Error) Error() string {
 // Retry determines if the error is transient and the action can be retried or not.
 // Some errors that can be retried, such as a timeout, may never succeed, so avoid infinite retries.
 func Retry(err error) bool {
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) {
+		return Retry(&httpErr.KustoError)
+	}
+
 	var e *Error
 	if errors.As(err, &e) {
 		// e.permanent can be set multiple ways. If it is true, you can never retry.
@@ -234,6 +355,7 @@ func HTTP(o Op, status string, statusCode int, body io.ReadCloser, prefix string
 			Kind:       KHTTPError,
 			restErrMsg: bodyBytes,
 			Err:        fmt.Errorf("%s(%s):\n%s", prefix, status, string(bodyBytes)),
+			statusCode: statusCode,
 		},
 		StatusCode: statusCode,
 	}
@@ -263,6 +385,10 @@ func e(args ...interface{}) *Error {
 			// Make a copy
 			argCopy := *arg
 			e.Err = argCopy.Err
+			e.clientRequestID, e.activityID, e.statusCode = argCopy.ClientRequestID(), argCopy.ActivityID(), argCopy.StatusCode()
+			if d, ok := argCopy.RetryAfter(); ok {
+				e.SetRetryAfter(d)
+			}
 		case error:
 			e.Err = arg
 		default:
@@ -375,6 +501,83 @@ func oneToErr(m map[string]interface{}, err *Error, op Op) *Error {
 	return err
 }
 
+// IsThrottled reports whether err, or an *Error it wraps, is the service throttling the request, with a 429
+// status code or a OneApiError code of throttling. The request can be retried later.
+func IsThrottled(err error) bool {
+	return match(err, func(e *Error) bool {
+		return e.statusCode == http.StatusTooManyRequests || e.Code == "TooManyRequests" ||
+			strings.Contains(e.ErrorCode, "Throttled") || strings.HasSuffix(e.Type, "ThrottledException")
+	})
+}
+
+// IsSyntax reports whether err, or an *Error it wraps, is the service failing to parse the query or command.
+func IsSyntax(err error) bool {
+	return match(err, func(e *Error) bool {
+		return e.ErrorCode == "SyntaxError" || e.Code == "SyntaxError" || strings.HasSuffix(e.Type, ".SyntaxException")
+	})
+}
+
+// IsInvalidCursor reports whether err, or an *Error it wraps, is the service rejecting the database cursor of the
+// query, such as a cursor of another database.
+func IsInvalidCursor(err error) bool {
+	return match(err, func(e *Error) bool {
+		return strings.Contains(e.ErrorCode, "Cursor") || strings.Contains(e.Code, "Cursor") ||
+			strings.HasSuffix(e.Type, "CursorException")
+	})
+}
+
+// IsNotFound reports whether err, or an *Error it wraps, is the service not finding an entity of the request, such as
+// a table or a stored query result that expired.
+func IsNotFound(err error) bool {
+	return match(err, func(e *Error) bool {
+		return strings.Contains(e.ErrorCode, "NotFound") || strings.Contains(e.Code, "NotFound") ||
+			strings.HasSuffix(e.Type, "NotFoundException")
+	})
+}
+
+// IsPermanent reports whether err, or an *Error it wraps, is a OneApiError the service marked as permanent, which
+// will fail again if the request is retried.
+func IsPermanent(err error) bool {
+	return match(err, func(e *Error) bool {
+		return e.IsPermanent
+	})
+}
+
+// RetryAfter returns the time the service asked to wait before retrying the request that failed with err, or a
+// request of an *Error err wraps, and true, or false if the service did not say. See Error.RetryAfter().
+func RetryAfter(err error) (time.Duration, bool) {
+	var d time.Duration
+	found := match(err, func(e *Error) bool {
+		if e.retryAfter == nil {
+			return false
+		}
+		d = *e.retryAfter
+		return true
+	})
+	return d, found
+}
+
+// match reports whether f returns true for the *Error or *HttpError in the chain of err, or for an *Error wrapped in
+// it with W().
+func match(err error, f func(e *Error) bool) bool {
+	var e *Error
+	var httpErr *HttpError
+	switch {
+	case errors.As(err, &httpErr):
+		e = &httpErr.KustoError
+	case errors.As(err, &e):
+	default:
+		return false
+	}
+	for ; e != nil; e = e.inner {
+		if f(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsThrottled reports whether the service throttled the request. See also the IsThrottled() function.
 func (e *HttpError) IsThrottled() bool {
 	return e != nil && (e.StatusCode == http.StatusTooManyRequests)
 }
@@ -404,6 +607,12 @@ type CombinedError struct {
 	Errors []error
 }
 
+// Unwrap returns the errors, so that errors.Is() and errors.As() find the *Error values among them, with their
+// correlation, see Error.ClientRequestID().
+func (c CombinedError) Unwrap() []error {
+	return c.Errors
+}
+
 func (c CombinedError) Error() string {
 	result := ""
 	for _, err := range c.Errors {
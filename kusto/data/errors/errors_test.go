@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -197,3 +200,180 @@ func TestOneToErr(t *testing.T) {
 		}
 	}
 }
+
+func TestCorrelation(t *testing.T) {
+	err := ES(OpQuery, KHTTPError, "bad request").SetCorrelation("KGC.execute;1", "activity-1", 400)
+	if got, want := err.Error(), "Op(OpQuery): Kind(KHTTPError): StatusCode(400): ClientRequestID(KGC.execute;1): ActivityID(activity-1): bad request"; got != want {
+		t.Errorf("TestCorrelation: Error(): got %q, want %q", got, want)
+	}
+
+	// Empty values do not unset the ones already set.
+	err.SetCorrelation("", "", 0)
+	if err.ClientRequestID() != "KGC.execute;1" || err.ActivityID() != "activity-1" || err.StatusCode() != 400 {
+		t.Errorf("TestCorrelation: SetCorrelation() with empty values: got (%q, %q, %d)", err.ClientRequestID(), err.ActivityID(), err.StatusCode())
+	}
+
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{desc: "Copied by E()", err: E(OpMgmt, KOther, err)},
+		{desc: "Wrapped by W()", err: W(err, ES(OpQuery, KOther, "outer"))},
+		{desc: "Combined", err: GetCombinedError(io.EOF, err)},
+		{desc: "Wrapped by fmt.Errorf()", err: fmt.Errorf("context: %w", GetCombinedError(err))},
+	}
+	for _, test := range tests {
+		var got *Error
+		if !errors.As(test.err, &got) {
+			t.Errorf("TestCorrelation(%s): errors.As(): got false, want true", test.desc)
+			continue
+		}
+		if got.ClientRequestID() != "KGC.execute;1" || got.ActivityID() != "activity-1" || got.StatusCode() != 400 {
+			t.Errorf("TestCorrelation(%s): got (%q, %q, %d), want (%q, %q, %d)", test.desc,
+				got.ClientRequestID(), got.ActivityID(), got.StatusCode(), "KGC.execute;1", "activity-1", 400)
+		}
+	}
+
+	httpErr := HTTP(OpQuery, "503 Service Unavailable", 503, io.NopCloser(strings.NewReader("busy")), "error")
+	if httpErr.KustoError.StatusCode() != 503 {
+		t.Errorf("TestCorrelation: HTTP(): got StatusCode() == %d, want 503", httpErr.KustoError.StatusCode())
+	}
+}
+
+func TestOneAPIError(t *testing.T) {
+	tests := []struct {
+		desc          string
+		statusCode    int
+		body          string
+		want          Error
+		wantThrottled bool
+		wantSyntax    bool
+		wantCursor    bool
+		wantNotFound  bool
+		wantRetry     bool
+	}{
+		{
+			desc:       "Syntax error",
+			statusCode: http.StatusBadRequest,
+			body: `{"error":{"code":"General_BadRequest","message":"Request is invalid and cannot be executed.",` +
+				`"@type":"Kusto.Data.Exceptions.SyntaxException","@errorCode":"SyntaxError","@permanent":true}}`,
+			want:       Error{Code: "General_BadRequest", Type: "Kusto.Data.Exceptions.SyntaxException", ErrorCode: "SyntaxError", IsPermanent: true},
+			wantSyntax: true,
+		},
+		{
+			desc:       "Invalid cursor",
+			statusCode: http.StatusBadRequest,
+			body: `{"error":{"code":"BadRequest_InvalidDatabaseCursor","message":"Cursor '999' is not valid for database 'db'",` +
+				`"@type":"Kusto.Data.Exceptions.InvalidDatabaseCursorException","@permanent":true}}`,
+			want:       Error{Code: "BadRequest_InvalidDatabaseCursor", Type: "Kusto.Data.Exceptions.InvalidDatabaseCursorException", IsPermanent: true},
+			wantCursor: true,
+		},
+		{
+			desc:       "Entity not found",
+			statusCode: http.StatusBadRequest,
+			body: `{"error":{"code":"BadRequest_EntityNotFound","message":"Stored query result 'pages' was not found",` +
+				`"@type":"Kusto.Data.Exceptions.EntityNotFoundException","@permanent":true}}`,
+			want:         Error{Code: "BadRequest_EntityNotFound", Type: "Kusto.Data.Exceptions.EntityNotFoundException", IsPermanent: true},
+			wantNotFound: true,
+		},
+		{
+			desc:       "Limits exceeded",
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":{"code":"LimitsExceeded","message":"Query execution has exceeded the allowed limits","@errorCode":"LimitsExceeded","@permanent":true}}`,
+			want:       Error{Code: "LimitsExceeded", ErrorCode: "LimitsExceeded", IsPermanent: true},
+		},
+		{
+			desc:          "Throttled",
+			statusCode:    http.StatusTooManyRequests,
+			body:          `{"error":{"code":"TooManyRequests","message":"The request was denied due to throttling","@permanent":false}}`,
+			want:          Error{Code: "TooManyRequests"},
+			wantThrottled: true,
+			wantRetry:     true,
+		},
+		{
+			desc:       "Malformed body",
+			statusCode: http.StatusServiceUnavailable,
+			body:       `{"error":`,
+			wantRetry:  true,
+		},
+		{
+			desc:       "Fields of the wrong type",
+			statusCode: http.StatusServiceUnavailable,
+			body:       `{"error":{"code":1,"@type":true,"@permanent":"yes"}}`,
+			wantRetry:  true,
+		},
+	}
+
+	for _, test := range tests {
+		err := HTTP(OpQuery, http.StatusText(test.statusCode), test.statusCode, io.NopCloser(strings.NewReader(test.body)), "error")
+		got := Error{Code: err.Code, Type: err.Type, ErrorCode: err.ErrorCode, IsPermanent: err.IsPermanent}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestOneAPIError(%s): -want/+got:\n%s", test.desc, diff)
+		}
+		// The message keeps the body as is.
+		if !strings.Contains(err.Error(), test.body) {
+			t.Errorf("TestOneAPIError(%s): got message %q, want it to contain the body", test.desc, err.Error())
+		}
+
+		wrapped := fmt.Errorf("wrapped: %w", err)
+		if got := IsThrottled(wrapped); got != test.wantThrottled {
+			t.Errorf("TestOneAPIError(%s): IsThrottled(): got %v, want %v", test.desc, got, test.wantThrottled)
+		}
+		if got := IsSyntax(wrapped); got != test.wantSyntax {
+			t.Errorf("TestOneAPIError(%s): IsSyntax(): got %v, want %v", test.desc, got, test.wantSyntax)
+		}
+		if got := IsInvalidCursor(wrapped); got != test.wantCursor {
+			t.Errorf("TestOneAPIError(%s): IsInvalidCursor(): got %v, want %v", test.desc, got, test.wantCursor)
+		}
+		if got := IsNotFound(wrapped); got != test.wantNotFound {
+			t.Errorf("TestOneAPIError(%s): IsNotFound(): got %v, want %v", test.desc, got, test.wantNotFound)
+		}
+		if got := IsPermanent(wrapped); got != test.want.IsPermanent {
+			t.Errorf("TestOneAPIError(%s): IsPermanent(): got %v, want %v", test.desc, got, test.want.IsPermanent)
+		}
+		if got := Retry(wrapped); got != test.wantRetry {
+			t.Errorf("TestOneAPIError(%s): Retry(): got %v, want %v", test.desc, got, test.wantRetry)
+		}
+	}
+
+	if IsThrottled(io.EOF) || IsSyntax(io.EOF) || IsPermanent(io.EOF) {
+		t.Errorf("TestOneAPIError(io.EOF): got a predicate true for a non-Kusto error")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if _, ok := RetryAfter(io.EOF); ok {
+		t.Errorf("TestRetryAfter(io.EOF): got true, want false")
+	}
+
+	err := ES(OpQuery, KHTTPError, "throttled")
+	if _, ok := err.RetryAfter(); ok {
+		t.Errorf("TestRetryAfter(unset): got true, want false")
+	}
+	err.SetRetryAfter(-time.Second)
+	if _, ok := err.RetryAfter(); ok {
+		t.Errorf("TestRetryAfter(negative): got true, want false")
+	}
+
+	err.SetRetryAfter(0)
+	if d, ok := err.RetryAfter(); !ok || d != 0 {
+		t.Errorf("TestRetryAfter(zero): got (%v, %v), want (0s, true)", d, ok)
+	}
+
+	err.SetRetryAfter(3 * time.Second)
+	httpErr := &HttpError{KustoError: *E(OpQuery, KHTTPError, err)}
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{desc: "*Error", err: err},
+		{desc: "Wrapped by W()", err: W(err, ES(OpQuery, KOther, "outer"))},
+		{desc: "*HttpError", err: httpErr},
+		{desc: "Wrapped by fmt.Errorf()", err: fmt.Errorf("context: %w", httpErr)},
+	}
+	for _, test := range tests {
+		if d, ok := RetryAfter(test.err); !ok || d != 3*time.Second {
+			t.Errorf("TestRetryAfter(%s): got (%v, %v), want (3s, true)", test.desc, d, ok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package errors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceHints are the hints about its capacity that the service returns in the headers of a response. A hint whose
+// header is absent or malformed is left nil, or empty.
+type ServiceHints struct {
+	// RateLimitRemaining is the number of requests that can still be made before being throttled.
+	RateLimitRemaining *int
+	// RateLimitLimit is the number of requests allowed in the current rate limit window.
+	RateLimitLimit *int
+	// RateLimitReset is the time left before the current rate limit window ends.
+	RateLimitReset *time.Duration
+	// RetryAfter is the time to wait before retrying a throttled request, from the x-ms-retry-after-ms header, or
+	// from the Retry-After header, which is in seconds.
+	RetryAfter *time.Duration
+	// WorkloadGroup is the workload group the request was classified into.
+	WorkloadGroup string
+	// IsThrottlingImminent indicates that the requests are about to be throttled.
+	IsThrottlingImminent bool
+	// ActivityID is the ID the service gave the request.
+	ActivityID string
+}
+
+// serviceHintHeaders are the headers ServiceHints are parsed from, with the function that sets the hint from a value.
+// The function leaves the hint unset if the value is malformed.
+var serviceHintHeaders = []struct {
+	header string
+	parse  func(h *ServiceHints, v string)
+}{
+	{"x-ms-ratelimit-remaining", intHint(func(h *ServiceHints) **int { return &h.RateLimitRemaining })},
+	{"x-ms-ratelimit-limit", intHint(func(h *ServiceHints) **int { return &h.RateLimitLimit })},
+	{"x-ms-ratelimit-reset", secondsHint(func(h *ServiceHints) **time.Duration { return &h.RateLimitReset })},
+	{"Retry-After", secondsHint(func(h *ServiceHints) **time.Duration { return &h.RetryAfter })},
+	// x-ms-retry-after-ms is more precise than Retry-After, so it is parsed after it and replaces it.
+	{"x-ms-retry-after-ms", millisecondsHint(func(h *ServiceHints) **time.Duration { return &h.RetryAfter })},
+	{"x-ms-workload-group", stringHint(func(h *ServiceHints) *string { return &h.WorkloadGroup })},
+	{"x-ms-throttling-imminent", boolHint(func(h *ServiceHints) *bool { return &h.IsThrottlingImminent })},
+	{"x-ms-activity-id", stringHint(func(h *ServiceHints) *string { return &h.ActivityID })},
+}
+
+// ParseServiceHints returns the ServiceHints in the headers of a response. header may be nil.
+func ParseServiceHints(header http.Header) ServiceHints {
+	var hints ServiceHints
+	for _, hh := range serviceHintHeaders {
+		v := strings.TrimSpace(header.Get(hh.header))
+		if v == "" {
+			continue
+		}
+		hh.parse(&hints, v)
+	}
+	return hints
+}
+
+// ServiceHints returns the ServiceHints in the headers of the response that returned the error.
+func (e *HttpError) ServiceHints() ServiceHints {
+	if e == nil {
+		return ServiceHints{}
+	}
+	return ParseServiceHints(e.Header)
+}
+
+func intHint(field func(h *ServiceHints) **int) func(h *ServiceHints, v string) {
+	return func(h *ServiceHints, v string) {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return
+		}
+		*field(h) = &i
+	}
+}
+
+// secondsHint parses a whole number of seconds, which can't be negative.
+func secondsHint(field func(h *ServiceHints) **time.Duration) func(h *ServiceHints, v string) {
+	return func(h *ServiceHints, v string) {
+		s, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return
+		}
+		d := time.Duration(s) * time.Second
+		*field(h) = &d
+	}
+}
+
+// millisecondsHint parses a whole number of milliseconds, which can't be negative.
+func millisecondsHint(field func(h *ServiceHints) **time.Duration) func(h *ServiceHints, v string) {
+	return func(h *ServiceHints, v string) {
+		ms, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return
+		}
+		d := time.Duration(ms) * time.Millisecond
+		*field(h) = &d
+	}
+}
+
+func stringHint(field func(h *ServiceHints) *string) func(h *ServiceHints, v string) {
+	return func(h *ServiceHints, v string) {
+		*field(h) = v
+	}
+}
+
+func boolHint(field func(h *ServiceHints) *bool) func(h *ServiceHints, v string) {
+	return func(h *ServiceHints, v string) {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return
+		}
+		*field(h) = b
+	}
+}
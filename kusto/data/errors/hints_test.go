@@ -0,0 +1,113 @@
+package errors
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParseServiceHints(t *testing.T) {
+	t.Parallel()
+
+	intPtr := func(i int) *int { return &i }
+	durationPtr := func(d time.Duration) *time.Duration { return &d }
+
+	tests := []struct {
+		desc   string
+		header http.Header
+		want   ServiceHints
+	}{
+		{
+			desc: "Nil header",
+		},
+		{
+			desc:   "No hints",
+			header: http.Header{"Content-Type": {"application/json"}},
+		},
+		{
+			desc: "All hints",
+			header: http.Header{
+				"X-Ms-Ratelimit-Remaining": {"12"},
+				"X-Ms-Ratelimit-Limit":     {"100"},
+				"X-Ms-Ratelimit-Reset":     {"30"},
+				"Retry-After":              {"5"},
+				"X-Ms-Workload-Group":      {"default"},
+				"X-Ms-Throttling-Imminent": {"true"},
+				"X-Ms-Activity-Id":         {"a1b2"},
+			},
+			want: ServiceHints{
+				RateLimitRemaining:   intPtr(12),
+				RateLimitLimit:       intPtr(100),
+				RateLimitReset:       durationPtr(30 * time.Second),
+				RetryAfter:           durationPtr(5 * time.Second),
+				WorkloadGroup:        "default",
+				IsThrottlingImminent: true,
+				ActivityID:           "a1b2",
+			},
+		},
+		{
+			desc: "Zero values and spaces",
+			header: http.Header{
+				"X-Ms-Ratelimit-Remaining": {" 0 "},
+				"X-Ms-Throttling-Imminent": {"False"},
+				"X-Ms-Workload-Group":      {"  "},
+			},
+			want: ServiceHints{RateLimitRemaining: intPtr(0)},
+		},
+		{
+			desc: "Malformed values",
+			header: http.Header{
+				"X-Ms-Ratelimit-Remaining": {"twelve"},
+				"X-Ms-Ratelimit-Limit":     {"1.5"},
+				"X-Ms-Ratelimit-Reset":     {"-3"},
+				"Retry-After":              {"Wed, 21 Oct 2015 07:28:00 GMT"},
+				"X-Ms-Throttling-Imminent": {"soon"},
+				"X-Ms-Workload-Group":      {"internal"},
+			},
+			want: ServiceHints{WorkloadGroup: "internal"},
+		},
+		{
+			desc:   "Retry-After in milliseconds",
+			header: http.Header{"Retry-After": {"2"}, "X-Ms-Retry-After-Ms": {"1500"}},
+			want:   ServiceHints{RetryAfter: durationPtr(1500 * time.Millisecond)},
+		},
+		{
+			desc:   "Malformed Retry-After in milliseconds",
+			header: http.Header{"Retry-After": {"2"}, "X-Ms-Retry-After-Ms": {"-1"}},
+			want:   ServiceHints{RetryAfter: durationPtr(2 * time.Second)},
+		},
+		{
+			desc:   "Overflow",
+			header: http.Header{"X-Ms-Ratelimit-Remaining": {"99999999999999999999"}, "Retry-After": {"99999999999"}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got := ParseServiceHints(test.header)
+			if diff := pretty.Compare(test.want, got); diff != "" {
+				t.Errorf("TestParseServiceHints(%s): -want/+got:\n%s", test.desc, diff)
+			}
+		})
+	}
+}
+
+func TestHttpErrorServiceHints(t *testing.T) {
+	t.Parallel()
+
+	var nilErr *HttpError
+	if diff := pretty.Compare(ServiceHints{}, nilErr.ServiceHints()); diff != "" {
+		t.Errorf("TestHttpErrorServiceHints(nil): -want/+got:\n%s", diff)
+	}
+
+	e := &HttpError{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+	got := e.ServiceHints()
+	if got.RetryAfter == nil || *got.RetryAfter != 7*time.Second {
+		t.Errorf("TestHttpErrorServiceHints: got RetryAfter == %v, want 7s", got.RetryAfter)
+	}
+}
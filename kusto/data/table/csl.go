@@ -0,0 +1,308 @@
+package table
+
+// csl.go renders Columns as CSL, such as the cslschema string "Name:string, Count:long", and parses them back.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+)
+
+// cslKeywords are the words that cannot be used as a plain identifier. Names that are one of them are quoted.
+var cslKeywords = map[string]bool{
+	"access": true, "alias": true, "and": true, "anomalychart": true, "areachart": true, "as": true, "asc": true,
+	"barchart": true, "between": true, "bool": true, "boolean": true, "by": true, "cluster": true, "columnchart": true,
+	"consume": true, "contains": true, "count": true, "database": true, "datatable": true, "date": true,
+	"datetime": true, "decimal": true, "declare": true, "default": true, "desc": true, "distinct": true, "double": true,
+	"dynamic": true, "evaluate": true, "extend": true, "externaldata": true, "facet": true, "false": true,
+	"filter": true, "find": true, "first": true, "fork": true, "from": true, "getschema": true, "guid": true,
+	"has": true, "in": true, "int": true, "int64": true, "invoke": true, "join": true, "kind": true, "last": true,
+	"let": true, "like": true, "limit": true, "long": true, "lookup": true, "materialize": true, "mv-apply": true,
+	"mv-expand": true, "not": true, "null": true, "nulls": true, "of": true, "on": true, "or": true, "order": true,
+	"parse": true, "partition": true, "pattern": true, "print": true, "project": true, "range": true, "real": true,
+	"render": true, "restrict": true, "sample": true, "scan": true, "search": true, "serialize": true, "set": true,
+	"sort": true, "step": true, "string": true, "summarize": true, "table": true, "take": true, "time": true,
+	"timespan": true, "to": true, "top": true, "true": true, "typeof": true, "union": true, "where": true,
+	"with": true,
+}
+
+// cslTypes maps the CSL type names, including their aliases and the .NET names used by some schema commands, to the
+// column types. The keys are lowercase.
+var cslTypes = map[string]types.Column{
+	"bool":     types.Bool,
+	"boolean":  types.Bool,
+	"datetime": types.DateTime,
+	"date":     types.DateTime,
+	"dynamic":  types.Dynamic,
+	"guid":     types.GUID,
+	"uuid":     types.GUID,
+	"uniqueid": types.GUID,
+	"int":      types.Int,
+	"int32":    types.Int,
+	"long":     types.Long,
+	"int64":    types.Long,
+	"real":     types.Real,
+	"double":   types.Real,
+	"string":   types.String,
+	"timespan": types.Timespan,
+	"time":     types.Timespan,
+	"decimal":  types.Decimal,
+
+	"system.boolean":                  types.Bool,
+	"system.datetime":                 types.DateTime,
+	"system.object":                   types.Dynamic,
+	"system.guid":                     types.GUID,
+	"system.int32":                    types.Int,
+	"system.int64":                    types.Long,
+	"system.double":                   types.Real,
+	"system.string":                   types.String,
+	"system.timespan":                 types.Timespan,
+	"system.data.sqltypes.sqldecimal": types.Decimal,
+}
+
+// ToCSLSchema returns the columns as a cslschema string, such as "Name:string, ['where']:long", which is the
+// format used by `.show table T cslschema` and by the column list of `.create table`. Names that are not plain
+// identifiers are quoted. The columns are not validated, see Columns.Validate().
+func (c Columns) ToCSLSchema() string {
+	sb := strings.Builder{}
+	for i, col := range c {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(QuoteIdentifier(col.Name))
+		sb.WriteByte(':')
+		sb.WriteString(string(col.Type))
+	}
+	return sb.String()
+}
+
+// ToCreateTableCommand returns the `.create table` command that creates a table named tableName with the columns.
+// It returns an error if the columns are not valid, for example if two of them have the same name.
+func (c Columns) ToCreateTableCommand(tableName string) (string, error) {
+	if strings.TrimSpace(tableName) == "" {
+		return "", fmt.Errorf("table name cannot be empty")
+	}
+	if err := c.Validate(); err != nil {
+		return "", fmt.Errorf("cannot create table %s: %w", tableName, err)
+	}
+	return ".create table " + QuoteIdentifier(tableName) + " (" + c.ToCSLSchema() + ")", nil
+}
+
+// ColumnsFromCSLSchema parses a cslschema string, such as "Name:string, ['where']:long", as returned by
+// `.show table T cslschema`. Type aliases, such as "double" for "real", are accepted. It returns an error if the
+// schema is malformed, a type is unknown or two columns have the same name.
+func ColumnsFromCSLSchema(s string) (Columns, error) {
+	p := cslParser{s: s}
+	p.skipSpace()
+	// The column list of a `.create table` command is accepted too.
+	parens := p.consume('(')
+
+	var cols Columns
+	names := map[string]bool{}
+	for {
+		p.skipSpace()
+		if p.done() && len(cols) == 0 && !parens {
+			return nil, fmt.Errorf("cslschema is empty")
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(':') {
+			return nil, fmt.Errorf("cslschema: expected ':' after column %q at offset %d", name, p.pos)
+		}
+		p.skipSpace()
+		typeName := p.word()
+		t, ok := cslTypes[strings.ToLower(typeName)]
+		if !ok {
+			return nil, fmt.Errorf("cslschema: column %q has unknown type %q", name, typeName)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("cslschema: column %q is defined more than once", name)
+		}
+		names[name] = true
+		cols = append(cols, Column{Name: name, Type: t})
+
+		p.skipSpace()
+		if p.consume(',') {
+			continue
+		}
+		if parens && !p.consume(')') {
+			return nil, fmt.Errorf("cslschema: expected ')' at offset %d", p.pos)
+		}
+		p.skipSpace()
+		if !p.done() {
+			return nil, fmt.Errorf("cslschema: unexpected %q at offset %d", p.s[p.pos:], p.pos)
+		}
+		return cols, nil
+	}
+}
+
+// QuoteIdentifier returns name as a CSL identifier, quoting it as ['name'] if it is not a plain identifier or if it
+// is a keyword.
+func QuoteIdentifier(name string) string {
+	if isPlainIdentifier(name) && !cslKeywords[strings.ToLower(name)] {
+		return name
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("['")
+	for _, r := range name {
+		switch r {
+		case '\\', '\'':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString("']")
+	return sb.String()
+}
+
+func isPlainIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type cslParser struct {
+	s   string
+	pos int
+}
+
+func (p *cslParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *cslParser) skipSpace() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *cslParser) consume(c byte) bool {
+	if !p.done() && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// word reads a plain identifier or a type name, which may be dotted.
+func (p *cslParser) word() string {
+	start := p.pos
+	for !p.done() {
+		c := p.s[p.pos]
+		if c != '_' && c != '.' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// identifier reads a column name, which is either a plain identifier or quoted as ['name'] or ["name"].
+func (p *cslParser) identifier() (string, error) {
+	start := p.pos
+	if !p.consume('[') {
+		if w := p.word(); w != "" {
+			return w, nil
+		}
+		if p.done() {
+			return "", fmt.Errorf("cslschema: expected a column name at the end")
+		}
+		return "", fmt.Errorf("cslschema: expected a column name at offset %d", start)
+	}
+
+	if p.done() || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+		return "", fmt.Errorf("cslschema: expected a quote after '[' at offset %d", start)
+	}
+	quote := p.s[p.pos]
+	p.pos++
+
+	sb := strings.Builder{}
+	for {
+		if p.done() {
+			return "", fmt.Errorf("cslschema: unterminated column name starting at offset %d", start)
+		}
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case quote:
+			if !p.consume(']') {
+				return "", fmt.Errorf("cslschema: expected ']' after the column name starting at offset %d", start)
+			}
+			if sb.Len() == 0 {
+				return "", fmt.Errorf("cslschema: empty column name at offset %d", start)
+			}
+			return sb.String(), nil
+		case '\\':
+			if p.done() {
+				return "", fmt.Errorf("cslschema: unterminated column name starting at offset %d", start)
+			}
+			e := p.s[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// QuoteString returns s as a double quoted CSL string literal, escaping the quotes, backslashes and control
+// characters, so that s can come from untrusted input.
+func QuoteString(s string) string {
+	b := strings.Builder{}
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	// Ranging over s replaces invalid UTF-8 with utf8.RuneError, like encoding/json does.
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
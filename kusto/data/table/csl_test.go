@@ -0,0 +1,231 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToCSLSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		columns Columns
+		want    string
+	}{
+		{
+			desc:    "Empty",
+			columns: Columns{},
+			want:    "",
+		},
+		{
+			desc: "Plain names",
+			columns: Columns{
+				{Name: "Name", Type: types.String},
+				{Name: "_count2", Type: types.Long},
+				{Name: "Payload", Type: types.Dynamic},
+			},
+			want: "Name:string, _count2:long, Payload:dynamic",
+		},
+		{
+			desc: "Keywords and type names",
+			columns: Columns{
+				{Name: "where", Type: types.Bool},
+				{Name: "Project", Type: types.Int},
+				{Name: "string", Type: types.String},
+			},
+			want: "['where']:bool, ['Project']:int, ['string']:string",
+		},
+		{
+			desc: "Names that are not identifiers",
+			columns: Columns{
+				{Name: "1st", Type: types.Real},
+				{Name: "with space", Type: types.DateTime},
+				{Name: "it's a \\ name", Type: types.Timespan},
+				{Name: "a,b:c", Type: types.GUID},
+				{Name: "línea\n", Type: types.Decimal},
+			},
+			want: `['1st']:real, ['with space']:datetime, ['it\'s a \\ name']:timespan, ['a,b:c']:guid, ['línea\n']:decimal`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got := test.columns.ToCSLSchema()
+			assert.Equal(t, test.want, got)
+			if len(test.columns) == 0 {
+				return
+			}
+
+			back, err := ColumnsFromCSLSchema(got)
+			require.NoError(t, err)
+			assert.Equal(t, test.columns, back)
+		})
+	}
+}
+
+func TestToCreateTableCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc      string
+		tableName string
+		columns   Columns
+		want      string
+		err       bool
+	}{
+		{
+			desc:      "Success",
+			tableName: "Events",
+			columns:   Columns{{Name: "Timestamp", Type: types.DateTime}, {Name: "by", Type: types.Dynamic}},
+			want:      ".create table Events (Timestamp:datetime, ['by']:dynamic)",
+		},
+		{
+			desc:      "Quoted table name",
+			tableName: "my table",
+			columns:   Columns{{Name: "A", Type: types.Long}},
+			want:      ".create table ['my table'] (A:long)",
+		},
+		{
+			desc:      "Empty table name",
+			tableName: " ",
+			columns:   Columns{{Name: "A", Type: types.Long}},
+			err:       true,
+		},
+		{
+			desc:      "No columns",
+			tableName: "T",
+			err:       true,
+		},
+		{
+			desc:      "Duplicate names",
+			tableName: "T",
+			columns:   Columns{{Name: "A", Type: types.Long}, {Name: "A", Type: types.String}},
+			err:       true,
+		},
+		{
+			desc:      "Empty name",
+			tableName: "T",
+			columns:   Columns{{Name: "", Type: types.Long}},
+			err:       true,
+		},
+		{
+			desc:      "Invalid type",
+			tableName: "T",
+			columns:   Columns{{Name: "A", Type: "Int64"}},
+			err:       true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := test.columns.ToCreateTableCommand(test.tableName)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestColumnsFromCSLSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc   string
+		schema string
+		want   Columns
+		err    string
+	}{
+		{
+			desc:   "Show cslschema output",
+			schema: "Name:string,Count:long,Payload:dynamic",
+			want:   Columns{{Name: "Name", Type: types.String}, {Name: "Count", Type: types.Long}, {Name: "Payload", Type: types.Dynamic}},
+		},
+		{
+			desc:   "Create table column list",
+			schema: " ( A : int , ['B c']:real ) ",
+			want:   Columns{{Name: "A", Type: types.Int}, {Name: "B c", Type: types.Real}},
+		},
+		{
+			desc:   "Aliases",
+			schema: "a:boolean, b:date, c:double, d:int64, e:uuid, f:time, g:System.String, h:System.Object",
+			want: Columns{
+				{Name: "a", Type: types.Bool}, {Name: "b", Type: types.DateTime}, {Name: "c", Type: types.Real},
+				{Name: "d", Type: types.Long}, {Name: "e", Type: types.GUID}, {Name: "f", Type: types.Timespan},
+				{Name: "g", Type: types.String}, {Name: "h", Type: types.Dynamic},
+			},
+		},
+		{
+			desc:   "Double quoted name",
+			schema: `["where \"x\""]:string`,
+			want:   Columns{{Name: `where "x"`, Type: types.String}},
+		},
+		{
+			desc:   "Empty",
+			schema: "  ",
+			err:    "cslschema is empty",
+		},
+		{
+			desc:   "Duplicate names",
+			schema: "A:long, ['A']:string",
+			err:    `cslschema: column "A" is defined more than once`,
+		},
+		{
+			desc:   "Unknown type",
+			schema: "A:Int128",
+			err:    `cslschema: column "A" has unknown type "Int128"`,
+		},
+		{
+			desc:   "Missing type",
+			schema: "A, B:long",
+			err:    `cslschema: expected ':' after column "A" at offset 1`,
+		},
+		{
+			desc:   "Trailing comma",
+			schema: "A:long,",
+			err:    "cslschema: expected a column name at the end",
+		},
+		{
+			desc:   "Unterminated name",
+			schema: "['A:long",
+			err:    "cslschema: unterminated column name starting at offset 0",
+		},
+		{
+			desc:   "Missing closing parenthesis",
+			schema: "(A:long",
+			err:    "cslschema: expected ')' at offset 7",
+		},
+		{
+			desc:   "Trailing text",
+			schema: "A:long B:long",
+			err:    `cslschema: unexpected "B:long" at offset 7`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ColumnsFromCSLSchema(test.schema)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
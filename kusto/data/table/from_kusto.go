@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 )
 
@@ -26,41 +27,243 @@ func decodeToStruct(cols Columns, row value.Values, p interface{}) error {
 	return nil
 }
 
+// Unmarshaler is implemented by the types that decode a Kusto value themselves. When the type of a field, or a
+// pointer to it, implements Unmarshaler, ToStruct() calls UnmarshalKusto() with the value of the column instead of
+// converting it, including when the value is null.
+type Unmarshaler interface {
+	UnmarshalKusto(v value.Kusto) error
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	kustoType       = reflect.TypeOf((*value.Kusto)(nil)).Elem()
+)
+
+// field is a field of a struct that a column is decoded into.
+type field struct {
+	// name is the path of the field from the struct, such as "Base.ID" for a promoted field.
+	name string
+	// index is the index sequence of the field for reflect.Value.FieldByIndex().
+	index []int
+	// depth is the number of embedded structs the field is promoted through.
+	depth int
+	// tagged is set if the column name is from the kusto tag of the field.
+	tagged bool
+}
+
+// pathField is a field that is decoded from a path within a dynamic column.
+type pathField struct {
+	field
+	path string
+}
+
 // fields represents the fields inside a struct.
 type fields struct {
-	colNameToFieldName map[string]string
+	colNameToField map[string]field
+	// colNameToPaths holds the fields decoded from paths within dynamic columns, by the name of the column.
+	colNameToPaths map[string][]pathField
 }
 
-// newFields takes in the Columns from our row and the reflect.Type of our *struct.
+// newFields takes in the Columns from our row and the reflect.Type of our *struct. The fields of embedded structs are
+// promoted with the Go rules: a field hides the fields of the same name that are embedded deeper, and the fields of
+// the same name at the same depth hide each other, unless only one of them has a kusto tag. An embedded struct that
+// has a kusto tag is decoded as a field, it is not promoted.
 func newFields(cols Columns, ptr reflect.Type) fields {
-	nFields := fields{colNameToFieldName: map[string]string{}}
-	for i := 0; i < ptr.Elem().NumField(); i++ {
-		field := ptr.Elem().Field(i)
-		if tag := field.Tag.Get("kusto"); strings.TrimSpace(tag) != "" {
-			nFields.colNameToFieldName[tag] = field.Name
-		} else {
-			nFields.colNameToFieldName[field.Name] = field.Name
-		}
+	byName := map[string][]field{}
+	collectFields(ptr.Elem(), nil, "", 0, map[reflect.Type]bool{}, byName)
+
+	colTypes := make(map[string]types.Column, len(cols))
+	for _, col := range cols {
+		colTypes[col.Name] = col.Type
 	}
 
+	nFields := fields{colNameToField: map[string]field{}, colNameToPaths: map[string][]pathField{}}
+	for name, candidates := range byName {
+		f, ok := dominantField(candidates)
+		if !ok {
+			continue
+		}
+		if col, path, ok := splitDynamicPath(name, colTypes); ok {
+			nFields.colNameToPaths[col] = append(nFields.colNameToPaths[col], pathField{field: f, path: path})
+			continue
+		}
+		nFields.colNameToField[name] = f
+	}
 	return nFields
 }
 
+// splitDynamicPath splits a name such as "Props.owner.name", which is not the name of a column, into the name of a
+// dynamic column and a path within it.
+func splitDynamicPath(name string, colTypes map[string]types.Column) (string, string, bool) {
+	if _, ok := colTypes[name]; ok {
+		return "", "", false
+	}
+	i := strings.IndexAny(name, ".[")
+	if i <= 0 {
+		return "", "", false
+	}
+	if colTypes[name[:i]] != types.Dynamic {
+		return "", "", false
+	}
+	return name[:i], strings.TrimPrefix(name[i:], "."), true
+}
+
+// collectFields adds the fields of struct type t, and those promoted from its embedded structs, to byName by column
+// name. visited holds the embedded structs on the path, so that a recursive embedding ends.
+func collectFields(t reflect.Type, index []int, prefix string, depth int, visited map[reflect.Type]bool, byName map[string][]field) {
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := strings.TrimSpace(sf.Tag.Get("kusto"))
+		if tag == "-" {
+			continue
+		}
+
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		if sf.Anonymous && tag == "" {
+			et := sf.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				if !visited[et] {
+					collectFields(et, idx, prefix+sf.Name+".", depth+1, visited, byName)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := field{name: prefix + sf.Name, index: idx, depth: depth, tagged: tag != ""}
+		colName := sf.Name
+		if f.tagged {
+			colName = tag
+		}
+		byName[colName] = append(byName[colName], f)
+	}
+}
+
+// dominantField returns the field that a column is decoded into among the fields for its name, if one of them hides
+// the others.
+func dominantField(candidates []field) (field, bool) {
+	depth := candidates[0].depth
+	for _, f := range candidates[1:] {
+		if f.depth < depth {
+			depth = f.depth
+		}
+	}
+
+	var shallowest []field
+	for _, f := range candidates {
+		if f.depth == depth {
+			shallowest = append(shallowest, f)
+		}
+	}
+	if len(shallowest) == 1 {
+		return shallowest[0], true
+	}
+
+	var tagged []field
+	for _, f := range shallowest {
+		if f.tagged {
+			tagged = append(tagged, f)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	return field{}, false
+}
+
 // convert converts a KustoValue that is for Column col into "v" reflect.Value with reflect.Type "t".
 func (f fields) convert(col Column, k value.Kusto, t reflect.Type, v reflect.Value) error {
-	fieldName, ok := f.colNameToFieldName[col.Name]
+	if fi, ok := f.colNameToField[col.Name]; ok {
+		fv, err := fieldByIndex(v.Elem(), fi.index)
+		if err != nil {
+			return fmt.Errorf("column %s could not store in struct.%s: %w", col.Name, fi.name, err)
+		}
+
+		if err := unmarshalOrConvert(k, fv); err != nil {
+			return fmt.Errorf("column %s could not store in struct.%s: %w", col.Name, fi.name, err)
+		}
+	}
+
+	for _, pf := range f.colNameToPaths[col.Name] {
+		if err := convertPath(k, pf, v); err != nil {
+			return fmt.Errorf("column %s path %s could not store in struct.%s: %w", col.Name, pf.path, pf.name, err)
+		}
+	}
+
+	return nil
+}
+
+// convertPath stores the value at the path of pf within the dynamic value k into the field of pf. The JSON of the
+// value is unmarshalled into the field, unless the field is an Unmarshaler or a value.Kusto type, which receive the
+// value as Dynamic.GetPath() returns it. A null value leaves the field unchanged.
+func convertPath(k value.Kusto, pf pathField, v reflect.Value) error {
+	d, ok := k.(value.Dynamic)
 	if !ok {
-		return nil
+		return fmt.Errorf("value was a %T, not a value.Dynamic", k)
+	}
+	sub, err := d.Lookup(pf.path)
+	if err != nil {
+		return err
+	}
+
+	fv, err := fieldByIndex(v.Elem(), pf.index)
+	if err != nil {
+		return err
+	}
+
+	if fv.Type().Implements(unmarshalerType) || reflect.PointerTo(fv.Type()).Implements(unmarshalerType) ||
+		fv.Type().Implements(kustoType) || reflect.PointerTo(fv.Type()).Implements(kustoType) {
+		leaf, err := d.GetPath(pf.path)
+		if err != nil {
+			return err
+		}
+		return unmarshalOrConvert(leaf, fv)
 	}
 
-	if fieldName == "-" {
+	if !sub.Valid {
 		return nil
 	}
+	return sub.UnmarshalInto(fv.Addr().Interface())
+}
 
-	err := k.Convert(v.Elem().FieldByName(fieldName))
-	if err != nil {
-		return fmt.Errorf("column %s could not store in struct.%s: %s", col.Name, fieldName, err.Error())
+// fieldByIndex returns the field of struct v at index, allocating the nil pointers to embedded structs on the way.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
 	}
+	return v, nil
+}
 
-	return nil
+// unmarshalOrConvert stores k into v, with the Unmarshaler of v if it has one, or else with k.Convert().
+func unmarshalOrConvert(k value.Kusto, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.Type().Implements(unmarshalerType) {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface().(Unmarshaler).UnmarshalKusto(k)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		return v.Addr().Interface().(Unmarshaler).UnmarshalKusto(k)
+	}
+	return k.Convert(v)
 }
@@ -0,0 +1,98 @@
+package table
+
+// literal.go renders value.Kusto values as CSL literals.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// Literal returns v as a literal of its type, such as `long(1)`, `"text"` or `datetime(null)`. These are also the
+// values of the query parameters sent in the request properties, except for strings, which are sent as is.
+func Literal(v value.Kusto) (string, error) {
+	switch v := v.(type) {
+	case value.Bool:
+		if !v.Valid {
+			return "bool(null)", nil
+		}
+		return "bool(" + strconv.FormatBool(v.Value) + ")", nil
+	case value.DateTime:
+		if !v.Valid {
+			return "datetime(null)", nil
+		}
+		return "datetime(" + v.Marshal() + ")", nil
+	case value.Dynamic:
+		if !v.Valid {
+			return "dynamic(null)", nil
+		}
+		// Compacting checks that the value is JSON, so that it cannot end the literal.
+		buf := bytes.Buffer{}
+		if err := json.Compact(&buf, v.Value); err != nil {
+			return "", fmt.Errorf("dynamic value is not valid JSON: %w", err)
+		}
+		return "dynamic(" + buf.String() + ")", nil
+	case value.GUID:
+		if !v.Valid {
+			return "guid(null)", nil
+		}
+		return "guid(" + v.Value.String() + ")", nil
+	case value.Int:
+		if !v.Valid {
+			return "int(null)", nil
+		}
+		return "int(" + strconv.FormatInt(int64(v.Value), 10) + ")", nil
+	case value.Long:
+		if !v.Valid {
+			return "long(null)", nil
+		}
+		return "long(" + strconv.FormatInt(v.Value, 10) + ")", nil
+	case value.Real:
+		switch {
+		case !v.Valid:
+			return "real(null)", nil
+		case math.IsNaN(v.Value):
+			return "real(nan)", nil
+		case math.IsInf(v.Value, 1):
+			return "real(+inf)", nil
+		case math.IsInf(v.Value, -1):
+			return "real(-inf)", nil
+		}
+		return "real(" + strconv.FormatFloat(v.Value, 'g', -1, 64) + ")", nil
+	case value.String:
+		return QuoteString(v.Value), nil
+	case value.Timespan:
+		return timespanLiteral(v), nil
+	case value.Decimal:
+		if !v.Valid {
+			return "decimal(null)", nil
+		}
+		if !value.DecRE.MatchString(v.Value) {
+			return "", fmt.Errorf("decimal value %q is not a decimal number", v.Value)
+		}
+		return "decimal(" + v.Value + ")", nil
+	}
+	return "", fmt.Errorf("type %T is not supported", v)
+}
+
+// timespanLiteral returns v as a time() literal, with the days only when there are any and all the 7 digits of the
+// ticks, such as `time(1.02:03:04.5000000)`.
+func timespanLiteral(v value.Timespan) string {
+	if !v.Valid {
+		return "time(null)"
+	}
+	s := v.Marshal()
+	i := strings.LastIndexByte(s, ':')
+	switch frac := strings.IndexByte(s[i:], '.'); {
+	case frac < 0:
+		s += ".0000000"
+	default:
+		s += strings.Repeat("0", 8-len(s[i+frac:]))
+	}
+	return "time(" + s + ")"
+}
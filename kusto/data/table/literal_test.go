@@ -0,0 +1,47 @@
+package table
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiteral(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		v    value.Kusto
+		want string
+		err  bool
+	}{
+		{desc: "bool", v: value.Bool{Value: true, Valid: true}, want: "bool(true)"},
+		{desc: "null bool", v: value.Bool{}, want: "bool(null)"},
+		{desc: "int", v: value.Int{Value: -3, Valid: true}, want: "int(-3)"},
+		{desc: "timespan", v: value.Timespan{Value: 90 * time.Second, Valid: true}, want: "time(00:01:30.0000000)"},
+		{desc: "real nan", v: value.Real{Value: math.NaN(), Valid: true}, want: "real(nan)"},
+		{desc: "decimal", v: value.Decimal{Value: "-1.5", Valid: true}, want: "decimal(-1.5)"},
+		{desc: "bad decimal", v: value.Decimal{Value: "1) | drop", Valid: true}, err: true},
+		{desc: "dynamic", v: value.Dynamic{Value: []byte(`{ "a": [1, 2] }`), Valid: true}, want: `dynamic({"a":[1,2]})`},
+		{desc: "bad dynamic", v: value.Dynamic{Value: []byte(`1) | drop`), Valid: true}, err: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Literal(test.v)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
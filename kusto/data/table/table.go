@@ -2,9 +2,9 @@
 package table
 
 import (
-	"encoding/csv"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -120,7 +120,7 @@ func (r *Row) ExtractValues(ptrs ...interface{}) error {
 		if ptrs[i] == nil {
 			continue
 		}
-		if err := val.Convert(reflect.ValueOf(ptrs[i]).Elem()); err != nil {
+		if err := unmarshalOrConvert(val, reflect.ValueOf(ptrs[i]).Elem()); err != nil {
 			return err
 		}
 	}
@@ -138,10 +138,27 @@ func (r *Row) ExtractValues(ptrs ...interface{}) error {
 //  2. Otherwise, if the name of a field matches the name of a column (ignoring case),
 //     decode the column into the field.
 //
+//  3. The fields of embedded structs, and of embedded pointers to structs, are promoted
+//     as in Go: a field hides the fields of the same name in deeper embedded structs, and
+//     fields of the same name at the same depth are ignored, unless only one of them has a
+//     tag. An embedded struct with a tag is decoded as a single field. Nil embedded
+//     pointers are allocated when one of their fields is decoded.
+//
+//  4. If the type of a field, or a pointer to it, implements Unmarshaler, its UnmarshalKusto()
+//     method decodes the column instead of the default conversion.
+//
+//  5. A tag such as `kusto:"Props.owner.name"`, which is not the name of a column but starts
+//     with the name of a dynamic column, decodes the value at the path within the column,
+//     as value.Dynamic.Lookup() finds it, into the field with encoding/json. A missing or
+//     null value leaves the field unchanged.
+//
 // Slice and pointer fields will be set to nil if the source column is a null value, and a
 // non-nil value if the column is not NULL. To decode NULL values of other types, use
 // one of the kusto types (Int, Long, Dynamic, ...) as the type of the destination field.
-// You can check the .Valid field of those types to see if the value was set.
+// You can check the .Valid field of those types to see if the value was set. Dynamic arrays
+// can be decoded into slices and pointers to slices, such as []T and *[]T.
+//
+// Errors name the column and the path of the field, such as "struct.Base.ID".
 func (r *Row) ToStruct(p interface{}) error {
 	// Check if p is a pointer to a struct
 	if t := reflect.TypeOf(p); t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
@@ -154,19 +171,52 @@ func (r *Row) ToStruct(p interface{}) error {
 	return decodeToStruct(r.ColumnTypes, r.Values, p)
 }
 
-// String implements fmt.Stringer for a Row. This simply outputs a CSV version of the row.
+// String implements fmt.Stringer for a Row. It outputs the row on a single line for logs, such as
+// `Name="a" Count=3 Props={...3 keys} Missing=null`. Strings are quoted, nulls are written as null and objects or
+// arrays longer than 64 bytes are summarized.
 func (r *Row) String() string {
-	line := []string{}
-	for _, v := range r.Values {
-		line = append(line, v.String())
-	}
+	const maxDynamic = 64
+
 	b := &strings.Builder{}
-	w := csv.NewWriter(b)
-	err := w.Write(line)
-	if err != nil {
-		return ""
+	for i, v := range r.Values {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if i < len(r.ColumnTypes) {
+			b.WriteString(r.ColumnTypes[i].Name)
+		} else {
+			fmt.Fprintf(b, "Column%d", i)
+		}
+		b.WriteByte('=')
+
+		switch v := v.(type) {
+		case nil:
+			b.WriteString("null")
+		case value.String:
+			if !v.Valid {
+				b.WriteString("null")
+				break
+			}
+			b.WriteString(strconv.Quote(v.Value))
+		case value.Dynamic:
+			if !v.Valid {
+				b.WriteString("null")
+				break
+			}
+			s := v.String()
+			if len(s) > maxDynamic {
+				s = v.Summary()
+			}
+			// Dynamic values can hold newlines, which must not split the line.
+			b.WriteString(strings.NewReplacer("\n", "\\n", "\r", "\\r").Replace(s))
+		default:
+			if value.IsNull(v) {
+				b.WriteString("null")
+				break
+			}
+			b.WriteString(v.String())
+		}
 	}
-	w.Flush()
 	return b.String()
 }
 
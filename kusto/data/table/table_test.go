@@ -1,6 +1,8 @@
 package table
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -8,6 +10,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRowColumns(t *testing.T) {
@@ -157,6 +160,231 @@ func TestRowToStruct(t *testing.T) {
 	}
 }
 
+type Base struct {
+	ID   int64 `kusto:"Id"`
+	Name string
+}
+
+type audit struct {
+	Source string
+}
+
+type origin struct {
+	Source string
+}
+
+type Details struct {
+	Name  string `kusto:"Name"`
+	Owner string
+}
+
+type celsius float64
+
+func (c *celsius) UnmarshalKusto(v value.Kusto) error {
+	r, ok := v.(value.Real)
+	if !ok {
+		return fmt.Errorf("celsius must be a real, was %T", v)
+	}
+	if !r.Valid {
+		*c = -273.15
+		return nil
+	}
+	*c = celsius(r.Value)
+	return nil
+}
+
+type upper string
+
+func (u *upper) UnmarshalKusto(v value.Kusto) error {
+	*u = upper(strings.ToUpper(v.String()))
+	return nil
+}
+
+func TestRowToStructEmbedded(t *testing.T) {
+	t.Parallel()
+
+	columns := Columns{
+		{Name: "Id", Type: types.Long},
+		{Name: "Name", Type: types.String},
+		{Name: "Source", Type: types.String},
+		{Name: "Owner", Type: types.String},
+		{Name: "Tags", Type: types.Dynamic},
+	}
+	row := value.Values{
+		value.Long{Value: 1, Valid: true},
+		value.String{Value: "node", Valid: true},
+		value.String{Value: "ingest", Valid: true},
+		value.String{Value: "ops", Valid: true},
+		value.Dynamic{Value: []byte(`["a","b"]`), Valid: true},
+	}
+
+	type embedded struct {
+		Base
+		audit
+		Tags *[]string
+	}
+
+	type embeddedPtr struct {
+		*Base
+		Name string
+	}
+
+	type conflict struct {
+		Base
+		Details
+	}
+
+	type untaggedConflict struct {
+		Base
+		audit
+		origin
+	}
+
+	type taggedEmbedded struct {
+		Base `kusto:"Tags"`
+	}
+
+	tests := []struct {
+		desc string
+		got  interface{}
+		want interface{}
+		err  bool
+	}{
+		{
+			desc: "Promoted fields of embedded structs",
+			got:  &embedded{},
+			want: &embedded{Base: Base{ID: 1, Name: "node"}, audit: audit{Source: "ingest"}, Tags: &[]string{"a", "b"}},
+		},
+		{
+			desc: "Embedded pointer is allocated and shallower fields hide deeper ones",
+			got:  &embeddedPtr{},
+			want: &embeddedPtr{Base: &Base{ID: 1}, Name: "node"},
+		},
+		{
+			desc: "Tagged field wins a conflict at the same depth",
+			got:  &conflict{},
+			want: &conflict{Base: Base{ID: 1}, Details: Details{Name: "node", Owner: "ops"}},
+		},
+		{
+			desc: "Untagged fields at the same depth hide each other",
+			got:  &untaggedConflict{},
+			want: &untaggedConflict{Base: Base{ID: 1, Name: "node"}},
+		},
+		{
+			desc: "Tagged embedded struct is not promoted",
+			got:  &taggedEmbedded{},
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Row{ColumnTypes: columns, Values: row}
+			err := r.ToStruct(test.got)
+			if test.err {
+				assert.ErrorContains(t, err, "column Tags could not store in struct.Base")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, test.got)
+		})
+	}
+}
+
+func TestRowToStructUnmarshaler(t *testing.T) {
+	t.Parallel()
+
+	columns := Columns{
+		{Name: "Temp", Type: types.Real},
+		{Name: "Null", Type: types.Real},
+		{Name: "Name", Type: types.String},
+		{Name: "Tags", Type: types.Dynamic},
+	}
+	row := value.Values{
+		value.Real{Value: 21.5, Valid: true},
+		value.Real{},
+		value.String{Value: "node", Valid: true},
+		value.Dynamic{},
+	}
+
+	type record struct {
+		Temp celsius
+		Null *celsius
+		Name upper
+		Tags *[]string
+	}
+
+	null := celsius(-273.15)
+	got := &record{}
+	r := &Row{ColumnTypes: columns, Values: row}
+	require.NoError(t, r.ToStruct(got))
+	assert.Equal(t, &record{Temp: 21.5, Null: &null, Name: "NODE"}, got)
+
+	var temp celsius
+	var name upper
+	require.NoError(t, r.ExtractValues(&temp, nil, &name, nil))
+	assert.Equal(t, celsius(21.5), temp)
+	assert.Equal(t, upper("NODE"), name)
+
+	err := r.ToStruct(&struct {
+		Name celsius
+	}{})
+	assert.ErrorContains(t, err, "column Name could not store in struct.Name: celsius must be a real")
+}
+
+func TestRowToStructDynamicPath(t *testing.T) {
+	t.Parallel()
+
+	columns := Columns{
+		{Name: "Id", Type: types.Long},
+		{Name: "Props", Type: types.Dynamic},
+	}
+	row := value.Values{
+		value.Long{Value: 1, Valid: true},
+		value.Dynamic{Value: []byte(`{"owner": {"name": "ops", "since": 2019}, "tags": ["a", "b"], "size": 1.5}`), Valid: true},
+	}
+
+	type owner struct {
+		Name  string `json:"name"`
+		Since int    `json:"since"`
+	}
+
+	type record struct {
+		ID        int64      `kusto:"Id"`
+		OwnerName string     `kusto:"Props.owner.name"`
+		Owner     *owner     `kusto:"Props.owner"`
+		FirstTag  string     `kusto:"Props.tags[0]"`
+		Size      float32    `kusto:"Props.size"`
+		Since     value.Long `kusto:"Props.owner.since"`
+		Missing   int        `kusto:"Props.missing"`
+		Upper     upper      `kusto:"Props.tags[1]"`
+		Props     value.Dynamic
+	}
+
+	got := &record{Missing: 7}
+	r := &Row{ColumnTypes: columns, Values: row}
+	require.NoError(t, r.ToStruct(got))
+	assert.Equal(t, &record{
+		ID:        1,
+		OwnerName: "ops",
+		Owner:     &owner{Name: "ops", Since: 2019},
+		FirstTag:  "a",
+		Size:      1.5,
+		Since:     value.Long{Value: 2019, Valid: true},
+		Missing:   7,
+		Upper:     "B",
+		Props:     row[1].(value.Dynamic),
+	}, got)
+
+	err := r.ToStruct(&struct {
+		Name int `kusto:"Props.owner.name"`
+	}{})
+	assert.ErrorContains(t, err, "column Props path owner.name could not store in struct.Name")
+}
+
 func TestExtractValuePartial(t *testing.T) {
 	t.Parallel()
 	columns := Columns{
@@ -243,3 +471,28 @@ func TestExtractValueAll(t *testing.T) {
 	assert.Equal(t, time.Duration(10), timespanVar)
 	assert.Equal(t, "5.6", decimalVar)
 }
+
+func TestRowString(t *testing.T) {
+	t.Parallel()
+
+	row := &Row{
+		ColumnTypes: Columns{
+			{Name: "Name", Type: types.String},
+			{Name: "Count", Type: types.Long},
+			{Name: "Small", Type: types.Dynamic},
+			{Name: "Wide", Type: types.Dynamic},
+			{Name: "Missing", Type: types.String},
+			{Name: "When", Type: types.DateTime},
+		},
+		Values: value.Values{
+			value.NewString("a \"quoted\"\nname"),
+			value.NewLong(3),
+			value.NewDynamic([]byte("{\"a\":\n1}")),
+			value.NewDynamic([]byte(`{"first":"a long value","second":"another long value","third":[1,2,3]}`)),
+			value.NullString(),
+			value.NullDateTime(),
+		},
+	}
+
+	assert.Equal(t, `Name="a \"quoted\"\nname" Count=3 Small={"a":\n1} Wide={...3 keys} Missing=null When=null`, row.String())
+}
@@ -15,6 +15,16 @@ type Bool struct {
 
 func (Bool) isKustoVal() {}
 
+// NewBool creates a non-null Bool holding v.
+func NewBool(v bool) Bool {
+	return Bool{Value: v, Valid: true}
+}
+
+// NullBool creates a null Bool.
+func NullBool() Bool {
+	return Bool{}
+}
+
 // String implements fmt.Stringer.
 func (bo Bool) String() string {
 	if !bo.Valid {
@@ -7,6 +7,8 @@ import (
 )
 
 // DateTime represents a Kusto datetime type.  DateTime implements Kusto.
+// Kusto datetimes are in UTC, with a precision of 100ns (one tick). Decoded values are always in UTC. Values in another
+// location are converted to UTC when marshaled, and anything finer than a tick is truncated.
 type DateTime struct {
 	// Value holds the value of the type.
 	Value time.Time
@@ -19,20 +21,30 @@ func (d DateTime) String() string {
 	if !d.Valid {
 		return ""
 	}
-	return fmt.Sprint(d.Value.Format(time.RFC3339Nano))
+	return d.Marshal()
 }
 
 func (DateTime) isKustoVal() {}
 
-// Marshal marshals the DateTime into a Kusto compatible string.
+// NewDateTime creates a non-null DateTime holding v.
+func NewDateTime(v time.Time) DateTime {
+	return DateTime{Value: v, Valid: true}
+}
+
+// NullDateTime creates a null DateTime.
+func NullDateTime() DateTime {
+	return DateTime{}
+}
+
+// Marshal marshals the DateTime into a Kusto compatible string, which is in UTC.
 func (d DateTime) Marshal() string {
 	if !d.Valid {
 		return time.Time{}.Format(time.RFC3339Nano)
 	}
-	return d.Value.Format(time.RFC3339Nano)
+	return d.Value.UTC().Truncate(tick).Format(time.RFC3339Nano)
 }
 
-// Unmarshal unmarshals i into DateTime. i must be a string representing RFC3339Nano or nil.
+// Unmarshal unmarshals i into DateTime. i must be a string representing RFC3339Nano or nil. The Value is in UTC.
 func (d *DateTime) Unmarshal(i interface{}) error {
 	if i == nil {
 		d.Value = time.Time{}
@@ -49,7 +61,7 @@ func (d *DateTime) Unmarshal(i interface{}) error {
 	if err != nil {
 		return fmt.Errorf("Column with type 'datetime' had value %s which did not parse: %s", str, err)
 	}
-	d.Value = t
+	d.Value = t.UTC()
 	d.Valid = true
 
 	return nil
@@ -5,8 +5,13 @@ import (
 	"math/big"
 	"reflect"
 	"regexp"
+	"strconv"
 )
 
+// DecimalPrec is the precision, in bits, of the *big.Float values parsed from a Decimal. It holds the 34 significant
+// digits of a Kusto decimal exactly enough that they are formatted back to the same digits.
+const DecimalPrec = 128
+
 // Decimal represents a Kusto decimal type.  Decimal implements Kusto.
 // Because Go does not have a dynamic decimal type that meets all needs, Decimal
 // provides the string representation for you to unmarshal into.
@@ -19,6 +24,22 @@ type Decimal struct {
 
 func (Decimal) isKustoVal() {}
 
+// NewDecimal creates a non-null Decimal. v must be the string representation of a decimal number.
+func NewDecimal(v string) Decimal {
+	return Decimal{Value: v, Valid: true}
+}
+
+// NewDecimalFromFloat creates a non-null Decimal from f, with the shortest decimal representation that parses back to
+// f at its precision. f must not be nil or infinite.
+func NewDecimalFromFloat(f *big.Float) Decimal {
+	return Decimal{Value: f.Text('f', -1), Valid: true}
+}
+
+// NullDecimal creates a null Decimal.
+func NullDecimal() Decimal {
+	return Decimal{}
+}
+
 // String implements fmt.Stringer.
 func (d Decimal) String() string {
 	if !d.Valid {
@@ -32,7 +53,19 @@ func (d *Decimal) ParseFloat(base int, prec uint, mode big.RoundingMode) (f *big
 	return big.ParseFloat(d.Value, base, prec, mode)
 }
 
-var DecRE = regexp.MustCompile(`^((\d+\.?\d*)|(\d*\.?\d+))$`) // Matches decimal numbers, with or without decimal dot, with optional parts missing.
+// ParsedDecimal returns the value as a *big.Float of DecimalPrec bits, or nil if the value is null.
+func (d Decimal) ParsedDecimal() (*big.Float, error) {
+	if !d.Valid {
+		return nil, nil
+	}
+	f, _, err := big.ParseFloat(d.Value, 10, DecimalPrec, big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("Decimal value %q is not a decimal number: %w", d.Value, err)
+	}
+	return f, nil
+}
+
+var DecRE = regexp.MustCompile(`^-?((\d+\.?\d*)|(\d*\.?\d+))$`) // Matches decimal numbers, with or without sign or decimal dot, with optional parts missing.
 
 // Unmarshal unmarshals i into Decimal. i must be a string representing a decimal type or nil.
 func (d *Decimal) Unmarshal(i interface{}) error {
@@ -56,7 +89,9 @@ func (d *Decimal) Unmarshal(i interface{}) error {
 	return nil
 }
 
-// Convert Decimal into reflect value.
+// Convert Decimal into reflect value. The receiver can be a string, a float64, a *big.Float, a Decimal or pointers to
+// them. A float64 only holds about 15 significant digits, the others are lost; use a string or a *big.Float to keep
+// all of them.
 func (d Decimal) Convert(v reflect.Value) error {
 	t := v.Type()
 	switch {
@@ -71,6 +106,33 @@ func (d Decimal) Convert(v reflect.Value) error {
 			v.Set(reflect.ValueOf(i))
 		}
 		return nil
+	case t.Kind() == reflect.Float64:
+		if d.Valid {
+			f, err := strconv.ParseFloat(d.Value, 64)
+			if err != nil {
+				return fmt.Errorf("Column was type Kusto.Decimal, value %q could not be converted to float64: %w", d.Value, err)
+			}
+			v.SetFloat(f)
+		}
+		return nil
+	case t.ConvertibleTo(reflect.TypeOf(new(float64))):
+		if d.Valid {
+			f, err := strconv.ParseFloat(d.Value, 64)
+			if err != nil {
+				return fmt.Errorf("Column was type Kusto.Decimal, value %q could not be converted to float64: %w", d.Value, err)
+			}
+			v.Set(reflect.ValueOf(&f).Convert(t))
+		}
+		return nil
+	case t.ConvertibleTo(reflect.TypeOf(new(big.Float))):
+		if d.Valid {
+			f, err := d.ParsedDecimal()
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(f).Convert(t))
+		}
+		return nil
 	case t.ConvertibleTo(reflect.TypeOf(Decimal{})):
 		v.Set(reflect.ValueOf(d))
 		return nil
@@ -1,9 +1,12 @@
 package value
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 // Dynamic represents a Kusto dynamic type.  Dynamic implements Kusto.
@@ -16,6 +19,16 @@ type Dynamic struct {
 
 func (Dynamic) isKustoVal() {}
 
+// NewDynamic creates a non-null Dynamic. v must be the JSON representation of a value.
+func NewDynamic(v []byte) Dynamic {
+	return Dynamic{Value: v, Valid: true}
+}
+
+// NullDynamic creates a null Dynamic.
+func NullDynamic() Dynamic {
+	return Dynamic{}
+}
+
 // String implements fmt.Stringer.
 func (d Dynamic) String() string {
 	if !d.Valid {
@@ -25,6 +38,248 @@ func (d Dynamic) String() string {
 	return string(d.Value)
 }
 
+// Summary returns a short description of an object or array, such as "{...3 keys}" or "[...5 items]".
+// Other values, or values that are not valid JSON, are returned as String() does.
+func (d Dynamic) Summary() string {
+	if !d.Valid {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(d.Value, &v); err != nil {
+		return string(d.Value)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			return "{...1 key}"
+		}
+		return fmt.Sprintf("{...%d keys}", len(v))
+	case []interface{}:
+		if len(v) == 1 {
+			return "[...1 item]"
+		}
+		return fmt.Sprintf("[...%d items]", len(v))
+	}
+	return string(d.Value)
+}
+
+// UnmarshalInto unmarshals the JSON of the value into v, as json.Unmarshal() does. A null value leaves v unchanged.
+// Unmarshal() is not this: it sets the Dynamic from a value received from Kusto.
+func (d Dynamic) UnmarshalInto(v any) error {
+	if !d.Valid {
+		return nil
+	}
+	return json.Unmarshal(d.Value, v)
+}
+
+// Map returns the value as a JSON object, or nil if the value is null. Numbers are json.Number, so that integers
+// larger than float64 can hold are not rounded.
+func (d Dynamic) Map() (map[string]any, error) {
+	if !d.Valid {
+		return nil, nil
+	}
+	var m map[string]any
+	if err := decodeNumbers(d.Value, &m); err != nil {
+		return nil, fmt.Errorf("dynamic value is not a JSON object: %w", err)
+	}
+	return m, nil
+}
+
+// Array returns the value as a JSON array, or nil if the value is null. Numbers are json.Number, so that integers
+// larger than float64 can hold are not rounded.
+func (d Dynamic) Array() ([]any, error) {
+	if !d.Valid {
+		return nil, nil
+	}
+	var a []any
+	if err := decodeNumbers(d.Value, &a); err != nil {
+		return nil, fmt.Errorf("dynamic value is not a JSON array: %w", err)
+	}
+	return a, nil
+}
+
+// GetPath returns the value at path within the value, such as "a.b[2].c", as the Kusto type that holds it: objects
+// and arrays are returned as a Dynamic, strings as a String, booleans as a Bool, integers as a Long, or as a Decimal
+// when they are larger than a long, and other numbers as a Real, or as a Decimal when they are larger than a float64.
+// Numbers that neither holds, such as 1e400, and nulls are returned as a Dynamic. See Lookup() for the paths.
+func (d Dynamic) GetPath(path string) (Kusto, error) {
+	sub, err := d.Lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Valid {
+		return sub, nil
+	}
+	return dynamicLeaf(sub.Value)
+}
+
+// Lookup returns the JSON at path within the value, such as "a.b[2].c", as a Dynamic. A property whose name is not an
+// identifier is written in brackets, as in `a["b.c"]` or `a['b c']`, and the path may start with "$". Like in Kusto,
+// missing properties, out of range indexes and the properties of null are a null Dynamic.
+func (d Dynamic) Lookup(path string) (Dynamic, error) {
+	segments, err := parseDynamicPath(path)
+	if err != nil {
+		return Dynamic{}, err
+	}
+	if !d.Valid {
+		return NullDynamic(), nil
+	}
+
+	raw := json.RawMessage(d.Value)
+	for i, seg := range segments {
+		if isJSONNull(raw) {
+			return NullDynamic(), nil
+		}
+		switch {
+		case seg.isIndex:
+			var a []json.RawMessage
+			if err := json.Unmarshal(raw, &a); err != nil {
+				return Dynamic{}, fmt.Errorf("dynamic path %q: %s is not an array", path, dynamicPathPrefix(segments[:i]))
+			}
+			if seg.index >= len(a) {
+				return NullDynamic(), nil
+			}
+			raw = a[seg.index]
+		default:
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return Dynamic{}, fmt.Errorf("dynamic path %q: %s is not an object", path, dynamicPathPrefix(segments[:i]))
+			}
+			v, ok := m[seg.key]
+			if !ok {
+				return NullDynamic(), nil
+			}
+			raw = v
+		}
+	}
+	if isJSONNull(raw) {
+		return NullDynamic(), nil
+	}
+	return NewDynamic(raw), nil
+}
+
+// dynamicLeaf returns raw, the JSON of a value within a Dynamic, as the Kusto type that holds it.
+func dynamicLeaf(raw []byte) (Kusto, error) {
+	var v any
+	if err := decodeNumbers(raw, &v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case string:
+		return NewString(v), nil
+	case bool:
+		return NewBool(v), nil
+	case json.Number:
+		s := v.String()
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return NewLong(i), nil
+		}
+		isInt := DecRE.MatchString(s) && !strings.Contains(s, ".")
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !isInt {
+			return NewReal(f), nil
+		}
+		if DecRE.MatchString(s) {
+			return NewDecimal(s), nil
+		}
+		// Such as 1e400, which none of the scalar types holds.
+		return NewDynamic([]byte(s)), nil
+	}
+	return NewDynamic(raw), nil
+}
+
+// decodeNumbers unmarshals data into v with the numbers as json.Number.
+func decodeNumbers(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("invalid JSON: data after the value")
+	}
+	return nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+// dynamicPathSegment is a property name or an array index in a path of GetPath().
+type dynamicPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseDynamicPath splits a path of GetPath() into its segments.
+func parseDynamicPath(path string) ([]dynamicPathSegment, error) {
+	p := strings.TrimPrefix(path, "$")
+	// After "$" and after a segment, a property name follows a '.'.
+	needDot := p != path
+
+	var segs []dynamicPathSegment
+	for i := 0; i < len(p); {
+		if p[i] == '[' {
+			end := strings.IndexByte(p[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("dynamic path %q has an unclosed '['", path)
+			}
+			inner := p[i+1 : i+end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segs = append(segs, dynamicPathSegment{key: inner[1 : len(inner)-1]})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("dynamic path %q has an invalid index %q", path, inner)
+				}
+				segs = append(segs, dynamicPathSegment{index: n, isIndex: true})
+			}
+			i += end + 1
+			needDot = true
+			continue
+		}
+
+		if needDot {
+			if p[i] != '.' {
+				return nil, fmt.Errorf("dynamic path %q expects '.' or '[' at offset %d", path, i+len(path)-len(p))
+			}
+			i++
+		}
+		end := strings.IndexAny(p[i:], ".[")
+		if end == -1 {
+			end = len(p) - i
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("dynamic path %q has an empty property name at offset %d", path, i+len(path)-len(p))
+		}
+		segs = append(segs, dynamicPathSegment{key: p[i : i+end]})
+		i += end
+		needDot = true
+	}
+	return segs, nil
+}
+
+// dynamicPathPrefix returns the path of segs, for errors.
+func dynamicPathPrefix(segs []dynamicPathSegment) string {
+	if len(segs) == 0 {
+		return "the value"
+	}
+	sb := strings.Builder{}
+	for _, seg := range segs {
+		switch {
+		case seg.isIndex:
+			sb.WriteString("[" + strconv.Itoa(seg.index) + "]")
+		default:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(seg.key)
+		}
+	}
+	return sb.String()
+}
+
 // Unmarshal unmarshal's i into Dynamic. i must be a string, []byte, map[string]interface{}, []interface{}, other JSON serializable value or nil.
 // If []byte or string, must be a JSON representation of a value.
 func (d *Dynamic) Unmarshal(i interface{}) error {
@@ -79,15 +334,19 @@ func (d Dynamic) Convert(v reflect.Value) error {
 		}
 
 		ptr := reflect.New(t)
-		if err := json.Unmarshal([]byte(d.Value), ptr.Interface()); err != nil {
+		if err := d.UnmarshalInto(ptr.Interface()); err != nil {
 			return fmt.Errorf("Error occurred while trying to unmarshal Dynamic into a %s: %s", t.Kind(), err)
 		}
 
 		valueToSet = ptr.Elem()
 	case t.Kind() == reflect.Struct:
+		if len(d.Value) == 0 {
+			// A null value, which has no JSON.
+			return nil
+		}
 		structPtr := reflect.New(t)
 
-		if err := json.Unmarshal([]byte(d.Value), structPtr.Interface()); err != nil {
+		if err := json.Unmarshal(d.Value, structPtr.Interface()); err != nil {
 			return fmt.Errorf("Could not unmarshal type dynamic into receiver: %s", err)
 		}
 
@@ -1,11 +1,13 @@
 package value_test
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type DynamicConverterTestCase struct {
@@ -210,3 +212,124 @@ func TestDynamicConverterNegative(t *testing.T) {
 
 	}
 }
+
+func TestDynamicSummary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc  string
+		value value.Dynamic
+		want  string
+	}{
+		{desc: "null", value: value.NullDynamic(), want: ""},
+		{desc: "object", value: value.NewDynamic([]byte(`{"a":1,"b":{"c":2},"d":[]}`)), want: "{...3 keys}"},
+		{desc: "object with one key", value: value.NewDynamic([]byte(`{"a":1}`)), want: "{...1 key}"},
+		{desc: "array", value: value.NewDynamic([]byte(`[1,2]`)), want: "[...2 items]"},
+		{desc: "array with one item", value: value.NewDynamic([]byte(`["a"]`)), want: "[...1 item]"},
+		{desc: "scalar", value: value.NewDynamic([]byte(`"hello"`)), want: `"hello"`},
+		{desc: "not JSON", value: value.NewDynamic([]byte(`{`)), want: `{`},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, test.value.Summary())
+		})
+	}
+}
+
+func TestDynamicGetPath(t *testing.T) {
+	t.Parallel()
+
+	d := value.NewDynamic([]byte(`{
+		"a": {"b": [1, 2, {"c": "deep"}]},
+		"big": 123456789012345678901234567890,
+		"huge": 1e400,
+		"pi": 3.14,
+		"ok": true,
+		"nothing": null,
+		"dotted.key": "x",
+		"obj": {"k": [1]}
+	}`))
+
+	tests := []struct {
+		path string
+		want value.Kusto
+		err  bool
+	}{
+		{path: "a.b[2].c", want: value.NewString("deep")},
+		{path: "$.a.b[0]", want: value.NewLong(1)},
+		{path: "a['b'][1]", want: value.NewLong(2)},
+		{path: "big", want: value.NewDecimal("123456789012345678901234567890")},
+		{path: "huge", want: value.NewDynamic([]byte("1e400"))},
+		{path: "pi", want: value.NewReal(3.14)},
+		{path: "ok", want: value.NewBool(true)},
+		{path: `["dotted.key"]`, want: value.NewString("x")},
+		{path: "obj", want: value.NewDynamic([]byte(`{"k": [1]}`))},
+		{path: "nothing", want: value.NullDynamic()},
+		{path: "nothing.below", want: value.NullDynamic()},
+		{path: "missing", want: value.NullDynamic()},
+		{path: "a.b[9]", want: value.NullDynamic()},
+		{path: "a.b.c", err: true},
+		{path: "ok[0]", err: true},
+		{path: "a..b", err: true},
+		{path: "a.b[x]", err: true},
+		{path: "a.b[1", err: true},
+		{path: "$a", err: true},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := d.GetPath(test.path)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	got, err := value.NullDynamic().GetPath("a.b")
+	require.NoError(t, err)
+	assert.Equal(t, value.NullDynamic(), got)
+}
+
+func TestDynamicMapArray(t *testing.T) {
+	t.Parallel()
+
+	m, err := value.NewDynamic([]byte(`{"id": 12345678901234567890, "name": "a"}`)).Map()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": json.Number("12345678901234567890"), "name": "a"}, m)
+
+	a, err := value.NewDynamic([]byte(`[1.5, null, "x"]`)).Array()
+	require.NoError(t, err)
+	assert.Equal(t, []any{json.Number("1.5"), nil, "x"}, a)
+
+	_, err = value.NewDynamic([]byte(`[1]`)).Map()
+	assert.Error(t, err)
+	_, err = value.NewDynamic([]byte(`{}`)).Array()
+	assert.Error(t, err)
+
+	m, err = value.NullDynamic().Map()
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	a, err = value.NullDynamic().Array()
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	var s TestStruct
+	require.NoError(t, value.NewDynamic([]byte(`{"name": "A", "id": 1}`)).UnmarshalInto(&s))
+	assert.Equal(t, TestStruct{Name: "A", ID: 1}, s)
+	require.NoError(t, value.NullDynamic().UnmarshalInto(&s))
+	assert.Equal(t, TestStruct{Name: "A", ID: 1}, s)
+
+	var ptr *TestStruct
+	require.NoError(t, value.NullDynamic().Convert(reflect.ValueOf(&ptr).Elem()))
+	assert.Nil(t, ptr)
+}
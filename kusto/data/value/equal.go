@@ -0,0 +1,215 @@
+package value
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Equal returns true if a and b hold the same Kusto type and the same value. The semantics are:
+//
+//   - Values of different types are never equal, even if they hold the same number (Int(1) != Long(1)).
+//   - Null values are equal to each other and different from any non-null value, including the zero value.
+//   - DateTime values are equal if they are the same instant, regardless of their time zone.
+//   - Decimal values are equal if they are numerically equal, so "1.50" equals "1.5".
+//   - Dynamic values are equal if their parsed JSON is deeply equal, so whitespace and key order do not matter.
+//     If either value is not valid JSON, the raw bytes are compared.
+//   - Real values compare with ==, except that NaN is equal to NaN.
+//
+// A nil Kusto is only equal to another nil Kusto.
+func Equal(a, b Kusto) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch a := a.(type) {
+	case Bool:
+		b, ok := b.(Bool)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value)
+	case Int:
+		b, ok := b.(Int)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value)
+	case Long:
+		b, ok := b.(Long)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value)
+	case Real:
+		b, ok := b.(Real)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value || (math.IsNaN(a.Value) && math.IsNaN(b.Value)))
+	case Decimal:
+		b, ok := b.(Decimal)
+		if !ok || a.Valid != b.Valid {
+			return false
+		}
+		if !a.Valid {
+			return true
+		}
+		c, err := compareDecimal(a.Value, b.Value)
+		if err != nil {
+			return a.Value == b.Value
+		}
+		return c == 0
+	case String:
+		b, ok := b.(String)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value)
+	case Dynamic:
+		b, ok := b.(Dynamic)
+		if !ok || a.Valid != b.Valid {
+			return false
+		}
+		if !a.Valid {
+			return true
+		}
+		var av, bv interface{}
+		if json.Unmarshal(a.Value, &av) != nil || json.Unmarshal(b.Value, &bv) != nil {
+			return bytes.Equal(a.Value, b.Value)
+		}
+		return reflect.DeepEqual(av, bv)
+	case DateTime:
+		b, ok := b.(DateTime)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value.Equal(b.Value))
+	case Timespan:
+		b, ok := b.(Timespan)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value)
+	case GUID:
+		b, ok := b.(GUID)
+		return ok && a.Valid == b.Valid && (!a.Valid || a.Value == b.Value)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Compare returns -1 if a is less than b, 0 if they are equal and 1 if a is greater than b.
+// a and b must be of the same type, which must be an ordered type: Bool (false < true), Int, Long, Real, Decimal,
+// String (byte-wise), DateTime, Timespan or GUID (byte-wise). Dynamic values cannot be compared.
+// Nulls are equal to each other and less than any non-null value, which matches Kusto's default ascending sort.
+// NaN is less than any other Real and equal to itself.
+func Compare(a, b Kusto) (int, error) {
+	if a == nil || b == nil {
+		return 0, fmt.Errorf("cannot compare a nil value.Kusto")
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return 0, fmt.Errorf("cannot compare a %T to a %T", a, b)
+	}
+
+	// nulls returns the order of a and b if one of them is null.
+	nulls := func(aValid, bValid bool) (int, bool) {
+		switch {
+		case !aValid && !bValid:
+			return 0, true
+		case !aValid:
+			return -1, true
+		case !bValid:
+			return 1, true
+		}
+		return 0, false
+	}
+
+	switch a := a.(type) {
+	case Bool:
+		b := b.(Bool)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		switch {
+		case a.Value == b.Value:
+			return 0, nil
+		case b.Value:
+			return -1, nil
+		}
+		return 1, nil
+	case Int:
+		b := b.(Int)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		return compareInt64(int64(a.Value), int64(b.Value)), nil
+	case Long:
+		b := b.(Long)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		return compareInt64(a.Value, b.Value), nil
+	case Real:
+		b := b.(Real)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		aNaN, bNaN := math.IsNaN(a.Value), math.IsNaN(b.Value)
+		switch {
+		case aNaN && bNaN:
+			return 0, nil
+		case aNaN:
+			return -1, nil
+		case bNaN:
+			return 1, nil
+		case a.Value < b.Value:
+			return -1, nil
+		case a.Value > b.Value:
+			return 1, nil
+		}
+		return 0, nil
+	case Decimal:
+		b := b.(Decimal)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		return compareDecimal(a.Value, b.Value)
+	case String:
+		b := b.(String)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		return strings.Compare(a.Value, b.Value), nil
+	case DateTime:
+		b := b.(DateTime)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		switch {
+		case a.Value.Before(b.Value):
+			return -1, nil
+		case a.Value.After(b.Value):
+			return 1, nil
+		}
+		return 0, nil
+	case Timespan:
+		b := b.(Timespan)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		return compareInt64(int64(a.Value), int64(b.Value)), nil
+	case GUID:
+		b := b.(GUID)
+		if c, ok := nulls(a.Valid, b.Valid); ok {
+			return c, nil
+		}
+		return bytes.Compare(a.Value[:], b.Value[:]), nil
+	}
+	return 0, fmt.Errorf("values of type %T cannot be ordered", a)
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareDecimal compares two decimal numbers exactly.
+func compareDecimal(a, b string) (int, error) {
+	ar, ok := new(big.Rat).SetString(a)
+	if !ok {
+		return 0, fmt.Errorf("Decimal value %q is not a decimal number", a)
+	}
+	br, ok := new(big.Rat).SetString(b)
+	if !ok {
+		return 0, fmt.Errorf("Decimal value %q is not a decimal number", b)
+	}
+	return ar.Cmp(br), nil
+}
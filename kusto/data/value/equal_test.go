@@ -0,0 +1,161 @@
+package value
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstructors(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	u := uuid.New()
+
+	assert.Equal(t, Bool{Value: true, Valid: true}, NewBool(true))
+	assert.Equal(t, Int{Value: 1, Valid: true}, NewInt(1))
+	assert.Equal(t, Long{Value: 1, Valid: true}, NewLong(1))
+	assert.Equal(t, Real{Value: 1.5, Valid: true}, NewReal(1.5))
+	assert.Equal(t, Decimal{Value: "1.5", Valid: true}, NewDecimal("1.5"))
+	assert.Equal(t, String{Value: "a", Valid: true}, NewString("a"))
+	assert.Equal(t, Dynamic{Value: []byte(`{}`), Valid: true}, NewDynamic([]byte(`{}`)))
+	assert.Equal(t, DateTime{Value: now, Valid: true}, NewDateTime(now))
+	assert.Equal(t, Timespan{Value: time.Second, Valid: true}, NewTimespan(time.Second))
+	assert.Equal(t, GUID{Value: u, Valid: true}, NewGUID(u))
+
+	for _, v := range []Kusto{NullBool(), NullInt(), NullLong(), NullReal(), NullDecimal(), NullString(), NullDynamic(),
+		NullDateTime(), NullTimespan(), NullGUID()} {
+		assert.Equal(t, "", v.String(), "%T", v)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	u := uuid.New()
+
+	tests := []struct {
+		desc string
+		a, b Kusto
+		want bool
+	}{
+		{desc: "nil and nil", want: true},
+		{desc: "nil and null", a: NullLong(), want: false},
+		{desc: "different types", a: NewInt(1), b: NewLong(1), want: false},
+		{desc: "different types, both null", a: NullInt(), b: NullLong(), want: false},
+
+		{desc: "Bool equal", a: NewBool(true), b: NewBool(true), want: true},
+		{desc: "Bool not equal", a: NewBool(true), b: NewBool(false), want: false},
+		{desc: "Bool null vs false", a: NullBool(), b: NewBool(false), want: false},
+		{desc: "Bool nulls", a: NullBool(), b: Bool{Value: true}, want: true},
+
+		{desc: "Int equal", a: NewInt(3), b: NewInt(3), want: true},
+		{desc: "Int null vs zero", a: NullInt(), b: NewInt(0), want: false},
+		{desc: "Long equal", a: NewLong(3), b: NewLong(3), want: true},
+		{desc: "Long not equal", a: NewLong(3), b: NewLong(4), want: false},
+		{desc: "Long null vs zero", a: NewLong(0), b: NullLong(), want: false},
+		{desc: "Long nulls", a: NullLong(), b: NullLong(), want: true},
+
+		{desc: "Real equal", a: NewReal(1.5), b: NewReal(1.5), want: true},
+		{desc: "Real NaN", a: NewReal(math.NaN()), b: NewReal(math.NaN()), want: true},
+		{desc: "Real NaN vs number", a: NewReal(math.NaN()), b: NewReal(0), want: false},
+		{desc: "Real null vs zero", a: NullReal(), b: NewReal(0), want: false},
+
+		{desc: "Decimal same text", a: NewDecimal("1.5"), b: NewDecimal("1.5"), want: true},
+		{desc: "Decimal trailing zeros", a: NewDecimal("1.50"), b: NewDecimal("1.5"), want: true},
+		{desc: "Decimal leading zeros", a: NewDecimal("001"), b: NewDecimal("1.0"), want: true},
+		{desc: "Decimal not equal", a: NewDecimal("1.51"), b: NewDecimal("1.5"), want: false},
+		{desc: "Decimal null vs zero", a: NullDecimal(), b: NewDecimal("0"), want: false},
+
+		{desc: "String equal", a: NewString("a"), b: NewString("a"), want: true},
+		{desc: "String null vs empty", a: NullString(), b: NewString(""), want: false},
+		{desc: "String nulls", a: NullString(), b: NullString(), want: true},
+
+		{desc: "Dynamic whitespace and key order", a: NewDynamic([]byte(`{"a":1,"b":[1,2]}`)), b: NewDynamic([]byte(`{ "b": [1, 2], "a": 1.0 }`)), want: true},
+		{desc: "Dynamic not equal", a: NewDynamic([]byte(`{"a":1}`)), b: NewDynamic([]byte(`{"a":2}`)), want: false},
+		{desc: "Dynamic array order", a: NewDynamic([]byte(`[1,2]`)), b: NewDynamic([]byte(`[2,1]`)), want: false},
+		{desc: "Dynamic invalid JSON", a: NewDynamic([]byte(`{`)), b: NewDynamic([]byte(`{`)), want: true},
+		{desc: "Dynamic null vs JSON null", a: NullDynamic(), b: NewDynamic([]byte(`null`)), want: false},
+
+		{desc: "DateTime same instant", a: NewDateTime(now), b: NewDateTime(now.In(time.FixedZone("X", 3600))), want: true},
+		{desc: "DateTime not equal", a: NewDateTime(now), b: NewDateTime(now.Add(time.Nanosecond)), want: false},
+		{desc: "DateTime null vs zero", a: NullDateTime(), b: NewDateTime(time.Time{}), want: false},
+
+		{desc: "Timespan equal", a: NewTimespan(time.Hour), b: NewTimespan(60 * time.Minute), want: true},
+		{desc: "Timespan null vs zero", a: NullTimespan(), b: NewTimespan(0), want: false},
+
+		{desc: "GUID equal", a: NewGUID(u), b: NewGUID(u), want: true},
+		{desc: "GUID not equal", a: NewGUID(u), b: NewGUID(uuid.New()), want: false},
+		{desc: "GUID null vs zero", a: NullGUID(), b: NewGUID(uuid.UUID{}), want: false},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, Equal(test.a, test.b))
+			assert.Equal(t, test.want, Equal(test.b, test.a), "Equal must be symmetric")
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := []struct {
+		desc string
+		a, b Kusto
+		want int
+		err  bool
+	}{
+		{desc: "nil", a: NewLong(1), err: true},
+		{desc: "different types", a: NewInt(1), b: NewLong(1), err: true},
+		{desc: "Dynamic", a: NewDynamic([]byte(`1`)), b: NewDynamic([]byte(`2`)), err: true},
+		{desc: "Decimal not a number", a: Decimal{Value: "x", Valid: true}, b: NewDecimal("1"), err: true},
+
+		{desc: "nulls", a: NullLong(), b: NullLong(), want: 0},
+		{desc: "null first", a: NullLong(), b: NewLong(math.MinInt64), want: -1},
+		{desc: "null last", a: NewString(""), b: NullString(), want: 1},
+
+		{desc: "Bool", a: NewBool(false), b: NewBool(true), want: -1},
+		{desc: "Bool equal", a: NewBool(true), b: NewBool(true), want: 0},
+		{desc: "Int", a: NewInt(2), b: NewInt(-1), want: 1},
+		{desc: "Long", a: NewLong(-1), b: NewLong(2), want: -1},
+		{desc: "Real", a: NewReal(1.5), b: NewReal(1.5), want: 0},
+		{desc: "Real NaN", a: NewReal(math.NaN()), b: NewReal(math.Inf(-1)), want: -1},
+		{desc: "Decimal", a: NewDecimal("10.0"), b: NewDecimal("9.99"), want: 1},
+		{desc: "Decimal equal", a: NewDecimal("1.50"), b: NewDecimal("1.5"), want: 0},
+		{desc: "String", a: NewString("a"), b: NewString("b"), want: -1},
+		{desc: "DateTime", a: NewDateTime(now.Add(time.Second)), b: NewDateTime(now), want: 1},
+		{desc: "DateTime same instant", a: NewDateTime(now.UTC()), b: NewDateTime(now.Local()), want: 0},
+		{desc: "Timespan", a: NewTimespan(time.Second), b: NewTimespan(time.Minute), want: -1},
+		{desc: "GUID", a: NewGUID(uuid.MustParse("00000000-0000-0000-0000-000000000001")), b: NewGUID(uuid.MustParse("00000000-0000-0000-0000-000000000002")), want: -1},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Compare(test.a, test.b)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+
+			got, err = Compare(test.b, test.a)
+			require.NoError(t, err)
+			assert.Equal(t, -test.want, got, "Compare must be antisymmetric")
+		})
+	}
+}
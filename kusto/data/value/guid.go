@@ -17,6 +17,16 @@ type GUID struct {
 
 func (GUID) isKustoVal() {}
 
+// NewGUID creates a non-null GUID holding v.
+func NewGUID(v uuid.UUID) GUID {
+	return GUID{Value: v, Valid: true}
+}
+
+// NullGUID creates a null GUID.
+func NullGUID() GUID {
+	return GUID{}
+}
+
 // String implements fmt.Stringer.
 func (g GUID) String() string {
 	if !g.Valid {
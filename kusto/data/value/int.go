@@ -18,6 +18,16 @@ type Int struct {
 
 func (Int) isKustoVal() {}
 
+// NewInt creates a non-null Int holding v.
+func NewInt(v int32) Int {
+	return Int{Value: v, Valid: true}
+}
+
+// NullInt creates a null Int.
+func NullInt() Int {
+	return Int{}
+}
+
 // String implements fmt.Stringer.
 func (in Int) String() string {
 	if !in.Valid {
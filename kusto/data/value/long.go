@@ -18,6 +18,16 @@ type Long struct {
 
 func (Long) isKustoVal() {}
 
+// NewLong creates a non-null Long holding v.
+func NewLong(v int64) Long {
+	return Long{Value: v, Valid: true}
+}
+
+// NullLong creates a null Long.
+func NullLong() Long {
+	return Long{}
+}
+
 // String implements fmt.Stringer.
 func (l Long) String() string {
 	if !l.Valid {
@@ -17,6 +17,16 @@ type Real struct {
 
 func (Real) isKustoVal() {}
 
+// NewReal creates a non-null Real holding v.
+func NewReal(v float64) Real {
+	return Real{Value: v, Valid: true}
+}
+
+// NullReal creates a null Real.
+func NullReal() Real {
+	return Real{}
+}
+
 // String implements fmt.Stringer.
 func (r Real) String() string {
 	if !r.Valid {
@@ -15,6 +15,16 @@ type String struct {
 
 func (String) isKustoVal() {}
 
+// NewString creates a non-null String holding v.
+func NewString(v string) String {
+	return String{Value: v, Valid: true}
+}
+
+// NullString creates a null String.
+func NullString() String {
+	return String{}
+}
+
 // String implements fmt.Stringer.
 func (s String) String() string {
 	if !s.Valid {
@@ -2,6 +2,7 @@ package value
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -20,6 +21,16 @@ type Timespan struct {
 
 func (Timespan) isKustoVal() {}
 
+// NewTimespan creates a non-null Timespan holding v.
+func NewTimespan(v time.Duration) Timespan {
+	return Timespan{Value: v, Valid: true}
+}
+
+// NullTimespan creates a null Timespan.
+func NullTimespan() Timespan {
+	return Timespan{}
+}
+
 // String implements fmt.Stringer.
 func (t Timespan) String() string {
 	if !t.Valid {
@@ -29,7 +40,8 @@ func (t Timespan) String() string {
 }
 
 // Marshal marshals the Timespan into a Kusto compatible string. The string is the contant invariant(c)
-// format. See https://docs.microsoft.com/en-us/dotnet/standard/base-types/standard-timespan-format-strings .
+// format, [-][d.]hh:mm:ss[.fffffff], with the trailing zeros of the fraction removed, which Unmarshal() parses back.
+// See https://docs.microsoft.com/en-us/dotnet/standard/base-types/standard-timespan-format-strings .
 func (t Timespan) Marshal() string {
 	const (
 		day = 24 * time.Hour
@@ -43,12 +55,13 @@ func (t Timespan) Marshal() string {
 	// For example, after we write to our string the number of days that value had, we remove those days
 	// from the duration. We continue doing this until val only holds values < 10 millionth of a second (tick)
 	// as that is the lowest precision in our string representation.
-	val := t.Value
+	// Truncating first keeps the sign off values closer to 0 than a tick, and -math.MinInt64 from overflowing.
+	val := t.Value.Truncate(tick)
 
 	sb := strings.Builder{}
 
 	// Add a - sign if we have a negative value. Convert our value to positive for easier processing.
-	if t.Value < 0 {
+	if val < 0 {
 		sb.WriteString("-")
 		val = val * -1
 	}
@@ -69,21 +82,12 @@ func (t Timespan) Marshal() string {
 	val = val - (seconds * time.Second)
 	sb.WriteString(fmt.Sprintf("%02d:%02d:%02d", int(hours), int(minutes), int(seconds)))
 
-	// Add our sub-second string representation that is proceeded with a ".".
-	milliseconds := val / time.Millisecond
-	val = val - (milliseconds * time.Millisecond)
-	ticks := val / tick
-	if milliseconds > 0 || ticks > 0 {
-		sb.WriteString(fmt.Sprintf(".%03d%d", milliseconds, ticks))
+	// Add our sub-second string representation that is proceeded with a ".", without trailing 0's.
+	if ticks := val / tick; ticks > 0 {
+		sb.WriteString(strings.TrimRight(fmt.Sprintf(".%07d", int64(ticks)), "0"))
 	}
 
-	// Remove any trailing 0's.
-	str := strings.TrimRight(sb.String(), "0")
-	if strings.HasSuffix(str, ":") {
-		str = str + "00"
-	}
-
-	return str
+	return sb.String()
 }
 
 // Unmarshal unmarshals i into Timespan. i must be a string representing a Values timespan or nil.
@@ -112,6 +116,10 @@ func (t *Timespan) Unmarshal(i interface{}) error {
 			v = v[1:]
 		}
 	}
+	// The fields are digits, a sign left in v is a second sign.
+	if strings.ContainsAny(v, "+-") {
+		return fmt.Errorf("value to unmarshal into Timespan has a misplaced sign(%s)", v)
+	}
 
 	sp := strings.Split(v, ":")
 	if len(sp) != 3 {
@@ -139,6 +147,9 @@ func (t *Timespan) Unmarshal(i interface{}) error {
 
 	sum += d
 
+	if sum < 0 {
+		return fmt.Errorf("timespan %s is larger than a time.Duration can hold", v)
+	}
 	if negative {
 		sum = sum * time.Duration(-1)
 	}
@@ -158,6 +169,9 @@ func (t *Timespan) unmarshalDaysHours(s string) (time.Duration, error) {
 		if err != nil {
 			return 0, fmt.Errorf("timespan's hours/day field was incorrect, was %s: %s", s, err)
 		}
+		if hours > int(math.MaxInt64/int64(time.Hour)) {
+			return 0, fmt.Errorf("timespan's hours field %d is larger than a time.Duration can hold", hours)
+		}
 		return time.Duration(hours) * time.Hour, nil
 	case 2:
 		days, err := strconv.Atoi(sp[0])
@@ -165,9 +179,13 @@ func (t *Timespan) unmarshalDaysHours(s string) (time.Duration, error) {
 			return 0, fmt.Errorf("timespan's hours/day field was incorrect, was %s", s)
 		}
 		hours, err := strconv.Atoi(sp[1])
-		if err != nil {
+		if err != nil || hours > 23 {
 			return 0, fmt.Errorf("timespan's hours/day field was incorrect, was %s", s)
 		}
+		// Kusto timespans go up to about 10675199 days, more than a time.Duration holds.
+		if days > int(math.MaxInt64/int64(day)) {
+			return 0, fmt.Errorf("timespan's days field %d is larger than a time.Duration can hold", days)
+		}
 		return time.Duration(days)*day + time.Duration(hours)*time.Hour, nil
 	}
 	return 0, fmt.Errorf("timespan's hours/days field did not have the requisite '.'s, was %s", s)
@@ -198,13 +216,13 @@ func (t *Timespan) unmarshalSeconds(s string) (time.Duration, error) {
 	switch len(sp) {
 	case 1:
 		seconds, err := strconv.Atoi(s)
-		if err != nil {
+		if err != nil || seconds > 59 {
 			return 0, fmt.Errorf("timespan's seconds field was incorrect, was %s", s)
 		}
 		return time.Duration(seconds) * time.Second, nil
 	case 2:
 		seconds, err := strconv.Atoi(sp[0])
-		if err != nil {
+		if err != nil || seconds > 59 {
 			return 0, fmt.Errorf("timespan's seconds field was incorrect, was %s", s)
 		}
 		n, err := strconv.Atoi(sp[1])
@@ -32,6 +32,21 @@ Each provides at minimum the following two methods:
 	.Unmarshal() - Unmarshals the value into a standard Go type.
 
 The Unmarshal() is for internal use, it should not be needed by an end user. Use .Value or table.Row.ToStruct() instead.
+
+# Dynamic values
+
+A Dynamic holds the JSON of its value. UnmarshalInto() decodes it into a Go value, Map() and Array() return it as
+a JSON object or array, and GetPath() returns the value at a path such as "a.b[2].c" as a scalar type. Map(),
+Array() and GetPath() keep the digits of numbers that a float64 cannot hold.
+
+# Building and comparing values
+
+Each type has a New<Type>() constructor for a non-null value and a Null<Type>() constructor for a null value, such as
+NewLong(1) and NullString(). These are handy to build the expected rows of a test or the rows given to kusto.MockRows.
+
+Equal() compares two values, taking care of the details that make == unreliable: datetimes in different time zones,
+decimals with different text and dynamic values with different JSON formatting. Compare() orders two values of the
+same type.
 */
 package value
 
@@ -48,3 +63,32 @@ type Kusto interface {
 
 // Values is a list of Kusto values, usually an ordered row.
 type Values []Kusto
+
+// IsNull returns true if v is nil or a null value.
+func IsNull(v Kusto) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case Bool:
+		return !v.Valid
+	case Int:
+		return !v.Valid
+	case Long:
+		return !v.Valid
+	case Real:
+		return !v.Valid
+	case Decimal:
+		return !v.Valid
+	case String:
+		return !v.Valid
+	case Dynamic:
+		return !v.Valid
+	case DateTime:
+		return !v.Valid
+	case Timespan:
+		return !v.Valid
+	case GUID:
+		return !v.Valid
+	}
+	return false
+}
@@ -3,12 +3,17 @@ package value
 import (
 	"encoding/json"
 	"math"
+	"math/big"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+	_ "time/tzdata" // For the DST tests.
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBool(t *testing.T) {
@@ -93,6 +98,14 @@ func TestDateTime(t *testing.T) {
 				Valid: true,
 			},
 		},
+		{
+			desc: "value has an offset",
+			i:    "2019-08-27T06:14:55.302919+02:00",
+			want: DateTime{
+				Value: timeMustParse(time.RFC3339Nano, "2019-08-27T04:14:55.302919Z"),
+				Valid: true,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -114,6 +127,61 @@ func TestDateTime(t *testing.T) {
 	}
 }
 
+func TestDateTimeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc string
+		in   time.Time
+		want string
+	}{
+		{
+			desc: "Before spring forward",
+			in:   time.Date(2022, 3, 13, 1, 59, 59, 999999900, ny),
+			want: "2022-03-13T06:59:59.9999999Z",
+		},
+		{
+			desc: "After spring forward",
+			in:   time.Date(2022, 3, 13, 3, 0, 0, 0, ny),
+			want: "2022-03-13T07:00:00Z",
+		},
+		{
+			desc: "First 01:30 on fall back",
+			in:   time.Date(2022, 11, 6, 5, 30, 0, 0, time.UTC).In(ny),
+			want: "2022-11-06T05:30:00Z",
+		},
+		{
+			desc: "Second 01:30 on fall back",
+			in:   time.Date(2022, 11, 6, 6, 30, 0, 0, time.UTC).In(ny),
+			want: "2022-11-06T06:30:00Z",
+		},
+		{
+			desc: "Finer than a tick is truncated",
+			in:   time.Date(2022, 6, 1, 12, 0, 0, 123456789, time.FixedZone("X", 90*60)),
+			want: "2022-06-01T10:30:00.1234567Z",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			d := NewDateTime(test.in)
+			assert.Equal(t, test.want, d.Marshal())
+			assert.Equal(t, test.want, d.String())
+
+			got := DateTime{}
+			require.NoError(t, got.Unmarshal(d.Marshal()))
+			assert.Equal(t, time.UTC, got.Value.Location())
+			assert.True(t, test.in.Truncate(100*time.Nanosecond).Equal(got.Value), "got %s, want %s", got.Value, test.in)
+		})
+	}
+}
+
 func TestDynamic(t *testing.T) {
 	t.Parallel()
 
@@ -530,6 +598,135 @@ func removeLeadingZeros(s string) string {
 	return strings.Trim(s, "0:.")
 }
 
+func TestTimespanMarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 0, want: "00:00:00"},
+		{in: 10 * time.Second, want: "00:00:10"},
+		{in: 4*time.Minute + 10*time.Second, want: "00:04:10"},
+		{in: time.Hour, want: "01:00:00"},
+		{in: 1500 * time.Millisecond, want: "00:00:01.5"},
+		{in: time.Millisecond + 50*time.Nanosecond, want: "00:00:00.001"},
+		{in: time.Millisecond + 5*tick, want: "00:00:00.0010005"},
+		{in: 54*time.Second + 123456789, want: "00:00:54.1234567"},
+		{in: 2*24*time.Hour + 30*time.Second, want: "2.00:00:30"},
+		{in: -(90 * time.Second), want: "-00:01:30"},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.want, func(t *testing.T) {
+			t.Parallel()
+
+			got := Timespan{Value: test.in, Valid: true}.Marshal()
+			assert.Equal(t, test.want, got)
+
+			back := Timespan{}
+			require.NoError(t, back.Unmarshal(got))
+			assert.Equal(t, test.in.Truncate(tick), back.Value)
+		})
+	}
+}
+
+// timespanLiterals are representative timespan literals sent by Kusto, the seeds of the fuzz tests.
+var timespanLiterals = []string{
+	"00:00:00",
+	"00:00:00.0000001",
+	"00:00:01.5000000",
+	"00:30:00",
+	"1.02:03:04.0050000",
+	"-1.02:03:04.0050000",
+	"23:59:59.9999999",
+	"-00:00:00.0000001",
+	"364.23:59:59.9999999",
+	"106751.23:47:16.8547758",
+	"-106751.23:47:16.8547758",
+}
+
+func TestTimespanLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		in   string
+		err  bool
+	}{
+		{desc: "Largest Kusto timespan", in: "10675199.02:48:05.4775807", err: true},
+		{desc: "Just over time.Duration", in: "106751.23:47:16.8547759", err: true},
+		{desc: "Hours over a day with days", in: "1.24:00:00", err: true},
+		{desc: "Seconds over a minute", in: "00:00:60", err: true},
+		{desc: "Double sign", in: "--00:00:01", err: true},
+		{desc: "Sign inside", in: "00:-01:00", err: true},
+		{desc: "Largest time.Duration in ticks", in: "106751.23:47:16.8547758"},
+		{desc: "Many hours without days", in: "48:00:00"},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got := Timespan{}
+			err := got.Unmarshal(test.in)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	for _, d := range []time.Duration{math.MaxInt64, math.MinInt64} {
+		back := Timespan{}
+		require.NoError(t, back.Unmarshal(NewTimespan(d).Marshal()))
+		assert.InDelta(t, float64(d), float64(back.Value), float64(tick))
+	}
+}
+
+func FuzzTimespanLiteral(f *testing.F) {
+	for _, lit := range timespanLiterals {
+		f.Add(lit)
+	}
+
+	f.Fuzz(func(t *testing.T, lit string) {
+		first := Timespan{}
+		if err := first.Unmarshal(lit); err != nil {
+			return
+		}
+
+		// The literal is parsed again to the same value once marshaled, whatever its form was.
+		second := Timespan{}
+		require.NoError(t, second.Unmarshal(first.Marshal()), "Marshal() of %q", lit)
+		assert.Equal(t, first.Value.Truncate(tick), second.Value, "literal %q", lit)
+	})
+}
+
+func FuzzTimespanDuration(f *testing.F) {
+	for _, lit := range timespanLiterals {
+		ts := Timespan{}
+		require.NoError(f, ts.Unmarshal(lit))
+		f.Add(int64(ts.Value))
+	}
+	f.Add(int64(math.MaxInt64))
+	f.Add(int64(math.MinInt64))
+
+	f.Fuzz(func(t *testing.T, d int64) {
+		lit := NewTimespan(time.Duration(d)).Marshal()
+
+		back := Timespan{}
+		require.NoError(t, back.Unmarshal(lit), "literal %q", lit)
+		assert.Equal(t, time.Duration(d).Truncate(tick), back.Value.Truncate(tick), "literal %q", lit)
+		if d == math.MinInt64 {
+			return
+		}
+		assert.Equal(t, lit, back.Marshal())
+	})
+}
+
 func TestDecimal(t *testing.T) {
 	t.Parallel()
 
@@ -549,6 +746,8 @@ func TestDecimal(t *testing.T) {
 		{desc: "Conversion of '1.',", i: "1.", want: Decimal{Value: "1.", Valid: true}},
 		{desc: "Conversion of '0.1',", i: "0.1", want: Decimal{Value: "0.1", Valid: true}},
 		{desc: "Conversion of '3.07',", i: "3.07", want: Decimal{Value: "3.07", Valid: true}},
+		{desc: "Conversion of '-3.07',", i: "-3.07", want: Decimal{Value: "-3.07", Valid: true}},
+		{desc: "cannot be a word", i: "1.0; drop", err: true},
 	}
 
 	for _, test := range tests {
@@ -569,6 +768,68 @@ func TestDecimal(t *testing.T) {
 	}
 }
 
+func TestDecimalConvert(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		"1234567890123456789012345678901234",
+		"-1234567890123456789012345678901234",
+		"0.1234567890123456789012345678901234",
+		"-123456789012345678.9012345678901234",
+		"9999999999999999999999999999999999",
+		"0.0000000000000000000000000000000001",
+		"0",
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test, func(t *testing.T) {
+			t.Parallel()
+			d := NewDecimal(test)
+
+			var s string
+			require.NoError(t, d.Convert(reflect.ValueOf(&s).Elem()))
+			assert.Equal(t, test, s)
+
+			var f *big.Float
+			require.NoError(t, d.Convert(reflect.ValueOf(&f).Elem()))
+			assert.Equal(t, test, NewDecimalFromFloat(f).Value)
+
+			parsed, err := d.ParsedDecimal()
+			require.NoError(t, err)
+			assert.Equal(t, 0, parsed.Cmp(f))
+
+			want, err := strconv.ParseFloat(test, 64)
+			require.NoError(t, err)
+			var r float64
+			require.NoError(t, d.Convert(reflect.ValueOf(&r).Elem()))
+			assert.Equal(t, want, r)
+			var rp *float64
+			require.NoError(t, d.Convert(reflect.ValueOf(&rp).Elem()))
+			assert.Equal(t, want, *rp)
+		})
+	}
+
+	t.Run("null", func(t *testing.T) {
+		t.Parallel()
+		d := NullDecimal()
+
+		f := big.NewFloat(1)
+		require.NoError(t, d.Convert(reflect.ValueOf(&f).Elem()))
+		assert.Equal(t, big.NewFloat(1), f)
+
+		parsed, err := d.ParsedDecimal()
+		require.NoError(t, err)
+		assert.Nil(t, parsed)
+	})
+
+	t.Run("unsupported receiver", func(t *testing.T) {
+		t.Parallel()
+		var i int
+		assert.Error(t, NewDecimal("1").Convert(reflect.ValueOf(&i).Elem()))
+	})
+}
+
 func timeMustParse(layout string, p string) time.Time {
 	t, err := time.Parse(layout, p)
 	if err != nil {
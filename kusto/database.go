@@ -0,0 +1,56 @@
+package kusto
+
+// database.go implements DatabaseClient, a view of a Client whose calls are made in a set database.
+
+import (
+	"context"
+)
+
+// DatabaseClient is a view of a Client whose calls are made in a set database, so that they do not need the
+// database as an argument. It shares everything with its Client: the connections, the http.Client, the tokens and the
+// validation of the endpoint, so deriving one per database is cheap. It is safe for concurrent use.
+// A DatabaseClient is not closed itself: its Client is, once all the views are done with it.
+type DatabaseClient struct {
+	client *Client
+	db     string
+}
+
+// WithDatabase returns a view of the Client whose calls are made in db. Calls can still be made in another
+// database with the Client returned by DatabaseClient.Client().
+// Example:
+//
+//	logs := client.WithDatabase("Logs")
+//	iter, err := logs.Query(ctx, kusto.NewStmt("Traces | take 10"))
+func (c *Client) WithDatabase(db string) *DatabaseClient {
+	return &DatabaseClient{client: c, db: db}
+}
+
+// Database returns the database the calls are made in.
+func (d *DatabaseClient) Database() string {
+	return d.db
+}
+
+// Client returns the Client the DatabaseClient was derived from, which makes calls in any database.
+func (d *DatabaseClient) Client() *Client {
+	return d.client
+}
+
+// Query calls Client.Query() in the database of the DatabaseClient.
+func (d *DatabaseClient) Query(ctx context.Context, query Stmt, options ...QueryOption) (*RowIterator, error) {
+	return d.client.Query(ctx, d.db, query, options...)
+}
+
+// QueryToJson calls Client.QueryToJson() in the database of the DatabaseClient.
+func (d *DatabaseClient) QueryToJson(ctx context.Context, query Stmt, options ...QueryOption) (string, error) {
+	return d.client.QueryToJson(ctx, d.db, query, options...)
+}
+
+// QueryToJsonResult calls Client.QueryToJsonResult() in the database of the DatabaseClient.
+func (d *DatabaseClient) QueryToJsonResult(ctx context.Context, query Stmt, options ...QueryOption) (JsonResult, error) {
+	return d.client.QueryToJsonResult(ctx, d.db, query, options...)
+}
+
+// Mgmt calls Client.Mgmt() in the database of the DatabaseClient.
+func (d *DatabaseClient) Mgmt(ctx context.Context, query Stmt, options ...MgmtOption) (*RowIterator, error) {
+	return d.client.Mgmt(ctx, d.db, query, options...)
+}
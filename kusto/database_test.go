@@ -0,0 +1,48 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseClient(t *testing.T) {
+	t.Parallel()
+
+	transport := &captureTransport{}
+	client := previewClient(t, transport)
+	logs := client.WithDatabase("Logs")
+	metrics := client.WithDatabase("Metrics")
+	ctx := context.Background()
+
+	assert.Equal(t, "Logs", logs.Database())
+	assert.Same(t, client, logs.Client())
+	assert.Same(t, logs.Client(), metrics.Client())
+
+	iter, err := logs.Query(ctx, NewStmt("T"))
+	require.NoError(t, err)
+	iter.Stop()
+	_, err = metrics.QueryToJson(ctx, NewStmt("T"))
+	require.NoError(t, err)
+	result, err := logs.QueryToJsonResult(ctx, NewStmt("T"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.RequestID())
+	iter, err = metrics.Mgmt(ctx, NewStmt(".show tables"))
+	require.NoError(t, err)
+	iter.Stop()
+	// The database can be overridden for a call.
+	iter, err = logs.Client().Query(ctx, "Other", NewStmt("T"))
+	require.NoError(t, err)
+	iter.Stop()
+
+	var got []string
+	for _, req := range transport.sent() {
+		var msg queryMsg
+		require.NoError(t, json.Unmarshal([]byte(req.Body), &msg))
+		got = append(got, msg.DB)
+	}
+	assert.Equal(t, []string{"Logs", "Metrics", "Logs", "Metrics", "Other"}, got)
+}
@@ -0,0 +1,98 @@
+package kusto
+
+// dataset.go implements the access to the primary result tables of a query that returns more than one, such as a query
+// with several tabular expression statements or the fork operator.
+
+import (
+	"context"
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// primaryTable marks the start of a primary result table in the rows of a RowIterator.
+type primaryTable struct {
+	index   int
+	columns table.Columns
+}
+
+// startTable records that the rows that follow belong to t.
+func (r *RowIterator) startTable(t *primaryTable) {
+	r.table = t
+	r.stats.tables.Add(1)
+	if r.onTable != nil {
+		r.onTable(t)
+	}
+}
+
+// TableIndex returns the index of the primary result table of the last row returned, starting at 0, or -1 before the
+// first row. A query returns more than one primary result table when it has several tabular expression statements or
+// uses the fork operator. The rows of each table have the columns of their table in Row.ColumnTypes.
+func (r *RowIterator) TableIndex() int {
+	if r.table == nil {
+		if r.mock != nil && r.started {
+			return 0
+		}
+		return -1
+	}
+	return r.table.index
+}
+
+// Dataset holds the primary result tables of a query, see Client.QueryDataset().
+type Dataset struct {
+	// Tables are the primary result tables, in the order of the query.
+	Tables []*TableResult
+}
+
+// TableResult is a primary result table of a query.
+type TableResult struct {
+	// Index is the position of the table among the primary result tables, starting at 0.
+	Index int
+	// Columns are the columns of the table.
+	Columns table.Columns
+	// Rows are the rows of the table, which may be empty.
+	Rows []*table.Row
+	// Errors are the errors the service sent inline with the rows of the table. The errors sent at the end of the
+	// results, such as a partial query failure, are in the last table.
+	Errors []*errors.Error
+}
+
+// QueryDataset is like Query(), but reads every row of the result into memory and returns them grouped by primary
+// result table, including the tables that have no rows. Only use this when the result fits comfortably in memory.
+func (c *Client) QueryDataset(ctx context.Context, db string, query Stmt, options ...QueryOption) (*Dataset, error) {
+	iter, err := c.Query(ctx, db, query, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Stop()
+
+	ds := &Dataset{}
+	iter.onTable = func(t *primaryTable) {
+		ds.Tables = append(ds.Tables, &TableResult{Index: t.index, Columns: t.columns})
+	}
+
+	for {
+		row, inlineErr, err := iter.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				return ds, nil
+			}
+			return nil, err
+		}
+
+		// A RowIterator without table boundaries, such as a mocked one, has a single table.
+		if len(ds.Tables) == 0 {
+			ds.Tables = append(ds.Tables, &TableResult{Columns: iter.columns})
+		}
+		last := ds.Tables[len(ds.Tables)-1]
+		if inlineErr != nil {
+			last.Errors = append(last.Errors, inlineErr)
+			continue
+		}
+		if row.Replace {
+			last.Rows = last.Rows[:0]
+		}
+		last.Rows = append(last.Rows, row)
+	}
+}
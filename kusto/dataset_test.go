@@ -0,0 +1,127 @@
+package kusto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const datasetNonProgressive = `[
+{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},
+{"FrameType":"DataTable","TableId":0,"TableKind":"QueryProperties","TableName":"@ExtendedProperties","Columns":[{"ColumnName":"TableId","ColumnType":"int"},{"ColumnName":"Key","ColumnType":"string"},{"ColumnName":"Value","ColumnType":"dynamic"}],"Rows":[]},
+{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Name","ColumnType":"string"}],"Rows":[["a"],["b"]]},
+{"FrameType":"DataTable","TableId":2,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Count","ColumnType":"long"}],"Rows":[]},
+{"FrameType":"DataTable","TableId":3,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Count","ColumnType":"long"},{"ColumnName":"Name","ColumnType":"string"}],"Rows":[[1,"c"]]},
+{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}
+]`
+
+const datasetProgressive = `[
+{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},
+{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Name","ColumnType":"string"}]},
+{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[["a"]]},
+{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[["b"]]},
+{"FrameType":"TableCompletion","TableId":1,"RowCount":2},
+{"FrameType":"TableHeader","TableId":2,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Count","ColumnType":"long"}]},
+{"FrameType":"TableCompletion","TableId":2,"RowCount":0},
+{"FrameType":"TableHeader","TableId":3,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Count","ColumnType":"long"},{"ColumnName":"Name","ColumnType":"string"}]},
+{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":3,"Rows":[[1,"c"]]},
+{"FrameType":"TableCompletion","TableId":3,"RowCount":1},
+{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}
+]`
+
+func datasetClient(t *testing.T, body string) *Client {
+	return newTestClient(t, "https://dataset.kusto.windows.net", fixtureTransport{body: []byte(body)})
+}
+
+func TestQueryDataset(t *testing.T) {
+	t.Parallel()
+
+	nameCols := table.Columns{{Name: "Name", Type: "string"}}
+	countCols := table.Columns{{Name: "Count", Type: "long"}}
+	bothCols := table.Columns{{Name: "Count", Type: "long"}, {Name: "Name", Type: "string"}}
+
+	tests := []struct {
+		desc string
+		body string
+	}{
+		{desc: "Non-progressive", body: datasetNonProgressive},
+		{desc: "Progressive", body: datasetProgressive},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ds, err := datasetClient(t, test.body).QueryDataset(context.Background(), "db", NewStmt("T; T | count"))
+			require.NoError(t, err)
+			require.Len(t, ds.Tables, 3)
+
+			assert.Equal(t, 0, ds.Tables[0].Index)
+			assert.Equal(t, nameCols, ds.Tables[0].Columns)
+			require.Len(t, ds.Tables[0].Rows, 2)
+			assert.Equal(t, nameCols, ds.Tables[0].Rows[1].ColumnTypes)
+			assert.Equal(t, value.Values{value.String{Value: "b", Valid: true}}, ds.Tables[0].Rows[1].Values)
+
+			assert.Equal(t, 1, ds.Tables[1].Index)
+			assert.Equal(t, countCols, ds.Tables[1].Columns)
+			assert.Empty(t, ds.Tables[1].Rows)
+
+			assert.Equal(t, 2, ds.Tables[2].Index)
+			require.Len(t, ds.Tables[2].Rows, 1)
+			assert.Equal(t, bothCols, ds.Tables[2].Rows[0].ColumnTypes)
+			assert.Equal(t, value.Values{value.Long{Value: 1, Valid: true}, value.String{Value: "c", Valid: true}}, ds.Tables[2].Rows[0].Values)
+		})
+	}
+}
+
+func TestTableIndex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		buffered bool
+	}{
+		{desc: "Streamed"},
+		{desc: "Materialized", buffered: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := datasetClient(t, datasetNonProgressive)
+			query := client.Query
+			if test.buffered {
+				query = client.QueryBuffered
+			}
+			iter, err := query(context.Background(), "db", NewStmt("T; T | count"))
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			passes := 1
+			if test.buffered {
+				passes = 2
+			}
+			for pass := 0; pass < passes; pass++ {
+				assert.Equal(t, -1, iter.TableIndex())
+
+				var got []int
+				require.NoError(t, iter.Do(func(row *table.Row) error {
+					got = append(got, iter.TableIndex())
+					return nil
+				}))
+				assert.Equal(t, []int{0, 0, 2}, got)
+
+				if test.buffered {
+					require.NoError(t, iter.Rewind())
+				}
+			}
+		})
+	}
+}
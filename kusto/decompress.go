@@ -0,0 +1,44 @@
+package kusto
+
+// decompress.go implements WithDecompressor(), which lets the service compress responses with encodings other than gzip.
+
+import (
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/internal/response"
+)
+
+// Decompressor returns a reader of the decompressed content of r. If the returned reader implements io.Closer, it is
+// closed along with the response body.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// WithDecompressor adds support for responses compressed with encoding, such as "zstd" or "br". The encoding is
+// advertised in the Accept-Encoding header of every request, along with gzip, and responses with a matching
+// Content-Encoding are decompressed with d. Responses that the service sent uncompressed, or compressed with gzip or
+// deflate, are still read as usual.
+//
+// The SDK does not depend on any zstd or brotli implementation, so the caller provides one. For example, with
+// github.com/klauspost/compress/zstd:
+//
+//	client, err := kusto.New(kcsb, kusto.WithDecompressor("zstd", func(r io.Reader) (io.ReadCloser, error) {
+//		dec, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return dec.IOReadCloser(), nil
+//	}))
+//
+// Registering "gzip" or "deflate" replaces the built in support of that encoding.
+func WithDecompressor(encoding string, d Decompressor) Option {
+	return func(c *Client) {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding == "" || d == nil {
+			return
+		}
+		if c.decompressors == nil {
+			c.decompressors = response.Decompressors{}
+		}
+		c.decompressors[encoding] = response.Decompressor(d)
+	}
+}
@@ -0,0 +1,316 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	goErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodedTransport is a fake http.RoundTripper that answers every query with body and the given Content-Encoding,
+// and records the Accept-Encoding header of the requests.
+type encodedTransport struct {
+	body     []byte
+	encoding string
+
+	mu     sync.Mutex
+	accept []string
+}
+
+func (e *encodedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	e.mu.Lock()
+	e.accept = append(e.accept, req.Header.Get("Accept-Encoding"))
+	e.mu.Unlock()
+
+	header := http.Header{}
+	if e.encoding != "" {
+		header.Set("Content-Encoding", e.encoding)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: header, Body: io.NopCloser(bytes.NewReader(e.body))}, nil
+}
+
+// storedZstd decodes zstd frames made of raw and RLE blocks, which is enough to read the fixtures without depending
+// on a zstd implementation.
+func storedZstd(r io.Reader) (io.ReadCloser, error) {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(in) < 5 || binary.LittleEndian.Uint32(in) != 0xFD2FB528 {
+		return nil, fmt.Errorf("not a zstd frame")
+	}
+	fhd := in[4]
+	pos := 5
+	single := fhd&0x20 != 0
+	if !single {
+		pos++ // Window descriptor.
+	}
+	pos += []int{0, 1, 2, 4}[fhd&0x3] // Dictionary ID.
+	fcs := []int{0, 2, 4, 8}[fhd>>6]
+	if fcs == 0 && single {
+		fcs = 1
+	}
+	pos += fcs
+
+	out := &bytes.Buffer{}
+	for {
+		if pos+3 > len(in) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		hdr := int(in[pos]) | int(in[pos+1])<<8 | int(in[pos+2])<<16
+		pos += 3
+		last, kind, size := hdr&1 == 1, (hdr>>1)&3, hdr>>3
+		switch kind {
+		case 0:
+			if pos+size > len(in) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out.Write(in[pos : pos+size])
+			pos += size
+		case 1:
+			if pos >= len(in) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			out.Write(bytes.Repeat(in[pos:pos+1], size))
+			pos++
+		default:
+			return nil, fmt.Errorf("zstd block type %d is not supported", kind)
+		}
+		if last {
+			return io.NopCloser(out), nil
+		}
+	}
+}
+
+// storedBrotli decodes brotli streams made of uncompressed meta-blocks, which is enough to read the fixtures without
+// depending on a brotli implementation.
+func storedBrotli(r io.Reader) (io.ReadCloser, error) {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	bit := 0
+	read := func(n int) (int, error) {
+		v := 0
+		for i := 0; i < n; i++ {
+			if bit/8 >= len(in) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			v |= int(in[bit/8]>>(bit%8)&1) << i
+			bit++
+		}
+		return v, nil
+	}
+
+	// WBITS is not needed to copy uncompressed meta-blocks.
+	if v, err := read(1); err != nil {
+		return nil, err
+	} else if v == 1 {
+		if v, err = read(3); err != nil {
+			return nil, err
+		} else if v == 0 {
+			if _, err := read(3); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := &bytes.Buffer{}
+	for {
+		last, err := read(1)
+		if err != nil {
+			return nil, err
+		}
+		if last == 1 {
+			empty, err := read(1)
+			if err != nil {
+				return nil, err
+			}
+			if empty == 1 {
+				return io.NopCloser(out), nil
+			}
+			return nil, fmt.Errorf("compressed brotli meta-blocks are not supported")
+		}
+		nibbles, err := read(2)
+		if err != nil {
+			return nil, err
+		}
+		if nibbles == 3 {
+			return nil, fmt.Errorf("brotli metadata blocks are not supported")
+		}
+		mlen, err := read(4 * (nibbles + 4))
+		if err != nil {
+			return nil, err
+		}
+		if uncompressed, err := read(1); err != nil {
+			return nil, err
+		} else if uncompressed != 1 {
+			return nil, fmt.Errorf("compressed brotli meta-blocks are not supported")
+		}
+
+		start := (bit + 7) / 8
+		end := start + mlen + 1
+		if end > len(in) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		out.Write(in[start:end])
+		bit = end * 8
+	}
+}
+
+func TestDecompressor(t *testing.T) {
+	t.Parallel()
+
+	plain, err := os.ReadFile(filepath.Join("testdata", "compressed.json"))
+	require.NoError(t, err)
+	fixture := func(ext string) []byte {
+		b, err := os.ReadFile(filepath.Join("testdata", "compressed.json"+ext))
+		require.NoError(t, err)
+		return b
+	}
+	corrupt := func(b []byte) []byte {
+		b = append([]byte(nil), b...)
+		b[0] ^= 0xff
+		return b
+	}
+	zstd, br := fixture(".zst"), fixture(".br")
+
+	tests := []struct {
+		desc       string
+		options    []Option
+		encoding   string
+		body       []byte
+		wantAccept string
+		err        bool
+	}{
+		{
+			desc:       "Default client, identity",
+			body:       plain,
+			wantAccept: "gzip",
+		},
+		{
+			desc:       "Default client, gzip",
+			encoding:   "gzip",
+			body:       fixture(".gz"),
+			wantAccept: "gzip",
+		},
+		{
+			desc:       "Default client, deflate",
+			encoding:   "deflate",
+			body:       fixture(".deflate"),
+			wantAccept: "gzip",
+		},
+		{
+			desc:       "Default client, zstd is unrecognized",
+			encoding:   "zstd",
+			body:       zstd,
+			wantAccept: "gzip",
+			err:        true,
+		},
+		{
+			desc:       "zstd",
+			options:    []Option{WithDecompressor("zstd", storedZstd), WithDecompressor("br", storedBrotli)},
+			encoding:   "zstd",
+			body:       zstd,
+			wantAccept: "br, zstd, gzip",
+		},
+		{
+			desc:       "br",
+			options:    []Option{WithDecompressor("zstd", storedZstd), WithDecompressor(" BR ", storedBrotli)},
+			encoding:   "br",
+			body:       br,
+			wantAccept: "br, zstd, gzip",
+		},
+		{
+			desc:       "Server ignored the negotiation",
+			options:    []Option{WithDecompressor("zstd", storedZstd)},
+			body:       plain,
+			wantAccept: "zstd, gzip",
+		},
+		{
+			desc:       "Server fell back to gzip",
+			options:    []Option{WithDecompressor("zstd", storedZstd)},
+			encoding:   "gzip",
+			body:       fixture(".gz"),
+			wantAccept: "zstd, gzip",
+		},
+		{
+			desc:       "Corrupted zstd",
+			options:    []Option{WithDecompressor("zstd", storedZstd)},
+			encoding:   "zstd",
+			body:       corrupt(zstd),
+			wantAccept: "zstd, gzip",
+			err:        true,
+		},
+		{
+			desc:       "Truncated br",
+			options:    []Option{WithDecompressor("br", storedBrotli)},
+			encoding:   "br",
+			body:       br[:len(br)/2],
+			wantAccept: "br, gzip",
+			err:        true,
+		},
+		{
+			desc:       "Corrupted gzip",
+			encoding:   "gzip",
+			body:       corrupt(fixture(".gz")),
+			wantAccept: "gzip",
+			err:        true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &encodedTransport{body: test.body, encoding: test.encoding}
+			client := &Client{}
+			for _, o := range test.options {
+				o(client)
+			}
+			conn, err := newConn("https://compressed.kusto.windows.net", Authorization{}, &http.Client{Transport: transport}, NewClientDetails("", ""))
+			require.NoError(t, err)
+			conn.decompressors = client.decompressors
+			client.conn, client.endpoint, client.http = conn, "https://compressed.kusto.windows.net", conn.client
+
+			got, err := client.QueryToJson(context.Background(), "db", NewStmt("T"))
+			require.Len(t, transport.accept, 1)
+			assert.Equal(t, test.wantAccept, transport.accept[0])
+			if test.err {
+				var kErr *errors.Error
+				require.True(t, goErrors.As(err, &kErr), "got %T: %v", err, err)
+				assert.Equal(t, errors.KInternal, kErr.Kind)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, string(plain), got)
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+			require.NoError(t, err)
+			defer iter.Stop()
+			var regions []string
+			require.NoError(t, iter.Do(func(row *table.Row) error {
+				regions = append(regions, row.Values[0].String())
+				return nil
+			}))
+			assert.Equal(t, []string{"west", "east", "north"}, regions)
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package kusto
+
+// dedup.go implements single-flight deduplication of identical queries, where concurrent callers issuing the same
+// query share a single request to the service and each receive a copy of the frames.
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+)
+
+// defaultDedupMaxRows is the default number of rows buffered for a shared query, see WithQueryDeduplicationMaxRows().
+const defaultDedupMaxRows = 100000
+
+type dedupSettings struct {
+	enabled bool
+	window  time.Duration
+	maxRows int
+}
+
+// WithQueryDeduplication makes concurrent calls to Query() with the same database, statement, parameters and options
+// share a single request to the service. Each caller receives its own RowIterator over a copy of the results.
+// A query that completed successfully less than window ago is served from its buffered results instead of being sent
+// again, a window of 0 only shares queries that are in flight.
+// The client request ID, application and user of the shared request are the ones of the first caller.
+// Use NoDedup() to opt a single query out.
+func WithQueryDeduplication(window time.Duration) Option {
+	return func(c *Client) {
+		c.dedupSettings.enabled = true
+		c.dedupSettings.window = window
+	}
+}
+
+// WithQueryDeduplicationMaxRows bounds the number of rows buffered for each shared query when using
+// WithQueryDeduplication(). Once a query has buffered more rows than this, new callers no longer join it and it is
+// not cached, while the callers already reading it are served at the pace of the slowest one. Defaults to 100,000.
+func WithQueryDeduplicationMaxRows(rows int) Option {
+	return func(c *Client) {
+		c.dedupSettings.maxRows = rows
+	}
+}
+
+// deduplicator holds the shared queries of a Client. mu guards all the state of the shared queries.
+type deduplicator struct {
+	window  time.Duration
+	maxRows int
+
+	mu      sync.Mutex
+	flights map[string]*sharedQuery
+}
+
+func newDeduplicator(settings dedupSettings) *deduplicator {
+	maxRows := settings.maxRows
+	if maxRows <= 0 {
+		maxRows = defaultDedupMaxRows
+	}
+	return &deduplicator{window: settings.window, maxRows: maxRows, flights: map[string]*sharedQuery{}}
+}
+
+// sharedQuery is a single request whose frames are buffered and replayed to each subscriber.
+type sharedQuery struct {
+	d    *deduplicator
+	key  string
+	cond *sync.Cond
+
+	// started is closed when the request has returned, at which point err, reqHeader, respHeader and bytes are set.
+	started    chan struct{}
+	err        error
+	reqHeader  http.Header
+	respHeader http.Header
+	bytes      *atomic.Int64
+	cancel     context.CancelFunc
+
+	// frames are the buffered frames, frames[0] being frame number base of the stream.
+	frames []frames.Frame
+	base   int
+	rows   int
+	done   bool
+	failed bool
+	// joinable is false once the query can no longer be shared with new callers.
+	joinable bool
+	subs     map[*dedupSubscriber]struct{}
+}
+
+type dedupSubscriber struct {
+	pos int
+}
+
+// dedupKey returns the key identifying identical queries. ok is false if the query cannot be deduplicated.
+func dedupKey(db string, query Stmt, opts *queryOptions) (key string, ok bool) {
+	b, ok := queryIdentity("", db, query.String(), opts)
+	return string(b), ok
+}
+
+// queryIdentity returns the JSON of what makes queries identical for dedupKey() and queryCacheKey(): their endpoint,
+// database, statement, parameters and options. ok is false if it cannot be marshaled.
+func queryIdentity(endpoint, db, csl string, opts *queryOptions) (b []byte, ok bool) {
+	options := opts.requestProperties.options()
+	// The server timeout is derived from the deadline of each caller.
+	delete(options, ServerTimeoutValue)
+
+	b, err := json.Marshal(
+		struct {
+			Endpoint           string `json:",omitempty"`
+			DB                 string
+			CSL                string
+			Parameters         map[string]string
+			Options            map[string]interface{}
+			PrimaryResultsOnly bool
+		}{
+			Endpoint:           endpoint,
+			DB:                 db,
+			CSL:                csl,
+			Parameters:         opts.requestProperties.Parameters,
+			Options:            options,
+			PrimaryResultsOnly: opts.primaryResultsOnly,
+		},
+	)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// dedupQuery runs the query through the deduplicator of the Client, if there is one.
+func (c *Client) dedupQuery(ctx context.Context, conn queryer, db string, query Stmt, opts *queryOptions) (execResp, error) {
+	if c.dedup == nil || opts.noDedup {
+		return conn.query(ctx, db, query, opts)
+	}
+	return c.dedup.query(ctx, conn, db, query, opts)
+}
+
+// query joins an identical shared query or starts a new one, and returns a stream of the frames for this caller.
+func (d *deduplicator) query(ctx context.Context, conn queryer, db string, query Stmt, opts *queryOptions) (execResp, error) {
+	key, ok := dedupKey(db, query, opts)
+	if !ok {
+		return conn.query(ctx, db, query, opts)
+	}
+
+	sub := &dedupSubscriber{}
+
+	d.mu.Lock()
+	sq, ok := d.flights[key]
+	if ok && sq.joinable {
+		sq.subs[sub] = struct{}{}
+		d.mu.Unlock()
+	} else {
+		// The request is not bound to the context of the caller that started it, as other callers may join it. It is
+		// cancelled once every caller has left, see unsubscribe().
+		sharedCtx, cancel := context.WithCancel(context.Background())
+		sq = &sharedQuery{
+			d:        d,
+			key:      key,
+			started:  make(chan struct{}),
+			cancel:   cancel,
+			joinable: true,
+			subs:     map[*dedupSubscriber]struct{}{sub: {}},
+		}
+		sq.cond = sync.NewCond(&d.mu)
+		d.flights[key] = sq
+		d.mu.Unlock()
+
+		go sq.start(sharedCtx, conn, db, query, opts)
+	}
+
+	// Every caller, including the one that started the request, waits for it with its own context.
+	select {
+	case <-ctx.Done():
+		sq.unsubscribe(sub)
+		return execResp{}, ctx.Err()
+	case <-sq.started:
+	}
+	if sq.err != nil {
+		return execResp{}, sq.err
+	}
+
+	out := make(chan frames.Frame, 1)
+	go sq.serve(ctx, sub, out)
+
+	return execResp{reqHeader: sq.reqHeader.Clone(), respHeader: sq.respHeader.Clone(), frameCh: out, bytes: sq.bytes}, nil
+}
+
+// start sends the request for the shared query on ctx, which is detached from the contexts of the callers.
+func (sq *sharedQuery) start(ctx context.Context, conn queryer, db string, query Stmt, opts *queryOptions) {
+	resp, err := conn.query(ctx, db, query, opts)
+
+	sq.d.mu.Lock()
+	if err != nil {
+		sq.err = err
+		sq.done = true
+		sq.remove()
+		sq.cancel()
+	} else {
+		sq.reqHeader = resp.reqHeader
+		sq.respHeader = resp.respHeader
+		sq.bytes = resp.bytes
+		go sq.pump(resp.frameCh)
+	}
+	sq.d.mu.Unlock()
+
+	close(sq.started)
+}
+
+// pump buffers the frames of the response for the subscribers.
+func (sq *sharedQuery) pump(in <-chan frames.Frame) {
+	d := sq.d
+	defer sq.cancel()
+
+	for fr := range in {
+		d.mu.Lock()
+		if isFailure(fr) {
+			sq.failed = true
+		}
+		// Once the query is not joinable, wait for the subscribers to consume the buffer before growing it further.
+		for !sq.joinable && sq.rows > d.maxRows && len(sq.subs) > 0 {
+			sq.cond.Wait()
+		}
+		if len(sq.subs) == 0 && !sq.joinable {
+			// Everyone left, drain the response without buffering it.
+			d.mu.Unlock()
+			continue
+		}
+
+		sq.frames = append(sq.frames, fr)
+		sq.rows += frameRows(fr)
+		if sq.joinable && sq.rows > d.maxRows {
+			sq.joinable = false
+			sq.remove()
+			sq.trim()
+		}
+		sq.cond.Broadcast()
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sq.done = true
+	sq.cond.Broadcast()
+
+	if !sq.joinable {
+		return
+	}
+	if sq.failed || d.window <= 0 {
+		sq.remove()
+		return
+	}
+	time.AfterFunc(d.window, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		sq.remove()
+	})
+}
+
+// serve sends the buffered frames to out as they become available, until the stream ends or ctx is done.
+func (sq *sharedQuery) serve(ctx context.Context, sub *dedupSubscriber, out chan frames.Frame) {
+	d := sq.d
+	defer close(out)
+	defer sq.unsubscribe(sub)
+
+	// sync.Cond cannot wait on a context, so wake the waiters up when ours is done.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			sq.cond.Broadcast()
+			d.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	for {
+		d.mu.Lock()
+		for sub.pos >= sq.base+len(sq.frames) && !sq.done && ctx.Err() == nil {
+			sq.cond.Wait()
+		}
+		if ctx.Err() != nil || sub.pos >= sq.base+len(sq.frames) {
+			d.mu.Unlock()
+			return
+		}
+		fr := sq.frames[sub.pos-sq.base]
+		d.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case out <- copyFrame(fr):
+		}
+
+		d.mu.Lock()
+		sub.pos++
+		if !sq.joinable {
+			sq.trim()
+		}
+		d.mu.Unlock()
+	}
+}
+
+// unsubscribe removes sub from the query, cancelling the request if it was the last subscriber of an unfinished query.
+func (sq *sharedQuery) unsubscribe(sub *dedupSubscriber) {
+	d := sq.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(sq.subs, sub)
+	if len(sq.subs) > 0 || sq.done {
+		if !sq.joinable {
+			sq.trim()
+		}
+		return
+	}
+
+	// Nobody is reading an unfinished query, stop it rather than keep it running for callers that may never come.
+	sq.joinable = false
+	sq.remove()
+	sq.frames = nil
+	sq.rows = 0
+	sq.cancel()
+	sq.cond.Broadcast()
+}
+
+// remove removes the query from the flights of the deduplicator. d.mu must be held.
+func (sq *sharedQuery) remove() {
+	if sq.d.flights[sq.key] == sq {
+		delete(sq.d.flights, sq.key)
+	}
+}
+
+// trim drops the frames that every subscriber has already received. d.mu must be held.
+func (sq *sharedQuery) trim() {
+	min := sq.base + len(sq.frames)
+	for sub := range sq.subs {
+		if sub.pos < min {
+			min = sub.pos
+		}
+	}
+	n := min - sq.base
+	if n <= 0 {
+		return
+	}
+	for i := 0; i < n; i++ {
+		sq.rows -= frameRows(sq.frames[i])
+		sq.frames[i] = nil
+	}
+	sq.frames = sq.frames[n:]
+	sq.base = min
+	sq.cond.Broadcast()
+}
+
+// isFailure reports if a frame means the query failed, in which case its results must not be cached.
+func isFailure(fr frames.Frame) bool {
+	switch v := fr.(type) {
+	case frames.Error:
+		return true
+	case v2.DataSetCompletion:
+		return v.HasErrors || v.Cancelled
+	}
+	return false
+}
+
+// frameRows returns the number of rows held by a frame.
+func frameRows(fr frames.Frame) int {
+	switch v := fr.(type) {
+	case v2.DataTable:
+		return len(v.KustoRows)
+	case v2.TableFragment:
+		return len(v.KustoRows)
+	}
+	return 0
+}
+
+// copyFrame copies the rows of a frame, so that subscribers cannot modify each other's rows.
+func copyFrame(fr frames.Frame) frames.Frame {
+	switch v := fr.(type) {
+	case v2.DataTable:
+		v.KustoRows = copyRows(v.KustoRows)
+		return v
+	case v2.TableFragment:
+		v.KustoRows = copyRows(v.KustoRows)
+		return v
+	}
+	return fr
+}
+
+func copyRows(rows []value.Values) []value.Values {
+	if rows == nil {
+		return nil
+	}
+	c := make([]value.Values, len(rows))
+	for i, row := range rows {
+		c[i] = append(value.Values(nil), row...)
+	}
+	return c
+}
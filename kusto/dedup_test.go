@@ -0,0 +1,272 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dedupTestStream = `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+	`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+	`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[[1],[2],[3]]},` +
+	`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+
+// dedupTransport is a fake http.RoundTripper that counts the queries it receives and holds them until release is closed.
+type dedupTransport struct {
+	requests atomic.Int64
+	release  chan struct{}
+	fail     bool
+}
+
+func (d *dedupTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	d.requests.Add(1)
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-d.release:
+	}
+
+	if d.fail {
+		return nil, fmt.Errorf("query failed")
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(dedupTestStream)),
+	}, nil
+}
+
+func newDedupTestClient(t *testing.T, transport *dedupTransport, settings dedupSettings) *Client {
+	client := newTestClient(t, "https://dedup.kusto.windows.net", transport)
+	client.dedup = newDeduplicator(settings)
+	return client
+}
+
+// subscribers returns the number of callers sharing the only query in flight.
+func (d *deduplicator) subscribers() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, sq := range d.flights {
+		return len(sq.subs)
+	}
+	return 0
+}
+
+// settled reports if every query of the deduplicator has finished reading its response.
+func (d *deduplicator) settled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, sq := range d.flights {
+		if !sq.done {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDedupConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const callers = 50
+
+	tests := []struct {
+		desc         string
+		options      []QueryOption
+		fail         bool
+		wantRequests int64
+	}{
+		{
+			desc:         "Shared",
+			wantRequests: 1,
+		},
+		{
+			desc:         "Shared failure",
+			fail:         true,
+			wantRequests: 1,
+		},
+		{
+			desc:         "NoDedup",
+			options:      []QueryOption{NoDedup()},
+			wantRequests: callers,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &dedupTransport{release: make(chan struct{}), fail: test.fail}
+			client := newDedupTestClient(t, transport, dedupSettings{enabled: true})
+
+			var wg sync.WaitGroup
+			errs := make([]error, callers)
+			rows := make([][]int64, callers)
+			for i := 0; i < callers; i++ {
+				i := i
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					iter, err := client.Query(context.Background(), "db", NewStmt("T"), test.options...)
+					if err != nil {
+						errs[i] = err
+						return
+					}
+					defer iter.Stop()
+
+					errs[i] = iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+						if e != nil {
+							return e
+						}
+						rows[i] = append(rows[i], r.Values[0].(value.Long).Value)
+						return nil
+					})
+				}()
+			}
+
+			if test.wantRequests == 1 {
+				require.Eventually(t, func() bool { return client.dedup.subscribers() == callers }, 5*time.Second, time.Millisecond)
+			} else {
+				require.Eventually(t, func() bool { return transport.requests.Load() == callers }, 5*time.Second, time.Millisecond)
+			}
+			close(transport.release)
+			wg.Wait()
+
+			assert.Equal(t, test.wantRequests, transport.requests.Load())
+			for i := 0; i < callers; i++ {
+				if test.fail {
+					assert.Error(t, errs[i])
+					continue
+				}
+				require.NoError(t, errs[i])
+				assert.Equal(t, []int64{1, 2, 3}, rows[i])
+			}
+		})
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc         string
+		settings     dedupSettings
+		wantRequests int64
+	}{
+		{
+			desc:         "Cached within window",
+			settings:     dedupSettings{enabled: true, window: time.Minute},
+			wantRequests: 1,
+		},
+		{
+			desc:         "No window",
+			settings:     dedupSettings{enabled: true},
+			wantRequests: 2,
+		},
+		{
+			desc:         "Over the row bound",
+			settings:     dedupSettings{enabled: true, window: time.Minute, maxRows: 2},
+			wantRequests: 2,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			release := make(chan struct{})
+			close(release)
+			transport := &dedupTransport{release: release}
+			client := newDedupTestClient(t, transport, test.settings)
+
+			for i := 0; i < 2; i++ {
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+				require.NoError(t, err)
+
+				var got []int64
+				err = iter.Do(func(r *table.Row) error {
+					got = append(got, r.Values[0].(value.Long).Value)
+					return nil
+				})
+				require.NoError(t, err)
+				assert.Equal(t, []int64{1, 2, 3}, got)
+				iter.Stop()
+				require.Eventually(t, client.dedup.settled, 5*time.Second, time.Millisecond)
+			}
+
+			assert.Equal(t, test.wantRequests, transport.requests.Load())
+		})
+	}
+}
+
+func TestDedupCallerContext(t *testing.T) {
+	t.Parallel()
+
+	transport := &dedupTransport{release: make(chan struct{})}
+	client := newDedupTestClient(t, transport, dedupSettings{enabled: true})
+
+	// The caller that starts the request and the one that joins it each wait with their own context.
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.Query(firstCtx, "db", NewStmt("T"))
+		firstErr <- err
+	}()
+	require.Eventually(t, func() bool { return transport.requests.Load() == 1 }, 5*time.Second, time.Millisecond)
+
+	type result struct {
+		rows []int64
+		err  error
+	}
+	second := make(chan result, 1)
+	go func() {
+		iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+		if err != nil {
+			second <- result{err: err}
+			return
+		}
+		defer iter.Stop()
+		var rows []int64
+		err = iter.Do(func(r *table.Row) error {
+			rows = append(rows, r.Values[0].(value.Long).Value)
+			return nil
+		})
+		second <- result{rows: rows, err: err}
+	}()
+	require.Eventually(t, func() bool { return client.dedup.subscribers() == 2 }, 5*time.Second, time.Millisecond)
+
+	// The first caller returns as soon as its context is cancelled, while the request is still in flight.
+	cancelFirst()
+	select {
+	case err := <-firstErr:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the first caller did not return once its context was cancelled")
+	}
+
+	// The request goes on for the caller that joined it.
+	close(transport.release)
+	got := <-second
+	require.NoError(t, got.err)
+	assert.Equal(t, []int64{1, 2, 3}, got.rows)
+	assert.Equal(t, int64(1), transport.requests.Load())
+}
@@ -0,0 +1,42 @@
+package kusto
+
+// defaultopts.go implements WithDefaultQueryOptions(), which applies QueryOptions to every query of a Client, and
+// RequestReadonlyLocked(), which keeps a query read-only whatever the options that follow.
+
+// WithDefaultQueryOptions sets options that are applied to every Query(), QueryToJson() and RequestPreview() call of
+// the Client, before the options passed to the call, which can add to them or override them. This allows a platform
+// to enforce options such as RequestReadonly() or RequestBlockRowLevelSecurity() when it creates the Client; use
+// RequestReadonlyLocked() for a read-only setting that the options of a call cannot override.
+// The options of a context, see ContextWithQueryOptions(), are applied after the default options, so they override
+// them too. Calling WithDefaultQueryOptions() more than once adds to the options.
+func WithDefaultQueryOptions(options ...QueryOption) Option {
+	return func(c *Client) {
+		c.defaultQueryOptions = append(c.defaultQueryOptions[:len(c.defaultQueryOptions):len(c.defaultQueryOptions)], options...)
+	}
+}
+
+// RequestReadonlyLocked is RequestReadonly(), except that the request stays read-only even if an option that follows
+// it, such as CustomQueryOption(RequestReadonlyValue, false), or a StatementInterceptor unsets request_readonly. It is
+// meant to be passed to WithDefaultQueryOptions().
+func RequestReadonlyLocked() QueryOption {
+	return func(q *queryOptions) error {
+		q.requestProperties.readOnlyLocked = true
+		q.requestProperties.setOption(RequestReadonlyValue, true)
+		return nil
+	}
+}
+
+// queryDefaults returns base, the defaults of the call, followed by the default options of the client, if any.
+func (c *Client) queryDefaults(base []QueryOption) []QueryOption {
+	if len(c.defaultQueryOptions) == 0 {
+		return base
+	}
+	return append(base[:len(base):len(base)], c.defaultQueryOptions...)
+}
+
+// lockReadOnly sets request_readonly again if RequestReadonlyLocked() was applied to props.
+func lockReadOnly(props *requestProperties) {
+	if props.readOnlyLocked {
+		props.setOption(RequestReadonlyValue, true)
+	}
+}
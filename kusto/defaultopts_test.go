@@ -0,0 +1,129 @@
+package kusto
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDefaultQueryOptions(t *testing.T) {
+	t.Parallel()
+
+	unsetReadonly := CustomQueryOption(RequestReadonlyValue, false)
+	unsetReadonlyInterceptor := func(ctx context.Context, info CallInfo) (CallInfo, error) {
+		info.Options[RequestReadonlyValue] = false
+		return info, nil
+	}
+
+	tests := []struct {
+		desc        string
+		defaults    []QueryOption
+		interceptor StatementInterceptor
+		ctxOptions  []QueryOption
+		options     []QueryOption
+		// want are the values of the request options that were sent, nil for an option that was not.
+		want map[string]interface{}
+	}{
+		{
+			desc: "No defaults",
+			want: map[string]interface{}{RequestReadonlyValue: nil, RequestBlockRowLevelSecurityValue: nil},
+		},
+		{
+			desc:     "Defaults",
+			defaults: []QueryOption{RequestReadonly(), RequestBlockRowLevelSecurity()},
+			want:     map[string]interface{}{RequestReadonlyValue: true, RequestBlockRowLevelSecurityValue: true},
+		},
+		{
+			desc:     "Call options add to the defaults",
+			defaults: []QueryOption{RequestReadonly()},
+			options:  []QueryOption{RequestBlockRowLevelSecurity()},
+			want:     map[string]interface{}{RequestReadonlyValue: true, RequestBlockRowLevelSecurityValue: true},
+		},
+		{
+			desc:     "Call options override the defaults",
+			defaults: []QueryOption{RequestReadonly()},
+			options:  []QueryOption{unsetReadonly},
+			want:     map[string]interface{}{RequestReadonlyValue: false},
+		},
+		{
+			desc:       "Context options override the defaults",
+			defaults:   []QueryOption{RequestReadonly()},
+			ctxOptions: []QueryOption{unsetReadonly},
+			want:       map[string]interface{}{RequestReadonlyValue: false},
+		},
+		{
+			desc:       "Call options override the context options",
+			defaults:   []QueryOption{RequestReadonly()},
+			ctxOptions: []QueryOption{unsetReadonly},
+			options:    []QueryOption{RequestReadonly()},
+			want:       map[string]interface{}{RequestReadonlyValue: true},
+		},
+		{
+			desc:       "Locked readonly is not overridden by context options",
+			defaults:   []QueryOption{RequestReadonlyLocked()},
+			ctxOptions: []QueryOption{unsetReadonly},
+			want:       map[string]interface{}{RequestReadonlyValue: true},
+		},
+		{
+			desc:     "Locked readonly is not overridden by call options",
+			defaults: []QueryOption{RequestReadonlyLocked()},
+			options:  []QueryOption{unsetReadonly},
+			want:     map[string]interface{}{RequestReadonlyValue: true},
+		},
+		{
+			desc:        "Locked readonly is not overridden by an interceptor",
+			defaults:    []QueryOption{RequestReadonlyLocked()},
+			interceptor: unsetReadonlyInterceptor,
+			want:        map[string]interface{}{RequestReadonlyValue: true},
+		},
+		{
+			desc:        "Readonly is overridden by an interceptor",
+			defaults:    []QueryOption{RequestReadonly()},
+			interceptor: unsetReadonlyInterceptor,
+			want:        map[string]interface{}{RequestReadonlyValue: false},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &recordTransport{}
+			client := newTestClient(t, "https://defaults.kusto.windows.net", transport)
+			WithDefaultQueryOptions(test.defaults...)(client)
+			WithStatementInterceptor(test.interceptor)(client)
+
+			ctx := ContextWithQueryOptions(context.Background(), test.ctxOptions...)
+			iter, err := client.Query(ctx, "db", NewStmt("T"), test.options...)
+			require.NoError(t, err)
+			iter.Stop()
+			_, err = client.QueryToJson(ctx, "db", NewStmt("T"), test.options...)
+			require.NoError(t, err)
+
+			sent := transport.sent()
+			require.Len(t, sent, 2)
+			for _, msg := range sent {
+				for key, want := range test.want {
+					got, ok := msg.Properties.Options[key]
+					if want == nil {
+						assert.False(t, ok, "%s was sent", key)
+						continue
+					}
+					assert.Equal(t, want, got, key)
+				}
+			}
+		})
+	}
+
+	// The options add up, and the ones of the client are not changed by the calls.
+	client := &Client{}
+	WithDefaultQueryOptions(RequestReadonly())(client)
+	WithDefaultQueryOptions(RequestBlockRowLevelSecurity())(client)
+	require.Len(t, client.defaultQueryOptions, 2)
+	options := client.queryDefaults([]QueryOption{NoTruncation()})
+	assert.Len(t, options, 3)
+	assert.Len(t, client.defaultQueryOptions, 2)
+}
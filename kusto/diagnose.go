@@ -0,0 +1,326 @@
+package kusto
+
+// diagnose.go implements Client.Diagnose(), which checks each layer of the connection to the service.
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// defaultDiagnoseStepTimeout is the default time each step of Diagnose() may take.
+const defaultDiagnoseStepTimeout = 10 * time.Second
+
+// DiagnosticStatus is the outcome of a step of Diagnose().
+type DiagnosticStatus string
+
+const (
+	// DSucceeded indicates the step succeeded.
+	DSucceeded DiagnosticStatus = "Succeeded"
+	// DFailed indicates the step failed or timed out.
+	DFailed DiagnosticStatus = "Failed"
+	// DSkipped indicates the step did not run, because it did not apply or a step it depends on failed.
+	DSkipped DiagnosticStatus = "Skipped"
+)
+
+// DiagnosticStep is the result of a step of Diagnose().
+type DiagnosticStep struct {
+	// Name is the name of the step.
+	Name string
+	// Status is the outcome of the step.
+	Status DiagnosticStatus
+	// Latency is how long the step took.
+	Latency time.Duration
+	// Detail holds information gathered by the step, such as resolved addresses or the TLS version.
+	Detail string
+	// Error is the error the step failed with, if any.
+	Error string
+}
+
+// DiagnosticsReport is the result of Diagnose(). It never holds secrets, so it can be attached to a support ticket.
+type DiagnosticsReport struct {
+	// Endpoint is the endpoint of the client.
+	Endpoint string
+	// Started is when the diagnostics started.
+	Started time.Time
+	// Steps are the results of each step, in the order they ran.
+	Steps []DiagnosticStep
+}
+
+// OK returns true if no step failed.
+func (d DiagnosticsReport) OK() bool {
+	for _, step := range d.Steps {
+		if step.Status == DFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// String implements fmt.Stringer, with one line per step.
+func (d DiagnosticsReport) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Diagnostics for %s at %s\n", d.Endpoint, d.Started.UTC().Format(time.RFC3339))
+	for _, step := range d.Steps {
+		fmt.Fprintf(b, "%-16s %-9s %10s", step.Name, step.Status, step.Latency.Round(time.Millisecond))
+		if step.Detail != "" {
+			fmt.Fprintf(b, "  %s", step.Detail)
+		}
+		if step.Error != "" {
+			fmt.Fprintf(b, "  error: %s", step.Error)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+type diagnoseOptions struct {
+	stepTimeout time.Duration
+}
+
+// DiagnoseOption is an optional argument to Diagnose().
+type DiagnoseOption func(d *diagnoseOptions)
+
+// DiagnoseStepTimeout sets the time each step of Diagnose() may take before it is reported as failed.
+// Defaults to 10 seconds.
+func DiagnoseStepTimeout(d time.Duration) DiagnoseOption {
+	return func(o *diagnoseOptions) {
+		o.stepTimeout = d
+	}
+}
+
+// Diagnose checks the connection to the service one layer at a time: DNS resolution of the endpoint, the proxy in
+// use, the TCP and TLS handshakes, the cloud metadata fetch, the trusted endpoint validation, token acquisition and
+// a .show version round trip. Each step is bound by a timeout, so a hung step does not block the report. The TLS
+// handshake is skipped for a http endpoint, such as a local emulator.
+// Diagnose does not return an error, the outcome of each step is in the report.
+func (c *Client) Diagnose(ctx context.Context, options ...DiagnoseOption) DiagnosticsReport {
+	opts := diagnoseOptions{stepTimeout: defaultDiagnoseStepTimeout}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	report := DiagnosticsReport{Endpoint: c.endpoint, Started: nower()}
+	run := func(name string, f func(ctx context.Context) (string, error)) bool {
+		step := runDiagnosticStep(ctx, name, opts.stepTimeout, f)
+		report.Steps = append(report.Steps, step)
+		return step.Status == DSucceeded
+	}
+	skip := func(name, reason string) {
+		report.Steps = append(report.Steps, DiagnosticStep{Name: name, Status: DSkipped, Detail: reason})
+	}
+
+	u, err := url.Parse(c.endpoint)
+	if err != nil || u.Hostname() == "" {
+		report.Steps = append(report.Steps, DiagnosticStep{Name: "endpoint", Status: DFailed, Error: fmt.Sprintf("could not parse the endpoint(%s): %v", c.endpoint, err)})
+		return report
+	}
+	host := u.Hostname()
+	// A http endpoint, such as a local emulator, is not reached through TLS.
+	plainHTTP := strings.EqualFold(u.Scheme, "http")
+	port := diagnosePort(u)
+	httpClient := c.http
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	dnsOK := run("dns", func(ctx context.Context) (string, error) {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(addrs, ","), nil
+	})
+
+	run("proxy", func(ctx context.Context) (string, error) {
+		proxy, err := diagnoseProxy(httpClient, u)
+		if err != nil {
+			return "", err
+		}
+		if proxy == nil {
+			return "none", nil
+		}
+		// Redacted hides the password of the proxy, if any.
+		return proxy.Redacted() + " (the tcp and tls steps connect directly)", nil
+	})
+
+	if dnsOK {
+		// The connection is handed over once dialed, as the step may have timed out by then.
+		dialed := make(chan net.Conn, 1)
+		tcpOK := run("tcp", func(ctx context.Context) (string, error) {
+			defer close(dialed)
+			conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+			if err != nil {
+				return "", err
+			}
+			dialed <- conn
+			return conn.RemoteAddr().String(), nil
+		})
+		switch {
+		case tcpOK && plainHTTP:
+			(<-dialed).Close()
+			skip("tls", "the endpoint uses http")
+		case tcpOK:
+			conn := <-dialed
+			run("tls", func(ctx context.Context) (string, error) {
+				cfg := &tls.Config{}
+				if t, ok := httpClient.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+					cfg = t.TLSClientConfig.Clone()
+				}
+				cfg.ServerName = host
+				tlsConn := tls.Client(conn, cfg)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					return "", err
+				}
+				state := tlsConn.ConnectionState()
+				return fmt.Sprintf("%s, %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)), nil
+			})
+			conn.Close()
+		default:
+			// A dial that succeeds after the step timed out is closed once it returns.
+			go func() {
+				if conn := <-dialed; conn != nil {
+					conn.Close()
+				}
+			}()
+			skip("tls", "the tcp step failed")
+		}
+	} else {
+		skip("tcp", "the dns step failed")
+		skip("tls", "the dns step failed")
+	}
+
+	var cloud CloudInfo
+	metadataOK := run("metadata", func(ctx context.Context) (string, error) {
+		var err error
+		cloud, err = GetMetadata(c.endpoint, httpClient)
+		if err != nil {
+			return "", err
+		}
+		return "login endpoint " + cloud.LoginEndpoint, nil
+	})
+
+	if metadataOK {
+		run("trusted-endpoint", func(ctx context.Context) (string, error) {
+			return "", checkTrustedEndpoint(c.endpoint, cloud.LoginEndpoint, c.trustedEndpointPolicy)
+		})
+	} else {
+		skip("trusted-endpoint", "the metadata step failed")
+	}
+
+	if tkp := c.auth.TokenProvider; tkp != nil && tkp.AuthorizationRequired() {
+		run("token", func(ctx context.Context) (string, error) {
+			// The token provider is shared with the calls of the Client, whose http.Client is kept if it was set.
+			tkp.setHttpIfUnset(httpClient)
+			// The token itself must never be reported.
+			_, tokenType, err := tkp.AcquireToken(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "acquired a " + tokenType + " token", nil
+		})
+	} else {
+		skip("token", "no authorization is configured")
+	}
+
+	if c.conn != nil {
+		run("show-version", func(ctx context.Context) (string, error) {
+			iter, err := c.Mgmt(ctx, defaultDB, NewStmt(".show version"))
+			if err != nil {
+				return "", err
+			}
+			defer iter.Stop()
+
+			var version string
+			err = iter.Do(func(row *table.Row) error {
+				for i, col := range row.ColumnTypes {
+					if col.Name == "ServiceVersion" || (version == "" && col.Name == "BuildVersion") {
+						version = row.Values[i].String()
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return "", err
+			}
+			if version == "" {
+				return "round trip succeeded", nil
+			}
+			return "service version " + version, nil
+		})
+	} else {
+		skip("show-version", "the client has no connection")
+	}
+
+	return report
+}
+
+// diagnosePort returns the port of u, or the default port of its scheme: 80 for http and 443 otherwise.
+func diagnosePort(u *url.URL) string {
+	switch {
+	case u.Port() != "":
+		return u.Port()
+	case strings.EqualFold(u.Scheme, "http"):
+		return "80"
+	}
+	return "443"
+}
+
+// runDiagnosticStep runs f with a timeout. f is run in its own goroutine so that steps that do not honor their
+// context are reported as failed once the timeout expires instead of blocking the report.
+func runDiagnosticStep(ctx context.Context, name string, timeout time.Duration, f func(ctx context.Context) (string, error)) DiagnosticStep {
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		detail string
+		err    error
+	}
+	ch := make(chan result, 1)
+
+	start := nower()
+	go func() {
+		detail, err := f(stepCtx)
+		ch <- result{detail: detail, err: err}
+	}()
+
+	step := DiagnosticStep{Name: name}
+	select {
+	case r := <-ch:
+		step.Detail = r.detail
+		if r.err != nil {
+			step.Status = DFailed
+			step.Error = r.err.Error()
+		} else {
+			step.Status = DSucceeded
+		}
+	case <-stepCtx.Done():
+		step.Status = DFailed
+		step.Error = fmt.Sprintf("step did not complete: %s", stepCtx.Err())
+	}
+	step.Latency = nower().Sub(start)
+	return step
+}
+
+// diagnoseProxy returns the proxy the http.Client uses for u, or nil if there is none.
+func diagnoseProxy(client *http.Client, u *url.URL) (*url.URL, error) {
+	proxy := http.ProxyFromEnvironment
+	if client.Transport != nil {
+		t, ok := client.Transport.(*http.Transport)
+		if !ok {
+			return nil, nil
+		}
+		proxy = t.Proxy
+	}
+	if proxy == nil {
+		return nil, nil
+	}
+	return proxy(&http.Request{URL: u})
+}
@@ -0,0 +1,184 @@
+package kusto
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const showVersionResponse = `{"Tables":[{"TableName":"Table_0","Columns":[` +
+	`{"ColumnName":"BuildVersion","DataType":"String","ColumnType":"string"},` +
+	`{"ColumnName":"ServiceVersion","DataType":"String","ColumnType":"string"}],` +
+	`"Rows":[["1.0.8000.1","1.0.8000.1234"]]}]}`
+
+func TestDiagnose(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc        string
+		plainHTTP   bool
+		mgmtHangs   bool
+		wantStatus  map[string]DiagnosticStatus
+		wantDetail  map[string]string
+		wantErrPart map[string]string
+	}{
+		{
+			desc: "Success",
+			wantStatus: map[string]DiagnosticStatus{
+				"dns":          DSucceeded,
+				"proxy":        DSucceeded,
+				"tcp":          DSucceeded,
+				"tls":          DSucceeded,
+				"metadata":     DSucceeded,
+				"token":        DSkipped,
+				"show-version": DSucceeded,
+			},
+			wantDetail: map[string]string{
+				"dns":          "127.0.0.1",
+				"show-version": "service version 1.0.8000.1234",
+			},
+		},
+		{
+			desc:      "Http endpoint",
+			plainHTTP: true,
+			wantStatus: map[string]DiagnosticStatus{
+				"tcp":          DSucceeded,
+				"tls":          DSkipped,
+				"show-version": DSucceeded,
+			},
+			wantDetail: map[string]string{
+				"tls": "the endpoint uses http",
+			},
+		},
+		{
+			desc:      "Hung step",
+			mgmtHangs: true,
+			wantStatus: map[string]DiagnosticStatus{
+				"tls":          DSucceeded,
+				"show-version": DFailed,
+			},
+			wantErrPart: map[string]string{
+				"show-version": "step did not complete",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			release := make(chan struct{})
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/rest/auth/metadata":
+					w.Write([]byte(`{"AzureAD":{"LoginEndpoint":"https://login.microsoftonline.com","LoginMfaRequired":false,` +
+						`"KustoClientAppId":"db662dc1-0cfe-4e1c-a843-19a68e65be58","KustoClientRedirectUri":"https://microsoft/kustoclient",` +
+						`"KustoServiceResourceId":"https://kusto.dev.kusto.windows.net","FirstPartyAuthorityUrl":"https://login.microsoftonline.com/f8cdef31-a31e-4b4a-93e4-5f571e91255a"}}`))
+				case "/v1/rest/mgmt":
+					if test.mgmtHangs {
+						<-release
+						return
+					}
+					w.Write([]byte(showVersionResponse))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			if test.plainHTTP {
+				server.Start()
+			} else {
+				server.StartTLS()
+			}
+			defer server.Close()
+			defer close(release)
+
+			conn, err := newConn(server.URL, Authorization{}, server.Client(), NewClientDetails("", ""))
+			require.NoError(t, err)
+			client := &Client{conn: conn, endpoint: server.URL, http: server.Client()}
+
+			start := time.Now()
+			report := client.Diagnose(context.Background(), DiagnoseStepTimeout(500*time.Millisecond))
+			assert.Less(t, time.Since(start), 4*time.Second)
+
+			steps := map[string]DiagnosticStep{}
+			for _, step := range report.Steps {
+				steps[step.Name] = step
+			}
+			for name, want := range test.wantStatus {
+				assert.Equal(t, want, steps[name].Status, "step %s: %+v", name, steps[name])
+			}
+			for name, want := range test.wantDetail {
+				assert.Equal(t, want, steps[name].Detail, "step %s", name)
+			}
+			for name, want := range test.wantErrPart {
+				assert.Contains(t, steps[name].Error, want, "step %s", name)
+			}
+
+			assert.Contains(t, report.String(), "show-version")
+			assert.Equal(t, server.URL, report.Endpoint)
+		})
+	}
+}
+
+func TestDiagnosePort(t *testing.T) {
+	t.Parallel()
+
+	for endpoint, want := range map[string]string{
+		"https://help.kusto.windows.net":      "443",
+		"http://localhost":                    "80",
+		"HTTP://localhost":                    "80",
+		"http://localhost:8080":               "8080",
+		"https://help.kusto.windows.net:8443": "8443",
+	} {
+		u, err := url.Parse(endpoint)
+		require.NoError(t, err)
+		assert.Equal(t, want, diagnosePort(u), endpoint)
+	}
+}
+
+func TestDiagnoseReportOK(t *testing.T) {
+	t.Parallel()
+
+	report := DiagnosticsReport{Steps: []DiagnosticStep{{Name: "dns", Status: DSucceeded}, {Name: "token", Status: DSkipped}}}
+	assert.True(t, report.OK())
+
+	report.Steps = append(report.Steps, DiagnosticStep{Name: "tls", Status: DFailed, Error: "bad certificate"})
+	assert.False(t, report.OK())
+	assert.True(t, strings.Contains(report.String(), "error: bad certificate"))
+}
+
+func TestDiagnoseKeepsTokenProviderHttp(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// The token provider keeps the http.Client of the calls of the Client.
+	tkp := &TokenProvider{customToken: "token", tokenScheme: "Bearer"}
+	callsHttp := &http.Client{}
+	tkp.SetHttp(callsHttp)
+	client := &Client{endpoint: server.URL, http: server.Client(), auth: Authorization{TokenProvider: tkp}}
+
+	report := client.Diagnose(context.Background(), DiagnoseStepTimeout(500*time.Millisecond))
+	for _, step := range report.Steps {
+		if step.Name == "token" {
+			assert.Equal(t, DSucceeded, step.Status, "%+v", step)
+		}
+	}
+	assert.Same(t, callsHttp, tkp.http.Load())
+
+	// A token provider without one gets the http.Client of the diagnosis.
+	tkp = &TokenProvider{}
+	tkp.setHttpIfUnset(callsHttp)
+	assert.Same(t, callsHttp, tkp.http.Load())
+}
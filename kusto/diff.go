@@ -0,0 +1,121 @@
+package kusto
+
+// diff.go implements DiffIterators(), which compares the rows of two RowIterators, such as the RowIterator of the code
+// under test and one replaying the expected rows from MockRows.
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// diffEntry is a row or an inline error read by DiffIterators().
+type diffEntry struct {
+	row       *table.Row
+	inlineErr *errors.Error
+}
+
+func (d diffEntry) String() string {
+	if d.inlineErr != nil {
+		return fmt.Sprintf("inline error %q", d.inlineErr.Error())
+	}
+	return fmt.Sprintf("%v", d.row.Values)
+}
+
+// DiffIterators reads want and got to the end and returns a description of the differences between their rows, one
+// per line, or an empty string if they have the same rows. The values are compared with value.Equal(), so datetimes
+// in different time zones, decimals written differently and dynamic values with different JSON formatting are equal.
+// The columns of the rows are compared by name and type, and the inline errors by their message.
+// An error is returned if either RowIterator fails. Both RowIterators must still be stopped.
+// Example:
+//
+//	m, _ := kusto.NewMockRows(table.Columns{{Name: "Id", Type: types.Long}})
+//	m.Row(value.Values{value.NewLong(1)})
+//	want := &kusto.RowIterator{}
+//	want.Mock(m)
+//
+//	diff, err := kusto.DiffIterators(want, got)
+func DiffIterators(want, got *RowIterator) (string, error) {
+	wantEntries, err := readDiffEntries(want)
+	if err != nil {
+		return "", fmt.Errorf("reading the wanted rows: %w", err)
+	}
+	gotEntries, err := readDiffEntries(got)
+	if err != nil {
+		return "", fmt.Errorf("reading the rows: %w", err)
+	}
+
+	var diffs []string
+	for i := 0; i < len(wantEntries) || i < len(gotEntries); i++ {
+		switch {
+		case i >= len(gotEntries):
+			diffs = append(diffs, fmt.Sprintf("row %d: missing, want %s", i, wantEntries[i]))
+		case i >= len(wantEntries):
+			diffs = append(diffs, fmt.Sprintf("row %d: unexpected %s", i, gotEntries[i]))
+		default:
+			diffs = append(diffs, diffEntries(i, wantEntries[i], gotEntries[i])...)
+		}
+	}
+	return strings.Join(diffs, "\n"), nil
+}
+
+// readDiffEntries reads the rows and inline errors of iter.
+func readDiffEntries(iter *RowIterator) ([]diffEntry, error) {
+	var entries []diffEntry
+	for {
+		row, inlineErr, err := iter.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return nil, err
+		}
+		entries = append(entries, diffEntry{row: row, inlineErr: inlineErr})
+	}
+}
+
+// diffEntries returns the differences between the entries number i of the RowIterators.
+func diffEntries(i int, want, got diffEntry) []string {
+	if want.inlineErr != nil || got.inlineErr != nil {
+		if want.inlineErr == nil || got.inlineErr == nil || want.inlineErr.Error() != got.inlineErr.Error() {
+			return []string{fmt.Sprintf("row %d: want %s, got %s", i, want, got)}
+		}
+		return nil
+	}
+
+	if !sameColumns(want.row.ColumnTypes, got.row.ColumnTypes) {
+		return []string{fmt.Sprintf("row %d: want columns %v, got %v", i, want.row.ColumnTypes, got.row.ColumnTypes)}
+	}
+	var diffs []string
+	for c, col := range want.row.ColumnTypes {
+		if !value.Equal(want.row.Values[c], got.row.Values[c]) {
+			diffs = append(diffs, fmt.Sprintf("row %d: column %q: want %s, got %s", i, col.Name, diffValue(want.row.Values[c]), diffValue(got.row.Values[c])))
+		}
+	}
+	return diffs
+}
+
+// sameColumns reports whether a and b have the same names and types, in the same order.
+func sameColumns(a, b table.Columns) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+	return true
+}
+
+// diffValue returns v as written in a difference, telling a null value from an empty string.
+func diffValue(v value.Kusto) string {
+	if value.IsNull(v) {
+		return "null"
+	}
+	return fmt.Sprintf("%q", v.String())
+}
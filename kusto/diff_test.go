@@ -0,0 +1,112 @@
+package kusto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffIterators(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{
+		{Name: "Id", Type: types.Long},
+		{Name: "Name", Type: types.String},
+		{Name: "At", Type: types.DateTime},
+		{Name: "Price", Type: types.Decimal},
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	wantRows := []value.Values{
+		{value.NewLong(1), value.NewString("a"), value.NewDateTime(at), value.NewDecimal("1.50")},
+		{value.NewLong(2), value.NullString(), value.NullDateTime(), value.NullDecimal()},
+	}
+
+	tests := []struct {
+		desc string
+		// gotColumns are the columns of the rows compared to wantRows, which default to columns.
+		gotColumns table.Columns
+		rows       []value.Values
+		err        error
+		want       string
+		wantErr    bool
+	}{
+		{
+			desc: "Equal values written differently",
+			rows: []value.Values{
+				{value.NewLong(1), value.NewString("a"), value.NewDateTime(at.In(time.FixedZone("UTC+2", 2*60*60))), value.NewDecimal("1.5")},
+				{value.NewLong(2), value.NullString(), value.NullDateTime(), value.NullDecimal()},
+			},
+		},
+		{
+			desc: "Different values",
+			rows: []value.Values{
+				{value.NewLong(1), value.NewString("b"), value.NewDateTime(at), value.NewDecimal("1.50")},
+				{value.NewLong(2), value.NewString(""), value.NullDateTime(), value.NullDecimal()},
+			},
+			want: `row 0: column "Name": want "a", got "b"` + "\n" + `row 1: column "Name": want null, got ""`,
+		},
+		{
+			desc: "Missing row",
+			rows: wantRows[:1],
+			want: "row 1: missing, want [2   ]",
+		},
+		{
+			desc: "Unexpected row",
+			rows: append(append([]value.Values{}, wantRows...), wantRows[0]),
+			want: "row 2: unexpected [1 a 2024-01-02T03:04:05Z 1.50]",
+		},
+		{
+			desc:       "Different columns",
+			gotColumns: table.Columns{{Name: "Id", Type: types.Long}, {Name: "Name", Type: types.String}, {Name: "At", Type: types.DateTime}, {Name: "Cost", Type: types.Decimal}},
+			rows:       wantRows[:1],
+			want: "row 0: want columns [{Id long} {Name string} {At datetime} {Price decimal}], got [{Id long} {Name string} {At datetime} {Cost decimal}]\n" +
+				"row 1: missing, want [2   ]",
+		},
+		{
+			desc:    "Failure",
+			rows:    wantRows,
+			err:     errors.ES(errors.OpQuery, errors.KInternal, "failed"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			mockIterator := func(columns table.Columns, rows []value.Values, err error) *RowIterator {
+				m, e := NewMockRows(columns)
+				require.NoError(t, e)
+				for _, row := range rows {
+					require.NoError(t, m.Row(row))
+				}
+				if err != nil {
+					require.NoError(t, m.Error(err))
+				}
+				iter := &RowIterator{}
+				require.NoError(t, iter.Mock(m))
+				t.Cleanup(iter.Stop)
+				return iter
+			}
+
+			gotColumns := test.gotColumns
+			if gotColumns == nil {
+				gotColumns = columns
+			}
+			diff, err := DiffIterators(mockIterator(columns, wantRows, nil), mockIterator(gotColumns, test.rows, test.err))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, diff)
+		})
+	}
+}
@@ -56,6 +56,13 @@ and accepts only string constants for arguments.
 		panic("add error handling")
 	}
 
+A query with several tabular expression statements, or that uses the fork operator, returns several primary result
+tables. Their rows are returned one table after the other, with the columns of their table: RowIterator.TableIndex()
+tells which table a row belongs to, and Client.QueryDataset() returns the rows grouped by table.
+
+The tables of a result that are not primary results, such as the extended properties and the query status that Mgmt()
+commands return along with their rows, are returned by RowIterator.NonPrimary() by kind and name once the rows were read.
+
 # Querying Rows Into Structs
 
 Keeping our query the same, instead of printing the Rows we will simply put them into a slice of structs
@@ -96,6 +103,9 @@ A struct object can use fields to store the Kusto values as normal Go values, po
 The value.Kusto types are useful when you need to distiguish between the zero value of a variable and the value not being
 set in Kusto.
 
+Fields of embedded structs are promoted as in Go, and a field whose type implements kusto.Unmarshaler decodes its
+column itself with UnmarshalKusto().
+
 All value.Kusto types have a .Value and .Valid field. .Value is the native Go value, .Valid is a bool which
 indicates if the value was set. More information can be found in the sub-package data/value.
 
@@ -121,11 +131,55 @@ The following is a conversion table from the Kusto column types to native Go val
 	------------------------------------------------------------------------------
 	timestamp			value.Timestamp, time.Duration, *time.Duration
 	------------------------------------------------------------------------------
-	decimal				value.Decimal, string, *string
+	decimal				value.Decimal, string, *string, float64, *float64, *big.Float
 	==============================================================================
 
+StructToKustoValues() does the reverse, returning the columns and values of a struct, and the kql package uses it to
+declare an inline datatable from a slice of structs, with the values escaped as literals:
+
+	decl, err := kql.NewDatatableFromStructs("Events", events)
+
+Kusto decimal values have up to 34 significant digits. A string or a *big.Float keeps all of them, while a float64 only
+keeps about 15. Decimal parameters can be passed as a string, *big.Float, *big.Int, float64 or value.Decimal.
+
 For more information on Kusto scalar types, see: https://docs.microsoft.com/en-us/azure/kusto/query/scalar-data-types/
 
+Kusto datetime values are in UTC, with a precision of 100ns. Decoded datetime values are always returned in UTC, and
+time.Time values passed as parameters are converted to UTC, so a time.Time in any location round trips to the same instant.
+Use the PreserveLocation() option to receive datetime values in another location, such as time.Local.
+
+# Exporting Rows
+
+ToCSV() and ToJSONLines() write the rows to an io.Writer as they are received, for tools that read files, and return the
+number of rows written:
+
+	f, err := os.Create("results.csv")
+	if err != nil {
+		panic("add error handling")
+	}
+	defer f.Close()
+
+	n, err := iter.ToCSV(f)
+
+Results larger than the limits of a query can be read by pages with QueryPaged(), which stores them on the service:
+
+	pages, err := client.QueryPaged(ctx, "database", query, 100000)
+	if err != nil {
+		panic("add error handling")
+	}
+	defer pages.Close(ctx)
+
+	for {
+		iter, ok, err := pages.NextPage(ctx)
+		if err != nil {
+			panic("add error handling")
+		}
+		if !ok {
+			break
+		}
+		// Read the rows of the page, then call iter.Stop().
+	}
+
 # Stmt
 
 Every query is done using a Stmt. A Stmt is built with Go string constants and can do variable substitution
@@ -156,6 +210,12 @@ using Kusto's Query Paramaters.
 		),
 	)
 
+The same query can be built with the kql package, which declares each parameter with the type of its Go value and
+escapes the names of tables, columns and databases. A kql.Builder is a Stmt, so it is passed to Query() as is:
+
+	stmt := kql.New("").AddTable("systemNodes").Add(" | where NodeId == ").AddParam("ParamNodeId", int64(100))
+	iter, err := client.Query(ctx, "database", stmt)
+
 # Ingest
 
 Support for Kusto ingestion from local files, Azure Blob Storage and streaming is supported in the sub-package ingest.
@@ -166,6 +226,9 @@ See documentation in that package for more details
 To support mocking for this client in your code for hermetic testing purposes, this client supports mocking the data
 returned by our RowIterator object. Please see the MockRows documentation for code examples.
 
+The sub-package fake provides a fake Client, which returns RowIterators built from seeded tables, rows, inline errors
+and headers with the same state machines as the Client. See the documentation in that package for an example.
+
 # Package Examples
 
 Below you will find a simple and complex example of doing Query() the represent compiled code:
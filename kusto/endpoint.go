@@ -0,0 +1,125 @@
+package kusto
+
+// endpoint.go converts the endpoint of a cluster between its query form, "https://cluster.region.kusto.windows.net",
+// and its ingestion form, "https://ingest-cluster.region.kusto.windows.net", see WithAutoCorrectEndpoint().
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// ingestPrefix is the prefix of the host name of the ingestion endpoint of a cluster.
+const ingestPrefix = "ingest-"
+
+// kustoDomains are the domains under which every cluster has an ingestion endpoint named after its query endpoint.
+// Clusters are named under them directly or with a region, such as "cluster.westeurope.kusto.windows.net", or with
+// the privatelink zone of a private endpoint.
+var kustoDomains = []string{
+	".kusto.windows.net", ".kustomfa.windows.net", ".kustodev.windows.net",
+	".kusto.azuresynapse.net", ".kusto.azuresynapse.azure.cn", ".kustodev.azuresynapse-dogfood.net",
+	".kusto.fabric.microsoft.com", ".kusto.data.microsoft.com",
+	".kusto.chinacloudapi.cn", ".kustomfa.chinacloudapi.cn",
+	".kusto.usgovcloudapi.net", ".kustomfa.usgovcloudapi.net",
+	".kusto.core.eaglex.ic.gov", ".kustomfa.core.eaglex.ic.gov",
+	".kusto.core.microsoft.scloud", ".kustomfa.core.microsoft.scloud",
+}
+
+// AmbiguousEndpointError is returned when the query or ingestion endpoint of an endpoint cannot be told from its host
+// name, such as an IP address, localhost, or the custom DNS name of a private endpoint, whose other endpoint can have
+// any name. The other endpoint must then be set explicitly.
+type AmbiguousEndpointError struct {
+	// Endpoint is the endpoint that could not be converted.
+	Endpoint string
+	// Reason describes why the conversion is ambiguous.
+	Reason string
+}
+
+// Error implements error.
+func (e *AmbiguousEndpointError) Error() string {
+	return fmt.Sprintf("endpoint %s cannot be converted: %s", e.Endpoint, e.Reason)
+}
+
+// WithAutoCorrectEndpoint lets New() accept the ingestion endpoint of a cluster, such as
+// "https://ingest-cluster.kusto.windows.net", which is converted into its query endpoint with ToQueryEndpoint().
+// Without it, New() rejects an endpoint that starts with "ingest-". This is for configurations that only hold the
+// ingestion endpoint. An endpoint whose conversion is ambiguous is an *AmbiguousEndpointError.
+func WithAutoCorrectEndpoint() Option {
+	return func(c *Client) {
+		c.autoCorrectEndpoint = true
+	}
+}
+
+// ToQueryEndpoint returns the query endpoint of endpoint, which is endpoint without the "ingest-" prefix of its host
+// name, such as "https://cluster.kusto.windows.net" for "https://ingest-cluster.kusto.windows.net". A query endpoint is
+// returned as is. The "ingest-" prefix is only removed under the domains of Kusto, for other hosts the conversion is an
+// *AmbiguousEndpointError.
+func ToQueryEndpoint(endpoint string) (string, error) {
+	u, err := parseEndpoint(endpoint)
+	if err != nil {
+		return "", err
+	}
+	host := u.Hostname()
+	if !strings.HasPrefix(strings.ToLower(host), ingestPrefix) {
+		return endpoint, nil
+	}
+	if reason := ambiguousHost(host); reason != "" {
+		return "", &AmbiguousEndpointError{Endpoint: endpoint, Reason: reason}
+	}
+
+	u.Host = u.Host[len(ingestPrefix):]
+	return u.String(), nil
+}
+
+// ToIngestionEndpoint returns the ingestion endpoint of endpoint, which is endpoint with its host name prefixed with
+// "ingest-", such as "https://ingest-cluster.kusto.windows.net" for "https://cluster.kusto.windows.net". An ingestion
+// endpoint is returned as is. The prefix is only added under the domains of Kusto, for other hosts the conversion is an
+// *AmbiguousEndpointError.
+func ToIngestionEndpoint(endpoint string) (string, error) {
+	u, err := parseEndpoint(endpoint)
+	if err != nil {
+		return "", err
+	}
+	host := u.Hostname()
+	if strings.HasPrefix(strings.ToLower(host), ingestPrefix) {
+		return endpoint, nil
+	}
+	if reason := ambiguousHost(host); reason != "" {
+		return "", &AmbiguousEndpointError{Endpoint: endpoint, Reason: reason}
+	}
+
+	u.Host = ingestPrefix + u.Host
+	return u.String(), nil
+}
+
+// parseEndpoint parses endpoint, which must have a host.
+func parseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "could not parse the endpoint(%s): %s", endpoint, err).SetNoRetry()
+	}
+	if u.Hostname() == "" {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "the endpoint(%s) has no host", endpoint).SetNoRetry()
+	}
+	return u, nil
+}
+
+// ambiguousHost returns why the other endpoint of host cannot be told from it, or "" if it can.
+func ambiguousHost(host string) string {
+	lower := strings.ToLower(host)
+	switch {
+	case net.ParseIP(host) != nil:
+		return "the host is an IP address"
+	case lower == "localhost":
+		return "the host is localhost"
+	}
+	for _, domain := range kustoDomains {
+		if strings.HasSuffix(lower, domain) {
+			return ""
+		}
+	}
+	return "the host is not under a Kusto domain, such as the custom DNS name of a private endpoint"
+}
@@ -0,0 +1,145 @@
+package kusto
+
+import (
+	goErrors "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointConversion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc       string
+		endpoint   string
+		wantQuery  string
+		wantIngest string
+		// ambiguous is set when both conversions are an *AmbiguousEndpointError, except for the endpoint that
+		// is already of the form asked.
+		ambiguous bool
+		err       bool
+	}{
+		{
+			desc:       "Cluster",
+			endpoint:   "https://cluster.kusto.windows.net",
+			wantQuery:  "https://cluster.kusto.windows.net",
+			wantIngest: "https://ingest-cluster.kusto.windows.net",
+		},
+		{
+			desc:       "Ingestion endpoint",
+			endpoint:   "https://ingest-cluster.kusto.windows.net",
+			wantQuery:  "https://cluster.kusto.windows.net",
+			wantIngest: "https://ingest-cluster.kusto.windows.net",
+		},
+		{
+			desc:       "Regional cluster with a port and a path",
+			endpoint:   "https://cluster.westeurope.kusto.windows.net:443/path",
+			wantQuery:  "https://cluster.westeurope.kusto.windows.net:443/path",
+			wantIngest: "https://ingest-cluster.westeurope.kusto.windows.net:443/path",
+		},
+		{
+			desc:       "Upper case prefix",
+			endpoint:   "https://INGEST-Cluster.EastUS.Kusto.Windows.Net",
+			wantQuery:  "https://Cluster.EastUS.Kusto.Windows.Net",
+			wantIngest: "https://INGEST-Cluster.EastUS.Kusto.Windows.Net",
+		},
+		{
+			desc:       "Privatelink zone",
+			endpoint:   "https://ingest-cluster.privatelink.westus.kusto.windows.net",
+			wantQuery:  "https://cluster.privatelink.westus.kusto.windows.net",
+			wantIngest: "https://ingest-cluster.privatelink.westus.kusto.windows.net",
+		},
+		{
+			desc:       "Sovereign cloud",
+			endpoint:   "https://cluster.kusto.chinacloudapi.cn",
+			wantQuery:  "https://cluster.kusto.chinacloudapi.cn",
+			wantIngest: "https://ingest-cluster.kusto.chinacloudapi.cn",
+		},
+		{
+			desc:       "Fabric",
+			endpoint:   "https://trd-abc.z0.kusto.fabric.microsoft.com",
+			wantQuery:  "https://trd-abc.z0.kusto.fabric.microsoft.com",
+			wantIngest: "https://ingest-trd-abc.z0.kusto.fabric.microsoft.com",
+		},
+		{
+			desc:      "Custom DNS of a private endpoint",
+			endpoint:  "https://kusto.contoso.internal",
+			wantQuery: "https://kusto.contoso.internal",
+			ambiguous: true,
+		},
+		{
+			desc:       "Custom DNS starting with ingest-",
+			endpoint:   "https://ingest-kusto.contoso.internal",
+			wantIngest: "https://ingest-kusto.contoso.internal",
+			ambiguous:  true,
+		},
+		{
+			desc:      "IP address",
+			endpoint:  "http://127.0.0.1:8080",
+			wantQuery: "http://127.0.0.1:8080",
+			ambiguous: true,
+		},
+		{
+			desc:      "localhost",
+			endpoint:  "http://localhost:8080",
+			wantQuery: "http://localhost:8080",
+			ambiguous: true,
+		},
+		{
+			desc:     "No host",
+			endpoint: "cluster.kusto.windows.net",
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			check := func(got string, err error, want string) {
+				if test.err {
+					assert.Error(t, err)
+					return
+				}
+				if test.ambiguous && want == "" {
+					var ambiguous *AmbiguousEndpointError
+					require.True(t, goErrors.As(err, &ambiguous), "got %T: %v", err, err)
+					assert.Equal(t, test.endpoint, ambiguous.Endpoint)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+
+			got, err := ToQueryEndpoint(test.endpoint)
+			check(got, err, test.wantQuery)
+			got, err = ToIngestionEndpoint(test.endpoint)
+			check(got, err, test.wantIngest)
+		})
+	}
+}
+
+func TestWithAutoCorrectEndpoint(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(NewConnectionStringBuilder("https://ingest-auto.kusto.windows.net"))
+	assert.Error(t, err, "an ingestion endpoint is rejected by default")
+
+	client, err := New(NewConnectionStringBuilder("https://ingest-auto.kusto.windows.net"), WithAutoCorrectEndpoint())
+	require.NoError(t, err)
+	assert.Equal(t, "https://auto.kusto.windows.net", client.Endpoint())
+	assert.Equal(t, "https://auto.kusto.windows.net/v2/rest/query", client.QueryURL())
+
+	_, err = New(NewConnectionStringBuilder("https://ingest-kusto.contoso.internal"), WithAutoCorrectEndpoint())
+	var ambiguous *AmbiguousEndpointError
+	assert.True(t, goErrors.As(err, &ambiguous), "got %T: %v", err, err)
+
+	// The ingestion endpoint of a Mgmt() call is the one of ToIngestionEndpoint().
+	transport := &captureTransport{}
+	client = newTestClient(t, "https://kusto.contoso.internal", transport)
+	_, err = client.getConn(mgmtCall, connOptions{mgmtOptions: &mgmtOptions{requestProperties: &requestProperties{}, queryIngestion: true}})
+	assert.True(t, goErrors.As(err, &ambiguous), "got %T: %v", err, err)
+}
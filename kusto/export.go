@@ -0,0 +1,261 @@
+package kusto
+
+// export.go implements RowIterator.ToCSV() and RowIterator.ToJSONLines(), which stream the rows to files for other
+// tools.
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// exportTimeFormat is the canonical text of datetime values, in UTC with the 100ns precision of Kusto.
+const exportTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+type exportOptions struct {
+	comma     rune
+	keepNulls bool
+}
+
+// ExportOption is an optional argument to ToCSV() and ToJSONLines().
+type ExportOption func(o *exportOptions)
+
+// ExportComma sets the field delimiter of ToCSV(). Defaults to ','.
+func ExportComma(r rune) ExportOption {
+	return func(o *exportOptions) {
+		o.comma = r
+	}
+}
+
+// ExportSkipNullNormalization writes the Value field of null values, which is the zero value of their type, such as 0,
+// false or 0001-01-01T00:00:00.0000000Z, instead of an empty CSV field or a JSON null. This suits tools that do not
+// accept missing values. Null dynamic values are still written as an empty field or null, as they have no zero value.
+func ExportSkipNullNormalization() ExportOption {
+	return func(o *exportOptions) {
+		o.keepNulls = true
+	}
+}
+
+// ToCSV writes the rows of the iterator to w as CSV (RFC 4180), with a header of the names of the columns. Each row is
+// written as soon as it is read, and the number of rows written is returned.
+//
+// Values are written in their canonical text: datetime values in UTC as RFC 3339 with 7 digits of fractional
+// seconds, timespan values as [-][d.]hh:mm:ss[.fffffff], reals as "NaN", "Infinity" or "-Infinity" when they are not
+// finite, and dynamic values as their raw JSON. Null values are written as an empty field, see
+// ExportSkipNullNormalization().
+//
+// When the results of a progressive query are replaced (table.Row.Replace), the rows already written are removed if w
+// is an *os.File, or anything else with Seek() and Truncate(), and an error of kind errors.KClientArgs is returned
+// otherwise. The returned count is then the number of rows since the replacement. This method will fail on errors
+// inline within the rows.
+func (r *RowIterator) ToCSV(w io.Writer, options ...ExportOption) (int64, error) {
+	opts := exportOptions{comma: ','}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := csv.NewWriter(buf)
+	enc.Comma = opts.comma
+	record := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		record[i] = col.Name
+	}
+	if err := enc.Write(record); err != nil {
+		return 0, errors.ES(r.op, errors.KClientArgs, "could not write CSV: %s", err).SetNoRetry()
+	}
+	enc.Flush()
+
+	return r.export(w, buf, func(row *table.Row) error {
+		for i := range record {
+			record[i] = ""
+			if i < len(row.Values) {
+				record[i] = opts.text(row.Values[i])
+			}
+		}
+		if err := enc.Write(record); err != nil {
+			return err
+		}
+		enc.Flush()
+		return enc.Error()
+	})
+}
+
+// ToJSONLines writes the rows of the iterator to w as JSON Lines: one JSON object per row, with the columns in their
+// order as keys. Each row is written as soon as it is read, and the number of rows written is returned.
+//
+// Booleans, ints, longs and finite reals are JSON values, dynamic values are their raw JSON, and the other types are
+// strings in the canonical text that ToCSV() writes, which keeps the precision of decimals. Null values are JSON nulls,
+// see ExportSkipNullNormalization(). Progressive results and inline errors are handled as with ToCSV().
+func (r *RowIterator) ToJSONLines(w io.Writer, options ...ExportOption) (int64, error) {
+	opts := exportOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	keys := make([][]byte, len(r.columns))
+	for i, col := range r.columns {
+		key, err := json.Marshal(col.Name)
+		if err != nil {
+			return 0, errors.ES(r.op, errors.KClientArgs, "could not write JSON: %s", err).SetNoRetry()
+		}
+		keys[i] = key
+	}
+
+	buf := &bytes.Buffer{}
+	return r.export(w, buf, func(row *table.Row) error {
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			var v value.Kusto
+			if i < len(row.Values) {
+				v = row.Values[i]
+			}
+			if err := opts.json(buf, v); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}\n")
+		return nil
+	})
+}
+
+// truncater is implemented by *os.File, which lets the rows written be removed when progressive results are replaced.
+type truncater interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// export writes what is in buf to w, then calls encode for each row, which adds the row to buf, and writes buf to w.
+func (r *RowIterator) export(w io.Writer, buf *bytes.Buffer, encode func(row *table.Row) error) (int64, error) {
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return 0, errors.E(r.op, errors.KIO, err)
+	}
+
+	// written is the number of bytes of rows written since the start or the last replacement.
+	var rows, written int64
+	err := r.Do(func(row *table.Row) error {
+		if row.Replace && written > 0 {
+			t, ok := w.(truncater)
+			if !ok {
+				return errors.ES(r.op, errors.KClientArgs, "the progressive results replaced %d rows that were already written, "+
+					"and the writer cannot be truncated", rows).SetNoRetry()
+			}
+			offset, err := t.Seek(-written, io.SeekCurrent)
+			if err != nil {
+				return errors.E(r.op, errors.KIO, err)
+			}
+			if err := t.Truncate(offset); err != nil {
+				return errors.E(r.op, errors.KIO, err)
+			}
+			rows, written = 0, 0
+		}
+		if row.Replace {
+			rows = 0
+		}
+
+		buf.Reset()
+		if err := encode(row); err != nil {
+			return errors.ES(r.op, errors.KClientArgs, "could not encode row %d: %s", rows, err).SetNoRetry()
+		}
+		n, err := w.Write(buf.Bytes())
+		written += int64(n)
+		if err != nil {
+			return errors.E(r.op, errors.KIO, err)
+		}
+		rows++
+		return nil
+	})
+	return rows, err
+}
+
+// text returns the canonical text of a value in a CSV field.
+func (o exportOptions) text(v value.Kusto) string {
+	if v == nil || (value.IsNull(v) && !o.keepNulls) {
+		return ""
+	}
+
+	switch v := v.(type) {
+	case value.Bool:
+		return strconv.FormatBool(v.Value)
+	case value.Int:
+		return strconv.FormatInt(int64(v.Value), 10)
+	case value.Long:
+		return strconv.FormatInt(v.Value, 10)
+	case value.Real:
+		switch {
+		case math.IsNaN(v.Value):
+			return "NaN"
+		case math.IsInf(v.Value, 1):
+			return "Infinity"
+		case math.IsInf(v.Value, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v.Value, 'g', -1, 64)
+	case value.Decimal:
+		if v.Value == "" {
+			return "0"
+		}
+		return v.Value
+	case value.String:
+		return v.Value
+	case value.DateTime:
+		return v.Value.UTC().Format(exportTimeFormat)
+	case value.Timespan:
+		v.Valid = true
+		return v.Marshal()
+	case value.GUID:
+		return v.Value.String()
+	case value.Dynamic:
+		return string(v.Value)
+	}
+	return v.String()
+}
+
+// json adds the JSON of a value to buf.
+func (o exportOptions) json(buf *bytes.Buffer, v value.Kusto) error {
+	if v == nil || (value.IsNull(v) && !o.keepNulls) {
+		buf.WriteString("null")
+		return nil
+	}
+
+	switch v := v.(type) {
+	case value.Bool, value.Int, value.Long:
+		buf.WriteString(o.text(v))
+		return nil
+	case value.Real:
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			break
+		}
+		buf.WriteString(o.text(v))
+		return nil
+	case value.Dynamic:
+		if len(v.Value) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		if !json.Valid(v.Value) {
+			return fmt.Errorf("dynamic value is not valid JSON: %q", v.Value)
+		}
+		return json.Compact(buf, v.Value)
+	}
+
+	s, err := json.Marshal(o.text(v))
+	if err != nil {
+		return err
+	}
+	buf.Write(s)
+	return nil
+}
@@ -0,0 +1,183 @@
+package kusto
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exportColumns = table.Columns{
+	{Name: "Name", Type: "string"},
+	{Name: "Count", Type: "long"},
+	{Name: "Price", Type: "real"},
+	{Name: "Amount", Type: "decimal"},
+	{Name: "When", Type: "datetime"},
+	{Name: "Took", Type: "timespan"},
+	{Name: "ID", Type: "guid"},
+	{Name: "Props", Type: "dynamic"},
+}
+
+func exportRows() []value.Values {
+	when := time.Date(2022, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+	return []value.Values{
+		{
+			value.NewString("a, \"quoted\"\nname"),
+			value.NewLong(3),
+			value.NewReal(1.5),
+			value.NewDecimal("12345678901234567890.123456789"),
+			value.NewDateTime(when),
+			value.NewTimespan(26*time.Hour + 1500*time.Millisecond),
+			value.NewGUID(uuid.MustParse("3b3a5d0a-6f2a-4d3e-9c0a-6d3b1f1a2b3c")),
+			value.NewDynamic([]byte(`{ "a": [1, 2] }`)),
+		},
+		{
+			value.NullString(),
+			value.NullLong(),
+			value.NewReal(math.Inf(-1)),
+			value.NullDecimal(),
+			value.NullDateTime(),
+			value.NullTimespan(),
+			value.NullGUID(),
+			value.NullDynamic(),
+		},
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		json    bool
+		options []ExportOption
+		want    string
+	}{
+		{
+			desc: "CSV",
+			want: "Name,Count,Price,Amount,When,Took,ID,Props\n" +
+				"\"a, \"\"quoted\"\"\nname\",3,1.5,12345678901234567890.123456789,2022-01-02T02:04:05.6000000Z,1.02:00:01.5," +
+				"3b3a5d0a-6f2a-4d3e-9c0a-6d3b1f1a2b3c,\"{ \"\"a\"\": [1, 2] }\"\n" +
+				",,-Infinity,,,,,\n",
+		},
+		{
+			desc:    "CSV with options",
+			options: []ExportOption{ExportComma(';'), ExportSkipNullNormalization()},
+			want: "Name;Count;Price;Amount;When;Took;ID;Props\n" +
+				"\"a, \"\"quoted\"\"\nname\";3;1.5;12345678901234567890.123456789;2022-01-02T02:04:05.6000000Z;1.02:00:01.5;" +
+				"3b3a5d0a-6f2a-4d3e-9c0a-6d3b1f1a2b3c;\"{ \"\"a\"\": [1, 2] }\"\n" +
+				";0;-Infinity;0;0001-01-01T00:00:00.0000000Z;00:00:00;00000000-0000-0000-0000-000000000000;\n",
+		},
+		{
+			desc: "JSON Lines",
+			json: true,
+			want: `{"Name":"a, \"quoted\"\nname","Count":3,"Price":1.5,"Amount":"12345678901234567890.123456789",` +
+				`"When":"2022-01-02T02:04:05.6000000Z","Took":"1.02:00:01.5","ID":"3b3a5d0a-6f2a-4d3e-9c0a-6d3b1f1a2b3c",` +
+				`"Props":{"a":[1,2]}}` + "\n" +
+				`{"Name":null,"Count":null,"Price":"-Infinity","Amount":null,"When":null,"Took":null,"ID":null,"Props":null}` + "\n",
+		},
+		{
+			desc:    "JSON Lines without null normalization",
+			json:    true,
+			options: []ExportOption{ExportSkipNullNormalization()},
+			want: `{"Name":"a, \"quoted\"\nname","Count":3,"Price":1.5,"Amount":"12345678901234567890.123456789",` +
+				`"When":"2022-01-02T02:04:05.6000000Z","Took":"1.02:00:01.5","ID":"3b3a5d0a-6f2a-4d3e-9c0a-6d3b1f1a2b3c",` +
+				`"Props":{"a":[1,2]}}` + "\n" +
+				`{"Name":"","Count":0,"Price":"-Infinity","Amount":"0","When":"0001-01-01T00:00:00.0000000Z","Took":"00:00:00",` +
+				`"ID":"00000000-0000-0000-0000-000000000000","Props":null}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := spoolIterator(exportColumns, func(send func(fr v2.TableFragment)) {
+				send(v2.TableFragment{KustoRows: exportRows()})
+			})
+			defer iter.Stop()
+
+			got := &bytes.Buffer{}
+			var n int64
+			var err error
+			if test.json {
+				n, err = iter.ToJSONLines(got, test.options...)
+			} else {
+				n, err = iter.ToCSV(got, test.options...)
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+			assert.Equal(t, test.want, got.String())
+		})
+	}
+}
+
+func TestExportNoRows(t *testing.T) {
+	t.Parallel()
+
+	iter := spoolIterator(exportColumns[:2], func(send func(fr v2.TableFragment)) {})
+	defer iter.Stop()
+
+	got := &bytes.Buffer{}
+	n, err := iter.ToCSV(got)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.Equal(t, "Name,Count\n", got.String())
+}
+
+func TestExportProgressive(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "ID", Type: "long"}}
+	progressive := func() *RowIterator {
+		return spoolIterator(columns, func(send func(fr v2.TableFragment)) {
+			send(v2.TableFragment{KustoRows: []value.Values{{value.NewLong(1)}, {value.NewLong(2)}}})
+			send(v2.TableFragment{KustoRows: []value.Values{{value.NewLong(10)}}, TableFragmentType: "DataReplace"})
+			send(v2.TableFragment{KustoRows: []value.Values{{value.NewLong(11)}}})
+		})
+	}
+
+	t.Run("File", func(t *testing.T) {
+		t.Parallel()
+
+		iter := progressive()
+		defer iter.Stop()
+
+		f, err := os.Create(filepath.Join(t.TempDir(), "out.csv"))
+		require.NoError(t, err)
+		defer f.Close()
+
+		n, err := iter.ToCSV(f)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		got, err := os.ReadFile(f.Name())
+		require.NoError(t, err)
+		assert.Equal(t, "ID\n10\n11\n", string(got))
+	})
+
+	t.Run("Writer", func(t *testing.T) {
+		t.Parallel()
+
+		iter := progressive()
+		defer iter.Stop()
+
+		n, err := iter.ToJSONLines(&bytes.Buffer{})
+		require.Error(t, err)
+		var kustoErr *errors.Error
+		require.ErrorAs(t, err, &kustoErr)
+		assert.Equal(t, errors.KClientArgs, kustoErr.Kind)
+		assert.Equal(t, int64(2), n)
+	})
+}
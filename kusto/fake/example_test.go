@@ -0,0 +1,55 @@
+package fake_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/fake"
+)
+
+// countNodes is the code under test, it is given a *kusto.Client in production.
+func countNodes(ctx context.Context, client kusto.Querier) (int64, error) {
+	iter, err := client.Query(ctx, "database", kusto.NewStmt("systemNodes | count"))
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Stop()
+
+	var count int64
+	err = iter.Do(func(row *table.Row) error {
+		rec := struct{ Count int64 }{}
+		if err := row.ToStruct(&rec); err != nil {
+			return err
+		}
+		count = rec.Count
+		return nil
+	})
+	return count, err
+}
+
+func ExampleClient() {
+	client := fake.NewClient()
+	err := client.OnQuery("systemNodes | count", fake.Response{
+		Tables: []fake.Table{
+			{
+				Columns: table.Columns{{Name: "Count", Type: types.Long}},
+				Rows:    []value.Values{{value.Long{Value: 3, Valid: true}}},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	count, err := countNodes(context.Background(), client)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(count, len(client.Calls()))
+
+	// Output: 3 1
+}
@@ -0,0 +1,336 @@
+/*
+Package fake provides a fake of *kusto.Client for the unit tests of code that queries Kusto.
+
+A Client is seeded with the Response to return for each query or management command. The RowIterators it returns
+are built from the frames of the Response by kusto.NewRowIteratorFromFrames(), so they decode the rows and inline
+errors with the same state machines as the ones of a *kusto.Client.
+
+Code under test should accept an interface with the methods it uses, such as kusto.Querier or kusto.QueryClient,
+which both *kusto.Client and *Client implement:
+
+	type nodes struct {
+		client kusto.QueryClient // A *kusto.Client in production, a *fake.Client in tests.
+	}
+*/
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	internalframes "github.com/Azure/azure-kusto-go/kusto/internal/frames"
+)
+
+// Endpoint is the endpoint returned by Client.Endpoint().
+const Endpoint = "https://fake.kusto.windows.net"
+
+var _ kusto.QueryClient = (*Client)(nil)
+
+// InlineError is an error sent by the service among the rows of a table, such as a partial query failure.
+type InlineError struct {
+	// Code is the code of the error, such as "LimitsExceeded".
+	Code string
+	// Message is the message of the error.
+	Message string
+}
+
+// Table is a primary result table of a Response.
+type Table struct {
+	// Columns are the columns of the table.
+	Columns table.Columns
+	// Rows are the rows of the table, whose values must match the types of the Columns.
+	Rows []value.Values
+	// Errors are the inline errors returned after the rows.
+	Errors []InlineError
+}
+
+// Response is what a Client returns for a call.
+type Response struct {
+	// Tables are the primary result tables, in order.
+	Tables []Table
+	// Header is the header of the response, in RowIterator.ResponseHeader.
+	Header http.Header
+	// Err, if set, is returned by the call instead of the tables.
+	Err error
+}
+
+// validate checks that the rows of r match their columns.
+func (r Response) validate() error {
+	for _, t := range r.Tables {
+		mock, err := kusto.NewMockRows(t.Columns)
+		if err != nil {
+			return err
+		}
+		for _, row := range t.Rows {
+			if err := mock.Row(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Call is a call received by a Client.
+type Call struct {
+	// Mgmt is set for a call to Mgmt(), unset for Query() and QueryToJson().
+	Mgmt bool
+	// DB is the database of the call.
+	DB string
+	// Query is the statement of the call, and Text is its text as returned by Stmt.String(), which starts with the
+	// declare query_parameters statement if the Stmt has definitions.
+	Query kusto.Stmt
+	Text  string
+}
+
+type handler struct {
+	match func(call Call) bool
+	resp  Response
+}
+
+// Client is a fake of *kusto.Client. Each call returns the Response of the first handler that matches it, in the
+// order they were added, or an error if none does. The options of the calls are ignored. It is safe for concurrent
+// use.
+type Client struct {
+	mu            sync.Mutex
+	handlers      []handler
+	calls         []Call
+	closed        bool
+	clientDetails *kusto.ClientDetails
+	http          *http.Client
+}
+
+// NewClient returns a Client without responses.
+func NewClient() *Client {
+	return &Client{
+		clientDetails: kusto.NewClientDetails("", ""),
+		http:          &http.Client{},
+	}
+}
+
+// OnCall makes the calls for which match returns true return resp. An error is returned if the rows of resp do not
+// match their columns.
+func (c *Client) OnCall(match func(call Call) bool, resp Response) error {
+	if err := resp.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler{match: match, resp: resp})
+	return nil
+}
+
+// OnQuery makes the calls to Query() and QueryToJson() whose text is query return resp, whatever their database.
+func (c *Client) OnQuery(query string, resp Response) error {
+	return c.OnCall(func(call Call) bool { return !call.Mgmt && call.Text == query }, resp)
+}
+
+// OnMgmt makes the calls to Mgmt() whose text is command return resp, whatever their database.
+func (c *Client) OnMgmt(command string, resp Response) error {
+	return c.OnCall(func(call Call) bool { return call.Mgmt && call.Text == command }, resp)
+}
+
+// Calls returns the calls received so far, in order.
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Query implements kusto.Querier.
+func (c *Client) Query(ctx context.Context, db string, query kusto.Stmt, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
+	resp, err := c.respond(errors.OpQuery, Call{DB: db, Query: query, Text: query.String()})
+	if err != nil {
+		return nil, err
+	}
+	return resp.iterator(ctx)
+}
+
+// Mgmt implements kusto.Mgmter.
+func (c *Client) Mgmt(ctx context.Context, db string, query kusto.Stmt, _ ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+	resp, err := c.respond(errors.OpMgmt, Call{Mgmt: true, DB: db, Query: query, Text: query.String()})
+	if err != nil {
+		return nil, err
+	}
+	return resp.iterator(ctx)
+}
+
+// QueryToJson implements kusto.QueryClient.QueryToJson(). The JSON is the response of the v2 query API holding the tables.
+func (c *Client) QueryToJson(ctx context.Context, db string, query kusto.Stmt, _ ...kusto.QueryOption) (string, error) {
+	resp, err := c.respond(errors.OpQuery, Call{DB: db, Query: query, Text: query.String()})
+	if err != nil {
+		return "", err
+	}
+	return resp.json()
+}
+
+// Endpoint implements kusto.QueryClient.Endpoint(), it returns the Endpoint constant.
+func (c *Client) Endpoint() string {
+	return Endpoint
+}
+
+// ClientDetails implements kusto.QueryClient.ClientDetails().
+func (c *Client) ClientDetails() *kusto.ClientDetails {
+	return c.clientDetails
+}
+
+// HttpClient implements kusto.QueryClient.HttpClient(). The Client makes no HTTP requests.
+func (c *Client) HttpClient() *http.Client {
+	return c.http
+}
+
+// Close implements kusto.QueryClient.Close(). The calls made after Close() fail.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// respond records call and returns the Response of the first handler that matches it.
+func (c *Client) respond(op errors.Op, call Call) (Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return Response{}, errors.ES(op, errors.KClientArgs, "the fake client is closed").SetNoRetry()
+	}
+	c.calls = append(c.calls, call)
+
+	for _, h := range c.handlers {
+		if h.match(call) {
+			if h.resp.Err != nil {
+				return Response{}, h.resp.Err
+			}
+			return h.resp, nil
+		}
+	}
+	return Response{}, errors.ES(op, errors.KClientArgs, "the fake client has no response for %q on database %q", call.Text, call.DB).SetNoRetry()
+}
+
+// frames returns the frames of the v2 query API holding the tables of r.
+func (r Response) frames() []frames.Frame {
+	out := []frames.Frame{frames.DataSetHeader{Base: frames.Base{FrameType: frames.TypeDataSetHeader}, Version: "v2.0"}}
+	hasErrors := false
+	for i, t := range r.Tables {
+		dt := frames.DataTable{
+			Base:      frames.Base{FrameType: frames.TypeDataTable},
+			TableID:   i,
+			TableKind: frames.PrimaryResult,
+			TableName: frames.PrimaryResult,
+			Columns:   t.Columns,
+		}
+		// Every RowIterator gets its own rows.
+		for _, row := range t.Rows {
+			dt.KustoRows = append(dt.KustoRows, append(value.Values(nil), row...))
+		}
+		for _, e := range t.Errors {
+			dt.RowErrors = append(dt.RowErrors, *errors.OneToErr(e.oneAPIError(), errors.OpQuery))
+			hasErrors = true
+		}
+		out = append(out, dt)
+	}
+	return append(out, frames.DataSetCompletion{Base: frames.Base{FrameType: frames.TypeDataSetCompletion}, HasErrors: hasErrors})
+}
+
+// iterator returns a RowIterator over the frames of r.
+func (r Response) iterator(ctx context.Context) (*kusto.RowIterator, error) {
+	fs := r.frames()
+	ch := make(chan frames.Frame, len(fs))
+	for _, f := range fs {
+		ch <- f
+	}
+	close(ch)
+
+	iter, err := kusto.NewRowIteratorFromFrames(ctx, ch)
+	if err != nil {
+		return nil, err
+	}
+	iter.ResponseHeader = r.Header.Clone()
+	return iter, nil
+}
+
+// oneAPIError returns e in the form the service sends it among the rows.
+func (e InlineError) oneAPIError() map[string]interface{} {
+	return map[string]interface{}{
+		"OneApiErrors": []interface{}{
+			map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    e.Code,
+					"message": e.Message,
+				},
+			},
+		},
+	}
+}
+
+type jsonColumn struct {
+	ColumnName string
+	ColumnType string
+}
+
+type jsonDataTable struct {
+	FrameType string
+	TableId   int
+	TableKind frames.TableKind
+	TableName frames.TableKind
+	Columns   []jsonColumn
+	Rows      []interface{}
+}
+
+// json returns r as the response of the v2 query API.
+func (r Response) json() (string, error) {
+	out := []interface{}{
+		struct {
+			FrameType     string
+			IsProgressive bool
+			Version       string
+		}{FrameType: frames.TypeDataSetHeader, Version: "v2.0"},
+	}
+
+	hasErrors := false
+	for i, t := range r.Tables {
+		dt := jsonDataTable{
+			FrameType: frames.TypeDataTable,
+			TableId:   i,
+			TableKind: frames.PrimaryResult,
+			TableName: frames.PrimaryResult,
+			Columns:   make([]jsonColumn, len(t.Columns)),
+			Rows:      []interface{}{},
+		}
+		for j, col := range t.Columns {
+			dt.Columns[j] = jsonColumn{ColumnName: col.Name, ColumnType: string(col.Type)}
+		}
+		for _, row := range t.Rows {
+			values := make([]interface{}, len(row))
+			for j, v := range row {
+				values[j] = internalframes.JSONValue(v)
+			}
+			dt.Rows = append(dt.Rows, values)
+		}
+		for _, e := range t.Errors {
+			dt.Rows = append(dt.Rows, e.oneAPIError())
+			hasErrors = true
+		}
+		out = append(out, dt)
+	}
+
+	out = append(out, struct {
+		FrameType string
+		HasErrors bool
+		Cancelled bool
+	}{FrameType: frames.TypeDataSetCompletion, HasErrors: hasErrors})
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
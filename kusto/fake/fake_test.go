@@ -0,0 +1,127 @@
+package fake
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = table.Columns{{Name: "Name", Type: types.String}, {Name: "Count", Type: types.Long}}
+
+var testResponse = Response{
+	Tables: []Table{
+		{
+			Columns: testColumns,
+			Rows: []value.Values{
+				{value.String{Value: "a", Valid: true}, value.Long{Value: 1, Valid: true}},
+				{value.String{Value: "b", Valid: true}, value.Long{}},
+			},
+			Errors: []InlineError{{Code: "LimitsExceeded", Message: "Query execution has exceeded the allowed limits"}},
+		},
+		{
+			Columns: table.Columns{{Name: "Total", Type: types.Real}},
+		},
+	},
+	Header: http.Header{"X-Test": []string{"1"}},
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient()
+	require.NoError(t, client.OnQuery("T", testResponse))
+	require.NoError(t, client.OnMgmt(".show tables", Response{Err: errors.ES(errors.OpMgmt, errors.KHTTPError, "boom")}))
+
+	iter, err := client.Query(context.Background(), "db", kusto.NewStmt("T"))
+	require.NoError(t, err)
+	defer iter.Stop()
+	assert.Equal(t, "1", iter.ResponseHeader.Get("X-Test"))
+
+	var rows []value.Values
+	var inline []*errors.Error
+	var tables []int
+	require.NoError(t, iter.DoOnRowOrError(func(row *table.Row, e *errors.Error) error {
+		if e != nil {
+			inline = append(inline, e)
+			return nil
+		}
+		rows = append(rows, row.Values)
+		tables = append(tables, iter.TableIndex())
+		return nil
+	}))
+	assert.Equal(t, testResponse.Tables[0].Rows, rows)
+	assert.Equal(t, []int{0, 0}, tables)
+	require.Len(t, inline, 1)
+	assert.Equal(t, errors.KLimitsExceeded, inline[0].Kind)
+
+	_, err = client.Mgmt(context.Background(), "db", kusto.NewStmt(".show tables"))
+	assert.EqualError(t, err, errors.ES(errors.OpMgmt, errors.KHTTPError, "boom").Error())
+
+	_, err = client.Query(context.Background(), "db", kusto.NewStmt("Unknown"))
+	assert.Error(t, err)
+
+	assert.Equal(t, []Call{
+		{DB: "db", Query: kusto.NewStmt("T"), Text: "T"},
+		{Mgmt: true, DB: "db", Query: kusto.NewStmt(".show tables"), Text: ".show tables"},
+		{DB: "db", Query: kusto.NewStmt("Unknown"), Text: "Unknown"},
+	}, client.Calls())
+
+	assert.Equal(t, Endpoint, client.Endpoint())
+	assert.NotNil(t, client.ClientDetails())
+	assert.NotNil(t, client.HttpClient())
+
+	require.NoError(t, client.Close())
+	_, err = client.Query(context.Background(), "db", kusto.NewStmt("T"))
+	assert.Error(t, err)
+}
+
+func TestClientQueryToJson(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient()
+	require.NoError(t, client.OnQuery("T", testResponse))
+
+	js, err := client.QueryToJson(context.Background(), "db", kusto.NewStmt("T"))
+	require.NoError(t, err)
+
+	// The JSON decodes to the same rows as the RowIterator.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iter, err := kusto.NewRowIteratorFromFrames(ctx, kusto.DecodeFrames(ctx, strings.NewReader(js)))
+	require.NoError(t, err)
+	defer iter.Stop()
+
+	ds := map[int][]value.Values{}
+	var inline []*errors.Error
+	require.NoError(t, iter.DoOnRowOrError(func(row *table.Row, e *errors.Error) error {
+		if e != nil {
+			inline = append(inline, e)
+			return nil
+		}
+		ds[iter.TableIndex()] = append(ds[iter.TableIndex()], row.Values)
+		return nil
+	}))
+	assert.Equal(t, map[int][]value.Values{0: testResponse.Tables[0].Rows}, ds)
+	require.Len(t, inline, 1)
+	assert.Equal(t, errors.KLimitsExceeded, inline[0].Kind)
+}
+
+func TestClientValidation(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient()
+	err := client.OnQuery("T", Response{Tables: []Table{{
+		Columns: testColumns,
+		Rows:    []value.Values{{value.String{Value: "a", Valid: true}, value.Int{Value: 1, Valid: true}}},
+	}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package frames_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+)
+
+// archived is a response of the v2 query API, as it could have been saved to a file.
+const archived = `[
+{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},
+{"FrameType":"DataTable","TableId":0,"TableKind":"QueryProperties","TableName":"@ExtendedProperties","Columns":[{"ColumnName":"TableId","ColumnType":"int"},{"ColumnName":"Key","ColumnType":"string"},{"ColumnName":"Value","ColumnType":"dynamic"}],"Rows":[[1,"Visualization","{\"Visualization\":\"table\"}"]]},
+{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"Region","ColumnType":"string"},{"ColumnName":"Count","ColumnType":"long"}],"Rows":[["west",1],["east",2]]},
+{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}
+]`
+
+// This example reads the raw frames of a response.
+func Example_rawFrames() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for f := range kusto.DecodeFrames(ctx, strings.NewReader(archived)) {
+		switch f := f.(type) {
+		case frames.DataSetHeader:
+			fmt.Printf("DataSetHeader: version %s, progressive %v\n", f.Version, f.IsProgressive)
+		case frames.DataTable:
+			fmt.Printf("DataTable %d: %s with %d columns and %d rows\n", f.TableID, f.TableName, len(f.Columns), len(f.KustoRows))
+		case frames.DataSetCompletion:
+			fmt.Printf("DataSetCompletion: errors %v\n", f.HasErrors)
+		case frames.Error:
+			fmt.Println("Error:", f.Msg)
+		}
+	}
+
+	// Output:
+	// DataSetHeader: version v2.0, progressive false
+	// DataTable 0: @ExtendedProperties with 3 columns and 1 rows
+	// DataTable 1: PrimaryResult with 2 columns and 2 rows
+	// DataSetCompletion: errors false
+}
+
+// countDecoder is a frames.Decoder that reads one number per line and returns them as a table.
+type countDecoder struct{}
+
+func (countDecoder) Decode(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame {
+	ch := make(chan frames.Frame)
+	go func() {
+		defer close(ch)
+		send := func(f frames.Frame) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- f:
+				return true
+			}
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			send(frames.Error{Msg: err.Error()})
+			return
+		}
+		rows := []value.Values{}
+		for _, line := range strings.Fields(string(b)) {
+			var n int64
+			if _, err := fmt.Sscan(line, &n); err != nil {
+				send(frames.Error{Msg: err.Error()})
+				return
+			}
+			rows = append(rows, value.Values{value.NewLong(n)})
+		}
+
+		send(frames.DataSetHeader{Base: frames.Base{FrameType: frames.TypeDataSetHeader}, Version: "v2.0", Op: op})
+		send(frames.DataTable{
+			Base:      frames.Base{FrameType: frames.TypeDataTable},
+			TableID:   1,
+			TableKind: frames.PrimaryResult,
+			TableName: frames.PrimaryResult,
+			Columns:   table.Columns{{Name: "N", Type: "long"}},
+			KustoRows: rows,
+			Op:        op,
+		})
+		send(frames.DataSetCompletion{Base: frames.Base{FrameType: frames.TypeDataSetCompletion}, Op: op})
+	}()
+	return ch
+}
+
+// This example implements a Decoder for another source of rows, and reads them with a RowIterator.
+func ExampleDecoder() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var dec frames.Decoder = countDecoder{}
+	iter, err := kusto.NewRowIteratorFromFrames(ctx, dec.Decode(ctx, strings.NewReader("1\n2\n3"), errors.OpQuery))
+	if err != nil {
+		panic(err)
+	}
+	defer iter.Stop()
+
+	err = iter.Do(func(row *table.Row) error {
+		fmt.Println(row.Values[0])
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// 1
+	// 2
+	// 3
+}
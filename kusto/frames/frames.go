@@ -0,0 +1,269 @@
+/*
+Package frames holds the frames of the Kusto REST v2 protocol, which is how query results are streamed from the service.
+
+Most users never need this package: Client.Query() decodes the frames and returns a RowIterator. It is useful for
+reading raw frames, such as when re-serializing a response in a proxy, and for providing frames from another source,
+such as an archived response, to kusto.NewRowIteratorFromFrames().
+
+A response is a JSON array of frames. It starts with a DataSetHeader and ends with a DataSetCompletion. In between,
+each table is either a single DataTable, or if DataSetHeader.IsProgressive or DataSetHeader.IsFragmented is set, a
+TableHeader followed by any number of TableFragment and TableProgress frames and a TableCompletion. Non-primary tables, such as the
+@ExtendedProperties and QueryCompletionInformation tables, are always sent as a DataTable.
+See https://learn.microsoft.com/en-us/azure/data-explorer/kusto/api/rest/response-v2 for the protocol.
+
+# Compatibility
+
+The types, fields and constants of this package follow the compatibility promise of the module: they are not removed
+or changed in a way that breaks callers within a major version. New fields and new TableKind values may be added, so
+code should ignore what it does not know. The decoders of the SDK are internal and may change.
+*/
+package frames
+
+import (
+	"context"
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+const (
+	// TypeDataTable is the .FrameType that indicates a Kusto DataTable.
+	TypeDataTable = "DataTable"
+	// TypeDataSetCompletion is the .FrameType that indicates a Kusto DataSetCompletion.
+	TypeDataSetCompletion = "DataSetCompletion"
+	// TypeDataSetHeader is the .FrameType that indicates a Kusto DataSetHeader.
+	TypeDataSetHeader = "DataSetHeader"
+	// TypeTableHeader is the .FrameType that indicates a Kusto TableHeader.
+	TypeTableHeader = "TableHeader"
+	// TypeTableFragment is the .FrameType that indicates a Kusto TableFragment.
+	TypeTableFragment = "TableFragment"
+	// TypeTableProgress is the .FrameType that indicates a Kusto TableProgress.
+	TypeTableProgress = "TableProgress"
+	// TypeTableCompletion is the .FrameType that indicates a Kusto TableCompletion.
+	TypeTableCompletion = "TableCompletion"
+)
+
+// TableKind describes the kind of table.
+type TableKind string
+
+const (
+	// QueryProperties is a dataTable.TableKind that contains properties about the query itself.
+	// The dataTable.TableName is usually ExtendedProperties.
+	QueryProperties TableKind = "QueryProperties"
+	// PrimaryResult is a dataTable.TableKind that contains the query information the user wants.
+	// The dataTable.TableName is PrimaryResult.
+	PrimaryResult TableKind = "PrimaryResult"
+	// QueryCompletionInformation contains information on how long the query took.
+	// The dataTable.TableName is QueryCompletionInformation.
+	QueryCompletionInformation TableKind = "QueryCompletionInformation"
+	QueryTraceLog              TableKind = "QueryTraceLog"
+	QueryPerfLog               TableKind = "QueryPerfLog"
+	QueryResult                TableKind = "QueryResult"
+	TableOfContents            TableKind = "TableOfContents"
+	QueryPlan                  TableKind = "QueryPlan"
+	QueryStatus                TableKind = "QueryStatus"
+	ExtendedProperties         TableKind = "@ExtendedProperties"
+	UnknownTableKind           TableKind = "Unknown"
+)
+
+// Decoder decodes a stream of Kusto frames. Implementations other than the ones of the SDK can be used to provide
+// frames from another source to kusto.NewRowIteratorFromFrames().
+type Decoder interface {
+	// Decode decodes r, a stream of Kusto frames, and sends the frames on the returned channel in the order they
+	// were received. For the v2 protocol, the first frame is a DataSetHeader. If decoding fails, an Error is sent
+	// as the last frame.
+	// The channel is closed once r is fully read, an Error was sent or ctx is done. If r implements io.Closer,
+	// it is closed at the same time. All frames have their Op field set to op.
+	Decode(ctx context.Context, r io.Reader, op errors.Op) <-chan Frame
+}
+
+// Frame is a type of Kusto frame as defined in the reference document.
+type Frame interface {
+	IsFrame()
+}
+
+// Error is not actually a Kusto frame, but is used to signal the end of a stream
+// where we encountered an error. Error implements error.
+type Error struct {
+	Msg string
+}
+
+// Error implements error.Error().
+func (e Error) Error() string {
+	return e.Msg
+}
+
+// IsFrame implements Frame.IsFrame().
+func (Error) IsFrame() {}
+
+// Base is information that is encoded in all frames. The fields aren't actually
+// in the spec, but are transmitted on the wire.
+type Base struct {
+	// FrameType is one of the Type constants, such as TypeDataTable.
+	FrameType string
+}
+
+// DataSetHeader is the first frame in a response. It implements Frame.
+type DataSetHeader struct {
+	Base
+	// Version is the version of the APi responding. The current version is "v2.0".
+	Version string
+	// IsProgressive indicates that TableHeader, TableFragment, TableProgress, and TableCompletion frames are used
+	// for the primary results instead of a DataTable.
+	IsProgressive bool
+	// IsFragmented indicates that TableHeader, TableFragment and TableCompletion frames are used for the primary
+	// results of a response that is not progressive, see kusto.ResultsV2FragmentedStreaming(). Unlike a progressive
+	// response, the fragments only ever append rows and there are no TableProgress frames.
+	IsFragmented bool
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op
+}
+
+// IsFrame implements Frame.IsFrame().
+func (DataSetHeader) IsFrame() {}
+
+// DataTable is used report information as a Table with Columns as row headers and Rows as the contained
+// data. It implements Frame.
+type DataTable struct {
+	Base
+	// TableID is a numeric representation of the this dataTable in relation to other dataTables returned
+	// in numeric order starting at 0.
+	TableID int `json:"TableId"`
+	// TableKind is a Kusto dataTable sub-type.
+	TableKind TableKind
+	// TableName is a name for the dataTable.
+	TableName TableKind
+	// Columns is a list of column names and their Kusto storage types.
+	Columns table.Columns
+	// KustoRows are the decoded rows, whose values match the Columns. See also Rows().
+	KustoRows []value.Values
+	// RowErrors are the errors the service sent in place of rows.
+	RowErrors []errors.Error
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op `json:"-"`
+}
+
+// IsFrame implements Frame.IsFrame().
+func (DataTable) IsFrame() {}
+
+// Rows returns the KustoRows of the table as table.Rows, with their Columns.
+func (d DataTable) Rows() table.Rows {
+	return tableRows(d.Columns, d.KustoRows, d.Op)
+}
+
+// DataSetCompletion indicates the stream id done. It implements Frame.
+type DataSetCompletion struct {
+	Base
+	// HasErrors indicates that their was an error in the stream.
+	HasErrors bool
+	// Cancelled indicates that the request was cancelled.
+	Cancelled bool
+	// OneAPIErrors is a list of errors encountered, as the JSON text of each OneApiError. They are the failures that
+	// happened after some results were sent, such as a partial query failure.
+	OneAPIErrors []string `json:"OneApiErrors"`
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op `json:"-"`
+}
+
+// IsFrame implements Frame.IsFrame().
+func (DataSetCompletion) IsFrame() {}
+
+// TableHeader indicates that instead of receiving a dataTable, we will receive a
+// stream of table information. This structure holds the base information, but none
+// of the row information.
+type TableHeader struct {
+	Base
+	// TableID is a numeric representation of the this TableHeader in the stream.
+	TableID int `json:"TableId"`
+	// TableKind is a Kusto Table sub-type.
+	TableKind TableKind
+	// TableName is a name for the Table.
+	TableName TableKind
+	// Columns is a list of column names and their Kusto storage types.
+	Columns table.Columns
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op `json:"-"`
+}
+
+// IsFrame implements Frame.IsFrame().
+func (TableHeader) IsFrame() {}
+
+// TableFragment holds some of the rows of the table started by the last TableHeader. It implements Frame.
+type TableFragment struct {
+	Base
+	// TableID is a numeric representation of the this table in relation to other table parts returned.
+	TableID int `json:"TableId"`
+	// FieldCount is the number of  fields being returned. This should align with the len(TableHeader.Columns).
+	FieldCount int
+	// TableFragmentType is "DataAppend" if the rows are added to the ones already received, or "DataReplace" if
+	// they replace them.
+	TableFragmentType string
+	// KustoRows are the decoded rows, whose values match the Columns. See also Rows().
+	KustoRows []value.Values
+	// RowErrors are the errors the service sent in place of rows.
+	RowErrors []errors.Error
+
+	// Columns are the columns of the TableHeader, which are needed to decode the rows. They are not sent by the
+	// service.
+	Columns table.Columns `json:"-"`
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op `json:"-"`
+}
+
+// IsFrame implements Frame.IsFrame().
+func (TableFragment) IsFrame() {}
+
+// Rows returns the KustoRows of the fragment as table.Rows, with their Columns.
+func (t TableFragment) Rows() table.Rows {
+	return tableRows(t.Columns, t.KustoRows, t.Op)
+}
+
+// tableRows returns values as table.Rows of columns.
+func tableRows(columns table.Columns, values []value.Values, op errors.Op) table.Rows {
+	if values == nil {
+		return nil
+	}
+	rows := make(table.Rows, len(values))
+	for i, v := range values {
+		rows[i] = &table.Row{ColumnTypes: columns, Values: v, Op: op}
+	}
+	return rows
+}
+
+// TableProgress interleaves with the TableFragment frame described above. It's sole purpose
+// is to notify the client about the query progress.
+type TableProgress struct {
+	Base
+	// TableID is a numeric representation of the this table in relation to other table parts returned.
+	TableID int `json:"TableId"`
+	// TableProgress is the progress in percent (0--100).
+	TableProgress float64
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op `json:"-"`
+}
+
+// IsFrame implements Frame.IsFrame().
+func (TableProgress) IsFrame() {}
+
+// TableCompletion frames marks the end of the table transmission. No more frames related to that table will be sent.
+type TableCompletion struct {
+	Base
+	// TableID is a numeric representation of the this table in relation to other table parts returned.
+	TableID int `json:"TableId"`
+	// RowCount is the final number of rows in the table.
+	RowCount int
+
+	// Op is the operation the frame was received for. It is not sent by the service.
+	Op errors.Op `json:"-"`
+}
+
+// IsFrame implements Frame.IsFrame().
+func (TableCompletion) IsFrame() {}
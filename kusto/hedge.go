@@ -0,0 +1,199 @@
+package kusto
+
+// hedge.go implements hedged requests, where a duplicate of a slow query is sent and the first response wins.
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	"github.com/google/uuid"
+)
+
+// hedgeHeader is the header used to tag duplicate requests, it holds the attempt number.
+const hedgeHeader = "x-ms-hedge-attempt"
+
+type hedgeOptions struct {
+	delay       time.Duration
+	maxAttempts int
+}
+
+// HedgeStats reports the outcomes of queries made with the Hedged() option.
+type HedgeStats struct {
+	// Hedges is the number of duplicate requests that were sent.
+	Hedges int64
+	// OriginalWins is the number of hedged queries where the original request responded first.
+	OriginalWins int64
+	// HedgeWins is the number of hedged queries where a duplicate request responded first.
+	HedgeWins int64
+	// Failures is the number of hedged queries where every request failed.
+	Failures int64
+}
+
+type hedgeCounters struct {
+	hedges, originalWins, hedgeWins, failures atomic.Int64
+}
+
+func (h *hedgeCounters) stats() HedgeStats {
+	return HedgeStats{
+		Hedges:       h.hedges.Load(),
+		OriginalWins: h.originalWins.Load(),
+		HedgeWins:    h.hedgeWins.Load(),
+		Failures:     h.failures.Load(),
+	}
+}
+
+// hedgeResult is the outcome of a single request in a hedged query.
+type hedgeResult struct {
+	attempt    int
+	op         errors.Op
+	reqHeader  http.Header
+	respHeader http.Header
+	body       io.ReadCloser
+	err        error
+}
+
+// executeHedged is the same as execute() for a query, except that a new request is sent every hedge.delay until
+// one of the requests receives a response or hedge.maxAttempts is reached.
+// If every request fails, the error of the original request is returned. A request that fails with an error that
+// cannot be retried, such as a syntax error, would fail again, so it ends the query with its error.
+func (c *conn) executeHedged(ctx context.Context, db string, query Stmt, properties requestProperties, hedge hedgeOptions, dec frames.Decoder) (execResp, error) {
+	results := make(chan hedgeResult, hedge.maxAttempts)
+	cancels := make([]context.CancelFunc, 0, hedge.maxAttempts)
+
+	launch := func() {
+		attempt := len(cancels)
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+
+		props := properties
+		if attempt > 0 {
+			props.ClientRequestID = "KGC.execute;" + uuid.New().String()
+			props.hedgeAttempt = attempt
+			c.hedgeStats.hedges.Add(1)
+		}
+
+		go func() {
+			op, reqHeader, respHeader, body, err := c.doRequest(attemptCtx, execQuery, db, query, props)
+			results <- hedgeResult{attempt: attempt, op: op, reqHeader: reqHeader, respHeader: respHeader, body: body, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(hedge.delay)
+	defer timer.Stop()
+
+	launch()
+	pending := 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < hedge.maxAttempts {
+				launch()
+				pending++
+				timer.Reset(hedge.delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				if !errors.Retry(r.err) || ctx.Err() != nil {
+					for _, cancel := range cancels {
+						cancel()
+					}
+					drainHedges(results, pending)
+					c.hedgeStats.failures.Add(1)
+					return execResp{}, r.err
+				}
+				if r.attempt == 0 {
+					firstErr = r.err
+				}
+				if pending > 0 {
+					continue
+				}
+				if len(cancels) < hedge.maxAttempts {
+					// Nothing is in flight, so don't wait for the timer to send the next request.
+					launch()
+					pending++
+					continue
+				}
+				for _, cancel := range cancels {
+					cancel()
+				}
+				c.hedgeStats.failures.Add(1)
+				return execResp{}, firstErr
+			}
+
+			body := c.hedgeWon(r, cancels, results, pending)
+			frameCh := dec.Decode(ctx, body, r.op)
+			return execResp{reqHeader: r.reqHeader, respHeader: r.respHeader, frameCh: frameCh}, nil
+		}
+	}
+}
+
+// hedgeWon cancels all the requests other than the winner, records the outcome and cleans up the responses
+// that are still in flight. It returns the body of the winner, which cancels the winner's request when it is closed.
+func (c *conn) hedgeWon(winner hedgeResult, cancels []context.CancelFunc, results chan hedgeResult, pending int) io.ReadCloser {
+	if winner.attempt == 0 {
+		c.hedgeStats.originalWins.Add(1)
+	} else {
+		c.hedgeStats.hedgeWins.Add(1)
+	}
+
+	for i, cancel := range cancels {
+		if i != winner.attempt {
+			cancel()
+		}
+	}
+	drainHedges(results, pending)
+
+	// The winner's context must live as long as the stream is being read. The decoder closes the body once the stream
+	// ends or the RowIterator is stopped, which releases it.
+	return &cancelOnClose{ReadCloser: winner.body, cancel: cancels[winner.attempt]}
+}
+
+// drainHedges closes the bodies of the pending requests of a hedged query, whose results are no longer used.
+func drainHedges(results chan hedgeResult, pending int) {
+	if pending == 0 {
+		return
+	}
+	go func() {
+		for ; pending > 0; pending-- {
+			if r := <-results; r.err == nil {
+				r.body.Close()
+			}
+		}
+	}()
+}
+
+// cancelOnClose is a response body that cancels the context of its request when it is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Abort cancels the request, which ends a read of the body. The decoder calls it when its context is done.
+func (c *cancelOnClose) Abort() error {
+	c.cancel()
+	if a, ok := c.ReadCloser.(interface{ Abort() error }); ok {
+		return a.Abort()
+	}
+	return c.ReadCloser.Close()
+}
+
+// HedgeStats returns the outcomes of the queries made with the Hedged() option by this client.
+func (c *Client) HedgeStats() HedgeStats {
+	if innerConn, ok := c.conn.(*conn); ok {
+		return innerConn.hedgeStats.stats()
+	}
+	return HedgeStats{}
+}
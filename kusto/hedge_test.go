@@ -0,0 +1,194 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hedgeTestStream = `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+	`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+
+// hedgeTransport is a fake http.RoundTripper that answers each hedge attempt after a configured delay.
+type hedgeTransport struct {
+	mu     sync.Mutex
+	delays map[string]time.Duration
+	fail   map[string]bool
+	// permanent are the attempts that fail with an error that cannot be retried.
+	permanent map[string]bool
+	seen      []string
+}
+
+func (h *hedgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	attempt := req.Header.Get(hedgeHeader)
+	if attempt == "" {
+		attempt = "0"
+	}
+	h.mu.Lock()
+	h.seen = append(h.seen, attempt)
+	delay := h.delays[attempt]
+	fail := h.fail[attempt]
+	permanent := h.permanent[attempt]
+	h.mu.Unlock()
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-time.After(delay):
+	}
+
+	if fail {
+		return nil, fmt.Errorf("attempt %s failed", attempt)
+	}
+	if permanent {
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"code":"General_BadRequest","message":"Syntax error","@permanent":true}}`)),
+		}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(hedgeTestStream)),
+	}, nil
+}
+
+func TestHedged(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc        string
+		delays      map[string]time.Duration
+		fail        map[string]bool
+		permanent   map[string]bool
+		wantErr     bool
+		wantSeen    []string
+		wantAttempt string
+		wantStats   HedgeStats
+	}{
+		{
+			desc:        "original wins",
+			delays:      map[string]time.Duration{"0": 0},
+			wantAttempt: "",
+			wantStats:   HedgeStats{OriginalWins: 1},
+		},
+		{
+			desc:        "hedge wins",
+			delays:      map[string]time.Duration{"0": 5 * time.Second, "1": 0},
+			wantAttempt: "1",
+			wantStats:   HedgeStats{Hedges: 1, HedgeWins: 1},
+		},
+		{
+			desc:      "error that cannot be retried stops hedging",
+			delays:    map[string]time.Duration{"0": 0},
+			permanent: map[string]bool{"0": true},
+			wantErr:   true,
+			wantSeen:  []string{"0"},
+			wantStats: HedgeStats{Failures: 1},
+		},
+		{
+			desc:      "both fail",
+			delays:    map[string]time.Duration{"0": 100 * time.Millisecond},
+			fail:      map[string]bool{"0": true, "1": true},
+			wantErr:   true,
+			wantStats: HedgeStats{Hedges: 1, Failures: 1},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &hedgeTransport{delays: test.delays, fail: test.fail, permanent: test.permanent}
+			c, err := newConn("https://hedge.kusto.windows.net", Authorization{}, &http.Client{Transport: transport}, NewClientDetails("", ""))
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			opts, err := setQueryOptions(ctx, errors.OpQuery, NewStmt("T"), nil, Hedged(50*time.Millisecond, 2))
+			require.NoError(t, err)
+
+			resp, err := c.query(ctx, "db", NewStmt("T"), opts)
+			assert.Equal(t, test.wantStats, c.hedgeStats.stats())
+			if test.wantErr {
+				require.Error(t, err)
+				if test.wantSeen != nil {
+					// No hedge is sent after the error, even once the delay has passed.
+					time.Sleep(100 * time.Millisecond)
+					transport.mu.Lock()
+					defer transport.mu.Unlock()
+					assert.Equal(t, test.wantSeen, transport.seen)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAttempt, resp.reqHeader.Get(hedgeHeader))
+
+			var got []interface{}
+			for fr := range resp.frameCh {
+				got = append(got, fr)
+			}
+			require.Len(t, got, 2)
+			assert.IsType(t, v2.DataSetHeader{}, got[0])
+			assert.IsType(t, v2.DataSetCompletion{}, got[1])
+		})
+	}
+}
+
+func TestHedgedOptionValidation(t *testing.T) {
+	t.Parallel()
+
+	_, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, Hedged(0, 2))
+	assert.Error(t, err)
+	_, err = setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, Hedged(time.Millisecond, 1))
+	assert.Error(t, err)
+}
+
+// TestHedgedReleasesWinner checks that a won hedge leaves no goroutine behind once its stream is read, even with a
+// context that is never done.
+func TestHedgedReleasesWinner(t *testing.T) {
+	transport := &hedgeTransport{delays: map[string]time.Duration{"0": time.Second, "1": 0}}
+	c, err := newConn("https://hedge.kusto.windows.net", Authorization{}, &http.Client{Transport: transport}, NewClientDetails("", ""))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	opts, err := setQueryOptions(ctx, errors.OpQuery, NewStmt("T"), nil, Hedged(10*time.Millisecond, 2))
+	require.NoError(t, err)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		resp, err := c.query(ctx, "db", NewStmt("T"), opts)
+		require.NoError(t, err)
+		for range resp.frameCh {
+		}
+	}
+	assert.Equal(t, HedgeStats{Hedges: 10, HedgeWins: 10}, c.hedgeStats.stats())
+
+	// The goroutines of the losing requests end once they see their cancellation. require.Eventually() is not used
+	// as it runs the condition in goroutines of its own.
+	after := runtime.NumGoroutine()
+	for deadline := time.Now().Add(5 * time.Second); after > before && time.Now().Before(deadline); after = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, after, before, "goroutines leaked")
+}
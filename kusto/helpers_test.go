@@ -0,0 +1,34 @@
+package kusto
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureTransport is a fake http.RoundTripper that answers every query and management command with the same body.
+type fixtureTransport struct {
+	body []byte
+}
+
+func (f fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") && !strings.HasSuffix(req.URL.Path, "/v1/rest/mgmt") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(string(f.body)))}, nil
+}
+
+// newTestClient returns a Client of endpoint, without authorization, whose requests are sent through transport.
+func newTestClient(t testing.TB, endpoint string, transport http.RoundTripper) *Client {
+	return newAuthTestClient(t, endpoint, Authorization{}, transport)
+}
+
+// newAuthTestClient is newTestClient() for a Client that authenticates with auth.
+func newAuthTestClient(t testing.TB, endpoint string, auth Authorization, transport http.RoundTripper) *Client {
+	conn, err := newConn(endpoint, auth, &http.Client{Transport: transport}, NewClientDetails("", ""))
+	require.NoError(t, err)
+	return &Client{conn: conn, endpoint: endpoint, auth: auth, http: conn.client}
+}
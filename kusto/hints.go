@@ -0,0 +1,18 @@
+package kusto
+
+// hints.go implements RowIterator.ServiceHints(), which reads the capacity hints in the headers of the response.
+
+import (
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// ServiceHints are the hints about its capacity that the service returns in the headers of a response, such as the
+// requests left before being throttled. The same hints are returned by (*errors.HttpError).ServiceHints() when a
+// call fails.
+type ServiceHints = errors.ServiceHints
+
+// ServiceHints returns the ServiceHints in the headers of the response to the query. They are zero for a
+// RowIterator that was not returned by a Client, such as one with MockRows.
+func (r *RowIterator) ServiceHints() ServiceHints {
+	return errors.ParseServiceHints(r.ResponseHeader)
+}
@@ -0,0 +1,116 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hintsTransport is a fake http.RoundTripper that answers with status and header.
+type hintsTransport struct {
+	status int
+	header http.Header
+}
+
+func (h hintsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	if h.status != http.StatusOK {
+		body = `{"error":{"code":"LimitsExceeded","message":"Request is throttled"}}`
+	}
+	return &http.Response{StatusCode: h.status, Status: http.StatusText(h.status), Header: h.header.Clone(), Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestServiceHints(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{
+		"X-Ms-Ratelimit-Remaining": {"3"},
+		"X-Ms-Ratelimit-Reset":     {"oops"},
+		"X-Ms-Workload-Group":      {"reports"},
+		"X-Ms-Throttling-Imminent": {"true"},
+		"Retry-After":              {"2"},
+	}
+
+	tests := []struct {
+		desc   string
+		status int
+		call   func(client *Client) (ServiceHints, error)
+	}{
+		{
+			desc:   "Query",
+			status: http.StatusOK,
+			call: func(client *Client) (ServiceHints, error) {
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+				if err != nil {
+					return ServiceHints{}, err
+				}
+				defer iter.Stop()
+				return iter.ServiceHints(), nil
+			},
+		},
+		{
+			desc:   "Throttled query",
+			status: http.StatusTooManyRequests,
+			call: func(client *Client) (ServiceHints, error) {
+				_, err := client.Query(context.Background(), "db", NewStmt("T"))
+				var httpErr *errors.HttpError
+				if !goErrors.As(err, &httpErr) {
+					return ServiceHints{}, err
+				}
+				assert.True(t, httpErr.IsThrottled())
+				return httpErr.ServiceHints(), nil
+			},
+		},
+		{
+			desc:   "Throttled command",
+			status: http.StatusTooManyRequests,
+			call: func(client *Client) (ServiceHints, error) {
+				_, err := client.Mgmt(context.Background(), "db", NewStmt(".show tables"))
+				var httpErr *errors.HttpError
+				if !goErrors.As(err, &httpErr) {
+					return ServiceHints{}, err
+				}
+				return httpErr.ServiceHints(), nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := hintsTransport{status: test.status, header: header}
+			client := newTestClient(t, "https://hints.kusto.windows.net", transport)
+
+			got, err := test.call(client)
+			require.NoError(t, err)
+
+			require.NotNil(t, got.RateLimitRemaining)
+			assert.Equal(t, 3, *got.RateLimitRemaining)
+			assert.Nil(t, got.RateLimitReset, "a malformed header is left nil")
+			assert.Nil(t, got.RateLimitLimit, "an absent header is left nil")
+			require.NotNil(t, got.RetryAfter)
+			assert.Equal(t, 2*time.Second, *got.RetryAfter)
+			assert.Equal(t, "reports", got.WorkloadGroup)
+			assert.True(t, got.IsThrottlingImminent)
+		})
+	}
+
+	assert.Equal(t, ServiceHints{}, (&RowIterator{}).ServiceHints())
+}
@@ -0,0 +1,201 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// defaultBatchConcurrency is the default number of files that a BatchIngestor ingests at the same time.
+const defaultBatchConcurrency = 4
+
+// BatchStoppedErr is the error of the files that a BatchIngestor did not ingest because the batch was stopped, when
+// its context was canceled or, with FailFast(), when the ingestion of another file failed.
+var BatchStoppedErr = errors.ES(errors.OpFileIngest, errors.KOther, "the file was not ingested because the batch was stopped").SetNoRetry()
+
+// BatchIngestor ingests many files with an Ingestor, several at the same time.
+type BatchIngestor struct {
+	ingestor    Ingestor
+	concurrency int
+	failFast    bool
+	onProgress  func(Progress)
+}
+
+// BatchOption is an optional argument to NewBatchIngestor().
+type BatchOption func(b *BatchIngestor)
+
+// WithConcurrency sets the number of files that are ingested at the same time. Defaults to 4.
+func WithConcurrency(n int) BatchOption {
+	return func(b *BatchIngestor) {
+		if n > 0 {
+			b.concurrency = n
+		}
+	}
+}
+
+// FailFast stops the batch when the ingestion of a file fails: the ingestions in progress are canceled and the files
+// that are left are not ingested. By default, all the files are ingested whatever the errors.
+func FailFast() BatchOption {
+	return func(b *BatchIngestor) {
+		b.failFast = true
+	}
+}
+
+// WithProgress sets a function that is called each time the ingestion of a file ends, for instance to drive a progress
+// bar. The calls are never concurrent.
+func WithProgress(f func(Progress)) BatchOption {
+	return func(b *BatchIngestor) {
+		b.onProgress = f
+	}
+}
+
+// Progress is the progress of a batch.
+type Progress struct {
+	// FilesDone is the number of files whose ingestion ended, successfully or not.
+	FilesDone int
+	// FilesTotal is the number of files of the batch.
+	FilesTotal int
+	// FilesFailed is the number of files whose ingestion failed.
+	FilesFailed int
+	// BytesUploaded is the size of the local files that were successfully ingested.
+	BytesUploaded int64
+}
+
+// FileResult is the outcome of the ingestion of a file of a batch.
+type FileResult struct {
+	// Path is the path of the file.
+	Path string
+	// Result is the result of the ingestion, nil if it failed.
+	Result *Result
+	// Err is the error of the ingestion, BatchStoppedErr if the file was not ingested because the batch was stopped.
+	Err error
+}
+
+// BatchResult holds the outcome of the ingestion of each file of a batch.
+type BatchResult struct {
+	// Files holds the outcome of each file, in the order of the paths passed to FromFiles().
+	Files []FileResult
+}
+
+// Results returns the results of the files that were successfully ingested, which can be waited with WaitAll().
+func (b *BatchResult) Results() []*Result {
+	var results []*Result
+	for _, f := range b.Files {
+		if f.Err == nil {
+			results = append(results, f.Result)
+		}
+	}
+	return results
+}
+
+// Failed returns the outcomes of the files that were not ingested.
+func (b *BatchResult) Failed() []FileResult {
+	var failed []FileResult
+	for _, f := range b.Files {
+		if f.Err != nil {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// Err returns the errors of the files that were not ingested, combined, or nil if all the files were ingested.
+func (b *BatchResult) Err() error {
+	var errs []error
+	for _, f := range b.Failed() {
+		errs = append(errs, f.Err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.GetCombinedError(errs...)
+}
+
+// NewBatchIngestor is the constructor for BatchIngestor. ingestor can be any of the clients of this package.
+func NewBatchIngestor(ingestor Ingestor, options ...BatchOption) *BatchIngestor {
+	b := &BatchIngestor{ingestor: ingestor, concurrency: defaultBatchConcurrency}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// FromFiles ingests the files at paths, local paths or blobstore URIs, with the FromFile() method of the Ingestor and
+// the same options for all of them. The error is only set if the batch was stopped, with the error of the context or,
+// with FailFast(), the first error of a file; the outcome of each file is in the BatchResult in any case.
+// This method is thread-safe.
+func (b *BatchIngestor) FromFiles(ctx context.Context, paths []string, options ...FileOption) (*BatchResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &BatchResult{Files: make([]FileResult, len(paths))}
+	progress := Progress{FilesTotal: len(paths)}
+
+	var (
+		mu       sync.Mutex
+		stopErr  error
+		wg       sync.WaitGroup
+		indexes  = make(chan int)
+		finished = func(index int, res *Result, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			result.Files[index].Result, result.Files[index].Err = res, err
+			progress.FilesDone++
+			if err != nil {
+				progress.FilesFailed++
+				if b.failFast && stopErr == nil {
+					stopErr = err
+					cancel()
+				}
+			} else if stat, statErr := os.Stat(paths[index]); statErr == nil {
+				progress.BytesUploaded += stat.Size()
+			}
+			if b.onProgress != nil {
+				b.onProgress(progress)
+			}
+		}
+	)
+
+	for index, path := range paths {
+		result.Files[index] = FileResult{Path: path, Err: BatchStoppedErr}
+	}
+
+	for w := 0; w < b.concurrency && w < len(paths); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if ctx.Err() != nil {
+					continue // The file is left as stopped.
+				}
+				res, err := b.ingestor.FromFile(ctx, paths[index], options...)
+				finished(index, res, err)
+			}
+		}()
+	}
+
+send:
+	for index := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if stopErr != nil {
+		return result, stopErr
+	}
+	if err := ctx.Err(); err != nil && len(result.Failed()) > 0 {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIngestor is an Ingestor whose FromFile() and FromReader() call onFromFile and onFromReader.
+type fakeIngestor struct {
+	onFromFile   func(ctx context.Context, fPath string) (*Result, error)
+	onFromReader func(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error)
+}
+
+func (f fakeIngestor) FromFile(ctx context.Context, fPath string, _ ...FileOption) (*Result, error) {
+	return f.onFromFile(ctx, fPath)
+}
+
+func (f fakeIngestor) FromReader(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+	if f.onFromReader == nil {
+		return nil, goErrors.New("not implemented")
+	}
+	return f.onFromReader(ctx, reader, options...)
+}
+
+func (f fakeIngestor) Close() error {
+	return nil
+}
+
+func TestBatchIngestor(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a.csv", "b.csv", "c.csv", "fail.csv", "e.csv", "f.csv"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("1,2,3\n"), 0600))
+		paths = append(paths, path)
+	}
+	failErr := goErrors.New("failed")
+
+	var running, maxRunning int32
+	ingestor := fakeIngestor{onFromFile: func(ctx context.Context, fPath string) (*Result, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		if filepath.Base(fPath) == "fail.csv" {
+			return nil, failErr
+		}
+		return newResult(), nil
+	}}
+
+	var progress []Progress
+	batch := NewBatchIngestor(ingestor, WithConcurrency(2), WithProgress(func(p Progress) {
+		progress = append(progress, p)
+	}))
+
+	result, err := batch.FromFiles(context.Background(), paths)
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+	require.Len(t, result.Files, len(paths))
+	for i, f := range result.Files {
+		assert.Equal(t, paths[i], f.Path)
+	}
+	assert.Len(t, result.Results(), 5)
+	require.Len(t, result.Failed(), 1)
+	assert.Equal(t, paths[3], result.Failed()[0].Path)
+	assert.ErrorIs(t, result.Failed()[0].Err, failErr)
+	assert.Error(t, result.Err())
+
+	require.Len(t, progress, len(paths))
+	assert.Equal(t, Progress{FilesDone: 6, FilesTotal: 6, FilesFailed: 1, BytesUploaded: 5 * 6}, progress[len(progress)-1])
+}
+
+func TestBatchIngestorFailFast(t *testing.T) {
+	t.Parallel()
+
+	failErr := goErrors.New("failed")
+	ingestor := fakeIngestor{onFromFile: func(ctx context.Context, fPath string) (*Result, error) {
+		if fPath == "fail" {
+			return nil, failErr
+		}
+		// The other files only end when the batch is stopped.
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	paths := []string{"slow", "fail", "never1", "never2"}
+	result, err := NewBatchIngestor(ingestor, WithConcurrency(2), FailFast()).FromFiles(context.Background(), paths)
+	assert.ErrorIs(t, err, failErr)
+
+	assert.ErrorIs(t, result.Files[0].Err, context.Canceled)
+	assert.ErrorIs(t, result.Files[1].Err, failErr)
+	stopped := 0
+	for _, f := range result.Files[2:] {
+		if f.Err == BatchStoppedErr {
+			stopped++
+		}
+	}
+	assert.Greater(t, stopped, 0)
+	assert.Empty(t, result.Results())
+}
+
+func TestBatchIngestorCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var once sync.Once
+	ingestor := fakeIngestor{onFromFile: func(ctx context.Context, fPath string) (*Result, error) {
+		once.Do(cancel)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	paths := []string{"a", "b", "c", "d"}
+	result, err := NewBatchIngestor(ingestor, WithConcurrency(1)).FromFiles(ctx, paths)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, result.Files[0].Err, context.Canceled)
+	for _, f := range result.Files[1:] {
+		assert.Equal(t, BatchStoppedErr, f.Err)
+	}
+}
+
+func TestBatchIngestorEmpty(t *testing.T) {
+	t.Parallel()
+
+	result, err := NewBatchIngestor(fakeIngestor{}).FromFiles(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Files)
+	assert.NoError(t, result.Err())
+}
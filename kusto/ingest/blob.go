@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// storageSuffixes are the suffixes of the hosts of Azure Blob Storage, by login endpoint of the cloud of the cluster.
+var storageSuffixes = map[string][]string{
+	"https://login.microsoftonline.com": {".blob.core.windows.net", ".blob.storage.azure.net"},
+	"https://login.chinacloudapi.cn":    {".blob.core.chinacloudapi.cn"},
+	"https://login.microsoftonline.us":  {".blob.core.usgovcloudapi.net"},
+}
+
+// FromBlob queues the ingestion of a blob that is already in Azure Blob Storage, without uploading it again. The
+// blob is the blob blobPath of the container of client, and the URL sent to the service is the one of client, so it
+// must either hold a SAS that lets the service read the blob, or the ManagedIdentity() option must be passed.
+// Unless the RawDataSize() option is passed, client is also used to read the size of the blob.
+// The host of the blob must be a host of Azure Blob Storage in the cloud of the cluster, when the cloud is known.
+// This method is thread-safe.
+func (i *Ingestion) FromBlob(ctx context.Context, client *azblob.Client, container, blobPath string, options ...FileOption) (*Result, error) {
+	if client == nil || container == "" || blobPath == "" {
+		return nil, errors.ES(errors.OpFileIngest, errors.KClientArgs, "FromBlob() requires a client, a container and a blob path").SetNoRetry()
+	}
+
+	blobClient := client.ServiceClient().NewContainerClient(container).NewBlobClient(blobPath)
+	blobURL := blobClient.URL()
+	if err := i.checkStorageHost(blobURL); err != nil {
+		return nil, err
+	}
+
+	result, props, err := i.prepForIngestion(ctx, options, i.newProp(), FromBlob)
+	if err != nil {
+		return nil, err
+	}
+	if err := queued.CompleteFormatFromFileName(&props, blobPath); err != nil {
+		return nil, err
+	}
+
+	size := props.Ingestion.RawDataSize
+	if size == 0 {
+		getSize := i.blobSize
+		if getSize == nil {
+			getSize = blobSize
+		}
+		if size, err = getSize(ctx, blobClient); err != nil {
+			return nil, errors.ES(errors.OpFileIngest, errors.KBlobstore, "could not read the size of the blob %q: %s", blobPath, err)
+		}
+	}
+
+	result.record.IngestionSourcePath = blobURL
+	if err := i.fs.Blob(ctx, blobURL, size, props); err != nil {
+		return nil, err
+	}
+
+	result.putQueued(i.mgr)
+	return result, nil
+}
+
+// blobSize returns the size of the blob of client from its properties.
+func blobSize(ctx context.Context, client *blob.Client) (int64, error) {
+	props, err := client.GetProperties(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	if props.ContentLength == nil {
+		return 0, fmt.Errorf("the properties of the blob have no content length")
+	}
+	return *props.ContentLength, nil
+}
+
+// checkStorageHost checks that the host of blobURL is a host of Azure Blob Storage in the cloud of the cluster. It
+// does not check anything if the cloud is not a known one.
+func (i *Ingestion) checkStorageHost(blobURL string) error {
+	getCloudInfo := i.cloudInfo
+	if getCloudInfo == nil {
+		getCloudInfo = func() (kusto.CloudInfo, error) {
+			return kusto.GetMetadata(i.client.Endpoint(), i.client.HttpClient())
+		}
+	}
+	info, err := getCloudInfo()
+	if err != nil {
+		return err
+	}
+
+	suffixes, ok := storageSuffixes[strings.TrimSuffix(strings.ToLower(info.LoginEndpoint), "/")]
+	if !ok {
+		return nil
+	}
+
+	u, err := url.Parse(blobURL)
+	if err != nil {
+		return errors.ES(errors.OpFileIngest, errors.KClientArgs, "could not parse the blob URL: %s", err).SetNoRetry()
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return errors.ES(
+		errors.OpFileIngest,
+		errors.KClientArgs,
+		"the host %q of the blob is not an Azure Blob Storage host of the cloud of the cluster, which end with one of %v",
+		host, suffixes,
+	).SetNoRetry()
+}
@@ -0,0 +1,178 @@
+package ingest
+
+import (
+	"context"
+	goErrors "errors"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromBlob(t *testing.T) {
+	t.Parallel()
+
+	const publicLogin = "https://login.microsoftonline.com"
+
+	tests := []struct {
+		name         string
+		serviceURL   string
+		blobPath     string
+		login        string
+		cloudErr     error
+		sizeErr      error
+		options      []FileOption
+		wantErr      bool
+		wantKind     errors.Kind
+		wantPath     string
+		wantIdentity string
+		wantSize     int64
+		wantFormat   properties.DataFormat
+		wantNoRetry  bool
+	}{
+		{
+			name:       "SAS",
+			serviceURL: "https://account.blob.core.windows.net/?sv=sas",
+			blobPath:   "dir/data.json",
+			login:      publicLogin,
+			wantPath:   "https://account.blob.core.windows.net/container/dir%2Fdata.json?sv=sas",
+			wantSize:   42,
+			wantFormat: JSON,
+		},
+		{
+			name:         "ManagedIdentity",
+			serviceURL:   "https://account.blob.core.windows.net/",
+			blobPath:     "data.csv.gz",
+			login:        publicLogin,
+			options:      []FileOption{ManagedIdentity("system")},
+			wantPath:     "https://account.blob.core.windows.net/container/data.csv.gz",
+			wantIdentity: "system",
+			wantSize:     42,
+			wantFormat:   CSV,
+		},
+		{
+			name:       "RawDataSize",
+			serviceURL: "https://account.blob.core.windows.net/",
+			blobPath:   "data.csv",
+			login:      publicLogin,
+			options:    []FileOption{RawDataSize(7)},
+			sizeErr:    goErrors.New("must not be called"),
+			wantPath:   "https://account.blob.core.windows.net/container/data.csv",
+			wantSize:   7,
+			wantFormat: CSV,
+		},
+		{
+			name:       "UnknownCloud",
+			serviceURL: "https://storage.example.com/",
+			blobPath:   "data.csv",
+			login:      "https://login.example.com",
+			wantPath:   "https://storage.example.com/container/data.csv",
+			wantSize:   42,
+			wantFormat: CSV,
+		},
+		{
+			name:        "WrongCloud",
+			serviceURL:  "https://account.blob.core.chinacloudapi.cn/",
+			blobPath:    "data.csv",
+			login:       publicLogin,
+			wantErr:     true,
+			wantKind:    errors.KClientArgs,
+			wantNoRetry: true,
+		},
+		{
+			name:        "UnknownFormat",
+			serviceURL:  "https://account.blob.core.windows.net/",
+			blobPath:    "data.unknown",
+			login:       publicLogin,
+			wantErr:     true,
+			wantKind:    errors.KClientArgs,
+			wantNoRetry: true,
+		},
+		{
+			name:        "EmptyManagedIdentity",
+			serviceURL:  "https://account.blob.core.windows.net/",
+			blobPath:    "data.csv",
+			login:       publicLogin,
+			options:     []FileOption{ManagedIdentity("")},
+			wantErr:     true,
+			wantKind:    errors.KClientArgs,
+			wantNoRetry: true,
+		},
+		{
+			name:       "SizeError",
+			serviceURL: "https://account.blob.core.windows.net/",
+			blobPath:   "data.csv",
+			login:      publicLogin,
+			sizeErr:    goErrors.New("forbidden"),
+			wantErr:    true,
+			wantKind:   errors.KBlobstore,
+		},
+		{
+			name:       "CloudInfoError",
+			serviceURL: "https://account.blob.core.windows.net/",
+			blobPath:   "data.csv",
+			cloudErr:   goErrors.New("unreachable"),
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := azblob.NewClientWithNoCredential(test.serviceURL, nil)
+			require.NoError(t, err)
+
+			ingestion, err := New(mockClient{endpoint: "https://test.kusto.windows.net", auth: kusto.Authorization{}}, "db", "table")
+			require.NoError(t, err)
+
+			var (
+				gotPath  string
+				gotSize  int64
+				gotProps properties.All
+			)
+			ingestion.fs = resources.FsMock{
+				OnBlob: func(ctx context.Context, from string, fileSize int64, props properties.All) error {
+					gotPath, gotSize, gotProps = from, fileSize, props
+					return nil
+				},
+			}
+			ingestion.cloudInfo = func() (kusto.CloudInfo, error) {
+				return kusto.CloudInfo{LoginEndpoint: test.login}, test.cloudErr
+			}
+			ingestion.blobSize = func(ctx context.Context, client *blob.Client) (int64, error) {
+				if test.sizeErr != nil {
+					return 0, test.sizeErr
+				}
+				return 42, nil
+			}
+
+			_, err = ingestion.FromBlob(context.Background(), client, "container", test.blobPath, test.options...)
+			if test.wantErr {
+				require.Error(t, err)
+				if test.wantKind != errors.KOther {
+					var e *errors.Error
+					require.True(t, goErrors.As(err, &e))
+					assert.Equal(t, test.wantKind, e.Kind)
+				}
+				if test.wantNoRetry {
+					assert.False(t, errors.Retry(err))
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantPath, gotPath)
+			assert.Equal(t, test.wantSize, gotSize)
+			assert.Equal(t, test.wantFormat, gotProps.Ingestion.Additional.Format)
+			assert.Equal(t, test.wantIdentity, gotProps.Source.ManagedIdentity)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultChannelBatchSize is the default size of the MultiJSON batches sent by FromChannel(), before compression.
+	defaultChannelBatchSize = 1 * mb
+	// defaultChannelFlushInterval is the default longest time a record waits in a batch of FromChannel().
+	defaultChannelFlushInterval = 5 * time.Second
+)
+
+// channelConfig is the configuration of FromChannel().
+type channelConfig struct {
+	batchSize     int
+	flushInterval time.Duration
+	fileOptions   []FileOption
+	newBackoff    func() backoff.BackOff
+}
+
+// ChannelOption is an optional argument to FromChannel().
+type ChannelOption func(c *channelConfig)
+
+// ChannelBatchSize sets the size in bytes of the MultiJSON batches sent by FromChannel(), before compression. A batch
+// is sent as soon as it reaches this size. Defaults to 1 MB, it should stay well under the 4 MB limit of streaming
+// ingestion once compressed.
+func ChannelBatchSize(size int) ChannelOption {
+	return func(c *channelConfig) {
+		if size > 0 {
+			c.batchSize = size
+		}
+	}
+}
+
+// ChannelFlushInterval sets the longest time a record waits in a batch of FromChannel() before the batch is sent.
+// Defaults to 5 seconds.
+func ChannelFlushInterval(d time.Duration) ChannelOption {
+	return func(c *channelConfig) {
+		if d > 0 {
+			c.flushInterval = d
+		}
+	}
+}
+
+// ChannelFileOptions sets options passed with each batch of FromChannel(), such as IngestionMappingRef(). The format of
+// the batches is always MultiJSON.
+func ChannelFileOptions(options ...FileOption) ChannelOption {
+	return func(c *channelConfig) {
+		c.fileOptions = append(c.fileOptions, options...)
+	}
+}
+
+// channelBackoff sets the backoff between the attempts to send a batch, for tests.
+func channelBackoff(newBackoff func() backoff.BackOff) ChannelOption {
+	return func(c *channelConfig) {
+		c.newBackoff = newBackoff
+	}
+}
+
+// FromChannel ingests the records received from ch, which must be structs, with the FromReader() method of ingestor,
+// usually a *Streaming or a *Managed client. The records are marshalled to MultiJSON, with the field names that
+// table.Row.ToStruct() uses: the `kusto` tag of a field, or else its name, skipping the fields tagged "-". They are
+// sent in batches, compressed with gzip by the client, when a batch reaches its size or when its oldest record waited
+// for the flush interval. A batch that fails with a retryable error is sent again with a backoff.
+//
+// Records are only received while no batch is sent, so a slow ingestion blocks the senders of ch instead of buffering
+// the records. FromChannel() returns once ch is closed and the last batch is sent, or with an error when ctx is
+// canceled or a batch cannot be sent, in which case the records of the batch are lost and ch is no longer read.
+func FromChannel[T any](ctx context.Context, ingestor Ingestor, ch <-chan T, options ...ChannelOption) error {
+	enc, err := newRecordEncoder(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return err
+	}
+
+	cfg := channelConfig{
+		batchSize:     defaultChannelBatchSize,
+		flushInterval: defaultChannelFlushInterval,
+		newBackoff: func() backoff.BackOff {
+			exp := backoff.NewExponentialBackOff()
+			exp.InitialInterval = defaultInitialInterval
+			exp.Multiplier = defaultMultiplier
+			return backoff.WithMaxRetries(exp, retryCount)
+		},
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+	// The format is set last, so that the mapping options do not override it.
+	fileOptions := append(cfg.fileOptions, FileFormat(MultiJSON))
+
+	var (
+		batch  bytes.Buffer
+		timer  = time.NewTimer(cfg.flushInterval)
+		flushC <-chan time.Time
+	)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() error {
+		timer.Stop()
+		flushC = nil
+		if batch.Len() == 0 {
+			return nil
+		}
+		defer batch.Reset()
+		return sendBatch(ctx, ingestor, batch.Bytes(), fileOptions, cfg.newBackoff())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-flushC:
+			if err := flush(); err != nil {
+				return err
+			}
+		case record, ok := <-ch:
+			if !ok {
+				return flush()
+			}
+
+			line, err := enc.encode(reflect.ValueOf(record))
+			if err != nil {
+				return err
+			}
+			if batch.Len() > 0 && batch.Len()+len(line) > cfg.batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if batch.Len() == 0 {
+				timer.Reset(cfg.flushInterval)
+				flushC = timer.C
+			}
+			batch.Write(line)
+
+			if batch.Len() >= cfg.batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// sendBatch sends batch with ingestor, and sends it again with off while it fails with a retryable error.
+func sendBatch(ctx context.Context, ingestor Ingestor, batch []byte, options []FileOption, off backoff.BackOff) error {
+	return backoff.Retry(func() error {
+		_, err := ingestor.FromReader(ctx, bytes.NewReader(batch), options...)
+		if err != nil && !errors.Retry(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(off, ctx))
+}
+
+// recordEncoder marshals structs to JSON lines, with the field names that table.Row.ToStruct() uses.
+type recordEncoder struct {
+	fields []recordField
+}
+
+// recordField is a field of a struct that is marshalled.
+type recordField struct {
+	index int
+	name  string
+}
+
+// newRecordEncoder returns a recordEncoder of the struct type t.
+func newRecordEncoder(t reflect.Type) (recordEncoder, error) {
+	if t.Kind() != reflect.Struct {
+		return recordEncoder{}, errors.ES(errors.OpIngestStream, errors.KClientArgs, "type %s is not a struct, only structs can be ingested from a channel", t).SetNoRetry()
+	}
+
+	enc := recordEncoder{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("kusto"); strings.TrimSpace(tag) != "" {
+			name = tag
+		}
+		if name == "-" {
+			continue
+		}
+		enc.fields = append(enc.fields, recordField{index: i, name: name})
+	}
+	return enc, nil
+}
+
+// encode marshals the struct v to a JSON object, followed by a new line.
+func (e recordEncoder) encode(v reflect.Value) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, field := range e.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+
+		b, err := json.Marshal(jsonValue(v.Field(field.index)))
+		if err != nil {
+			return nil, errors.ES(errors.OpIngestStream, errors.KClientArgs, "could not marshal the field %s: %s", field.name, err).SetNoRetry()
+		}
+		buf.Write(b)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+// jsonValue returns the value to marshal for the field v. It is the reverse of the conversions of
+// table.Row.ToStruct(): the value.Kusto types are null when not valid, and the datetime, timespan and guid values are
+// the strings that Kusto parses.
+func jsonValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch x := v.Interface().(type) {
+	case value.Bool:
+		return validOrNil(x.Valid, x.Value)
+	case value.Int:
+		return validOrNil(x.Valid, x.Value)
+	case value.Long:
+		return validOrNil(x.Valid, x.Value)
+	case value.Real:
+		return validOrNil(x.Valid, x.Value)
+	case value.String:
+		return validOrNil(x.Valid, x.Value)
+	case value.Decimal:
+		return validOrNil(x.Valid, json.Number(x.Value))
+	case value.Dynamic:
+		return validOrNil(x.Valid && json.Valid(x.Value), json.RawMessage(x.Value))
+	case value.DateTime:
+		return validOrNil(x.Valid, x.Marshal())
+	case value.Timespan:
+		return validOrNil(x.Valid, x.Marshal())
+	case value.GUID:
+		return validOrNil(x.Valid, x.Value.String())
+	case time.Time:
+		return value.DateTime{Value: x, Valid: true}.Marshal()
+	case time.Duration:
+		return value.Timespan{Value: x, Valid: true}.Marshal()
+	case uuid.UUID:
+		return x.String()
+	}
+	return v.Interface()
+}
+
+// validOrNil returns v if valid, or else nil.
+func validOrNil(valid bool, v interface{}) interface{} {
+	if !valid {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,256 @@
+package ingest
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type channelRecord struct {
+	ID       int64 `kusto:"Id"`
+	Name     string
+	Skipped  string `kusto:"-"`
+	private  string
+	Count    value.Long
+	Missing  value.Long
+	Payload  value.Dynamic
+	Price    value.Decimal
+	When     time.Time
+	Duration time.Duration
+	GUID     uuid.UUID
+	Pointer  *string
+}
+
+func TestRecordEncoder(t *testing.T) {
+	t.Parallel()
+
+	enc, err := newRecordEncoder(reflect.TypeOf(channelRecord{}))
+	require.NoError(t, err)
+
+	rec := channelRecord{
+		ID:       1,
+		Name:     "a \"name\"",
+		Skipped:  "skipped",
+		private:  "private",
+		Count:    value.NewLong(2),
+		Payload:  value.NewDynamic([]byte(`{"a":[1,2]}`)),
+		Price:    value.NewDecimal("1.50"),
+		When:     time.Date(2024, 1, 2, 3, 4, 5, 600, time.FixedZone("X", 3600)),
+		Duration: 26*time.Hour + 3*time.Second,
+		GUID:     uuid.MustParse("11111111-2222-3333-4444-555555555555"),
+	}
+	got, err := enc.encode(reflect.ValueOf(rec))
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"Id":1,"Name":"a \"name\"","Count":2,"Missing":null,"Payload":{"a":[1,2]},"Price":1.50,`+
+			`"When":"2024-01-02T02:04:05.0000006Z","Duration":"1.02:00:03","GUID":"11111111-2222-3333-4444-555555555555",`+
+			`"Pointer":null}`+"\n",
+		string(got),
+	)
+
+	_, err = newRecordEncoder(reflect.TypeOf(""))
+	assert.Error(t, err)
+}
+
+// recordingIngestor records the batches sent with FromReader().
+type recordingIngestor struct {
+	mu      sync.Mutex
+	batches []string
+}
+
+func (r *recordingIngestor) ingestor(t *testing.T) fakeIngestor {
+	return fakeIngestor{onFromReader: func(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+		props := properties.All{}
+		for _, option := range options {
+			assert.NoError(t, option.Run(&props, StreamingClient, FromReader))
+		}
+		assert.Equal(t, MultiJSON, props.Ingestion.Additional.Format)
+		assert.Equal(t, "mapping", props.Ingestion.Additional.IngestionMappingRef)
+
+		b, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.batches = append(r.batches, string(b))
+		return newResult(), nil
+	}}
+}
+
+func (r *recordingIngestor) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.batches...)
+}
+
+type smallRecord struct {
+	N int
+}
+
+func TestFromChannelBatchSize(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordingIngestor{}
+	ch := make(chan smallRecord, 10)
+	for i := 0; i < 5; i++ {
+		ch <- smallRecord{N: i}
+	}
+	close(ch)
+
+	// Each record is 8 bytes, so a batch holds 2 records.
+	err := FromChannel(context.Background(), rec.ingestor(t), ch, ChannelBatchSize(20), ChannelFlushInterval(time.Hour),
+		ChannelFileOptions(IngestionMappingRef("mapping", JSON)))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"{\"N\":0}\n{\"N\":1}\n",
+		"{\"N\":2}\n{\"N\":3}\n",
+		"{\"N\":4}\n",
+	}, rec.sent())
+}
+
+func TestFromChannelFlushInterval(t *testing.T) {
+	t.Parallel()
+
+	rec := &recordingIngestor{}
+	ch := make(chan smallRecord)
+	done := make(chan error)
+	go func() {
+		done <- FromChannel(context.Background(), rec.ingestor(t), ch, ChannelFlushInterval(10*time.Millisecond),
+			ChannelFileOptions(IngestionMappingRef("mapping", JSON)))
+	}()
+
+	ch <- smallRecord{N: 1}
+	require.Eventually(t, func() bool { return len(rec.sent()) == 1 }, 5*time.Second, time.Millisecond)
+
+	close(ch)
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"{\"N\":1}\n"}, rec.sent())
+}
+
+func TestFromChannelBackpressure(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	ingestor := fakeIngestor{onFromReader: func(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+		<-release
+		return newResult(), nil
+	}}
+
+	ch := make(chan smallRecord)
+	done := make(chan error)
+	go func() {
+		done <- FromChannel(context.Background(), ingestor, ch, ChannelBatchSize(1))
+	}()
+
+	// The first record is sent right away, and blocks the ingestion.
+	ch <- smallRecord{N: 1}
+	select {
+	case ch <- smallRecord{N: 2}:
+		assert.Fail(t, "the channel was read while a batch was sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	ch <- smallRecord{N: 2}
+	close(ch)
+	require.NoError(t, <-done)
+}
+
+func TestFromChannelRetries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "Retryable",
+			errs:      []error{errors.ES(errors.OpIngestStream, errors.KTimeout, "timeout"), nil},
+			wantCalls: 2,
+		},
+		{
+			name:      "Permanent",
+			errs:      []error{errors.ES(errors.OpIngestStream, errors.KClientArgs, "bad").SetNoRetry()},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name: "TooManyRetries",
+			errs: []error{
+				errors.ES(errors.OpIngestStream, errors.KTimeout, "timeout"),
+				errors.ES(errors.OpIngestStream, errors.KTimeout, "timeout"),
+				errors.ES(errors.OpIngestStream, errors.KTimeout, "timeout"),
+			},
+			wantCalls: 3,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			ingestor := fakeIngestor{onFromReader: func(ctx context.Context, reader io.Reader, options ...FileOption) (*Result, error) {
+				b, err := io.ReadAll(reader)
+				assert.NoError(t, err)
+				assert.Equal(t, "{\"N\":1}\n", string(b))
+
+				err = test.errs[calls]
+				calls++
+				if err != nil {
+					return nil, err
+				}
+				return newResult(), nil
+			}}
+
+			ch := make(chan smallRecord, 1)
+			ch <- smallRecord{N: 1}
+			close(ch)
+			err := FromChannel(context.Background(), ingestor, ch, channelBackoff(func() backoff.BackOff {
+				return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 2)
+			}))
+
+			assert.Equal(t, test.wantCalls, calls)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFromChannelCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := FromChannel(ctx, fakeIngestor{}, make(chan smallRecord))
+	assert.True(t, goErrors.Is(err, context.Canceled))
+}
+
+func TestFromChannelNotStruct(t *testing.T) {
+	t.Parallel()
+
+	err := FromChannel(context.Background(), fakeIngestor{}, make(chan string))
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "not a struct"))
+}
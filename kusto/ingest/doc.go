@@ -6,12 +6,21 @@ For more information on Kusto Data Ingestion, please see: https://docs.microsoft
 # Create a client
 
 Creating a client simply requires a *kusto.Client, the name of the database and the name of the table to be ingested into.
+The clients accept a QueryClient, which *kusto.Client implements, so that a fake can be passed in tests.
 
 	in, err := ingest.New(kustoClient, "database", "table")
 	if err != nil {
 		panic("add error handling")
 	}
 
+The queued clients fetch the ingestion resources, such as the SAS of the temporary storage, and the auth context on first
+use, and refresh them in the background every hour, so that long-running clients keep working when they rotate. The
+interval is set with the WithResourceRefreshInterval() option.
+
+The ingestion resources hold several blob containers and queues, which are used in round-robin. One that fails is only
+used after the others for a minute, and the upload of a file or the enqueue of an ingestion is tried again with the next
+one. ResourceStats() returns the numbers of successes and failures of each of them.
+
 # Ingestion from a local file
 
 Ingesting a local file requires simply passing the path to the file to be ingested:
@@ -23,6 +32,11 @@ Ingesting a local file requires simply passing the path to the file to be ingest
 FromFile() will accept Unix path names on Unix platforms and Windows path names on Windows platforms.
 The file will not be deleted after upload (there is an option that will allow that though).
 
+The format of the file is detected from its extension, such as .csv, .json, .multijson, .parquet or .avro.gz, unless
+it is set with the FileFormat() option. An error wrapping UnknownFormatErr is returned if the extension is unknown. Files
+are compressed with gzip before the upload, except those that are already compressed and those in the binary formats
+AVRO, Parquet and ORC.
+
 # Ingestion from an Azure Blob Storage file
 
 This package will also accept ingestion from an Azure Blob Storage file:
@@ -33,6 +47,35 @@ This package will also accept ingestion from an Azure Blob Storage file:
 
 This will ingest a file from Azure Blob Storage. We only support https:// paths and your domain name may differ than what is here.
 
+A blob can also be ingested from an *azblob.Client, without it being uploaded again. The service reads the blob with the
+SAS of the URL of the client, or with a managed identity of the cluster when the ManagedIdentity() option is passed:
+
+	if _, err := in.FromBlob(ctx, blobClient, "container", "path/to/data.csv", ingest.ManagedIdentity("system")); err != nil {
+		panic("add error handling")
+	}
+
+FromBlob() reads the size of the blob with the client, unless it is passed with the RawDataSize() option, and returns an
+error if the host of the blob is not an Azure Blob Storage host of the cloud of the cluster.
+
+# Ingestion of many files
+
+A BatchIngestor ingests many files with any of the clients, several at the same time, and returns the outcome of each of
+them in a BatchResult:
+
+	batch := ingest.NewBatchIngestor(in, ingest.WithConcurrency(8), ingest.WithProgress(func(p ingest.Progress) {
+		fmt.Printf("%d/%d files, %d bytes\n", p.FilesDone, p.FilesTotal, p.BytesUploaded)
+	}))
+
+	result, err := batch.FromFiles(ctx, paths, ingest.FileFormat(ingest.CSV))
+	if err != nil {
+		// The batch was stopped, the files that were not ingested have the BatchStoppedErr error.
+	}
+	for _, failed := range result.Failed() {
+		// inspect failed.Path and failed.Err
+	}
+
+By default all the files are ingested whatever the errors, and the FailFast() option stops the batch on the first one.
+
 # Ingestion from an io.Reader
 
 Sometimes you want to ingest a stream of data that you have in memory without writing to disk.  You can do this simply by chunking the
@@ -57,14 +100,47 @@ data via an io.Reader.
 It is important to remember that FromReader() will terminate when it receives an io.EOF from the io.Reader.  Use io.Readers that won't
 return io.EOF until the io.Writer is closed (such as io.Pipe).
 
+FromReader() uploads the data to Blob Storage in blocks as it is read, with the buffers set by WithStaticBuffer(). Data that
+is already compressed with gzip or zip is detected from its first bytes and uploaded as is. The size of the uncompressed
+data is sent with the ingestion, counted while reading or, for compressed data, provided with the RawDataSize() option.
+
 # Ingestion from a Stream
 
-Instestion from a stream commits blocks of fully formed data encodes (JSON, AVRO, ...) into Kusto:
+Streaming ingestion sends fully formed data encodes (CSV, JSON, MultiJSON, AVRO, ...) directly to the engine of the cluster,
+through the /v1/rest/ingest/ API, with the authorization and client details of the *kusto.Client:
 
-	if err := in.Stream(ctx , jsonEncodedData, ingest.JSON, "mappingName"); err != nil {
+	streaming, err := ingest.NewStreaming(kustoClient, "database", "table")
+	if err != nil {
+		panic("add error handling")
+	}
+
+	_, err = streaming.FromReader(ctx, jsonEncodedData, ingest.FileFormat(ingest.JSON), ingest.IngestionMappingRef("mappingName", ingest.JSON))
+	if err != nil {
 		panic("add error handling")
 	}
 
+The data is compressed with gzip, unless the DontCompress() option is passed, and must not be larger than 4 MB once
+compressed, otherwise StreamingSizeLimitErr is returned. NewManaged() returns a client that falls back to queued ingestion
+for larger data.
+
+# Ingestion from a channel of structs
+
+FromChannel() marshals the structs received from a channel to MultiJSON, with the field names that table.Row.ToStruct()
+uses, and ingests them in batches with a Streaming or Managed client. A batch is sent when it reaches the size set with
+ChannelBatchSize(), or when its oldest record waited for the interval set with ChannelFlushInterval():
+
+	records := make(chan Event, 100)
+	go produce(records) // Closes records when done.
+
+	err := ingest.FromChannel(ctx, streaming, records, ingest.ChannelFlushInterval(time.Second),
+		ingest.ChannelFileOptions(ingest.IngestionMappingRef("mappingName", ingest.JSON)))
+	if err != nil {
+		panic("add error handling")
+	}
+
+The channel is not read while a batch is sent, so when the ingestion is slower than the producers, they block on the
+channel instead of the records piling up in memory.
+
 # Ingestion with Status Reporting
 
 You can use Kusto Go SDK to get table-based status reporting of ingestion operations.
@@ -89,7 +165,18 @@ This feature is not suitable for users running ingestion at high rates, and may
 			// inspect the failure
 			// statusCode, _ := ingest.GetIngestionStatus(err)
 			// failureStatus, _ := ingest.GetIngestionFailureStatus(err)
+			// errorCode, _ := ingest.GetErrorCode(err)
+			// details, _ := ingest.GetErrorDetails(err)
+			// fromUpdatePolicy := ingest.OriginatesFromUpdatePolicy(err)
 		}
 	}
+
+When several sources are ingested, WaitAll() waits for all their results and returns the number of ingestions by
+status, with the errors of the ones that did not succeed:
+
+	statuses := ingest.WaitAll(ctx, results...)
+	if !statuses.Succeeded() {
+		// inspect statuses.Failures
+	}
 */
 package ingest
@@ -7,6 +7,7 @@ import (
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
 	"github.com/cenkalti/backoff/v4"
 )
 
@@ -132,6 +133,24 @@ func DontCompress() FileOption {
 	}
 }
 
+// RawDataSize provides the size of the uncompressed data, in bytes, to send in the ingestion message so that the
+// service can prioritize it. The size of the data read by FromReader() is otherwise counted by the client, which is not
+// possible when the data is already compressed.
+func RawDataSize(size int64) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if size <= 0 {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "RawDataSize() option must be positive, was %d", size).SetNoRetry()
+			}
+			p.Ingestion.RawDataSize = size
+			return nil
+		},
+		clientScopes: QueuedClient | ManagedClient,
+		sourceScope:  FromReader | FromBlob,
+		name:         "RawDataSize",
+	}
+}
+
 func backOff(off *backoff.ExponentialBackOff) FileOption {
 	return option{
 		run: func(p *properties.All) error {
@@ -157,6 +176,10 @@ func FlushImmediately() FileOption {
 	}
 }
 
+// UnknownFormatErr is wrapped in the error returned by FromFile() when the FileFormat() option is not passed and the
+// format cannot be detected from the extension of the file, which can be checked with errors.Is().
+var UnknownFormatErr = queued.UnknownFormatErr
+
 // DataFormat indicates what type of encoding format was used for source data.
 // Not all options can be used in every method.
 type DataFormat = properties.DataFormat
@@ -206,9 +229,17 @@ const (
 	SingleJSON DataFormat = properties.SingleJSON
 )
 
+// errMappingAndMappingRef is returned when both the IngestionMapping() and IngestionMappingRef() options are passed.
+var errMappingAndMappingRef = errors.ES(
+	errors.OpUnknown,
+	errors.KClientArgs,
+	"IngestionMapping() and IngestionMappingRef() options cannot be used together",
+).SetNoRetry()
+
 // IngestionMapping provides runtime mapping of the data being imported to the fields in the table.
 // "ref" will be JSON encoded, so it can be any type that can be JSON marshalled. If you pass a string
-// or []byte, it will be interpreted as already being JSON encoded.
+// or []byte, it will be interpreted as already being JSON encoded. If you pass a []ColumnMapping, each column mapping
+// is checked to have the fields required by mappingKind.
 // mappingKind can only be: CSV, JSON, AVRO, Parquet or ORC.
 // The mappingKind parameter will also automatically set the FileFormat option.
 func IngestionMapping(mapping interface{}, mappingKind DataFormat) FileOption {
@@ -221,6 +252,9 @@ func IngestionMapping(mapping interface{}, mappingKind DataFormat) FileOption {
 					"IngestionMapping() option does not support EncodingType %v", mappingKind,
 				).SetNoRetry()
 			}
+			if p.Ingestion.Additional.IngestionMappingRef != "" {
+				return errMappingAndMappingRef
+			}
 
 			var j string
 			switch v := mapping.(type) {
@@ -228,6 +262,11 @@ func IngestionMapping(mapping interface{}, mappingKind DataFormat) FileOption {
 				j = v
 			case []byte:
 				j = string(v)
+			case []ColumnMapping:
+				var err error
+				if j, err = marshalColumnMappings(v, mappingKind); err != nil {
+					return err
+				}
 			default:
 				b, err := json.Marshal(mapping)
 				if err != nil {
@@ -262,6 +301,9 @@ func IngestionMappingRef(refName string, mappingKind DataFormat) FileOption {
 			if !mappingKind.IsValidMappingKind() {
 				return errors.ES(errors.OpUnknown, errors.KClientArgs, "IngestionMappingRef() option does not support EncodingType %v", mappingKind).SetNoRetry()
 			}
+			if p.Ingestion.Additional.IngestionMapping != "" {
+				return errMappingAndMappingRef
+			}
 			p.Ingestion.Additional.IngestionMappingRef = refName
 			p.Ingestion.Additional.IngestionMappingType = mappingKind
 			p.Ingestion.Additional.Format = mappingKind
@@ -299,11 +341,12 @@ func IgnoreSizeLimit() FileOption {
 	}
 }
 
-// Tags are tags to be associated with the ingested ata.
+// Tags are tags to be associated with the ingested ata. They are added to the tags of the other options, such as
+// IngestByTags() and DropByTags().
 func Tags(tags []string) FileOption {
 	return option{
 		run: func(p *properties.All) error {
-			p.Ingestion.Additional.Tags = tags
+			p.Ingestion.Additional.Tags = append(p.Ingestion.Additional.Tags, tags...)
 			return nil
 		},
 		sourceScope:  FromFile | FromReader | FromBlob,
@@ -312,6 +355,55 @@ func Tags(tags []string) FileOption {
 	}
 }
 
+// IngestByTags associates ingest-by: tags with the ingested data, which IfNotExists() can use in later ingestions.
+// The tags are given without their ingest-by: prefix.
+// For more information see: https://docs.microsoft.com/en-us/azure/kusto/management/extents-overview#ingest-by-extent-tags
+func IngestByTags(tags []string) FileOption {
+	return prefixedTags(tags, "ingest-by:", "IngestByTags")
+}
+
+// DropByTags associates drop-by: tags with the ingested data, which allows dropping the extents of the data with the
+// .drop extents command. The tags are given without their drop-by: prefix. These tags should be used sparingly.
+// For more information see: https://docs.microsoft.com/en-us/azure/kusto/management/extents-overview#drop-by-extent-tags
+func DropByTags(tags []string) FileOption {
+	return prefixedTags(tags, "drop-by:", "DropByTags")
+}
+
+func prefixedTags(tags []string, prefix, name string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			for _, tag := range tags {
+				if tag == "" {
+					return errors.ES(errors.OpUnknown, errors.KClientArgs, "%s() option was passed an empty tag", name).SetNoRetry()
+				}
+				p.Ingestion.Additional.Tags = append(p.Ingestion.Additional.Tags, prefix+tag)
+			}
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         name,
+	}
+}
+
+// ManagedIdentity makes the service read the blob with the managed identity objectID, which must be assigned to the
+// cluster and have access to the blob, instead of a SAS in the blob URL. Use "system" for the system-assigned identity.
+// For more information see: https://docs.microsoft.com/en-us/azure/data-explorer/ingest-data-managed-identity
+func ManagedIdentity(objectID string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if objectID == "" {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "ManagedIdentity() option was passed an empty object ID").SetNoRetry()
+			}
+			p.Source.ManagedIdentity = objectID
+			return nil
+		},
+		sourceScope:  FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "ManagedIdentity",
+	}
+}
+
 // IfNotExists provides a string value that, if specified, prevents ingestion from succeeding if the table already
 // has data tagged with an ingest-by: tag with the same value. This ensures idempotent data ingestion.
 // For more information see: https://docs.microsoft.com/en-us/azure/kusto/management/extents-overview#ingest-by-extent-tags
@@ -344,10 +436,14 @@ func ReportResultToTable() FileOption {
 }
 
 // SetCreationTime option allows the user to override the data creation time the retention policies are considered against
-// If not set the data creation time is considered to be the time of ingestion
+// If not set the data creation time is considered to be the time of ingestion. This is used to backdate historical data.
+// The time is sent in RFC3339 format, in UTC, so it must be between the years 0 and 9999.
 func SetCreationTime(t time.Time) FileOption {
 	return option{
 		run: func(p *properties.All) error {
+			if t.IsZero() || t.UTC().Year() < 0 || t.UTC().Year() > 9999 {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "SetCreationTime() option was passed a time that cannot be sent in RFC3339 format: %v", t).SetNoRetry()
+			}
 			p.Ingestion.Additional.CreationTime = t
 			return nil
 		},
@@ -357,6 +453,37 @@ func SetCreationTime(t time.Time) FileOption {
 	}
 }
 
+// IgnoreFirstRecord ignores the first record of each file, such as the header line of a CSV file. It applies to the
+// CSV-like formats: CSV, TSV, TSVE, SCSV, SOHSV, PSV and TXT.
+func IgnoreFirstRecord() FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			p.Ingestion.Additional.IgnoreFirstRecord = true
+			return nil
+		},
+		sourceScope:  FromFile | FromReader | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "IgnoreFirstRecord",
+	}
+}
+
+// ZipPattern provides the regular expression that selects the files of a zip archive to ingest, all the files being
+// ingested by default. It only applies when the source is a zip archive.
+func ZipPattern(pattern string) FileOption {
+	return option{
+		run: func(p *properties.All) error {
+			if pattern == "" {
+				return errors.ES(errors.OpUnknown, errors.KClientArgs, "ZipPattern() option was passed an empty pattern").SetNoRetry()
+			}
+			p.Ingestion.Additional.ZipPattern = pattern
+			return nil
+		},
+		sourceScope:  FromFile | FromBlob,
+		clientScopes: QueuedClient | ManagedClient,
+		name:         "ZipPattern",
+	}
+}
+
 // ValidationOption is an an option for validating the ingestion input data.
 // These are defined as constants within this package.
 type ValidationOption int8
@@ -3,8 +3,10 @@ package ingest
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -231,3 +233,69 @@ func TestFileFormatAndMapping(t *testing.T) {
 	}
 
 }
+
+func TestAdditionalPropertiesOptions(t *testing.T) {
+	t.Parallel()
+
+	creation := time.Date(2019, 5, 1, 12, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+
+	tests := []struct {
+		desc    string
+		options []FileOption
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "None",
+			want: `{}`,
+		},
+		{
+			desc:    "Tags",
+			options: []FileOption{Tags([]string{"a"}), IngestByTags([]string{"batch-1"}), DropByTags([]string{"2019-05"})},
+			want:    `{"tags":["a","ingest-by:batch-1","drop-by:2019-05"]}`,
+		},
+		{
+			desc:    "IfNotExists",
+			options: []FileOption{IfNotExists("batch-1")},
+			want:    `{"ingestIfNotExists":"batch-1"}`,
+		},
+		{
+			desc:    "Creation time",
+			options: []FileOption{SetCreationTime(creation)},
+			want:    `{"creationTime":"2019-05-01T10:30:00Z"}`,
+		},
+		{
+			desc:    "IgnoreFirstRecord and ZipPattern",
+			options: []FileOption{IgnoreFirstRecord(), ZipPattern(`.*\.csv`)},
+			want:    `{"ignoreFirstRecord":true,"zipPattern":".*\\.csv"}`,
+		},
+		{desc: "Empty tag", options: []FileOption{DropByTags([]string{""})}, wantErr: true},
+		{desc: "Zero creation time", options: []FileOption{SetCreationTime(time.Time{})}, wantErr: true},
+		{desc: "Creation time out of range", options: []FileOption{SetCreationTime(time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC))}, wantErr: true},
+		{desc: "Empty zip pattern", options: []FileOption{ZipPattern("")}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			props := properties.All{}
+			var err error
+			for _, o := range test.options {
+				if err = o.Run(&props, QueuedClient, FromFile); err != nil {
+					break
+				}
+			}
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := json.Marshal(props.Ingestion.Additional)
+			require.NoError(t, err)
+			assert.JSONEq(t, test.want, string(got))
+		})
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/queued"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/streaming_ingest"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/google/uuid"
 )
 
@@ -36,6 +39,13 @@ type Ingestion struct {
 
 	bufferSize int
 	maxBuffers int
+
+	refreshInterval time.Duration
+
+	// cloudInfo returns the metadata of the cloud of the cluster, nil to get it with kusto.GetMetadata().
+	cloudInfo func() (kusto.CloudInfo, error)
+	// blobSize returns the size of a blob for FromBlob(), nil to read it from the properties of the blob.
+	blobSize func(ctx context.Context, client *blob.Client) (int64, error)
 }
 
 // Option is an optional argument to New().
@@ -49,16 +59,19 @@ func WithStaticBuffer(bufferSize int, maxBuffers int) Option {
 	}
 }
 
-// New is a constructor for Ingestion.
-func New(client QueryClient, db, table string, options ...Option) (*Ingestion, error) {
-	mgr, err := resources.New(client)
-	if err != nil {
-		return nil, err
+// WithResourceRefreshInterval sets the interval at which the ingestion resources, such as the SAS of the temporary
+// storage, and the auth context are refreshed in the background. Each refresh is randomly moved by up to 10% of it, and
+// failed refreshes are retried with a backoff while the resources fetched before are still used. Defaults to 1 hour.
+func WithResourceRefreshInterval(d time.Duration) Option {
+	return func(s *Ingestion) {
+		s.refreshInterval = d
 	}
+}
 
+// New is a constructor for Ingestion.
+func New(client QueryClient, db, table string, options ...Option) (*Ingestion, error) {
 	i := &Ingestion{
 		client: client,
-		mgr:    mgr,
 		db:     db,
 		table:  table,
 	}
@@ -67,6 +80,12 @@ func New(client QueryClient, db, table string, options ...Option) (*Ingestion, e
 		option(i)
 	}
 
+	mgr, err := resources.New(client, resources.WithRefreshInterval(i.refreshInterval))
+	if err != nil {
+		return nil, err
+	}
+	i.mgr = mgr
+
 	fs, err := queued.New(db, table, mgr, client.HttpClient(), queued.WithStaticBuffer(i.bufferSize, i.maxBuffers))
 	if err != nil {
 		return nil, err
@@ -157,6 +176,11 @@ func (i *Ingestion) fromFile(ctx context.Context, fPath string, options []FileOp
 		return nil, err
 	}
 
+	// The format is needed to know whether to compress the file, and an unknown one must fail before the upload.
+	if err := queued.CompleteFormatFromFileName(&props, fPath); err != nil {
+		return nil, err
+	}
+
 	result.record.IngestionSourcePath = fPath
 
 	if local {
@@ -250,6 +274,27 @@ func (i *Ingestion) newProp() properties.All {
 	}
 }
 
+// ResourceStats are the counters of the uses of a storage resource of the ingestion, a blob container to which data is
+// uploaded or a queue to which ingestions are enqueued.
+type ResourceStats struct {
+	// Successes is the number of successful uploads to the container or enqueues to the queue.
+	Successes int64
+	// Failures is the number of failed uploads to the container or enqueues to the queue.
+	Failures int64
+	// DemotedUntil is the time until which the resource is only used after the others, because of its last failure.
+	DemotedUntil time.Time
+}
+
+// ResourceStats returns the counters of the uses of the storage resources of the ingestion, by URL without SAS, for
+// diagnostics. The resources are used in round-robin, and one that fails is only used after the others for a while.
+func (i *Ingestion) ResourceStats() map[string]ResourceStats {
+	stats := map[string]ResourceStats{}
+	for u, s := range i.mgr.Stats() {
+		stats[u] = ResourceStats{Successes: s.Successes, Failures: s.Failures, DemotedUntil: s.DemotedUntil}
+	}
+	return stats
+}
+
 func (i *Ingestion) Close() error {
 	i.mgr.Close()
 	var err error
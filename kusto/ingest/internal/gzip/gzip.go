@@ -40,7 +40,7 @@ func (s *Streamer) Reset(reader io.ReadCloser) {
 	s.run()
 }
 
-// InputSize returns the amount of data that the Streamer streamed. This will only be accurate for
+// InputSize returns the amount of uncompressed data that the Streamer streamed. This will only be accurate for
 // the full stream after Read() has returned io.EOF and not before.
 func (s *Streamer) InputSize() int64 {
 	return atomic.LoadInt64(&s.size)
@@ -69,7 +69,8 @@ func (s *Streamer) run() {
 		defer zw.Close()
 		defer zw.Flush()
 
-		_, err := io.Copy(zw, s.userInput)
+		n, err := io.Copy(zw, s.userInput)
+		atomic.StoreInt64(&s.size, n)
 		if err != nil {
 			s.err.Store(err)
 		}
@@ -78,9 +79,7 @@ func (s *Streamer) run() {
 
 // Read implements io.Reader.
 func (s *Streamer) Read(b []byte) (int, error) {
-	amount, err := s.outputRead.Read(b)
-	atomic.AddInt64(&s.size, int64(amount))
-	return amount, err
+	return s.outputRead.Read(b)
 }
 
 // Close implements io.Closer.
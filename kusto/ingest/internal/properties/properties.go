@@ -112,7 +112,7 @@ var dfDescriptions = []dfDescriptor{
 	{"ApacheAvro", "avro", "", false},
 	{"Csv", "csv", ".csv", true},
 	{"Json", "json", ".json", true},
-	{"MultiJson", "multijson", "", false},
+	{"MultiJson", "multijson", ".multijson", false},
 	{"Orc", "orc", ".orc", true},
 	{"Parquet", "parquet", ".parquet", true},
 	{"Psv", "psv", ".psv", false},
@@ -192,6 +192,16 @@ func (d DataFormat) IsValidMappingKind() bool {
 	return false
 }
 
+// ShouldCompress returns false for the binary formats, which are compressed internally, so compressing them again
+// only costs time.
+func (d DataFormat) ShouldCompress() bool {
+	switch d {
+	case AVRO, ApacheAVRO, Parquet, ORC:
+		return false
+	}
+	return true
+}
+
 // DataFormatDiscovery looks at the file name and tries to discern what the file format is.
 func DataFormatDiscovery(fName string) DataFormat {
 	name := fName
@@ -254,6 +264,9 @@ type SourceOptions struct {
 
 	// OriginalSource is the path to the original source file, used for deletion.
 	OriginalSource string
+
+	// ManagedIdentity is the object ID of the managed identity that the service uses to read the blob, if any.
+	ManagedIdentity string
 }
 
 // Ingestion is a JSON serializable set of options that must be provided to the service.
@@ -311,7 +324,12 @@ type Additional struct {
 	// has data tagged with an ingest-by: tag with the same value. This ensures idempotent data ingestion.
 	IngestIfNotExists string `json:"ingestIfNotExists,omitempty"`
 	// CreationTime is used to override the time considered for retantion policies, which by default is the time of ingestion.
+	// It is not sent if it is the zero value.
 	CreationTime time.Time `json:"creationTime,omitempty"`
+	// IgnoreFirstRecord, if set, ignores the first record of each file, which is the header of CSV files.
+	IgnoreFirstRecord bool `json:"ignoreFirstRecord,omitempty"`
+	// ZipPattern is a regular expression that selects the files of a zip archive to ingest.
+	ZipPattern string `json:"zipPattern,omitempty"`
 }
 
 // StatusTableDescription is a reference to the table status entry used for this ingestion command.
@@ -358,6 +376,13 @@ func (a Additional) MarshalJSON() ([]byte, error) {
 		m["ingestionMappingType"] = a.IngestionMappingType.CamelCase()
 	}
 
+	// omitempty does not omit a zero time.Time.
+	if a.CreationTime.IsZero() {
+		delete(m, "creationTime")
+	} else {
+		m["creationTime"] = a.CreationTime.UTC().Format(time.RFC3339Nano)
+	}
+
 	return json.Marshal(m)
 }
 
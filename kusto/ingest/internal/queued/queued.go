@@ -3,18 +3,21 @@
 package queued
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	goErrors "errors"
 	"fmt"
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"io"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -105,7 +108,7 @@ func New(db, table string, mgr *resources.Manager, http *http.Client, options ..
 
 // Local ingests a local file into Kusto.
 func (i *Ingestion) Local(ctx context.Context, from string, props properties.All) error {
-	client, container, err := i.upstreamContainer()
+	containers, err := i.upstreamContainers()
 	if err != nil {
 		return err
 	}
@@ -121,7 +124,28 @@ func (i *Ingestion) Local(ctx context.Context, from string, props properties.All
 		return errors.ES(errors.OpFileIngest, errors.KBlobstore, "no Kusto queue resources are defined, there is no queue to upload to").SetNoRetry()
 	}
 
-	blobURL, size, err := i.localToBlob(ctx, from, client, container, &props)
+	// The file can be read again, so the upload is tried with the next container when it fails with one.
+	var (
+		blobURL string
+		size    int64
+	)
+	for _, storageURI := range containers {
+		var client *azblob.Client
+		client, err = i.containerClient(storageURI)
+		if err != nil {
+			return err
+		}
+
+		blobURL, size, err = i.localToBlob(ctx, from, client, storageURI.ObjectName(), &props)
+		if err == nil {
+			i.mgr.ReportSuccess(storageURI)
+			break
+		}
+		if !isBlobstoreErr(err) {
+			return err
+		}
+		i.mgr.ReportFailure(storageURI)
+	}
 	if err != nil {
 		return err
 	}
@@ -136,10 +160,17 @@ func (i *Ingestion) Local(ctx context.Context, from string, props properties.All
 // Reader uploads a file via an io.Reader.
 // If the function succeeds, it returns the path of the created blob.
 func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properties.All) (string, error) {
-	to, toContainer, err := i.upstreamContainer()
+	// The reader cannot be read again, so only the best ranked container is tried.
+	containers, err := i.upstreamContainers()
 	if err != nil {
 		return "", err
 	}
+	storageURI := containers[0]
+	to, err := i.containerClient(storageURI)
+	if err != nil {
+		return "", err
+	}
+	toContainer := storageURI.ObjectName()
 
 	mgrResources, err := i.mgr.Resources()
 	if err != nil {
@@ -152,27 +183,39 @@ func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properti
 		return "", errors.ES(errors.OpFileIngest, errors.KBlobstore, "no Kusto queue resources are defined, there is no queue to upload to").SetNoRetry()
 	}
 
-	shouldCompress := true
+	compression := properties.CTNone
 	if props.Source.OriginalSource != "" {
-		shouldCompress = CompressionDiscovery(props.Source.OriginalSource) == properties.CTNone
-	}
-	if props.Source.DontCompress {
-		shouldCompress = false
+		compression = CompressionDiscovery(props.Source.OriginalSource)
 	}
+	shouldCompress := compression == properties.CTNone && !props.Source.DontCompress && props.Ingestion.Additional.Format.ShouldCompress()
 
-	extension := "gz"
-	if !shouldCompress {
-		if props.Source.OriginalSource != "" {
-			extension = filepath.Ext(props.Source.OriginalSource)
-		} else {
-			extension = props.Ingestion.Additional.Format.String() // Best effort
+	// Data that is already compressed is uploaded as is.
+	if shouldCompress {
+		reader, compression, err = DetectCompression(reader)
+		if err != nil {
+			return "", err
 		}
+		shouldCompress = compression == properties.CTNone
 	}
 
-	blobName := fmt.Sprintf("%s_%s_%s_%s.%s", i.db, i.table, nower(), filepath.Base(uuid.New().String()), extension)
+	var extension string
+	switch {
+	case shouldCompress:
+		extension = "gz"
+	case props.Source.OriginalSource != "":
+		extension = filepath.Ext(props.Source.OriginalSource)
+	case compression == properties.GZIP:
+		extension = "gz"
+	case compression == properties.ZIP:
+		extension = "zip"
+	default:
+		extension = props.Ingestion.Additional.Format.String() // Best effort
+	}
 
-	size := int64(0)
+	blobName := fmt.Sprintf("%s_%s_%s_%s.%s", i.db, i.table, nower(), filepath.Base(uuid.New().String()), extension)
 
+	counter := &countingReader{reader: reader}
+	reader = counter
 	if shouldCompress {
 		reader = gzip.Compress(reader)
 	}
@@ -187,11 +230,15 @@ func (i *Ingestion) Reader(ctx context.Context, reader io.Reader, props properti
 	)
 
 	if err != nil {
+		i.mgr.ReportFailure(storageURI)
 		return blobName, errors.ES(errors.OpFileIngest, errors.KBlobstore, "problem uploading to Blob Storage: %s", err)
 	}
+	i.mgr.ReportSuccess(storageURI)
 
-	if gz, ok := reader.(*gzip.Streamer); ok {
-		size = gz.InputSize()
+	// The size of compressed data is only known from the RawDataSize() option.
+	size := props.Ingestion.RawDataSize
+	if size == 0 && compression == properties.CTNone {
+		size = counter.count()
 	}
 	if err := i.Blob(ctx, fullUrl(to, toContainer, blobName), size, props); err != nil {
 		return blobName, err
@@ -207,12 +254,15 @@ func (i *Ingestion) Blob(ctx context.Context, from string, fileSize int64, props
 	// To learn more about ingestion methods go to:
 	// https://docs.microsoft.com/en-us/azure/data-explorer/ingest-data-overview#ingestion-methods
 
-	to, err := i.upstreamQueue()
+	queues, err := i.upstreamQueues()
 	if err != nil {
 		return err
 	}
 
 	props.Ingestion.BlobPath = from
+	if props.Source.ManagedIdentity != "" {
+		props.Ingestion.BlobPath += ";managed_identity=" + props.Source.ManagedIdentity
+	}
 	if fileSize != 0 {
 		props.Ingestion.RawDataSize = fileSize
 	}
@@ -229,7 +279,15 @@ func (i *Ingestion) Blob(ctx context.Context, from string, fileSize int64, props
 		return errors.ES(errors.OpFileIngest, errors.KInternal, "could not marshal the ingestion blob info: %s", err).SetNoRetry()
 	}
 
-	if _, err := to.Enqueue(ctx, j, 0, 0); err != nil {
+	// The message is enqueued to the next queue when it fails with one.
+	for _, queue := range queues {
+		if _, err = i.queueURL(queue).Enqueue(ctx, j, 0, 0); err == nil {
+			i.mgr.ReportSuccess(queue)
+			break
+		}
+		i.mgr.ReportFailure(queue)
+	}
+	if err != nil {
 		return errors.E(errors.OpFileIngest, errors.KBlobstore, err)
 	}
 
@@ -241,6 +299,12 @@ func (i *Ingestion) Blob(ctx context.Context, from string, fileSize int64, props
 	return nil
 }
 
+// UnknownFormatErr is wrapped in the error returned by CompleteFormatFromFileName() when the format is not set and
+// cannot be detected from the file extension.
+var UnknownFormatErr = fmt.Errorf("the format of the file could not be detected from its extension, set it with the FileFormat() option")
+
+// CompleteFormatFromFileName sets the format of props from the extension of from if it is not set, and fails with
+// UnknownFormatErr if the extension is unknown.
 func CompleteFormatFromFileName(props *properties.All, from string) error {
 	// If they did not tell us how the file was encoded, try to discover it from the file extension.
 	if props.Ingestion.Additional.Format != properties.DFUnknown {
@@ -249,30 +313,33 @@ func CompleteFormatFromFileName(props *properties.All, from string) error {
 
 	et := properties.DataFormatDiscovery(from)
 	if et == properties.DFUnknown {
-		// If we can't figure out the file type, default to CSV.
-		et = properties.CSV
+		return errors.E(errors.OpFileIngest, errors.KClientArgs, fmt.Errorf("%w: %s", UnknownFormatErr, from)).SetNoRetry()
 	}
 	props.Ingestion.Additional.Format = et
 
 	return nil
 }
 
-// upstreamContainer randomly selects a container queue in which to upload our file to blobstore.
-func (i *Ingestion) upstreamContainer() (*azblob.Client, string, error) {
-	mgrResources, err := i.mgr.Resources()
+// upstreamContainers returns the containers in which to upload our file to blobstore, in the order in which they should
+// be tried.
+func (i *Ingestion) upstreamContainers() ([]*resources.URI, error) {
+	containers, err := i.mgr.RankedContainers()
 	if err != nil {
-		return nil, "", errors.E(errors.OpFileIngest, errors.KBlobstore, err)
+		return nil, errors.E(errors.OpFileIngest, errors.KBlobstore, err)
 	}
 
-	if len(mgrResources.Containers) == 0 {
-		return nil, "", errors.ES(
+	if len(containers) == 0 {
+		return nil, errors.ES(
 			errors.OpFileIngest,
 			errors.KBlobstore,
 			"no Blob Storage container resources are defined, there is no container to upload to",
 		).SetNoRetry()
 	}
+	return containers, nil
+}
 
-	storageURI := mgrResources.Containers[rand.Intn(len(mgrResources.Containers))]
+// containerClient returns a client of the storage account of the container storageURI.
+func (i *Ingestion) containerClient(storageURI *resources.URI) (*azblob.Client, error) {
 	serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net?%s", storageURI.Account(), storageURI.SAS().Encode())
 
 	client, err := azblob.NewClientWithNoCredential(serviceURL, &azblob.ClientOptions{
@@ -282,32 +349,43 @@ func (i *Ingestion) upstreamContainer() (*azblob.Client, string, error) {
 	})
 
 	if err != nil {
-		return nil, "", errors.E(errors.OpFileIngest, errors.KBlobstore, err)
+		return nil, errors.E(errors.OpFileIngest, errors.KBlobstore, err)
 	}
 
-	return client, storageURI.ObjectName(), nil
+	return client, nil
 }
 
-func (i *Ingestion) upstreamQueue() (azqueue.MessagesURL, error) {
-	mgrResources, err := i.mgr.Resources()
+// upstreamQueues returns the queues in which to enqueue the ingestion message, in the order in which they should be
+// tried.
+func (i *Ingestion) upstreamQueues() ([]*resources.URI, error) {
+	queues, err := i.mgr.RankedQueues()
 	if err != nil {
-		return azqueue.MessagesURL{}, err
+		return nil, err
 	}
 
-	if len(mgrResources.Queues) == 0 {
-		return azqueue.MessagesURL{}, errors.ES(
+	if len(queues) == 0 {
+		return nil, errors.ES(
 			errors.OpFileIngest,
 			errors.KBlobstore,
 			"no Kusto queue resources are defined, there is no queue to upload to",
 		).SetNoRetry()
 	}
+	return queues, nil
+}
 
-	queue := mgrResources.Queues[rand.Intn(len(mgrResources.Queues))]
+// queueURL returns the URL of the messages of the queue.
+func (i *Ingestion) queueURL(queue *resources.URI) azqueue.MessagesURL {
 	service, _ := url.Parse(fmt.Sprintf("https://%s.queue.core.windows.net?%s", queue.Account(), queue.SAS().Encode()))
 
 	p := createPipeline(i.http)
 
-	return azqueue.NewServiceURL(*service, p).NewQueueURL(queue.ObjectName()).NewMessagesURL(), nil
+	return azqueue.NewServiceURL(*service, p).NewQueueURL(queue.ObjectName()).NewMessagesURL()
+}
+
+// isBlobstoreErr tells if err is an error of Blob Storage, after which the upload can be tried with another container.
+func isBlobstoreErr(err error) bool {
+	var e *errors.Error
+	return goErrors.As(err, &e) && e.Kind == errors.KBlobstore
 }
 
 func createPipeline(http *http.Client) pipeline.Pipeline {
@@ -331,8 +409,9 @@ var nower = time.Now
 // error if there was one.
 func (i *Ingestion) localToBlob(ctx context.Context, from string, client *azblob.Client, container string, props *properties.All) (string, int64, error) {
 	compression := CompressionDiscovery(from)
+	shouldCompress := compression == properties.CTNone && !props.Source.DontCompress && props.Ingestion.Additional.Format.ShouldCompress()
 	blobName := fmt.Sprintf("%s_%s_%s_%s_%s", i.db, i.table, nower(), filepath.Base(uuid.New().String()), filepath.Base(from))
-	if compression == properties.CTNone {
+	if shouldCompress {
 		blobName = blobName + ".gz"
 	}
 
@@ -354,7 +433,7 @@ func (i *Ingestion) localToBlob(ctx context.Context, from string, client *azblob
 		).SetNoRetry()
 	}
 
-	if compression == properties.CTNone && !props.Source.DontCompress {
+	if shouldCompress {
 		gstream := gzip.New()
 		gstream.Reset(file)
 
@@ -414,6 +493,42 @@ func CompressionDiscovery(fName string) properties.CompressionType {
 	return properties.CTNone
 }
 
+// DetectCompression detects whether the data of reader is compressed with gzip or zip from its first bytes. It returns
+// a reader of all the data of reader, with the CompressionType, which is CTNone if the data is not compressed.
+func DetectCompression(reader io.Reader) (io.Reader, properties.CompressionType, error) {
+	br := bufio.NewReader(reader)
+	head, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, properties.CTUnknown, errors.E(errors.OpFileIngest, errors.KIO, err)
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return br, properties.GZIP, nil
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return br, properties.ZIP, nil
+	}
+	return br, properties.CTNone, nil
+}
+
+// countingReader counts the bytes read from reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.reader.Read(b)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+// count returns the number of bytes read so far.
+func (c *countingReader) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
 // This allows mocking the stat func later on
 var statFunc = os.Stat
 
@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	goErrors "errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 )
@@ -27,6 +34,8 @@ func TestFormatDiscovery(t *testing.T) {
 		{".AVRO.GZ", properties.AVRO},
 		{".csv", properties.CSV},
 		{".json", properties.JSON},
+		{".multijson", properties.MultiJSON},
+		{".parquet.gz", properties.Parquet},
 		{".orc", properties.ORC},
 		{".parquet", properties.Parquet},
 		{".psv", properties.PSV},
@@ -50,6 +59,43 @@ func TestFormatDiscovery(t *testing.T) {
 	}
 }
 
+func TestCompleteFormatFromFileName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc               string
+		from               string
+		format             properties.DataFormat
+		want               properties.DataFormat
+		wantShouldCompress bool
+		wantErr            bool
+	}{
+		{desc: "Detected", from: "/path/to/file.tsv.gz", want: properties.TSV, wantShouldCompress: true},
+		{desc: "Binary", from: "https://account.blob.core.windows.net/container/file.parquet?sas", want: properties.Parquet},
+		{desc: "Set", from: "/path/to/file", format: properties.ORC, want: properties.ORC},
+		{desc: "Unknown", from: "/path/to/file.log", wantErr: true},
+		{desc: "No extension", from: "/path/to/file", wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			props := properties.All{}
+			props.Ingestion.Additional.Format = test.format
+			err := CompleteFormatFromFileName(&props, test.from)
+			if test.wantErr {
+				assert.True(t, goErrors.Is(err, UnknownFormatErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, props.Ingestion.Additional.Format)
+			assert.Equal(t, test.wantShouldCompress, props.Ingestion.Additional.Format.ShouldCompress())
+		})
+	}
+}
+
 func TestCompressionDiscovery(t *testing.T) {
 	t.Parallel()
 
@@ -76,6 +122,45 @@ func TestCompressionDiscovery(t *testing.T) {
 
 }
 
+func TestDetectCompression(t *testing.T) {
+	t.Parallel()
+
+	gz := bytes.Buffer{}
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte("a,b\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+
+	tests := []struct {
+		desc  string
+		input []byte
+		want  properties.CompressionType
+	}{
+		{"gzip", gz.Bytes(), properties.GZIP},
+		{"zip", []byte("PK\x03\x04rest of the archive"), properties.ZIP},
+		{"csv", []byte("a,b\n"), properties.CTNone},
+		{"shorter than the header", []byte("a"), properties.CTNone},
+		{"empty", nil, properties.CTNone},
+	}
+
+	for _, test := range tests {
+		test := test // capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			reader, got, err := DetectCompression(bytes.NewReader(test.input))
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+
+			// The reader returns all the data, including the bytes used for the detection.
+			data, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, len(test.input), len(data))
+			assert.True(t, bytes.Equal(test.input, data))
+		})
+	}
+}
+
 type fakeBlobstore struct {
 	out       *bytes.Buffer
 	shouldErr bool
@@ -300,3 +385,63 @@ func TestIsLocalPath(t *testing.T) {
 		})
 	}
 }
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBlobQueueFailover(t *testing.T) {
+	t.Parallel()
+
+	const enqueued = `<?xml version="1.0" encoding="utf-8"?><QueueMessagesList><QueueMessage>` +
+		`<MessageId>id</MessageId><InsertionTime>Mon, 01 Jan 2024 00:00:00 GMT</InsertionTime>` +
+		`<ExpirationTime>Mon, 08 Jan 2024 00:00:00 GMT</ExpirationTime><PopReceipt>receipt</PopReceipt>` +
+		`<TimeNextVisible>Mon, 01 Jan 2024 00:00:00 GMT</TimeNextVisible></QueueMessage></QueueMessagesList>`
+
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(req.URL.Host, "bad.") {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}, Request: req}, nil
+		}
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader(enqueued)), Header: http.Header{}, Request: req}, nil
+	})}
+
+	queue := func(root string) value.Values {
+		return value.Values{
+			value.String{Valid: true, Value: "SecuredReadyForAggregationQueue"},
+			value.String{Valid: true, Value: root},
+		}
+	}
+	mgr, err := resources.New(
+		resources.FakeResources([]value.Values{
+			queue("https://bad.queue.core.windows.net/queue"),
+			queue("https://good.queue.core.windows.net/queue"),
+		}, false),
+		resources.WithRandSource(rand.NewSource(0)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(mgr.Close)
+
+	ingestion, err := New("db", "table", mgr, client)
+	require.NoError(t, err)
+
+	// Whichever queue is tried first, the bad one fails once and is then demoted after the good one.
+	props := properties.All{
+		Ingestion: properties.Ingestion{
+			DatabaseName: "db",
+			TableName:    "table",
+			Additional:   properties.Additional{AuthContext: "authtoken"},
+		},
+	}
+	for j := 0; j < 2; j++ {
+		err := ingestion.Blob(context.Background(), "https://account.blob.core.windows.net/container/data.csv", 0, props)
+		require.NoError(t, err)
+	}
+
+	stats := mgr.Stats()
+	assert.Equal(t, resources.Stats{Successes: 2}, stats["https://good.queue.core.windows.net/queue"])
+	bad := stats["https://bad.queue.core.windows.net/queue"]
+	assert.Equal(t, int64(1), bad.Failures)
+	assert.Equal(t, int64(0), bad.Successes)
+}
@@ -0,0 +1,129 @@
+package resources
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultCooldown is how long a storage resource is demoted after a failure.
+const defaultCooldown = 1 * time.Minute
+
+const (
+	containersKind = "containers"
+	queuesKind     = "queues"
+)
+
+// Stats are the counters of the uses of a storage resource, a blob container or a queue.
+type Stats struct {
+	// Successes is the number of successful uploads to the container or enqueues to the queue.
+	Successes int64
+	// Failures is the number of failed uploads to the container or enqueues to the queue.
+	Failures int64
+	// DemotedUntil is the time until which the resource is tried after the healthy ones, because of its last failure.
+	DemotedUntil time.Time
+}
+
+// ranking orders the storage resources of a Manager. The healthy resources are used in round-robin, from a random
+// first one, and the resources that failed are demoted after them for a cooldown.
+type ranking struct {
+	mu       sync.Mutex
+	rand     *rand.Rand
+	now      func() time.Time
+	cooldown time.Duration
+	next     map[string]int    // Next round-robin position, by kind
+	stats    map[string]*Stats // By resource key
+}
+
+// init sets the defaults of the fields that are not set. r.mu must be held.
+func (r *ranking) init() {
+	if r.rand == nil {
+		r.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if r.now == nil {
+		r.now = time.Now
+	}
+	if r.cooldown <= 0 {
+		r.cooldown = defaultCooldown
+	}
+	if r.next == nil {
+		r.next = map[string]int{}
+	}
+	if r.stats == nil {
+		r.stats = map[string]*Stats{}
+	}
+}
+
+// rank returns uris in the order in which they should be tried: the healthy ones, starting from the next round-robin
+// position of kind, then the demoted ones, the ones that are demoted for the shortest time first.
+func (r *ranking) rank(kind string, uris []*URI) []*URI {
+	if len(uris) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+
+	start, ok := r.next[kind]
+	if !ok {
+		start = r.rand.Intn(len(uris))
+	}
+	start %= len(uris)
+	r.next[kind] = start + 1
+
+	now := r.now()
+	healthy := make([]*URI, 0, len(uris))
+	var demoted []*URI
+	for j := range uris {
+		u := uris[(start+j)%len(uris)]
+		if s := r.stats[resourceKey(u)]; s != nil && s.DemotedUntil.After(now) {
+			demoted = append(demoted, u)
+			continue
+		}
+		healthy = append(healthy, u)
+	}
+
+	sort.SliceStable(demoted, func(a, b int) bool {
+		return r.stats[resourceKey(demoted[a])].DemotedUntil.Before(r.stats[resourceKey(demoted[b])].DemotedUntil)
+	})
+	return append(healthy, demoted...)
+}
+
+// report records a use of u, demoting it if it failed.
+func (r *ranking) report(u *URI, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+
+	key := resourceKey(u)
+	s := r.stats[key]
+	if s == nil {
+		s = &Stats{}
+		r.stats[key] = s
+	}
+	if failed {
+		s.Failures++
+		s.DemotedUntil = r.now().Add(r.cooldown)
+		return
+	}
+	s.Successes++
+}
+
+// snapshot returns a copy of the stats.
+func (r *ranking) snapshot() map[string]Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make(map[string]Stats, len(r.stats))
+	for key, s := range r.stats {
+		stats[key] = *s
+	}
+	return stats
+}
+
+// resourceKey identifies u without its SAS, which changes when the resources are refreshed.
+func resourceKey(u *URI) string {
+	return u.u.Scheme + "://" + u.u.Host + u.u.Path
+}
@@ -0,0 +1,69 @@
+package resources
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRanking(t *testing.T) {
+	t.Parallel()
+
+	a := mustParse("https://a.blob.core.windows.net/container?sig=1")
+	b := mustParse("https://b.blob.core.windows.net/container?sig=1")
+	c := mustParse("https://c.blob.core.windows.net/container?sig=1")
+	uris := []*URI{a, b, c}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &ranking{
+		rand:     rand.New(rand.NewSource(0)),
+		now:      func() time.Time { return now },
+		cooldown: time.Minute,
+	}
+
+	// The first resource is random, then the healthy resources are used in round-robin.
+	first := r.rank(containersKind, uris)
+	start := 0
+	for first[0] != uris[start] {
+		start++
+	}
+	for j := 1; j < 4; j++ {
+		got := r.rank(containersKind, uris)
+		assert.Equal(t, uris[(start+j)%3], got[0])
+		assert.Len(t, got, 3)
+	}
+
+	// The queues have their own round-robin position.
+	assert.Len(t, r.rank(queuesKind, uris), 3)
+
+	// Failed resources are tried last, the ones that failed first before the others, until the cooldown ends.
+	r.report(b, true)
+	now = now.Add(time.Second)
+	r.report(a, true)
+	r.report(c, false)
+	for j := 0; j < 3; j++ {
+		assert.Equal(t, []*URI{c, b, a}, r.rank(containersKind, uris))
+	}
+
+	now = now.Add(time.Minute)
+	got := r.rank(containersKind, uris)
+	assert.ElementsMatch(t, uris, got)
+	assert.NotEqual(t, []*URI{c, b, a}, got)
+
+	// The stats are by URL without SAS, so that they survive the refresh of the resources.
+	r.report(mustParse("https://c.blob.core.windows.net/container?sig=2"), false)
+	assert.Equal(t, map[string]Stats{
+		"https://a.blob.core.windows.net/container": {Failures: 1, DemotedUntil: now},
+		"https://b.blob.core.windows.net/container": {Failures: 1, DemotedUntil: now.Add(-time.Second)},
+		"https://c.blob.core.windows.net/container": {Successes: 2},
+	}, r.snapshot())
+}
+
+func TestRankingEmpty(t *testing.T) {
+	t.Parallel()
+
+	r := &ranking{}
+	assert.Empty(t, r.rank(queuesKind, nil))
+}
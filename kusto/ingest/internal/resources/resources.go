@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/url"
 	"strings"
 	"sync"
@@ -22,7 +23,16 @@ const (
 	defaultInitialInterval = 1 * time.Second
 	defaultMultiplier      = 2
 	retryCount             = 4
-	fetchInterval          = 1 * time.Hour
+
+	// DefaultRefreshInterval is the default interval at which the ingestion resources and the auth context are
+	// refreshed in the background.
+	DefaultRefreshInterval = 1 * time.Hour
+	// refreshJitter is the fraction of the refresh interval by which each refresh is randomly moved, so that clients
+	// created at the same time do not all refresh at the same time.
+	refreshJitter = 0.1
+	// refreshRetryInterval and refreshMaxRetryInterval bound the backoff between failed background refreshes.
+	refreshRetryInterval    = 10 * time.Second
+	refreshMaxRetryInterval = 5 * time.Minute
 )
 
 // mgmter is a private interface that allows us to write hermetic tests against the kusto.Client.Mgmt() method.
@@ -130,25 +140,61 @@ type token struct {
 	AuthContext string `kusto:"AuthorizationContext"`
 }
 
-// Manager manages Kusto resources.
+// authContext is a fetched auth context.
+type authContext struct {
+	value     string
+	fetchTime time.Time
+}
+
+// Manager manages Kusto resources. The ingestion resources and the auth context are fetched on first use and then
+// refreshed in the background, so that callers keep using valid ones without waiting for the Mgmt() calls.
 type Manager struct {
-	client                   mgmter
-	done                     chan struct{}
-	resources                atomic.Value // Stores Ingestion
-	lastFetchTime            atomic.Value // Stores time.Time
-	kustoToken               token
-	authTokenCacheExpiration time.Time
-	authLock                 sync.Mutex
-	fetchLock                sync.Mutex
+	client          mgmter
+	done            chan struct{}
+	closeOnce       sync.Once
+	refreshInterval time.Duration
+	resources       atomic.Value // Stores Ingestion
+	lastFetchTime   atomic.Value // Stores time.Time
+	authContext     atomic.Value // Stores authContext
+	authLock        sync.Mutex
+	fetchLock       sync.Mutex
+	ranking         ranking
+}
+
+// Option is an optional argument to New().
+type Option func(m *Manager)
+
+// WithRefreshInterval sets the interval at which the ingestion resources and the auth context are refreshed. Each
+// refresh is randomly moved by up to 10% of it. A non-positive interval keeps DefaultRefreshInterval.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.refreshInterval = d
+		}
+	}
+}
+
+// WithRandSource sets the source of the random first resource of the round-robin over the storage resources.
+func WithRandSource(src rand.Source) Option {
+	return func(m *Manager) {
+		m.ranking.rand = rand.New(src)
+	}
+}
+
+// WithCooldown sets how long a storage resource is tried after the healthy ones, once it failed. Defaults to 1 minute.
+func WithCooldown(d time.Duration) Option {
+	return func(m *Manager) {
+		m.ranking.cooldown = d
+	}
 }
 
 // New is the constructor for Manager.
-func New(client mgmter) (*Manager, error) {
-	m := &Manager{client: client, done: make(chan struct{})}
-	m.authLock = sync.Mutex{}
-	m.fetchLock = sync.Mutex{}
+func New(client mgmter, options ...Option) (*Manager, error) {
+	m := &Manager{client: client, done: make(chan struct{}), refreshInterval: DefaultRefreshInterval}
+	for _, option := range options {
+		option(m)
+	}
 
-	m.authTokenCacheExpiration = time.Now().UTC()
 	go m.renewResources()
 
 	return m, nil
@@ -156,47 +202,110 @@ func New(client mgmter) (*Manager, error) {
 
 // Close closes the manager. This stops any token refreshes.
 func (m *Manager) Close() {
-	for {
-		select {
-		case <-m.done:
-			return
-		default:
-			close(m.done)
-			return
-		}
+	m.closeOnce.Do(func() { close(m.done) })
+}
+
+// interval returns the refresh interval of the manager.
+func (m *Manager) interval() time.Duration {
+	if m.refreshInterval <= 0 {
+		return DefaultRefreshInterval
 	}
+	return m.refreshInterval
 }
 
-func (m *Manager) renewResources() {
-	tickDuration := 30 * time.Second
+// validity returns how long the fetched resources and auth context are used. It is twice the refresh interval, so
+// that they are still used while failed refreshes are retried.
+func (m *Manager) validity() time.Duration {
+	return 2 * m.interval()
+}
 
-	tick := time.NewTicker(tickDuration)
-	count := fetchInterval // Start with a fetch immediately.
+// jitter randomly moves d by up to refreshJitter of it.
+func jitter(d time.Duration) time.Duration {
+	delta := float64(d) * refreshJitter
+	return d + time.Duration(delta*(2*rand.Float64()-1))
+}
+
+// renewResources refreshes the resources and the auth context every jittered interval until the manager is closed.
+// A failed refresh is retried with an exponential backoff.
+func (m *Manager) renewResources() {
+	retry := m.refreshBackoff()
+	timer := time.NewTimer(jitter(m.interval()))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-tick.C:
-			count += tickDuration
-			if count >= fetchInterval {
-				count = 0 * time.Second
-				m.fetchRetry(context.Background())
+		case <-timer.C:
+			if err := m.refresh(context.Background()); err != nil {
+				timer.Reset(retry.NextBackOff())
+				continue
 			}
+			retry.Reset()
+			timer.Reset(jitter(m.interval()))
 		case <-m.done:
-			tick.Stop()
 			return
 		}
 	}
 }
 
+// refreshBackoff returns the backoff between failed refreshes, which never gives up.
+func (m *Manager) refreshBackoff() backoff.BackOff {
+	exp := backoff.NewExponentialBackOff()
+	exp.InitialInterval = refreshRetryInterval
+	exp.MaxInterval = refreshMaxRetryInterval
+	if exp.MaxInterval > m.interval() {
+		exp.MaxInterval = m.interval()
+	}
+	if exp.InitialInterval > exp.MaxInterval {
+		exp.InitialInterval = exp.MaxInterval
+	}
+	exp.MaxElapsedTime = 0
+	exp.Reset()
+	return exp
+}
+
+// refresh fetches the ingestion resources and the auth context. Until it succeeds, callers keep getting the ones
+// fetched before, for as long as they are valid.
+func (m *Manager) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := m.fetch(ctx); err != nil {
+		return err
+	}
+
+	m.authLock.Lock()
+	defer m.authLock.Unlock()
+	_, err := m.fetchAuthContext(ctx)
+	return err
+}
+
 // AuthContext returns a string representing the authorization context. This auth token is a temporary token
 // that can be used to write a message via ingestion.  This is different than the ADAL token.
 func (m *Manager) AuthContext(ctx context.Context) (string, error) {
+	if auth, ok := m.validAuthContext(); ok {
+		return auth, nil
+	}
+
+	// Only one caller fetches the auth context, the others wait for it and use what it fetched.
 	m.authLock.Lock()
 	defer m.authLock.Unlock()
-	if m.authTokenCacheExpiration.After(time.Now().UTC()) {
-		return m.kustoToken.AuthContext, nil
+	if auth, ok := m.validAuthContext(); ok {
+		return auth, nil
+	}
+	return m.fetchAuthContext(ctx)
+}
+
+// validAuthContext returns the fetched auth context, if there is one that is still valid.
+func (m *Manager) validAuthContext() (string, bool) {
+	auth, ok := m.authContext.Load().(authContext)
+	if !ok || auth.fetchTime.Add(m.validity()).Before(time.Now().UTC()) {
+		return "", false
 	}
+	return auth.value, true
+}
 
+// fetchAuthContext makes a kusto.Client.Mgmt() call to retrieve the auth context. m.authLock must be held.
+func (m *Manager) fetchAuthContext(ctx context.Context) (string, error) {
 	var rows *kusto.RowIterator
 	retryCtx := backoff.WithContext(InitBackoff(), ctx)
 	err := backoff.Retry(func() error {
@@ -236,8 +345,7 @@ func (m *Manager) AuthContext(ctx context.Context) (string, error) {
 		return "", err
 	}
 
-	m.kustoToken = token
-	m.authTokenCacheExpiration = time.Now().UTC().Add(time.Hour)
+	m.authContext.Store(authContext{value: token.AuthContext, fetchTime: time.Now().UTC()})
 	return token.AuthContext, nil
 }
 
@@ -284,7 +392,11 @@ func (i *Ingestion) importRec(rec ingestResc) error {
 func (m *Manager) fetch(ctx context.Context) error {
 	m.fetchLock.Lock()
 	defer m.fetchLock.Unlock()
+	return m.fetchLocked(ctx)
+}
 
+// fetchLocked is fetch, with m.fetchLock already held.
+func (m *Manager) fetchLocked(ctx context.Context) error {
 	var rows *kusto.RowIterator
 	retryCtx := backoff.WithContext(InitBackoff(), ctx)
 	err := backoff.Retry(func() error {
@@ -333,6 +445,7 @@ func (m *Manager) fetch(ctx context.Context) error {
 	return nil
 }
 
+// fetchRetry fetches the resources, retrying a few times on failure. m.fetchLock must be held.
 func (m *Manager) fetchRetry(ctx context.Context) error {
 	attempts := 0
 	for {
@@ -344,7 +457,7 @@ func (m *Manager) fetchRetry(ctx context.Context) error {
 		}
 
 		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-		err := m.fetch(ctx)
+		err := m.fetchLocked(ctx)
 		cancel()
 		if err != nil {
 			attempts++
@@ -359,14 +472,20 @@ func (m *Manager) fetchRetry(ctx context.Context) error {
 }
 
 // Resources returns information about the ingestion resources. This will used cached information instead
-// of fetching from source.
+// of fetching from source, unless there is none that is still valid.
 func (m *Manager) Resources() (Ingestion, error) {
-	lastFetchTime, ok := m.lastFetchTime.Load().(time.Time)
-	if !ok || lastFetchTime.Add(2*fetchInterval).Before(time.Now().UTC()) {
-		err := m.fetchRetry(context.Background())
-		if err != nil {
-			return Ingestion{}, err
-		}
+	if i, ok := m.validResources(); ok {
+		return i, nil
+	}
+
+	// Only one caller fetches the resources, the others wait for it and use what it fetched.
+	m.fetchLock.Lock()
+	defer m.fetchLock.Unlock()
+	if i, ok := m.validResources(); ok {
+		return i, nil
+	}
+	if err := m.fetchRetry(context.Background()); err != nil {
+		return Ingestion{}, err
 	}
 
 	i, ok := m.resources.Load().(Ingestion)
@@ -376,9 +495,54 @@ func (m *Manager) Resources() (Ingestion, error) {
 	return i, nil
 }
 
+// validResources returns the fetched resources, if there are some that are still valid.
+func (m *Manager) validResources() (Ingestion, bool) {
+	lastFetchTime, ok := m.lastFetchTime.Load().(time.Time)
+	if !ok || lastFetchTime.Add(m.validity()).Before(time.Now().UTC()) {
+		return Ingestion{}, false
+	}
+	i, ok := m.resources.Load().(Ingestion)
+	return i, ok
+}
+
 func InitBackoff() backoff.BackOff {
 	exp := backoff.NewExponentialBackOff()
 	exp.InitialInterval = defaultInitialInterval
 	exp.Multiplier = defaultMultiplier
 	return backoff.WithMaxRetries(exp, retryCount)
 }
+
+// RankedContainers returns the blob containers of the ingestion resources in the order in which they should be tried:
+// in round-robin over the healthy ones, then the ones that recently failed.
+func (m *Manager) RankedContainers() ([]*URI, error) {
+	i, err := m.Resources()
+	if err != nil {
+		return nil, err
+	}
+	return m.ranking.rank(containersKind, i.Containers), nil
+}
+
+// RankedQueues returns the queues of the ingestion resources in the order in which they should be tried: in
+// round-robin over the healthy ones, then the ones that recently failed.
+func (m *Manager) RankedQueues() ([]*URI, error) {
+	i, err := m.Resources()
+	if err != nil {
+		return nil, err
+	}
+	return m.ranking.rank(queuesKind, i.Queues), nil
+}
+
+// ReportSuccess records a successful upload to a container or enqueue to a queue.
+func (m *Manager) ReportSuccess(u *URI) {
+	m.ranking.report(u, false)
+}
+
+// ReportFailure records a failed upload to a container or enqueue to a queue, which demotes it for the cooldown.
+func (m *Manager) ReportFailure(u *URI) {
+	m.ranking.report(u, true)
+}
+
+// Stats returns the counters of the uses of the storage resources, by URL without SAS.
+func (m *Manager) Stats() map[string]Stats {
+	return m.ranking.snapshot()
+}
@@ -2,9 +2,15 @@ package resources
 
 import (
 	"context"
-	"github.com/stretchr/testify/assert"
+	"errors"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
@@ -247,3 +253,108 @@ func TestResources(t *testing.T) {
 		})
 	}
 }
+
+// countingMgmt answers the resources and auth context Mgmt() calls, and counts them.
+type countingMgmt struct {
+	mu    sync.Mutex
+	calls map[string]int
+	fail  bool
+}
+
+func newCountingMgmt() *countingMgmt {
+	return &countingMgmt{calls: map[string]int{}}
+}
+
+func (c *countingMgmt) Mgmt(ctx context.Context, db string, query kusto.Stmt, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+	c.mu.Lock()
+	c.calls[query.String()]++
+	fail := c.fail
+	c.mu.Unlock()
+
+	if fail {
+		return nil, errors.New("some mgmt error")
+	}
+	if query.String() == ".get ingestion resources" {
+		return SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+	}
+	return FakeAuthContext([]value.Values{{value.String{Valid: true, Value: "authtoken"}}}, false).Mgmt(ctx, db, query, options...)
+}
+
+func (c *countingMgmt) count(query string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[query]
+}
+
+func (c *countingMgmt) setFail(fail bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fail = fail
+}
+
+func TestConcurrentFetch(t *testing.T) {
+	t.Parallel()
+
+	mgmt := newCountingMgmt()
+	manager := &Manager{client: mgmt}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := manager.Resources()
+			assert.NoError(t, err)
+			auth, err := manager.AuthContext(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "authtoken", auth)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, mgmt.count(".get ingestion resources"))
+	assert.Equal(t, 1, mgmt.count(".get kusto identity token"))
+}
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+
+	mgmt := newCountingMgmt()
+	manager, err := New(mgmt, WithRefreshInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer manager.Close()
+
+	require.Eventually(t, func() bool {
+		return mgmt.count(".get ingestion resources") >= 2 && mgmt.count(".get kusto identity token") >= 2
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestRefreshFailureKeepsValidResources(t *testing.T) {
+	t.Parallel()
+
+	mgmt := newCountingMgmt()
+	manager := &Manager{client: mgmt}
+	require.NoError(t, manager.refresh(context.Background()))
+
+	mgmt.setFail(true)
+	assert.Error(t, manager.refresh(context.Background()))
+
+	got, err := manager.Resources()
+	assert.NoError(t, err)
+	assert.Len(t, got.Containers, 1)
+	auth, err := manager.AuthContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "authtoken", auth)
+	assert.Equal(t, 2, mgmt.count(".get ingestion resources"))
+	assert.Equal(t, 1, mgmt.count(".get kusto identity token"))
+}
+
+func TestJitter(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 100; i++ {
+		got := jitter(time.Hour)
+		assert.GreaterOrEqual(t, got, 54*time.Minute)
+		assert.LessOrEqual(t, got, 66*time.Minute)
+	}
+}
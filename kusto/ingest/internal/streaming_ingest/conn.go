@@ -10,7 +10,6 @@ import (
 	"net/url"
 	"path"
 	"regexp"
-	"strings"
 	"sync"
 
 	"github.com/Azure/azure-kusto-go/kusto"
@@ -66,8 +65,12 @@ func newWithoutValidation(endpoint string, auth kusto.Authorization, client *htt
 		headers.Add("x-ms-client-version", clientDetails.ClientVersionForTracing())
 	}
 
-	// TODO(daniel/jdoak): Get rid of this Replace stuff. I mean, its just hacky.
-	u, err := url.Parse(strings.Replace(endpoint, "ingest-", "", 1))
+	// Streaming ingestion is sent to the query endpoint of the cluster.
+	endpoint, err := kusto.ToQueryEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, errors.E(
 			errors.OpServConn,
@@ -157,7 +160,13 @@ func (c *Conn) StreamIngest(ctx context.Context, db, table string, payload io.Re
 		if err != nil {
 			return err
 		}
-		return errors.HTTP(writeOp, resp.Status, resp.StatusCode, body, "streaming ingest issue")
+		httpErr := errors.HTTP(writeOp, resp.Status, resp.StatusCode, body, "streaming ingest issue")
+		httpErr.Header = resp.Header
+		httpErr.SetCorrelation(headers.Get("x-ms-client-request-id"), resp.Header.Get("x-ms-activity-id"), resp.StatusCode)
+		if d := httpErr.ServiceHints().RetryAfter; d != nil {
+			httpErr.SetRetryAfter(*d)
+		}
+		return httpErr
 	}
 	return nil
 }
@@ -72,7 +72,18 @@ func (m *Managed) FromReader(ctx context.Context, reader io.Reader, options ...F
 	return m.managedStreamImpl(ctx, reader, props)
 }
 
+// streamable returns false if props has ingestion properties that the streaming API cannot carry, in which case the
+// data is queued.
+func streamable(props properties.All) bool {
+	a := props.Ingestion.Additional
+	return len(a.Tags) == 0 && a.IngestIfNotExists == "" && a.CreationTime.IsZero() && !a.IgnoreFirstRecord && a.ZipPattern == ""
+}
+
 func (m *Managed) managedStreamImpl(ctx context.Context, payload io.Reader, props properties.All) (*Result, error) {
+	if !streamable(props) {
+		return m.queued.fromReader(ctx, payload, []FileOption{}, props)
+	}
+
 	compress := !props.Source.DontCompress
 	if compress {
 		payload = gzip.Compress(payload)
@@ -147,6 +158,12 @@ func (m *Managed) newProp() properties.All {
 	}
 }
 
+// ResourceStats returns the counters of the uses of the storage resources of the queued ingestions, by URL without SAS,
+// for diagnostics.
+func (m *Managed) ResourceStats() map[string]ResourceStats {
+	return m.queued.ResourceStats()
+}
+
 func (m *Managed) Close() error {
 	var err error
 	err = m.queued.Close()
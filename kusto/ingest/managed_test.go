@@ -290,9 +290,41 @@ func TestManaged(t *testing.T) {
 			expectedCounter: 1,
 			expectedStatus:  Queued,
 		},
+		{
+			name:    "TestQueuedOnlyOptions",
+			options: []FileOption{IngestByTags([]string{"batch-1"}), IgnoreFirstRecord()},
+			onStreamIngest: func(t *testing.T, ctx context.Context, db, table string, payload io.Reader, format properties.DataFormat, mappingName string,
+				clientRequestId string) error {
+				require.Fail(t, "Options that streaming does not support shouldn't try to stream")
+				return errors.E(errors.OpIngestStream, errors.KHTTPError, fmt.Errorf("error"))
+			},
+			onMgmt: func(t *testing.T, ctx context.Context, db string, query kusto.Stmt, options ...kusto.MgmtOption) (*kusto.RowIterator, error) {
+				// .get ingestion resources is always called in the ctor
+				if query.String() == ".get ingestion resources" {
+					return resources.SuccessfulFakeResources().Mgmt(ctx, db, query, options...)
+				}
+				if query.String() == ".get kusto identity token" {
+					return nil, nil
+				}
+
+				require.Fail(t, "Unexpected queued ingest call")
+				return nil, nil
+			},
+			onReader: func(t *testing.T, ctx context.Context, reader io.Reader, props properties.All) (string, error) {
+				counter++
+				assert.Equal(t, []string{"ingest-by:batch-1"}, props.Ingestion.Additional.Tags)
+				assert.True(t, props.Ingestion.Additional.IgnoreFirstRecord)
+				all, err := io.ReadAll(reader)
+				assert.NoError(t, err)
+				assert.Equal(t, data, all)
+				return "", nil
+			},
+			expectedCounter: 1,
+			expectedStatus:  Queued,
+		},
 		{
 			name:     "TestBlob",
-			options:  []FileOption{},
+			options:  []FileOption{FileFormat(CSV)},
 			blobPath: someBlobPath,
 			onStreamIngest: func(t *testing.T, ctx context.Context, db, table string, payload io.Reader, format properties.DataFormat, mappingName string,
 				clientRequestId string) error {
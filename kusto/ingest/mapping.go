@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+)
+
+// ColumnMapping maps a column of the table to the data being ingested. A []ColumnMapping can be passed to the
+// IngestionMapping() option, which checks that each column mapping has the fields required by the kind of the mapping.
+// For more details, see: https://docs.microsoft.com/en-us/azure/data-explorer/kusto/management/mappings
+type ColumnMapping struct {
+	// Column is the name of the column of the table. Required.
+	Column string
+	// DataType is the type of the column, which is only needed if the column does not exist in the table yet.
+	DataType types.Column
+	// Path is the path of the value in the data for JSON, AVRO, Parquet and ORC mappings, such as "$.event.time".
+	Path string
+	// Ordinal is the index of the value in the row of a CSV mapping, use the Ordinal() function to set it.
+	Ordinal *int
+	// ConstValue is a constant value to ingest in the column instead of a value of the data.
+	ConstValue string
+	// Transform is a transformation applied to the value, such as "DateTimeFromUnixSeconds".
+	Transform string
+}
+
+// Ordinal returns a pointer to i, to set ColumnMapping.Ordinal.
+func Ordinal(i int) *int {
+	return &i
+}
+
+// MarshalJSON implements json.Marshaler, with the layout of the mappings of the service.
+func (c ColumnMapping) MarshalJSON() ([]byte, error) {
+	props := map[string]string{}
+	if c.Path != "" {
+		props["Path"] = c.Path
+	}
+	if c.Ordinal != nil {
+		props["Ordinal"] = strconv.Itoa(*c.Ordinal)
+	}
+	if c.ConstValue != "" {
+		props["ConstValue"] = c.ConstValue
+	}
+	if c.Transform != "" {
+		props["Transform"] = c.Transform
+	}
+
+	return json.Marshal(struct {
+		Column     string
+		DataType   types.Column      `json:",omitempty"`
+		Properties map[string]string `json:",omitempty"`
+	}{
+		Column:     c.Column,
+		DataType:   c.DataType,
+		Properties: props,
+	})
+}
+
+// validate checks that c has the fields required by a mapping of kind mappingKind.
+func (c ColumnMapping) validate(mappingKind DataFormat) error {
+	if c.Column == "" {
+		return errors.ES(errors.OpUnknown, errors.KClientArgs, "a ColumnMapping must have a Column").SetNoRetry()
+	}
+	if c.DataType != "" && !c.DataType.Valid() {
+		return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q has an invalid DataType %q", c.Column, c.DataType).SetNoRetry()
+	}
+
+	if mappingKind == CSV {
+		if c.Path != "" {
+			return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q cannot have a Path in a CSV mapping", c.Column).SetNoRetry()
+		}
+		if c.Ordinal == nil && c.ConstValue == "" {
+			return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q must have an Ordinal or a ConstValue in a CSV mapping", c.Column).SetNoRetry()
+		}
+		if c.Ordinal != nil && *c.Ordinal < 0 {
+			return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q has a negative Ordinal %d", c.Column, *c.Ordinal).SetNoRetry()
+		}
+		return nil
+	}
+
+	if c.Ordinal != nil {
+		return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q can only have an Ordinal in a CSV mapping", c.Column).SetNoRetry()
+	}
+	if c.Path == "" && c.ConstValue == "" {
+		return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q must have a Path or a ConstValue in a %v mapping", c.Column, mappingKind).SetNoRetry()
+	}
+	if mappingKind == JSON && c.Path != "" && !strings.HasPrefix(c.Path, "$") {
+		return errors.ES(errors.OpUnknown, errors.KClientArgs, "ColumnMapping for column %q has a Path %q that does not start with $", c.Column, c.Path).SetNoRetry()
+	}
+	return nil
+}
+
+// marshalColumnMappings validates the column mappings of a mapping of kind mappingKind and returns their JSON.
+func marshalColumnMappings(mappings []ColumnMapping, mappingKind DataFormat) (string, error) {
+	if len(mappings) == 0 {
+		return "", errors.ES(errors.OpUnknown, errors.KClientArgs, "IngestionMapping() option was passed no ColumnMapping").SetNoRetry()
+	}
+	for _, m := range mappings {
+		if err := m.validate(mappingKind); err != nil {
+			return "", err
+		}
+	}
+
+	b, err := json.Marshal(mappings)
+	if err != nil {
+		return "", errors.ES(errors.OpUnknown, errors.KClientArgs, "could not JSON encode the ColumnMappings: %s", err).SetNoRetry()
+	}
+	return string(b), nil
+}
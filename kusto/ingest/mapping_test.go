@@ -0,0 +1,88 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnMappings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		mappings []ColumnMapping
+		kind     DataFormat
+		want     string
+		wantErr  bool
+	}{
+		{
+			desc: "CSV",
+			mappings: []ColumnMapping{
+				{Column: "Name", DataType: types.String, Ordinal: Ordinal(0)},
+				{Column: "Source", ConstValue: "app"},
+			},
+			kind: CSV,
+			want: `[{"Column":"Name","DataType":"string","Properties":{"Ordinal":"0"}},{"Column":"Source","Properties":{"ConstValue":"app"}}]`,
+		},
+		{
+			desc: "JSON",
+			mappings: []ColumnMapping{
+				{Column: "Time", DataType: types.DateTime, Path: "$.ts", Transform: "DateTimeFromUnixSeconds"},
+			},
+			kind: JSON,
+			want: `[{"Column":"Time","DataType":"datetime","Properties":{"Path":"$.ts","Transform":"DateTimeFromUnixSeconds"}}]`,
+		},
+		{
+			desc:     "Parquet",
+			mappings: []ColumnMapping{{Column: "Name", Path: "$.name"}},
+			kind:     Parquet,
+			want:     `[{"Column":"Name","Properties":{"Path":"$.name"}}]`,
+		},
+		{desc: "No column mappings", kind: CSV, wantErr: true},
+		{desc: "No column", mappings: []ColumnMapping{{Ordinal: Ordinal(0)}}, kind: CSV, wantErr: true},
+		{desc: "Invalid data type", mappings: []ColumnMapping{{Column: "a", DataType: "text", Ordinal: Ordinal(0)}}, kind: CSV, wantErr: true},
+		{desc: "CSV without ordinal", mappings: []ColumnMapping{{Column: "a"}}, kind: CSV, wantErr: true},
+		{desc: "CSV with path", mappings: []ColumnMapping{{Column: "a", Path: "$.a", Ordinal: Ordinal(0)}}, kind: CSV, wantErr: true},
+		{desc: "CSV with negative ordinal", mappings: []ColumnMapping{{Column: "a", Ordinal: Ordinal(-1)}}, kind: CSV, wantErr: true},
+		{desc: "JSON without path", mappings: []ColumnMapping{{Column: "a"}}, kind: JSON, wantErr: true},
+		{desc: "JSON with ordinal", mappings: []ColumnMapping{{Column: "a", Path: "$.a", Ordinal: Ordinal(0)}}, kind: JSON, wantErr: true},
+		{desc: "JSON with invalid path", mappings: []ColumnMapping{{Column: "a", Path: "a"}}, kind: JSON, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			props := properties.All{}
+			err := IngestionMapping(test.mappings, test.kind).Run(&props, QueuedClient, FromFile)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, test.want, props.Ingestion.Additional.IngestionMapping)
+			assert.Equal(t, test.kind, props.Ingestion.Additional.IngestionMappingType)
+			assert.Equal(t, test.kind, props.Ingestion.Additional.Format)
+		})
+	}
+}
+
+func TestMappingAndMappingRef(t *testing.T) {
+	t.Parallel()
+
+	mapping := IngestionMapping([]ColumnMapping{{Column: "a", Path: "$.a"}}, JSON)
+	ref := IngestionMappingRef("mapping", JSON)
+
+	props := properties.All{}
+	require.NoError(t, mapping.Run(&props, QueuedClient, FromFile))
+	assert.Equal(t, errMappingAndMappingRef, ref.Run(&props, QueuedClient, FromFile))
+
+	props = properties.All{}
+	require.NoError(t, ref.Run(&props, QueuedClient, FromFile))
+	assert.Equal(t, errMappingAndMappingRef, mapping.Run(&props, QueuedClient, FromFile))
+}
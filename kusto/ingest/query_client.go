@@ -1,19 +1,21 @@
 package ingest
 
 import (
-	"context"
 	"io"
 	"net/http"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 )
 
+// QueryClient is the part of *kusto.Client used by the ingestion clients.
 type QueryClient interface {
 	io.Closer
+	kusto.Querier
+	kusto.Mgmter
 	Auth() kusto.Authorization
 	Endpoint() string
-	Query(ctx context.Context, db string, query kusto.Stmt, options ...kusto.QueryOption) (*kusto.RowIterator, error)
-	Mgmt(ctx context.Context, db string, query kusto.Stmt, options ...kusto.MgmtOption) (*kusto.RowIterator, error)
 	HttpClient() *http.Client
 	ClientDetails() *kusto.ClientDetails
 }
+
+var _ QueryClient = (*kusto.Client)(nil)
@@ -3,24 +3,41 @@ package ingest
 import (
 	"context"
 	"fmt"
-	"math/rand"
+	"strings"
 	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto"
+
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/properties"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/resources"
 	"github.com/Azure/azure-kusto-go/kusto/ingest/internal/status"
+	"github.com/cenkalti/backoff/v4"
 )
 
+// statusTable reads the status records from the status table. Exists to allow fakes in tests.
+type statusTable interface {
+	Read(ingestionSourceID string) (map[string]interface{}, error)
+}
+
 // Result provides a way for users track the state of ingestion jobs.
 type Result struct {
 	record        statusRecord
-	tableClient   *status.TableClient
+	tableClient   statusTable
 	reportToTable bool
+	// ingestedAt is when the ingestion was started.
+	ingestedAt time.Time
+	// ingestByTag is the first ingest-by: tag of the ingestion, if any.
+	ingestByTag string
+	// newBackoff returns the intervals between the reads of the status table, nil for newStatusBackoff().
+	newBackoff func() backoff.BackOff
 }
 
+// ingestByPrefix is the prefix of the extent tags that ingest-by: queries can find.
+const ingestByPrefix = "ingest-by:"
+
 // newResult creates an initial ingestion status record.
 func newResult() *Result {
-	ret := &Result{}
+	ret := &Result{ingestedAt: time.Now()}
 
 	ret.record = newStatusRecord()
 	return ret
@@ -30,6 +47,24 @@ func newResult() *Result {
 func (r *Result) putProps(props properties.All) {
 	r.reportToTable = props.Ingestion.ReportMethod == properties.ReportStatusToTable || props.Ingestion.ReportMethod == properties.ReportStatusToQueueAndTable
 	r.record.FromProps(props)
+	for _, tag := range props.Ingestion.Additional.Tags {
+		if strings.HasPrefix(tag, ingestByPrefix) {
+			r.ingestByTag = tag
+			break
+		}
+	}
+}
+
+// Marker returns a kusto.IngestMarker of the ingested data, to pass to kusto.Client.QueryAfter() in order to query
+// the data once it is visible. The marker has the ingest-by: tag of the ingestion if one was set with Tags(), which
+// is the most precise, otherwise it relies on the time the ingestion was started.
+func (r *Result) Marker() kusto.IngestMarker {
+	return kusto.IngestMarker{
+		Database:   r.record.Database,
+		Table:      r.record.Table,
+		Tag:        r.ingestByTag,
+		IngestedAt: r.ingestedAt,
+	}
 }
 
 // putQueued sets the initial success status depending on status reporting state
@@ -52,7 +87,7 @@ func (r *Result) putQueued(mgr *resources.Manager) {
 	if len(managerResources.Tables) == 0 {
 		r.record.Status = StatusRetrievalFailed
 		r.record.FailureStatus = Permanent
-		r.record.Details = "Ingestion resources do not include a status table URI: " + err.Error()
+		r.record.Details = "Ingestion resources do not include a status table URI"
 		return
 	}
 
@@ -79,7 +114,9 @@ func (r *Result) putQueued(mgr *resources.Manager) {
 }
 
 // Wait returns a channel that can be checked for ingestion results.
-// In order to check actual status please use the ReportResultToTable option when ingesting data.
+// In order to check actual status please use the ReportResultToTable option when ingesting data. The status table is
+// then read with a growing interval until the status is final, and the channel receives the status record if the
+// ingestion did not succeed, which also happens when ctx is done first.
 func (r *Result) Wait(ctx context.Context) chan error {
 	ch := make(chan error, 1)
 
@@ -100,49 +137,103 @@ func (r *Result) Wait(ctx context.Context) chan error {
 	return ch
 }
 
+const (
+	// statusPollInterval is the first interval between the reads of the status table, which grows up to
+	// statusPollMaxInterval as the ingestion takes longer.
+	statusPollInterval    = 10 * time.Second
+	statusPollMaxInterval = 2 * time.Minute
+	// statusReadAttempts is the number of consecutive failed reads of the status table after which polling stops.
+	statusReadAttempts = 3
+)
+
+// newStatusBackoff returns the intervals between the reads of the status table.
+func newStatusBackoff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = statusPollInterval
+	b.MaxInterval = statusPollMaxInterval
+	b.MaxElapsedTime = 0 // Until the context is done.
+	b.Reset()
+	return b
+}
+
+// poll reads the status table until the status of the record is final, the reads fail statusReadAttempts times in a
+// row or ctx is done.
 func (r *Result) poll(ctx context.Context) {
-	const pollInterval = 10 * time.Second
-	attempts := 3
-	delay := [3]int{120, 60, 10} // attempts are counted backwards
+	if r.tableClient == nil {
+		return
+	}
 
-	// create a table client
-	if r.tableClient != nil {
-		// Create a ticker to poll the table in 10 second intervals.
-		timer := time.NewTimer(pollInterval)
-		defer timer.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				r.record.Status = StatusRetrievalCanceled
+	newBackoff := newStatusBackoff
+	if r.newBackoff != nil {
+		newBackoff = r.newBackoff
+	}
+	b := newBackoff()
+	timer := time.NewTimer(b.NextBackOff())
+	defer timer.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			r.record.Status = StatusRetrievalCanceled
+			r.record.FailureStatus = Transient
+			return
+		case <-timer.C:
+		}
+
+		smap, err := r.tableClient.Read(r.record.IngestionSourceID.String())
+		if err != nil {
+			failures++
+			if failures == statusReadAttempts {
+				r.record.Status = StatusRetrievalFailed
 				r.record.FailureStatus = Transient
+				r.record.Details = "Failed reading from Status Table: " + err.Error()
+				return
+			}
+		} else {
+			failures = 0
+			r.record.FromMap(smap)
+			if r.record.Status.IsFinal() {
 				return
-
-			case <-timer.C:
-				smap, err := r.tableClient.Read(r.record.IngestionSourceID.String())
-				if err != nil {
-					if attempts == 0 {
-						r.record.Status = StatusRetrievalFailed
-						r.record.FailureStatus = Transient
-						r.record.Details = "Failed reading from Status Table: " + err.Error()
-						return
-					}
-
-					attempts = attempts - 1
-					time.Sleep(time.Duration(delay[attempts]+rand.Intn(5)) * time.Second)
-				} else {
-					r.record.FromMap(smap)
-					if r.record.Status.IsFinal() {
-						return
-					}
-				}
-
-				timer.Reset(pollInterval)
 			}
 		}
+
+		timer.Reset(b.NextBackOff())
 	}
 }
 
+// Statuses is the aggregated status of the ingestions of several sources, as returned by WaitAll().
+type Statuses struct {
+	// Counts are the numbers of ingestions by status.
+	Counts map[StatusCode]int
+	// Failures are the errors of the ingestions that did not succeed, in the order of their Results. Their details can be
+	// read with GetIngestionStatus(), GetErrorCode(), GetErrorDetails() and the other functions of this package.
+	Failures []error
+}
+
+// Succeeded returns true if all the ingestions succeeded.
+func (s Statuses) Succeeded() bool {
+	return len(s.Failures) == 0
+}
+
+// WaitAll waits for all the results, as Wait() does, and returns their aggregated status. The ingestions that are not
+// finished when ctx is done have the StatusRetrievalCanceled status.
+func WaitAll(ctx context.Context, results ...*Result) Statuses {
+	chans := make([]chan error, len(results))
+	for i, r := range results {
+		chans[i] = r.Wait(ctx)
+	}
+
+	s := Statuses{Counts: map[StatusCode]int{}}
+	for i, ch := range chans {
+		if err := <-ch; err != nil {
+			s.Failures = append(s.Failures, err)
+		}
+		s.Counts[results[i].record.Status]++
+	}
+	return s
+}
+
 // IsStatusRecord verifies that the given error is a status record.
 func IsStatusRecord(err error) bool {
 	_, ok := err.(statusRecord)
@@ -167,6 +258,24 @@ func GetIngestionFailureStatus(err error) (FailureStatusCode, error) {
 	return Unknown, fmt.Errorf("Error is not an Ingestion Result")
 }
 
+// GetErrorDetails extracts the human readable description of the failure from an ingestion error
+func GetErrorDetails(err error) (string, error) {
+	if s, ok := err.(statusRecord); ok {
+		return s.Details, nil
+	}
+
+	return "", fmt.Errorf("Error is not an Ingestion Result")
+}
+
+// OriginatesFromUpdatePolicy indicates whether an ingestion error originated from an update policy of the table
+func OriginatesFromUpdatePolicy(err error) bool {
+	if s, ok := err.(statusRecord); ok {
+		return s.OriginatesFromUpdatePolicy
+	}
+
+	return false
+}
+
 // GetErrorCode extracts the error code from an ingestion error
 func GetErrorCode(err error) (string, error) {
 	if s, ok := err.(statusRecord); ok {
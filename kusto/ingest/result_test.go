@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatusTable returns its records in order, then the last one.
+type fakeStatusTable struct {
+	mu      sync.Mutex
+	records []map[string]interface{}
+	errs    []error
+	reads   int
+}
+
+func (f *fakeStatusTable) Read(string) (map[string]interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.reads
+	f.reads++
+	if i < len(f.errs) && f.errs[i] != nil {
+		return nil, f.errs[i]
+	}
+	if i >= len(f.records) {
+		i = len(f.records) - 1
+	}
+	return f.records[i], nil
+}
+
+func pendingResult(table statusTable) *Result {
+	r := newResult()
+	r.record.IngestionSourceID = uuid.New()
+	r.record.Status = Pending
+	r.reportToTable = true
+	r.tableClient = table
+	r.newBackoff = func() backoff.BackOff { return backoff.NewConstantBackOff(time.Millisecond) }
+	return r
+}
+
+func TestResultWait(t *testing.T) {
+	t.Parallel()
+
+	pending := map[string]interface{}{"Status": "Pending"}
+	failed := map[string]interface{}{
+		"Status":                     "Failed",
+		"FailureStatus":              "Permanent",
+		"ErrorCode":                  "BadRequest_EmptyBlob",
+		"Details":                    "Blob is empty",
+		"OriginatesFromUpdatePolicy": true,
+	}
+	readErr := fmt.Errorf("read error")
+
+	tests := []struct {
+		desc        string
+		table       *fakeStatusTable
+		wantStatus  StatusCode
+		wantCode    string
+		wantDetails string
+		wantPolicy  bool
+	}{
+		{
+			desc:       "Succeeded",
+			table:      &fakeStatusTable{records: []map[string]interface{}{pending, pending, {"Status": "Succeeded"}}},
+			wantStatus: Succeeded,
+		},
+		{
+			desc:        "Failed",
+			table:       &fakeStatusTable{records: []map[string]interface{}{pending, failed}},
+			wantStatus:  Failed,
+			wantCode:    "BadRequest_EmptyBlob",
+			wantDetails: "Blob is empty",
+			wantPolicy:  true,
+		},
+		{
+			desc:       "Read errors are retried",
+			table:      &fakeStatusTable{records: []map[string]interface{}{pending, pending, {"Status": "Succeeded"}}, errs: []error{readErr, readErr}},
+			wantStatus: Succeeded,
+		},
+		{
+			desc:        "Too many read errors",
+			table:       &fakeStatusTable{records: []map[string]interface{}{pending}, errs: []error{readErr, readErr, readErr}},
+			wantStatus:  StatusRetrievalFailed,
+			wantCode:    unknownString,
+			wantDetails: "Failed reading from Status Table: read error",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := <-pendingResult(test.table).Wait(context.Background())
+			if test.wantStatus == Succeeded {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, IsStatusRecord(err))
+
+			status, _ := GetIngestionStatus(err)
+			assert.Equal(t, test.wantStatus, status)
+			code, _ := GetErrorCode(err)
+			assert.Equal(t, test.wantCode, code)
+			details, _ := GetErrorDetails(err)
+			assert.Equal(t, test.wantDetails, details)
+			assert.Equal(t, test.wantPolicy, OriginatesFromUpdatePolicy(err))
+		})
+	}
+}
+
+func TestResultWaitCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := pendingResult(&fakeStatusTable{records: []map[string]interface{}{{"Status": "Pending"}}})
+	ch := r.Wait(ctx)
+	cancel()
+
+	err := <-ch
+	require.Error(t, err)
+	status, _ := GetIngestionStatus(err)
+	assert.Equal(t, StatusRetrievalCanceled, status)
+	assert.True(t, IsRetryable(err))
+}
+
+func TestWaitAll(t *testing.T) {
+	t.Parallel()
+
+	queued := newResult()
+	queued.record.Status = Queued
+
+	results := []*Result{
+		pendingResult(&fakeStatusTable{records: []map[string]interface{}{{"Status": "Succeeded"}}}),
+		pendingResult(&fakeStatusTable{records: []map[string]interface{}{{"Status": "Failed", "ErrorCode": "Stream_NoDataToIngest"}}}),
+		queued,
+		pendingResult(&fakeStatusTable{records: []map[string]interface{}{{"Status": "Pending"}, {"Status": "Succeeded"}}}),
+	}
+
+	got := WaitAll(context.Background(), results...)
+	assert.False(t, got.Succeeded())
+	assert.Equal(t, map[StatusCode]int{Succeeded: 2, Failed: 1, Queued: 1}, got.Counts)
+	require.Len(t, got.Failures, 1)
+	code, err := GetErrorCode(got.Failures[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Stream_NoDataToIngest", code)
+
+	assert.True(t, WaitAll(context.Background()).Succeeded())
+}
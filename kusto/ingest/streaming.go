@@ -1,6 +1,7 @@
 package ingest
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -28,6 +29,16 @@ type Streaming struct {
 
 var FileIsBlobErr = errors.ES(errors.OpIngestStream, errors.KClientArgs, "blobstore paths are not supported for streaming")
 
+// StreamingSizeLimitErr is returned by Streaming when the payload to send, after compression, is larger than the 4 MB
+// limit of streaming ingestion. Such data can be ingested with the queued client, or with the managed client, which
+// falls back to queued ingestion.
+var StreamingSizeLimitErr = errors.ES(
+	errors.OpIngestStream,
+	errors.KLimitsExceeded,
+	"the payload is larger than the %d bytes limit of streaming ingestion, use queued or managed ingestion instead",
+	maxStreamingSize,
+).SetNoRetry()
+
 // NewStreaming is the constructor for Streaming.
 // More information can be found here:
 // https://docs.microsoft.com/en-us/azure/kusto/management/create-ingestion-mapping-command
@@ -112,16 +123,18 @@ func (i *Streaming) FromReader(ctx context.Context, reader io.Reader, options ..
 }
 
 func streamImpl(c streamIngestor, ctx context.Context, payload io.Reader, props properties.All) (*Result, error) {
-	compress := !props.Source.DontCompress
-	if compress {
-		payload = gzip.Compress(payload)
+	payload, err := readStreamingPayload(payload, !props.Source.DontCompress)
+	if err != nil {
+		return nil, err
 	}
 
 	if props.Ingestion.Additional.Format == DFUnknown {
 		props.Ingestion.Additional.Format = CSV
 	}
 
-	err := c.StreamIngest(ctx, props.Ingestion.DatabaseName, props.Ingestion.TableName, payload, props.Ingestion.Additional.Format,
+	// The result is created first, so that the time of its marker is not after the data is ingested.
+	result := newResult()
+	err = c.StreamIngest(ctx, props.Ingestion.DatabaseName, props.Ingestion.TableName, payload, props.Ingestion.Additional.Format,
 		props.Ingestion.Additional.IngestionMappingRef,
 		props.Streaming.ClientRequestId)
 
@@ -137,13 +150,36 @@ func streamImpl(c streamIngestor, ctx context.Context, payload io.Reader, props
 		return nil, err
 	}
 
-	result := newResult()
 	result.putProps(props)
 	result.record.Status = "Success"
 
 	return result, nil
 }
 
+// readStreamingPayload reads the payload to send, compressing it with gzip if compress is set, and closes it. It
+// returns StreamingSizeLimitErr if what is sent would be larger than maxStreamingSize.
+func readStreamingPayload(payload io.Reader, compress bool) (io.Reader, error) {
+	if closer, ok := payload.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	toSend := payload
+	if compress {
+		zr := gzip.Compress(payload)
+		defer zr.(io.Closer).Close()
+		toSend = zr
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(toSend, maxStreamingSize+1))
+	if err != nil {
+		return nil, errors.E(errors.OpIngestStream, errors.KIO, err)
+	}
+	if len(buf) > maxStreamingSize {
+		return nil, StreamingSizeLimitErr
+	}
+	return bytes.NewReader(buf), nil
+}
+
 func (i *Streaming) newProp() properties.All {
 	return properties.All{
 		Ingestion: properties.Ingestion{
@@ -3,11 +3,13 @@ package ingest
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto"
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -223,3 +225,88 @@ func TestStreaming(t *testing.T) {
 	}
 
 }
+
+func TestResultMarker(t *testing.T) {
+	t.Parallel()
+
+	var ingested time.Time
+	streaming := Streaming{
+		db:     "defaultDb",
+		table:  "defaultTable",
+		client: mockClient{endpoint: "https://test.kusto.windows.net", auth: kusto.Authorization{}},
+		streamConn: fakeStreamIngestor{
+			onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format properties.DataFormat, mappingName string, clientRequestId string) error {
+				ingested = time.Now()
+				return nil
+			},
+		},
+	}
+
+	result, err := streaming.FromReader(context.Background(), strings.NewReader("a,b\n"), Table("otherTable"))
+	require.NoError(t, err)
+
+	marker := result.Marker()
+	assert.Equal(t, "defaultDb", marker.Database)
+	assert.Equal(t, "otherTable", marker.Table)
+	assert.Empty(t, marker.Tag)
+	assert.False(t, marker.IngestedAt.IsZero())
+	assert.False(t, marker.IngestedAt.After(ingested), "the marker time must not be after the ingestion")
+
+	// The first ingest-by: tag is used.
+	result = newResult()
+	props := properties.All{Ingestion: properties.Ingestion{DatabaseName: "db", TableName: "T"}}
+	props.Ingestion.Additional.Tags = []string{"drop-by:old", "ingest-by:batch-1", "ingest-by:batch-2"}
+	result.putProps(props)
+	marker = result.Marker()
+	assert.Equal(t, kusto.IngestMarker{Database: "db", Table: "T", Tag: "ingest-by:batch-1", IngestedAt: result.ingestedAt}, marker)
+}
+
+func TestStreamingSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	// Random data does not compress, so it is still larger than the limit after compression.
+	data := make([]byte, maxStreamingSize+mb)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		options []FileOption
+		wantErr error
+	}{
+		{name: "Compressed", data: data, wantErr: StreamingSizeLimitErr},
+		{name: "Uncompressed", data: data, options: []FileOption{DontCompress()}, wantErr: StreamingSizeLimitErr},
+		{name: "Uncompressed at the limit", data: data[:maxStreamingSize], options: []FileOption{DontCompress()}},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			sent := 0
+			streaming := Streaming{
+				db:     "defaultDb",
+				table:  "defaultTable",
+				client: mockClient{endpoint: "https://test.kusto.windows.net", auth: kusto.Authorization{}},
+				streamConn: fakeStreamIngestor{
+					onStreamIngest: func(ctx context.Context, db, table string, payload io.Reader, format properties.DataFormat, mappingName string, clientRequestId string) error {
+						b, err := io.ReadAll(payload)
+						sent = len(b)
+						return err
+					},
+				},
+			}
+
+			_, err := streaming.FromReader(context.Background(), bytes.NewReader(test.data), test.options...)
+			if test.wantErr != nil {
+				assert.Equal(t, test.wantErr, err)
+				assert.Zero(t, sent)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(test.data), sent)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package kusto
+
+// inlist.go implements Stmt.AddInList(), which adds an injection safe in() operator over a list of values to a Stmt.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultInListThreshold is the number of values above which AddInList() passes the values as a query parameter.
+	DefaultInListThreshold = 50
+	// MaxInListLiterals is the maximum number of values AddInList() will write into the query text.
+	MaxInListLiterals = 1000
+)
+
+type inListOptions struct {
+	threshold int
+}
+
+// InListOption is an optional argument to AddInList().
+type InListOption func(o *inListOptions)
+
+// InListThreshold sets the number of values above which AddInList() passes the values as a dynamic query parameter
+// instead of literals in the query text. n must be between 0 and MaxInListLiterals. Defaults to DefaultInListThreshold.
+func InListThreshold(n int) InListOption {
+	return func(o *inListOptions) {
+		o.threshold = n
+	}
+}
+
+// AddInList adds an in() operator over values to the Stmt, such as `in ("a", "b")`. values must be a []string,
+// []int64 or []uuid.UUID. Every value is escaped, so values can safely come from untrusted input.
+// When there are more values than the threshold (see InListThreshold()), the values are passed as a dynamic query
+// parameter called name and the operator is `in (name)`, which keeps the query text small. The Definitions and
+// Parameters for name are added to the Stmt and kept by later calls to WithDefinitions() and WithParameters().
+// A Stmt with a parameter cannot be used with Mgmt().
+// Example:
+//
+//	stmt, err := NewStmt("Devices | where DeviceId ").AddInList("deviceIds", ids)
+func (s Stmt) AddInList(name stringConstant, values interface{}, options ...InListOption) (Stmt, error) {
+	opts := inListOptions{threshold: DefaultInListThreshold}
+	for _, o := range options {
+		o(&opts)
+	}
+	if opts.threshold < 0 || opts.threshold > MaxInListLiterals {
+		return s, fmt.Errorf("InListThreshold() must be between 0 and %d, was %d", MaxInListLiterals, opts.threshold)
+	}
+	if !validParamName(name.String()) {
+		return s, fmt.Errorf("name %q is not a valid parameter name", name)
+	}
+
+	literals, param, err := inListValues(values)
+	if err != nil {
+		return s, err
+	}
+
+	if len(literals) <= opts.threshold {
+		if len(literals) == 0 {
+			// Kusto does not accept an empty in() list.
+			s.queryStr += "in (dynamic([]))"
+			return s, nil
+		}
+		s.queryStr += "in (" + strings.Join(literals, ", ") + ")"
+		return s, nil
+	}
+
+	key := name.String()
+	if _, ok := s.defs.m[key]; ok {
+		return s, fmt.Errorf("parameter %q is already defined in the Stmt", key)
+	}
+	if _, ok := s.inLists[key]; ok {
+		return s, fmt.Errorf("parameter %q is already used by another AddInList() call", key)
+	}
+
+	s.inLists = s.inLists.clone()
+	s.inLists[key] = param
+
+	s.defs = s.defs.clone()
+	s.defs.m[key] = ParamType{Type: types.Dynamic}
+
+	params := s.params.clone()
+	params.m[key] = param
+	params, err = params.validate(s.defs)
+	if err != nil {
+		return s, err
+	}
+	s.params = params
+
+	s.queryStr += "in (" + key + ")"
+	return s, nil
+}
+
+// MustAddInList is the same as AddInList(), but it must succeed or it panics.
+func (s Stmt) MustAddInList(name stringConstant, values interface{}, options ...InListOption) Stmt {
+	s, err := s.AddInList(name, values, options...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// withInLists adds the parameters of AddInList() and AddParam() calls to defs.
+func (s Stmt) withInLists(defs Definitions) (Definitions, error) {
+	for key := range s.inLists {
+		if _, ok := defs.m[key]; ok {
+			return defs, fmt.Errorf("parameter %q is already used by an AddInList() call", key)
+		}
+		defs.m[key] = ParamType{Type: types.Dynamic}
+	}
+	for key, paramType := range s.addedTypes {
+		if _, ok := defs.m[key]; ok {
+			return defs, fmt.Errorf("parameter %q is already used by an AddParam() call", key)
+		}
+		defs.m[key] = paramType
+	}
+	return defs, nil
+}
+
+// inListValues returns the literals of values in the query language and a copy of values to use as a dynamic
+// query parameter.
+func inListValues(values interface{}) ([]string, interface{}, error) {
+	switch v := values.(type) {
+	case []string:
+		literals := make([]string, len(v))
+		for i, s := range v {
+			literals[i] = table.QuoteString(s)
+		}
+		return literals, append([]string(nil), v...), nil
+	case []int64:
+		literals := make([]string, len(v))
+		for i, n := range v {
+			literals[i] = strconv.FormatInt(n, 10)
+		}
+		return literals, append([]int64(nil), v...), nil
+	case []uuid.UUID:
+		literals := make([]string, len(v))
+		param := make([]string, len(v))
+		for i, u := range v {
+			param[i] = u.String()
+			literals[i] = "guid(" + param[i] + ")"
+		}
+		return literals, param, nil
+	}
+	return nil, nil, fmt.Errorf("AddInList() values must be a []string, []int64 or []uuid.UUID, was %T", values)
+}
+
+// validParamName returns true if name can be used as the name of a query parameter.
+func validParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
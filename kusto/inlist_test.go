@@ -0,0 +1,139 @@
+package kusto
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddInList(t *testing.T) {
+	t.Parallel()
+
+	u := uuid.MustParse("6f3b3e2c-1a9d-4b5e-8f2a-0c1d2e3f4a5b")
+	many := make([]int64, DefaultInListThreshold+1)
+	for i := range many {
+		many[i] = int64(i)
+	}
+	manyJSON, err := json.Marshal(many)
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc       string
+		values     interface{}
+		options    []InListOption
+		err        bool
+		wantStr    string
+		wantValues map[string]string
+	}{
+		{
+			desc:   "Error: unsupported type",
+			values: []int{1},
+			err:    true,
+		},
+		{
+			desc:    "Error: threshold too large",
+			values:  []int64{1},
+			options: []InListOption{InListThreshold(MaxInListLiterals + 1)},
+			err:     true,
+		},
+		{
+			desc:    "Success: empty list",
+			values:  []string{},
+			wantStr: "T | where x in (dynamic([]))",
+		},
+		{
+			desc:    "Success: strings",
+			values:  []string{"a", `quote"d`, `back\slash`, "new\nline", "tab\tcr\r", "bell\a", "'single'"},
+			wantStr: `T | where x in ("a", "quote\"d", "back\\slash", "new\nline", "tab\tcr\r", "bell\u0007", "'single'")`,
+		},
+		{
+			desc:    "Success: injection attempt stays in the literal",
+			values:  []string{`") | take 1 //`},
+			wantStr: `T | where x in ("\") | take 1 //")`,
+		},
+		{
+			desc:    "Success: longs",
+			values:  []int64{1, -2},
+			wantStr: "T | where x in (1, -2)",
+		},
+		{
+			desc:    "Success: guids",
+			values:  []uuid.UUID{u},
+			wantStr: "T | where x in (guid(6f3b3e2c-1a9d-4b5e-8f2a-0c1d2e3f4a5b))",
+		},
+		{
+			desc:       "Success: parameter above the default threshold",
+			values:     many,
+			wantStr:    "declare query_parameters(ids:dynamic);\nT | where x in (ids)",
+			wantValues: map[string]string{"ids": fmt.Sprintf("dynamic(%s)", manyJSON)},
+		},
+		{
+			desc:       "Success: parameter with escaped strings",
+			values:     []string{`quote"d`, `back\slash`, "new\nline"},
+			options:    []InListOption{InListThreshold(2)},
+			wantStr:    "declare query_parameters(ids:dynamic);\nT | where x in (ids)",
+			wantValues: map[string]string{"ids": `dynamic(["quote\"d","back\\slash","new\nline"])`},
+		},
+		{
+			desc:       "Success: parameter with guids",
+			values:     []uuid.UUID{u},
+			options:    []InListOption{InListThreshold(0)},
+			wantStr:    "declare query_parameters(ids:dynamic);\nT | where x in (ids)",
+			wantValues: map[string]string{"ids": `dynamic(["6f3b3e2c-1a9d-4b5e-8f2a-0c1d2e3f4a5b"])`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			stmt, err := NewStmt("T | where x ").AddInList("ids", test.values, test.options...)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantStr, stmt.String())
+			assert.EqualValues(t, test.wantValues, stmt.params.outM)
+		})
+	}
+}
+
+func TestAddInListWithParameters(t *testing.T) {
+	t.Parallel()
+
+	ids := []int64{1, 2, 3}
+	stmt := NewStmt("T | where x ").MustAddInList("ids", ids, InListThreshold(1)).Add(" and Name == name")
+
+	_, err := stmt.AddInList("ids", ids, InListThreshold(1))
+	assert.Error(t, err, "the parameter name is already used")
+
+	// The parameter is kept when the other definitions and values are set.
+	stmt, err = stmt.WithDefinitions(NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}))
+	require.NoError(t, err)
+	stmt, err = stmt.WithParameters(NewParameters().Must(QueryValues{"name": "a"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "declare query_parameters(ids:dynamic, name:string);\nT | where x in (ids) and Name == name", stmt.String())
+	j, err := stmt.ValuesJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"ids":"dynamic([1,2,3])","name":"a"}`, j)
+
+	// The caller's slice can change without changing the Stmt.
+	ids[0] = 42
+	j, err = stmt.ValuesJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"ids":"dynamic([1,2,3])","name":"a"}`, j)
+
+	_, err = stmt.WithDefinitions(NewDefinitions().Must(ParamTypes{"ids": ParamType{Type: types.String}}))
+	assert.Error(t, err)
+	_, err = stmt.WithParameters(NewParameters().Must(QueryValues{"ids": "a"}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package kusto
+
+// interceptor.go implements WithStatementInterceptor(), which lets callers audit and rewrite every statement before it
+// is sent.
+
+import (
+	"context"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// CallKind is the Client method a statement is sent by.
+type CallKind int
+
+const (
+	// CallQuery is a call to Client.Query().
+	CallQuery CallKind = iota + 1
+	// CallMgmt is a call to Client.Mgmt().
+	CallMgmt
+	// CallQueryToJSON is a call to Client.QueryToJson().
+	CallQueryToJSON
+	// CallMgmtToJSON is a call to Client.MgmtToJson().
+	CallMgmtToJSON
+)
+
+// String implements fmt.Stringer.
+func (k CallKind) String() string {
+	switch k {
+	case CallQuery:
+		return "Query"
+	case CallMgmt:
+		return "Mgmt"
+	case CallQueryToJSON:
+		return "QueryToJson"
+	case CallMgmtToJSON:
+		return "MgmtToJson"
+	}
+	return "Unknown"
+}
+
+// CallInfo describes a statement about to be sent, see WithStatementInterceptor().
+type CallInfo struct {
+	// Kind is the Client method the statement is sent by. Changing it has no effect.
+	Kind CallKind
+	// DB is the database the statement is sent to.
+	DB string
+	// Statement is the text of the statement, including the declaration of its parameters.
+	Statement string
+	// Parameters are the values of the parameters of the statement, in their CSL form.
+	Parameters map[string]string
+	// Options are the request options, such as "notruncation", including the ones set by the client.
+	Options map[string]interface{}
+}
+
+// StatementInterceptor is called with every statement before it is sent. The CallInfo it returns is the one sent.
+// If it returns an error, the call is aborted and returns that error.
+type StatementInterceptor func(ctx context.Context, info CallInfo) (CallInfo, error)
+
+// WithStatementInterceptor adds an interceptor that is called before every Query(), Mgmt(), QueryToJson() and
+// MgmtToJson() call is sent, for example to record the statements or to add a `set` statement in front of them. When
+// several are added, they are called in the order they were added, each receiving the CallInfo returned by the
+// previous one. The interceptors are called concurrently by concurrent calls. A Client made with WithReadOnlyClient()
+// checks the statement again once intercepted.
+func WithStatementInterceptor(i StatementInterceptor) Option {
+	return func(c *Client) {
+		if i != nil {
+			c.interceptors = append(c.interceptors, i)
+		}
+	}
+}
+
+// intercept runs the interceptors of the client on a statement, and returns the database and statement to send.
+// The request properties are updated with the parameters and options returned by the interceptors.
+func (c *Client) intercept(ctx context.Context, kind CallKind, db string, query Stmt, props *requestProperties) (string, Stmt, error) {
+	if len(c.interceptors) == 0 {
+		return db, query, nil
+	}
+
+	op := errors.OpQuery
+	if kind == CallMgmt || kind == CallMgmtToJSON {
+		op = errors.OpMgmt
+	}
+
+	text := query.String()
+	info := CallInfo{Kind: kind, DB: db, Statement: text, Options: props.options()}
+	if props.Parameters != nil {
+		info.Parameters = make(map[string]string, len(props.Parameters))
+		for k, v := range props.Parameters {
+			info.Parameters[k] = v
+		}
+	}
+
+	for _, i := range c.interceptors {
+		var err error
+		if info, err = i(ctx, info); err != nil {
+			return "", Stmt{}, err
+		}
+	}
+
+	if info.Statement != text {
+		if info.Statement == "" {
+			return "", Stmt{}, errors.ES(op, errors.KClientArgs, "a statement interceptor returned an empty statement").SetNoRetry()
+		}
+		// The statement already holds the declaration of the parameters.
+		query = Stmt{queryStr: info.Statement, unsafe: query.unsafe}
+	}
+	props.Parameters = info.Parameters
+	props.typed = typedOptions{}
+	props.Options = make(map[string]interface{}, len(info.Options))
+	for k, v := range info.Options {
+		props.setOption(k, v)
+	}
+	lockReadOnly(props)
+
+	// Mgmt() calls of read-only clients are rejected before being intercepted.
+	if c.readOnly {
+		if isCommand(info.Statement) {
+			return "", Stmt{}, &ReadOnlyError{Op: op, Reason: "management commands cannot be sent"}
+		}
+		props.setOption(RequestReadonlyValue, true)
+	}
+	return info.DB, query, nil
+}
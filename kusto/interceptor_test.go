@@ -0,0 +1,243 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementInterceptor(t *testing.T) {
+	t.Parallel()
+
+	errRejected := fmt.Errorf("rejected by policy")
+	stmt := NewStmt("T | where Name == name").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}),
+	).MustParameters(NewParameters().Must(QueryValues{"name": "a"}))
+
+	tests := []struct {
+		desc         string
+		readOnly     bool
+		interceptors []StatementInterceptor
+		call         func(ctx context.Context, client *Client) error
+		wantErr      error
+		wantReadOnly bool
+		// want is the message that reaches the service, nil if nothing is sent.
+		want func(t *testing.T, msg queryMsg)
+	}{
+		{
+			desc: "Query is rewritten",
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					info.Statement = "set query_results_cache_max_age = time(5m);\n" + info.Statement
+					info.Options[NoTruncationValue] = true
+					delete(info.Options, resultsProgressiveEnabledValue)
+					info.Parameters["name"] = `"b"`
+					return info, nil
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				iter, err := client.Query(ctx, "db", stmt)
+				if err == nil {
+					iter.Stop()
+				}
+				return err
+			},
+			want: func(t *testing.T, msg queryMsg) {
+				assert.Equal(t, "db", msg.DB)
+				assert.Equal(t, "set query_results_cache_max_age = time(5m);\ndeclare query_parameters(name:string);\nT | where Name == name", msg.CSL)
+				assert.Equal(t, map[string]string{"name": `"b"`}, msg.Properties.Parameters)
+				assert.Equal(t, true, msg.Properties.Options[NoTruncationValue])
+				assert.NotContains(t, msg.Properties.Options, resultsProgressiveEnabledValue)
+			},
+		},
+		{
+			desc: "Interceptors compose in registration order",
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					info.Statement += " | take 1"
+					info.DB = "first"
+					return info, nil
+				},
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					info.Statement += " | count"
+					info.DB += "-second"
+					return info, nil
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("T"))
+				return err
+			},
+			want: func(t *testing.T, msg queryMsg) {
+				assert.Equal(t, "first-second", msg.DB)
+				assert.Equal(t, "T | take 1 | count", msg.CSL)
+			},
+		},
+		{
+			desc: "Mgmt is rewritten",
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					if info.Kind != CallMgmt {
+						return info, fmt.Errorf("got kind %s", info.Kind)
+					}
+					info.Statement = ".show version"
+					return info, nil
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				// The response is in v2 frames, which Mgmt() cannot decode, so only the request matters.
+				iter, err := client.Mgmt(ctx, "db", NewStmt(".show tables"))
+				if err == nil {
+					iter.Stop()
+				}
+				return nil
+			},
+			want: func(t *testing.T, msg queryMsg) {
+				assert.Equal(t, ".show version", msg.CSL)
+			},
+		},
+		{
+			desc: "Rejection aborts the call",
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					return info, nil
+				},
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					return info, errRejected
+				},
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					panic("not called after a rejection")
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.Query(ctx, "db", NewStmt("T"))
+				return err
+			},
+			wantErr: errRejected,
+		},
+		{
+			desc: "Empty statement",
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					info.Statement = ""
+					return info, nil
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("T"))
+				return err
+			},
+			wantErr: fmt.Errorf("any"),
+		},
+		{
+			desc:     "Read-only client rejects a rewritten command",
+			readOnly: true,
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					info.Statement = ".drop table T"
+					return info, nil
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("T"))
+				return err
+			},
+			wantErr: &ReadOnlyError{},
+		},
+		{
+			desc:     "Read-only client keeps the read-only option",
+			readOnly: true,
+			interceptors: []StatementInterceptor{
+				func(ctx context.Context, info CallInfo) (CallInfo, error) {
+					if info.Options[RequestReadonlyValue] != true {
+						return info, fmt.Errorf("the interceptor should see the read-only option")
+					}
+					info.Options = nil
+					return info, nil
+				},
+			},
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("T"))
+				return err
+			},
+			want: func(t *testing.T, msg queryMsg) {
+				assert.Equal(t, map[string]interface{}{RequestReadonlyValue: true}, msg.Properties.Options)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &recordTransport{}
+			client := newTestClient(t, "https://intercept.kusto.windows.net", transport)
+			if test.readOnly {
+				WithReadOnlyClient()(client)
+			}
+			for _, i := range test.interceptors {
+				WithStatementInterceptor(i)(client)
+			}
+
+			err := test.call(context.Background(), client)
+			if test.wantErr != nil {
+				require.Error(t, err)
+				switch want := test.wantErr.(type) {
+				case *ReadOnlyError:
+					assert.True(t, goErrors.As(err, &want), "got %T: %v", err, err)
+				default:
+					if want == errRejected {
+						assert.Same(t, errRejected, err)
+					}
+				}
+				assert.Empty(t, transport.sent(), "an aborted call must not reach the service")
+				return
+			}
+			require.NoError(t, err)
+			sent := transport.sent()
+			require.Len(t, sent, 1)
+			test.want(t, sent[0])
+		})
+	}
+}
+
+func TestStatementInterceptorAudit(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var audit []CallInfo
+	transport := &recordTransport{}
+	client := newTestClient(t, "https://intercept.kusto.windows.net", transport)
+	WithStatementInterceptor(func(ctx context.Context, info CallInfo) (CallInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		audit = append(audit, info)
+		return info, nil
+	})(client)
+
+	stmt := NewStmt("T | where Id == id").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{"id": ParamType{Type: types.Long}}),
+	).MustParameters(NewParameters().Must(QueryValues{"id": int64(7)}))
+	_, err := client.QueryToJson(context.Background(), "db", stmt, NoTruncation())
+	require.NoError(t, err)
+
+	require.Len(t, audit, 1)
+	assert.Equal(t, CallQueryToJSON, audit[0].Kind)
+	assert.Equal(t, "db", audit[0].DB)
+	assert.Equal(t, stmt.String(), audit[0].Statement)
+	assert.Equal(t, map[string]string{"id": "long(7)"}, audit[0].Parameters)
+	assert.Equal(t, true, audit[0].Options[NoTruncationValue])
+
+	// The statement is sent unchanged.
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, stmt.String(), sent[0].CSL)
+	assert.Equal(t, map[string]string{"id": "long(7)"}, sent[0].Properties.Parameters)
+}
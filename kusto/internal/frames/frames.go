@@ -1,3 +1,5 @@
+// Package frames holds the internal helpers of the frame decoders. The frame types are defined in the public
+// kusto/frames package, and aliased here so that the decoders can refer to them.
 package frames
 
 import (
@@ -5,24 +7,24 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	pub "github.com/Azure/azure-kusto-go/kusto/frames"
 )
 
 const (
 	// TypeDataTable is the .FrameType that indicates a Kusto DataTable.
-	TypeDataTable = "DataTable"
+	TypeDataTable = pub.TypeDataTable
 	// TypeDataSetCompletion is the .FrameType that indicates a Kusto DataSetCompletion.
-	TypeDataSetCompletion = "DataSetCompletion"
+	TypeDataSetCompletion = pub.TypeDataSetCompletion
 	// TypeDataSetHeader is the .FrameType that indicates a Kusto DataSetHeader.
-	TypeDataSetHeader = "DataSetHeader"
+	TypeDataSetHeader = pub.TypeDataSetHeader
 	// TypeTableHeader is the .FrameType that indicates a Kusto TableHeader.
-	TypeTableHeader = "TableHeader"
+	TypeTableHeader = pub.TypeTableHeader
 	// TypeTableFragment is the .FrameType that indicates a Kusto TableFragment.
-	TypeTableFragment = "TableFragment"
+	TypeTableFragment = pub.TypeTableFragment
 	// TypeTableProgress is the .FrameType that indicates a Kusto TableProgress.
-	TypeTableProgress = "TableProgress"
+	TypeTableProgress = pub.TypeTableProgress
 	// TypeTableCompletion is the .FrameType that indicates a Kusto TableCompletion.
-	TypeTableCompletion = "TableCompletion"
+	TypeTableCompletion = pub.TypeTableCompletion
 )
 
 // These constants represent keys for fields when unmarshalling various JSON dicts representing Kusto frames.
@@ -42,52 +44,30 @@ const (
 )
 
 // TableKind describes the kind of table.
-type TableKind string
+type TableKind = pub.TableKind
 
 const (
-	// QueryProperties is a dataTable.TableKind that contains properties about the query itself.
-	// The dataTable.TableName is usually ExtendedProperties.
-	QueryProperties TableKind = "QueryProperties"
-	// PrimaryResult is a dataTable.TableKind that contains the query information the user wants.
-	// The dataTable.TableName is PrimaryResult.
-	PrimaryResult TableKind = "PrimaryResult"
-	// QueryCompletionInformation contains information on how long the query took.
-	// The dataTable.TableName is QueryCompletionInformation.
-	QueryCompletionInformation TableKind = "QueryCompletionInformation"
-	QueryTraceLog              TableKind = "QueryTraceLog"
-	QueryPerfLog               TableKind = "QueryPerfLog"
-	QueryResult                TableKind = "QueryResult"
-	TableOfContents            TableKind = "TableOfContents"
-	QueryPlan                  TableKind = "QueryPlan"
-	ExtendedProperties         TableKind = "@ExtendedProperties"
-	UnknownTableKind           TableKind = "Unknown"
+	QueryProperties            = pub.QueryProperties
+	PrimaryResult              = pub.PrimaryResult
+	QueryCompletionInformation = pub.QueryCompletionInformation
+	QueryTraceLog              = pub.QueryTraceLog
+	QueryPerfLog               = pub.QueryPerfLog
+	QueryResult                = pub.QueryResult
+	TableOfContents            = pub.TableOfContents
+	QueryPlan                  = pub.QueryPlan
+	ExtendedProperties         = pub.ExtendedProperties
+	UnknownTableKind           = pub.UnknownTableKind
 )
 
 // Decoder provides a function that will decode an incoming data stream and return a channel of Frame objects.
-type Decoder interface {
-	// Decode decodes an io.Reader representing a stream of Kusto frames into our Frame representation.
-	// The type and order of frames is dependent on the REST interface version and the progressive frame settings.
-	Decode(ctx context.Context, r io.ReadCloser, op errors.Op) chan Frame
-}
+type Decoder = pub.Decoder
 
 // Frame is a type of Kusto frame as defined in the reference document.
-type Frame interface {
-	IsFrame()
-}
+type Frame = pub.Frame
 
 // Error is not actually a Kusto frame, but is used to signal the end of a stream
 // where we encountered an error. Error implements error.
-type Error struct {
-	Msg string
-}
-
-// Error implements error.Error().
-func (e Error) Error() string {
-	return e.Msg
-}
-
-// IsFrame implements Frame.IsFrame().
-func (Error) IsFrame() {}
+type Error = pub.Error
 
 // Errorf write a frames.Error to ch with fmt.Sprint(s, a...).
 func Errorf(ctx context.Context, ch chan Frame, s string, a ...interface{}) {
@@ -96,3 +76,35 @@ func Errorf(ctx context.Context, ch chan Frame, s string, a ...interface{}) {
 	case ch <- Error{Msg: fmt.Sprintf(s, a...)}:
 	}
 }
+
+// Aborter is implemented by the response bodies that read another body, such as a decompressor, whose Abort() closes
+// the body they read. Unlike Close(), it can be called while the body is read.
+type Aborter interface {
+	Abort() error
+}
+
+// CloseOnDone closes r, if it is an io.Closer, as soon as ctx is done, so that a decoder blocked reading r returns
+// and the connection of the response is torn down, instead of reading the response to its end. r is aborted
+// instead of closed if it is an Aborter. The returned func stops watching ctx, it must be called once r is no
+// longer read.
+func CloseOnDone(ctx context.Context, r io.Reader) (stop func()) {
+	var closeFn func() error
+	switch c := r.(type) {
+	case Aborter:
+		closeFn = c.Abort
+	case io.Closer:
+		closeFn = c.Close
+	default:
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = closeFn()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,45 @@
+package frames
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// JSONValue returns v as the service sends it in the rows of a frame, to be marshalled with encoding/json.
+func JSONValue(v value.Kusto) interface{} {
+	if value.IsNull(v) {
+		return nil
+	}
+
+	switch v := v.(type) {
+	case value.Bool:
+		return v.Value
+	case value.Int:
+		return v.Value
+	case value.Long:
+		return v.Value
+	case value.Real:
+		// JSON has no such numbers, the service sends them as strings.
+		switch {
+		case math.IsNaN(v.Value):
+			return "NaN"
+		case math.IsInf(v.Value, 1):
+			return "Infinity"
+		case math.IsInf(v.Value, -1):
+			return "-Infinity"
+		}
+		return v.Value
+	case value.DateTime:
+		return v.Marshal()
+	case value.Timespan:
+		return v.Marshal()
+	case value.Dynamic:
+		if json.Valid(v.Value) {
+			return json.RawMessage(v.Value)
+		}
+		return string(v.Value)
+	}
+	return v.String()
+}
@@ -0,0 +1,63 @@
+package unmarshal
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+)
+
+var benchColumns = table.Columns{
+	{Name: "Id", Type: types.Long},
+	{Name: "Timestamp", Type: types.DateTime},
+	{Name: "Name", Type: types.String},
+	{Name: "Value", Type: types.Real},
+	{Name: "ActivityId", Type: types.GUID},
+	{Name: "Ok", Type: types.Bool},
+	{Name: "Duration", Type: types.Timespan},
+	{Name: "Amount", Type: types.Decimal},
+}
+
+// Current (unmarshalValue() switching on the type of the column for every value):
+// BenchmarkRawRows    	       1	2891236324 ns/op	  43.48 MB/s	701427976 B/op	19715672 allocs/op
+// New (Converter made once per table, with fast paths for each column type and shared nulls):
+// BenchmarkRawRows    	       1	1761254042 ns/op	  71.37 MB/s	585414824 B/op	15315706 allocs/op
+
+// BenchmarkRawRows measures the conversion of the JSON of 1,000,000 rows of benchColumns, one in ten being nulls.
+func BenchmarkRawRows(b *testing.B) {
+	raw := benchRows(1000000)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(raw)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, _, err := RawRows(benchColumns, raw, errors.OpQuery); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// benchRows returns the JSON of n rows of benchColumns.
+func benchRows(n int) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if i%10 == 9 {
+			buf.WriteString(`[null,null,null,null,null,null,null,null]`)
+			continue
+		}
+		fmt.Fprintf(
+			buf,
+			`[%d,"2023-05-%02dT04:14:55.%07dZ","name %d",%d.25,"bde1b9ee-3a4d-4a79-a3a4-%012d",%t,"00:%02d:00.099","%d.10"]`,
+			i, i%28+1, i%10000000, i, i, i, i%2 == 0, i%60, i,
+		)
+	}
+	buf.WriteString("]")
+	return buf.Bytes()
+}
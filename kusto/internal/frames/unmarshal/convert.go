@@ -0,0 +1,273 @@
+package unmarshal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
+
+	"github.com/google/uuid"
+)
+
+// Converter converts the rows of a table into []value.Values. The conversion of each column is chosen from its type
+// once, when the Converter is made from the columns of the DataTable or TableHeader frame, instead of for every value.
+type Converter struct {
+	columns table.Columns
+	funcs   []columnFunc
+}
+
+// columnFunc converts the values of a column. raw converts the JSON of a value, inter a value decoded into an
+// interface{}. Both return the same value.Kusto for the same value. null is the value of the nulls of the column,
+// which is shared by all of them, or nil if the type of the column is unknown.
+type columnFunc struct {
+	raw   func(raw []byte) (value.Kusto, error)
+	inter func(i interface{}) (value.Kusto, error)
+	null  value.Kusto
+}
+
+// NewConverter returns the Converter of the rows of a table with columns. A column of an unknown type is an error
+// once a row is converted.
+func NewConverter(columns table.Columns) *Converter {
+	c := &Converter{columns: columns, funcs: make([]columnFunc, len(columns))}
+	for i, col := range columns {
+		f := newColumnFunc(col)
+		f.null, _ = f.inter(nil)
+		c.funcs[i] = f
+	}
+	return c
+}
+
+// Columns returns the columns the Converter was made for.
+func (c *Converter) Columns() table.Columns {
+	return c.columns
+}
+
+// newColumnFunc returns the conversion of the values of col. The common values of each type have a fast path from
+// their JSON, the others are decoded into an interface{} and unmarshalled by the value.Kusto of the type.
+func newColumnFunc(col table.Column) columnFunc {
+	switch col.Type {
+	case types.Bool:
+		inter := interFunc[value.Bool](col, "Bool")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if raw[0] == 't' || raw[0] == 'f' {
+					return value.Bool{Value: raw[0] == 't', Valid: true}, nil
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.DateTime:
+		inter := interFunc[value.DateTime](col, "DateTime")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if s, ok := rawString(raw); ok {
+					if t, err := time.Parse(time.RFC3339Nano, string(s)); err == nil {
+						return value.DateTime{Value: t.UTC(), Valid: true}, nil
+					}
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.Decimal:
+		inter := interFunc[value.Decimal](col, "Decimal")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if s, ok := rawString(raw); ok && value.DecRE.Match(s) {
+					return value.Decimal{Value: string(s), Valid: true}, nil
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.Dynamic:
+		inter := interFunc[value.Dynamic](col, "Dynamic")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if s, ok := rawString(raw); ok {
+					if len(s) == 0 || &s[0] == &raw[1] {
+						// s is a part of raw, which is reused by the decoder.
+						s = append([]byte{}, s...)
+					}
+					return value.Dynamic{Value: s, Valid: true}, nil
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.GUID:
+		inter := interFunc[value.GUID](col, "GUID")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if s, ok := rawString(raw); ok {
+					if u, err := uuid.ParseBytes(s); err == nil {
+						return value.GUID{Value: u, Valid: true}, nil
+					}
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.Int:
+		inter := interFunc[value.Int](col, "Int")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if rawNumber(raw) {
+					if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil && n <= math.MaxInt32 {
+						return value.Int{Value: int32(n), Valid: true}, nil
+					}
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.Long:
+		inter := interFunc[value.Long](col, "Long")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if rawNumber(raw) {
+					if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+						return value.Long{Value: n, Valid: true}, nil
+					}
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.Real:
+		inter := interFunc[value.Real](col, "Real")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if rawNumber(raw) {
+					if f, err := strconv.ParseFloat(string(raw), 64); err == nil {
+						return value.Real{Value: f, Valid: true}, nil
+					}
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.String:
+		inter := interFunc[value.String](col, "String")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				if s, ok := rawString(raw); ok {
+					return value.String{Value: string(s), Valid: true}, nil
+				}
+				return rawInter(raw, inter)
+			},
+		}
+	case types.Timespan:
+		inter := interFunc[value.Timespan](col, "Timespan")
+		return columnFunc{
+			inter: inter,
+			raw: func(raw []byte) (value.Kusto, error) {
+				return rawInter(raw, inter)
+			},
+		}
+	}
+
+	inter := func(i interface{}) (value.Kusto, error) {
+		return nil, fmt.Errorf("DataTable had column of type %s, which was unknown", col.Type)
+	}
+	return columnFunc{
+		inter: inter,
+		raw: func(raw []byte) (value.Kusto, error) {
+			return inter(nil)
+		},
+	}
+}
+
+// unmarshaler is a pointer to a value.Kusto, which unmarshals the values decoded into an interface{}.
+type unmarshaler[T value.Kusto] interface {
+	*T
+	Unmarshal(i interface{}) error
+}
+
+// interFunc returns the conversion of the values of col decoded into an interface{} into a T, named name in errors.
+func interFunc[T value.Kusto, P unmarshaler[T]](col table.Column, name string) func(i interface{}) (value.Kusto, error) {
+	return func(i interface{}) (value.Kusto, error) {
+		var v T
+		if err := P(&v).Unmarshal(i); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal column %s into a %s value: %s", col.Name, name, err)
+		}
+		return v, nil
+	}
+}
+
+// rawInter decodes raw, the JSON of a value, into an interface{} the way the rows are decoded by json.Unmarshal(), and
+// converts it with inter.
+func rawInter(raw []byte, inter func(i interface{}) (value.Kusto, error)) (value.Kusto, error) {
+	switch raw[0] {
+	case 'n':
+		return inter(nil)
+	case 't', 'f':
+		return inter(raw[0] == 't')
+	case '"':
+		s, ok := json.Unquote(raw)
+		if !ok {
+			return nil, fmt.Errorf("could not unquote the string %s", raw)
+		}
+		return inter(string(s))
+	case '{', '[':
+		var i interface{}
+		if err := json.Unmarshal(raw, &i); err != nil {
+			return nil, err
+		}
+		return inter(i)
+	}
+	return inter(json.Number(raw))
+}
+
+// rawString returns the value of raw if it is the JSON of a string.
+func rawString(raw []byte) ([]byte, bool) {
+	if raw[0] != '"' {
+		return nil, false
+	}
+	return json.Unquote(raw)
+}
+
+// rawNumber reports if raw is the JSON of a number.
+func rawNumber(raw []byte) bool {
+	return raw[0] == '-' || (raw[0] >= '0' && raw[0] <= '9')
+}
+
+// Rows converts interRows, the rows of the table decoded into []interface{}, see the package level Rows().
+func (c *Converter) Rows(interRows []interface{}, op errors.Op) ([]value.Values, []errors.Error, error) {
+	rows := make([]value.Values, 0, len(interRows))
+	var errorRows []errors.Error
+
+	for _, rawRow := range interRows {
+		interRow, ok := rawRow.([]interface{})
+		if !ok && rawRow != nil {
+			errorRows = append(errorRows, rowErrors(rawRow, op)...)
+			continue
+		}
+		if len(interRow) < len(c.columns) {
+			return nil, nil, fmt.Errorf("row had %d values, but the table has %d columns", len(interRow), len(c.columns))
+		}
+
+		row := make(value.Values, len(c.columns))
+		for i, f := range c.funcs {
+			if interRow[i] == nil && f.null != nil {
+				row[i] = f.null
+				continue
+			}
+			v, err := f.inter(interRow[i])
+			if err != nil {
+				return nil, nil, err
+			}
+			row[i] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, errorRows, nil
+}
@@ -0,0 +1,109 @@
+package unmarshal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
+
+	"github.com/google/uuid"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestConverter(t *testing.T) {
+	t.Parallel()
+
+	aUUID := uuid.MustParse("bde1b9ee-3a4d-4a79-a3a4-0a8df3ea8b53")
+	dt, err := time.Parse(time.RFC3339Nano, "2019-08-27T04:14:55.302919Z")
+	if err != nil {
+		panic(err)
+	}
+	offset, err := time.Parse(time.RFC3339Nano, "2019-08-27T06:14:55+02:00")
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		desc       string
+		columnType types.Column
+		json       string
+		want       value.Kusto
+		err        bool
+	}{
+		{desc: "Null bool", columnType: types.Bool, json: `null`, want: value.Bool{}},
+		{desc: "Bool true", columnType: types.Bool, json: `true`, want: value.Bool{Value: true, Valid: true}},
+		{desc: "Bool false", columnType: types.Bool, json: `false`, want: value.Bool{Value: false, Valid: true}},
+		{desc: "Bool as string", columnType: types.Bool, json: `"true"`, err: true},
+		{desc: "Null datetime", columnType: types.DateTime, json: `null`, want: value.DateTime{}},
+		{desc: "DateTime", columnType: types.DateTime, json: `"2019-08-27T04:14:55.302919Z"`, want: value.DateTime{Value: dt, Valid: true}},
+		{desc: "DateTime with offset", columnType: types.DateTime, json: `"2019-08-27T06:14:55+02:00"`, want: value.DateTime{Value: offset.UTC(), Valid: true}},
+		{desc: "Bad datetime", columnType: types.DateTime, json: `"yesterday"`, err: true},
+		{desc: "Null decimal", columnType: types.Decimal, json: `null`, want: value.Decimal{}},
+		{desc: "Decimal", columnType: types.Decimal, json: `"-3.20"`, want: value.Decimal{Value: "-3.20", Valid: true}},
+		{desc: "Bad decimal", columnType: types.Decimal, json: `"3..2"`, err: true},
+		{desc: "Null dynamic", columnType: types.Dynamic, json: `null`, want: value.Dynamic{}},
+		{desc: "Dynamic string", columnType: types.Dynamic, json: `"{\"key\":\"value\"}"`, want: value.Dynamic{Value: []byte(`{"key":"value"}`), Valid: true}},
+		{desc: "Dynamic object", columnType: types.Dynamic, json: `{"key":[1,2]}`, want: value.Dynamic{Value: []byte(`{"key":[1,2]}`), Valid: true}},
+		{desc: "Dynamic number", columnType: types.Dynamic, json: `12.5`, want: value.Dynamic{Value: []byte(`12.5`), Valid: true}},
+		{desc: "Null guid", columnType: types.GUID, json: `null`, want: value.GUID{}},
+		{desc: "GUID", columnType: types.GUID, json: `"bde1b9ee-3a4d-4a79-a3a4-0a8df3ea8b53"`, want: value.GUID{Value: aUUID, Valid: true}},
+		{desc: "Bad guid", columnType: types.GUID, json: `"guid"`, err: true},
+		{desc: "Null int", columnType: types.Int, json: `null`, want: value.Int{}},
+		{desc: "Int", columnType: types.Int, json: `-12`, want: value.Int{Value: -12, Valid: true}},
+		{desc: "Int too large", columnType: types.Int, json: `2147483648`, err: true},
+		{desc: "Null long", columnType: types.Long, json: `null`, want: value.Long{}},
+		{desc: "Long", columnType: types.Long, json: `9223372036854775807`, want: value.Long{Value: 9223372036854775807, Valid: true}},
+		{desc: "Long with a fraction", columnType: types.Long, json: `1.5`, err: true},
+		{desc: "Null real", columnType: types.Real, json: `null`, want: value.Real{}},
+		{desc: "Real", columnType: types.Real, json: `1.5e3`, want: value.Real{Value: 1500, Valid: true}},
+		{desc: "Real as string", columnType: types.Real, json: `"NaN"`, err: true},
+		{desc: "Null string", columnType: types.String, json: `null`, want: value.String{}},
+		{desc: "String", columnType: types.String, json: `"John \"Doak\" é"`, want: value.String{Value: `John "Doak" é`, Valid: true}},
+		{desc: "Empty string", columnType: types.String, json: `""`, want: value.String{Value: "", Valid: true}},
+		{desc: "String as number", columnType: types.String, json: `1`, err: true},
+		{desc: "Null timespan", columnType: types.Timespan, json: `null`, want: value.Timespan{}},
+		{desc: "Timespan", columnType: types.Timespan, json: `"1.00:00:00.099"`, want: value.Timespan{Value: 24*time.Hour + 99*time.Millisecond, Valid: true}},
+		{desc: "Bad timespan", columnType: types.Timespan, json: `"1:00"`, err: true},
+		{desc: "Unknown type", columnType: types.Column("unknown"), json: `null`, err: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			conv := NewConverter(table.Columns{{Name: "store", Type: test.columnType}})
+
+			// The rows decoded into []interface{} and their JSON must give the same values.
+			var interRows []interface{}
+			if err := json.Unmarshal([]byte("[["+test.json+"]]"), &interRows); err != nil {
+				panic(err)
+			}
+			interGot, _, interErr := conv.Rows(interRows, errors.OpQuery)
+			rawGot, _, rawErr := conv.RawRows([]byte("[["+test.json+"]]"), errors.OpQuery)
+
+			switch {
+			case test.err && (interErr == nil || rawErr == nil):
+				t.Fatalf("TestConverter(%s): got err == (%v, %v), want errors", test.desc, interErr, rawErr)
+			case !test.err && (interErr != nil || rawErr != nil):
+				t.Fatalf("TestConverter(%s): got err == (%v, %v), want err == nil", test.desc, interErr, rawErr)
+			case test.err:
+				if interErr.Error() != rawErr.Error() {
+					t.Errorf("TestConverter(%s): got raw err == %s, want %s", test.desc, rawErr, interErr)
+				}
+				return
+			}
+
+			if diff := pretty.Compare(test.want, interGot[0][0]); diff != "" {
+				t.Errorf("TestConverter(%s): Rows() -want/+got:\n%s", test.desc, diff)
+			}
+			if diff := pretty.Compare(test.want, rawGot[0][0]); diff != "" {
+				t.Errorf("TestConverter(%s): RawRows() -want/+got:\n%s", test.desc, diff)
+			}
+		})
+	}
+}
@@ -1190,6 +1190,19 @@ func (d *decodeState) unquote(s []byte) (t string, ok bool) {
 	return
 }
 
+// Unquote returns the value of the JSON string literal s, quotes included, as it is decoded. The result is a slice of
+// s when s has no escape and is ASCII.
+func Unquote(s []byte) (t []byte, ok bool) {
+	d := decodeState{safeUnquote: -1}
+	for i := 1; i < len(s)-1; i++ {
+		if c := s[i]; c == '\\' || c >= utf8.RuneSelf {
+			d.safeUnquote = i - 1
+			break
+		}
+	}
+	return d.unquoteBytes(s)
+}
+
 func (d *decodeState) unquoteBytes(s []byte) (t []byte, ok bool) {
 	// We already know that s[0] == '"'. However, we don't know that the
 	// closing quote exists in all cases, such as when the string is nested
@@ -0,0 +1,155 @@
+package unmarshal
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
+)
+
+// rowsPerBlock is the number of rows whose values share an allocation in RawRows().
+const rowsPerBlock = 64
+
+var emptyRow = []byte("[]")
+
+// RawRows is Rows() for the raw JSON of the list of rows, see Converter.RawRows().
+func RawRows(columns table.Columns, raw []byte, op errors.Op) ([]value.Values, []errors.Error, error) {
+	return NewConverter(columns).RawRows(raw, op)
+}
+
+// RawRows converts raw, the JSON of the list of rows of the table, which must be valid, as it is once the frame it is
+// part of was unmarshalled. The rows are read from raw as they come, without decoding each of them into a
+// []interface{} first. The values of the rows are the ones Rows() returns for the same rows, and the values hold no
+// reference to raw.
+func (c *Converter) RawRows(raw []byte, op errors.Op) ([]value.Values, []errors.Error, error) {
+	columns := c.columns
+	rows := make([]value.Values, 0)
+	var errorRows []errors.Error
+
+	i := skipSpace(raw, 0)
+	if i == len(raw) || raw[i] == 'n' { // null
+		return rows, nil, nil
+	}
+	if raw[i] != '[' {
+		return nil, nil, fmt.Errorf("Rows was not a list, started with %q", raw[i])
+	}
+
+	// The values of the rows are sliced out of blocks, which saves an allocation per row.
+	var block value.Values
+	for i++; ; {
+		i = skipSpace(raw, i)
+		if raw[i] == ',' {
+			i = skipSpace(raw, i+1)
+		}
+		if raw[i] == ']' {
+			break
+		}
+		end := valueEnd(raw, i)
+		rawRow := raw[i:end]
+		i = end
+
+		switch rawRow[0] {
+		case '[':
+		case 'n':
+			rawRow = emptyRow
+		default:
+			var errRow interface{}
+			if err := json.Unmarshal(rawRow, &errRow); err != nil {
+				return nil, nil, err
+			}
+			errorRows = append(errorRows, rowErrors(errRow, op)...)
+			continue
+		}
+
+		if block == nil || len(block) < len(columns) {
+			block = make(value.Values, len(columns)*rowsPerBlock)
+		}
+		row := block[:len(columns):len(columns)]
+		block = block[len(columns):]
+
+		j := 1
+		for k := range columns {
+			j = skipSpace(rawRow, j)
+			if rawRow[j] == ',' {
+				j = skipSpace(rawRow, j+1)
+			}
+			if rawRow[j] == ']' {
+				return nil, nil, fmt.Errorf("row had %d values, but the table has %d columns", k, len(columns))
+			}
+			end := valueEnd(rawRow, j)
+			f := c.funcs[k]
+			if rawRow[j] == 'n' && f.null != nil {
+				row[k] = f.null
+				j = end
+				continue
+			}
+			v, err := f.raw(rawRow[j:end])
+			if err != nil {
+				return nil, nil, err
+			}
+			row[k] = v
+			j = end
+		}
+		rows = append(rows, row)
+	}
+	return rows, errorRows, nil
+}
+
+// skipSpace returns the index of the first byte of raw at or after i that is not JSON whitespace.
+func skipSpace(raw []byte, i int) int {
+	for ; i < len(raw); i++ {
+		switch raw[i] {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// valueEnd returns the index that follows the end of the JSON value that starts at raw[i].
+func valueEnd(raw []byte, i int) int {
+	switch raw[i] {
+	case '"':
+		return stringEnd(raw, i)
+	case '[', '{':
+		depth := 0
+		for ; i < len(raw); i++ {
+			switch raw[i] {
+			case '"':
+				i = stringEnd(raw, i) - 1
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	}
+	// A number, true, false or null.
+	for ; i < len(raw); i++ {
+		switch raw[i] {
+		case ',', ']', '}', ' ', '\t', '\n', '\r':
+			return i
+		}
+	}
+	return i
+}
+
+// stringEnd returns the index that follows the closing quote of the JSON string that starts at raw[i].
+func stringEnd(raw []byte, i int) int {
+	for i++; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
@@ -2,12 +2,10 @@
 package unmarshal
 
 import (
-	"fmt"
 	"sync"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
-	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 )
 
@@ -30,91 +28,19 @@ func PutRows(rows []interface{}) {
 }
 
 // Rows unmarshals a slice of a slice that represents a set of rows and translates them into a set of []value.Values.
+// Decoders that convert several frames of a table make a Converter once instead, see NewConverter().
 func Rows(columns table.Columns, interRows []interface{}, op errors.Op) ([]value.Values, []errors.Error, error) {
-	rows := make([]value.Values, 0, len(interRows))
-	var errorRows []errors.Error
-
-	for _, rawRow := range interRows {
-		interRow, ok := rawRow.([]interface{})
-		if !ok && rawRow != nil {
-			errorRow, ok := rawRow.(map[string]interface{})
-			if !ok {
-				errorRows = append(errorRows, *errors.ES(op, errors.KInternal, "Unexpected row error: %v", rawRow))
-			}
-
-			errorRows = append(errorRows, *errors.OneToErr(errorRow, op))
-
-			continue
-		}
+	return NewConverter(columns).Rows(interRows, op)
+}
 
-		row := make(value.Values, len(columns))
-		for i, col := range columns {
-			switch col.Type {
-			case types.Bool:
-				v := value.Bool{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Bool value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.DateTime:
-				v := value.DateTime{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a DateTime value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.Decimal:
-				v := value.Decimal{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Decimal value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.Dynamic:
-				v := value.Dynamic{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Dynamic value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.GUID:
-				v := value.GUID{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a GUID value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.Int:
-				v := value.Int{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Int value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.Long:
-				v := value.Long{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Long value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.Real:
-				v := value.Real{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Real value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.String:
-				v := value.String{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a String value: %s", col.Name, err)
-				}
-				row[i] = v
-			case types.Timespan:
-				v := value.Timespan{}
-				if err := v.Unmarshal(interRow[i]); err != nil {
-					return nil, nil, fmt.Errorf("unable to unmarshal column %s into a Timespan value: %s", col.Name, err)
-				}
-				row[i] = v
-			default:
-				return nil, nil, fmt.Errorf("DataTable had column of type %s, which was unknown", col.Type)
-			}
-		}
-		rows = append(rows, row)
+// rowErrors returns the errors of rawRow, a row that was not a list of values.
+func rowErrors(rawRow interface{}, op errors.Op) []errors.Error {
+	errorRow, ok := rawRow.(map[string]interface{})
+	if !ok {
+		return []errors.Error{*errors.ES(op, errors.KInternal, "Unexpected row error: %v", rawRow)}
+	}
+	if e := errors.OneToErr(errorRow, op); e != nil {
+		return []errors.Error{*e}
 	}
-	return rows, errorRows, nil
+	return []errors.Error{*errors.ES(op, errors.KInternal, "Unexpected row error: %v", rawRow)}
 }
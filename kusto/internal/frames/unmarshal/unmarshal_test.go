@@ -1,6 +1,7 @@
 package unmarshal
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
 
 	"github.com/google/uuid"
 	"github.com/kylelemons/godebug/pretty"
@@ -61,3 +63,78 @@ func TestUnmarshalRows(t *testing.T) {
 		}
 	}
 }
+
+func TestRawRows(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{
+		{Name: "Bool", Type: types.Bool},
+		{Name: "DateTime", Type: types.DateTime},
+		{Name: "Decimal", Type: types.Decimal},
+		{Name: "Dynamic", Type: types.Dynamic},
+		{Name: "GUID", Type: types.GUID},
+		{Name: "Int", Type: types.Int},
+		{Name: "Long", Type: types.Long},
+		{Name: "Real", Type: types.Real},
+		{Name: "String", Type: types.String},
+		{Name: "Timespan", Type: types.Timespan},
+	}
+
+	tests := []struct {
+		desc string
+		rows string
+	}{
+		{desc: "No rows", rows: `[]`},
+		{desc: "Null rows", rows: `null`},
+		{
+			desc: "Values",
+			rows: `[[true,"2019-08-27T04:14:55.302919Z","3.2","{\"key\":\"value\"}","bde1b9ee-3a4d-4a79-a3a4-0a8df3ea8b53",1,-9007199254740993,1.5e3,"John \"Doak\" \u00e9t\u00e9","1.00:00:00.099"]]`,
+		},
+		{desc: "Nulls", rows: `[[null,null,null,null,null,null,null,null,null,null]]`},
+		{
+			desc: "Whitespace and empty strings",
+			rows: "[ [ false , null , null , \"\" , null , 0 , 0 , 0 , \"\" , null ] ,\n\t[false,null,null,\"\",null,2,3,4,\"é\",null] ]",
+		},
+		{
+			desc: "Dynamic values",
+			rows: `[[null,null,null,{"b":[1,"]",{"c":null}],"a":"<&>"},null,null,null,null,null,null],[null,null,null,[1.0,2,"x"],null,null,null,null,null,null],[null,null,null,12,null,null,null,null,null,null],[null,null,null,true,null,null,null,null,null,null]]`,
+		},
+		{
+			desc: "Error row",
+			rows: `[[true,null,null,null,null,null,null,null,null,null],{"OneApiErrors":[{"error":{"code":"LimitsExceeded","message":"Request is invalid and cannot be executed."}}]}]`,
+		},
+		{desc: "Bad long", rows: `[[null,null,null,null,null,null,1.5,null,null,null]]`},
+		{desc: "Int too large", rows: `[[null,null,null,null,null,2147483648,null,null,null,null]]`},
+		{desc: "Long too large", rows: `[[null,null,null,null,null,null,9223372036854775808,null,null,null]]`},
+		{desc: "Bool as string", rows: `[["true",null,null,null,null,null,null,null,null,null]]`},
+		{desc: "String as number", rows: `[[null,null,null,null,null,null,null,null,1,null]]`},
+		{desc: "Bad GUID", rows: `[[null,null,null,null,"guid",null,null,null,null,null]]`},
+		{desc: "Bad Decimal", rows: `[[null,null,"3..2",null,null,null,null,null,null,null]]`},
+		{desc: "Bad DateTime", rows: `[[null,"yesterday",null,null,null,null,null,null,null,null]]`},
+		{desc: "Short row", rows: `[[true,null]]`},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var interRows []interface{}
+			if err := json.Unmarshal([]byte(test.rows), &interRows); err != nil {
+				panic(err)
+			}
+			wantRows, wantErrs, wantErr := Rows(columns, interRows, errors.OpQuery)
+
+			gotRows, gotErrs, gotErr := RawRows(columns, []byte(test.rows), errors.OpQuery)
+			if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+				t.Fatalf("TestRawRows(%s): got err == %v, want err == %v", test.desc, gotErr, wantErr)
+			}
+			if diff := pretty.Compare(wantRows, gotRows); diff != "" {
+				t.Errorf("TestRawRows(%s): rows -want/+got:\n%s", test.desc, diff)
+			}
+			if diff := pretty.Compare(wantErrs, gotErrs); diff != "" {
+				t.Errorf("TestRawRows(%s): row errors -want/+got:\n%s", test.desc, diff)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync/atomic"
 
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
@@ -22,19 +23,31 @@ type DataSet struct {
 
 // Decoder implements frames.Decoder on the REST v1 frames.
 type Decoder struct {
+	// Bytes, if set, is increased by the size of the JSON of every table decoded.
+	Bytes *atomic.Int64
+
 	dec *json.Decoder
 	op  errors.Op
+	// offset is the position in the input when Bytes was last increased.
+	offset int64
 }
 
+var _ frames.Decoder = (*Decoder)(nil)
+
 // Decode implements frames.Decoder.Decode(). This is not thread safe.
-func (d *Decoder) Decode(ctx context.Context, r io.ReadCloser, op errors.Op) chan frames.Frame {
+func (d *Decoder) Decode(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame {
 	ch := make(chan frames.Frame, 1) // Channel is sized to 1. We read from the channel faster than we put on the channel.
 	d.dec = json.NewDecoder(r)
 	d.op = op
+	d.offset = 0
 
 	go func() {
-		defer r.Close()
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
 		defer close(ch)
+		// Stopping the query closes r, which ends a read that waits for the service.
+		defer frames.CloseOnDone(ctx, r)()
 
 		if err := d.nextDelimEquals('{'); err != nil {
 			frames.Errorf(ctx, ch, err.Error())
@@ -99,6 +112,16 @@ func (d *Decoder) findStringToken(s string) error {
 	}
 }
 
+// countBytes increases Bytes by the size of the input read since the last call.
+func (d *Decoder) countBytes() {
+	if d.Bytes == nil {
+		return
+	}
+	offset := d.dec.InputOffset()
+	d.Bytes.Add(offset - d.offset)
+	d.offset = offset
+}
+
 func (d *Decoder) processTables(ctx context.Context, ch chan frames.Frame) error {
 	rows := unmarshal.GetRows()
 	defer unmarshal.PutRows(rows)
@@ -114,13 +137,14 @@ func (d *Decoder) processTables(ctx context.Context, ch chan frames.Frame) error
 		if err != nil {
 			return err
 		}
+		d.countBytes()
 
 		columns, err := dt.DataTypes.ToColumns()
 		if err != nil {
 			return err
 		}
 
-		dt.KustoRows, dt.RowErrors, err = unmarshal.Rows(columns, dt.Rows, d.op)
+		dt.KustoRows, dt.RowErrors, err = unmarshal.NewConverter(columns).Rows(dt.Rows, d.op)
 		if err != nil {
 			return err
 		}
@@ -3,6 +3,7 @@ package v2
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"testing"
@@ -67,3 +68,67 @@ func BenchmarkGetFrameType(b *testing.B) {
 		}
 	}
 }
+
+// Current (10,000 row non-primary table ahead of the primary result):
+// BenchmarkTimeToFirstRow/AllTables-4             	      72	  19963468 ns/op	 4758524 B/op	  120078 allocs/op
+// BenchmarkTimeToFirstRow/PrimaryResultsOnly-4    	     100	  10265983 ns/op	 1049629 B/op	      82 allocs/op
+// New (rows read from the raw JSON by unmarshal.RawRows()):
+// BenchmarkTimeToFirstRow/AllTables               	      99	  15611786 ns/op	 3493421 B/op	   30249 allocs/op
+// BenchmarkTimeToFirstRow/PrimaryResultsOnly      	     128	   9181472 ns/op	 1050648 B/op	      73 allocs/op
+
+// BenchmarkTimeToFirstRow measures the time it takes to receive the primary result when a large non-primary table
+// precedes it in the stream, with and without PrimaryResultsOnly.
+func BenchmarkTimeToFirstRow(b *testing.B) {
+	stream := timeToFirstRowStream(10000)
+
+	for _, primaryOnly := range []bool{false, true} {
+		primaryOnly := primaryOnly
+		name := "AllTables"
+		if primaryOnly {
+			name = "PrimaryResultsOnly"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			dec := Decoder{PrimaryResultsOnly: primaryOnly}
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				framesCh := dec.Decode(ctx, io.NopCloser(bytes.NewReader(stream)), errors.OpQuery)
+				for fr := range framesCh {
+					if ef, ok := fr.(frames.Error); ok {
+						panic(ef.Error())
+					}
+					if dt, ok := fr.(DataTable); ok && dt.TableKind == frames.PrimaryResult {
+						break
+					}
+				}
+				b.StopTimer()
+				cancel()
+				for range framesCh {
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// timeToFirstRowStream creates a non-progressive stream with a non-primary table of nonPrimaryRows rows ahead of
+// a small primary result.
+func timeToFirstRowStream(nonPrimaryRows int) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},`)
+	buf.WriteString(`{"FrameType":"DataTable","TableId":0,"TableKind":"QueryTraceLog","TableName":"QueryTraceLog",`)
+	buf.WriteString(`"Columns":[{"ColumnName":"Id","ColumnType":"long"},{"ColumnName":"Value","ColumnType":"dynamic"}],"Rows":[`)
+	for i := 0; i < nonPrimaryRows; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(buf, `[%d,"{\"Name\":\"row%d\",\"Values\":[1,2,3]}"]`, i, i)
+	}
+	buf.WriteString(`]},`)
+	buf.WriteString(`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",`)
+	buf.WriteString(`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[[1],[2],[3]]},`)
+	buf.WriteString(`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`)
+	return buf.Bytes()
+}
@@ -5,25 +5,43 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync/atomic"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
 )
 
 // Decoder implements frames.Decoder on the REST v2 frames.
 type Decoder struct {
-	columns table.Columns
-	dec     *json.Decoder
-	op      errors.Op
+	// PrimaryResultsOnly causes the Decoder to drop every table that is not a PrimaryResult at the frame level.
+	// The rows of those tables are never decoded and the frames are never sent on the output channel.
+	PrimaryResultsOnly bool
+	// Bytes, if set, is increased by the size of the JSON of every frame decoded.
+	Bytes *atomic.Int64
+
+	// conv converts the rows of the TableFragments of the current table, it is made from its TableHeader.
+	conv *unmarshal.Converter
+	dec  *json.Decoder
+	op   errors.Op
+
+	// skipTable is set when PrimaryResultsOnly is set and we are between the TableHeader and
+	// TableCompletion of a non-primary table.
+	skipTable bool
 
 	frameRaw json.RawMessage
+	// offset is the position in the input when Bytes was last increased.
+	offset int64
 }
 
+var _ frames.Decoder = (*Decoder)(nil)
+
 // Decode implements frames.Decoder.Decode(). This is not thread safe.
-func (d *Decoder) Decode(ctx context.Context, r io.ReadCloser, op errors.Op) chan frames.Frame {
-	d.columns = nil
+func (d *Decoder) Decode(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame {
+	d.conv = nil
+	d.skipTable = false
+	d.offset = 0
 	d.dec = json.NewDecoder(r)
 	d.dec.UseNumber()
 	d.op = op
@@ -31,8 +49,12 @@ func (d *Decoder) Decode(ctx context.Context, r io.ReadCloser, op errors.Op) cha
 	ch := make(chan frames.Frame, 1) // Channel is sized to 1. We read from the channel faster than we put on the channel.
 
 	go func() {
-		defer r.Close()
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
 		defer close(ch)
+		// Stopping the query closes r, which ends a read that waits for the service.
+		defer frames.CloseOnDone(ctx, r)()
 
 		// We should receive a '[' indicating the start of the JSON list of Frames.
 		t, err := d.dec.Token()
@@ -54,6 +76,7 @@ func (d *Decoder) Decode(ctx context.Context, r io.ReadCloser, op errors.Op) cha
 			frames.Errorf(ctx, ch, "first frame had error: %s", err)
 			return
 		}
+		d.countBytes()
 		ch <- dsh
 
 		// Start decoding the rest of the frames.
@@ -82,6 +105,16 @@ func (d *Decoder) dataSetHeader() (DataSetHeader, error) {
 	return dsh, err
 }
 
+// countBytes increases Bytes by the size of the input read since the last call.
+func (d *Decoder) countBytes() {
+	if d.Bytes == nil {
+		return
+	}
+	offset := d.dec.InputOffset()
+	d.Bytes.Add(offset - d.offset)
+	d.offset = offset
+}
+
 // decodeFrames is used to decode incoming frames after the DataSetHeader has been received.
 func (d *Decoder) decodeFrames(ctx context.Context, ch chan frames.Frame) {
 	for d.dec.More() {
@@ -110,6 +143,7 @@ func (d *Decoder) decode(ctx context.Context, ch chan frames.Frame) error {
 	if err != nil {
 		return err
 	}
+	d.countBytes()
 
 	ft, err := getFrameType(d.frameRaw)
 	if err != nil {
@@ -118,48 +152,76 @@ func (d *Decoder) decode(ctx context.Context, ch chan frames.Frame) error {
 
 	switch {
 	case bytes.Equal(ft, ftDataTable):
+		if d.PrimaryResultsOnly {
+			kind, err := getTableKind(d.frameRaw)
+			if err != nil {
+				return err
+			}
+			if kind != frames.PrimaryResult {
+				return nil
+			}
+		}
 		dt := DataTable{}
-		if err := dt.UnmarshalRaw(d.frameRaw); err != nil {
+		if err := unmarshalDataTable(&dt, d.frameRaw); err != nil {
 			return err
 		}
 		dt.Op = d.op
 		ch <- dt
 	case bytes.Equal(ft, ftDataSetCompletion):
 		dc := DataSetCompletion{}
-		if err := dc.UnmarshalRaw(d.frameRaw); err != nil {
+		if err := unmarshalDataSetCompletion(&dc, d.frameRaw); err != nil {
 			return err
 		}
 		dc.Op = d.op
 		ch <- dc
 	case bytes.Equal(ft, ftTableHeader):
 		th := TableHeader{}
-		if err := th.UnmarshalRaw(d.frameRaw); err != nil {
+		if err := json.Unmarshal(d.frameRaw, &th); err != nil {
 			return err
 		}
+		if d.PrimaryResultsOnly && th.TableKind != frames.PrimaryResult {
+			d.skipTable = true
+			return nil
+		}
 		th.Op = d.op
-		d.columns = th.Columns
+		d.conv = unmarshal.NewConverter(th.Columns)
 		ch <- th
 	case bytes.Equal(ft, ftTableFragment):
-		tf := TableFragment{Columns: d.columns}
-		if err := tf.UnmarshalRaw(d.frameRaw); err != nil {
+		if d.skipTable {
+			return nil
+		}
+		conv := d.conv
+		if conv == nil {
+			// A TableFragment without a TableHeader has no columns.
+			conv = unmarshal.NewConverter(nil)
+		}
+		tf := TableFragment{Columns: conv.Columns()}
+		if err := unmarshalTableFragment(&tf, d.frameRaw, conv); err != nil {
 			return err
 		}
 		tf.Op = d.op
 		ch <- tf
 	case bytes.Equal(ft, ftTableProgress):
+		if d.skipTable {
+			return nil
+		}
 		tp := TableProgress{}
-		if err := tp.UnmarshalRaw(d.frameRaw); err != nil {
+		if err := json.Unmarshal(d.frameRaw, &tp); err != nil {
 			return err
 		}
 		tp.Op = d.op
 		ch <- tp
 	case bytes.Equal(ft, ftTableCompletion):
+		if d.skipTable {
+			d.skipTable = false
+			return nil
+		}
 		tc := TableCompletion{}
-		if err := tc.UnmarshalRaw(d.frameRaw); err != nil {
+		if err := json.Unmarshal(d.frameRaw, &tc); err != nil {
 			return err
 		}
 		tc.Op = d.op
-		d.columns = nil
+		d.conv = nil
 		ch <- tc
 	default:
 		return fmt.Errorf("received FrameType %s, which we did not expect", ft)
@@ -167,6 +229,20 @@ func (d *Decoder) decode(ctx context.Context, ch chan frames.Frame) error {
 	return nil
 }
 
+// tableKind is used to extract only the TableKind of a DataTable, which avoids decoding the rows of tables we discard.
+type tableKind struct {
+	TableKind frames.TableKind
+}
+
+// getTableKind returns the TableKind of a raw DataTable frame.
+func getTableKind(message json.RawMessage) (frames.TableKind, error) {
+	tk := tableKind{}
+	if err := json.Unmarshal(message, &tk); err != nil {
+		return "", err
+	}
+	return tk.TableKind, nil
+}
+
 var (
 	frameType = []byte(fmt.Sprintf("%q:", frames.FieldFrameType))
 	comma     = []byte(`,`)
@@ -457,6 +457,91 @@ func TestErrorDecode(t *testing.T) {
 	}
 }
 
+func TestPrimaryResultsOnlyDecode(t *testing.T) {
+	t.Parallel()
+
+	jsonStr := `[
+  {"FrameType":"dataSetHeader","IsProgressive":true,"Version":"v2.0"},
+  {
+    "FrameType":"DataTable","TableId":0,"TableKind":"QueryProperties","TableName":"@ExtendedProperties",
+    "Columns":[{"ColumnName":"TableId","ColumnType":"int"},{"ColumnName":"Key","ColumnType":"string"}],
+    "Rows":[[1,"Visualization"]]
+  },
+  {"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"x","ColumnType":"long"}]},
+  {"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[[1],[2]]},
+  {"FrameType":"TableProgress","TableId":1,"TableProgress":100},
+  {"FrameType":"TableCompletion","TableId":1,"RowCount":2},
+  {"FrameType":"TableHeader","TableId":2,"TableKind":"QueryTraceLog","TableName":"QueryTraceLog","Columns":[{"ColumnName":"y","ColumnType":"string"}]},
+  {"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":2,"Rows":[["a"]]},
+  {"FrameType":"TableProgress","TableId":2,"TableProgress":100},
+  {"FrameType":"TableCompletion","TableId":2,"RowCount":1},
+  {
+    "FrameType":"DataTable","TableId":3,"TableKind":"QueryCompletionInformation","TableName":"QueryCompletionInformation",
+    "Columns":[{"ColumnName":"ClientRequestId","ColumnType":"string"}],
+    "Rows":[["KPC.execute;752dd747-5f6a-45c6-9ee2-e6662530ecc3"]]
+  },
+  {"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}
+]`
+
+	cols := table.Columns{{Name: "x", Type: "long"}}
+	wantFrames := []interface{}{
+		DataSetHeader{Base: Base{FrameType: "dataSetHeader"}, IsProgressive: true, Version: "v2.0", Op: errors.OpQuery},
+		TableHeader{Base: Base{FrameType: "TableHeader"}, TableID: 1, TableKind: "PrimaryResult", TableName: "PrimaryResult", Columns: cols, Op: errors.OpQuery},
+		TableFragment{
+			Base:              Base{FrameType: "TableFragment"},
+			TableID:           1,
+			TableFragmentType: "DataAppend",
+			KustoRows: []value.Values{
+				{value.Long{Value: 1, Valid: true}},
+				{value.Long{Value: 2, Valid: true}},
+			},
+			Columns: cols,
+			Op:      errors.OpQuery,
+		},
+		TableProgress{Base: Base{FrameType: "TableProgress"}, TableID: 1, TableProgress: 100, Op: errors.OpQuery},
+		TableCompletion{Base: Base{FrameType: "TableCompletion"}, TableID: 1, RowCount: 2, Op: errors.OpQuery},
+		DataSetCompletion{Base: Base{FrameType: "DataSetCompletion"}, Op: errors.OpQuery},
+	}
+
+	dec := Decoder{PrimaryResultsOnly: true}
+	ch := dec.Decode(context.Background(), io.NopCloser(strings.NewReader(jsonStr)), errors.OpQuery)
+
+	var got []interface{}
+	for fr := range ch {
+		got = append(got, fr)
+	}
+	require.EqualValues(t, wantFrames, got)
+}
+
+func TestDecodeDataSetCompletionErrors(t *testing.T) {
+	t.Parallel()
+
+	jsonStr := `[
+  {"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},
+  {
+    "FrameType":"DataSetCompletion","HasErrors":true,"Cancelled":false,
+    "OneApiErrors":[{"error":{"code":"LimitsExceeded","message":"Query execution has exceeded the allowed limits"}}, "plain"]
+  }
+]`
+
+	dec := Decoder{}
+	ch := dec.Decode(context.Background(), io.NopCloser(strings.NewReader(jsonStr)), errors.OpQuery)
+
+	var got []interface{}
+	for fr := range ch {
+		got = append(got, fr)
+	}
+	require.Len(t, got, 2)
+	completion, ok := got[1].(DataSetCompletion)
+	require.True(t, ok, "got %T", got[1])
+	require.EqualValues(t, []string{`{"error":{"code":"LimitsExceeded","message":"Query execution has exceeded the allowed limits"}}`, "plain"}, completion.OneAPIErrors)
+
+	require.EqualValues(t, []errors.Error{
+		*errors.ES(errors.OpQuery, errors.KLimitsExceeded, "Query execution has exceeded the allowed limits;See https://docs.microsoft.com/en-us/azure/kusto/concepts/querylimits"),
+		*errors.ES(errors.OpQuery, errors.KInternal, "plain"),
+	}, CompletionErrors(completion, errors.OpQuery))
+}
+
 func timeMustParse(layout string, p string) time.Time {
 	t, err := time.Parse(layout, p)
 	if err != nil {
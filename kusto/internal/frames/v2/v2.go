@@ -3,70 +3,65 @@ package v2
 
 import (
 	"fmt"
+
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/data/table"
-	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	pub "github.com/Azure/azure-kusto-go/kusto/frames"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal"
 	"github.com/Azure/azure-kusto-go/kusto/internal/frames/unmarshal/json"
 )
 
-// Base is information that is encoded in all frames. The fields aren't actually
-// in the spec, but are transmitted on the wire.
-type Base struct {
-	FrameType string
-}
+// The frame types are defined in the public kusto/frames package.
+type (
+	Base              = pub.Base
+	DataSetHeader     = pub.DataSetHeader
+	DataTable         = pub.DataTable
+	DataSetCompletion = pub.DataSetCompletion
+	TableHeader       = pub.TableHeader
+	TableFragment     = pub.TableFragment
+	TableProgress     = pub.TableProgress
+	TableCompletion   = pub.TableCompletion
+)
 
-// DataSetHeader is the first frame in a response. It implements Frame.
-type DataSetHeader struct {
-	Base
-	// Version is the version of the APi responding. The current version is "v2.0".
-	Version string
-	// IsProgressive indicates that TableHeader, TableFragment, TableProgress, and TableCompletion
-	IsProgressive bool
+// unmarshalDataTable unmarshals the raw JSON representing a DataTable. The rows are converted straight from their
+// JSON by the unmarshal.Converter of its columns.
+func unmarshalDataTable(d *DataTable, raw json.RawMessage) error {
+	aux := struct {
+		*DataTable
+		Rows json.RawMessage
+	}{DataTable: d}
 
-	Op errors.Op
-}
+	if err := json.Unmarshal(raw, &aux); err != nil || !rowsList(aux.Rows) {
+		return unmarshalDataTableRows(d, raw)
+	}
 
-func (DataSetHeader) IsFrame() {}
-
-// DataTable is used report information as a Table with Columns as row headers and Rows as the contained
-// data. It implements Frame.
-type DataTable struct {
-	Base
-	// TableID is a numeric representation of the this dataTable in relation to other dataTables returned
-	// in numeric order starting at 09.
-	TableID int `json:"TableId"`
-	// TableKind is a Kusto dataTable sub-type.
-	TableKind frames.TableKind
-	// TableName is a name for the dataTable.
-	TableName frames.TableKind
-	// Columns is a list of column names and their Kusto storage types.
-	Columns table.Columns
-	// Rows contains the table data that was fetched, along with errors.
-	Rows      []interface{}
-	KustoRows []value.Values
-	RowErrors []errors.Error
-
-	Op errors.Op `json:"-"`
+	v, rowErrors, err := unmarshal.NewConverter(d.Columns).RawRows(aux.Rows, d.Op)
+	if err != nil {
+		return err
+	}
+	d.KustoRows = v
+	d.RowErrors = rowErrors
+	return nil
 }
 
-// UnmarshalRaw unmarshals the raw JSON representing a DataTable.
-func (d *DataTable) UnmarshalRaw(raw json.RawMessage) error {
-	d.Rows = unmarshal.GetRows()
-	defer func() {
-		unmarshal.PutRows(d.Rows)
-		d.Rows = nil
-	}()
-
-	if err := json.Unmarshal(raw, d); err != nil {
+// unmarshalDataTableRows unmarshals the raw JSON representing a DataTable by decoding its rows into []interface{}
+// first. It returns the errors of the DataTables that unmarshalDataTable() cannot read, such as one with a OneApiError
+// in place of its rows.
+func unmarshalDataTableRows(d *DataTable, raw json.RawMessage) error {
+	aux := struct {
+		*DataTable
+		Rows []interface{}
+	}{DataTable: d, Rows: unmarshal.GetRows()}
+	defer func() { unmarshal.PutRows(aux.Rows) }()
+
+	if err := json.Unmarshal(raw, &aux); err != nil {
 		if oe := RawToOneAPIErr(raw, d.Op); oe != nil {
 			return oe
 		}
 		return err
 	}
 
-	v, rowErrors, err := unmarshal.Rows(d.Columns, d.Rows, d.Op)
+	v, rowErrors, err := unmarshal.Rows(d.Columns, aux.Rows, d.Op)
 	if err != nil {
 		return err
 	}
@@ -75,97 +70,89 @@ func (d *DataTable) UnmarshalRaw(raw json.RawMessage) error {
 	return nil
 }
 
-// IsFrame implements frame.Frame.
-func (DataTable) IsFrame() {}
+// unmarshalDataSetCompletion unmarshals the raw JSON representing a DataSetCompletion. The service sends the
+// OneApiErrors as JSON objects, they are kept in OneAPIErrors as their JSON text.
+func unmarshalDataSetCompletion(d *DataSetCompletion, raw json.RawMessage) error {
+	aux := struct {
+		*DataSetCompletion
+		OneAPIErrors []json.RawMessage `json:"OneApiErrors"`
+	}{DataSetCompletion: d}
 
-// DataSetCompletion indicates the stream id done. It implements Frame.
-type DataSetCompletion struct {
-	Base
-	// HasErrors indicates that their was an error in the stream.
-	HasErrors bool
-	// Cancelled indicates that the request was cancelled.
-	Cancelled bool
-	// OneAPIErrors is a list of errors encountered.
-	OneAPIErrors []string `json:"OneApiErrors"`
+	err := json.Unmarshal(raw, &aux)
+	if err != nil {
+		return errors.GetCombinedError(fmt.Errorf("json parsing failed: %v", raw), err)
+	}
 
-	Op errors.Op `json:"-"`
+	d.OneAPIErrors = nil
+	for _, oneErr := range aux.OneAPIErrors {
+		var s string
+		if json.Unmarshal(oneErr, &s) == nil {
+			d.OneAPIErrors = append(d.OneAPIErrors, s)
+			continue
+		}
+		d.OneAPIErrors = append(d.OneAPIErrors, string(oneErr))
+	}
+	return nil
 }
 
-// IsFrame implements frame.Frame.
-func (DataSetCompletion) IsFrame() {}
-
-// UnmarshalRaw unmarshals the raw JSON representing a DataSetCompletion.
-func (d *DataSetCompletion) UnmarshalRaw(raw json.RawMessage) error {
-	err := json.Unmarshal(raw, &d)
-	if err != nil {
-		err = errors.GetCombinedError(fmt.Errorf("json parsing failed: %v", raw), err)
+// CompletionErrors returns the errors of the OneAPIErrors of d, which are the failures of a query that happened after
+// some of its results were sent.
+func CompletionErrors(d DataSetCompletion, op errors.Op) []errors.Error {
+	if len(d.OneAPIErrors) == 0 {
+		return nil
 	}
-	return err
-}
 
-// TableHeader indicates that instead of receiving a dataTable, we will receive a
-// stream of table information. This structure holds the base information, but none
-// of the row information.
-type TableHeader struct {
-	Base
-	// TableID is a numeric representation of the this TableHeader in the stream.
-	TableID int `json:"TableId"`
-	// TableKind is a Kusto Table sub-type.
-	TableKind frames.TableKind
-	// TableName is a name for the Table.
-	TableName frames.TableKind
-	// Columns is a list of column names and their Kusto storage types.
-	Columns table.Columns
-
-	Op errors.Op `json:"-"`
+	errs := make([]errors.Error, 0, len(d.OneAPIErrors))
+	for _, oneErr := range d.OneAPIErrors {
+		m := map[string]interface{}{}
+		if json.Unmarshal([]byte(oneErr), &m) == nil {
+			if e := errors.OneToErr(map[string]interface{}{"OneApiErrors": []interface{}{m}}, op); e != nil {
+				errs = append(errs, *e)
+				continue
+			}
+		}
+		errs = append(errs, *errors.ES(op, errors.KInternal, "%s", oneErr))
+	}
+	return errs
 }
 
-// IsFrame implements frame.Frame.
-func (TableHeader) IsFrame() {}
+// unmarshalTableFragment unmarshals the raw JSON representing a TableFragment, whose rows are converted by conv, the
+// Converter made from the TableHeader of the table. See unmarshalDataTable().
+func unmarshalTableFragment(t *TableFragment, raw json.RawMessage, conv *unmarshal.Converter) error {
+	aux := struct {
+		*TableFragment
+		Rows json.RawMessage
+	}{TableFragment: t}
 
-// UnmarshalRaw unmarshals the raw JSON representing a TableHeader.
-func (t *TableHeader) UnmarshalRaw(raw json.RawMessage) error {
-	return json.Unmarshal(raw, &t)
-}
+	if err := json.Unmarshal(raw, &aux); err != nil || !rowsList(aux.Rows) {
+		return unmarshalTableFragmentRows(t, raw, conv)
+	}
 
-// TableFragment details the streaming data passed by server that would normally be the Row data in
-type TableFragment struct {
-	Base
-	// TableID is a numeric representation of the this table in relation to other table parts returned.
-	TableID int `json:"TableId"`
-	// FieldCount is the number of  fields being returned. This should align with the len(TableHeader.Columns).
-	FieldCount int
-	// TableFragment type is the type of TFDataAppend or TFDataReplace.
-	TableFragmentType string
-	// Rows contains the the table data th[at was fetched.
-	Rows      []interface{}
-	KustoRows []value.Values
-	RowErrors []errors.Error
-
-	Columns table.Columns `json:"-"` // Needed for decoding values.
-
-	Op errors.Op `json:"-"`
+	v, rowErrors, err := conv.RawRows(aux.Rows, t.Op)
+	if err != nil {
+		return err
+	}
+	t.KustoRows = v
+	t.RowErrors = rowErrors
+	return nil
 }
 
-// IsFrame implements frame.Frame.
-func (TableFragment) IsFrame() {}
-
-// UnmarshalRaw unmarshals the raw JSON representing a TableFragment.
-func (t *TableFragment) UnmarshalRaw(raw json.RawMessage) error {
-	t.Rows = unmarshal.GetRows()
-	defer func() {
-		unmarshal.PutRows(t.Rows)
-		t.Rows = nil
-	}()
+// unmarshalTableFragmentRows is unmarshalDataTableRows() for a TableFragment.
+func unmarshalTableFragmentRows(t *TableFragment, raw json.RawMessage, conv *unmarshal.Converter) error {
+	aux := struct {
+		*TableFragment
+		Rows []interface{}
+	}{TableFragment: t, Rows: unmarshal.GetRows()}
+	defer func() { unmarshal.PutRows(aux.Rows) }()
 
-	if err := json.Unmarshal(raw, t); err != nil {
+	if err := json.Unmarshal(raw, &aux); err != nil {
 		if oe := RawToOneAPIErr(raw, t.Op); oe != nil {
 			return oe
 		}
 		return err
 	}
 
-	v, rowErrors, err := unmarshal.Rows(t.Columns, t.Rows, t.Op)
+	v, rowErrors, err := conv.Rows(aux.Rows, t.Op)
 	if err != nil {
 		return err
 	}
@@ -175,43 +162,9 @@ func (t *TableFragment) UnmarshalRaw(raw json.RawMessage) error {
 	return nil
 }
 
-// TableProgress interleaves with the TableFragment frame described above. It's sole purpose
-// is to notify the client about the query progress.
-type TableProgress struct {
-	Base
-	// TableID is a numeric representation of the this table in relation to other table parts returned.
-	TableID int `json:"TableId"`
-	// TableProgress is the progress in percent (0--100).
-	TableProgress float64
-
-	Op errors.Op `json:"-"`
-}
-
-// IsFrame implements frame.Frame.
-func (TableProgress) IsFrame() {}
-
-// UnmarshalRaw unmarshals the raw JSON representing a TableProgress.
-func (t *TableProgress) UnmarshalRaw(raw json.RawMessage) error {
-	return json.Unmarshal(raw, &t)
-}
-
-// TableCompletion frames marks the end of the table transmission. No more frames related to that table will be sent.
-type TableCompletion struct {
-	Base
-	// TableID is a numeric representation of the this table in relation to other table parts returned.
-	TableID int `json:"TableId"`
-	// RowCount is the final number of rows in the table.
-	RowCount int
-
-	Op errors.Op `json:"-"`
-}
-
-// IsFrame implements frame.Frame.
-func (TableCompletion) IsFrame() {}
-
-// UnmarshalRaw unmarshals the raw JSON representing a TableCompletion.
-func (t *TableCompletion) UnmarshalRaw(raw json.RawMessage) error {
-	return json.Unmarshal(raw, &t)
+// rowsList reports if the raw Rows of a frame are a list, or are missing or null, which unmarshal.RawRows() reads.
+func rowsList(rows json.RawMessage) bool {
+	return len(rows) == 0 || rows[0] == '[' || rows[0] == 'n'
 }
 
 // RawToOneAPIErr returns a OneAPI error if it is buried where the "Row" should be. Otherwise it returns nil.
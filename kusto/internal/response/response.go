@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -20,6 +21,11 @@ func (o *originalCloser) Read(p []byte) (n int, err error) {
 	return o.wrapper.Read(p)
 }
 
+// Abort closes the original body without closing the wrapper, which may be reading it, see frames.CloseOnDone().
+func (o *originalCloser) Abort() error {
+	return o.original.Close()
+}
+
 func (o *originalCloser) Close() error {
 	if err := o.wrapper.Close(); err != nil {
 		return err
@@ -27,11 +33,50 @@ func (o *originalCloser) Close() error {
 	return o.original.Close()
 }
 
+// Decompressor returns a reader of the decompressed content of r.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// Decompressors holds the Decompressor to use for each Content-Encoding, in addition to gzip and deflate.
+type Decompressors map[string]Decompressor
+
+// AcceptEncoding returns the value of the Accept-Encoding header advertising the encodings in d and gzip.
+func (d Decompressors) AcceptEncoding() string {
+	encs := make([]string, 0, len(d)+1)
+	for enc := range d {
+		if enc != "gzip" {
+			encs = append(encs, enc)
+		}
+	}
+	sort.Strings(encs)
+	return strings.Join(append(encs, "gzip"), ", ")
+}
+
+// TranslateBody returns the body of resp, decompressed according to its Content-Encoding. Only gzip and deflate
+// are supported.
 func TranslateBody(resp *http.Response, op errors.Op) (io.ReadCloser, error) {
+	return Decompressors(nil).TranslateBody(resp, op)
+}
+
+// TranslateBody returns the body of resp, decompressed according to its Content-Encoding. The Decompressors in d
+// take precedence over the built in gzip and deflate support.
+func (d Decompressors) TranslateBody(resp *http.Response, op errors.Op) (io.ReadCloser, error) {
 	body := resp.Body
 	var wrapper io.ReadCloser
-	switch enc := strings.ToLower(resp.Header.Get("Content-Encoding")); enc {
-	case "":
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if dec, ok := d[enc]; ok && enc != "" {
+		var err error
+		wrapper, err = dec(resp.Body)
+		if err != nil {
+			return nil, errors.E(op, errors.KInternal, fmt.Errorf("%s reader error: %w", enc, err))
+		}
+		return &originalCloser{
+			original: body,
+			wrapper:  wrapper,
+		}, nil
+	}
+
+	switch enc {
+	case "", "identity":
 		return body, nil
 	case "gzip":
 		var err error
@@ -0,0 +1,12 @@
+// Package stmt holds the string constant type of the statements, which is shared by the kusto and kql packages.
+package stmt
+
+// StringConstant is the type of the text of a statement. As it is in an internal package, it cannot be named
+// outside the module, so the only way to pass a StringConstant to kusto.NewStmt() or kql.New() is a string constant.
+// This allows us to enforce the use of constants or strings built with injection protection.
+type StringConstant string
+
+// String implements fmt.Stringer.
+func (s StringConstant) String() string {
+	return string(s)
+}
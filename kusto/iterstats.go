@@ -0,0 +1,65 @@
+package kusto
+
+// iterstats.go implements RowIterator.Stats(), counters of what a RowIterator received and returned.
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// IterStats are counters of the results of a query, see RowIterator.Stats().
+type IterStats struct {
+	// Rows is the number of rows returned by the RowIterator, not counting the inline errors.
+	Rows int64
+	// Tables is the number of primary result tables whose rows were returned, including the tables without rows.
+	Tables int64
+	// Frames is the number of frames received, or of tables for a management command.
+	Frames int64
+	// Bytes is the approximate size of the response that was decoded.
+	Bytes int64
+	// Duration is the time from the first frame to the end of the response. While the response is being received,
+	// it is the time so far. It stops at Stop() if the response did not end before.
+	Duration time.Duration
+}
+
+// iterCounters holds the counters of a RowIterator. They are updated atomically, so that Stats() can be called
+// from any goroutine.
+type iterCounters struct {
+	rows   atomic.Int64
+	tables atomic.Int64
+	frames atomic.Int64
+	// bytes is shared with the decoder of the response, nil if the response was not decoded, such as for a cached
+	// result.
+	bytes *atomic.Int64
+	// start is when the first frame was received, zero if the RowIterator has no response.
+	start time.Time
+	// end is when the response ended or Stop() was called, as Unix nanoseconds, zero before that.
+	end atomic.Int64
+}
+
+// stop records the end of the response, unless it was already recorded.
+func (c *iterCounters) stop() {
+	c.end.CompareAndSwap(0, nower().UnixNano())
+}
+
+// Stats returns the counters of the results of the query. They are updated while the rows are read and can be read
+// at any time, including after Do() returned or Stop() was called. The results served from the client cache have
+// no frames, bytes or duration.
+func (r *RowIterator) Stats() IterStats {
+	stats := IterStats{
+		Rows:   r.stats.rows.Load(),
+		Tables: r.stats.tables.Load(),
+		Frames: r.stats.frames.Load(),
+	}
+	if r.stats.bytes != nil {
+		stats.Bytes = r.stats.bytes.Load()
+	}
+	if !r.stats.start.IsZero() {
+		end := nower()
+		if ns := r.stats.end.Load(); ns != 0 {
+			end = time.Unix(0, ns)
+		}
+		stats.Duration = end.Sub(r.stats.start)
+	}
+	return stats
+}
@@ -0,0 +1,81 @@
+package kusto
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterStats(t *testing.T) {
+	t.Parallel()
+
+	v1Body, err := os.ReadFile(filepath.Join("testdata", "visualization_v1_timechart.json"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc string
+		body string
+		mgmt bool
+		want IterStats
+	}{
+		{desc: "Non-progressive", body: datasetNonProgressive, want: IterStats{Rows: 3, Tables: 3, Frames: 6}},
+		{desc: "Progressive", body: datasetProgressive, want: IterStats{Rows: 3, Tables: 3, Frames: 11}},
+		{desc: "Management command", body: string(v1Body), mgmt: true, want: IterStats{Rows: 2, Tables: 1, Frames: 4}},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := datasetClient(t, test.body)
+			var iter *RowIterator
+			var err error
+			if test.mgmt {
+				iter, err = client.Mgmt(context.Background(), "db", NewStmt(".show tables"))
+			} else {
+				iter, err = client.Query(context.Background(), "db", NewStmt("T; T | count"))
+			}
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			require.NoError(t, iter.Do(func(*table.Row) error { return nil }))
+
+			got := iter.Stats()
+			assert.Equal(t, test.want.Rows, got.Rows)
+			assert.Equal(t, test.want.Tables, got.Tables)
+			assert.Equal(t, test.want.Frames, got.Frames)
+			// The delimiters around the frames are not all counted.
+			assert.InDelta(t, len(test.body), got.Bytes, 4)
+			assert.GreaterOrEqual(t, got.Duration, time.Duration(0))
+
+			// The response ended, so the duration no longer changes.
+			time.Sleep(time.Millisecond)
+			assert.Equal(t, got, iter.Stats())
+		})
+	}
+}
+
+func TestIterStatsStop(t *testing.T) {
+	t.Parallel()
+
+	iter, err := datasetClient(t, datasetNonProgressive).Query(context.Background(), "db", NewStmt("T; T | count"))
+	require.NoError(t, err)
+
+	row, err := iter.Next()
+	require.NoError(t, err)
+	require.NotNil(t, row)
+	iter.Stop()
+
+	got := iter.Stats()
+	assert.EqualValues(t, 1, got.Rows)
+	assert.EqualValues(t, 1, got.Tables)
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, got.Duration, iter.Stats().Duration)
+}
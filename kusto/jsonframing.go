@@ -0,0 +1,36 @@
+package kusto
+
+// jsonframing.go holds the framing options of Client.QueryToJson() and the check of the JSON it returns.
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// JsonFramingProgressive makes QueryToJson() return the progressive v2 framing, where the rows of a table are split
+// in TableHeader, TableFragment and TableProgress frames. This is the framing Query() uses.
+func JsonFramingProgressive() QueryOption {
+	return func(q *queryOptions) error {
+		q.requestProperties.setOption(resultsProgressiveEnabledValue, true)
+		return nil
+	}
+}
+
+// JsonFramingNonProgressive makes QueryToJson() return the non-progressive v2 framing, where each table is a single
+// DataTable frame. This is the default of QueryToJson().
+func JsonFramingNonProgressive() QueryOption {
+	return func(q *queryOptions) error {
+		q.requestProperties.deleteOption(resultsProgressiveEnabledValue)
+		return nil
+	}
+}
+
+// checkJSON returns an error if payload is not a complete JSON document, such as a body cut short when the
+// connection was dropped.
+func checkJSON(payload []byte) error {
+	if json.Valid(payload) {
+		return nil
+	}
+	return errors.ES(errors.OpQuery, errors.KIO, "the response to QueryToJson() is not complete JSON (%d bytes), the connection may have been dropped", len(payload))
+}
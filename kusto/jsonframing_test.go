@@ -0,0 +1,153 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	nonProgressiveJSON = `[{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":0,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[[1],[2]]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	progressiveJSON = `[{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},` +
+		`{"FrameType":"TableHeader","TableId":0,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}]},` +
+		`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":0,"Rows":[[1],[2]]},` +
+		`{"FrameType":"TableCompletion","TableId":0,"RowCount":2},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+)
+
+// framingTransport is a fake http.RoundTripper that answers in the framing asked for by the request. If truncate is
+// set, the body is cut short.
+type framingTransport struct {
+	truncate bool
+}
+
+func (f framingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	body := nonProgressiveJSON
+	if msg.Properties.Options[resultsProgressiveEnabledValue] == true {
+		body = progressiveJSON
+	}
+	if f.truncate {
+		body = body[:len(body)/2]
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestQueryToJsonFraming(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		options []QueryOption
+		// ctxOptions are set with ContextWithQueryOptions(), defaults with WithDefaultQueryOptions().
+		ctxOptions []QueryOption
+		defaults   []QueryOption
+		truncate   bool
+		want       string
+		wantErr    bool
+	}{
+		{
+			desc: "Non-progressive by default",
+			want: nonProgressiveJSON,
+		},
+		{
+			desc:    "Non-progressive",
+			options: []QueryOption{JsonFramingNonProgressive()},
+			want:    nonProgressiveJSON,
+		},
+		{
+			desc:    "Progressive",
+			options: []QueryOption{JsonFramingProgressive()},
+			want:    progressiveJSON,
+		},
+		{
+			desc:    "The last option wins",
+			options: []QueryOption{JsonFramingProgressive(), JsonFramingNonProgressive()},
+			want:    nonProgressiveJSON,
+		},
+		{
+			desc:       "Progressive from the context",
+			ctxOptions: []QueryOption{JsonFramingProgressive()},
+			want:       progressiveJSON,
+		},
+		{
+			desc:     "Progressive from the defaults of the client",
+			defaults: []QueryOption{JsonFramingProgressive()},
+			want:     progressiveJSON,
+		},
+		{
+			desc:       "The options win over the context",
+			ctxOptions: []QueryOption{JsonFramingProgressive()},
+			options:    []QueryOption{JsonFramingNonProgressive()},
+			want:       nonProgressiveJSON,
+		},
+		{
+			desc:     "Truncated body",
+			truncate: true,
+			wantErr:  true,
+		},
+		{
+			desc:     "Truncated progressive body",
+			options:  []QueryOption{JsonFramingProgressive()},
+			truncate: true,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newTestClient(t, "https://json.kusto.windows.net", framingTransport{truncate: test.truncate})
+			client.defaultQueryOptions = test.defaults
+
+			ctx := ContextWithQueryOptions(context.Background(), test.ctxOptions...)
+			got, err := client.QueryToJson(ctx, "db", NewStmt("T"), test.options...)
+			if test.wantErr {
+				assert.Empty(t, got)
+				var kustoErr *errors.Error
+				require.True(t, goErrors.As(err, &kustoErr), "got %T: %v", err, err)
+				assert.Equal(t, errors.KIO, kustoErr.Kind)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestQueryFramingUnchanged(t *testing.T) {
+	t.Parallel()
+
+	transport := &recordTransport{}
+	client := newTestClient(t, "https://json.kusto.windows.net", transport)
+
+	// Query() keeps its progressive default.
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	iter.Stop()
+
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, true, sent[0].Properties.Options[resultsProgressiveEnabledValue])
+}
@@ -1,16 +1,24 @@
 package kusto
 
 import (
+	"context"
+	"crypto"
+	"crypto/x509"
 	"fmt"
 	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"net/http"
+	"os"
 	"strconv"
 	"strings"
 )
 
 type ConnectionStringBuilder struct {
-	DataSource                       string
+	DataSource string
+	// InitialCatalog is the database of the connection string, which calls do not use by default: pass it to
+	// Client.WithDatabase() to make it the database of the calls.
+	InitialCatalog                   string
 	AadUserID                        string
 	Password                         string
 	UserToken                        string
@@ -19,21 +27,43 @@ type ConnectionStringBuilder struct {
 	AuthorityId                      string
 	ApplicationCertificate           string
 	ApplicationCertificateThumbprint string
-	SendCertificateChain             bool
-	ApplicationToken                 string
-	AzCli                            bool
-	MsiAuthentication                bool
-	ManagedServiceIdentity           string
-	InteractiveLogin                 bool
-	RedirectURL                      string
-	DefaultAuth                      bool
-	ClientOptions                    *azcore.ClientOptions
-	ApplicationForTracing            string
-	UserForTracing                   string
+	// ApplicationCertificatePassword is the password of the PFX certificate, see WithAppCertificate(). If it is empty,
+	// ApplicationCertificateThumbprint is used as the password, as in the former versions of this package.
+	ApplicationCertificatePassword string
+	// ApplicationCertificatePath is the path of a PEM or PFX certificate file, read by New() instead of
+	// ApplicationCertificate, see WithAppCertificateFile().
+	ApplicationCertificatePath string
+	SendCertificateChain       bool
+	ApplicationToken           string
+	// OnBehalfOf authenticates as the user of UserAssertion, exchanged with ApplicationClientId and ApplicationKey, see
+	// WithApplicationTokenCredentialOnBehalfOf().
+	OnBehalfOf bool
+	// UserAssertion is the token of the user for OnBehalfOf, "" if it is passed per query with the OnBehalfOf() option.
+	UserAssertion string
+	AzCli         bool
+	// AzureDeveloperCli authenticates as the user logged in with the Azure Developer CLI, see WithAzureDeveloperCli().
+	AzureDeveloperCli      bool
+	MsiAuthentication      bool
+	ManagedServiceIdentity string
+	// ManagedIdentityResourceID is the ARM resource ID of the user-assigned managed identity, see
+	// WithUserAssignedIdentityResourceId(). It cannot be set with ManagedServiceIdentity, which is its client ID.
+	ManagedIdentityResourceID string
+	InteractiveLogin          bool
+	RedirectURL               string
+	DefaultAuth               bool
+	ClientOptions             *azcore.ClientOptions
+	ApplicationForTracing     string
+	UserForTracing            string
+	// TokenCallback returns the token of the requests, see WithTokenCallback().
+	TokenCallback func(ctx context.Context, resource string) (string, error)
+	// AllowInsecureEndpoint accepts a DataSource on plain http on any host, see AllowInsecure().
+	AllowInsecureEndpoint bool
 }
 
 const (
 	dataSource                       string = "DataSource"
+	initialCatalog                   string = "InitialCatalog"
+	federatedSecurity                string = "FederatedSecurity"
 	aadUserId                        string = "AADUserID"
 	password                         string = "Password"
 	applicationClientId              string = "ApplicationClientId"
@@ -43,28 +73,76 @@ const (
 	applicationToken                 string = "ApplicationToken"
 	userToken                        string = "UserToken"
 	applicationCertificateThumbprint string = "ApplicationCertificateThumbprint"
+	applicationCertificatePath       string = "ApplicationCertificatePath"
 	sendCertificateChain             string = "SendCertificateChain"
 	interactiveLogin                 string = "InteractiveLogin"
 	domainHint                       string = "RedirectURL"
+	managedIdentity                  string = "MsiAuthentication"
+	managedIdentityClientId          string = "ManagedServiceIdentity"
+	managedIdentityResourceId        string = "ManagedIdentityResourceID"
+	applicationForTracing            string = "ApplicationForTracing"
+	userForTracing                   string = "UserForTracing"
 )
 
 const (
 	BEARER_TYPE = "Bearer"
 )
 
-var csMapping = map[string]string{"datasource": dataSource, "data source": dataSource, "addr": dataSource, "address": dataSource, "network address": dataSource, "server": dataSource,
-	"aad user id": aadUserId, "aaduserid": aadUserId,
-	"password": password, "pwd": password,
-	"application client id": applicationClientId, "applicationclientid": applicationClientId, "appclientid": applicationClientId,
-	"application key": applicationKey, "applicationkey": applicationKey, "appkey": applicationKey,
-	"application certificate": applicationCertificate, "applicationcertificate": applicationCertificate,
-	"application certificate thumbprint": applicationCertificateThumbprint, "applicationcertificatethumbprint": applicationCertificateThumbprint,
-	"sendcertificatechain": sendCertificateChain, "send certificate chain": sendCertificateChain,
-	"authority id": authorityId, "authorityid": authorityId, "authority": authorityId, "tenantid": authorityId, "tenant": authorityId, "tid": authorityId,
-	"application token": applicationToken, "applicationtoken": applicationToken, "apptoken": applicationToken,
-	"user token": userToken, "usertoken": userToken, "usrtoken": userToken,
-	"interactive login": interactiveLogin, "interactivelogin": interactiveLogin,
-	"domain hint": domainHint, "domainhint": domainHint,
+// csKeyword is a keyword of connection strings, see
+// https://learn.microsoft.com/azure/data-explorer/kusto/api/connection-strings/kusto
+type csKeyword struct {
+	key string
+	// name is the canonical form of the keyword, which ConnectionString() writes.
+	name string
+	// aliases are the other forms of the keyword, in lower case.
+	aliases []string
+	// secret indicates that the value is hidden by ConnectionString(false).
+	secret bool
+}
+
+// csKeywords are the keywords of connection strings, in the order ConnectionString() writes them.
+var csKeywords = []csKeyword{
+	{key: dataSource, name: "Data Source", aliases: []string{"datasource", "addr", "address", "network address", "server", "cluster"}},
+	{key: initialCatalog, name: "Initial Catalog", aliases: []string{"initialcatalog", "database"}},
+	{key: federatedSecurity, name: "AAD Federated Security", aliases: []string{"aadfederatedsecurity", "federated security", "federatedsecurity", "fed"}},
+	{key: aadUserId, name: "AAD User ID", aliases: []string{"aaduserid", "user id", "userid", "uid"}},
+	{key: password, name: "Password", aliases: []string{"pwd"}, secret: true},
+	{key: applicationClientId, name: "Application Client Id", aliases: []string{"applicationclientid", "appclientid"}},
+	{key: applicationKey, name: "Application Key", aliases: []string{"applicationkey", "appkey"}, secret: true},
+	{key: applicationCertificate, name: "Application Certificate", aliases: []string{"applicationcertificate"}, secret: true},
+	{key: applicationCertificateThumbprint, name: "Application Certificate Thumbprint", aliases: []string{"applicationcertificatethumbprint", "appcert"}},
+	{key: sendCertificateChain, name: "Application Certificate Send Public Certificate", aliases: []string{"applicationcertificatesendpubliccertificate", "sendx5c", "send certificate chain", "sendcertificatechain"}},
+	{key: authorityId, name: "Authority Id", aliases: []string{"authorityid", "authority", "tenantid", "tenant", "tid"}},
+	{key: applicationToken, name: "Application Token", aliases: []string{"applicationtoken", "apptoken"}, secret: true},
+	{key: userToken, name: "User Token", aliases: []string{"usertoken", "usrtoken"}, secret: true},
+	{key: managedIdentity, name: "Managed Identity", aliases: []string{"managedidentity", "msi"}},
+	{key: managedIdentityClientId, name: "Managed Identity Client Id", aliases: []string{"managedidentityclientid", "msi client id", "msiclientid"}},
+	{key: managedIdentityResourceId, name: "Managed Identity Resource Id", aliases: []string{"managedidentityresourceid", "msi resource id", "msiresourceid"}},
+	{key: interactiveLogin, name: "Interactive Login", aliases: []string{"interactivelogin"}},
+	{key: domainHint, name: "Domain Hint", aliases: []string{"domainhint"}},
+	{key: applicationForTracing, name: "Application Name for Tracing", aliases: []string{"applicationnamefortracing", "traceappname"}},
+	{key: userForTracing, name: "User Name for Tracing", aliases: []string{"usernamefortracing", "traceusername"}},
+}
+
+// csMapping maps the keywords of csKeywords and their aliases, in lower case, to their key.
+var csMapping = func() map[string]string {
+	m := map[string]string{}
+	for _, k := range csKeywords {
+		m[strings.ToLower(k.name)] = k.key
+		for _, a := range k.aliases {
+			m[a] = k.key
+		}
+	}
+	return m
+}()
+
+// csKeywordNames returns the canonical keywords of connection strings, for error messages.
+func csKeywordNames() string {
+	names := make([]string, 0, len(csKeywords))
+	for _, k := range csKeywords {
+		names = append(names, strconv.Quote(k.name))
+	}
+	return strings.Join(names, ", ")
 }
 
 func requireNonEmpty(key string, value string) {
@@ -77,11 +155,29 @@ func assignValue(kcsb *ConnectionStringBuilder, rawKey string, value string) err
 	rawKey = strings.ToLower(strings.Trim(rawKey, " "))
 	parsedKey, ok := csMapping[rawKey]
 	if !ok {
-		return fmt.Errorf("Error: unsupported key %q in connection string ", rawKey)
+		return fmt.Errorf("Error: unsupported key %q in connection string, the supported keys are %s (or their aliases)", rawKey, csKeywordNames())
+	}
+	parseBool := func() (bool, error) {
+		bval, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("Error: key %q in connection string must be true or false, not %q", rawKey, value)
+		}
+		return bval, nil
 	}
+
 	switch parsedKey {
 	case dataSource:
 		kcsb.DataSource = value
+	case initialCatalog:
+		kcsb.InitialCatalog = value
+	case federatedSecurity:
+		bval, err := parseBool()
+		if err != nil {
+			return err
+		}
+		if !bval {
+			return fmt.Errorf("Error: key %q in connection string cannot be false, as the client always authenticates with AAD", rawKey)
+		}
 	case aadUserId:
 		kcsb.AadUserID = value
 	case password:
@@ -95,7 +191,10 @@ func assignValue(kcsb *ConnectionStringBuilder, rawKey string, value string) err
 	case applicationCertificateThumbprint:
 		kcsb.ApplicationCertificateThumbprint = value
 	case sendCertificateChain:
-		bval, _ := strconv.ParseBool(value)
+		bval, err := parseBool()
+		if err != nil {
+			return err
+		}
 		kcsb.SendCertificateChain = bval
 	case authorityId:
 		kcsb.AuthorityId = value
@@ -104,25 +203,113 @@ func assignValue(kcsb *ConnectionStringBuilder, rawKey string, value string) err
 	case userToken:
 		kcsb.UserToken = value
 	case interactiveLogin:
-		bval, _ := strconv.ParseBool(value)
+		bval, err := parseBool()
+		if err != nil {
+			return err
+		}
 		kcsb.InteractiveLogin = bval
+	case managedIdentity:
+		bval, err := parseBool()
+		if err != nil {
+			return err
+		}
+		kcsb.MsiAuthentication = bval
+	case managedIdentityClientId:
+		kcsb.MsiAuthentication = true
+		kcsb.ManagedServiceIdentity = value
+	case managedIdentityResourceId:
+		kcsb.MsiAuthentication = true
+		kcsb.ManagedIdentityResourceID = value
 	case domainHint:
 		kcsb.RedirectURL = value
+	case applicationForTracing:
+		kcsb.ApplicationForTracing = value
+	case userForTracing:
+		kcsb.UserForTracing = value
 	}
 	return nil
 }
 
+// value returns the value of key in the connection string, or "" if it is not set.
+func (kcsb *ConnectionStringBuilder) value(key string) string {
+	formatBool := func(b bool) string {
+		if b {
+			return "True"
+		}
+		return ""
+	}
+
+	switch key {
+	case dataSource:
+		return kcsb.DataSource
+	case initialCatalog:
+		return kcsb.InitialCatalog
+	case aadUserId:
+		return kcsb.AadUserID
+	case password:
+		return kcsb.Password
+	case applicationClientId:
+		return kcsb.ApplicationClientId
+	case applicationKey:
+		return kcsb.ApplicationKey
+	case applicationCertificate:
+		return kcsb.ApplicationCertificate
+	case applicationCertificateThumbprint:
+		return kcsb.ApplicationCertificateThumbprint
+	case sendCertificateChain:
+		return formatBool(kcsb.SendCertificateChain)
+	case authorityId:
+		return kcsb.AuthorityId
+	case applicationToken:
+		return kcsb.ApplicationToken
+	case userToken:
+		return kcsb.UserToken
+	case interactiveLogin:
+		return formatBool(kcsb.InteractiveLogin)
+	case managedIdentity:
+		return formatBool(kcsb.MsiAuthentication)
+	case managedIdentityClientId:
+		return kcsb.ManagedServiceIdentity
+	case managedIdentityResourceId:
+		return kcsb.ManagedIdentityResourceID
+	case domainHint:
+		return kcsb.RedirectURL
+	case applicationForTracing:
+		return kcsb.ApplicationForTracing
+	case userForTracing:
+		return kcsb.UserForTracing
+	}
+	return ""
+}
+
 // NewConnectionStringBuilder Creates new Kusto ConnectionStringBuilder.
 // Params takes kusto connection string connStr: string.  Kusto connection string should be of the format:
 // https://<clusterName>.kusto.windows.net;AAD User ID="user@microsoft.com";Password=P@ssWord
 // For more information please look at:
 // https://docs.microsoft.com/azure/data-explorer/kusto/api/connection-strings/kusto
+// It panics if the connection string is not valid, see ParseConnectionString() for a version that returns an error.
 func NewConnectionStringBuilder(connStr string) *ConnectionStringBuilder {
-	kcsb := ConnectionStringBuilder{}
 	if isEmpty(connStr) {
 		panic("error: Connection string cannot be empty")
 	}
-	connStrArr := strings.Split(connStr, ";")
+	kcsb, err := ParseConnectionString(connStr)
+	if err != nil {
+		panic(err)
+	}
+	return kcsb
+}
+
+// ParseConnectionString parses a connection string in the semicolon-delimited form of the other Kusto SDKs, such as
+// "Data Source=https://<clusterName>.kusto.windows.net;AAD Federated Security=True;Application Client Id=...;
+// Application Key=...". The keywords are case-insensitive and have aliases, such as "Addr" for "Data Source" or "Fed"
+// for "AAD Federated Security", and values may be quoted. The first element may be the data source alone. An error is
+// returned for an unknown keyword, listing the supported ones.
+func ParseConnectionString(connStr string) (*ConnectionStringBuilder, error) {
+	kcsb := ConnectionStringBuilder{}
+	if isEmpty(connStr) {
+		return nil, kustoErrors.ES(kustoErrors.OpServConn, kustoErrors.KClientArgs, "connection string cannot be empty").SetNoRetry()
+	}
+	connStrArr := splitConnectionString(connStr)
 	if !strings.Contains(connStrArr[0], "=") {
 		connStrArr[0] = "Data Source=" + connStrArr[0]
 	}
@@ -131,17 +318,103 @@ func NewConnectionStringBuilder(connStr string) *ConnectionStringBuilder {
 		if isEmpty(strings.Trim(kvp, " ")) {
 			continue
 		}
-		kvparr := strings.Split(kvp, "=")
-		val := strings.Trim(kvparr[1], " ")
+		// Values such as application keys may hold a '='.
+		kvparr := strings.SplitN(kvp, "=", 2)
+		if len(kvparr) != 2 {
+			return nil, kustoErrors.ES(kustoErrors.OpServConn, kustoErrors.KClientArgs, "element %q of the connection string is not of the form keyword=value", strings.TrimSpace(kvp)).SetNoRetry()
+		}
+		val := unquote(strings.Trim(kvparr[1], " "))
 		if isEmpty(val) {
 			continue
 		}
 		if err := assignValue(&kcsb, kvparr[0], val); err != nil {
-			panic(err)
+			return nil, kustoErrors.E(kustoErrors.OpServConn, kustoErrors.KClientArgs, err).SetNoRetry()
 		}
 	}
 
-	return &kcsb
+	return &kcsb, nil
+}
+
+// splitConnectionString splits connStr into its keyword=value elements. A ';' in a value that is quoted, with a double
+// or a single quote right after the '=', does not end the element. In a quoted value, the quote is escaped by
+// doubling it.
+func splitConnectionString(connStr string) []string {
+	var (
+		elements []string
+		start    int
+		quote    byte
+		// inValue is true after the '=' of the element, valueStart until the first character of the value that is
+		// not a space.
+		inValue, valueStart bool
+	)
+	for i := 0; i < len(connStr); i++ {
+		c := connStr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				if i+1 < len(connStr) && connStr[i+1] == quote {
+					i++
+					continue
+				}
+				quote = 0
+			}
+		case c == ';':
+			elements = append(elements, connStr[start:i])
+			start = i + 1
+			inValue, valueStart = false, false
+		case !inValue && c == '=':
+			inValue, valueStart = true, true
+		case valueStart && c == ' ':
+		case valueStart && (c == '"' || c == '\''):
+			quote = c
+			valueStart = false
+		default:
+			valueStart = false
+		}
+	}
+	return append(elements, connStr[start:])
+}
+
+// unquote removes the quotes around a value of a connection string, if any, and unescapes the doubled quotes in it.
+func unquote(val string) string {
+	if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0] {
+		q := val[:1]
+		return strings.ReplaceAll(val[1:len(val)-1], q+q, q)
+	}
+	return val
+}
+
+// quoteValue quotes a value of a connection string if it would not be parsed back as is: if it holds a ';' or a '=', starts
+// with a quote, or starts or ends with a space.
+func quoteValue(val string) string {
+	if !strings.ContainsAny(val, ";=") && val[0] != '"' && val[0] != '\'' && strings.TrimSpace(val) == val {
+		return val
+	}
+	return `"` + strings.ReplaceAll(val, `"`, `""`) + `"`
+}
+
+// ConnectionString returns the connection string of the builder, with the canonical keywords, which
+// ParseConnectionString() parses back into the same builder. If includeSecrets is false, the values of the secrets,
+// such as passwords and application keys, are replaced by "****", so that the result can be logged.
+// The authentication methods that have no keyword, such as WithAzCli(), are not written.
+func (kcsb *ConnectionStringBuilder) ConnectionString(includeSecrets bool) string {
+	var b strings.Builder
+	for _, k := range csKeywords {
+		v := kcsb.value(k.key)
+		if v == "" {
+			continue
+		}
+		if k.secret && !includeSecrets {
+			v = "****"
+		}
+		if b.Len() > 0 {
+			b.WriteByte(';')
+		}
+		b.WriteString(k.name)
+		b.WriteByte('=')
+		b.WriteString(quoteValue(v))
+	}
+	return b.String()
 }
 
 func (kcsb *ConnectionStringBuilder) resetConnectionString() {
@@ -153,15 +426,22 @@ func (kcsb *ConnectionStringBuilder) resetConnectionString() {
 	kcsb.AuthorityId = ""
 	kcsb.ApplicationCertificate = ""
 	kcsb.ApplicationCertificateThumbprint = ""
+	kcsb.ApplicationCertificatePassword = ""
+	kcsb.ApplicationCertificatePath = ""
 	kcsb.SendCertificateChain = false
 	kcsb.ApplicationToken = ""
+	kcsb.OnBehalfOf = false
+	kcsb.UserAssertion = ""
 	kcsb.AzCli = false
+	kcsb.AzureDeveloperCli = false
 	kcsb.MsiAuthentication = false
 	kcsb.ManagedServiceIdentity = ""
+	kcsb.ManagedIdentityResourceID = ""
 	kcsb.InteractiveLogin = false
 	kcsb.RedirectURL = ""
 	kcsb.ClientOptions = nil
 	kcsb.DefaultAuth = false
+	kcsb.TokenCallback = nil
 }
 
 // WithAadUserPassAuth Creates a Kusto Connection string builder that will authenticate with AAD user name and password.
@@ -199,20 +479,64 @@ func (kcsb *ConnectionStringBuilder) WithAadAppKey(appId string, appKey string,
 }
 
 // WithAppCertificate Creates a Kusto Connection string builder that will authenticate with AAD application using a certificate.
-func (kcsb *ConnectionStringBuilder) WithAppCertificate(appId string, certificate string, thumprint string, sendCertChain bool, authorityID string) *ConnectionStringBuilder {
+// certificate holds the certificate and its private key, PEM or PFX encoded, and password is the password of the PFX, if any.
+// If sendCertChain is true, the x5c header is sent with the certificate chain, as needed for subject name/issuer (SNI)
+// authentication, which allows rotating the certificate without updating the application.
+// The certificate is parsed by New(), which returns an error if it is not valid.
+func (kcsb *ConnectionStringBuilder) WithAppCertificate(appId string, certificate []byte, password string, sendCertChain bool, authorityID string) *ConnectionStringBuilder {
 	requireNonEmpty(dataSource, kcsb.DataSource)
-	requireNonEmpty(applicationCertificate, certificate)
+	requireNonEmpty(applicationCertificate, string(certificate))
 	requireNonEmpty(authorityId, authorityID)
 	kcsb.resetConnectionString()
 	kcsb.ApplicationClientId = appId
 	kcsb.AuthorityId = authorityID
 
-	kcsb.ApplicationCertificate = certificate
-	kcsb.ApplicationCertificateThumbprint = thumprint
+	kcsb.ApplicationCertificate = string(certificate)
+	kcsb.ApplicationCertificatePassword = password
 	kcsb.SendCertificateChain = sendCertChain
 	return kcsb
 }
 
+// WithAppCertificateFile is like WithAppCertificate(), with the certificate read from the PEM or PFX file at path.
+// The file is read by New(), which returns an error if it cannot be read or the certificate is not valid.
+func (kcsb *ConnectionStringBuilder) WithAppCertificateFile(appId string, path string, password string, sendCertChain bool, authorityID string) *ConnectionStringBuilder {
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	requireNonEmpty(applicationCertificatePath, path)
+	requireNonEmpty(authorityId, authorityID)
+	kcsb.resetConnectionString()
+	kcsb.ApplicationClientId = appId
+	kcsb.AuthorityId = authorityID
+
+	kcsb.ApplicationCertificatePath = path
+	kcsb.ApplicationCertificatePassword = password
+	kcsb.SendCertificateChain = sendCertChain
+	return kcsb
+}
+
+// WithBearerToken Creates a Kusto Connection string builder that will authenticate with a token acquired beforehand,
+// for example by a token broker, which is sent as is until the builder is changed. See WithTokenCallback() for tokens
+// that expire.
+func (kcsb *ConnectionStringBuilder) WithBearerToken(token string) *ConnectionStringBuilder {
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	requireNonEmpty(userToken, token)
+	kcsb.resetConnectionString()
+	kcsb.UserToken = token
+	return kcsb
+}
+
+// WithTokenCallback Creates a Kusto Connection string builder that will authenticate with the tokens returned by
+// callback, which receives the resource URI of the cluster, such as "https://help.kusto.windows.net". It is called for
+// every request, so it should cache the tokens, as a token broker does. An error of callback fails the request.
+func (kcsb *ConnectionStringBuilder) WithTokenCallback(callback func(ctx context.Context, resource string) (string, error)) *ConnectionStringBuilder {
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	if callback == nil {
+		panic("Error: TokenCallback cannot be null")
+	}
+	kcsb.resetConnectionString()
+	kcsb.TokenCallback = callback
+	return kcsb
+}
+
 // WithApplicationToken Creates a Kusto Connection string builder that will authenticate with AAD application and an application token.
 func (kcsb *ConnectionStringBuilder) WithApplicationToken(appId string, appToken string) *ConnectionStringBuilder {
 	requireNonEmpty(dataSource, kcsb.DataSource)
@@ -222,14 +546,52 @@ func (kcsb *ConnectionStringBuilder) WithApplicationToken(appId string, appToken
 	return kcsb
 }
 
+// WithApplicationTokenCredentialOnBehalfOf Creates a Kusto Connection string builder that will authenticate as a user,
+// with the OAuth on-behalf-of flow: the AAD application of appId and appKey exchanges userAssertion, the token of the user
+// received by a web API, for a token of the cluster. If userAssertion is empty, it must be passed to every query with the
+// OnBehalfOf() option, and to every management command with MgmtOnBehalfOf(), so that one Client serves many users.
+// The tokens are cached per user assertion.
+// The tenant of the exchange is the AuthorityId of the builder, or "organizations" if it is empty.
+func (kcsb *ConnectionStringBuilder) WithApplicationTokenCredentialOnBehalfOf(appId string, appKey string, userAssertion string) *ConnectionStringBuilder {
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	requireNonEmpty(applicationClientId, appId)
+	requireNonEmpty(applicationKey, appKey)
+	authorityID := kcsb.AuthorityId
+	kcsb.resetConnectionString()
+	kcsb.ApplicationClientId = appId
+	kcsb.ApplicationKey = appKey
+	kcsb.AuthorityId = authorityID
+	kcsb.OnBehalfOf = true
+	kcsb.UserAssertion = userAssertion
+	return kcsb
+}
+
 // WithAzCli Creates a Kusto Connection string builder that will use existing authenticated az cli profile password.
-func (kcsb *ConnectionStringBuilder) WithAzCli() *ConnectionStringBuilder {
+// The user must have run "az login", which makes it a fit for local development, including in containers where no
+// browser can be opened for WithInteractiveLogin(). If endpoint is not empty, it replaces the DataSource of the builder.
+func (kcsb *ConnectionStringBuilder) WithAzCli(endpoint string) *ConnectionStringBuilder {
+	if !isEmpty(endpoint) {
+		kcsb.DataSource = endpoint
+	}
 	requireNonEmpty(dataSource, kcsb.DataSource)
 	kcsb.resetConnectionString()
 	kcsb.AzCli = true
 	return kcsb
 }
 
+// WithAzureDeveloperCli Creates a Kusto Connection string builder that will use the account logged in with the Azure
+// Developer CLI, by running "azd auth token". The user must have run "azd auth login". If endpoint is not empty, it
+// replaces the DataSource of the builder.
+func (kcsb *ConnectionStringBuilder) WithAzureDeveloperCli(endpoint string) *ConnectionStringBuilder {
+	if !isEmpty(endpoint) {
+		kcsb.DataSource = endpoint
+	}
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	kcsb.resetConnectionString()
+	kcsb.AzureDeveloperCli = true
+	return kcsb
+}
+
 // WithUserManagedIdentity Creates a Kusto Connection string builder that will authenticate with AAD application, using
 // an application token obtained from a Microsoft Service Identity endpoint using user assigned id.
 func (kcsb *ConnectionStringBuilder) WithUserManagedIdentity(clientID string) *ConnectionStringBuilder {
@@ -240,6 +602,24 @@ func (kcsb *ConnectionStringBuilder) WithUserManagedIdentity(clientID string) *C
 	return kcsb
 }
 
+// WithUserAssignedIdentityResourceId Creates a Kusto Connection string builder that will authenticate with AAD application,
+// using an application token obtained from a Microsoft Service Identity endpoint using the ARM resource ID of a user
+// assigned identity, of the form /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.ManagedIdentity/
+// userAssignedIdentities/<name>. If endpoint is not empty, it replaces the DataSource of the builder.
+// Note that some hosting environments don't accept resource IDs, in which case use WithUserManagedIdentity() with the
+// client ID of the identity. Selecting the identity by object ID is not supported.
+func (kcsb *ConnectionStringBuilder) WithUserAssignedIdentityResourceId(endpoint string, resourceID string) *ConnectionStringBuilder {
+	if !isEmpty(endpoint) {
+		kcsb.DataSource = endpoint
+	}
+	requireNonEmpty(dataSource, kcsb.DataSource)
+	requireNonEmpty(managedIdentityResourceId, resourceID)
+	kcsb.resetConnectionString()
+	kcsb.MsiAuthentication = true
+	kcsb.ManagedIdentityResourceID = resourceID
+	return kcsb
+}
+
 // WithSystemManagedIdentity Creates a Kusto Connection string builder that will authenticate with AAD application, using
 // an application token obtained from a Microsoft Service Identity endpoint using system assigned id.
 func (kcsb *ConnectionStringBuilder) WithSystemManagedIdentity() *ConnectionStringBuilder {
@@ -261,6 +641,14 @@ func (kcsb *ConnectionStringBuilder) WithInteractiveLogin(authorityID string) *C
 	return kcsb
 }
 
+// AllowInsecure sets whether the DataSource may be a plain http endpoint on any host, such as the Kusto emulator
+// (Kustainer) on another machine. Plain http endpoints on the local host, such as http://localhost:8080, are always
+// accepted. These endpoints are not checked against the trusted endpoints, and the tokens are sent to them unencrypted.
+func (kcsb *ConnectionStringBuilder) AllowInsecure(allow bool) *ConnectionStringBuilder {
+	kcsb.AllowInsecureEndpoint = allow
+	return kcsb
+}
+
 // AttachPolicyClientOptions Assigns ClientOptions to string builder that contains configuration settings like Logging and Retry configs for a client's pipeline.
 // Read more at https://pkg.go.dev/github.com/Azure/azure-sdk-for-go/sdk/azcore@v1.2.0/policy#ClientOptions
 func (kcsb *ConnectionStringBuilder) AttachPolicyClientOptions(options *azcore.ClientOptions) *ConnectionStringBuilder {
@@ -272,6 +660,7 @@ func (kcsb *ConnectionStringBuilder) AttachPolicyClientOptions(options *azcore.C
 }
 
 // WithDefaultAzureCredential Create Kusto Conntection String that will be used for default auth mode. The order of auth will be via environment variables, managed identity and Azure CLI .
+// It must be opted in explicitly: a builder without any authentication sends no token.
 // Read more at https://learn.microsoft.com/azure/developer/go/azure-sdk-authentication?tabs=bash#2-authenticate-with-azure
 func (kcsb *ConnectionStringBuilder) WithDefaultAzureCredential() *ConnectionStringBuilder {
 	kcsb.resetConnectionString()
@@ -279,6 +668,42 @@ func (kcsb *ConnectionStringBuilder) WithDefaultAzureCredential() *ConnectionStr
 	return kcsb
 }
 
+// managedIdentityID returns the ID of the user assigned managed identity, or nil for the system assigned one.
+func (kcsb *ConnectionStringBuilder) managedIdentityID() azidentity.ManagedIDKind {
+	switch {
+	case !isEmpty(kcsb.ManagedServiceIdentity):
+		return azidentity.ClientID(kcsb.ManagedServiceIdentity)
+	case !isEmpty(kcsb.ManagedIdentityResourceID):
+		return azidentity.ResourceID(kcsb.ManagedIdentityResourceID)
+	}
+	return nil
+}
+
+// parseCertificate returns the certificate chain and the private key of ApplicationCertificate, or of the file at
+// ApplicationCertificatePath. The errors are not retried, as the certificate will not become valid.
+func (kcsb *ConnectionStringBuilder) parseCertificate() ([]*x509.Certificate, crypto.PrivateKey, error) {
+	data := []byte(kcsb.ApplicationCertificate)
+	if !isEmpty(kcsb.ApplicationCertificatePath) {
+		var err error
+		data, err = os.ReadFile(kcsb.ApplicationCertificatePath)
+		if err != nil {
+			return nil, nil, kustoErrors.ES(kustoErrors.OpTokenProvider, kustoErrors.KClientArgs,
+				"could not read the application certificate: %s", err).SetNoRetry()
+		}
+	}
+	password := kcsb.ApplicationCertificatePassword
+	if isEmpty(password) {
+		password = kcsb.ApplicationCertificateThumbprint
+	}
+
+	cert, key, err := azidentity.ParseCertificates(data, []byte(password))
+	if err != nil {
+		return nil, nil, kustoErrors.ES(kustoErrors.OpTokenProvider, kustoErrors.KClientArgs,
+			"could not parse the application certificate: %s", err).SetNoRetry()
+	}
+	return cert, key, nil
+}
+
 // Method to be used for generating TokenCredential
 func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error) {
 	tkp := &TokenProvider{}
@@ -286,6 +711,11 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 
 	var init func(*CloudInfo, *azcore.ClientOptions, string) (azcore.TokenCredential, error)
 
+	if !isEmpty(kcsb.ManagedServiceIdentity) && !isEmpty(kcsb.ManagedIdentityResourceID) {
+		return nil, kustoErrors.ES(kustoErrors.OpTokenProvider, kustoErrors.KClientArgs,
+			"the managed identity can be selected by its client ID or by its resource ID, not both").SetNoRetry()
+	}
+
 	switch {
 	case kcsb.InteractiveLogin:
 		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
@@ -317,6 +747,11 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 
 			return cred, nil
 		}
+	case kcsb.OnBehalfOf:
+		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
+			return newOnBehalfOfCredential(ci.LoginEndpoint, kcsb.AuthorityId, kcsb.ApplicationClientId, kcsb.ApplicationKey,
+				kcsb.UserAssertion, func() *http.Client { return tkp.http.Load().(*http.Client) }), nil
+		}
 	case !isEmpty(kcsb.ApplicationClientId) && !isEmpty(kcsb.ApplicationKey):
 		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
 			authorityId := kcsb.AuthorityId
@@ -336,16 +771,16 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 
 			return cred, nil
 		}
-	case !isEmpty(kcsb.ApplicationCertificate):
+	case !isEmpty(kcsb.ApplicationCertificate) || !isEmpty(kcsb.ApplicationCertificatePath):
+		cert, key, err := kcsb.parseCertificate()
+		if err != nil {
+			return nil, err
+		}
 		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
 			opts := &azidentity.ClientCertificateCredentialOptions{ClientOptions: *cliOpts}
 			opts.SendCertificateChain = kcsb.SendCertificateChain
 
-			cert, thumprintKey, err := azidentity.ParseCertificates([]byte(kcsb.ApplicationCertificate), []byte(kcsb.ApplicationCertificateThumbprint))
-			if err != nil {
-				return nil, kustoErrors.E(kustoErrors.OpTokenProvider, kustoErrors.KOther, err)
-			}
-			cred, err := azidentity.NewClientCertificateCredential(kcsb.AuthorityId, appClientId, cert, thumprintKey, opts)
+			cred, err := azidentity.NewClientCertificateCredential(kcsb.AuthorityId, appClientId, cert, key, opts)
 
 			if err != nil {
 				return nil, kustoErrors.E(kustoErrors.OpTokenProvider, kustoErrors.KOther,
@@ -357,9 +792,7 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 	case kcsb.MsiAuthentication:
 		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
 			opts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: *cliOpts}
-			if !isEmpty(kcsb.ManagedServiceIdentity) {
-				opts.ID = azidentity.ClientID(kcsb.ManagedServiceIdentity)
-			}
+			opts.ID = kcsb.managedIdentityID()
 
 			cred, err := azidentity.NewManagedIdentityCredential(opts)
 
@@ -370,6 +803,10 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 
 			return cred, nil
 		}
+	case kcsb.TokenCallback != nil:
+		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
+			return tokenCallbackCredential(kcsb.TokenCallback), nil
+		}
 	case !isEmpty(kcsb.UserToken):
 		{
 			tkp.customToken = kcsb.UserToken
@@ -397,6 +834,10 @@ func (kcsb *ConnectionStringBuilder) newTokenProvider() (*TokenProvider, error)
 
 			return cred, nil
 		}
+	case kcsb.AzureDeveloperCli:
+		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
+			return newAzureDeveloperCLICredential(kcsb.AuthorityId), nil
+		}
 	case kcsb.DefaultAuth:
 		init = func(ci *CloudInfo, cliOpts *azcore.ClientOptions, appClientId string) (azcore.TokenCredential, error) {
 			//Default Azure authentication
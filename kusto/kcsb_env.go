@@ -0,0 +1,124 @@
+package kusto
+
+// kcsb_env.go implements NewConnectionStringBuilderFromEnv(), which configures a ConnectionStringBuilder from
+// environment variables only.
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by NewConnectionStringBuilderFromEnv() when it is
+// passed an empty prefix.
+const DefaultEnvPrefix = "KUSTO"
+
+// The names of the environment variables read by NewConnectionStringBuilderFromEnv(), after the prefix and "_".
+const (
+	envEndpoint                = "ENDPOINT"
+	envDatabase                = "DATABASE"
+	envClientID                = "CLIENT_ID"
+	envClientSecret            = "CLIENT_SECRET"
+	envTenantID                = "TENANT_ID"
+	envManagedIdentityClientID = "MANAGED_IDENTITY_CLIENT_ID"
+	envInteractive             = "INTERACTIVE"
+)
+
+// NewConnectionStringBuilderFromEnv returns a ConnectionStringBuilder configured by the environment variables whose
+// names start with prefix and "_", such as KUSTO_ENDPOINT for the prefix "KUSTO", which is the default when prefix is
+// empty. The variables are:
+//
+//	<prefix>_ENDPOINT                    the DataSource, which is required.
+//	<prefix>_DATABASE                    the InitialCatalog.
+//	<prefix>_CLIENT_ID                   with <prefix>_CLIENT_SECRET and <prefix>_TENANT_ID, authenticates as an
+//	<prefix>_CLIENT_SECRET               application, see WithAadAppKey().
+//	<prefix>_TENANT_ID                   the tenant of the application, of the interactive login, or of the
+//	                                     DefaultAzureCredential.
+//	<prefix>_MANAGED_IDENTITY_CLIENT_ID  authenticates as the user-assigned managed identity with this client ID, see
+//	                                     WithUserManagedIdentity().
+//	<prefix>_INTERACTIVE                 a boolean, such as "true", to authenticate with an interactive login in a
+//	                                     browser, see WithInteractiveLogin().
+//
+// An application, a managed identity and an interactive login are mutually exclusive. When none of them is set, the
+// DefaultAzureCredential is used, see WithDefaultAzureCredential(). An error names the variables that conflict or are
+// missing.
+func NewConnectionStringBuilderFromEnv(prefix string) (*ConnectionStringBuilder, error) {
+	return connectionStringBuilderFromEnv(prefix, os.LookupEnv)
+}
+
+// connectionStringBuilderFromEnv is NewConnectionStringBuilderFromEnv() with the environment variables of lookup.
+func connectionStringBuilderFromEnv(prefix string, lookup func(key string) (string, bool)) (*ConnectionStringBuilder, error) {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	name := func(key string) string {
+		return prefix + "_" + key
+	}
+	get := func(key string) string {
+		v, _ := lookup(name(key))
+		return strings.TrimSpace(v)
+	}
+	envErr := func(format string, args ...interface{}) error {
+		return kustoErrors.ES(kustoErrors.OpServConn, kustoErrors.KClientArgs, format, args...).SetNoRetry()
+	}
+
+	endpoint := get(envEndpoint)
+	if endpoint == "" {
+		return nil, envErr("the environment variable %s is missing", name(envEndpoint))
+	}
+	clientID, clientSecret, tenantID := get(envClientID), get(envClientSecret), get(envTenantID)
+	managedIdentity := get(envManagedIdentityClientID)
+	interactive := false
+	if v := get(envInteractive); v != "" {
+		var err error
+		if interactive, err = strconv.ParseBool(v); err != nil {
+			return nil, envErr("the environment variable %s=%q is not a boolean", name(envInteractive), v)
+		}
+	}
+
+	// The application is selected by any of its variables, so that a missing one is reported rather than ignored.
+	var modes []string
+	if clientID != "" || clientSecret != "" {
+		modes = append(modes, name(envClientID)+"/"+name(envClientSecret))
+	}
+	if managedIdentity != "" {
+		modes = append(modes, name(envManagedIdentityClientID))
+	}
+	if interactive {
+		modes = append(modes, name(envInteractive))
+	}
+	if len(modes) > 1 {
+		return nil, envErr("the environment variables %s cannot be set together, they select different authentications",
+			strings.Join(modes, " and "))
+	}
+
+	kcsb := &ConnectionStringBuilder{DataSource: endpoint, InitialCatalog: get(envDatabase)}
+	switch {
+	case clientID != "" || clientSecret != "":
+		var missing []string
+		for _, key := range []string{envClientID, envClientSecret, envTenantID} {
+			if get(key) == "" {
+				missing = append(missing, name(key))
+			}
+		}
+		if len(missing) > 0 {
+			return nil, envErr("the environment variables %s, %s and %s are all needed for an application, missing: %s",
+				name(envClientID), name(envClientSecret), name(envTenantID), strings.Join(missing, ", "))
+		}
+		kcsb.WithAadAppKey(clientID, clientSecret, tenantID)
+	case managedIdentity != "":
+		if tenantID != "" {
+			return nil, envErr("the environment variables %s and %s cannot be set together, a managed identity has no tenant",
+				name(envTenantID), name(envManagedIdentityClientID))
+		}
+		kcsb.WithUserManagedIdentity(managedIdentity)
+	case interactive:
+		kcsb.WithInteractiveLogin(tenantID)
+	default:
+		kcsb.WithDefaultAzureCredential()
+		kcsb.AuthorityId = tenantID
+	}
+	return kcsb, nil
+}
@@ -0,0 +1,117 @@
+package kusto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionStringBuilderFromEnv(t *testing.T) {
+	t.Parallel()
+
+	const endpoint = "https://env.kusto.windows.net"
+
+	tests := []struct {
+		desc   string
+		prefix string
+		env    map[string]string
+		want   *ConnectionStringBuilder
+		// errVars are the variables the error must name.
+		errVars []string
+	}{
+		{
+			desc:    "Error: no endpoint",
+			env:     map[string]string{"KUSTO_CLIENT_ID": "id"},
+			errVars: []string{"KUSTO_ENDPOINT"},
+		},
+		{
+			desc: "Only the endpoint falls back to the DefaultAzureCredential",
+			env:  map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_DATABASE": "db"},
+			want: &ConnectionStringBuilder{DataSource: endpoint, InitialCatalog: "db", DefaultAuth: true},
+		},
+		{
+			desc: "DefaultAzureCredential with a tenant",
+			env:  map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_TENANT_ID": "tenant"},
+			want: &ConnectionStringBuilder{DataSource: endpoint, DefaultAuth: true, AuthorityId: "tenant"},
+		},
+		{
+			desc: "Application",
+			env:  map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_CLIENT_ID": "id", "KUSTO_CLIENT_SECRET": "secret", "KUSTO_TENANT_ID": "tenant"},
+			want: &ConnectionStringBuilder{DataSource: endpoint, ApplicationClientId: "id", ApplicationKey: "secret", AuthorityId: "tenant"},
+		},
+		{
+			desc:    "Error: application without a secret and a tenant",
+			env:     map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_CLIENT_ID": "id"},
+			errVars: []string{"KUSTO_CLIENT_SECRET", "KUSTO_TENANT_ID"},
+		},
+		{
+			desc:    "Error: application secret without a client ID",
+			env:     map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_CLIENT_SECRET": "secret", "KUSTO_TENANT_ID": "tenant"},
+			errVars: []string{"KUSTO_CLIENT_ID"},
+		},
+		{
+			desc: "Managed identity",
+			env:  map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_MANAGED_IDENTITY_CLIENT_ID": "mi"},
+			want: &ConnectionStringBuilder{DataSource: endpoint, MsiAuthentication: true, ManagedServiceIdentity: "mi"},
+		},
+		{
+			desc:    "Error: managed identity with a tenant",
+			env:     map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_MANAGED_IDENTITY_CLIENT_ID": "mi", "KUSTO_TENANT_ID": "tenant"},
+			errVars: []string{"KUSTO_MANAGED_IDENTITY_CLIENT_ID", "KUSTO_TENANT_ID"},
+		},
+		{
+			desc:    "Error: managed identity and application",
+			env:     map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_MANAGED_IDENTITY_CLIENT_ID": "mi", "KUSTO_CLIENT_ID": "id", "KUSTO_CLIENT_SECRET": "secret"},
+			errVars: []string{"KUSTO_MANAGED_IDENTITY_CLIENT_ID", "KUSTO_CLIENT_ID"},
+		},
+		{
+			desc: "Interactive",
+			env:  map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_INTERACTIVE": "true", "KUSTO_TENANT_ID": "tenant"},
+			want: &ConnectionStringBuilder{DataSource: endpoint, InteractiveLogin: true, AuthorityId: "tenant"},
+		},
+		{
+			desc: "Interactive false",
+			env:  map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_INTERACTIVE": "0"},
+			want: &ConnectionStringBuilder{DataSource: endpoint, DefaultAuth: true},
+		},
+		{
+			desc:    "Error: interactive is not a boolean",
+			env:     map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_INTERACTIVE": "yes please"},
+			errVars: []string{"KUSTO_INTERACTIVE"},
+		},
+		{
+			desc:    "Error: interactive and managed identity",
+			env:     map[string]string{"KUSTO_ENDPOINT": endpoint, "KUSTO_INTERACTIVE": "true", "KUSTO_MANAGED_IDENTITY_CLIENT_ID": "mi"},
+			errVars: []string{"KUSTO_INTERACTIVE", "KUSTO_MANAGED_IDENTITY_CLIENT_ID"},
+		},
+		{
+			desc:   "Prefix",
+			prefix: "APP_ADX",
+			env:    map[string]string{"KUSTO_ENDPOINT": "https://other.kusto.windows.net", "APP_ADX_ENDPOINT": endpoint, "APP_ADX_MANAGED_IDENTITY_CLIENT_ID": "mi"},
+			want:   &ConnectionStringBuilder{DataSource: endpoint, MsiAuthentication: true, ManagedServiceIdentity: "mi"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			lookup := func(key string) (string, bool) {
+				v, ok := test.env[key]
+				return v, ok
+			}
+			got, err := connectionStringBuilderFromEnv(test.prefix, lookup)
+			if len(test.errVars) > 0 {
+				require.Error(t, err)
+				for _, v := range test.errVars {
+					assert.Contains(t, err.Error(), v)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
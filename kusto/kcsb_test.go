@@ -1,9 +1,23 @@
 package kusto
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	goErrors "errors"
+	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	kustoErrors "github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/require"
 	"github.com/tj/assert"
 )
 
@@ -68,6 +82,337 @@ func TestGetConnectionStringBuilder(t *testing.T) {
 	}
 }
 
+func TestParseConnectionString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		connectionString string
+		want             ConnectionStringBuilder
+		wantErr          []string
+	}{
+		{
+			name:             "dotnet application key",
+			connectionString: "Data Source=https://help.kusto.windows.net;Initial Catalog=Samples;AAD Federated Security=True;Application Client Id=appid;Application Key=a2V5=;Authority Id=tenant",
+			want: ConnectionStringBuilder{
+				DataSource:          "https://help.kusto.windows.net",
+				InitialCatalog:      "Samples",
+				ApplicationClientId: "appid",
+				ApplicationKey:      "a2V5=",
+				AuthorityId:         "tenant",
+			},
+		},
+		{
+			name:             "aliases in any case and spacing",
+			connectionString: "  ADDR = https://help.kusto.windows.net ; database=Samples;FED=true;AppClientId=appid;APPCERT=thumb;SendX5c=True;TenantId=tenant;TraceAppName=app;TraceUserName=user",
+			want: ConnectionStringBuilder{
+				DataSource:                       "https://help.kusto.windows.net",
+				InitialCatalog:                   "Samples",
+				ApplicationClientId:              "appid",
+				ApplicationCertificateThumbprint: "thumb",
+				SendCertificateChain:             true,
+				AuthorityId:                      "tenant",
+				ApplicationForTracing:            "app",
+				UserForTracing:                   "user",
+			},
+		},
+		{
+			name:             "quoted value",
+			connectionString: `https://help.kusto.windows.net;AAD User ID="user@microsoft.com";Pwd='P@ssWord'`,
+			want: ConnectionStringBuilder{
+				DataSource: "https://help.kusto.windows.net",
+				AadUserID:  "user@microsoft.com",
+				Password:   "P@ssWord",
+			},
+		},
+		{
+			name:             "quoted value with separators and quotes",
+			connectionString: `https://help.kusto.windows.net;Password = "P@ss;Wo=rd ""x""" ;Application Key='it''s;key';User Token=a"b`,
+			want: ConnectionStringBuilder{
+				DataSource:     "https://help.kusto.windows.net",
+				Password:       `P@ss;Wo=rd "x"`,
+				ApplicationKey: "it's;key",
+				UserToken:      `a"b`,
+			},
+		},
+		{
+			name:             "unknown keyword",
+			connectionString: "Data Source=https://help.kusto.windows.net;Fed=True;Application Secret=key",
+			wantErr:          []string{`"application secret"`, `"Data Source"`, `"AAD Federated Security"`, `"Application Key"`},
+		},
+		{
+			name:             "federated security off",
+			connectionString: "Data Source=https://help.kusto.windows.net;AAD Federated Security=False",
+			wantErr:          []string{"cannot be false"},
+		},
+		{
+			name:             "invalid boolean",
+			connectionString: "Data Source=https://help.kusto.windows.net;Interactive Login=maybe",
+			wantErr:          []string{`"maybe"`},
+		},
+		{
+			name:             "no value",
+			connectionString: "Data Source=https://help.kusto.windows.net;Fed",
+			wantErr:          []string{`"Fed"`},
+		},
+		{
+			name:             "empty",
+			connectionString: "",
+			wantErr:          []string{"cannot be empty"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseConnectionString(test.connectionString)
+			if len(test.wantErr) > 0 {
+				assert.Error(t, err)
+				for _, want := range test.wantErr {
+					assert.Contains(t, err.Error(), want)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.EqualValues(t, test.want, *actual)
+		})
+	}
+}
+
+func TestConnectionStringRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	full := ConnectionStringBuilder{
+		DataSource:                       "https://help.kusto.windows.net",
+		InitialCatalog:                   "Samples",
+		AadUserID:                        "user@microsoft.com",
+		Password:                         `P@ss=Wo;rd"`,
+		UserToken:                        "usertoken",
+		ApplicationClientId:              "appid",
+		ApplicationKey:                   "a2V5=",
+		AuthorityId:                      "tenant",
+		ApplicationCertificate:           "cert",
+		ApplicationCertificateThumbprint: "thumb",
+		SendCertificateChain:             true,
+		ApplicationToken:                 "apptoken",
+		MsiAuthentication:                true,
+		ManagedServiceIdentity:           "msiclientid",
+		ManagedIdentityResourceID:        "/subscriptions/sub/msi",
+		InteractiveLogin:                 true,
+		RedirectURL:                      "www.google.com",
+		ApplicationForTracing:            "app",
+		UserForTracing:                   "user",
+	}
+	assert.Equal(t, "Data Source=https://help.kusto.windows.net;Initial Catalog=Samples;AAD User ID=user@microsoft.com;"+
+		`Password="P@ss=Wo;rd""";Application Client Id=appid;Application Key="a2V5=";Application Certificate=cert;`+
+		"Application Certificate Thumbprint=thumb;Application Certificate Send Public Certificate=True;Authority Id=tenant;"+
+		"Application Token=apptoken;User Token=usertoken;Managed Identity=True;Managed Identity Client Id=msiclientid;"+
+		"Managed Identity Resource Id=/subscriptions/sub/msi;Interactive Login=True;Domain Hint=www.google.com;"+
+		"Application Name for Tracing=app;User Name for Tracing=user", full.ConnectionString(true))
+	assert.Equal(t, "Data Source=https://help.kusto.windows.net;Initial Catalog=Samples;AAD User ID=user@microsoft.com;"+
+		"Password=****;Application Client Id=appid;Application Key=****;Application Certificate=****;"+
+		"Application Certificate Thumbprint=thumb;Application Certificate Send Public Certificate=True;Authority Id=tenant;"+
+		"Application Token=****;User Token=****;Managed Identity=True;Managed Identity Client Id=msiclientid;"+
+		"Managed Identity Resource Id=/subscriptions/sub/msi;Interactive Login=True;Domain Hint=www.google.com;"+
+		"Application Name for Tracing=app;User Name for Tracing=user", full.ConnectionString(false))
+
+	// The values that would not be parsed back as they are are quoted.
+	spaced := ConnectionStringBuilder{DataSource: "https://help.kusto.windows.net", ApplicationToken: " 'token' ", UserToken: `"token"`}
+	assert.Equal(t, `Data Source=https://help.kusto.windows.net;Application Token=" 'token' ";User Token="""token"""`, spaced.ConnectionString(true))
+	got, err := ParseConnectionString(spaced.ConnectionString(true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, spaced, *got)
+
+	// Every keyword, in its canonical form or any alias, is parsed back into the builder.
+	for _, k := range csKeywords {
+		k := k // Capture
+		if k.key == federatedSecurity {
+			continue
+		}
+		for _, name := range append([]string{k.name}, k.aliases...) {
+			name := name // Capture
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				want := ConnectionStringBuilder{DataSource: "https://help.kusto.windows.net"}
+				assert.NoError(t, assignValue(&want, k.name, full.value(k.key)))
+
+				got, err := ParseConnectionString("Data Source=https://help.kusto.windows.net;" + name + "=" + quoteValue(want.value(k.key)))
+				assert.NoError(t, err)
+				assert.EqualValues(t, want, *got)
+
+				got, err = ParseConnectionString(want.ConnectionString(true))
+				assert.NoError(t, err)
+				assert.EqualValues(t, want, *got)
+			})
+		}
+	}
+
+	got, err = ParseConnectionString(full.ConnectionString(true))
+	assert.NoError(t, err)
+	assert.EqualValues(t, full, *got)
+}
+
+func TestManagedIdentity(t *testing.T) {
+	t.Parallel()
+
+	const resourceID = "/subscriptions/sub/resourceGroups/group/providers/Microsoft.ManagedIdentity/userAssignedIdentities/name"
+
+	tests := []struct {
+		name    string
+		kcsb    *ConnectionStringBuilder
+		want    azidentity.ManagedIDKind
+		wantErr bool
+	}{
+		{
+			name: "system assigned",
+			kcsb: NewConnectionStringBuilder("https://msi.kusto.windows.net").WithSystemManagedIdentity(),
+		},
+		{
+			name: "client id",
+			kcsb: NewConnectionStringBuilder("https://msi.kusto.windows.net").WithUserManagedIdentity("clientid"),
+			want: azidentity.ClientID("clientid"),
+		},
+		{
+			name: "resource id",
+			kcsb: NewConnectionStringBuilder("https://msi.kusto.windows.net").WithUserManagedIdentity("clientid").WithUserAssignedIdentityResourceId("", resourceID),
+			want: azidentity.ResourceID(resourceID),
+		},
+		{
+			name: "resource id with endpoint",
+			kcsb: (&ConnectionStringBuilder{}).WithUserAssignedIdentityResourceId("https://msi.kusto.windows.net", resourceID),
+			want: azidentity.ResourceID(resourceID),
+		},
+		{
+			name: "connection string client id",
+			kcsb: NewConnectionStringBuilder("https://msi.kusto.windows.net;MSI Client Id=clientid"),
+			want: azidentity.ClientID("clientid"),
+		},
+		{
+			name: "connection string resource id",
+			kcsb: NewConnectionStringBuilder("https://msi.kusto.windows.net;Managed Identity Resource Id=" + resourceID),
+			want: azidentity.ResourceID(resourceID),
+		},
+		{
+			name:    "connection string both",
+			kcsb:    NewConnectionStringBuilder("https://msi.kusto.windows.net;Managed Identity Client Id=clientid;Managed Identity Resource Id=" + resourceID),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.True(t, test.kcsb.MsiAuthentication)
+			assert.Equal(t, "https://msi.kusto.windows.net", test.kcsb.DataSource)
+
+			client, err := New(test.kcsb)
+			if test.wantErr {
+				var kerr *kustoErrors.Error
+				assert.True(t, goErrors.As(err, &kerr), "got %T: %v", err, err)
+				assert.Equal(t, kustoErrors.KClientArgs, kerr.Kind)
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+			assert.Equal(t, test.want, test.kcsb.managedIdentityID())
+		})
+	}
+
+	assert.Panics(t, func() {
+		NewConnectionStringBuilder("https://msi.kusto.windows.net").WithUserAssignedIdentityResourceId("", "")
+	})
+}
+
+// testCertificate returns a self-signed certificate and its private key, PEM encoded.
+func testCertificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kusto-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})...)
+}
+
+func TestAppCertificate(t *testing.T) {
+	t.Parallel()
+
+	const endpoint = "https://cert.kusto.windows.net"
+	cert := testCertificate(t)
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certPath, cert, 0600))
+	badPath := filepath.Join(dir, "bad.pem")
+	require.NoError(t, os.WriteFile(badPath, []byte("not a certificate"), 0600))
+
+	tests := []struct {
+		name    string
+		kcsb    *ConnectionStringBuilder
+		wantErr bool
+	}{
+		{
+			name: "bytes",
+			kcsb: NewConnectionStringBuilder(endpoint).WithAppCertificate("app", cert, "", true, "tenant"),
+		},
+		{
+			name: "file",
+			kcsb: NewConnectionStringBuilder(endpoint).WithAppCertificateFile("app", certPath, "", true, "tenant"),
+		},
+		{
+			name:    "invalid bytes",
+			kcsb:    NewConnectionStringBuilder(endpoint).WithAppCertificate("app", []byte("not a certificate"), "", false, "tenant"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid file",
+			kcsb:    NewConnectionStringBuilder(endpoint).WithAppCertificateFile("app", badPath, "", false, "tenant"),
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			kcsb:    NewConnectionStringBuilder(endpoint).WithAppCertificateFile("app", filepath.Join(dir, "missing.pem"), "", false, "tenant"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := New(test.kcsb)
+			if test.wantErr {
+				var kerr *kustoErrors.Error
+				require.True(t, goErrors.As(err, &kerr), "got %T: %v", err, err)
+				assert.Equal(t, kustoErrors.KClientArgs, kerr.Kind)
+				assert.False(t, kustoErrors.Retry(err))
+				return
+			}
+			require.NoError(t, err)
+			defer client.Close()
+			assert.True(t, client.auth.TokenProvider.AuthorizationRequired())
+			assert.Equal(t, "app", test.kcsb.ApplicationClientId)
+			assert.True(t, test.kcsb.SendCertificateChain)
+		})
+	}
+
+	assert.Panics(t, func() { NewConnectionStringBuilder(endpoint).WithAppCertificate("app", nil, "", false, "tenant") })
+	assert.Panics(t, func() { NewConnectionStringBuilder(endpoint).WithAppCertificateFile("app", "", "", false, "tenant") })
+}
+
 func TestWithAadUserPassAuth(t *testing.T) {
 	want := ConnectionStringBuilder{
 		DataSource:  "endpoint",
@@ -160,6 +505,13 @@ func TestGetTokenProviderHappy(t *testing.T) {
 				MsiAuthentication:      true,
 				ClientOptions:          &azcore.ClientOptions{},
 			},
+		}, {
+			name: "test_tokenprovider_managedresourceid",
+			kcsb: ConnectionStringBuilder{
+				DataSource:                "https://endpoint/test_tokenprovider_managedresourceid",
+				ManagedIdentityResourceID: "/subscriptions/sub/resourceGroups/group/providers/Microsoft.ManagedIdentity/userAssignedIdentities/name",
+				MsiAuthentication:         true,
+			},
 		}, {
 			name: "test_tokenprovider_managedidauth2",
 			kcsb: ConnectionStringBuilder{
@@ -0,0 +1,103 @@
+// Package kql builds Kusto Query Language text from Go values. The values are escaped as literals, so they can come
+// from untrusted input.
+package kql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/internal/stmt"
+)
+
+// Builder builds a statement from string constants, escaped names, literals and query parameters. It is a kusto.Stmt,
+// so it is passed to Client.Query() and Client.Mgmt() as is. Every method returns a new Builder, see kusto.Stmt.
+// Example:
+//
+//	stmt := kql.New("").AddTable(table).Add(" | where Name == ").AddParam("name", "bob").Add(" | take ").AddLiteral(10)
+//	// declare query_parameters(name:string);
+//	// Events | where Name == name | take long(10)
+//	iter, err := client.Query(ctx, db, stmt)
+//
+// The parameters are declared with the type of their value, see kusto.Stmt.AddParam(). An error from one of the
+// methods is kept by the Builder and returned by Err() and by the client once the Builder is used.
+type Builder = kusto.Stmt
+
+// New returns a Builder that starts with query, which must be a string constant, see kusto.NewStmt().
+func New(query stmt.StringConstant, options ...kusto.StmtOption) Builder {
+	return kusto.NewStmt(query, options...)
+}
+
+// NewDatatableFromStructs returns a let statement that declares name as a datatable holding rows, which must be a
+// slice of structs or of pointers to structs. The columns and values of each struct are the ones of
+// kusto.StructToKustoValues(). Example:
+//
+//	decl, err := kql.NewDatatableFromStructs("Events", []Event{{ID: 1, Name: "start"}})
+//	// let Events = datatable(ID:long, Name:string) [
+//	// 	long(1), "start"
+//	// ];
+//
+// The statement can be added to a kusto.Stmt with UnsafeAdd(), as everything but the query text is escaped.
+func NewDatatableFromStructs(name string, rows interface{}) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("NewDatatableFromStructs() requires a name")
+	}
+	rv := reflect.ValueOf(rows)
+	if rv.Kind() != reflect.Slice {
+		return "", fmt.Errorf("NewDatatableFromStructs() rows must be a slice of structs, was %T", rows)
+	}
+
+	// The columns come from the element type, so that an empty slice declares an empty datatable.
+	elem := rv.Type().Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return "", fmt.Errorf("NewDatatableFromStructs() rows must be a slice of structs, was %T", rows)
+	}
+	cols, _, err := kusto.StructToKustoValues(reflect.New(elem).Interface())
+	if err != nil {
+		return "", err
+	}
+	if len(cols) == 0 {
+		return "", fmt.Errorf("NewDatatableFromStructs() rows of type %s have no columns", elem)
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("let " + table.QuoteIdentifier(name) + " = datatable(")
+	for i, col := range cols {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(table.QuoteIdentifier(col.Name) + ":" + string(col.Type))
+	}
+	sb.WriteString(") [\n")
+
+	for i := 0; i < rv.Len(); i++ {
+		row := rv.Index(i)
+		if row.Kind() == reflect.Ptr && row.IsNil() {
+			return "", fmt.Errorf("NewDatatableFromStructs() rows[%d] is nil", i)
+		}
+		_, values, err := kusto.StructToKustoValues(row.Interface())
+		if err != nil {
+			return "", fmt.Errorf("rows[%d]: %w", i, err)
+		}
+		literals := make([]string, len(values))
+		for j, v := range values {
+			if literals[j], err = table.Literal(v); err != nil {
+				return "", fmt.Errorf("rows[%d] column %s: %w", i, cols[j].Name, err)
+			}
+		}
+		if i > 0 {
+			sb.WriteString(",\n")
+		}
+		sb.WriteString("\t" + strings.Join(literals, ", "))
+	}
+	if rv.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("];\n")
+	return sb.String(), nil
+}
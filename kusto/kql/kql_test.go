@@ -0,0 +1,107 @@
+package kql
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	ID    int64 `kusto:"Id"`
+	Name  string
+	Seen  time.Time
+	Node  *uuid.UUID
+	Props map[string]string
+	Ratio float64
+}
+
+func TestNewDatatableFromStructs(t *testing.T) {
+	t.Parallel()
+
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	node := uuid.MustParse("6f6b7c0e-6d5a-4c3e-9c2b-1a2b3c4d5e6f")
+
+	tests := []struct {
+		desc      string
+		tableName string
+		rows      interface{}
+		want      string
+		err       bool
+	}{
+		{
+			desc:      "Rows",
+			tableName: "Events",
+			rows: []event{
+				{ID: 1, Name: "start", Seen: seen, Node: &node, Props: map[string]string{"k": "v"}, Ratio: 0.5},
+				{ID: 2, Name: "\"]; .drop table Events; print \"", Ratio: math.Inf(1)},
+			},
+			want: "let Events = datatable(Id:long, Name:string, Seen:datetime, Node:guid, Props:dynamic, Ratio:real) [\n" +
+				"\tlong(1), \"start\", datetime(2024-01-02T03:04:05Z), guid(6f6b7c0e-6d5a-4c3e-9c2b-1a2b3c4d5e6f), dynamic({\"k\":\"v\"}), real(0.5),\n" +
+				"\tlong(2), \"\\\"]; .drop table Events; print \\\"\", datetime(0001-01-01T00:00:00Z), guid(null), dynamic(null), real(+inf)\n" +
+				"];\n",
+		},
+		{
+			desc:      "Pointers and quoted name",
+			tableName: "my events",
+			rows:      []*event{{ID: 3, Seen: seen}},
+			want: "let ['my events'] = datatable(Id:long, Name:string, Seen:datetime, Node:guid, Props:dynamic, Ratio:real) [\n" +
+				"\tlong(3), \"\", datetime(2024-01-02T03:04:05Z), guid(null), dynamic(null), real(0)\n" +
+				"];\n",
+		},
+		{
+			desc:      "Empty",
+			tableName: "Events",
+			rows:      []event{},
+			want:      "let Events = datatable(Id:long, Name:string, Seen:datetime, Node:guid, Props:dynamic, Ratio:real) [\n];\n",
+		},
+		{
+			desc:      "Nil row",
+			tableName: "Events",
+			rows:      []*event{nil},
+			err:       true,
+		},
+		{
+			desc:      "Not a slice of structs",
+			tableName: "Events",
+			rows:      []string{"a"},
+			err:       true,
+		},
+		{
+			desc:      "No name",
+			tableName: " ",
+			rows:      []event{},
+			err:       true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewDatatableFromStructs(test.tableName, test.rows)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	t.Parallel()
+
+	var b Builder = New("").AddTable("Events").Add(" | where Name == ").AddParam("name", "bob").Add(" | take ").AddLiteral(10)
+	require.NoError(t, b.Err())
+
+	assert.Equal(t, "declare query_parameters(name:string);\nEvents | where Name == name | take long(10)", b.String())
+	params, err := b.Parameters()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "bob"}, params)
+}
@@ -7,11 +7,14 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/Azure/azure-kusto-go/kusto/internal/response"
+	"github.com/cenkalti/backoff/v4"
 )
 
 // queryer provides for getting a stream of Kusto frames. Exists to allow fake Kusto streams in tests.
@@ -19,7 +22,8 @@ type queryer interface {
 	io.Closer
 	query(ctx context.Context, db string, query Stmt, options *queryOptions) (execResp, error)
 	mgmt(ctx context.Context, db string, query Stmt, options *mgmtOptions) (execResp, error)
-	queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (string, error)
+	queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (jsonResp, error)
+	mgmtToJson(ctx context.Context, db string, query Stmt, options *mgmtOptions) (jsonResp, error)
 }
 
 // Authorization provides the TokenProvider needed to acquire the auth token.
@@ -36,8 +40,49 @@ type Client struct {
 	mgmtConnMu       sync.Mutex
 	http             *http.Client
 	clientDetails    *ClientDetails
+	dedupSettings    dedupSettings
+	dedup            *deduplicator
+	readOnly         bool
+	decompressors    response.Decompressors
+	logger           Logger
+	interceptors     []StatementInterceptor
+	resultsCache     *resultsCache
+	// timeoutHeadroom is set by WithTimeoutHeadroom(), nil for DefaultTimeoutHeadroom.
+	timeoutHeadroom *time.Duration
+	// newMarkerBackoff returns the intervals between the checks of QueryAfter(), nil for the default ones.
+	newMarkerBackoff func() backoff.BackOff
+	// retry is set by WithRetryOptions(), nil to never retry.
+	retry *retryPolicy
+	// compressMin is set by WithRequestCompression(), 0 to never compress.
+	compressMin int
+	// callbacks are set by WithCallbacks(), nil if there are none.
+	callbacks *Callbacks
+	// wrapTransport are set by WithRoundTripper(), in order.
+	wrapTransport []func(http.RoundTripper) http.RoundTripper
+	// allowInsecure is set by ConnectionStringBuilder.AllowInsecure().
+	allowInsecure bool
+	// trustedEndpointPolicy is set by WithTrustedEndpointPolicy(), nil to use the trusted endpoints.
+	trustedEndpointPolicy func(host string) bool
+	// defaultQueryOptions are set by WithDefaultQueryOptions(), in order.
+	defaultQueryOptions []QueryOption
+	// queryCache is set by WithQueryCache(), nil to not cache the responses.
+	queryCache *queryCache
+	// autoCorrectEndpoint is set by WithAutoCorrectEndpoint().
+	autoCorrectEndpoint bool
+	// closed is set once Close() was called, after which the calls return ClientClosedErr.
+	closed atomic.Bool
+	// inflightMu guards inflight, the calls in flight, which Close() cancels. See track().
+	inflightMu sync.Mutex
+	inflight   map[*inflightCall]struct{}
+	// closeOnce runs Close() once, which returns closeErr every time.
+	closeOnce sync.Once
+	closeErr  error
 }
 
+// ClientClosedErr is returned by the calls of a Client made after Close() was called on it, and by the calls and
+// RowIterators that Close() cancelled.
+var ClientClosedErr = errors.ES(errors.OpServConn, errors.KClientArgs, "client is closed").SetNoRetry()
+
 // Option is an optional argument type for New().
 type Option func(c *Client)
 
@@ -55,33 +100,53 @@ func New(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error) {
 	if err != nil {
 		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "could not parse the endpoint(%s): %s", endpoint, err).SetNoRetry()
 	}
-	if strings.HasPrefix(u.Hostname(), "ingest-") {
-		return nil, errors.ES(
-			errors.OpServConn,
-			errors.KClientArgs,
-			"endpoint argument started with 'ingest-'. Adding 'ingest-' is taken care of by the client. "+
-				"If using Mgmt() on an ingestion endpoint, use option QueryIngestion(). This is very uncommon",
-		)
-	}
 
-	client := &Client{auth: *auth, endpoint: endpoint, clientDetails: NewClientDetails(kcsb.ApplicationForTracing, kcsb.UserForTracing)}
+	client := &Client{auth: *auth, endpoint: endpoint, clientDetails: NewClientDetails(kcsb.ApplicationForTracing, kcsb.UserForTracing),
+		allowInsecure: kcsb.AllowInsecureEndpoint}
 	for _, o := range options {
 		o(client)
 	}
 
-	if client.http == nil {
-		client.http = &http.Client{}
+	if strings.HasPrefix(strings.ToLower(u.Hostname()), ingestPrefix) {
+		if !client.autoCorrectEndpoint {
+			return nil, errors.ES(
+				errors.OpServConn,
+				errors.KClientArgs,
+				"endpoint argument started with 'ingest-'. Adding 'ingest-' is taken care of by the client, "+
+					"or use option WithAutoCorrectEndpoint() to remove it. "+
+					"If using Mgmt() on an ingestion endpoint, use option QueryIngestion(). This is very uncommon",
+			)
+		}
+		if endpoint, err = ToQueryEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+		client.endpoint = endpoint
+	}
+
+	if err := client.setHTTPClient(); err != nil {
+		return nil, err
+	}
+
+	if client.dedupSettings.enabled {
+		client.dedup = newDeduplicator(client.dedupSettings)
 	}
 
-	conn, err := newConn(endpoint, *auth, client.http, client.clientDetails)
+	conn, err := newConnAllowInsecure(endpoint, client.allowInsecure, *auth, client.http, client.clientDetails)
 	if err != nil {
 		return nil, err
 	}
+	conn.decompressors = client.decompressors
+	conn.retry = client.retry
+	conn.compressMin = client.compressMin
+	conn.callbacks = client.callbacks
+	conn.trustedEndpointPolicy = client.trustedEndpointPolicy
 	client.conn = conn
 
 	return client, nil
 }
 
+// WithHttpClient sets the http.Client used to send the requests, instead of the one New() makes. It cannot be used with
+// WithRoundTripper().
 func WithHttpClient(client *http.Client) Option {
 	return func(c *Client) {
 		c.http = client
@@ -121,31 +186,90 @@ const (
 // Note that the server has a timeout of 4 minutes for a query by default unless the context deadline is set. Queries can
 // take a maximum of 1 hour.
 func (c *Client) Query(ctx context.Context, db string, query Stmt, options ...QueryOption) (*RowIterator, error) {
+	return c.query(ctx, db, query, nil, options...)
+}
+
+// query is Query(), with base as the defaults of the call, which the defaults of the client override, see
+// setQueryOptions().
+func (c *Client) query(ctx context.Context, db string, query Stmt, base []QueryOption, options ...QueryOption) (*RowIterator, error) {
+	options, err := c.readOnlyQuery(query, options)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel, err := contextSetup(ctx, false) // Note: cancel is called when *RowIterator has Stop() called.
 	if err != nil {
 		return nil, err
 	}
+	// The caches are not used once the Client is closed either.
+	cancel, err = c.track(cancel)
+	if err != nil {
+		return nil, err
+	}
 
-	opts, err := setQueryOptions(ctx, errors.OpQuery, query, options...)
+	opts, err := setQueryOptions(ctx, errors.OpQuery, query, c.queryTimeoutHeadroom(c.queryDefaults(base)), options...)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
+	c.warnUnsafe(CallQuery, query)
+	db, query, err = c.intercept(ctx, CallQuery, db, opts.query, opts.requestProperties)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var iter *RowIterator
+	switch {
+	case c.resultsCache != nil && opts.cacheable:
+		iter, err = c.resultsCache.query(ctx, cancel, c, db, query, opts)
+	case c.queryCache != nil:
+		iter, err = c.queryCache.query(ctx, cancel, c, db, query, opts)
+	default:
+		iter, err = c.runQuery(ctx, cancel, db, query, opts)
+	}
+	if err != nil {
+		return nil, c.closedErr(err)
+	}
+	iter.clientClosed = &c.closed
+	return iter, nil
+}
+
+// runQuery sends a query whose options are set and returns its RowIterator.
+func (c *Client) runQuery(ctx context.Context, cancel context.CancelFunc, db string, query Stmt, opts *queryOptions) (*RowIterator, error) {
 	conn, err := c.getConn(queryCall, connOptions{queryOptions: opts})
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	execResp, err := conn.query(ctx, db, query, opts)
+	execResp, err := c.dedupQuery(ctx, conn, db, query, opts)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
 
+	iter, err := startRowIterator(ctx, cancel, execResp, <-execResp.frameCh, errors.OpQuery, opts.progressCallback)
+	if err != nil {
+		return nil, err
+	}
+	iter.primaryResultsOnly = opts.primaryResultsOnly
+	iter.location = opts.location
+	iter.requestProperties = newResolvedProperties(opts.requestProperties)
+	iter.setLogger(c.logger)
+
+	return iter, nil
+}
+
+// startRowIterator returns a RowIterator over the v2 frames of execResp, first being the frame already received.
+// cancel is called if first is an error, otherwise when the RowIterator is stopped. onProgress is the callback of
+// WithProgressCallback(), nil if there is none.
+func startRowIterator(ctx context.Context, cancel context.CancelFunc, execResp execResp, first frames.Frame, op errors.Op,
+	onProgress func(tableOrdinal int64, percent float64)) (*RowIterator, error) {
 	var header v2.DataSetHeader
 
-	ff := <-execResp.frameCh
-	switch v := ff.(type) {
+	switch v := first.(type) {
 	case v2.DataSetHeader:
 		header = v
 	case frames.Error:
@@ -153,91 +277,148 @@ func (c *Client) Query(ctx context.Context, db string, query Stmt, options ...Qu
 		return nil, v
 	}
 
-	iter, columnsReady := newRowIterator(ctx, cancel, execResp, header, errors.OpQuery)
+	iter, columnsReady := newRowIterator(ctx, cancel, execResp, header, op)
+	// The DataSetHeader is the first frame.
+	iter.stats.frames.Add(1)
 
 	var sm stateMachine
-	if header.IsProgressive {
+	var notifier *progressNotifier
+	// A fragmented stream uses the frames of a progressive one for its primary tables.
+	if header.IsProgressive || header.IsFragmented {
+		notifier = newProgressNotifier(onProgress)
 		sm = &progressiveSM{
-			op:   errors.OpQuery,
-			iter: iter,
-			in:   execResp.frameCh,
-			ctx:  ctx,
-			wg:   &sync.WaitGroup{},
+			op:       op,
+			iter:     iter,
+			in:       execResp.frameCh,
+			ctx:      ctx,
+			notifier: notifier,
+			wg:       &sync.WaitGroup{},
 		}
 	} else {
 		sm = &nonProgressiveSM{
-			op:   errors.OpQuery,
+			op:   op,
 			iter: iter,
 			in:   execResp.frameCh,
 			ctx:  ctx,
 			wg:   &sync.WaitGroup{},
 		}
 	}
-	go runSM(sm)
+	go func() {
+		runSM(sm)
+		notifier.close()
+	}()
 
 	<-columnsReady
 
 	return iter, nil
 }
 
+// QueryToJson runs query on database db like Query() does, and returns the response of the service as a JSON string.
+// The response uses the non-progressive v2 framing, a JSON array of frames where each table is a single DataTable
+// frame, unless JsonFramingProgressive() is passed. If the response is not complete JSON, an error is returned
+// instead of the partial string. See QueryToJsonResult() for the headers of the request and of the response.
 func (c *Client) QueryToJson(ctx context.Context, db string, query Stmt, options ...QueryOption) (string, error) {
-	ctx, cancel, err := contextSetup(ctx, false) // Note: cancel is called when *RowIterator has Stop() called.
+	result, err := c.QueryToJsonResult(ctx, db, query, options...)
 	if err != nil {
 		return "", err
 	}
+	return result.JSON, nil
+}
 
-	opts, err := setQueryOptions(ctx, errors.OpQuery, query, options...)
+// QueryToJsonResult is QueryToJson(), which also returns the headers of the request and of the response, such as
+// the client request ID that was sent.
+func (c *Client) QueryToJsonResult(ctx context.Context, db string, query Stmt, options ...QueryOption) (JsonResult, error) {
+	options, err := c.readOnlyQuery(query, options)
 	if err != nil {
-		return "", err
+		return JsonResult{}, err
+	}
+
+	ctx, cancel, err := contextSetup(ctx, false)
+	if err != nil {
+		return JsonResult{}, err
+	}
+	cancel, err = c.track(cancel)
+	if err != nil {
+		return JsonResult{}, err
+	}
+	defer cancel()
+
+	// Unlike Query(), the default framing is non-progressive, which the defaults of the client, the options of the
+	// context and options can change.
+	base := []QueryOption{JsonFramingNonProgressive()}
+	opts, err := setQueryOptions(ctx, errors.OpQuery, query, c.queryTimeoutHeadroom(c.queryDefaults(base)), options...)
+	if err != nil {
+		return JsonResult{}, err
+	}
+
+	c.warnUnsafe(CallQueryToJSON, query)
+	db, query, err = c.intercept(ctx, CallQueryToJSON, db, opts.query, opts.requestProperties)
+	if err != nil {
+		return JsonResult{}, err
 	}
 
 	conn, err := c.getConn(queryCall, connOptions{queryOptions: opts})
 	if err != nil {
-		return "", err
+		return JsonResult{}, err
 	}
 
-	json, err := conn.queryToJson(ctx, db, query, opts)
+	resp, err := conn.queryToJson(ctx, db, query, opts)
 	if err != nil {
-		cancel()
-		return "", err
+		return JsonResult{}, c.closedErr(err)
 	}
 
-	return json, nil
+	return JsonResult{JSON: resp.body, RequestHeader: resp.reqHeader, ResponseHeader: resp.respHeader}, nil
 }
 
 // Mgmt is used to do management queries to Kusto.
 // Details can be found at: https://docs.microsoft.com/en-us/azure/kusto/management/
-// Mgmt accepts a Stmt, but that Stmt cannot have any query parameters attached at this time.
+// Mgmt accepts a Stmt with Definitions and Parameters, which are sent like the ones of Query(), for commands that
+// run a query such as .set-or-append. They are not supported with IngestionEndpoint(), as the ingestion endpoint does
+// not run queries.
 // Note that the server has a timeout of 10 minutes for a management call by default unless the context deadline is set.
 // There is a maximum of 1 hour.
 func (c *Client) Mgmt(ctx context.Context, db string, query Stmt, options ...MgmtOption) (*RowIterator, error) {
-
-	if !query.params.IsZero() || !query.defs.IsZero() {
-		return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "a Mgmt() call cannot accept a Stmt object that has Definitions or Parameters attached")
+	if err := c.readOnlyMgmt(); err != nil {
+		return nil, err
 	}
 
 	ctx, cancel, err := contextSetup(ctx, true) // Note: cancel is called when *RowIterator has Stop() called.
 	if err != nil {
 		return nil, err
 	}
+	cancel, err = c.track(cancel)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := setMgmtOptions(ctx, errors.OpMgmt, query, c.mgmtTimeoutHeadroom(options)...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
-	opts, err := setMgmtOptions(ctx, errors.OpMgmt, query, options...)
+	c.warnUnsafe(CallMgmt, query)
+	db, query, err = c.intercept(ctx, CallMgmt, db, query, opts.requestProperties)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	conn, err := c.getConn(mgmtCall, connOptions{mgmtOptions: opts})
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	execResp, err := conn.mgmt(ctx, db, query, opts)
 	if err != nil {
 		cancel()
-		return nil, err
+		return nil, c.closedErr(err)
 	}
 
 	iter, columnsReady := newRowIterator(ctx, cancel, execResp, v2.DataSetHeader{}, errors.OpMgmt)
+	iter.requestProperties = newResolvedProperties(opts.requestProperties)
+	iter.clientClosed = &c.closed
 	sm := &v1SM{
 		op:   errors.OpQuery,
 		iter: iter,
@@ -253,19 +434,62 @@ func (c *Client) Mgmt(ctx context.Context, db string, query Stmt, options ...Mgm
 	return iter, nil
 }
 
-func setQueryOptions(ctx context.Context, op errors.Op, query Stmt, options ...QueryOption) (*queryOptions, error) {
-	params, err := query.params.toParameters(query.defs)
+// MgmtToJson runs query on database db like Mgmt() does, and returns the response of the service as a JSON string,
+// which is the v1 response body exactly as the service sent it, once decompressed. This is useful to pass the result
+// of a command such as .show tables to tools that parse the JSON of the service. If the response is not complete JSON,
+// an error is returned instead of the partial string.
+func (c *Client) MgmtToJson(ctx context.Context, db string, query Stmt, options ...MgmtOption) (string, error) {
+	if err := c.readOnlyMgmt(); err != nil {
+		return "", err
+	}
+
+	ctx, cancel, err := contextSetup(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	cancel, err = c.track(cancel)
 	if err != nil {
-		return nil, errors.ES(op, errors.KClientArgs, "QueryValues in the the Stmt were incorrect: %s", err).SetNoRetry()
+		return "", err
 	}
+	defer cancel()
 
-	// Match our server deadline to our context.Deadline. This should be set from withing kusto.Query() to always have a value.
-	deadline, ok := ctx.Deadline()
-	if ok {
-		options = append(
-			options,
-			queryServerTimeout(deadline.Sub(nower())),
-		)
+	opts, err := setMgmtOptions(ctx, errors.OpMgmt, query, c.mgmtTimeoutHeadroom(options)...)
+	if err != nil {
+		return "", err
+	}
+
+	c.warnUnsafe(CallMgmtToJSON, query)
+	db, query, err = c.intercept(ctx, CallMgmtToJSON, db, query, opts.requestProperties)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := c.getConn(mgmtCall, connOptions{mgmtOptions: opts})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := conn.mgmtToJson(ctx, db, query, opts)
+	if err != nil {
+		return "", c.closedErr(err)
+	}
+	return resp.body, nil
+}
+
+// setQueryOptions returns the queryOptions of query. defaults are applied first: the defaults of the call, such as the
+// framing of QueryToJson(), then the ones of the client. The options carried by ctx and then options override them.
+func setQueryOptions(ctx context.Context, op errors.Op, query Stmt, defaults []QueryOption, options ...QueryOption) (*queryOptions, error) {
+	params, err := stmtParameters(op, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Options carried by the context are applied before the explicit ones, so that the explicit ones win. The defaults
+	// come before all of them.
+	ctxOptions := contextQueryOptions(ctx)
+	if len(ctxOptions)+len(defaults) > 0 {
+		merged := make([]QueryOption, 0, len(defaults)+len(ctxOptions)+len(options))
+		options = append(append(append(merged, defaults...), ctxOptions...), options...)
 	}
 
 	opt := &queryOptions{
@@ -273,35 +497,38 @@ func setQueryOptions(ctx context.Context, op errors.Op, query Stmt, options ...Q
 			Options:    map[string]interface{}{},
 			Parameters: params,
 		},
+		timeoutHeadroom: DefaultTimeoutHeadroom,
 	}
 	/*if op == errors.OpQuery {
 		// We want progressive frames by default for Query(), but not Mgmt() because it uses v1 framing and ingestion endpoints
 		// do not support it.
-		opt.requestProperties.Options["results_progressive_enabled"] = true
+		opt.requestProperties.setOption(resultsProgressiveEnabledValue, true)
 	}*/
-	opt.requestProperties.Options["results_progressive_enabled"] = true
+	opt.requestProperties.setOption(resultsProgressiveEnabledValue, true)
 
 	for _, o := range options {
 		if err := o(opt); err != nil {
 			return nil, errors.ES(op, errors.KClientArgs, "QueryValues in the the Stmt were incorrect: %s", err).SetNoRetry()
 		}
 	}
+	lockReadOnly(opt.requestProperties)
+	if !isEmpty(opt.userAssertion) {
+		// The results of a user must not be served to another one.
+		opt.noDedup = true
+		opt.cacheable = false
+	}
+	if err := validateServerTimeout(op, opt.requestProperties); err != nil {
+		return nil, err
+	}
+	opt.query = offloadParameters(query, opt.requestProperties, opt.offloadThreshold)
+	setServerTimeout(ctx, opt.requestProperties, opt.timeoutHeadroom)
 	return opt, nil
 }
 
 func setMgmtOptions(ctx context.Context, op errors.Op, query Stmt, options ...MgmtOption) (*mgmtOptions, error) {
-	params, err := query.params.toParameters(query.defs)
+	params, err := stmtParameters(op, query)
 	if err != nil {
-		return nil, errors.ES(op, errors.KClientArgs, "QueryValues in the the Stmt were incorrect: %s", err).SetNoRetry()
-	}
-
-	// Match our server deadline to our context.Deadline. This should be set from withing kusto.Query() to always have a value.
-	deadline, ok := ctx.Deadline()
-	if ok {
-		options = append(
-			options,
-			mgmtServerTimeout(deadline.Sub(nower())),
-		)
+		return nil, err
 	}
 
 	opt := &mgmtOptions{
@@ -309,11 +536,12 @@ func setMgmtOptions(ctx context.Context, op errors.Op, query Stmt, options ...Mg
 			Options:    map[string]interface{}{},
 			Parameters: params,
 		},
+		timeoutHeadroom: DefaultTimeoutHeadroom,
 	}
 	if op == errors.OpQuery {
 		// We want progressive frames by default for Query(), but not Mgmt() because it uses v1 framing and ingestion endpoints
 		// do not support it.
-		opt.requestProperties.Options["results_progressive_enabled"] = true
+		opt.requestProperties.setOption(resultsProgressiveEnabledValue, true)
 	}
 
 	for _, o := range options {
@@ -321,35 +549,57 @@ func setMgmtOptions(ctx context.Context, op errors.Op, query Stmt, options ...Mg
 			return nil, errors.ES(op, errors.KClientArgs, "QueryValues in the the Stmt were incorrect: %s", err).SetNoRetry()
 		}
 	}
+	if opt.queryIngestion && (!query.params.IsZero() || !query.defs.IsZero()) {
+		return nil, errors.ES(op, errors.KClientArgs, "a Mgmt() call to the ingestion endpoint, see IngestionEndpoint(), cannot accept a Stmt object "+
+			"that has Definitions or Parameters attached, as the ingestion endpoint does not run queries").SetNoRetry()
+	}
+	if err := validateServerTimeout(op, opt.requestProperties); err != nil {
+		return nil, err
+	}
+	setServerTimeout(ctx, opt.requestProperties, opt.timeoutHeadroom)
 	return opt, nil
 }
 
 func (c *Client) getConn(callType callType, options connOptions) (queryer, error) {
+	if c.closed.Load() {
+		return nil, ClientClosedErr
+	}
 	switch callType {
 	case queryCall:
 		return c.conn, nil
 	case mgmtCall:
-		delete(options.mgmtOptions.requestProperties.Options, "results_progressive_enabled")
+		options.mgmtOptions.requestProperties.deleteOption(resultsProgressiveEnabledValue)
 		if options.mgmtOptions.queryIngestion {
 			c.mgmtConnMu.Lock()
 			defer c.mgmtConnMu.Unlock()
 
+			// Close() holds the lock, so that no connection is opened once it closed them.
+			if c.closed.Load() {
+				return nil, ClientClosedErr
+			}
 			if c.ingestConn != nil {
 				return c.ingestConn, nil
 			}
 
-			u, _ := url.Parse(c.endpoint) // Don't care about the error
-			u.Host = "ingest-" + u.Host
+			ingestEndpoint, err := ToIngestionEndpoint(c.endpoint)
+			if err != nil {
+				return nil, err
+			}
 			auth := c.auth
 			var details *ClientDetails
 			if innerConn, ok := c.conn.(*conn); ok {
 				details = innerConn.clientDetails
 			}
 
-			iconn, err := newConn(u.String(), auth, c.http, details)
+			iconn, err := newConnAllowInsecure(ingestEndpoint, c.allowInsecure, auth, c.http, details)
 			if err != nil {
 				return nil, err
 			}
+			iconn.decompressors = c.decompressors
+			iconn.retry = c.retry
+			iconn.compressMin = c.compressMin
+			iconn.callbacks = c.callbacks
+			iconn.trustedEndpointPolicy = c.trustedEndpointPolicy
 			c.ingestConn = iconn
 
 			return iconn, nil
@@ -391,18 +641,76 @@ func (c *Client) ClientDetails() *ClientDetails {
 	return c.clientDetails
 }
 
+// Close cancels the calls in flight, closes the idle connections of the Client and stops the background refresh of its
+// token. The calls made after Close(), the calls it cancelled and the RowIterators of the queries it cancelled return
+// ClientClosedErr. Close can be called more than once and concurrently with the other calls, it returns the same
+// error every time.
 func (c *Client) Close() error {
-	var err error
-	if c.conn != nil {
-		err = c.conn.Close()
-	}
-	if c.ingestConn != nil {
-		err2 := c.ingestConn.Close()
-		if err == nil {
-			err = err2
-		} else {
-			err = errors.GetCombinedError(err, err2)
+	c.closeOnce.Do(func() {
+		c.mgmtConnMu.Lock()
+		defer c.mgmtConnMu.Unlock()
+
+		c.inflightMu.Lock()
+		c.closed.Store(true)
+		inflight := c.inflight
+		c.inflight = nil
+		c.inflightMu.Unlock()
+		for call := range inflight {
+			call.cancel()
 		}
+
+		var err error
+		if c.conn != nil {
+			err = c.conn.Close()
+		}
+		if c.ingestConn != nil {
+			err2 := c.ingestConn.Close()
+			if err == nil {
+				err = err2
+			} else {
+				err = errors.GetCombinedError(err, err2)
+			}
+		}
+		c.closeErr = err
+	})
+	return c.closeErr
+}
+
+// inflightCall is a call of a Client in flight.
+type inflightCall struct {
+	cancel context.CancelFunc
+}
+
+// track registers a call in flight, whose context is cancelled by cancel, so that Close() cancels it. It returns the
+// cancel func to call once the call is done, such as when its RowIterator is stopped, or ClientClosedErr if the
+// Client is closed.
+func (c *Client) track(cancel context.CancelFunc) (context.CancelFunc, error) {
+	call := &inflightCall{cancel: cancel}
+
+	c.inflightMu.Lock()
+	if c.closed.Load() {
+		c.inflightMu.Unlock()
+		cancel()
+		return nil, ClientClosedErr
+	}
+	if c.inflight == nil {
+		c.inflight = map[*inflightCall]struct{}{}
+	}
+	c.inflight[call] = struct{}{}
+	c.inflightMu.Unlock()
+
+	return func() {
+		c.inflightMu.Lock()
+		delete(c.inflight, call)
+		c.inflightMu.Unlock()
+		cancel()
+	}, nil
+}
+
+// closedErr returns ClientClosedErr in place of err if the Client was closed, which cancelled the call that failed.
+func (c *Client) closedErr(err error) error {
+	if err != nil && c.closed.Load() {
+		return ClientClosedErr
 	}
 	return err
 }
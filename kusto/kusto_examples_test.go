@@ -13,6 +13,7 @@ import (
 
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
 )
 
 func Example_simple() {
@@ -241,6 +242,56 @@ func ExampleClient_Query_do() {
 	}
 }
 
+func ExampleRowIterator_Filter() {
+	// Rows can be transformed as they are read with RowIterator.Filter(), RowIterator.MapRows() and
+	// RowIterator.Limit(), instead of doing it in the function passed to Do().
+
+	kcsb := NewConnectionStringBuilder("endpoint").WithAadAppKey("clientID", "clientSecret", "tenentID")
+
+	client, err := New(kcsb)
+	if err != nil {
+		panic("add error handling")
+	}
+	// Be sure to close the client when you're done. (Error handling omitted for brevity.)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	iter, err := client.Query(ctx, "database", NewStmt("systemNodes | project CollectionTime, NodeId"))
+	if err != nil {
+		panic("add error handling")
+	}
+	defer iter.Stop()
+
+	// Keep the first 10 rows with an even NodeId, and only print their NodeId. Once 10 rows were read, the query is
+	// stopped.
+	pipeline := iter.
+		Filter(func(row *table.Row) (bool, error) {
+			id, ok := row.Values[1].(value.Long)
+			if !ok {
+				return false, fmt.Errorf("NodeId is a %T, not a long", row.Values[1])
+			}
+			return id.Valid && id.Value%2 == 0, nil
+		}).
+		MapRows(func(row *table.Row) (*table.Row, error) {
+			// The row is modified in place, which avoids a copy.
+			row.Values = row.Values[1:]
+			row.ColumnTypes = row.ColumnTypes[1:]
+			return row, nil
+		}).
+		Limit(10)
+
+	err = pipeline.Do(
+		func(row *table.Row) error {
+			fmt.Println(row.Values[0])
+			return nil
+		},
+	)
+	if err != nil {
+		panic("add error handling")
+	}
+}
+
 func ExampleClient_Query_struct() {
 	// Capture our values into a struct and sends those values into a channel. Normally this would be done between
 	// a couple of functions representing a sender and a receiver.
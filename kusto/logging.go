@@ -0,0 +1,60 @@
+package kusto
+
+// logging.go implements WithLogger(), the hook through which the client reports conditions that are not errors.
+
+import "fmt"
+
+// LogLevel is the severity of a message passed to a Logger.
+type LogLevel int
+
+const (
+	// LogDebug is the level of messages that are only useful to debug the client.
+	LogDebug LogLevel = iota
+	// LogInfo is the level of informational messages.
+	LogInfo
+	// LogWarn is the level of conditions the caller may want to act on, such as the warnings sent by the service.
+	LogWarn
+	// LogError is the level of errors that could not be returned to the caller.
+	LogError
+)
+
+// String implements fmt.Stringer.
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarn:
+		return "WARN"
+	case LogError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// Logger receives the messages logged by a Client. It may be called concurrently from several goroutines.
+type Logger func(level LogLevel, msg string)
+
+// WithLogger sets the Logger that receives the messages of the client. By default, nothing is logged.
+func WithLogger(l Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// log calls l if it is set.
+func (l Logger) log(level LogLevel, format string, args ...interface{}) {
+	if l != nil {
+		l(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// warnUnsafe logs a warning when query holds text that is not injection protected, see NewUnsafeStmt(), unless its
+// unsafe warning was suppressed.
+func (c *Client) warnUnsafe(kind CallKind, query Stmt) {
+	if !query.unsafeText || query.unsafe.SuppressWarning {
+		return
+	}
+	c.logger.log(LogWarn, "%s() is running a Stmt built with unsafe text: %s", kind, query.String())
+}
@@ -2,14 +2,15 @@ package kusto
 
 import (
 	"time"
-
-	"github.com/Azure/azure-kusto-go/kusto/data/errors"
-	"github.com/Azure/azure-kusto-go/kusto/data/value"
 )
 
 type mgmtOptions struct {
 	requestProperties *requestProperties
 	queryIngestion    bool
+	// timeoutHeadroom is subtracted from the time left before the context deadline to derive the servertimeout.
+	timeoutHeadroom time.Duration
+	// userAssertion is the token of the user the command is run for, set by MgmtOnBehalfOf().
+	userAssertion string
 }
 
 // Deprecated: Writing mode is now the default. Use the `RequestReadonly` option to make a read-only request.
@@ -20,23 +21,12 @@ func AllowWrite() MgmtOption {
 }
 
 // IngestionEndpoint will instruct the Mgmt call to connect to the ingest-[endpoint] instead of [endpoint].
-// This is not often used by end users and can only be used with a Mgmt() call.
+// This is not often used by end users and can only be used with a Mgmt() call. The ingestion endpoint is the one of
+// ToIngestionEndpoint(), so the call fails with an *AmbiguousEndpointError for an endpoint that has none under the
+// domains of Kusto.
 func IngestionEndpoint() MgmtOption {
 	return func(m *mgmtOptions) error {
 		m.queryIngestion = true
 		return nil
 	}
 }
-
-// mgmtServerTimeout is the amount of time the server will allow a call to take.
-// NOTE: I have made the serverTimeout private. For the moment, I'm going to use the context.Context timer
-// to set timeouts via this private method.
-func mgmtServerTimeout(d time.Duration) MgmtOption {
-	return func(m *mgmtOptions) error {
-		if d > 1*time.Hour {
-			return errors.ES(errors.OpQuery, errors.KClientArgs, "ServerTimeout option was set to %v, but can't be more than 1 hour", d)
-		}
-		m.requestProperties.Options["servertimeout"] = value.Timespan{Valid: true, Value: d}.Marshal()
-		return nil
-	}
-}
@@ -11,7 +11,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
-	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
 	v1 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v1"
 )
 
@@ -32,6 +32,8 @@ func newColumnMap(cols table.Columns) columnMap {
 
 // MockRows provides the abilty to provide mocked Row data that can be played back from a RowIterator.
 // This allows for creating hermetic tests from mock data or creating mock data from a real data fetch.
+// The values of the rows are built with the constructors of the value package, such as value.NewLong() and
+// value.NullString(), and a RowIterator replaying the expected rows can be compared to another with DiffIterators().
 type MockRows struct {
 	columns table.Columns
 	// playback is the list of data we are going to return to the RowIterator.
@@ -121,8 +123,12 @@ func (m *MockRows) Error(err error) error {
 type mockConn struct {
 }
 
-func (m mockConn) queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (string, error) {
-	return "[]]", nil
+func (m mockConn) queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (jsonResp, error) {
+	return jsonResp{body: "[]]"}, nil
+}
+
+func (m mockConn) mgmtToJson(ctx context.Context, db string, query Stmt, options *mgmtOptions) (jsonResp, error) {
+	return jsonResp{body: `{"Tables":[]}`}, nil
 }
 
 func (m mockConn) Close() error {
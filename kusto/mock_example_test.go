@@ -20,12 +20,6 @@ import (
 // What would be in our package
 /*****************************************/
 
-// querier provides a single method, Query(), which is used to query Kusto for some information.
-// This can be substituted for our fake during tests.
-type querier interface {
-	Query(context.Context, string, kusto.Stmt, ...kusto.QueryOption) (*kusto.RowIterator, error)
-}
-
 // NodeRec represents our Kusto data that will be returned.
 type NodeRec struct {
 	// ID is the table's NodeId. We use the field tag here to to instruct our client to convert NodeId to ID.
@@ -37,11 +31,11 @@ type NodeRec struct {
 // NodeInfo is the type we are going to test.
 type NodeInfo struct {
 	stmt    kusto.Stmt
-	querier querier // This can be a fakeQuerier or *kusto.Client
+	querier kusto.Querier // This can be a fakeQuerier or *kusto.Client
 }
 
 // New is the constructor for NodeInfo.
-func New(client *kusto.Client) (*NodeInfo, error) {
+func New(client kusto.Querier) (*NodeInfo, error) {
 	return &NodeInfo{
 		querier: client,
 		stmt: kusto.NewStmt("systemNodes | project CollectionTime, NodeId | where NodeId == ParamNodeId").MustDefinitions(
@@ -83,13 +77,13 @@ func (n *NodeInfo) Node(ctx context.Context, id int64) (NodeRec, error) {
 // What would be in our _test.go file
 /*****************************************/
 
-// fakeQuerier implements querier.querier so we can do hermetic testing.
+// fakeQuerier implements kusto.Querier so we can do hermetic testing.
 type fakeQuerier struct {
 	mock        *kusto.MockRows
 	expectQuery string
 }
 
-// Query implements querier.querier.
+// Query implements kusto.Querier.
 func (f *fakeQuerier) Query(_ context.Context, _ string, passedQuery kusto.Stmt, _ ...kusto.QueryOption) (*kusto.RowIterator, error) {
 	if passedQuery.String() != f.expectQuery {
 		panic("we expect the query to be " + f.expectQuery)
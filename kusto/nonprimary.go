@@ -0,0 +1,82 @@
+package kusto
+
+// nonprimary.go implements RowIterator.NonPrimary(), which returns all the non-primary tables of a result.
+
+import (
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+)
+
+// NonPrimaryKey identifies a non-primary table by its kind and name, such as frames.QueryProperties and
+// frames.ExtendedProperties.
+type NonPrimaryKey struct {
+	Kind frames.TableKind
+	Name frames.TableKind
+}
+
+// NonPrimaryTable is a table of a result that is not a primary result, such as the extended properties, the query
+// status or the table of contents that Mgmt() commands return along with their results.
+type NonPrimaryTable struct {
+	// Ordinal is the position of the table in the response.
+	Ordinal int
+	// Kind is the kind of the table.
+	Kind frames.TableKind
+	// Name is the name of the table.
+	Name frames.TableKind
+	// Columns are the columns of the table.
+	Columns table.Columns
+	// RowErrors are the errors the service sent in place of rows.
+	RowErrors []errors.Error
+
+	values []value.Values
+	op     errors.Op
+
+	once sync.Once
+	rows []*table.Row
+}
+
+func newNonPrimaryTable(dt frames.DataTable) *NonPrimaryTable {
+	return &NonPrimaryTable{
+		Ordinal:   dt.TableID,
+		Kind:      dt.TableKind,
+		Name:      dt.TableName,
+		Columns:   dt.Columns,
+		RowErrors: dt.RowErrors,
+		values:    dt.KustoRows,
+		op:        dt.Op,
+	}
+}
+
+// Rows returns the rows of the table. They are only built on the first call, so that the tables that are never looked
+// at cost no more than their values.
+func (t *NonPrimaryTable) Rows() []*table.Row {
+	t.once.Do(func() {
+		t.rows = make([]*table.Row, len(t.values))
+		for i, values := range t.values {
+			t.rows[i] = &table.Row{ColumnTypes: t.Columns, Values: values, Op: t.op}
+		}
+	})
+	return t.rows
+}
+
+// NonPrimary returns the non-primary tables received so far, by kind and name. It has all the tables once the
+// RowIterator reached io.EOF. When several tables have the same kind and name, the last one is returned.
+// Returns NonPrimarySuppressedErr if the query was made with the PrimaryResultsOnly() option.
+func (r *RowIterator) NonPrimary() (map[NonPrimaryKey]*NonPrimaryTable, error) {
+	if r.primaryResultsOnly {
+		return nil, NonPrimarySuppressedErr
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tables := make(map[NonPrimaryKey]*NonPrimaryTable, len(r.nonPrimaryTables))
+	for _, dt := range r.nonPrimaryTables {
+		tables[NonPrimaryKey{Kind: dt.TableKind, Name: dt.TableName}] = newNonPrimaryTable(dt)
+	}
+	return tables, nil
+}
@@ -0,0 +1,112 @@
+package kusto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonPrimary(t *testing.T) {
+	t.Parallel()
+
+	// The table of contents does not list the tables in their order.
+	const withTOC = `{"Tables": [` +
+		`{"TableName": "Table_0", "Columns": [{"ColumnName": "OperationId", "DataType": "String"}], "Rows": [["op1"]]},` +
+		`{"TableName": "Table_1", "Columns": [{"ColumnName": "Value", "DataType": "String"}], "Rows": [["{\"Visualization\":null}"]]},` +
+		`{"TableName": "Table_2", "Columns": [{"ColumnName": "Timestamp", "DataType": "DateTime"}, {"ColumnName": "Severity", "DataType": "Int32"},` +
+		` {"ColumnName": "StatusDescription", "DataType": "String"}], "Rows": [["2024-01-01T00:00:00Z", 4, "Query completed successfully"]]},` +
+		`{"TableName": "Table_3", "Columns": [{"ColumnName": "Ordinal", "DataType": "Int64"}, {"ColumnName": "Kind", "DataType": "String"},` +
+		` {"ColumnName": "Name", "DataType": "String"}, {"ColumnName": "Id", "DataType": "String"}, {"ColumnName": "PrettyName", "DataType": "String"}],` +
+		` "Rows": [[2, "QueryStatus", "QueryStatus", "id2", ""], [0, "QueryResult", "PrimaryResult", "id0", ""],` +
+		` [1, "QueryProperties", "@ExtendedProperties", "id1", ""]]}` +
+		`]}`
+	const single = `{"Tables": [{"TableName": "Table_0", "Columns": [{"ColumnName": "OperationId", "DataType": "String"}], "Rows": [["op1"]]}]}`
+
+	tests := []struct {
+		desc string
+		body string
+		want map[NonPrimaryKey][]value.Values
+		// wantOrdinals are the ordinals of the tables in the response.
+		wantOrdinals map[NonPrimaryKey]int
+	}{
+		{
+			desc: "Table of contents",
+			body: withTOC,
+			want: map[NonPrimaryKey][]value.Values{
+				{Kind: frames.QueryProperties, Name: frames.ExtendedProperties}: {
+					{value.NewString(`{"Visualization":null}`)},
+				},
+				{Kind: frames.QueryStatus, Name: frames.QueryStatus}: {
+					{value.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)), value.NewInt(4), value.NewString("Query completed successfully")},
+				},
+				{Kind: frames.TableOfContents, Name: frames.TableOfContents}: {
+					{value.NewLong(2), value.NewString("QueryStatus"), value.NewString("QueryStatus"), value.NewString("id2"), value.NewString("")},
+					{value.NewLong(0), value.NewString("QueryResult"), value.NewString("PrimaryResult"), value.NewString("id0"), value.NewString("")},
+					{value.NewLong(1), value.NewString("QueryProperties"), value.NewString("@ExtendedProperties"), value.NewString("id1"), value.NewString("")},
+				},
+			},
+			wantOrdinals: map[NonPrimaryKey]int{
+				{Kind: frames.QueryProperties, Name: frames.ExtendedProperties}: 1,
+				{Kind: frames.QueryStatus, Name: frames.QueryStatus}:            2,
+				{Kind: frames.TableOfContents, Name: frames.TableOfContents}:    3,
+			},
+		},
+		{
+			desc: "Single table",
+			body: single,
+			want: map[NonPrimaryKey][]value.Values{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &mgmtJSONTransport{body: test.body}
+			client := newTestClient(t, "https://mgmt.kusto.windows.net", transport)
+
+			iter, err := client.Mgmt(context.Background(), "db", NewStmt(".show operations"))
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			var primary []string
+			require.NoError(t, iter.Do(func(row *table.Row) error {
+				primary = append(primary, row.Values[0].String())
+				return nil
+			}))
+			assert.Equal(t, []string{"op1"}, primary)
+
+			got, err := iter.NonPrimary()
+			require.NoError(t, err)
+
+			gotValues := map[NonPrimaryKey][]value.Values{}
+			for key, tbl := range got {
+				assert.Equal(t, key, NonPrimaryKey{Kind: tbl.Kind, Name: tbl.Name})
+				assert.Equal(t, test.wantOrdinals[key], tbl.Ordinal)
+				for _, row := range tbl.Rows() {
+					assert.Equal(t, tbl.Columns, row.ColumnTypes)
+					gotValues[key] = append(gotValues[key], row.Values)
+				}
+			}
+			assert.Equal(t, test.want, gotValues)
+
+			if status, ok := got[NonPrimaryKey{Kind: frames.QueryStatus, Name: frames.QueryStatus}]; ok {
+				assert.Equal(t, table.Columns{
+					{Name: "Timestamp", Type: types.DateTime},
+					{Name: "Severity", Type: types.Int},
+					{Name: "StatusDescription", Type: types.String},
+				}, status.Columns)
+				// The rows are only built once.
+				assert.Same(t, status.Rows()[0], status.Rows()[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package kusto
+
+// offload.go implements OffloadLargeParameters(), which moves large query parameters from the request properties into
+// let statements in the query text.
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+)
+
+// OffloadLargeParameters sends the query parameters whose value is longer than threshold bytes, such as large dynamic
+// arrays used with in(), as let statements in front of the query text instead of in the request properties. Some
+// gateways limit the size of the request properties. The names of the parameters are bound by the let statements, so
+// the query uses them unchanged. The values are escaped as literals, so they can come from untrusted input.
+// The rewritten statement is the one sent: it is the one seen by WithStatementInterceptor() and in errors.
+// threshold must be more than 0.
+func OffloadLargeParameters(threshold int) QueryOption {
+	return func(q *queryOptions) error {
+		if threshold <= 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "OffloadLargeParameters option was set to %d, but must be more than 0", threshold)
+		}
+		q.offloadThreshold = threshold
+		return nil
+	}
+}
+
+// offloadParameters returns query with the parameters in props that are longer than threshold moved to let
+// statements in front of its text, and removes them from the Definitions of the query and from props.
+func offloadParameters(query Stmt, props *requestProperties, threshold int) Stmt {
+	if threshold <= 0 {
+		return query
+	}
+
+	var names []string
+	for name, v := range props.Parameters {
+		if len(v) > threshold {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return query
+	}
+	sort.Strings(names)
+
+	defs := query.defs.clone()
+	params := query.params.clone()
+	remaining := make(map[string]string, len(props.Parameters)-len(names))
+	for k, v := range props.Parameters {
+		remaining[k] = v
+	}
+
+	build := strings.Builder{}
+	for _, name := range names {
+		literal := remaining[name]
+		// String parameters are sent as their raw value, all the other types are sent as literals.
+		if defs.m[name].Type == types.String {
+			literal = table.QuoteString(literal)
+		}
+		build.WriteString("let " + name + " = " + literal + ";\n")
+
+		delete(defs.m, name)
+		delete(params.m, name)
+		delete(params.outM, name)
+		delete(remaining, name)
+	}
+
+	query.defs = defs
+	query.params = params
+	query.queryStr = build.String() + query.queryStr
+	props.Parameters = remaining
+	return query
+}
@@ -0,0 +1,229 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoTransport is a fake http.RoundTripper that answers a query with the value each name of the query is bound to,
+// either by a let statement or by a declared query parameter, and with the rest of the query text.
+type echoTransport struct {
+	mu   sync.Mutex
+	msgs []queryMsg
+}
+
+func (e *echoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") || strings.HasSuffix(req.URL.Path, "/auth/metadata") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.msgs = append(e.msgs, msg)
+	e.mu.Unlock()
+
+	bound := map[string]string{}
+	var query []string
+	for _, line := range strings.Split(msg.CSL, "\n") {
+		switch {
+		case strings.HasPrefix(line, "declare query_parameters("):
+			decls := strings.TrimSuffix(strings.TrimPrefix(line, "declare query_parameters("), ");")
+			for _, decl := range strings.Split(decls, ", ") {
+				name := strings.SplitN(decl, ":", 2)[0]
+				bound[name] = msg.Properties.Parameters[name]
+			}
+		case strings.HasPrefix(line, "let "):
+			decl := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(line, "let "), ";"), " = ", 2)
+			literal := decl[1]
+			if strings.HasPrefix(literal, `"`) {
+				s, err := strconv.Unquote(literal)
+				if err != nil {
+					return nil, err
+				}
+				literal = s
+			}
+			bound[decl[0]] = literal
+		default:
+			query = append(query, line)
+		}
+	}
+
+	names := make([]string, 0, len(bound))
+	for name := range bound {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := [][]string{{"", strings.Join(query, "\n")}}
+	for _, name := range names {
+		rows = append(rows, []string{name, bound[name]})
+	}
+	b, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"Name","ColumnType":"string"},{"ColumnName":"Value","ColumnType":"string"}],"Rows":` + string(b) + `},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (e *echoTransport) sent() []queryMsg {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]queryMsg(nil), e.msgs...)
+}
+
+func TestOffloadLargeParameters(t *testing.T) {
+	t.Parallel()
+
+	ids := make([]int64, 100)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	root := NewStmt("T | where Name == name and Id in (ids) and Flag == flag").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{
+			"name": ParamType{Type: types.String},
+			"ids":  ParamType{Type: types.Dynamic},
+			"flag": ParamType{Type: types.Bool},
+		}),
+	)
+	longName := strings.Repeat(`a"b\c`, 20) + "\n\t\x01é"
+
+	tests := []struct {
+		desc      string
+		stmt      Stmt
+		threshold int
+		// wantOffloaded are the parameters sent as let statements.
+		wantOffloaded []string
+	}{
+		{
+			desc:      "Nothing above the threshold",
+			stmt:      root.MustParameters(NewParameters().Must(QueryValues{"name": "a", "ids": ids[:2], "flag": true})),
+			threshold: 1000,
+		},
+		{
+			desc:          "Dynamic",
+			stmt:          root.MustParameters(NewParameters().Must(QueryValues{"name": "a", "ids": ids, "flag": true})),
+			threshold:     100,
+			wantOffloaded: []string{"ids"},
+		},
+		{
+			desc:          "String with characters to escape",
+			stmt:          root.MustParameters(NewParameters().Must(QueryValues{"name": longName, "ids": ids[:2], "flag": true})),
+			threshold:     50,
+			wantOffloaded: []string{"name"},
+		},
+		{
+			desc:          "All",
+			stmt:          root.MustParameters(NewParameters().Must(QueryValues{"name": longName, "ids": ids, "flag": true})),
+			threshold:     1,
+			wantOffloaded: []string{"flag", "ids", "name"},
+		},
+		{
+			desc:          "AddInList",
+			stmt:          NewStmt("T | where Id ").MustAddInList("ids", ids, InListThreshold(10)),
+			threshold:     100,
+			wantOffloaded: []string{"ids"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &echoTransport{}
+			client := newTestClient(t, "https://offload.kusto.windows.net", transport)
+
+			query := func(options ...QueryOption) [][]string {
+				iter, err := client.Query(context.Background(), "db", test.stmt, options...)
+				require.NoError(t, err)
+				defer iter.Stop()
+
+				var got [][]string
+				err = iter.Do(func(row *table.Row) error {
+					got = append(got, []string{row.Values[0].String(), row.Values[1].String()})
+					return nil
+				})
+				require.NoError(t, err)
+				return got
+			}
+
+			want := query()
+			got := query(OffloadLargeParameters(test.threshold))
+			assert.Equal(t, want, got)
+
+			sent := transport.sent()
+			require.Len(t, sent, 2)
+			assert.Equal(t, test.stmt.String(), sent[0].CSL)
+			for _, name := range test.wantOffloaded {
+				_, ok := sent[1].Properties.Parameters[name]
+				assert.False(t, ok, "parameter %q was not offloaded", name)
+				assert.Contains(t, sent[1].CSL, "let "+name+" = ")
+			}
+			assert.Len(t, sent[1].Properties.Parameters, len(sent[0].Properties.Parameters)-len(test.wantOffloaded))
+			if len(test.wantOffloaded) == 0 {
+				assert.Equal(t, sent[0].CSL, sent[1].CSL)
+				assert.Equal(t, sent[0].Properties.Parameters, sent[1].Properties.Parameters)
+			}
+
+			// The offloaded parameters are also left out of the properties that are resolved.
+			resolved, err := ResolveQueryOptions(context.Background(), test.stmt, OffloadLargeParameters(test.threshold))
+			require.NoError(t, err)
+			assert.Equal(t, sent[1].Properties.Parameters, resolved.Parameters)
+		})
+	}
+}
+
+func TestOffloadLargeParametersStatement(t *testing.T) {
+	t.Parallel()
+
+	stmt := NewStmt("T | where Name == name and Id in (ids)").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{
+			"name": ParamType{Type: types.String},
+			"ids":  ParamType{Type: types.Dynamic},
+		}),
+	).MustParameters(NewParameters().Must(QueryValues{"name": `x"y`, "ids": []int64{1, 2, 3}}))
+
+	var got CallInfo
+	transport := &recordTransport{}
+	client := newTestClient(t, "https://offload.kusto.windows.net", transport)
+	WithStatementInterceptor(func(ctx context.Context, info CallInfo) (CallInfo, error) {
+		got = info
+		return info, nil
+	})(client)
+
+	iter, err := client.Query(context.Background(), "db", stmt, OffloadLargeParameters(10))
+	require.NoError(t, err)
+	iter.Stop()
+
+	// The interceptors see the statement that is sent.
+	want := "declare query_parameters(name:string);\nlet ids = dynamic([1,2,3]);\nT | where Name == name and Id in (ids)"
+	assert.Equal(t, want, got.Statement)
+	assert.Equal(t, map[string]string{"name": `x"y`}, got.Parameters)
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, want, sent[0].CSL)
+
+	for _, threshold := range []int{0, -1} {
+		_, err := client.Query(context.Background(), "db", stmt, OffloadLargeParameters(threshold))
+		assert.Error(t, err, "OffloadLargeParameters(%d)", threshold)
+	}
+}
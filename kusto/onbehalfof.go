@@ -0,0 +1,167 @@
+package kusto
+
+// onbehalfof.go implements the OAuth on-behalf-of flow of WithApplicationTokenCredentialOnBehalfOf(), which azidentity
+// does not provide in the version this module uses.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// defaultOnBehalfOfTenant is the tenant of the on-behalf-of exchange when the builder has no AuthorityId.
+const defaultOnBehalfOfTenant = "organizations"
+
+// OnBehalfOf sets the token of the user the query is made for, when the Client authenticates with
+// WithApplicationTokenCredentialOnBehalfOf(): it is exchanged for a token of the cluster, as the user, so that one Client
+// serves many users. It replaces the user assertion of the builder, if any. The tokens are cached per user assertion,
+// and the queries made on behalf of a user are never deduplicated with, or served from the results cache of, other
+// queries. Pass it with ContextWithQueryOptions() to set it once per incoming request.
+// It only applies to queries, use MgmtOnBehalfOf() for management commands.
+func OnBehalfOf(userAssertion string) QueryOption {
+	return func(q *queryOptions) error {
+		q.userAssertion = userAssertion
+		return nil
+	}
+}
+
+// MgmtOnBehalfOf is OnBehalfOf() for a call to Mgmt() or MgmtToJson(): the command is run as the user of
+// userAssertion.
+func MgmtOnBehalfOf(userAssertion string) MgmtOption {
+	return func(m *mgmtOptions) error {
+		m.userAssertion = userAssertion
+		return nil
+	}
+}
+
+// userAssertionKey is the context key of the user assertion set with OnBehalfOf().
+type userAssertionKey struct{}
+
+// withUserAssertion returns ctx carrying assertion for the onBehalfOfCredential, or ctx if assertion is empty.
+func withUserAssertion(ctx context.Context, assertion string) context.Context {
+	if isEmpty(assertion) {
+		return ctx
+	}
+	return context.WithValue(ctx, userAssertionKey{}, assertion)
+}
+
+// onBehalfOfCredential is an azcore.TokenCredential that exchanges the token of a user for a token of the cluster, as
+// the user. The tokens are cached by the hash of the user assertion and the scopes, so that a user never gets the
+// token of another one, and the assertions are not kept once their tokens expire.
+type onBehalfOfCredential struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	// assertion is the user assertion of the builder, "" if it is passed per query with OnBehalfOf().
+	assertion string
+	http      func() *http.Client
+
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]azcore.AccessToken
+}
+
+func newOnBehalfOfCredential(loginEndpoint, tenantID, clientID, clientSecret, assertion string, http func() *http.Client) *onBehalfOfCredential {
+	if isEmpty(tenantID) {
+		tenantID = defaultOnBehalfOfTenant
+	}
+	return &onBehalfOfCredential{
+		tokenURL:     strings.TrimRight(loginEndpoint, "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token",
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		assertion:    assertion,
+		http:         http,
+		tokens:       map[[sha256.Size]byte]azcore.AccessToken{},
+	}
+}
+
+// GetToken implements azcore.TokenCredential, for the user assertion carried by ctx, or else the one of the builder.
+func (c *onBehalfOfCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	assertion := c.assertion
+	if a, ok := ctx.Value(userAssertionKey{}).(string); ok {
+		assertion = a
+	}
+	if isEmpty(assertion) {
+		return azcore.AccessToken{}, fmt.Errorf("error: no user assertion for the on-behalf-of flow, pass it with OnBehalfOf()")
+	}
+
+	key := sha256.Sum256([]byte(assertion + "\x00" + strings.Join(opts.Scopes, " ")))
+	now := nower()
+	c.mu.Lock()
+	token, ok := c.tokens[key]
+	c.mu.Unlock()
+	if ok && now.Before(token.ExpiresOn.Add(-DefaultTokenRefreshSkew)) {
+		return token, nil
+	}
+
+	token, err := c.exchange(ctx, assertion, opts.Scopes)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, t := range c.tokens {
+		if !now.Before(t.ExpiresOn) {
+			delete(c.tokens, k)
+		}
+	}
+	c.tokens[key] = token
+	return token, nil
+}
+
+// exchange requests a token for scopes on behalf of the user of assertion.
+func (c *onBehalfOfCredential) exchange(ctx context.Context, assertion string, scopes []string) (azcore.AccessToken, error) {
+	form := url.Values{
+		"grant_type":          {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"client_id":           {c.clientID},
+		"client_secret":       {c.clientSecret},
+		"assertion":           {assertion},
+		"scope":               {strings.Join(scopes, " ")},
+		"requested_token_use": {"on_behalf_of"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	requested := nower()
+	resp, err := c.http().Do(req)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: on-behalf-of token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: on-behalf-of token request failed: %w", err)
+	}
+
+	var result struct {
+		AccessToken      string          `json:"access_token"`
+		ExpiresIn        json.RawMessage `json:"expires_in"`
+		Error            string          `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: on-behalf-of token request failed with %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || isEmpty(result.AccessToken) {
+		return azcore.AccessToken{}, fmt.Errorf("error: on-behalf-of token request failed with %s: %s: %s", resp.Status, result.Error, result.ErrorDescription)
+	}
+	// expires_in is a number of seconds, sent as a string by some versions of the endpoint.
+	var expiresIn int64
+	if err := json.Unmarshal([]byte(strings.Trim(string(result.ExpiresIn), `"`)), &expiresIn); err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: on-behalf-of token has an invalid expires_in %s", result.ExpiresIn)
+	}
+	return azcore.AccessToken{Token: result.AccessToken, ExpiresOn: requested.Add(time.Duration(expiresIn) * time.Second)}, nil
+}
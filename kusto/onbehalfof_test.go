@@ -0,0 +1,174 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oboTransport is a fake http.RoundTripper for the cloud metadata, the AAD token endpoint and the cluster, which
+// answers the on-behalf-of exchanges with a token named after the user assertion.
+type oboTransport struct {
+	captureTransport
+
+	mu        sync.Mutex
+	exchanges []url.Values
+	// fail is the error the token endpoint returns, "" to succeed.
+	fail string
+}
+
+func (o *oboTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	respond := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/auth/metadata"):
+		return respond(http.StatusOK, fmt.Sprintf(`{"AzureAD": {"LoginEndpoint": "https://login.microsoftonline.com", "KustoServiceResourceId": "%s://%s"}}`,
+			req.URL.Scheme, req.URL.Host)), nil
+	case req.URL.Host == "login.microsoftonline.com":
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+		o.mu.Lock()
+		o.exchanges = append(o.exchanges, req.PostForm)
+		o.mu.Unlock()
+		if o.fail != "" {
+			return respond(http.StatusBadRequest, `{"error": "invalid_grant", "error_description": "`+o.fail+`"}`), nil
+		}
+		return respond(http.StatusOK, `{"token_type": "Bearer", "expires_in": "3600", "access_token": "token-for-`+req.PostForm.Get("assertion")+`"}`), nil
+	}
+	return o.captureTransport.RoundTrip(req)
+}
+
+func (o *oboTransport) assertions() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var assertions []string
+	for _, e := range o.exchanges {
+		assertions = append(assertions, e.Get("assertion"))
+	}
+	return assertions
+}
+
+func TestOnBehalfOf(t *testing.T) {
+	t.Parallel()
+
+	query := func(t *testing.T, ctx context.Context, client *Client, options ...QueryOption) error {
+		iter, err := client.Query(ctx, "db", NewStmt("T"), options...)
+		if err != nil {
+			return err
+		}
+		iter.Stop()
+		return nil
+	}
+
+	t.Run("Per query", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &oboTransport{}
+		kcsb := NewConnectionStringBuilder("https://obo-per-query.kusto.windows.net")
+		kcsb.AuthorityId = "tenant"
+		client, err := New(kcsb.WithApplicationTokenCredentialOnBehalfOf("app", "secret", ""),
+			WithHttpClient(&http.Client{Transport: transport}), WithClientResultsCache(1<<20, time.Minute))
+		require.NoError(t, err)
+		defer client.Close()
+
+		ctx := context.Background()
+		require.NoError(t, query(t, ctx, client, OnBehalfOf("alice"), Cacheable()))
+		require.NoError(t, query(t, ctx, client, OnBehalfOf("bob"), Cacheable()))
+		require.NoError(t, query(t, ContextWithQueryOptions(ctx, OnBehalfOf("alice")), client))
+		assert.Error(t, query(t, ctx, client))
+
+		// Each user gets its own token, exchanged once, and no results are shared.
+		assert.Equal(t, []string{"alice", "bob"}, transport.assertions())
+		var auth []string
+		for _, r := range transport.sent() {
+			auth = append(auth, r.Header.Get("Authorization"))
+		}
+		assert.Equal(t, []string{"Bearer token-for-alice", "Bearer token-for-bob", "Bearer token-for-alice"}, auth)
+
+		exchange := transport.exchanges[0]
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", exchange.Get("grant_type"))
+		assert.Equal(t, "on_behalf_of", exchange.Get("requested_token_use"))
+		assert.Equal(t, "app", exchange.Get("client_id"))
+		assert.Equal(t, "secret", exchange.Get("client_secret"))
+		assert.Equal(t, "https://obo-per-query.kusto.windows.net/.default", exchange.Get("scope"))
+	})
+
+	t.Run("Management commands", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &oboTransport{}
+		client, err := New(NewConnectionStringBuilder("https://obo-mgmt.kusto.windows.net").WithApplicationTokenCredentialOnBehalfOf("app", "secret", ""),
+			WithHttpClient(&http.Client{Transport: transport}))
+		require.NoError(t, err)
+		defer client.Close()
+
+		ctx := context.Background()
+		iter, err := client.Mgmt(ctx, "db", NewStmt(".show tables"), MgmtOnBehalfOf("alice"))
+		require.NoError(t, err)
+		iter.Stop()
+		_, err = client.MgmtToJson(ctx, "db", NewStmt(".show tables"), MgmtOnBehalfOf("bob"))
+		require.NoError(t, err)
+		_, err = client.Mgmt(ctx, "db", NewStmt(".show tables"))
+		assert.Error(t, err)
+
+		assert.Equal(t, []string{"alice", "bob"}, transport.assertions())
+		var auth []string
+		for _, r := range transport.sent() {
+			auth = append(auth, r.Header.Get("Authorization"))
+		}
+		assert.Equal(t, []string{"Bearer token-for-alice", "Bearer token-for-bob"}, auth)
+	})
+
+	t.Run("Builder assertion", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &oboTransport{}
+		client, err := New(NewConnectionStringBuilder("https://obo-builder.kusto.windows.net").WithApplicationTokenCredentialOnBehalfOf("app", "secret", "carol"),
+			WithHttpClient(&http.Client{Transport: transport}))
+		require.NoError(t, err)
+		defer client.Close()
+
+		require.NoError(t, query(t, context.Background(), client))
+		require.NoError(t, query(t, context.Background(), client))
+		require.NoError(t, query(t, context.Background(), client, OnBehalfOf("dave")))
+		assert.Equal(t, []string{"carol", "dave"}, transport.assertions())
+	})
+
+	t.Run("Exchange failure", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &oboTransport{fail: "AADSTS50013: Assertion failed signature validation"}
+		client, err := New(NewConnectionStringBuilder("https://obo-failure.kusto.windows.net").WithApplicationTokenCredentialOnBehalfOf("app", "secret", ""),
+			WithHttpClient(&http.Client{Transport: transport}))
+		require.NoError(t, err)
+		defer client.Close()
+
+		err = query(t, context.Background(), client, OnBehalfOf("eve-secret-token"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "AADSTS50013")
+		assert.NotContains(t, err.Error(), "eve-secret-token")
+		assert.Empty(t, transport.sent())
+	})
+
+	// Queries on behalf of a user are not deduplicated.
+	opts, err := setQueryOptions(context.Background(), 0, NewStmt("T"), nil, Cacheable(), OnBehalfOf("alice"))
+	require.NoError(t, err)
+	assert.True(t, opts.noDedup)
+	assert.False(t, opts.cacheable)
+
+	assert.Panics(t, func() {
+		NewConnectionStringBuilder("https://obo.kusto.windows.net").WithApplicationTokenCredentialOnBehalfOf("app", "", "")
+	})
+}
@@ -0,0 +1,178 @@
+package kusto
+
+// paged.go implements Client.QueryPaged(), which reads the results of a query by pages from a stored query result.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/google/uuid"
+)
+
+// PageRowNumberColumn is the column of the stored query result of QueryPaged() that numbers its rows from 1. It is
+// removed from the rows of the pages.
+const PageRowNumberColumn = "kusto_page_row"
+
+// StoredQueryResultExpiredError is returned by PageIterator.NextPage() when the stored query result the pages are read
+// from no longer exists, because it expired or was dropped. The pages that were not read are lost, and the query must
+// be run again with QueryPaged().
+type StoredQueryResultExpiredError struct {
+	// Name is the name of the stored query result.
+	Name string
+	// Page is the index, from 0, of the page that could not be read.
+	Page int
+	// Err is the error of the service.
+	Err error
+}
+
+// Error implements error.
+func (s *StoredQueryResultExpiredError) Error() string {
+	return fmt.Sprintf("Op(%s): the stored query result %s expired before page %d was read: %s", errors.OpQuery, s.Name, s.Page, s.Err)
+}
+
+// Unwrap returns the error of the service.
+func (s *StoredQueryResultExpiredError) Unwrap() error {
+	return s.Err
+}
+
+// PageIterator reads the pages of the results of Client.QueryPaged(). It is not safe for concurrent use.
+type PageIterator struct {
+	client   *Client
+	db       string
+	name     string
+	pageSize int
+	total    int64
+	options  []QueryOption
+
+	// page is the index of the next page.
+	page int
+	// next is the row number of the first row of the next page.
+	next   int64
+	err    error
+	closed bool
+}
+
+// QueryPaged runs query on database db and stores its results on the service with .set stored_query_result, so that
+// they can be read by pages of pageSize rows with the returned PageIterator. This reads results that are larger than
+// the limits of a single query, such as 500,000 rows or 64 MB. Each page is a query, sent with options.
+//
+// The rows are numbered in the order the query returns them, so the query should sort them for the pages to have a
+// meaningful order. The stored query result is kept on the service until PageIterator.Close() drops it, or until it
+// expires, after 24 hours; reading a page after it expired returns a *StoredQueryResultExpiredError.
+// pageSize must be more than 0.
+func (c *Client) QueryPaged(ctx context.Context, db string, query Stmt, pageSize int, options ...QueryOption) (*PageIterator, error) {
+	if pageSize <= 0 {
+		return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "QueryPaged() pageSize must be more than 0, was %d", pageSize).SetNoRetry()
+	}
+
+	name := "kusto_pages_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	set := query
+	set.queryStr = ".set stored_query_result " + name + " with (previewCount = 0) <|\n" + query.queryStr +
+		"\n| serialize " + PageRowNumberColumn + " = row_number()"
+	iter, err := c.Mgmt(ctx, db, set)
+	if err != nil {
+		return nil, err
+	}
+	err = iter.Do(func(*table.Row) error { return nil })
+	iter.Stop()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PageIterator{client: c, db: db, name: name, pageSize: pageSize, options: options, next: 1}
+	total, err := p.count(ctx)
+	if err != nil {
+		// The stored query result is not needed if it cannot be read.
+		p.Close(ctx)
+		return nil, err
+	}
+	p.total = total
+	return p, nil
+}
+
+// Name returns the name of the stored query result the pages are read from.
+func (p *PageIterator) Name() string {
+	return p.name
+}
+
+// Total returns the number of rows of the results.
+func (p *PageIterator) Total() int64 {
+	return p.total
+}
+
+// NextPage returns a RowIterator over the rows of the next page, which must be stopped, and true. Once all the pages
+// were returned, or if the PageIterator was closed, it returns nil and false.
+// If the stored query result expired, a *StoredQueryResultExpiredError is returned, and then by all the calls that
+// follow.
+func (p *PageIterator) NextPage(ctx context.Context) (*RowIterator, bool, error) {
+	if p.err != nil {
+		return nil, false, p.err
+	}
+	if p.closed || p.next > p.total {
+		return nil, false, nil
+	}
+
+	last := p.next + int64(p.pageSize) - 1
+	stmt := Stmt{queryStr: fmt.Sprintf("stored_query_result(%s)\n| where %s between (%d .. %d)\n| order by %s asc\n| project-away %s",
+		table.QuoteString(p.name), PageRowNumberColumn, p.next, last, PageRowNumberColumn, PageRowNumberColumn)}
+	iter, err := p.client.Query(ctx, p.db, stmt, p.options...)
+	if err != nil {
+		return nil, false, p.expired(err)
+	}
+	p.page++
+	p.next = last + 1
+	return iter, true, nil
+}
+
+// Close drops the stored query result. The pages that were not read can no longer be. Close can be called more than
+// once, and does nothing if the stored query result expired.
+func (p *PageIterator) Close(ctx context.Context) error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.err != nil {
+		return nil
+	}
+
+	iter, err := p.client.Mgmt(ctx, p.db, Stmt{queryStr: ".drop stored_query_result " + p.name})
+	if err != nil {
+		return err
+	}
+	defer iter.Stop()
+	return iter.Do(func(*table.Row) error { return nil })
+}
+
+// count returns the number of rows of the stored query result.
+func (p *PageIterator) count(ctx context.Context) (int64, error) {
+	iter, err := p.client.Query(ctx, p.db, Stmt{queryStr: "stored_query_result(" + table.QuoteString(p.name) + ")\n| count"}, p.options...)
+	if err != nil {
+		return 0, p.expired(err)
+	}
+	defer iter.Stop()
+
+	var total int64
+	err = iter.Do(func(row *table.Row) error {
+		if len(row.Values) > 0 {
+			if count, ok := row.Values[0].(value.Long); ok {
+				total = count.Value
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// expired returns a *StoredQueryResultExpiredError if err is the service not finding the stored query result, the
+// only entity of the page queries, and keeps it for the calls that follow.
+func (p *PageIterator) expired(err error) error {
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	p.err = &StoredQueryResultExpiredError{Name: p.name, Page: p.page, Err: err}
+	return p.err
+}
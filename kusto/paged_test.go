@@ -0,0 +1,167 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedTransport is a fake http.RoundTripper for a stored query result of rows rows, which expires after expireAfter
+// page queries, or never if expireAfter is 0.
+type pagedTransport struct {
+	rows        int
+	expireAfter int
+
+	mu       sync.Mutex
+	commands []string
+	queries  []string
+}
+
+var pageBetween = regexp.MustCompile(`between \((\d+) \.\. (\d+)\)`)
+
+func (p *pagedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	respond := func(body string) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}
+
+	if strings.HasSuffix(req.URL.Path, "/v1/rest/mgmt") {
+		p.commands = append(p.commands, msg.CSL)
+		return respond(`{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"x","DataType":"Int64","ColumnType":"long"}],"Rows":[]}]}`)
+	}
+
+	p.queries = append(p.queries, msg.CSL)
+	if p.expireAfter > 0 && len(p.queries) > p.expireAfter {
+		body := `{"error":{"code":"BadRequest_EntityNotFound","message":"Stored query result 'kusto_pages' was not found",` +
+			`"@type":"Kusto.Data.Exceptions.EntityNotFoundException","@permanent":true}}`
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+
+	columns := `[{"ColumnName":"Count","ColumnType":"long"}]`
+	rows := fmt.Sprintf("[%d]", p.rows)
+	if m := pageBetween.FindStringSubmatch(msg.CSL); m != nil {
+		var first, last int
+		fmt.Sscan(m[1], &first)
+		fmt.Sscan(m[2], &last)
+		columns = `[{"ColumnName":"x","ColumnType":"long"}]`
+		var values []string
+		for i := first; i <= last && i <= p.rows; i++ {
+			values = append(values, fmt.Sprintf("[%d]", i*10))
+		}
+		rows = strings.Join(values, ",")
+	}
+
+	return respond(`[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":` + columns + `,"Rows":[` + rows + `]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`)
+}
+
+func pagedClient(t *testing.T, transport *pagedTransport) *Client {
+	return newTestClient(t, "https://paged.kusto.windows.net", transport)
+}
+
+func TestQueryPaged(t *testing.T) {
+	t.Parallel()
+
+	transport := &pagedTransport{rows: 5}
+	client := pagedClient(t, transport)
+	ctx := context.Background()
+
+	pages, err := client.QueryPaged(ctx, "db", NewStmt("Events | sort by Timestamp asc"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), pages.Total())
+
+	var got [][]int64
+	for {
+		iter, ok, err := pages.NextPage(ctx)
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		var page []int64
+		require.NoError(t, iter.Do(func(row *table.Row) error {
+			page = append(page, row.Values[0].(value.Long).Value)
+			return nil
+		}))
+		iter.Stop()
+		got = append(got, page)
+	}
+	assert.Equal(t, [][]int64{{10, 20}, {30, 40}, {50}}, got)
+
+	require.NoError(t, pages.Close(ctx))
+	require.NoError(t, pages.Close(ctx))
+	_, ok, err := pages.NextPage(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	name := pages.Name()
+	assert.Equal(t, []string{
+		".set stored_query_result " + name + " with (previewCount = 0) <|\nEvents | sort by Timestamp asc\n| serialize kusto_page_row = row_number()",
+		".drop stored_query_result " + name,
+	}, transport.commands)
+	assert.Equal(t, "stored_query_result(\""+name+"\")\n| where kusto_page_row between (3 .. 4)\n| order by kusto_page_row asc\n| project-away kusto_page_row",
+		transport.queries[2])
+}
+
+func TestQueryPagedExpired(t *testing.T) {
+	t.Parallel()
+
+	transport := &pagedTransport{rows: 5, expireAfter: 2}
+	client := pagedClient(t, transport)
+	ctx := context.Background()
+
+	pages, err := client.QueryPaged(ctx, "db", NewStmt("Events"), 3)
+	require.NoError(t, err)
+
+	iter, ok, err := pages.NextPage(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	iter.Stop()
+
+	_, ok, err = pages.NextPage(ctx)
+	assert.False(t, ok)
+	var expired *StoredQueryResultExpiredError
+	require.True(t, goErrors.As(err, &expired), "got %T: %v", err, err)
+	assert.Equal(t, pages.Name(), expired.Name)
+	assert.Equal(t, 1, expired.Page)
+
+	_, _, again := pages.NextPage(ctx)
+	assert.Equal(t, err, again)
+
+	// An expired stored query result is not dropped.
+	require.NoError(t, pages.Close(ctx))
+	assert.Len(t, transport.commands, 1)
+}
+
+func TestQueryPagedPageSize(t *testing.T) {
+	t.Parallel()
+
+	transport := &pagedTransport{rows: 5}
+	_, err := pagedClient(t, transport).QueryPaged(context.Background(), "db", NewStmt("Events"), 0)
+	require.Error(t, err)
+	assert.Empty(t, transport.commands)
+}
@@ -0,0 +1,264 @@
+package kusto
+
+// pipeline.go implements the Filter(), MapRows() and Limit() stages, which transform the rows of a RowIterator as they
+// are read.
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// StageError is returned by a PipelineIterator when the function of one of its stages fails.
+type StageError struct {
+	// Stage is the name of the stage that failed, such as "Filter".
+	Stage string
+	// Index is the position of the stage in the pipeline, the first stage being 0.
+	Index int
+	// Row is the index of the row the stage failed on, counted from the first row the stage received.
+	Row int64
+	// Err is the error returned by the function of the stage.
+	Err error
+}
+
+// Error implements error.
+func (s *StageError) Error() string {
+	return fmt.Sprintf("pipeline stage %d (%s) failed on row %d: %s", s.Index, s.Stage, s.Row, s.Err)
+}
+
+// Unwrap returns the error returned by the function of the stage.
+func (s *StageError) Unwrap() error {
+	return s.Err
+}
+
+// rowSource is what a stage reads its rows from: a RowIterator or a previous stage.
+type rowSource interface {
+	NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error)
+	Stop()
+}
+
+// PipelineIterator is a stage of a pipeline over the rows of a RowIterator, as returned by Filter(), MapRows() and
+// Limit(). Stages are lazy: a row is read from the query when the last stage is asked for one, and rows are passed from
+// stage to stage without being copied.
+// It provides the same methods to read rows as RowIterator, and more stages can be added to it. Stop() stops the
+// query. Inline errors are passed through the stages unchanged. PipelineIterator is not safe for concurrent use.
+type PipelineIterator struct {
+	src         rowSource
+	progressive bool
+	index       int
+	name        string
+	// process returns the row to pass on, or nil to drop it.
+	process func(row *table.Row) (*table.Row, error)
+	// limit is the number of rows a Limit() stage passes on, or -1 for other stages.
+	limit int
+	// passed is the number of rows passed on since the last table.Row.Replace, for Limit().
+	passed int
+	rows   int64
+	// replace is true when a dropped row had table.Row.Replace set, which is then set on the next row passed on.
+	replace bool
+	err     error
+}
+
+// Filter returns a stage that only passes on the rows for which f returns true. If f returns an error, iteration stops
+// and a *StageError is returned.
+// f sees the row as read from the query, or from the previous stage, without a copy: the row may be modified by the
+// stages that come after, so the values kept past the call must be copied.
+func (r *RowIterator) Filter(f func(row *table.Row) (bool, error)) *PipelineIterator {
+	return newFilter(r, r.progressive, 0, f)
+}
+
+// MapRows returns a stage that passes on the row returned by f for each row. f sees the row without a copy, like for
+// Filter(), and may modify it in place and return it, which avoids allocating a new row. If f returns an error or a nil row, iteration stops and a *StageError is
+// returned. The table.Row.Replace flag of the row f is given is kept on the row it returns.
+func (r *RowIterator) MapRows(f func(row *table.Row) (*table.Row, error)) *PipelineIterator {
+	return newMapRows(r, r.progressive, 0, f)
+}
+
+// Limit returns a stage that passes on at most n rows. Once n rows were passed on, the query is stopped and io.EOF is
+// returned, so the rest of the results are not received: GetNonPrimary() and the like will be missing the tables that
+// come after the rows. For progressive queries, the count restarts whenever the results are replaced
+// (table.Row.Replace), and the query is read to its end.
+func (r *RowIterator) Limit(n int) *PipelineIterator {
+	return newLimit(r, r.progressive, 0, n)
+}
+
+// Filter adds a stage to the pipeline, see RowIterator.Filter().
+func (p *PipelineIterator) Filter(f func(row *table.Row) (bool, error)) *PipelineIterator {
+	return newFilter(p, p.progressive, p.index+1, f)
+}
+
+// MapRows adds a stage to the pipeline, see RowIterator.MapRows().
+func (p *PipelineIterator) MapRows(f func(row *table.Row) (*table.Row, error)) *PipelineIterator {
+	return newMapRows(p, p.progressive, p.index+1, f)
+}
+
+// Limit adds a stage to the pipeline, see RowIterator.Limit().
+func (p *PipelineIterator) Limit(n int) *PipelineIterator {
+	return newLimit(p, p.progressive, p.index+1, n)
+}
+
+func newFilter(src rowSource, progressive bool, index int, f func(row *table.Row) (bool, error)) *PipelineIterator {
+	return &PipelineIterator{
+		src:         src,
+		progressive: progressive,
+		index:       index,
+		name:        "Filter",
+		limit:       -1,
+		process: func(row *table.Row) (*table.Row, error) {
+			keep, err := f(row)
+			if err != nil || !keep {
+				return nil, err
+			}
+			return row, nil
+		},
+	}
+}
+
+func newMapRows(src rowSource, progressive bool, index int, f func(row *table.Row) (*table.Row, error)) *PipelineIterator {
+	return &PipelineIterator{
+		src:         src,
+		progressive: progressive,
+		index:       index,
+		name:        "MapRows",
+		limit:       -1,
+		process: func(row *table.Row) (*table.Row, error) {
+			replace := row.Replace
+			out, err := f(row)
+			if err != nil {
+				return nil, err
+			}
+			if out == nil {
+				return nil, fmt.Errorf("MapRows() function returned a nil row")
+			}
+			if replace {
+				out.Replace = true
+			}
+			return out, nil
+		},
+	}
+}
+
+func newLimit(src rowSource, progressive bool, index int, n int) *PipelineIterator {
+	if n < 0 {
+		n = 0
+	}
+	return &PipelineIterator{
+		src:         src,
+		progressive: progressive,
+		index:       index,
+		name:        "Limit",
+		limit:       n,
+		process: func(row *table.Row) (*table.Row, error) {
+			return row, nil
+		},
+	}
+}
+
+// Stop is called to stop any further iteration. It stops the query.
+func (p *PipelineIterator) Stop() {
+	p.src.Stop()
+}
+
+// Do calls f for every row passed on by the stage. If f returns a non-nil error,
+// iteration stops. This method will fail on errors inline within the rows.
+func (p *PipelineIterator) Do(f func(r *table.Row) error) error {
+	for {
+		row, err := p.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row); err != nil {
+			return err
+		}
+	}
+}
+
+// DoOnRowOrError calls f for every row passed on by the stage or inline error. If f returns a non-nil error,
+// iteration stops.
+func (p *PipelineIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error) error {
+	for {
+		row, inlineErr, err := p.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row, inlineErr); err != nil {
+			return err
+		}
+	}
+}
+
+// Next gets the next Row passed on by the stage. io.EOF is returned if there are no more rows.
+// This method will fail on errors inline within the rows.
+func (p *PipelineIterator) Next() (row *table.Row, finalError error) {
+	row, inlineErr, err := p.NextRowOrError()
+	if err != nil {
+		return nil, err
+	}
+	if inlineErr != nil {
+		p.err = inlineErr
+		return nil, inlineErr
+	}
+	return row, nil
+}
+
+// NextRowOrError gets the next Row passed on by the stage or service-side error.
+// Once finalError returns non-nil, all subsequent calls will return the same error.
+func (p *PipelineIterator) NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+
+	for {
+		if p.limit >= 0 && p.passed >= p.limit && !p.progressive {
+			p.src.Stop()
+			p.err = io.EOF
+			return nil, nil, p.err
+		}
+
+		row, inlineErr, err := p.src.NextRowOrError()
+		if err != nil {
+			p.err = err
+			return nil, nil, err
+		}
+		if inlineErr != nil {
+			return nil, inlineErr, nil
+		}
+
+		rowIndex := p.rows
+		p.rows++
+		if row.Replace {
+			p.passed = 0
+			p.replace = false
+		}
+		if p.limit >= 0 && p.passed >= p.limit {
+			// Only progressive queries get here, and the rows past the limit are dropped until the results are
+			// replaced.
+			continue
+		}
+
+		replace := row.Replace
+		out, err := p.process(row)
+		if err != nil {
+			p.err = &StageError{Stage: p.name, Index: p.index, Row: rowIndex, Err: err}
+			return nil, nil, p.err
+		}
+		if out == nil {
+			p.replace = p.replace || replace
+			continue
+		}
+		if p.replace {
+			out.Replace = true
+			p.replace = false
+		}
+		p.passed++
+		return out, nil, nil
+	}
+}
@@ -0,0 +1,267 @@
+package kusto
+
+import (
+	goErrors "errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipelineIterator returns a RowIterator that replays rows, the way a query that is not progressive would.
+func pipelineIterator(t *testing.T, rows ...value.Values) *RowIterator {
+	m, err := NewMockRows(dedupColumns)
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NoError(t, m.Row(row))
+	}
+
+	iter := &RowIterator{op: errors.OpQuery}
+	require.NoError(t, iter.Mock(m))
+	return iter
+}
+
+func evenIDs(row *table.Row) (bool, error) {
+	return row.Values[0].(value.Long).Value%2 == 0, nil
+}
+
+func upperNames(row *table.Row) (*table.Row, error) {
+	name := row.Values[1].(value.String)
+	name.Value = strings.ToUpper(name.Value)
+	row.Values[1] = name
+	return row, nil
+}
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	errFailed := fmt.Errorf("failed")
+	failOn := func(id int64) func(row *table.Row) (bool, error) {
+		return func(row *table.Row) (bool, error) {
+			if row.Values[0].(value.Long).Value == id {
+				return false, errFailed
+			}
+			return true, nil
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		build   func(iter *RowIterator) *PipelineIterator
+		want    []value.Values
+		wantErr error
+		// stopped is true if the query must be stopped by the pipeline.
+		stopped bool
+	}{
+		{
+			desc:  "Filter",
+			build: func(iter *RowIterator) *PipelineIterator { return iter.Filter(evenIDs) },
+			want:  []value.Values{dedupRow(2, "b", true), dedupRow(4, "d", true)},
+		},
+		{
+			desc:  "MapRows",
+			build: func(iter *RowIterator) *PipelineIterator { return iter.MapRows(upperNames) },
+			want: []value.Values{
+				dedupRow(1, "A", true), dedupRow(2, "B", true), dedupRow(3, "C", true), dedupRow(4, "D", true), dedupRow(5, "E", true),
+			},
+		},
+		{
+			desc:    "Limit",
+			build:   func(iter *RowIterator) *PipelineIterator { return iter.Limit(2) },
+			want:    []value.Values{dedupRow(1, "a", true), dedupRow(2, "b", true)},
+			stopped: true,
+		},
+		{
+			desc:    "Limit zero",
+			build:   func(iter *RowIterator) *PipelineIterator { return iter.Limit(0) },
+			stopped: true,
+		},
+		{
+			desc:  "Limit larger than the results",
+			build: func(iter *RowIterator) *PipelineIterator { return iter.Limit(10) },
+			want: []value.Values{
+				dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(3, "c", true), dedupRow(4, "d", true), dedupRow(5, "e", true),
+			},
+		},
+		{
+			desc: "Chained",
+			build: func(iter *RowIterator) *PipelineIterator {
+				return iter.Filter(evenIDs).MapRows(upperNames).Limit(1)
+			},
+			want:    []value.Values{dedupRow(2, "B", true)},
+			stopped: true,
+		},
+		{
+			desc:    "Filter error",
+			build:   func(iter *RowIterator) *PipelineIterator { return iter.Filter(failOn(3)) },
+			want:    []value.Values{dedupRow(1, "a", true), dedupRow(2, "b", true)},
+			wantErr: &StageError{Stage: "Filter", Index: 0, Row: 2, Err: errFailed},
+		},
+		{
+			desc: "Error in a later stage",
+			build: func(iter *RowIterator) *PipelineIterator {
+				return iter.Filter(evenIDs).Filter(failOn(4))
+			},
+			want:    []value.Values{dedupRow(2, "b", true)},
+			wantErr: &StageError{Stage: "Filter", Index: 1, Row: 1, Err: errFailed},
+		},
+		{
+			desc: "MapRows returns a nil row",
+			build: func(iter *RowIterator) *PipelineIterator {
+				return iter.MapRows(func(*table.Row) (*table.Row, error) { return nil, nil })
+			},
+			wantErr: &StageError{Stage: "MapRows", Index: 0, Row: 0, Err: fmt.Errorf("MapRows() function returned a nil row")},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(3, "c", true), dedupRow(4, "d", true), dedupRow(5, "e", true))
+			p := test.build(iter)
+			defer p.Stop()
+
+			var got []value.Values
+			err := p.Do(func(row *table.Row) error {
+				got = append(got, row.Values)
+				return nil
+			})
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.wantErr, err)
+			if test.wantErr != nil {
+				var stageErr *StageError
+				require.True(t, goErrors.As(err, &stageErr))
+				assert.True(t, goErrors.Is(err, stageErr.Err))
+
+				// The error is kept.
+				_, err = p.Next()
+				assert.Equal(t, test.wantErr, err)
+				return
+			}
+			assert.Equal(t, test.stopped, iter.ctx.Err() != nil)
+
+			_, err = p.Next()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestPipelineProgressive(t *testing.T) {
+	t.Parallel()
+
+	inlineErr := errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Some error")
+	fragments := []v2.TableFragment{
+		{
+			KustoRows: []value.Values{dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(4, "d", true)},
+			RowErrors: []errors.Error{*inlineErr},
+		},
+		{
+			// The first row, which replaces the results, is dropped by Filter().
+			KustoRows:         []value.Values{dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(3, "c", true), dedupRow(4, "d", true)},
+			TableFragmentType: "DataReplace",
+		},
+	}
+
+	iter := spoolIterator(dedupColumns, func(send func(fr v2.TableFragment)) {
+		for _, fr := range fragments {
+			send(fr)
+		}
+	})
+	p := iter.Filter(evenIDs).Limit(1)
+	defer p.Stop()
+
+	type result struct {
+		id      int64
+		replace bool
+	}
+	var got []result
+	inlineErrs := 0
+	err := p.DoOnRowOrError(func(row *table.Row, e *errors.Error) error {
+		if e != nil {
+			inlineErrs++
+			return nil
+		}
+		got = append(got, result{id: row.Values[0].(value.Long).Value, replace: row.Replace})
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Limit() restarts its count when the results are replaced, and the query is read to its end.
+	assert.Equal(t, []result{{id: 2}, {id: 2, replace: true}}, got)
+	assert.Equal(t, 1, inlineErrs)
+}
+
+/*
+BenchmarkPipeline/Iterate         	       3	 720286351 ns/op	176853784 B/op	 4004037 allocs/op
+BenchmarkPipeline/PassThrough     	       3	 801514973 ns/op	176854040 B/op	 4004041 allocs/op
+*/
+func BenchmarkPipeline(b *testing.B) {
+	const rows = 1000000
+
+	newIter := func() *RowIterator {
+		return spoolIterator(dedupColumns, func(send func(fr v2.TableFragment)) {
+			for i := 0; i < rows; i += 1000 {
+				fragment := make([]value.Values, 1000)
+				for j := range fragment {
+					fragment[j] = dedupRow(int64(i+j), "name", true)
+				}
+				send(v2.TableFragment{KustoRows: fragment})
+			}
+		})
+	}
+
+	b.Run("Iterate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			iter := newIter()
+			n := 0
+			for {
+				if _, err := iter.Next(); err != nil {
+					if err != io.EOF {
+						b.Fatal(err)
+					}
+					break
+				}
+				n++
+			}
+			if n != rows {
+				b.Fatalf("got %d rows, want %d", n, rows)
+			}
+			iter.Stop()
+		}
+	})
+
+	b.Run("PassThrough", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			iter := newIter()
+			p := iter.
+				Filter(func(*table.Row) (bool, error) { return true, nil }).
+				MapRows(func(row *table.Row) (*table.Row, error) { return row, nil }).
+				Limit(rows)
+			n := 0
+			for {
+				if _, err := p.Next(); err != nil {
+					if err != io.EOF {
+						b.Fatal(err)
+					}
+					break
+				}
+				n++
+			}
+			if n != rows {
+				b.Fatalf("got %d rows, want %d", n, rows)
+			}
+			p.Stop()
+		}
+	})
+}
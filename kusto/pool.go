@@ -0,0 +1,341 @@
+package kusto
+
+// pool.go implements ClientPool, a registry of Clients shared by the callers that use the same cluster with the same
+// identity.
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// DefaultPoolIdleTTL is how long a ClientPool keeps a Client that is not in use, unless PoolIdleTTL() is used.
+const DefaultPoolIdleTTL = 10 * time.Minute
+
+// PoolOption is an optional argument to NewClientPool().
+type PoolOption func(p *ClientPool)
+
+// PoolIdleTTL sets how long a Client that is not in use is kept before being closed. Zero keeps them until the pool
+// is closed or they are evicted by PoolMaxClients().
+func PoolIdleTTL(d time.Duration) PoolOption {
+	return func(p *ClientPool) {
+		if d >= 0 {
+			p.ttl = d
+		}
+	}
+}
+
+// PoolMaxClients caps the number of Clients in the pool. When a new Client is needed and the pool is full, the least
+// recently used Client that is not in use is closed. If they are all in use, Get() returns an error. Zero, the
+// default, does not cap the pool.
+func PoolMaxClients(n int) PoolOption {
+	return func(p *ClientPool) {
+		if n >= 0 {
+			p.max = n
+		}
+	}
+}
+
+// ClientPool shares Clients between the callers that use the same endpoint with the same identity, as a Client is
+// safe for concurrent use and holds the connections and tokens that are worth reusing. It is safe for concurrent use.
+//
+// Every Client returned by Get() must be passed to Release() once the caller is done with it, including the
+// RowIterators of its queries. A Client is never closed while it is in use: it is only closed once it is released by
+// all its callers and then stays unused for the idle TTL, is evicted to make room for another Client, or the pool is
+// closed. Callers must not call Close() on the Clients of a pool.
+type ClientPool struct {
+	ttl       time.Duration
+	max       int
+	now       func() time.Time
+	newClient func(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error)
+
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+	clients map[*Client]*poolEntry
+	// lru holds the *poolEntry, the most recently used first.
+	lru    *list.List
+	closed bool
+	// evictErr holds the errors of closing the Clients that were evicted, which Close() returns.
+	evictErr error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type poolEntry struct {
+	key    string
+	client *Client
+	refs   int
+	// idleSince is when refs dropped to zero.
+	idleSince time.Time
+	elem      *list.Element
+	// clientOptions is kept so that its address, which is part of the key, is not reused while the entry exists.
+	clientOptions *azcore.ClientOptions
+}
+
+// NewClientPool returns a new ClientPool. Close() must be called once it is no longer used.
+func NewClientPool(options ...PoolOption) *ClientPool {
+	p := &ClientPool{
+		ttl:       DefaultPoolIdleTTL,
+		now:       time.Now,
+		newClient: New,
+		entries:   map[string]*poolEntry{},
+		clients:   map[*Client]*poolEntry{},
+		lru:       list.New(),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, o := range options {
+		o(p)
+	}
+
+	if p.ttl > 0 {
+		go p.janitor()
+	} else {
+		close(p.done)
+	}
+	return p
+}
+
+// Get returns the Client of the endpoint and identity of kcsb, creating it with New(kcsb, options...) if the pool
+// does not have one. The options are only used when the Client is created, so callers that need different options
+// for the same endpoint and identity must use different pools. The Client must be passed to Release() when done.
+// The identity of a kcsb with a TokenCallback cannot be told from the builder, so it must use GetWithIdentity().
+func (p *ClientPool) Get(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error) {
+	if kcsb != nil && kcsb.TokenCallback != nil {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "ClientPool.Get() cannot tell the identity of a TokenCallback, use GetWithIdentity()").SetNoRetry()
+	}
+	return p.get(kcsb, "", options...)
+}
+
+// GetWithIdentity is Get() for a kcsb whose identity is not fully described by the builder, such as one with a
+// TokenCallback. identity is a key of the caller naming that identity, such as the tenant and user a token broker
+// returns the tokens of. Clients are only shared by the callers passing the same identity, so it must differ for
+// every identity the callbacks authenticate with.
+func (p *ClientPool) GetWithIdentity(kcsb *ConnectionStringBuilder, identity string, options ...Option) (*Client, error) {
+	if identity == "" {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "ClientPool.GetWithIdentity() requires an identity").SetNoRetry()
+	}
+	return p.get(kcsb, identity, options...)
+}
+
+func (p *ClientPool) get(kcsb *ConnectionStringBuilder, identity string, options ...Option) (*Client, error) {
+	if kcsb == nil {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "ClientPool.Get() cannot be passed a nil *ConnectionStringBuilder").SetNoRetry()
+	}
+	key := poolKey(kcsb, identity)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errors.ES(errors.OpServConn, errors.KClientArgs, "ClientPool is closed").SetNoRetry()
+	}
+
+	if e, ok := p.entries[key]; ok {
+		e.refs++
+		p.lru.MoveToFront(e.elem)
+		return e.client, nil
+	}
+
+	if p.max > 0 && len(p.entries) >= p.max {
+		if !p.evictLRU() {
+			return nil, errors.ES(errors.OpServConn, errors.KLimitsExceeded, "ClientPool has %d clients in use, which is the maximum", p.max)
+		}
+	}
+
+	client, err := p.newClient(kcsb, options...)
+	if err != nil {
+		return nil, err
+	}
+	e := &poolEntry{key: key, client: client, refs: 1, clientOptions: kcsb.ClientOptions}
+	e.elem = p.lru.PushFront(e)
+	p.entries[key] = e
+	p.clients[client] = e
+	return client, nil
+}
+
+// Release returns a Client obtained from Get() to the pool. It must be called once for every call to Get(), after the
+// queries made with the Client are done.
+func (p *ClientPool) Release(client *Client) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.clients[client]
+	if !ok || e.refs == 0 {
+		return errors.ES(errors.OpServConn, errors.KClientArgs, "ClientPool.Release() was passed a Client that is not in use from the pool").SetNoRetry()
+	}
+	e.refs--
+	if e.refs > 0 {
+		return nil
+	}
+	e.idleSince = p.now()
+	p.lru.MoveToFront(e.elem)
+
+	if p.closed {
+		return p.remove(e)
+	}
+	return nil
+}
+
+// Len returns the number of Clients in the pool, in use or not.
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Close closes the Clients that are not in use and stops the pool. The Clients still in use are closed when they are
+// released. Get() fails once the pool is closed. The error includes the errors of closing the Clients that the pool
+// evicted before.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.stop)
+
+	err := p.evictErr
+	for _, e := range p.entries {
+		if e.refs == 0 {
+			err = combineErrors(err, p.remove(e))
+		}
+	}
+	p.mu.Unlock()
+
+	<-p.done
+	return err
+}
+
+// janitor closes the Clients that stayed unused for the idle TTL until the pool is closed.
+func (p *ClientPool) janitor() {
+	defer close(p.done)
+
+	interval := p.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.evictIdle()
+		}
+	}
+}
+
+// evictIdle closes the Clients that have not been used for the idle TTL.
+func (p *ClientPool) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ttl <= 0 {
+		return
+	}
+	now := p.now()
+	// The least recently used entries are at the back of the list.
+	for elem := p.lru.Back(); elem != nil; {
+		e := elem.Value.(*poolEntry)
+		elem = elem.Prev()
+		if e.refs == 0 && now.Sub(e.idleSince) >= p.ttl {
+			p.evictErr = combineErrors(p.evictErr, p.remove(e))
+		}
+	}
+}
+
+// evictLRU closes the least recently used Client that is not in use. It returns false if they are all in use.
+func (p *ClientPool) evictLRU() bool {
+	for elem := p.lru.Back(); elem != nil; elem = elem.Prev() {
+		e := elem.Value.(*poolEntry)
+		if e.refs == 0 {
+			p.evictErr = combineErrors(p.evictErr, p.remove(e))
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes e from the pool and closes its Client. e must not be in use.
+func (p *ClientPool) remove(e *poolEntry) error {
+	delete(p.entries, e.key)
+	delete(p.clients, e.client)
+	p.lru.Remove(e.elem)
+	return e.client.Close()
+}
+
+func combineErrors(err, err2 error) error {
+	switch {
+	case err == nil:
+		return err2
+	case err2 == nil:
+		return err
+	}
+	return errors.GetCombinedError(err, err2)
+}
+
+// poolKey returns the key of the Client for kcsb, which is made of its canonical endpoint and the identity it
+// authenticates with, including the identity passed to GetWithIdentity(). Secrets are hashed, so that they are not
+// kept in the key, but different secrets for the same identity get different Clients.
+func poolKey(kcsb *ConnectionStringBuilder, identity string) string {
+	endpoint := strings.TrimSpace(kcsb.DataSource)
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host := strings.ToLower(u.Hostname())
+		if port := u.Port(); port != "" && !(u.Scheme == "https" && port == "443") && !(u.Scheme == "http" && port == "80") {
+			host += ":" + port
+		}
+		endpoint = strings.ToLower(u.Scheme) + "://" + host + strings.TrimRight(u.Path, "/")
+	}
+
+	secrets := sha256.New()
+	for _, s := range []string{kcsb.Password, kcsb.UserToken, kcsb.ApplicationKey, kcsb.ApplicationCertificate, kcsb.ApplicationCertificatePassword, kcsb.ApplicationToken, kcsb.UserAssertion} {
+		secrets.Write([]byte(strconv.Itoa(len(s))))
+		secrets.Write([]byte{0})
+		secrets.Write([]byte(s))
+	}
+
+	clientOptions := ""
+	if kcsb.ClientOptions != nil {
+		clientOptions = fmt.Sprintf("%p", kcsb.ClientOptions)
+	}
+
+	return strings.Join([]string{
+		endpoint,
+		kcsb.AadUserID,
+		kcsb.ApplicationClientId,
+		strings.ToLower(kcsb.AuthorityId),
+		kcsb.ApplicationCertificateThumbprint,
+		kcsb.ApplicationCertificatePath,
+		strconv.FormatBool(kcsb.SendCertificateChain),
+		strconv.FormatBool(kcsb.OnBehalfOf),
+		strconv.FormatBool(kcsb.AzCli),
+		strconv.FormatBool(kcsb.AzureDeveloperCli),
+		strconv.FormatBool(kcsb.MsiAuthentication),
+		kcsb.ManagedServiceIdentity,
+		kcsb.ManagedIdentityResourceID,
+		strconv.FormatBool(kcsb.InteractiveLogin),
+		kcsb.RedirectURL,
+		strconv.FormatBool(kcsb.DefaultAuth),
+		clientOptions,
+		strconv.FormatBool(kcsb.TokenCallback != nil),
+		identity,
+		strconv.FormatBool(kcsb.AllowInsecureEndpoint),
+		kcsb.ApplicationForTracing,
+		kcsb.UserForTracing,
+		hex.EncodeToString(secrets.Sum(nil)),
+	}, "\x00")
+}
@@ -0,0 +1,408 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poolConn is a fake queryer that records when it is closed, and then returns closeErr. Its queries block until
+// release is closed.
+type poolConn struct {
+	endpoint string
+	closed   int32
+	closeErr error
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (p *poolConn) Close() error {
+	atomic.AddInt32(&p.closed, 1)
+	return p.closeErr
+}
+
+func (p *poolConn) isClosed() bool {
+	return atomic.LoadInt32(&p.closed) > 0
+}
+
+func (p *poolConn) query(ctx context.Context, db string, query Stmt, options *queryOptions) (execResp, error) {
+	if p.started != nil {
+		close(p.started)
+	}
+	if p.release != nil {
+		<-p.release
+	}
+	if p.isClosed() {
+		return execResp{}, fmt.Errorf("query on a closed client")
+	}
+	return execResp{}, fmt.Errorf("done")
+}
+
+func (p *poolConn) mgmt(ctx context.Context, db string, query Stmt, options *mgmtOptions) (execResp, error) {
+	return execResp{}, fmt.Errorf("not implemented")
+}
+
+func (p *poolConn) queryToJson(ctx context.Context, db string, query Stmt, options *queryOptions) (jsonResp, error) {
+	return jsonResp{}, fmt.Errorf("not implemented")
+}
+
+func (p *poolConn) mgmtToJson(ctx context.Context, db string, query Stmt, options *mgmtOptions) (jsonResp, error) {
+	return jsonResp{}, fmt.Errorf("not implemented")
+}
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// testPool returns a ClientPool whose Clients have a poolConn, and the number of Clients it created.
+func testPool(t *testing.T, clock *fakeClock, options ...PoolOption) (*ClientPool, *int32) {
+	p := NewClientPool(options...)
+	t.Cleanup(func() { p.Close() })
+
+	created := new(int32)
+	p.mu.Lock()
+	p.now = clock.Now
+	p.newClient = func(kcsb *ConnectionStringBuilder, options ...Option) (*Client, error) {
+		atomic.AddInt32(created, 1)
+		return &Client{conn: &poolConn{endpoint: kcsb.DataSource}, endpoint: kcsb.DataSource}, nil
+	}
+	p.mu.Unlock()
+	return p, created
+}
+
+func poolConnOf(c *Client) *poolConn {
+	return c.conn.(*poolConn)
+}
+
+func TestPoolKey(t *testing.T) {
+	t.Parallel()
+
+	base := func() *ConnectionStringBuilder {
+		return &ConnectionStringBuilder{DataSource: "https://help.kusto.windows.net", ApplicationClientId: "app", ApplicationKey: "key", AuthorityId: "tenant"}
+	}
+	same := []func(k *ConnectionStringBuilder){
+		func(k *ConnectionStringBuilder) { k.DataSource = "HTTPS://Help.Kusto.Windows.NET/" },
+		func(k *ConnectionStringBuilder) { k.DataSource = "https://help.kusto.windows.net:443" },
+		func(k *ConnectionStringBuilder) { k.AuthorityId = "TENANT" },
+	}
+	different := []func(k *ConnectionStringBuilder){
+		func(k *ConnectionStringBuilder) { k.DataSource = "https://other.kusto.windows.net" },
+		func(k *ConnectionStringBuilder) { k.DataSource = "https://help.kusto.windows.net:8443" },
+		func(k *ConnectionStringBuilder) { k.AuthorityId = "other-tenant" },
+		func(k *ConnectionStringBuilder) { k.ApplicationClientId = "other-app" },
+		func(k *ConnectionStringBuilder) { k.ApplicationKey = "rotated-key" },
+		func(k *ConnectionStringBuilder) { k.ApplicationKey, k.Password = "", "key" },
+		func(k *ConnectionStringBuilder) { k.ApplicationKey, k.ApplicationCertificatePath = "", "cert.pem" },
+		func(k *ConnectionStringBuilder) { k.MsiAuthentication = true },
+		func(k *ConnectionStringBuilder) {
+			k.ApplicationKey, k.TokenCallback = "", func(context.Context, string) (string, error) { return "", nil }
+		},
+		func(k *ConnectionStringBuilder) { k.OnBehalfOf, k.UserAssertion = true, "user" },
+		func(k *ConnectionStringBuilder) { k.ApplicationKey, k.AzureDeveloperCli = "", true },
+		func(k *ConnectionStringBuilder) {
+			k.MsiAuthentication, k.ManagedIdentityResourceID = true, "/subscriptions/sub/msi"
+		},
+		func(k *ConnectionStringBuilder) { k.UserForTracing = "user" },
+		func(k *ConnectionStringBuilder) { k.AllowInsecure(true) },
+	}
+
+	want := poolKey(base(), "")
+	assert.NotContains(t, want, "key\x00", "secrets must not be kept in the key")
+	for i, f := range same {
+		k := base()
+		f(k)
+		assert.Equal(t, want, poolKey(k, ""), "same[%d]", i)
+	}
+	for i, f := range different {
+		k := base()
+		f(k)
+		assert.NotEqual(t, want, poolKey(k, ""), "different[%d]", i)
+	}
+	assert.NotEqual(t, want, poolKey(base(), "tenant-a"))
+	assert.NotEqual(t, poolKey(base(), "tenant-a"), poolKey(base(), "tenant-b"))
+}
+
+func TestClientPoolTokenCallback(t *testing.T) {
+	t.Parallel()
+
+	p, created := testPool(t, &fakeClock{now: time.Now()})
+
+	// The closures of one function literal share their code, so they cannot be told apart by their address.
+	broker := func(tenant string) func(context.Context, string) (string, error) {
+		return func(context.Context, string) (string, error) { return "token of " + tenant, nil }
+	}
+	kcsb := func(tenant string) *ConnectionStringBuilder {
+		return NewConnectionStringBuilder("https://help.kusto.windows.net").WithTokenCallback(broker(tenant))
+	}
+
+	_, err := p.Get(kcsb("a"))
+	require.Error(t, err)
+	assert.Equal(t, 0, p.Len())
+	_, err = p.GetWithIdentity(kcsb("a"), "")
+	require.Error(t, err)
+
+	a, err := p.GetWithIdentity(kcsb("a"), "tenant-a")
+	require.NoError(t, err)
+	b, err := p.GetWithIdentity(kcsb("b"), "tenant-b")
+	require.NoError(t, err)
+	assert.NotSame(t, a, b)
+	again, err := p.GetWithIdentity(kcsb("a"), "tenant-a")
+	require.NoError(t, err)
+	assert.Same(t, a, again)
+	assert.EqualValues(t, 2, atomic.LoadInt32(created))
+
+	for _, c := range []*Client{a, b, again} {
+		require.NoError(t, p.Release(c))
+	}
+}
+
+func TestClientPoolRefCount(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	p, created := testPool(t, clock, PoolIdleTTL(time.Minute))
+	kcsb := &ConnectionStringBuilder{DataSource: "https://a.kusto.windows.net"}
+
+	c1, err := p.Get(kcsb)
+	require.NoError(t, err)
+	c2, err := p.Get(&ConnectionStringBuilder{DataSource: "https://A.kusto.windows.net/"})
+	require.NoError(t, err)
+	assert.Same(t, c1, c2)
+	assert.EqualValues(t, 1, atomic.LoadInt32(created))
+
+	// Still in use by one caller, so it is never idle.
+	require.NoError(t, p.Release(c1))
+	clock.Advance(time.Hour)
+	p.evictIdle()
+	assert.Equal(t, 1, p.Len())
+	assert.False(t, poolConnOf(c1).isClosed())
+
+	// Idle, but not for long enough.
+	require.NoError(t, p.Release(c2))
+	clock.Advance(59 * time.Second)
+	p.evictIdle()
+	assert.Equal(t, 1, p.Len())
+
+	// Used again, which resets the idle time.
+	c3, err := p.Get(kcsb)
+	require.NoError(t, err)
+	assert.Same(t, c1, c3)
+	require.NoError(t, p.Release(c3))
+	clock.Advance(59 * time.Second)
+	p.evictIdle()
+	assert.Equal(t, 1, p.Len())
+
+	clock.Advance(time.Second)
+	p.evictIdle()
+	assert.Equal(t, 0, p.Len())
+	assert.True(t, poolConnOf(c1).isClosed())
+
+	// Releasing too many times is an error, and a new Client is created.
+	assert.Error(t, p.Release(c1))
+	c4, err := p.Get(kcsb)
+	require.NoError(t, err)
+	assert.NotSame(t, c1, c4)
+	assert.EqualValues(t, 2, atomic.LoadInt32(created))
+	require.NoError(t, p.Release(c4))
+	assert.Error(t, p.Release(c4))
+}
+
+func TestClientPoolLRU(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	p, _ := testPool(t, clock, PoolMaxClients(2), PoolIdleTTL(0))
+	get := func(name string) *Client {
+		c, err := p.Get(&ConnectionStringBuilder{DataSource: "https://" + name + ".kusto.windows.net"})
+		require.NoError(t, err)
+		return c
+	}
+
+	a, b := get("a"), get("b")
+	require.NoError(t, p.Release(a))
+	require.NoError(t, p.Release(b))
+	// a is the most recently used.
+	require.NoError(t, p.Release(get("a")))
+
+	c := get("c")
+	assert.Equal(t, 2, p.Len())
+	assert.True(t, poolConnOf(b).isClosed(), "b is the least recently used")
+	assert.False(t, poolConnOf(a).isClosed())
+
+	// a and c are in use, so there is no room for d.
+	a2 := get("a")
+	_, err := p.Get(&ConnectionStringBuilder{DataSource: "https://d.kusto.windows.net"})
+	var kErr *errors.Error
+	require.True(t, goErrors.As(err, &kErr), "got %v", err)
+	assert.Equal(t, errors.KLimitsExceeded, kErr.Kind)
+	assert.False(t, poolConnOf(a).isClosed())
+	assert.False(t, poolConnOf(c).isClosed())
+
+	require.NoError(t, p.Release(a2))
+	d := get("d")
+	assert.True(t, poolConnOf(a).isClosed())
+	assert.False(t, poolConnOf(c).isClosed())
+
+	require.NoError(t, p.Release(c))
+	require.NoError(t, p.Close())
+	assert.True(t, poolConnOf(c).isClosed())
+	assert.False(t, poolConnOf(d).isClosed(), "d is still in use")
+	_, err = p.Get(&ConnectionStringBuilder{DataSource: "https://e.kusto.windows.net"})
+	assert.Error(t, err)
+
+	require.NoError(t, p.Release(d))
+	assert.True(t, poolConnOf(d).isClosed(), "released after the pool was closed")
+}
+
+func TestClientPoolEvictionErrors(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	p, _ := testPool(t, clock, PoolMaxClients(1), PoolIdleTTL(time.Minute))
+	get := func(name string, closeErr error) *Client {
+		c, err := p.Get(&ConnectionStringBuilder{DataSource: "https://" + name + ".kusto.windows.net"})
+		require.NoError(t, err)
+		poolConnOf(c).closeErr = closeErr
+		require.NoError(t, p.Release(c))
+		return c
+	}
+
+	idleErr := fmt.Errorf("idle close failed")
+	lruErr := fmt.Errorf("lru close failed")
+
+	// a is evicted as idle, b as the least recently used to make room for c.
+	a := get("a", idleErr)
+	clock.Advance(time.Minute)
+	p.evictIdle()
+	assert.True(t, poolConnOf(a).isClosed())
+	b := get("b", lruErr)
+	get("c", nil)
+	assert.True(t, poolConnOf(b).isClosed())
+
+	err := p.Close()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), idleErr.Error())
+	assert.Contains(t, err.Error(), lruErr.Error())
+}
+
+func TestClientPoolEvictionDuringQuery(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	p, _ := testPool(t, clock, PoolMaxClients(1), PoolIdleTTL(time.Minute))
+
+	client, err := p.Get(&ConnectionStringBuilder{DataSource: "https://a.kusto.windows.net"})
+	require.NoError(t, err)
+	conn := poolConnOf(client)
+	conn.started, conn.release = make(chan struct{}), make(chan struct{})
+
+	queryErr := make(chan error, 1)
+	go func() {
+		_, err := client.Query(context.Background(), "db", NewStmt("T"))
+		queryErr <- err
+	}()
+	<-conn.started
+
+	// Neither the TTL nor the cap can evict the Client while the query is in flight.
+	clock.Advance(time.Hour)
+	p.evictIdle()
+	_, err = p.Get(&ConnectionStringBuilder{DataSource: "https://b.kusto.windows.net"})
+	assert.Error(t, err)
+	assert.False(t, conn.isClosed())
+
+	close(conn.release)
+	assert.EqualError(t, <-queryErr, "done")
+	require.NoError(t, p.Release(client))
+
+	other, err := p.Get(&ConnectionStringBuilder{DataSource: "https://b.kusto.windows.net"})
+	require.NoError(t, err)
+	assert.True(t, conn.isClosed())
+	require.NoError(t, p.Release(other))
+}
+
+func TestClientPoolConcurrency(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	p, created := testPool(t, clock, PoolMaxClients(4), PoolIdleTTL(time.Minute))
+
+	var all sync.Map
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				kcsb := &ConnectionStringBuilder{DataSource: fmt.Sprintf("https://c%d.kusto.windows.net", (i+j)%6)}
+				c, err := p.Get(kcsb)
+				if err != nil {
+					// The pool is full of Clients in use.
+					continue
+				}
+				all.Store(c, true)
+				if poolConnOf(c).isClosed() {
+					t.Errorf("Get() returned a closed Client")
+				}
+				if poolConnOf(c).endpoint != kcsb.DataSource {
+					t.Errorf("Get(%s) returned the Client of %s", kcsb.DataSource, poolConnOf(c).endpoint)
+				}
+				if j%10 == 0 {
+					clock.Advance(time.Second)
+					p.evictIdle()
+				}
+				if err := p.Release(c); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, p.Len(), 4)
+	require.NoError(t, p.Close())
+	assert.Equal(t, 0, p.Len())
+
+	count := 0
+	all.Range(func(k, _ interface{}) bool {
+		count++
+		assert.True(t, poolConnOf(k.(*Client)).isClosed())
+		return true
+	})
+	assert.EqualValues(t, atomic.LoadInt32(created), count)
+}
+
+func TestClientPoolGetErrors(t *testing.T) {
+	t.Parallel()
+
+	p := NewClientPool()
+	defer p.Close()
+
+	_, err := p.Get(nil)
+	assert.Error(t, err)
+	_, err = p.Get(&ConnectionStringBuilder{DataSource: "https://ingest-a.kusto.windows.net"})
+	assert.Error(t, err)
+	assert.Equal(t, 0, p.Len())
+}
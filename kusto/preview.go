@@ -0,0 +1,146 @@
+package kusto
+
+// preview.go implements Client.RequestPreview() and Client.MgmtRequestPreview(), which show the HTTP request a call
+// would send, and the accessors of the endpoint URLs of the Client.
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// MaskedAuthorization replaces the token in the Authorization header of a RequestPreview.
+const MaskedAuthorization = "<masked>"
+
+// RequestPreview is the HTTP request that a call would send.
+type RequestPreview struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// URL is the URL of the request, which is the QueryURL(), MgmtURL() or the mgmt URL of the ingest- endpoint.
+	URL string
+	// Header holds the headers of the request. If the Client has credentials, the Authorization header has the
+	// token scheme followed by MaskedAuthorization instead of the token.
+	Header http.Header
+	// Body is the JSON body of the request. It is shown uncompressed, even when the request is compressed, see
+	// WithRequestCompression().
+	Body string
+}
+
+// RequestPreview returns the HTTP request that Query() would send for query on database db with the options,
+// without sending anything, not even a request for a token. The request is built by the code that builds the ones
+// that are sent, with the options of the Client such as WithReadOnlyClient() and WithTimeoutHeadroom().
+// Three parts of a request can differ from the preview: the x-ms-client-request-id header is generated for each
+// request unless ClientRequestID() is passed, the servertimeout is derived from the default deadline of Query() unless
+// ServerTimeout() is passed, and the statement interceptors of the Client are not run, see WithStatementInterceptor().
+func (c *Client) RequestPreview(db string, query Stmt, options ...QueryOption) (RequestPreview, error) {
+	if strings.HasPrefix(strings.TrimSpace(query.String()), ".") {
+		return RequestPreview{}, errors.ES(errors.OpQuery, errors.KClientArgs, "a Stmt to Query() cannot begin with a period(.), only Mgmt() calls can do that").SetNoRetry()
+	}
+
+	options, err := c.readOnlyQuery(query, options)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+
+	ctx, cancel, err := contextSetup(context.Background(), false)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	defer cancel()
+
+	opts, err := setQueryOptions(ctx, errors.OpQuery, query, c.queryTimeoutHeadroom(c.queryDefaults(nil)), options...)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+
+	conn, err := c.getConn(queryCall, connOptions{queryOptions: opts})
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	return previewRequest(conn, errors.OpQuery, execQuery, db, opts.query, *opts.requestProperties)
+}
+
+// MgmtRequestPreview returns the HTTP request that Mgmt() would send for query on database db with the options,
+// without sending anything. It is the Mgmt() counterpart of RequestPreview(). With IngestionEndpoint(), the URL is
+// the one of the ingest- endpoint.
+func (c *Client) MgmtRequestPreview(db string, query Stmt, options ...MgmtOption) (RequestPreview, error) {
+	if err := c.readOnlyMgmt(); err != nil {
+		return RequestPreview{}, err
+	}
+
+	ctx, cancel, err := contextSetup(context.Background(), true)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	defer cancel()
+
+	opts, err := setMgmtOptions(ctx, errors.OpMgmt, query, c.mgmtTimeoutHeadroom(options)...)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+
+	conn, err := c.getConn(mgmtCall, connOptions{mgmtOptions: opts})
+	if err != nil {
+		return RequestPreview{}, err
+	}
+	return previewRequest(conn, errors.OpMgmt, execMgmt, db, query, *opts.requestProperties)
+}
+
+// previewRequest builds the request of a call on q, which must be a *conn.
+func previewRequest(q queryer, op errors.Op, execType int, db string, query Stmt, properties requestProperties) (RequestPreview, error) {
+	conn, ok := q.(*conn)
+	if !ok {
+		return RequestPreview{}, errors.ES(op, errors.KClientArgs, "the request of a Client that does not connect to a Kusto endpoint cannot be previewed").SetNoRetry()
+	}
+
+	buff := &bytes.Buffer{}
+	_, req, err := conn.newRequest(execType, db, query, properties, buff)
+	if err != nil {
+		return RequestPreview{}, err
+	}
+
+	if conn.compresses(buff.Len()) {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if tkp := conn.auth.TokenProvider; tkp != nil && tkp.AuthorizationRequired() {
+		req.Header.Add("Authorization", strings.TrimSpace(tkp.tokenScheme+" "+MaskedAuthorization))
+	}
+
+	return RequestPreview{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header,
+		Body:   buff.String(),
+	}, nil
+}
+
+// QueryURL returns the URL that Query() and QueryToJson() calls are sent to, or "" if the Client does not connect
+// to a Kusto endpoint, such as a mock.
+func (c *Client) QueryURL() string {
+	return c.connURL(func(conn *conn) *url.URL { return conn.endQuery })
+}
+
+// MgmtURL returns the URL that Mgmt() calls are sent to, unless they have the IngestionEndpoint() option, or "" if
+// the Client does not connect to a Kusto endpoint.
+func (c *Client) MgmtURL() string {
+	return c.connURL(func(conn *conn) *url.URL { return conn.endMgmt })
+}
+
+// StreamIngestURL returns the base URL of the streaming ingestion into the cluster of the Client, which is followed
+// by the database and the table of the ingestion, or "" if the Client does not connect to a Kusto endpoint.
+func (c *Client) StreamIngestURL() string {
+	return c.connURL(func(conn *conn) *url.URL { return conn.streamQuery })
+}
+
+// connURL returns the URL chosen by endpoint from the connection of the Client, if it is a *conn.
+func (c *Client) connURL(endpoint func(conn *conn) *url.URL) string {
+	conn, ok := c.conn.(*conn)
+	if !ok {
+		return ""
+	}
+	return endpoint(conn).String()
+}
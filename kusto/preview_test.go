@@ -0,0 +1,186 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureTransport is a fake http.RoundTripper that keeps the requests sent to the Kusto endpoints.
+type captureTransport struct {
+	mu   sync.Mutex
+	reqs []RequestPreview
+}
+
+func (c *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") || strings.HasSuffix(req.URL.Path, "/auth/metadata") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.reqs = append(c.reqs, RequestPreview{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: string(body)})
+	c.mu.Unlock()
+
+	frames := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	if strings.HasSuffix(req.URL.Path, "/v1/rest/mgmt") {
+		frames = `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"x","DataType":"Int64"}],"Rows":[]}]}`
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(frames))}, nil
+}
+
+func (c *captureTransport) sent() []RequestPreview {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]RequestPreview(nil), c.reqs...)
+}
+
+func previewClient(t *testing.T, transport http.RoundTripper) *Client {
+	auth := Authorization{TokenProvider: &TokenProvider{customToken: "s3cr3t", tokenScheme: "Bearer"}}
+	conn, err := newConn("https://preview.kusto.windows.net", auth, &http.Client{Transport: transport}, NewClientDetails("app", "user"))
+	require.NoError(t, err)
+	return &Client{conn: conn, endpoint: "https://preview.kusto.windows.net", auth: auth, http: conn.client}
+}
+
+func TestRequestPreview(t *testing.T) {
+	t.Parallel()
+
+	transport := &captureTransport{}
+	client := previewClient(t, transport)
+
+	query := NewStmt("T | where x == v").MustDefinitions(NewDefinitions().Must(ParamTypes{"v": ParamType{Type: "long"}})).
+		MustParameters(NewParameters().Must(QueryValues{"v": int64(42)}))
+	options := []QueryOption{ClientRequestID("preview-1"), ServerTimeout(time.Minute), NoTruncation()}
+
+	preview, err := client.RequestPreview("db", query, options...)
+	require.NoError(t, err)
+	assert.Empty(t, transport.sent(), "a preview must not send anything")
+
+	iter, err := client.Query(context.Background(), "db", query, options...)
+	require.NoError(t, err)
+	iter.Stop()
+
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	got := sent[0]
+
+	// The token is masked, and only the token.
+	assert.Equal(t, "Bearer "+MaskedAuthorization, preview.Header.Get("Authorization"))
+	assert.Equal(t, "Bearer s3cr3t", got.Header.Get("Authorization"))
+	assert.NotContains(t, preview.Body, "s3cr3t")
+	preview.Header.Del("Authorization")
+	got.Header.Del("Authorization")
+
+	assert.Equal(t, got, preview)
+	assert.Equal(t, http.MethodPost, preview.Method)
+	assert.Equal(t, client.QueryURL(), preview.URL)
+}
+
+func TestMgmtRequestPreview(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		options []MgmtOption
+		wantURL string
+	}{
+		{
+			desc:    "Mgmt",
+			wantURL: "https://preview.kusto.windows.net/v1/rest/mgmt",
+		},
+		{
+			desc:    "Ingestion endpoint",
+			options: []MgmtOption{IngestionEndpoint()},
+			wantURL: "https://ingest-preview.kusto.windows.net/v1/rest/mgmt",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &captureTransport{}
+			client := previewClient(t, transport)
+
+			preview, err := client.MgmtRequestPreview("db", NewStmt(".show tables"), test.options...)
+			require.NoError(t, err)
+			assert.Empty(t, transport.sent(), "a preview must not send anything")
+			assert.Equal(t, test.wantURL, preview.URL)
+
+			iter, err := client.Mgmt(context.Background(), "db", NewStmt(".show tables"), test.options...)
+			require.NoError(t, err)
+			iter.Stop()
+
+			sent := transport.sent()
+			require.Len(t, sent, 1)
+			got := sent[0]
+			assert.Equal(t, got.Method, preview.Method)
+			assert.Equal(t, got.URL, preview.URL)
+
+			// The client request id is generated for each request, and the servertimeout is derived from the time
+			// left before the deadline.
+			for _, header := range []http.Header{got.Header, preview.Header} {
+				assert.True(t, strings.HasPrefix(header.Get("x-ms-client-request-id"), "KGC.execute;"))
+				header.Del("x-ms-client-request-id")
+			}
+			assert.Equal(t, "Bearer "+MaskedAuthorization, preview.Header.Get("Authorization"))
+			preview.Header.Set("Authorization", got.Header.Get("Authorization"))
+			assert.Equal(t, got.Header, preview.Header)
+
+			var gotMsg, previewMsg queryMsg
+			require.NoError(t, json.Unmarshal([]byte(got.Body), &gotMsg))
+			require.NoError(t, json.Unmarshal([]byte(preview.Body), &previewMsg))
+			assert.NotNil(t, previewMsg.Properties.Options[ServerTimeoutValue])
+			delete(gotMsg.Properties.Options, ServerTimeoutValue)
+			delete(previewMsg.Properties.Options, ServerTimeoutValue)
+			assert.Equal(t, gotMsg, previewMsg)
+		})
+	}
+}
+
+func TestRequestPreviewErrors(t *testing.T) {
+	t.Parallel()
+
+	transport := &captureTransport{}
+	client := previewClient(t, transport)
+
+	_, err := client.RequestPreview("db", NewStmt(".show tables"))
+	assert.Error(t, err)
+
+	WithReadOnlyClient()(client)
+	_, err = client.MgmtRequestPreview("db", NewStmt(".drop table T"))
+	assert.Error(t, err)
+
+	_, err = NewMockClient().RequestPreview("db", NewStmt("T"))
+	assert.Error(t, err)
+	assert.Empty(t, transport.sent())
+}
+
+func TestEndpointURLs(t *testing.T) {
+	t.Parallel()
+
+	client := previewClient(t, &captureTransport{})
+	assert.Equal(t, "https://preview.kusto.windows.net/v2/rest/query", client.QueryURL())
+	assert.Equal(t, "https://preview.kusto.windows.net/v1/rest/mgmt", client.MgmtURL())
+	assert.Equal(t, "https://preview.kusto.windows.net/v1/rest/ingest/", client.StreamIngestURL())
+
+	mock := NewMockClient()
+	assert.Empty(t, mock.QueryURL())
+	assert.Empty(t, mock.MgmtURL())
+	assert.Empty(t, mock.StreamIngestURL())
+}
@@ -0,0 +1,96 @@
+package kusto
+
+// progress.go implements WithProgressCallback(), which reports the progress of the tables of a progressive response.
+
+import (
+	"sync"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// WithProgressCallback calls f with the progress of the tables of a progressive response, in percent (0-100), as
+// their TableProgress frames arrive, and with 100 when a table is complete, so that a UI can finalize it.
+// tableOrdinal is the TableId of the table in the response. Responses are progressive unless
+// ResultsProgressiveDisable() or ResultsV2FragmentedStreaming() is passed, and only progressive responses have
+// TableProgress frames.
+// f is called in order from its own goroutine, so a slow f never delays the rows. The progress that arrives while f
+// is running only keeps its latest update, the others are dropped, but the completion of a table is never dropped.
+func WithProgressCallback(f func(tableOrdinal int64, percent float64)) QueryOption {
+	return func(q *queryOptions) error {
+		if f == nil {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "WithProgressCallback() cannot be passed a nil func").SetNoRetry()
+		}
+		q.progressCallback = f
+		return nil
+	}
+}
+
+// progressUpdate is an update to pass to the callback of WithProgressCallback().
+type progressUpdate struct {
+	tableOrdinal int64
+	percent      float64
+	// complete is set for the update sent at TableCompletion, which is never dropped.
+	complete bool
+}
+
+// progressNotifier passes the updates of a progressiveSM to the callback of WithProgressCallback(), without blocking
+// the progressiveSM. A nil *progressNotifier drops the updates.
+type progressNotifier struct {
+	f func(tableOrdinal int64, percent float64)
+
+	mu sync.Mutex
+	// pending are the updates that were not yet passed to f. Only the last one can be an update that is not complete.
+	pending []progressUpdate
+	// wake is signaled when updates are pending, and closed when no more updates will be sent.
+	wake chan struct{}
+}
+
+// newProgressNotifier returns a progressNotifier that calls f, or nil if f is nil. close() must be called on it.
+func newProgressNotifier(f func(tableOrdinal int64, percent float64)) *progressNotifier {
+	if f == nil {
+		return nil
+	}
+	n := &progressNotifier{f: f, wake: make(chan struct{}, 1)}
+	go n.run()
+	return n
+}
+
+// notify queues u, replacing the previous update if it was not a completion.
+func (n *progressNotifier) notify(u progressUpdate) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if last := len(n.pending) - 1; last >= 0 && !n.pending[last].complete {
+		n.pending[last] = u
+	} else {
+		n.pending = append(n.pending, u)
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default: // Already signaled.
+	}
+}
+
+// close stops the notifier once the pending updates are passed to the callback.
+func (n *progressNotifier) close() {
+	if n == nil {
+		return
+	}
+	close(n.wake)
+}
+
+func (n *progressNotifier) run() {
+	for range n.wake {
+		n.mu.Lock()
+		pending := n.pending
+		n.pending = nil
+		n.mu.Unlock()
+
+		for _, u := range pending {
+			n.f(u.tableOrdinal, u.percent)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package kusto
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressRecorder records the calls to the callback of WithProgressCallback().
+type progressRecorder struct {
+	mu      sync.Mutex
+	updates []progressUpdate
+}
+
+func (r *progressRecorder) record(tableOrdinal int64, percent float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, progressUpdate{tableOrdinal: tableOrdinal, percent: percent})
+}
+
+func (r *progressRecorder) get() []progressUpdate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]progressUpdate(nil), r.updates...)
+}
+
+func TestWithProgressCallback(t *testing.T) {
+	t.Parallel()
+
+	_, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("test"), nil, WithProgressCallback(nil))
+	require.Error(t, err)
+
+	columns := table.Columns{{Name: "A", Type: "long"}}
+	ch := make(chan frames.Frame, 10)
+	ch <- v2.DataSetHeader{Base: v2.Base{FrameType: frames.TypeDataSetHeader}, IsProgressive: true}
+	ch <- v2.TableHeader{Base: v2.Base{FrameType: frames.TypeTableHeader}, TableID: 1, TableKind: frames.PrimaryResult, Columns: columns}
+	ch <- v2.TableProgress{Base: v2.Base{FrameType: frames.TypeTableProgress}, TableID: 1, TableProgress: 25}
+	ch <- v2.TableFragment{
+		Base:              v2.Base{FrameType: frames.TypeTableFragment},
+		TableID:           1,
+		TableFragmentType: "DataAppend",
+		KustoRows:         []value.Values{{value.Long{Value: 1, Valid: true}}},
+	}
+	ch <- v2.TableProgress{Base: v2.Base{FrameType: frames.TypeTableProgress}, TableID: 1, TableProgress: 50}
+	ch <- v2.TableCompletion{Base: v2.Base{FrameType: frames.TypeTableCompletion}, TableID: 1, RowCount: 1}
+	ch <- v2.DataSetCompletion{Base: v2.Base{FrameType: frames.TypeDataSetCompletion}}
+	close(ch)
+
+	rec := &progressRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	iter, err := startRowIterator(ctx, cancel, execResp{frameCh: ch}, <-ch, errors.OpQuery, rec.record)
+	require.NoError(t, err)
+	defer iter.Stop()
+
+	rows := 0
+	require.NoError(t, iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+		require.Nil(t, e)
+		rows++
+		return nil
+	}))
+	assert.Equal(t, 1, rows)
+
+	// The updates can be dropped while the callback runs, but they are received in order and end with the completion.
+	want := []progressUpdate{{tableOrdinal: 1, percent: 25}, {tableOrdinal: 1, percent: 50}, {tableOrdinal: 1, percent: 100}}
+	require.Eventually(t, func() bool {
+		got := rec.get()
+		return len(got) > 0 && got[len(got)-1] == want[len(want)-1]
+	}, 5*time.Second, time.Millisecond)
+
+	got := rec.get()
+	i := 0
+	for _, u := range got {
+		for i < len(want) && want[i] != u {
+			i++
+		}
+		require.Less(t, i, len(want), "update %+v is not expected or out of order in %+v", u, got)
+	}
+}
+
+func TestProgressNotifierDropsUpdates(t *testing.T) {
+	t.Parallel()
+
+	var nilNotifier *progressNotifier
+	nilNotifier.notify(progressUpdate{percent: 1})
+	nilNotifier.close()
+	assert.Nil(t, newProgressNotifier(nil))
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	rec := &progressRecorder{}
+	first := true
+	n := newProgressNotifier(func(tableOrdinal int64, percent float64) {
+		if first {
+			first = false
+			close(entered)
+			<-release
+		}
+		rec.record(tableOrdinal, percent)
+	})
+
+	n.notify(progressUpdate{tableOrdinal: 0, percent: 10})
+	<-entered
+
+	// While the callback is blocked, only the latest update is kept, but completions are kept too.
+	n.notify(progressUpdate{tableOrdinal: 0, percent: 20})
+	n.notify(progressUpdate{tableOrdinal: 0, percent: 30})
+	n.notify(progressUpdate{tableOrdinal: 0, percent: 100, complete: true})
+	n.notify(progressUpdate{tableOrdinal: 1, percent: 40})
+	n.notify(progressUpdate{tableOrdinal: 1, percent: 50})
+	close(release)
+	n.close()
+
+	want := []progressUpdate{{tableOrdinal: 0, percent: 10}, {tableOrdinal: 0, percent: 100}, {tableOrdinal: 1, percent: 50}}
+	require.Eventually(t, func() bool {
+		return len(rec.get()) == len(want)
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, want, rec.get())
+}
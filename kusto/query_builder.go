@@ -6,17 +6,22 @@ These provide injection safe querying for data retrieval and insertion.
 */
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	ilog "github.com/Azure/azure-kusto-go/kusto/internal/log"
+	"github.com/Azure/azure-kusto-go/kusto/internal/stmt"
 	"github.com/Azure/azure-kusto-go/kusto/unsafe"
 
 	"github.com/google/uuid"
@@ -24,13 +29,9 @@ import (
 
 // stringConstant is an internal type that cannot be created outside the package.  The only two ways to build
 // a stringConstant is to pass a string constant or use a local function to build the stringConstant.
-// This allows us to enforce the use of constants or strings built with injection protection.
-type stringConstant string
-
-// String implements fmt.Stringer.
-func (s stringConstant) String() string {
-	return string(s)
-}
+// This allows us to enforce the use of constants or strings built with injection protection. It is shared with the
+// kql package, see stmt.StringConstant.
+type stringConstant = stmt.StringConstant
 
 // ParamTypes is a list of parameter types and corresponding type data.
 type ParamTypes map[string]ParamType
@@ -58,7 +59,7 @@ type ParamType struct {
 	// CTReal must be an float64
 	// CTString must be a string
 	// CTTimespan must be a time.Duration
-	// CTDecimal must be a string or *big.Float representing a decimal value
+	// CTDecimal must be a string, *big.Float, *big.Int, float64 or value.Decimal representing a decimal value
 	Default interface{}
 
 	name string
@@ -116,24 +117,10 @@ func (p ParamType) validate() error {
 		}
 		return nil
 	case types.Decimal:
-		switch v := p.Default.(type) {
-		case string:
-			if !value.DecRE.MatchString(v) {
-				return fmt.Errorf("string representing decimal does not appear to be a decimal number, was %v", v)
-			}
-			return nil
-		case *big.Float:
-			if v == nil {
-				return fmt.Errorf("*big.Float type cannot be set to the nil value")
-			}
-			return nil
-		case *big.Int:
-			if v == nil {
-				return fmt.Errorf("*big.Int type cannot be set to the nil value")
-			}
-			return nil
-		}
-		return fmt.Errorf("the .Type was %s, but the value was a %T", p.Type, p.Default)
+		if _, err := decimalString(p.Default); err != nil {
+			return fmt.Errorf("the .Type was %s, but %w", p.Type, err)
+		}
+		return nil
 	}
 	return fmt.Errorf("received a field type %q we don't recognize", p.Type)
 }
@@ -151,7 +138,7 @@ func (p ParamType) string() string {
 			return p.name + ":datetime"
 		}
 		v := p.Default.(time.Time)
-		return fmt.Sprintf("%s:datetime = datetime(%s)", p.name, v.Format(time.RFC3339Nano))
+		return fmt.Sprintf("%s:datetime = datetime(%s)", p.name, value.DateTime{Value: v, Valid: true}.Marshal())
 	case types.Dynamic:
 		return p.name + ":dynamic"
 	case types.GUID:
@@ -195,18 +182,51 @@ func (p ParamType) string() string {
 			return p.name + ":decimal"
 		}
 
-		var sval string
-		switch v := p.Default.(type) {
-		case string:
-			sval = v
-		case *big.Float:
-			sval = v.String()
-		}
+		sval, _ := decimalString(p.Default)
 		return fmt.Sprintf("%s:decimal = decimal(%s)", p.name, sval)
 	}
 	panic("internal bug: ParamType.string() called without a call to .validate()")
 }
 
+// decimalString returns the literal of a decimal parameter value, which can be a string, *big.Float, *big.Int, float64
+// or value.Decimal. *big.Float and float64 values are formatted with all the digits of their precision.
+func decimalString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		if !value.DecRE.MatchString(v) {
+			return "", fmt.Errorf("string representing decimal does not appear to be a decimal number, was %v", v)
+		}
+		return v, nil
+	case *big.Float:
+		if v == nil {
+			return "", fmt.Errorf("*big.Float type cannot be set to the nil value")
+		}
+		if v.IsInf() {
+			return "", fmt.Errorf("*big.Float type cannot be set to an infinite value")
+		}
+		return value.NewDecimalFromFloat(v).Value, nil
+	case *big.Int:
+		if v == nil {
+			return "", fmt.Errorf("*big.Int type cannot be set to the nil value")
+		}
+		return v.String(), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("float64 type cannot be set to %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case value.Decimal:
+		if !v.Valid {
+			return "null", nil
+		}
+		if !value.DecRE.MatchString(v.Value) {
+			return "", fmt.Errorf("value.Decimal does not appear to be a decimal number, was %v", v.Value)
+		}
+		return v.Value, nil
+	}
+	return "", fmt.Errorf("%T is not a string, *big.Float, *big.Int, float64 or value.Decimal", v)
+}
+
 // Definitions represents definitions of parameters that are substituted for variables in
 // a Kusto Query. This provides both variable substitution in a Stmt and provides protection against
 // SQL-like injection attacks.
@@ -307,7 +327,9 @@ func (p Definitions) clone() Definitions {
 
 // QueryValues represents a set of values that are substituted in Parameters. Every QueryValue key
 // must have a corresponding Parameter name. All values must be compatible with the Kusto Column type
-// it will go into (int64 for a long, int32 for int, time.Time for datetime, ...)
+// it will go into (int64 for a long, int32 for int, time.Time for datetime, ...) or be its value.Kusto type, whose
+// null values are sent as nulls. A dynamic value is marshalled into JSON, unless it is a json.RawMessage or a
+// value.Dynamic, which already hold JSON.
 type QueryValues map[string]interface{}
 
 func (v QueryValues) clone() QueryValues {
@@ -395,84 +417,144 @@ func (q Parameters) validate(p Definitions) (Parameters, error) {
 		if !ok {
 			return q, fmt.Errorf("Parameters contains key %q that is not defined in the Stmt's Parameters", k)
 		}
-		switch paramType.Type {
-		case types.Bool:
-			b, ok := v.(bool)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](bool) = %T, which is not a bool", k, v)
-			}
-			if b {
-				out[k] = "bool(true)"
-				break
-			}
-			out[k] = "bool(false)"
-		case types.DateTime:
-			t, ok := v.(time.Time)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](datetime) = %T, which is not a time.Time", k, v)
-			}
-			out[k] = fmt.Sprintf("datetime(%s)", t.Format(time.RFC3339Nano))
-		case types.Dynamic:
-			b, err := json.Marshal(v)
-			if err != nil {
-				return q, fmt.Errorf("Parameters[%s](dynamic), %T could not be marshalled into JSON, err: %s", k, v, err)
-			}
-			out[k] = fmt.Sprintf("dynamic(%s)", string(b))
-		case types.GUID:
-			u, ok := v.(uuid.UUID)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](guid) = %T, which is not a uuid.UUID", k, v)
-			}
-			out[k] = fmt.Sprintf("guid(%s)", u.String())
-		case types.Int:
-			i, ok := v.(int32)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](int) = %T, which is not an int32", k, v)
-			}
-			out[k] = fmt.Sprintf("int(%d)", i)
-		case types.Long:
-			i, ok := v.(int64)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](long) = %T, which is not an int64", k, v)
-			}
-			out[k] = fmt.Sprintf("long(%d)", i)
-		case types.Real:
-			i, ok := v.(float64)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](real) = %T, which is not a float64", k, v)
-			}
-			out[k] = fmt.Sprintf("real(%f)", i)
-		case types.String:
-			s, ok := v.(string)
-			if !ok {
-				return q, fmt.Errorf("Parameters[%s](string) = %T, which is not a string", k, v)
-			}
-			out[k] = fmt.Sprint(s)
-		case types.Timespan:
-			d, ok := v.(time.Duration)
-			if !ok {
-				return q, fmt.Errorf("parameters[%s](timespan) = %T, which is not a time.Duration", k, v)
-			}
-			out[k] = fmt.Sprintf("timespan(%s)", value.Timespan{Value: d, Valid: true}.Marshal())
-		case types.Decimal:
-			var sval string
-			switch v := v.(type) {
-			case string:
-				sval = v
-			case *big.Float:
-				sval = v.String()
-			case *big.Int:
-				sval = v.String()
-			default:
-				return q, fmt.Errorf("Parameters[%s](decimal) = %T, which is not a string or *big.Float", k, v)
-			}
-			out[k] = fmt.Sprintf("decimal(%s)", sval)
+		literal, err := paramLiteral(k, paramType.Type, v)
+		if err != nil {
+			return q, err
 		}
+		out[k] = literal
 	}
 	q.outM = out
 	return q, nil
 }
 
+// paramLiteral returns the value of the parameter k of type t sent in the request properties, which is v as a
+// literal of t, such as `long(1)`, except for strings, which are sent as is. v is the Go type of t, see ParamType,
+// or the value.Kusto of t, whose null is sent as the null of t, such as `real(null)`.
+func paramLiteral(k string, t types.Column, v interface{}) (string, error) {
+	if t == types.String {
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case value.String:
+			return v.Value, nil
+		}
+		return "", fmt.Errorf("Parameters[%s](string) = %T, which is not a string", k, v)
+	}
+
+	kv, err := paramKusto(k, t, v)
+	if err != nil {
+		return "", err
+	}
+	literal, err := table.Literal(kv)
+	if err != nil {
+		return "", fmt.Errorf("Parameters[%s](%s): %w", k, t, err)
+	}
+	return literal, nil
+}
+
+// paramKusto returns v, the value of the parameter k of type t, as a value.Kusto of t, see paramLiteral().
+func paramKusto(k string, t types.Column, v interface{}) (value.Kusto, error) {
+	switch t {
+	case types.Bool:
+		switch v := v.(type) {
+		case bool:
+			return value.Bool{Value: v, Valid: true}, nil
+		case value.Bool:
+			return v, nil
+		}
+		return nil, fmt.Errorf("Parameters[%s](bool) = %T, which is not a bool", k, v)
+	case types.DateTime:
+		switch v := v.(type) {
+		case time.Time:
+			return value.DateTime{Value: v, Valid: true}, nil
+		case value.DateTime:
+			return v, nil
+		}
+		return nil, fmt.Errorf("Parameters[%s](datetime) = %T, which is not a time.Time", k, v)
+	case types.Dynamic:
+		b, err := dynamicJSON(v)
+		if err != nil {
+			return nil, fmt.Errorf("Parameters[%s](dynamic), %T could not be marshalled into JSON, err: %s", k, v, err)
+		}
+		return value.Dynamic{Value: b, Valid: true}, nil
+	case types.GUID:
+		switch v := v.(type) {
+		case uuid.UUID:
+			return value.GUID{Value: v, Valid: true}, nil
+		case value.GUID:
+			return v, nil
+		}
+		return nil, fmt.Errorf("Parameters[%s](guid) = %T, which is not a uuid.UUID", k, v)
+	case types.Int:
+		switch v := v.(type) {
+		case int32:
+			return value.Int{Value: v, Valid: true}, nil
+		case value.Int:
+			return v, nil
+		}
+		return nil, fmt.Errorf("Parameters[%s](int) = %T, which is not an int32", k, v)
+	case types.Long:
+		switch v := v.(type) {
+		case int64:
+			return value.Long{Value: v, Valid: true}, nil
+		case value.Long:
+			return v, nil
+		}
+		return nil, fmt.Errorf("Parameters[%s](long) = %T, which is not an int64", k, v)
+	case types.Real:
+		switch v := v.(type) {
+		case float64:
+			return value.Real{Value: v, Valid: true}, nil
+		case value.Real:
+			return v, nil
+		}
+		return nil, fmt.Errorf("Parameters[%s](real) = %T, which is not a float64", k, v)
+	case types.Timespan:
+		switch v := v.(type) {
+		case time.Duration:
+			return value.Timespan{Value: v, Valid: true}, nil
+		case value.Timespan:
+			return v, nil
+		}
+		return nil, fmt.Errorf("parameters[%s](timespan) = %T, which is not a time.Duration", k, v)
+	case types.Decimal:
+		if d, ok := v.(value.Decimal); ok && !d.Valid {
+			return d, nil
+		}
+		sval, err := decimalString(v)
+		if err != nil {
+			return nil, fmt.Errorf("Parameters[%s](decimal): %w", k, err)
+		}
+		return value.Decimal{Value: sval, Valid: true}, nil
+	}
+	return nil, fmt.Errorf("Parameters[%s] has type %q, which we don't recognize", k, t)
+}
+
+// dynamicJSON returns the JSON of a dynamic parameter value. A value.Dynamic or json.RawMessage already holds JSON,
+// which is compacted instead of being marshalled into a JSON string, and nil or a null value.Dynamic is null.
+func dynamicJSON(v interface{}) ([]byte, error) {
+	var raw []byte
+	switch v := v.(type) {
+	case nil:
+		return []byte("null"), nil
+	case value.Dynamic:
+		if !v.Valid {
+			return []byte("null"), nil
+		}
+		raw = v.Value
+	case json.RawMessage:
+		raw = v
+	default:
+		return json.Marshal(v)
+	}
+
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // Stmt is a Kusto Query statement. A Stmt is thread-safe, but methods on the Stmt are not.
 // All methods on a Stmt do not alter the statement, they return a new Stmt object with the changes.
 // This includes a copy of the Definitions and Parameters objects, if provided.  This allows a
@@ -482,6 +564,17 @@ type Stmt struct {
 	defs     Definitions
 	params   Parameters
 	unsafe   unsafe.Stmt
+	// inLists are the parameters added by AddInList().
+	inLists QueryValues
+	// addedTypes and addedValues are the parameters added by AddParam().
+	addedTypes  ParamTypes
+	addedValues QueryValues
+	// err is the first error of a builder method, such as AddParam(), see Err().
+	err error
+	// review is the Reviewer of the unsafe.Policy of NewUnsafeStmt(), which reviews the text of UnsafeAdd().
+	review func(query string) error
+	// unsafeText is set once text that is not injection protected was added to the Stmt.
+	unsafeText bool
 }
 
 // StmtOption is an optional argument to NewStmt().
@@ -494,6 +587,7 @@ func UnsafeStmt(options unsafe.Stmt) StmtOption {
 	return func(s *Stmt) {
 		ilog.UnsafeWarning(options.SuppressWarning)
 		s.unsafe.Add = true
+		s.unsafe.SuppressWarning = options.SuppressWarning
 	}
 }
 
@@ -517,12 +611,56 @@ func (s Stmt) Add(query stringConstant) Stmt {
 // UnsafeAdd provides a method to add strings that are not injection protected to the Stmt.
 // To utilize this method, you must create the Stmt with the UnsafeStmt() option and pass
 // the unsafe.Stmt with .Add set to true. If not set, THIS WILL PANIC!
+// For a Stmt made with NewUnsafeStmt(), query is first passed to the Reviewer of the unsafe.Policy, and a rejection
+// is kept by the Stmt, see Err().
 func (s Stmt) UnsafeAdd(query string) Stmt {
 	if !s.unsafe.Add {
 		panic("Stmt.UnsafeAdd() called, but the unsafe.Stmt.Add ability has not been enabled")
 	}
 
+	if s.err != nil {
+		return s
+	}
+	if s.review != nil {
+		if err := s.review(query); err != nil {
+			return s.withErr(fmt.Errorf("UnsafeAdd(): the text was rejected by the Reviewer: %w", err))
+		}
+	}
+
 	s.queryStr = s.queryStr + query
+	s.unsafeText = true
+	return s
+}
+
+// NewUnsafeStmt creates a Stmt from text that is only known at runtime, such as a query written by a user. text is
+// not injection protected, so policy sets the guardrails: its Reviewer is called with text, and with the text of
+// later UnsafeAdd() calls on the Stmt, and an error rejects it. The Stmt has unsafe.Stmt.Add enabled, and the client
+// logs a warning to the Logger set with WithLogger() each time the Stmt is run, unless policy.SuppressWarning is set.
+// USE AT YOUR OWN RISK!
+func NewUnsafeStmt(text string, policy unsafe.Policy) (Stmt, error) {
+	if policy.RequireReviewer && policy.Reviewer == nil {
+		return Stmt{}, fmt.Errorf("NewUnsafeStmt(): the unsafe.Policy requires a Reviewer, but none was set")
+	}
+	if policy.Reviewer != nil {
+		if err := policy.Reviewer(text); err != nil {
+			return Stmt{}, fmt.Errorf("NewUnsafeStmt(): the text was rejected by the Reviewer: %w", err)
+		}
+	}
+	ilog.UnsafeWarning(policy.SuppressWarning)
+
+	return Stmt{
+		queryStr:   text,
+		unsafe:     unsafe.Stmt{Add: true, SuppressWarning: policy.SuppressWarning},
+		review:     policy.Reviewer,
+		unsafeText: true,
+	}, nil
+}
+
+// UnsafeAddLiteral adds str to the Stmt as a string literal, with its quotes, backslashes and control characters
+// escaped, such as `"say \"hi\""`. Unlike UnsafeAdd(), it does not require unsafe.Stmt.Add, as str cannot end the
+// literal, so it covers the common case of a value known only at runtime.
+func (s Stmt) UnsafeAddLiteral(str string) Stmt {
+	s.queryStr = s.queryStr + table.QuoteString(str)
 	return s
 }
 
@@ -534,7 +672,11 @@ func (s Stmt) WithDefinitions(defs Definitions) (Stmt, error) {
 	if len(defs.m) == 0 {
 		return s, fmt.Errorf("cannot pass Definitions that are empty")
 	}
-	s.defs = defs.clone()
+	defs, err := s.withInLists(defs.clone())
+	if err != nil {
+		return s, err
+	}
+	s.defs = defs
 
 	return s, nil
 }
@@ -556,6 +698,18 @@ func (s Stmt) WithParameters(params Parameters) (Stmt, error) {
 		return s, fmt.Errorf("cannot call WithParameters() if WithDefinitions hasn't been called")
 	}
 	params = params.clone()
+	for k, v := range s.inLists {
+		if _, ok := params.m[k]; ok {
+			return s, fmt.Errorf("Parameters contains key %q that is used by an AddInList() call", k)
+		}
+		params.m[k] = v
+	}
+	for k, v := range s.addedValues {
+		if _, ok := params.m[k]; ok {
+			return s, fmt.Errorf("Parameters contains key %q that is used by an AddParam() call", k)
+		}
+		params.m[k] = v
+	}
 	var err error
 
 	params, err = params.validate(s.defs)
@@ -594,7 +748,7 @@ func (s Stmt) String() string {
 // that will be passed to the server. These values are substitued for Definitions in the Stmt and
 // are represented by the Parameters that was passed.
 func (s Stmt) ValuesJSON() (string, error) {
-	m, err := s.params.toParameters(s.defs)
+	m, err := s.Parameters()
 	if err != nil {
 		return "", err
 	}
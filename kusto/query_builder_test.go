@@ -1,13 +1,16 @@
 package kusto
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/require"
+	"math"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -155,7 +158,16 @@ func TestParamType(t *testing.T) {
 				Default: now,
 				name:    "my_value",
 			},
-			wantStr: fmt.Sprintf("my_value:datetime = datetime(%s)", now.Format(time.RFC3339Nano)),
+			wantStr: fmt.Sprintf("my_value:datetime = datetime(%s)", now.UTC().Truncate(100*time.Nanosecond).Format(time.RFC3339Nano)),
+		},
+		{
+			desc: "Success Default for types.DateTime in another location",
+			param: ParamType{
+				Type:    types.DateTime,
+				Default: time.Date(2022, 3, 13, 1, 30, 0, 123456789, time.FixedZone("EST", -5*3600)),
+				name:    "my_value",
+			},
+			wantStr: "my_value:datetime = datetime(2022-03-13T06:30:00.1234567Z)",
 		},
 		{
 			desc: "Success Default for types.Dynamic",
@@ -255,6 +267,24 @@ func TestParamType(t *testing.T) {
 			},
 			wantStr: "my_value:decimal = decimal(1.)",
 		},
+		{
+			desc: "Success 34 digits *big.Float for types.Decimal",
+			param: ParamType{
+				Type:    types.Decimal,
+				Default: bigDecimal(t, "-1234567890123456789012345678.901234"),
+				name:    "my_value",
+			},
+			wantStr: "my_value:decimal = decimal(-1234567890123456789012345678.901234)",
+		},
+		{
+			desc: "Success value.Decimal for types.Decimal",
+			param: ParamType{
+				Type:    types.Decimal,
+				Default: value.NewDecimal("0.1234567890123456789012345678901234"),
+				name:    "my_value",
+			},
+			wantStr: "my_value:decimal = decimal(0.1234567890123456789012345678901234)",
+		},
 	}
 
 	for _, test := range tests {
@@ -416,7 +446,13 @@ func TestParameters(t *testing.T) {
 			desc:    "Success time.Time",
 			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.DateTime}}),
 			qValues: NewParameters().Must(map[string]interface{}{"key1": now}),
-			want:    map[string]string{"key1": fmt.Sprintf("datetime(%s)", now.Format(time.RFC3339Nano))},
+			want:    map[string]string{"key1": fmt.Sprintf("datetime(%s)", now.UTC().Truncate(100*time.Nanosecond).Format(time.RFC3339Nano))},
+		},
+		{
+			desc:    "Success time.Time in another location",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.DateTime}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": time.Date(2022, 11, 6, 1, 30, 0, 0, time.FixedZone("EDT", -4*3600))}),
+			want:    map[string]string{"key1": "datetime(2022-11-06T05:30:00Z)"},
 		},
 		{
 			desc:    "Success uuid.UUID",
@@ -440,7 +476,7 @@ func TestParameters(t *testing.T) {
 			desc:    "Success float64",
 			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Real}}),
 			qValues: NewParameters().Must(map[string]interface{}{"key1": 1.1}),
-			want:    map[string]string{"key1": fmt.Sprintf("real(%f)", 1.1)},
+			want:    map[string]string{"key1": "real(1.1)"},
 		},
 		{
 			desc:    "Success string",
@@ -452,7 +488,7 @@ func TestParameters(t *testing.T) {
 			desc:    "Success time.Duration",
 			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Timespan}}),
 			qValues: NewParameters().Must(map[string]interface{}{"key1": 3 * time.Second}),
-			want:    map[string]string{"key1": "timespan(00:00:03)"},
+			want:    map[string]string{"key1": "time(00:00:03.0000000)"},
 		},
 		{
 			desc:    "Success string representing decimal",
@@ -472,6 +508,108 @@ func TestParameters(t *testing.T) {
 			qValues: NewParameters().Must(map[string]interface{}{"key1": big.NewInt(5)}),
 			want:    map[string]string{"key1": fmt.Sprintf("decimal(%s)", big.NewInt(5).String())},
 		},
+		{
+			desc:    "Success 34 digits *big.Float for decimal",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Decimal}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": bigDecimal(t, "9999999999999999999999999999999999")}),
+			want:    map[string]string{"key1": "decimal(9999999999999999999999999999999999)"},
+		},
+		{
+			desc:    "Success float64 for decimal",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Decimal}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": 0.1}),
+			want:    map[string]string{"key1": "decimal(0.1)"},
+		},
+		{
+			desc:    "Success value.Decimal for decimal",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Decimal}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": value.NewDecimal("-12.5")}),
+			want:    map[string]string{"key1": "decimal(-12.5)"},
+		},
+		{
+			desc:    "Success null value.Decimal for decimal",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Decimal}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": value.NullDecimal()}),
+			want:    map[string]string{"key1": "decimal(null)"},
+		},
+		{
+			desc:    "Success float64 keeps its precision",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Real}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": 1e-9}),
+			want:    map[string]string{"key1": "real(1e-09)"},
+		},
+		{
+			desc:    "Success float64 NaN and infinities",
+			qParams: NewDefinitions().Must(map[string]ParamType{"a": {Type: types.Real}, "b": {Type: types.Real}, "c": {Type: types.Real}}),
+			qValues: NewParameters().Must(map[string]interface{}{"a": math.NaN(), "b": math.Inf(1), "c": math.Inf(-1)}),
+			want:    map[string]string{"a": "real(nan)", "b": "real(+inf)", "c": "real(-inf)"},
+		},
+		{
+			desc:    "Success null value.Real",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Real}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": value.Real{}}),
+			want:    map[string]string{"key1": "real(null)"},
+		},
+		{
+			desc:    "Success time.Duration with days and ticks",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Timespan}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": 50*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Millisecond}),
+			want:    map[string]string{"key1": "time(2.02:03:04.5000000)"},
+		},
+		{
+			desc:    "Success negative time.Duration",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Timespan}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": -(time.Minute + 1200*time.Nanosecond)}),
+			want:    map[string]string{"key1": "time(-00:01:00.0000012)"},
+		},
+		{
+			desc:    "Success null value.Timespan",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Timespan}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": value.Timespan{}}),
+			want:    map[string]string{"key1": "time(null)"},
+		},
+		{
+			desc:    "Success value.GUID and null value.GUID",
+			qParams: NewDefinitions().Must(map[string]ParamType{"a": {Type: types.GUID}, "b": {Type: types.GUID}}),
+			qValues: NewParameters().Must(map[string]interface{}{"a": value.GUID{Value: uu, Valid: true}, "b": value.GUID{}}),
+			want:    map[string]string{"a": fmt.Sprintf("guid(%s)", uu.String()), "b": "guid(null)"},
+		},
+		{
+			desc:    "Success map[string]any for dynamic",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Dynamic}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": map[string]any{"a": []int{1, 2}, "b": `quote"d)`}}),
+			want:    map[string]string{"key1": `dynamic({"a":[1,2],"b":"quote\"d)"})`},
+		},
+		{
+			desc:    "Success json.RawMessage for dynamic",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Dynamic}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": json.RawMessage(`{ "a": 1 }`)}),
+			want:    map[string]string{"key1": `dynamic({"a":1})`},
+		},
+		{
+			desc:    "Success value.Dynamic, null value.Dynamic and nil for dynamic",
+			qParams: NewDefinitions().Must(map[string]ParamType{"a": {Type: types.Dynamic}, "b": {Type: types.Dynamic}, "c": {Type: types.Dynamic}}),
+			qValues: NewParameters().Must(map[string]interface{}{"a": value.Dynamic{Value: []byte(`[ 1, "x" ]`), Valid: true}, "b": value.Dynamic{}, "c": nil}),
+			want:    map[string]string{"a": `dynamic([1,"x"])`, "b": "dynamic(null)", "c": "dynamic(null)"},
+		},
+		{
+			desc:    "Should be JSON, isn't",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Dynamic}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": json.RawMessage(`1) | take 1`)}),
+			err:     true,
+		},
+		{
+			desc:    "Success value.Kusto for the other types",
+			qParams: NewDefinitions().Must(map[string]ParamType{"a": {Type: types.Bool}, "b": {Type: types.Int}, "c": {Type: types.Long}, "d": {Type: types.String}, "e": {Type: types.DateTime}}),
+			qValues: NewParameters().Must(map[string]interface{}{"a": value.Bool{Value: true, Valid: true}, "b": value.Int{}, "c": value.Long{Value: 2, Valid: true}, "d": value.String{Value: "s", Valid: true}, "e": value.DateTime{}}),
+			want:    map[string]string{"a": "bool(true)", "b": "int(null)", "c": "long(2)", "d": "s", "e": "datetime(null)"},
+		},
+		{
+			desc:    "Should be a decimal number, isn't",
+			qParams: NewDefinitions().Must(map[string]ParamType{"key1": {Type: types.Decimal}}),
+			qValues: NewParameters().Must(map[string]interface{}{"key1": "1); drop table"}),
+			err:     true,
+		},
 	}
 
 	for _, test := range tests {
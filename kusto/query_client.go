@@ -0,0 +1,33 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// Querier is implemented by a client that can run queries, such as *Client. Code that only runs queries should accept
+// a Querier, which a fake can implement in tests.
+type Querier interface {
+	Query(ctx context.Context, db string, query Stmt, options ...QueryOption) (*RowIterator, error)
+}
+
+// Mgmter is implemented by a client that can run management commands, such as *Client.
+type Mgmter interface {
+	Mgmt(ctx context.Context, db string, query Stmt, options ...MgmtOption) (*RowIterator, error)
+}
+
+// QueryClient is the part of *Client that is needed by most of its consumers, such as the ingest package. Methods
+// will not be added to it: code that needs other methods of *Client should declare its own interface, which can embed
+// Querier and Mgmter.
+type QueryClient interface {
+	io.Closer
+	Querier
+	Mgmter
+	QueryToJson(ctx context.Context, db string, query Stmt, options ...QueryOption) (string, error)
+	Endpoint() string
+	ClientDetails() *ClientDetails
+	HttpClient() *http.Client
+}
+
+var _ QueryClient = (*Client)(nil)
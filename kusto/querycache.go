@@ -0,0 +1,236 @@
+package kusto
+
+// querycache.go implements WithQueryCache(), which stores the responses of queries in a Cache provided by the
+// application, such as a cache shared by the processes of a service, and replays them for identical queries.
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+)
+
+// Cache stores the responses of the queries of a Client set up with WithQueryCache(). Its methods must be safe for
+// concurrent use. A Cache that fails, such as a remote cache that cannot be reached, should behave as if the key was
+// not found, as the query is then sent to the service.
+type Cache interface {
+	// Get returns the value stored for key, and true, or false if there is none or it expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set stores value for key for ttl. value must not be retained after Set returns, unless copied.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// queryCacheMode is how a query uses the cache set up by WithQueryCache().
+type queryCacheMode uint8
+
+const (
+	// queryCacheUse reads the response from the cache, or stores it there.
+	queryCacheUse queryCacheMode = iota
+	// queryCacheBypass neither reads nor stores the response, see BypassQueryCache().
+	queryCacheBypass
+	// queryCacheRefresh stores the response without reading the cache, see RefreshQueryCache().
+	queryCacheRefresh
+)
+
+// WithQueryCache stores the responses of the Query() calls of the Client in cache for ttl, and answers the identical
+// queries that follow with the stored response, which is decoded into a new RowIterator as if it came from the
+// service. Queries are identical when they are sent to the same endpoint and database, with the same statement once
+// its comments and extra whitespace are removed, the same parameters and the same options, except for the
+// servertimeout. The keys are the hex SHA-256 of these.
+// The response body, the JSON of the v2 frames, is stored as it was received, so the whole response is held in
+// memory before it is stored; the RowIterator of a response from the cache has no request or response header.
+// Responses with errors, commands and queries made with OnBehalfOf() are never stored. Use BypassQueryCache() or
+// RefreshQueryCache() to skip the cache for a query. The cache of the service, which a query can use with
+// QueryResultsCacheMaxAge(), is separate. A nil cache or a ttl that is not positive is ignored.
+// The keys do not identify the principal the Client authenticates as, so a Cache must only be shared by clients that
+// are allowed to read the same data: a client could otherwise be answered with results its principal cannot read.
+// Unlike WithClientResultsCache(), an in-process cache of the decoded rows of the queries marked with Cacheable(),
+// WithQueryCache() stores the bytes of every response in a store the application picks, which can outlive the process
+// and be shared, at the cost of decoding the response again on each hit. A Cacheable() query of a Client with both
+// caches uses the results cache only.
+func WithQueryCache(cache Cache, ttl time.Duration) Option {
+	return func(c *Client) {
+		if cache == nil || ttl <= 0 {
+			return
+		}
+		c.queryCache = &queryCache{cache: cache, ttl: ttl}
+	}
+}
+
+// BypassQueryCache sends the query to the service without reading or storing its response in the cache set up by
+// WithQueryCache().
+func BypassQueryCache() QueryOption {
+	return func(q *queryOptions) error {
+		q.queryCacheMode = queryCacheBypass
+		return nil
+	}
+}
+
+// RefreshQueryCache sends the query to the service even if its response is in the cache set up by WithQueryCache(),
+// and replaces the stored response with the new one.
+func RefreshQueryCache() QueryOption {
+	return func(q *queryOptions) error {
+		q.queryCacheMode = queryCacheRefresh
+		return nil
+	}
+}
+
+// queryCache is the cache set up by WithQueryCache().
+type queryCache struct {
+	cache Cache
+	ttl   time.Duration
+}
+
+// query answers a query from the cache, or by sending it and storing its response.
+func (qc *queryCache) query(ctx context.Context, cancel context.CancelFunc, client *Client, db string, query Stmt, opts *queryOptions) (*RowIterator, error) {
+	if opts.queryCacheMode == queryCacheBypass || isCommand(query.String()) || !isEmpty(opts.userAssertion) {
+		return client.runQuery(ctx, cancel, db, query, opts)
+	}
+	key, ok := queryCacheKey(client.endpoint, db, query, opts)
+	if !ok {
+		return client.runQuery(ctx, cancel, db, query, opts)
+	}
+
+	if opts.queryCacheMode != queryCacheRefresh {
+		// An empty entry is replaced by the response of the query.
+		if b, ok := qc.cache.Get(ctx, key); ok && len(b) > 0 {
+			return client.replayQuery(ctx, cancel, nil, nil, b, opts)
+		}
+	}
+
+	conn, err := client.getConn(queryCall, connOptions{queryOptions: opts})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	jsonResp, err := conn.queryToJson(ctx, db, query, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	body := []byte(jsonResp.body)
+	if !responseHasErrors(ctx, body) {
+		qc.cache.Set(ctx, key, body, qc.ttl)
+	}
+	return client.replayQuery(ctx, cancel, jsonResp.reqHeader, jsonResp.respHeader, body, opts)
+}
+
+// replayQuery returns a RowIterator over the frames of body, a response that was read in full. The headers of the
+// request and of the response are nil if the response comes from the cache.
+func (c *Client) replayQuery(ctx context.Context, cancel context.CancelFunc, reqHeader, respHeader http.Header, body []byte, opts *queryOptions) (*RowIterator, error) {
+	dec := &v2.Decoder{PrimaryResultsOnly: opts.primaryResultsOnly}
+	frameCh := dec.Decode(ctx, bytes.NewReader(body), errors.OpQuery)
+
+	iter, err := startRowIterator(ctx, cancel, execResp{reqHeader: reqHeader, respHeader: respHeader, frameCh: frameCh}, <-frameCh, errors.OpQuery, opts.progressCallback)
+	if err != nil {
+		return nil, err
+	}
+	iter.primaryResultsOnly = opts.primaryResultsOnly
+	iter.location = opts.location
+	iter.requestProperties = newResolvedProperties(opts.requestProperties)
+	iter.setLogger(c.logger)
+	return iter, nil
+}
+
+// responseHasErrors reports if the frames of a response hold an error, or cannot be decoded, in which case the
+// response is not cached.
+func responseHasErrors(ctx context.Context, body []byte) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dec := &v2.Decoder{}
+	hasErrors := false
+	for fr := range dec.Decode(ctx, bytes.NewReader(body), errors.OpQuery) {
+		switch fr := fr.(type) {
+		case frames.Error:
+			hasErrors = true
+		case frames.DataSetCompletion:
+			hasErrors = hasErrors || fr.HasErrors || fr.Cancelled
+		}
+	}
+	return hasErrors || ctx.Err() != nil
+}
+
+// queryCacheKey returns the key of the response of a query in the Cache. ok is false if the query cannot be cached.
+func queryCacheKey(endpoint, db string, query Stmt, opts *queryOptions) (key string, ok bool) {
+	b, ok := queryIdentity(endpoint, db, normalizeCSL(query.String()), opts)
+	if !ok {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// normalizeCSL returns query without its comments, with its runs of whitespace replaced by a single space and
+// trimmed, so that queries that only differ in their layout have the same key. The string literals are kept as is.
+func normalizeCSL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+			continue
+		case strings.HasPrefix(query[i:], "//"):
+			space = true
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(query)
+			}
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		n := literalLen(query[i:])
+		b.WriteString(query[i : i+n])
+		i += n
+	}
+	return b.String()
+}
+
+// literalLen returns the length of the string literal s starts with, or 1 if it does not start with one. An
+// unterminated literal runs to the end of s.
+func literalLen(s string) int {
+	switch {
+	case strings.HasPrefix(s, "```"):
+		if end := strings.Index(s[3:], "```"); end >= 0 {
+			return end + 6
+		}
+		return len(s)
+	case len(s) > 1 && s[0] == '@' && (s[1] == '\'' || s[1] == '"'):
+		// A verbatim string literal has no escapes, a doubled quote being two literals that follow each other.
+		if end := strings.IndexByte(s[2:], s[1]); end >= 0 {
+			return end + 3
+		}
+		return len(s)
+	case len(s) > 1 && (s[0] == 'h' || s[0] == 'H') && (s[1] == '\'' || s[1] == '"'):
+		// An obfuscated string literal.
+		return 1 + literalLen(s[1:])
+	case s[0] == '\'' || s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case s[0]:
+				return i + 1
+			}
+		}
+		return len(s)
+	}
+	return 1
+}
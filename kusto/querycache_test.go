@@ -0,0 +1,241 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache is a Cache in a map, which records the ttl of the values it is given.
+type mapCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	sets   int
+	ttls   []time.Duration
+}
+
+func (m *mapCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.values[key]
+	return b, ok
+}
+
+func (m *mapCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.values == nil {
+		m.values = map[string][]byte{}
+	}
+	m.values[key] = append([]byte(nil), value...)
+	m.sets++
+	m.ttls = append(m.ttls, ttl)
+}
+
+// queryCacheTransport is a fake http.RoundTripper that counts the queries and answers with body.
+type queryCacheTransport struct {
+	requests atomic.Int64
+	body     string
+}
+
+func (q *queryCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	q.requests.Add(1)
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(q.body))}, nil
+}
+
+func TestQueryCache(t *testing.T) {
+	t.Parallel()
+
+	want := []value.Values{
+		{value.Long{Value: 1, Valid: true}},
+		{value.Long{Value: 2, Valid: true}},
+		{value.Long{Value: 3, Valid: true}},
+	}
+
+	withName := func(name string) Stmt {
+		return NewStmt("T | where Name == name").MustDefinitions(
+			NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}),
+		).MustParameters(NewParameters().Must(QueryValues{"name": name}))
+	}
+
+	hasErrors := strings.Replace(dedupTestStream, `"HasErrors":false`, `"HasErrors":true`, 1)
+
+	tests := []struct {
+		desc          string
+		body          string
+		first, second Stmt
+		secondOptions []QueryOption
+		requests      int64
+		sets          int
+	}{
+		{
+			desc:     "Cached",
+			first:    NewStmt("T"),
+			second:   NewStmt("T"),
+			requests: 1,
+			sets:     1,
+		},
+		{
+			desc:     "Same statement with another layout",
+			first:    NewStmt("T\n| take 3 // the first rows"),
+			second:   NewStmt("  T | take 3 "),
+			requests: 1,
+			sets:     1,
+		},
+		{
+			desc:     "Other statement",
+			first:    NewStmt("T | where x == 'a  b'"),
+			second:   NewStmt("T | where x == 'a b'"),
+			requests: 2,
+			sets:     2,
+		},
+		{
+			desc:     "Other parameters",
+			first:    withName("a"),
+			second:   withName("b"),
+			requests: 2,
+			sets:     2,
+		},
+		{
+			desc:          "Other options",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			secondOptions: []QueryOption{QueryResultsCacheMaxAge(time.Minute)},
+			requests:      2,
+			sets:          2,
+		},
+		{
+			desc:          "Bypass",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			secondOptions: []QueryOption{BypassQueryCache()},
+			requests:      2,
+			sets:          1,
+		},
+		{
+			desc:          "Refresh",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			secondOptions: []QueryOption{RefreshQueryCache()},
+			requests:      2,
+			sets:          2,
+		},
+		{
+			desc:     "Errors are not cached",
+			body:     hasErrors,
+			first:    NewStmt("T"),
+			second:   NewStmt("T"),
+			requests: 2,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &queryCacheTransport{body: dedupTestStream}
+			if test.body != "" {
+				transport.body = test.body
+			}
+			client := newTestClient(t, "https://querycache.kusto.windows.net", transport)
+			cache := &mapCache{}
+			WithQueryCache(cache, time.Minute)(client)
+
+			for i, query := range []Stmt{test.first, test.second} {
+				var options []QueryOption
+				if i == 1 {
+					options = test.secondOptions
+				}
+				iter, err := client.Query(context.Background(), "db", query, options...)
+				require.NoError(t, err)
+				got := cachedTestRows(t, iter)
+				if test.body == "" {
+					assert.Equal(t, want, got)
+				}
+			}
+
+			assert.Equal(t, test.requests, transport.requests.Load())
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+			assert.Equal(t, test.sets, cache.sets)
+			for _, ttl := range cache.ttls {
+				assert.Equal(t, time.Minute, ttl)
+			}
+		})
+	}
+}
+
+func TestQueryCacheReplay(t *testing.T) {
+	t.Parallel()
+
+	transport := &queryCacheTransport{body: dedupTestStream}
+	client := newTestClient(t, "https://querycache.kusto.windows.net", transport)
+	cache := &mapCache{}
+	WithQueryCache(cache, time.Minute)(client)
+
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	cachedTestRows(t, iter)
+
+	// The raw response is stored, under a key that does not reveal the query.
+	cache.mu.Lock()
+	require.Len(t, cache.values, 1)
+	for key, b := range cache.values {
+		assert.Len(t, key, 64)
+		assert.Equal(t, dedupTestStream, string(b))
+	}
+	cache.mu.Unlock()
+
+	// A hit is decoded again, with the options of the call.
+	iter, err = client.Query(context.Background(), "db", NewStmt("T"), PreserveLocation(time.Local))
+	require.NoError(t, err)
+	assert.Equal(t, []value.Values{
+		{value.Long{Value: 1, Valid: true}},
+		{value.Long{Value: 2, Valid: true}},
+		{value.Long{Value: 3, Valid: true}},
+	}, cachedTestRows(t, iter))
+	assert.Equal(t, int64(1), transport.requests.Load())
+
+	// Without a cache, or with a zero ttl, nothing is set up.
+	WithQueryCache(nil, time.Minute)(&Client{})
+	disabled := &Client{}
+	WithQueryCache(cache, 0)(disabled)
+	assert.Nil(t, disabled.queryCache)
+}
+
+func TestNormalizeCSL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "  T  |\n\ttake 10\n", want: "T | take 10"},
+		{query: "// comment\nT // trailing\n| count", want: "T | count"},
+		{query: "T | where x == 'a  // b'", want: "T | where x == 'a  // b'"},
+		{query: `T | where x == "a \"  b"  | count`, want: `T | where x == "a \"  b" | count`},
+		{query: `T | where x == @'C:\'  | count`, want: `T | where x == @'C:\' | count`},
+		{query: "T | where x == h'secret  value'", want: "T | where x == h'secret  value'"},
+		{query: "print ```a\n\n  b```   ", want: "print ```a\n\n  b```"},
+		{query: "T | where x == 'unterminated  ", want: "T | where x == 'unterminated  "},
+		{query: "", want: ""},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, normalizeCSL(test.query), test.query)
+	}
+}
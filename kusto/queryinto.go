@@ -0,0 +1,87 @@
+package kusto
+
+// queryinto.go implements QueryInto(), which decodes the rows of a query into structs.
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// Unmarshaler is implemented by the types of struct fields that decode a Kusto value themselves, with
+// UnmarshalKusto(v value.Kusto) error. It takes precedence over the default conversion in QueryInto() and
+// table.Row.ToStruct().
+type Unmarshaler = table.Unmarshaler
+
+// QueryInto runs query and decodes every row of the primary result into a T, which must be a struct type. Columns
+// are mapped to the fields as with table.Row.ToStruct(): by the `kusto` tag of a field, or else by its name.
+// Errors inline within the rows, which the service sends when part of a query failed, do not stop the iteration:
+// the rows are returned along with an *errors.CombinedError holding the inline errors. Any other error ends the
+// iteration, and is returned with the rows decoded before it. The rows of a progressive result that replace the
+// previous ones, see table.Row.Replace, discard the values decoded before them.
+// Example:
+//
+//	type Node struct {
+//		Name string    `kusto:"NodeName"`
+//		Seen time.Time `kusto:"LastSeen"`
+//	}
+//
+//	nodes, err := kusto.QueryInto[Node](ctx, client, "database", kusto.NewStmt("Nodes | take 10"))
+func QueryInto[T any](ctx context.Context, client *Client, db string, query Stmt, options ...QueryOption) ([]T, error) {
+	if err := checkStructType[T](); err != nil {
+		return nil, err
+	}
+
+	iter, err := client.Query(ctx, db, query, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Stop()
+
+	var (
+		values    []T
+		inlineErr []error
+	)
+	err = iter.DoOnRowOrError(func(row *table.Row, e *errors.Error) error {
+		if e != nil {
+			inlineErr = append(inlineErr, e)
+			return nil
+		}
+		v, err := decodeRow[T](row)
+		if err != nil {
+			return err
+		}
+		if row.Replace {
+			values = values[:0]
+		}
+		values = append(values, v)
+		return nil
+	})
+	if err != nil {
+		return values, err
+	}
+	if len(inlineErr) > 0 {
+		return values, errors.GetCombinedError(inlineErr...)
+	}
+	return values, nil
+}
+
+// checkStructType returns an error if T is not a struct type, which QueryInto() and QuerySeq() decode rows into.
+func checkStructType[T any]() error {
+	if t := reflect.TypeOf((*T)(nil)).Elem(); t.Kind() != reflect.Struct {
+		return errors.ES(errors.OpQuery, errors.KClientArgs, "type %s is not a struct, rows can only be decoded into structs", t).SetNoRetry()
+	}
+	return nil
+}
+
+// decodeRow decodes row into a new T.
+func decodeRow[T any](row *table.Row) (T, error) {
+	var v T
+	if err := row.ToStruct(&v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
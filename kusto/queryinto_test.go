@@ -0,0 +1,191 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// intoColumns are the columns of the fixtures of QueryInto() and QuerySeq(), one of every type.
+const intoColumns = `"Columns":[{"ColumnName":"vb","ColumnType":"bool"},{"ColumnName":"vdate","ColumnType":"datetime"},` +
+	`{"ColumnName":"vobj","ColumnType":"dynamic"},{"ColumnName":"vguid","ColumnType":"guid"},{"ColumnName":"vnum","ColumnType":"int"},` +
+	`{"ColumnName":"vlong","ColumnType":"long"},{"ColumnName":"vreal","ColumnType":"real"},{"ColumnName":"vstr","ColumnType":"string"},` +
+	`{"ColumnName":"vspan","ColumnType":"timespan"},{"ColumnName":"vdec","ColumnType":"decimal"}]`
+
+const (
+	intoRow1 = `[true,"2020-03-04T14:05:01.3109965Z",{"moshe":"value"},"74be27de-1e4e-49d9-b579-fe0b331d3642",1,9223372036854775807,0.01,"asdf","01:23:45.6789000","2.00000000000001"]`
+	intoRow2 = `[false,"2021-01-01T00:00:00Z",{"a":[1,2]},"00000000-0000-0000-0000-000000000001",-2,-3,1.5,"","00:00:01","123.45"]`
+
+	intoInlineErr = `{"OneApiErrors":[{"error":{"code":"LimitsExceeded","message":"Request is invalid and cannot be executed.",` +
+		`"@type":"Kusto.Data.Exceptions.KustoServicePartialQueryFailureLimitsExceededException",` +
+		`"@message":"Query execution has exceeded the allowed limits (80DA0003): .","@permanent":false}}]}`
+)
+
+// intoFixture is the response to a query with the rows of the QueryInto() tests.
+func intoFixture(progressive bool, inlineErr bool) []byte {
+	if !progressive {
+		rows := intoRow1 + "," + intoRow2
+		if inlineErr {
+			rows += "," + intoInlineErr
+		}
+		return []byte("[\n" + `{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},` + "\n" +
+			`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` + intoColumns + `,"Rows":[` + rows + "]},\n" +
+			`{"FrameType":"DataSetCompletion","HasErrors":` + boolJSON(inlineErr) + `,"Cancelled":false}` + "\n]")
+	}
+
+	rows := intoRow2
+	if inlineErr {
+		rows += "," + intoInlineErr
+	}
+	return []byte("[\n" + `{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},` + "\n" +
+		`{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` + intoColumns + "},\n" +
+		`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[` + intoRow1 + "]},\n" +
+		`{"FrameType":"TableProgress","TableId":1,"TableProgress":50},` + "\n" +
+		`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[` + rows + "]},\n" +
+		`{"FrameType":"TableCompletion","TableId":1,"RowCount":2},` + "\n" +
+		`{"FrameType":"DataSetCompletion","HasErrors":` + boolJSON(inlineErr) + `,"Cancelled":false}` + "\n]")
+}
+
+// intoReplaceFixture is the response to a progressive query whose second fragment replaces the first row with the
+// second one.
+func intoReplaceFixture() []byte {
+	return []byte("[\n" + `{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},` + "\n" +
+		`{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` + intoColumns + "},\n" +
+		`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[` + intoRow1 + "]},\n" +
+		`{"FrameType":"TableFragment","TableFragmentType":"DataReplace","TableId":1,"Rows":[` + intoRow2 + "]},\n" +
+		`{"FrameType":"TableCompletion","TableId":1,"RowCount":1},` + "\n" +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}` + "\n]")
+}
+
+func boolJSON(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// intoRecord has a field for every column type of intoColumns.
+type intoRecord struct {
+	Bool     bool                   `kusto:"vb"`
+	DateTime time.Time              `kusto:"vdate"`
+	Dynamic  map[string]interface{} `kusto:"vobj"`
+	GUID     uuid.UUID              `kusto:"vguid"`
+	Int      int32                  `kusto:"vnum"`
+	Long     int64                  `kusto:"vlong"`
+	Real     float64                `kusto:"vreal"`
+	String   string                 `kusto:"vstr"`
+	Timespan time.Duration          `kusto:"vspan"`
+	Decimal  string                 `kusto:"vdec"`
+	Ignored  map[string]interface{} `kusto:"-"`
+}
+
+var intoWant = []intoRecord{
+	{
+		Bool:     true,
+		DateTime: time.Date(2020, 3, 4, 14, 5, 1, 310996500, time.UTC),
+		Dynamic:  map[string]interface{}{"moshe": "value"},
+		GUID:     uuid.MustParse("74be27de-1e4e-49d9-b579-fe0b331d3642"),
+		Int:      1,
+		Long:     9223372036854775807,
+		Real:     0.01,
+		String:   "asdf",
+		Timespan: time.Hour + 23*time.Minute + 45*time.Second + 678900*time.Microsecond,
+		Decimal:  "2.00000000000001",
+	},
+	{
+		DateTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		Dynamic:  map[string]interface{}{"a": []interface{}{float64(1), float64(2)}},
+		GUID:     uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		Int:      -2,
+		Long:     -3,
+		Real:     1.5,
+		Timespan: time.Second,
+		Decimal:  "123.45",
+	},
+}
+
+// intoClient returns a Client whose queries are answered with body.
+func intoClient(t *testing.T, body []byte) *Client {
+	return newTestClient(t, "https://into.kusto.windows.net", fixtureTransport{body: body})
+}
+
+func TestQueryInto(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		progressive   bool
+		inlineErr     bool
+		wantInlineErr bool
+	}{
+		{desc: "Non-progressive"},
+		{desc: "Progressive", progressive: true},
+		{desc: "Non-progressive with a partial error", inlineErr: true, wantInlineErr: true},
+		{desc: "Progressive with a partial error", progressive: true, inlineErr: true, wantInlineErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := intoClient(t, intoFixture(test.progressive, test.inlineErr))
+			got, err := QueryInto[intoRecord](context.Background(), client, "db", NewStmt("T"))
+			assert.Equal(t, intoWant, got)
+			if !test.wantInlineErr {
+				require.NoError(t, err)
+				return
+			}
+
+			// The rows are returned along with the partial error.
+			var combined *errors.CombinedError
+			require.True(t, goErrors.As(err, &combined), "got %T: %v", err, err)
+			require.Len(t, combined.Errors, 1)
+			var kerr *errors.Error
+			require.True(t, goErrors.As(combined.Errors[0], &kerr))
+			assert.Equal(t, errors.KLimitsExceeded, kerr.Kind)
+		})
+	}
+}
+
+func TestQueryIntoReplace(t *testing.T) {
+	t.Parallel()
+
+	client := intoClient(t, intoReplaceFixture())
+	got, err := QueryInto[intoRecord](context.Background(), client, "db", NewStmt("T"))
+	require.NoError(t, err)
+	assert.Equal(t, intoWant[1:], got)
+}
+
+func TestQueryIntoErrors(t *testing.T) {
+	t.Parallel()
+
+	client := intoClient(t, intoFixture(false, false))
+
+	// T must be a struct, which is checked before sending the query.
+	_, err := QueryInto[int](context.Background(), client, "db", NewStmt("T"))
+	var kerr *errors.Error
+	require.True(t, goErrors.As(err, &kerr), "got %T: %v", err, err)
+	assert.Equal(t, errors.KClientArgs, kerr.Kind)
+
+	// A row that cannot be decoded ends the iteration.
+	type mismatch struct {
+		Bool string `kusto:"vb"`
+	}
+	got, err := QueryInto[mismatch](context.Background(), client, "db", NewStmt("T"))
+	assert.Error(t, err)
+	assert.Empty(t, got)
+
+	// The error of the query is returned.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got2, err := QueryInto[intoRecord](ctx, client, "db", NewStmt("T"))
+	assert.Error(t, err)
+	assert.Empty(t, got2)
+}
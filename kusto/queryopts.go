@@ -4,6 +4,7 @@ package kusto
 // it clogs up the main kusto.go file.
 
 import (
+	"context"
 	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
@@ -19,10 +20,66 @@ type requestProperties struct {
 	Application     string
 	User            string
 	ClientRequestID string
+
+	// typed holds the common options, which are not stored in Options.
+	typed typedOptions
+
+	// hedgeAttempt is the attempt number of a hedged request, 0 being the original request.
+	hedgeAttempt int
+	// readOnlyLocked keeps request_readonly set, see RequestReadonlyLocked().
+	readOnlyLocked bool
 }
 
 type queryOptions struct {
 	requestProperties *requestProperties
+	// primaryResultsOnly causes all non-primary tables to be dropped when decoding the response.
+	primaryResultsOnly bool
+	// hedge holds the settings for hedged requests, nil if not hedging.
+	hedge *hedgeOptions
+	// noDedup opts the query out of the deduplication set up by WithQueryDeduplication().
+	noDedup bool
+	// location is the location datetime values are converted to, nil for UTC.
+	location *time.Location
+	// cacheable allows the results to be served from the cache set up by WithClientResultsCache().
+	cacheable bool
+	// timeoutHeadroom is subtracted from the time left before the context deadline to derive the servertimeout.
+	timeoutHeadroom time.Duration
+	// offloadThreshold is the size above which parameters are sent as let statements, 0 to never offload them.
+	offloadThreshold int
+	// query is the Stmt to send, which is the one passed to setQueryOptions() with its large parameters offloaded.
+	query Stmt
+	// userAssertion is the token of the user the query is made for, set by OnBehalfOf().
+	userAssertion string
+	// queryCacheMode is how the query uses the cache set up by WithQueryCache().
+	queryCacheMode queryCacheMode
+	// progressCallback is set by WithProgressCallback(), nil if there is none.
+	progressCallback func(tableOrdinal int64, percent float64)
+}
+
+// queryOptionsKey is the context key for the QueryOptions set with ContextWithQueryOptions().
+type queryOptionsKey struct{}
+
+// ContextWithQueryOptions returns a copy of ctx that carries options, which are applied to any Query() made with
+// the returned context or a context derived from it. This allows middleware to set options such as Application()
+// or ClientRequestID() without passing them through every call.
+// Options are applied in this order, so that the later ones win: the defaults of the Client, see
+// WithDefaultQueryOptions(), the options of the contexts ctx was derived from, options, and then the options passed
+// to Query().
+func ContextWithQueryOptions(ctx context.Context, options ...QueryOption) context.Context {
+	if len(options) == 0 {
+		return ctx
+	}
+	parent := contextQueryOptions(ctx)
+	merged := make([]QueryOption, 0, len(parent)+len(options))
+	merged = append(merged, parent...)
+	merged = append(merged, options...)
+	return context.WithValue(ctx, queryOptionsKey{}, merged)
+}
+
+// contextQueryOptions returns the QueryOptions carried by ctx.
+func contextQueryOptions(ctx context.Context) []QueryOption {
+	options, _ := ctx.Value(queryOptionsKey{}).([]QueryOption)
+	return options
 }
 
 const NoRequestTimeoutValue = "norequesttimeout"
@@ -69,12 +126,16 @@ const RequestDescriptionValue = "request_description"
 const RequestExternalTableDisabledValue = "request_external_table_disabled"
 const RequestImpersonationDisabledValue = "request_impersonation_disabled"
 const RequestReadonlyValue = "request_readonly"
+const RequestReadonlyHardlineValue = "request_readonly_hardline"
 const RequestRemoteEntitiesDisabledValue = "request_remote_entities_disabled"
 const RequestSandboxedExecutionDisabledValue = "request_sandboxed_execution_disabled"
 const RequestUserValue = "request_user"
 const TruncationMaxRecordsValue = "truncation_max_records"
 const TruncationMaxSizeValue = "truncation_max_size"
 const ValidatePermissionsValue = "validate_permissions"
+const ResultsErrorReportingPlacementValue = "results_error_reporting_placement"
+const ResultsV2FragmentPrimaryTablesValue = "results_v2_fragment_primary_tables"
+const ResultsV2NewlinesBetweenFramesValue = "results_v2_newlines_between_frames"
 
 // ClientRequestID sets the x-ms-client-request-id header, and can be used to identify the request in the `.show queries` output.
 func ClientRequestID(clientRequestID string) QueryOption {
@@ -103,15 +164,16 @@ func User(userName string) QueryOption {
 // NoRequestTimeout enables setting the request timeout to its maximum value.
 func NoRequestTimeout() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[NoRequestTimeoutValue] = true
+		q.requestProperties.setOption(NoRequestTimeoutValue, true)
 		return nil
 	}
 }
 
-// NoTruncation enables suppressing truncation of the query results returned to the caller.
+// NoTruncation enables suppressing truncation of the query results returned to the caller. By default, the service
+// truncates the results at 500,000 records or 64 MB, see TruncationMaxRecords() and TruncationMaxSize().
 func NoTruncation() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[NoTruncationValue] = true
+		q.requestProperties.setOption(NoTruncationValue, true)
 		return nil
 	}
 }
@@ -119,20 +181,86 @@ func NoTruncation() QueryOption {
 // ResultsProgressiveDisable disables the progressive query stream.
 func ResultsProgressiveDisable() QueryOption {
 	return func(q *queryOptions) error {
-		delete(q.requestProperties.Options, "results_progressive_enabled")
+		q.requestProperties.deleteOption(resultsProgressiveEnabledValue)
+		return nil
+	}
+}
+
+// ResultsV2FragmentedStreaming has the service send the primary results in TableFragment frames of a response that
+// is not progressive, so that rows are yielded as each fragment is decoded, instead of once the whole table was
+// decoded from a single DataTable frame. Unlike the progressive framing, which it replaces, the fragments only ever
+// append rows and no TableProgress frames are sent. It is meant for queries that return many rows, to bound the
+// memory used while they are read. The frames are separated by newlines, so a raw response can be read line by line.
+func ResultsV2FragmentedStreaming() QueryOption {
+	return func(q *queryOptions) error {
+		q.requestProperties.deleteOption(resultsProgressiveEnabledValue)
+		q.requestProperties.setOption(ResultsV2FragmentPrimaryTablesValue, true)
+		q.requestProperties.setOption(ResultsV2NewlinesBetweenFramesValue, true)
+		return nil
+	}
+}
+
+// PrimaryResultsOnly drops every table that is not the primary result before its rows are decoded. This reduces
+// the work done by the client and the time it takes to receive the first row, which is useful for latency-sensitive
+// callers that do not need the query properties or completion information.
+// When set, GetNonPrimary(), GetExtendedProperties() and GetQueryCompletionInformation() on the RowIterator will
+// return NonPrimarySuppressedErr. See BenchmarkTimeToFirstRow in the frames/v2 package for a measurement of the gain.
+func PrimaryResultsOnly() QueryOption {
+	return func(q *queryOptions) error {
+		q.primaryResultsOnly = true
+		return nil
+	}
+}
+
+// PreserveLocation converts the datetime values of the rows into loc, for presentation layers that show local times.
+// By default, datetime values are returned in UTC, which is how Kusto stores them. Only the location changes, the
+// instant is the same. Non-primary tables are not converted.
+func PreserveLocation(loc *time.Location) QueryOption {
+	return func(q *queryOptions) error {
+		if loc == nil {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "PreserveLocation() cannot be passed a nil *time.Location").SetNoRetry()
+		}
+		q.location = loc
+		return nil
+	}
+}
+
+// Hedged issues a duplicate of the query if no response headers have been received after delay, up to maxAttempts
+// requests in total. The first response to start streaming is used and the other requests are cancelled.
+// Each duplicate request has its own client request ID and is tagged with the x-ms-hedge-attempt header.
+// This should only be used for idempotent read queries where latency matters more than the extra load on the service.
+// Management commands cannot be hedged. Outcomes are reported in Client.HedgeStats().
+func Hedged(delay time.Duration, maxAttempts int) QueryOption {
+	return func(q *queryOptions) error {
+		if delay <= 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "Hedged() delay must be positive, was %v", delay)
+		}
+		if maxAttempts < 2 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "Hedged() maxAttempts must be at least 2, was %d", maxAttempts)
+		}
+		q.hedge = &hedgeOptions{delay: delay, maxAttempts: maxAttempts}
+		return nil
+	}
+}
+
+// NoDedup makes the query always send its own request, even if the client was created with WithQueryDeduplication().
+func NoDedup() QueryOption {
+	return func(q *queryOptions) error {
+		q.noDedup = true
 		return nil
 	}
 }
 
-// queryServerTimeout is the amount of time the server will allow a query to take.
-// NOTE: I have made the serverTimeout private. For the moment, I'm going to use the context.Context timer
-// to set timeouts via this private method.
-func queryServerTimeout(d time.Duration) QueryOption {
+// ServerTimeout sets the servertimeout request property, the amount of time the server will allow the query to take,
+// instead of deriving it from the deadline of the context. See WithTimeoutHeadroom(). It can't be more than 1 hour,
+// the maximum of the service, which is also the most that is derived from the deadline of the context.
+func ServerTimeout(d time.Duration) QueryOption {
 	return func(q *queryOptions) error {
-		if d > 1*time.Hour {
-			return errors.ES(errors.OpQuery, errors.KClientArgs, "ServerTimeout option was set to %v, but can't be more than 1 hour", d)
+		if d <= 0 || d > maxServerTimeout {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "ServerTimeout option was set to %v, but must be more than 0 and can't be more than %v, "+
+				"the maximum of the service", d, maxServerTimeout).SetNoRetry()
 		}
-		q.requestProperties.Options[ServerTimeoutValue] = value.Timespan{Valid: true, Value: d}.Marshal()
+		q.requestProperties.setOption(ServerTimeoutValue, value.Timespan{Valid: true, Value: d}.Marshal())
 		return nil
 	}
 }
@@ -140,10 +268,10 @@ func queryServerTimeout(d time.Duration) QueryOption {
 // CustomQueryOption exists to allow a QueryOption that is not defined in the Go SDK, as all options
 // are not defined. Please Note: you should always use the type safe options provided below when available.
 // Also note that Kusto does not error on non-existent parameter names or bad values, it simply doesn't
-// work as expected.
+// work as expected. A time.Duration or value.Timespan is sent as a timespan literal, such as "1.02:03:04.005".
 func CustomQueryOption(paramName string, i interface{}) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[paramName] = i
+		q.requestProperties.setOption(paramName, i)
 		return nil
 	}
 }
@@ -151,32 +279,43 @@ func CustomQueryOption(paramName string, i interface{}) QueryOption {
 // DeferPartialQueryFailures disables reporting partial query failures as part of the result set.
 func DeferPartialQueryFailures() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[DeferPartialQueryFailuresValue] = true
+		q.requestProperties.setOption(DeferPartialQueryFailuresValue, true)
 		return nil
 	}
 }
 
 // MaxMemoryConsumptionPerQueryPerNode overrides the default maximum amount of memory a whole query
-// may allocate per node.
+// may allocate per node, in bytes. It must be more than 0.
 func MaxMemoryConsumptionPerQueryPerNode(i uint64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[MaxMemoryConsumptionPerQueryPerNodeValue] = i
+		if i == 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "MaxMemoryConsumptionPerQueryPerNode option was set to 0, but must be more than 0").SetNoRetry()
+		}
+		q.requestProperties.setOption(MaxMemoryConsumptionPerQueryPerNodeValue, i)
 		return nil
 	}
 }
 
-// MaxMemoryConsumptionPerIterator overrides the default maximum amount of memory a query operator may allocate.
+// MaxMemoryConsumptionPerIterator overrides the default maximum amount of memory a query operator may allocate, in
+// bytes. It must be more than 0.
 func MaxMemoryConsumptionPerIterator(i uint64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[MaxMemoryConsumptionPerIteratorValue] = i
+		if i == 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "MaxMemoryConsumptionPerIterator option was set to 0, but must be more than 0").SetNoRetry()
+		}
+		q.requestProperties.setOption(MaxMemoryConsumptionPerIteratorValue, i)
 		return nil
 	}
 }
 
-// MaxOutputColumns overrides the default maximum number of columns a query is allowed to produce.
+// MaxOutputColumns overrides the default maximum number of columns a query is allowed to produce. It must be more
+// than 0.
 func MaxOutputColumns(i int) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[MaxOutputColumnsValue] = i
+		if i <= 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "MaxOutputColumns option was set to %d, but must be more than 0", i).SetNoRetry()
+		}
+		q.requestProperties.setOption(MaxOutputColumnsValue, i)
 		return nil
 	}
 }
@@ -184,7 +323,7 @@ func MaxOutputColumns(i int) QueryOption {
 // PushSelectionThroughAggregation will push simple selection through aggregation .
 func PushSelectionThroughAggregation() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[PushSelectionThroughAggregationValue] = true
+		q.requestProperties.setOption(PushSelectionThroughAggregationValue, true)
 		return nil
 	}
 }
@@ -193,7 +332,7 @@ func PushSelectionThroughAggregation() QueryOption {
 // called without parameters.
 func QueryCursorAfterDefault(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryCursorAfterDefaultValue] = s
+		q.requestProperties.setOption(QueryCursorAfterDefaultValue, s)
 		return nil
 	}
 }
@@ -202,7 +341,7 @@ func QueryCursorAfterDefault(s string) QueryOption {
 // without parameters.
 func QueryCursorBeforeOrAtDefault(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryCursorBeforeOrAtDefaultValue] = s
+		q.requestProperties.setOption(QueryCursorBeforeOrAtDefaultValue, s)
 		return nil
 	}
 }
@@ -210,7 +349,7 @@ func QueryCursorBeforeOrAtDefault(s string) QueryOption {
 // QueryCursorCurrent overrides the cursor value returned by the cursor_current() or current_cursor() functions.
 func QueryCursorCurrent(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryCursorCurrentValue] = s
+		q.requestProperties.setOption(QueryCursorCurrentValue, s)
 		return nil
 	}
 }
@@ -218,7 +357,7 @@ func QueryCursorCurrent(s string) QueryOption {
 // QueryCursorDisabled overrides the cursor value returned by the cursor_current() or current_cursor() functions.
 func QueryCursorDisabled(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryCursorDisabledValue] = s
+		q.requestProperties.setOption(QueryCursorDisabledValue, s)
 		return nil
 	}
 }
@@ -227,7 +366,7 @@ func QueryCursorDisabled(s string) QueryOption {
 // cursor_before_or_at_default (upper bound is optional).
 func QueryCursorScopedTables(l []string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryCursorScopedTablesValue] = l
+		q.requestProperties.setOption(QueryCursorScopedTablesValue, l)
 		return nil
 	}
 }
@@ -259,7 +398,7 @@ func QueryDataScope(ds DataScope) QueryOption {
 		}
 	}
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryDatascopeValue] = string(ds.(dataScope))
+		q.requestProperties.setOption(QueryDatascopeValue, string(ds.(dataScope)))
 		return nil
 	}
 }
@@ -268,7 +407,7 @@ func QueryDataScope(ds DataScope) QueryOption {
 // (query_datetimescope_to / query_datetimescope_from)
 func QueryDateTimeScopeColumn(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryDateTimeScopeColumnValue] = s
+		q.requestProperties.setOption(QueryDateTimeScopeColumnValue, s)
 		return nil
 	}
 }
@@ -277,7 +416,7 @@ func QueryDateTimeScopeColumn(s string) QueryOption {
 // query_datetimescope_column only (if defined).
 func QueryDateTimeScopeFrom(t time.Time) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryDateTimeScopeFromValue] = t.Format(time.RFC3339Nano)
+		q.requestProperties.setOption(QueryDateTimeScopeFromValue, value.DateTime{Value: t, Valid: true}.Marshal())
 		return nil
 	}
 }
@@ -286,7 +425,7 @@ func QueryDateTimeScopeFrom(t time.Time) QueryOption {
 // query_datetimescope_column only (if defined).
 func QueryDateTimeScopeTo(t time.Time) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryDateTimeScopeToValue] = t.Format(time.RFC3339Nano)
+		q.requestProperties.setOption(QueryDateTimeScopeToValue, value.DateTime{Value: t, Valid: true}.Marshal())
 		return nil
 	}
 }
@@ -294,7 +433,7 @@ func QueryDateTimeScopeTo(t time.Time) QueryOption {
 // ClientMaxRedirectCount If set and positive, indicates the maximum number of HTTP redirects that the client will process.
 func ClientMaxRedirectCount(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[ClientMaxRedirectCountValue] = i
+		q.requestProperties.setOption(ClientMaxRedirectCountValue, i)
 		return nil
 	}
 }
@@ -304,7 +443,7 @@ func ClientMaxRedirectCount(i int64) QueryOption {
 // Examples: 'dynamic([ { "Name": "V1", "Keys" : [ "K1", "K2" ] } ])' (shuffle view V1 by K1, K2) or 'dynamic([ { "Name": "V1" } ])' (shuffle view V1 by all keys)
 func MaterializedViewShuffle(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[MaterializedViewShuffleValue] = s
+		q.requestProperties.setOption(MaterializedViewShuffleValue, s)
 		return nil
 	}
 }
@@ -312,7 +451,7 @@ func MaterializedViewShuffle(s string) QueryOption {
 // QueryBinAutoAt When evaluating the bin_auto() function, the start value to use.
 func QueryBinAutoAt(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryBinAutoAtValue] = s
+		q.requestProperties.setOption(QueryBinAutoAtValue, s)
 		return nil
 	}
 }
@@ -320,7 +459,7 @@ func QueryBinAutoAt(s string) QueryOption {
 // QueryBinAutoSize When evaluating the bin_auto() function, the bin size value to use.
 func QueryBinAutoSize(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryBinAutoSizeValue] = s
+		q.requestProperties.setOption(QueryBinAutoSizeValue, s)
 		return nil
 	}
 }
@@ -329,7 +468,7 @@ func QueryBinAutoSize(s string) QueryOption {
 // level in the query hierarchy for each subgroup of nodes; the size of the subgroup is set by this option.
 func QueryDistributionNodesSpan(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryDistributionNodesSpanValue] = i
+		q.requestProperties.setOption(QueryDistributionNodesSpanValue, i)
 		return nil
 	}
 }
@@ -337,7 +476,7 @@ func QueryDistributionNodesSpan(i int64) QueryOption {
 // QueryFanoutNodesPercent The percentage of nodes to fan out execution to.
 func QueryFanoutNodesPercent(i int) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryFanoutNodesPercentValue] = i
+		q.requestProperties.setOption(QueryFanoutNodesPercentValue, i)
 		return nil
 	}
 }
@@ -345,7 +484,7 @@ func QueryFanoutNodesPercent(i int) QueryOption {
 // QueryFanoutThreadsPercent The percentage of threads to fan out execution to.
 func QueryFanoutThreadsPercent(i int) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryFanoutThreadsPercentValue] = i
+		q.requestProperties.setOption(QueryFanoutThreadsPercentValue, i)
 		return nil
 	}
 }
@@ -353,7 +492,7 @@ func QueryFanoutThreadsPercent(i int) QueryOption {
 // QueryForceRowLevelSecurity If specified, forces Row Level Security rules, even if row_level_security policy is disabled
 func QueryForceRowLevelSecurity() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryForceRowLevelSecurityValue] = true
+		q.requestProperties.setOption(QueryForceRowLevelSecurityValue, true)
 		return nil
 	}
 }
@@ -361,7 +500,7 @@ func QueryForceRowLevelSecurity() QueryOption {
 // QueryLanguage Controls how the query text is to be interpreted (Kql or Sql).
 func QueryLanguage(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryLanguageValue] = s
+		q.requestProperties.setOption(QueryLanguageValue, s)
 		return nil
 	}
 }
@@ -369,7 +508,7 @@ func QueryLanguage(s string) QueryOption {
 // QueryLogQueryParameters Enables logging of the query parameters, so that they can be viewed later in the .show queries journal.
 func QueryLogQueryParameters() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryLogQueryParametersValue] = true
+		q.requestProperties.setOption(QueryLogQueryParametersValue, true)
 		return nil
 	}
 }
@@ -377,7 +516,7 @@ func QueryLogQueryParameters() QueryOption {
 // QueryMaxEntitiesInUnion Overrides the default maximum number of entities in a union.
 func QueryMaxEntitiesInUnion(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryMaxEntitiesInUnionValue] = i
+		q.requestProperties.setOption(QueryMaxEntitiesInUnionValue, i)
 		return nil
 	}
 }
@@ -385,7 +524,7 @@ func QueryMaxEntitiesInUnion(i int64) QueryOption {
 // QueryNow Overrides the datetime value returned by the now(0s) function.
 func QueryNow(t time.Time) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryNowValue] = t.Format(time.RFC3339Nano)
+		q.requestProperties.setOption(QueryNowValue, value.DateTime{Value: t, Valid: true}.Marshal())
 		return nil
 	}
 }
@@ -393,7 +532,7 @@ func QueryNow(t time.Time) QueryOption {
 // QueryPythonDebug If set, generate python debug query for the enumerated python node (default first).
 func QueryPythonDebug(i int) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryPythonDebugValue] = i
+		q.requestProperties.setOption(QueryPythonDebugValue, i)
 		return nil
 	}
 }
@@ -401,15 +540,16 @@ func QueryPythonDebug(i int) QueryOption {
 // QueryResultsApplyGetschema If set, retrieves the schema of each tabular data in the results of the query instead of the data itself.
 func QueryResultsApplyGetschema() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryResultsApplyGetschemaValue] = true
+		q.requestProperties.setOption(QueryResultsApplyGetschemaValue, true)
 		return nil
 	}
 }
 
-// QueryResultsCacheMaxAge If positive, controls the maximum age of the cached query results the service is allowed to return
+// QueryResultsCacheMaxAge If positive, controls the maximum age of the cached query results the service is allowed to return.
+// This is the results cache of the service, which is separate from the one of WithQueryCache().
 func QueryResultsCacheMaxAge(d time.Duration) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryResultsCacheMaxAgeValue] = value.Timespan{Value: d, Valid: true}.Marshal()
+		q.requestProperties.setOption(QueryResultsCacheMaxAgeValue, value.Timespan{Value: d, Valid: true}.Marshal())
 		return nil
 	}
 }
@@ -417,7 +557,7 @@ func QueryResultsCacheMaxAge(d time.Duration) QueryOption {
 // QueryResultsCachePerShard If set, enables per-shard query cache.
 func QueryResultsCachePerShard() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryResultsCachePerShardValue] = true
+		q.requestProperties.setOption(QueryResultsCachePerShardValue, true)
 		return nil
 	}
 }
@@ -425,7 +565,7 @@ func QueryResultsCachePerShard() QueryOption {
 // QueryResultsProgressiveRowCount Hint for Kusto as to how many records to send in each update (takes effect only if OptionResultsProgressiveEnabled is set)
 func QueryResultsProgressiveRowCount(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryResultsProgressiveRowCountValue] = i
+		q.requestProperties.setOption(QueryResultsProgressiveRowCountValue, i)
 		return nil
 	}
 }
@@ -433,7 +573,7 @@ func QueryResultsProgressiveRowCount(i int64) QueryOption {
 // QueryResultsProgressiveUpdatePeriod Hint for Kusto as to how often to send progress frames (takes effect only if OptionResultsProgressiveEnabled is set)
 func QueryResultsProgressiveUpdatePeriod(i int32) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryResultsProgressiveUpdatePeriodValue] = i
+		q.requestProperties.setOption(QueryResultsProgressiveUpdatePeriodValue, i)
 		return nil
 	}
 }
@@ -441,7 +581,7 @@ func QueryResultsProgressiveUpdatePeriod(i int32) QueryOption {
 // QueryTakeMaxRecords Enables limiting query results to this number of records.
 func QueryTakeMaxRecords(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryTakeMaxRecordsValue] = i
+		q.requestProperties.setOption(QueryTakeMaxRecordsValue, i)
 		return nil
 	}
 }
@@ -449,7 +589,7 @@ func QueryTakeMaxRecords(i int64) QueryOption {
 // QueryConsistency Controls query consistency
 func QueryConsistency(c string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[QueryConsistencyValue] = c
+		q.requestProperties.setOption(QueryConsistencyValue, c)
 		return nil
 	}
 }
@@ -458,7 +598,7 @@ func QueryConsistency(c string) QueryOption {
 // Does not set the `Application` property in `.show queries`, see `Application` for that.
 func RequestAppName(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestAppNameValue] = s
+		q.requestProperties.setOption(RequestAppNameValue, s)
 		return nil
 	}
 }
@@ -466,7 +606,7 @@ func RequestAppName(s string) QueryOption {
 // RequestBlockRowLevelSecurity If specified, blocks access to tables for which row_level_security policy is enabled.
 func RequestBlockRowLevelSecurity() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestBlockRowLevelSecurityValue] = true
+		q.requestProperties.setOption(RequestBlockRowLevelSecurityValue, true)
 		return nil
 	}
 }
@@ -474,7 +614,7 @@ func RequestBlockRowLevelSecurity() QueryOption {
 // RequestCalloutDisabled If specified, indicates that the request can't call-out to a user-provided service.
 func RequestCalloutDisabled() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestCalloutDisabledValue] = true
+		q.requestProperties.setOption(RequestCalloutDisabledValue, true)
 		return nil
 	}
 }
@@ -482,7 +622,7 @@ func RequestCalloutDisabled() QueryOption {
 // RequestDescription Arbitrary text that the author of the request wants to include as the request description.
 func RequestDescription(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestDescriptionValue] = s
+		q.requestProperties.setOption(RequestDescriptionValue, s)
 		return nil
 	}
 }
@@ -490,7 +630,7 @@ func RequestDescription(s string) QueryOption {
 // RequestExternalTableDisabled If specified, indicates that the request can't invoke code in the ExternalTable.
 func RequestExternalTableDisabled() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestExternalTableDisabledValue] = true
+		q.requestProperties.setOption(RequestExternalTableDisabledValue, true)
 		return nil
 	}
 }
@@ -498,15 +638,24 @@ func RequestExternalTableDisabled() QueryOption {
 // RequestImpersonationDisabled If specified, indicates that the service should not impersonate the caller's identity.
 func RequestImpersonationDisabled() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestImpersonationDisabledValue] = true
+		q.requestProperties.setOption(RequestImpersonationDisabledValue, true)
 		return nil
 	}
 }
 
-// RequestReadonly If specified, indicates that the request can't write anything.
+// RequestReadonly If specified, indicates that the request can't write anything. See also RequestReadonlyLocked().
 func RequestReadonly() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestReadonlyValue] = true
+		q.requestProperties.setOption(RequestReadonlyValue, true)
+		return nil
+	}
+}
+
+// RequestReadonlyHardline If specified, indicates that the request operates in a strict read-only mode: it can't write
+// anything and functionality that is not strictly read-only, such as plugins, is disabled.
+func RequestReadonlyHardline() QueryOption {
+	return func(q *queryOptions) error {
+		q.requestProperties.setOption(RequestReadonlyHardlineValue, true)
 		return nil
 	}
 }
@@ -514,7 +663,7 @@ func RequestReadonly() QueryOption {
 // RequestRemoteEntitiesDisabled If specified, indicates that the request can't access remote databases and clusters.
 func RequestRemoteEntitiesDisabled() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestRemoteEntitiesDisabledValue] = true
+		q.requestProperties.setOption(RequestRemoteEntitiesDisabledValue, true)
 		return nil
 	}
 }
@@ -522,7 +671,7 @@ func RequestRemoteEntitiesDisabled() QueryOption {
 // RequestSandboxedExecutionDisabled If specified, indicates that the request can't invoke code in the sandbox.
 func RequestSandboxedExecutionDisabled() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestSandboxedExecutionDisabledValue] = true
+		q.requestProperties.setOption(RequestSandboxedExecutionDisabledValue, true)
 		return nil
 	}
 }
@@ -531,23 +680,31 @@ func RequestSandboxedExecutionDisabled() QueryOption {
 // Does not set the `User` property in `.show queries`, see `User` for that.
 func RequestUser(s string) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[RequestUserValue] = s
+		q.requestProperties.setOption(RequestUserValue, s)
 		return nil
 	}
 }
 
 // TruncationMaxRecords Overrides the default maximum number of records a query is allowed to return to the caller (truncation).
+// It must be more than 0, see NoTruncation() to not truncate the results.
 func TruncationMaxRecords(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[TruncationMaxRecordsValue] = i
+		if i <= 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "TruncationMaxRecords option was set to %d, but must be more than 0", i).SetNoRetry()
+		}
+		q.requestProperties.setOption(TruncationMaxRecordsValue, i)
 		return nil
 	}
 }
 
-// TruncationMaxSize Overrides the default maximum data size a query is allowed to return to the caller (truncation).
+// TruncationMaxSize Overrides the default maximum data size a query is allowed to return to the caller (truncation),
+// in bytes. It must be more than 0, see NoTruncation() to not truncate the results.
 func TruncationMaxSize(i int64) QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[TruncationMaxSizeValue] = i
+		if i <= 0 {
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "TruncationMaxSize option was set to %d, but must be more than 0", i).SetNoRetry()
+		}
+		q.requestProperties.setOption(TruncationMaxSizeValue, i)
 		return nil
 	}
 }
@@ -555,7 +712,7 @@ func TruncationMaxSize(i int64) QueryOption {
 // ValidatePermissions Validates user's permissions to perform the query and doesn't run the query itself.
 func ValidatePermissions() QueryOption {
 	return func(q *queryOptions) error {
-		q.requestProperties.Options[ValidatePermissionsValue] = true
+		q.requestProperties.setOption(ValidatePermissionsValue, true)
 		return nil
 	}
 }
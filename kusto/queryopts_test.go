@@ -0,0 +1,277 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	_ "time/tzdata" // For the DST tests.
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextWithQueryOptions(t *testing.T) {
+	t.Parallel()
+
+	base := context.Background()
+	outer := ContextWithQueryOptions(base, Application("outer"), User("outer"), ClientRequestID("outer"))
+	inner := ContextWithQueryOptions(outer, Application("inner"))
+	sibling := ContextWithQueryOptions(outer, User("sibling"))
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		options []QueryOption
+		want    requestProperties
+	}{
+		{
+			desc: "No context options",
+			ctx:  base,
+			want: requestProperties{},
+		},
+		{
+			desc: "Context options",
+			ctx:  outer,
+			want: requestProperties{Application: "outer", User: "outer", ClientRequestID: "outer"},
+		},
+		{
+			desc: "Derived context wins over its parent",
+			ctx:  inner,
+			want: requestProperties{Application: "inner", User: "outer", ClientRequestID: "outer"},
+		},
+		{
+			desc: "Sibling contexts don't leak into each other",
+			ctx:  sibling,
+			want: requestProperties{Application: "outer", User: "sibling", ClientRequestID: "outer"},
+		},
+		{
+			desc:    "Explicit options win",
+			ctx:     inner,
+			options: []QueryOption{ClientRequestID("explicit")},
+			want:    requestProperties{Application: "inner", User: "outer", ClientRequestID: "explicit"},
+		},
+		{
+			desc: "Unrelated context",
+			ctx:  context.WithValue(base, queryOptionsKey{}, nil),
+			want: requestProperties{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := setQueryOptions(test.ctx, errors.OpQuery, NewStmt("T"), nil, test.options...)
+			require.NoError(t, err)
+			got := opts.requestProperties
+			assert.Equal(t, test.want.Application, got.Application)
+			assert.Equal(t, test.want.User, got.User)
+			assert.Equal(t, test.want.ClientRequestID, got.ClientRequestID)
+		})
+	}
+
+	assert.Equal(t, base, ContextWithQueryOptions(base))
+}
+
+func TestPreserveLocation(t *testing.T) {
+	t.Parallel()
+
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	body, err := os.ReadFile(filepath.Join("testdata", "datetime.json"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc    string
+		options []QueryOption
+		want    *time.Location
+		err     bool
+	}{
+		{desc: "Default is UTC", want: time.UTC},
+		{desc: "New York", options: []QueryOption{PreserveLocation(ny)}, want: ny},
+		{desc: "Nil location", options: []QueryOption{PreserveLocation(nil)}, err: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newTestClient(t, "https://datetime.kusto.windows.net", fixtureTransport{body: body})
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"), test.options...)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			type when struct {
+				When *time.Time
+				Name string
+			}
+			var got []when
+			require.NoError(t, iter.Do(func(r *table.Row) error {
+				w := when{}
+				if err := r.ToStruct(&w); err != nil {
+					return err
+				}
+				got = append(got, w)
+				return nil
+			}))
+			require.Len(t, got, 3)
+
+			// The instants are the same whatever the location, crossing the DST change in New York.
+			assert.True(t, got[0].When.Equal(time.Date(2022, 3, 13, 1, 59, 59, 999999900, ny)))
+			assert.True(t, got[1].When.Equal(time.Date(2022, 3, 13, 3, 0, 0, 0, ny)))
+			assert.Equal(t, test.want, got[0].When.Location())
+			assert.Equal(t, test.want, got[1].When.Location())
+			assert.Nil(t, got[2].When)
+
+			// A decoded value sent back as a parameter is the instant that was received.
+			assert.Equal(t, "2022-03-13T07:00:00Z", value.NewDateTime(*got[1].When).Marshal())
+		})
+	}
+}
+
+// pipeTransport is a fake http.RoundTripper that answers with the body written to w, as the test writes it.
+type pipeTransport struct {
+	r *io.PipeReader
+
+	mu  sync.Mutex
+	msg queryMsg
+}
+
+func (p *pipeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := json.NewDecoder(req.Body).Decode(&p.msg); err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: p.r}, nil
+}
+
+func TestResultsV2FragmentedStreaming(t *testing.T) {
+	t.Parallel()
+
+	r, w := io.Pipe()
+	transport := &pipeTransport{r: r}
+	client := newTestClient(t, "https://fragmented.kusto.windows.net", transport)
+
+	write := func(s string) {
+		go func() {
+			_, err := io.WriteString(w, s)
+			assert.NoError(t, err)
+		}()
+	}
+	fragment := func(first, last int) string {
+		var rows []string
+		for i := first; i <= last; i++ {
+			rows = append(rows, "["+strconv.Itoa(i)+"]")
+		}
+		return `{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[` + strings.Join(rows, ",") + "]},\n"
+	}
+
+	write("[\n" + `{"FrameType":"DataSetHeader","IsProgressive":false,"IsFragmented":true,"Version":"v2.0"},` + "\n" +
+		`{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult","Columns":[{"ColumnName":"x","ColumnType":"long"}]},` + "\n" +
+		fragment(1, 2))
+
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"), ResultsV2FragmentedStreaming())
+	require.NoError(t, err)
+	defer iter.Stop()
+
+	transport.mu.Lock()
+	options := transport.msg.Properties.Options
+	transport.mu.Unlock()
+	assert.Equal(t, true, options[ResultsV2FragmentPrimaryTablesValue])
+	assert.Equal(t, true, options[ResultsV2NewlinesBetweenFramesValue])
+	_, ok := options[resultsProgressiveEnabledValue]
+	assert.False(t, ok, "the progressive framing must not be asked for")
+
+	// The rows of the first fragment are yielded before the rest of the response was sent.
+	var got []int64
+	next := func() {
+		row, inlineErr, err := iter.NextRowOrError()
+		require.NoError(t, err)
+		require.Nil(t, inlineErr)
+		got = append(got, row.Values[0].(value.Long).Value)
+	}
+	next()
+	next()
+	assert.Equal(t, []int64{1, 2}, got)
+
+	write(fragment(3, 5) +
+		`{"FrameType":"TableCompletion","TableId":1,"RowCount":5},` + "\n" +
+		`{"FrameType":"DataTable","TableId":2,"TableKind":"QueryCompletionInformation","TableName":"QueryCompletionInformation","Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` + "\n" +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}` + "\n]")
+
+	require.NoError(t, iter.DoOnRowOrError(func(row *table.Row, inlineErr *errors.Error) error {
+		require.Nil(t, inlineErr)
+		got = append(got, row.Values[0].(value.Long).Value)
+		return nil
+	}))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, got)
+	require.NoError(t, w.Close())
+}
+
+func TestQueryOptionLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		option  QueryOption
+		key     string
+		want    interface{}
+		wantErr bool
+	}{
+		{desc: "NoTruncation", option: NoTruncation(), key: NoTruncationValue, want: true},
+		{desc: "TruncationMaxRecords", option: TruncationMaxRecords(1000), key: TruncationMaxRecordsValue, want: int64(1000)},
+		{desc: "TruncationMaxRecords zero", option: TruncationMaxRecords(0), wantErr: true},
+		{desc: "TruncationMaxSize", option: TruncationMaxSize(1 << 20), key: TruncationMaxSizeValue, want: int64(1 << 20)},
+		{desc: "TruncationMaxSize negative", option: TruncationMaxSize(-1), wantErr: true},
+		{desc: "MaxMemoryConsumptionPerQueryPerNode", option: MaxMemoryConsumptionPerQueryPerNode(1 << 30), key: MaxMemoryConsumptionPerQueryPerNodeValue, want: uint64(1 << 30)},
+		{desc: "MaxMemoryConsumptionPerQueryPerNode zero", option: MaxMemoryConsumptionPerQueryPerNode(0), wantErr: true},
+		{desc: "MaxMemoryConsumptionPerIterator", option: MaxMemoryConsumptionPerIterator(1 << 30), key: MaxMemoryConsumptionPerIteratorValue, want: uint64(1 << 30)},
+		{desc: "MaxMemoryConsumptionPerIterator zero", option: MaxMemoryConsumptionPerIterator(0), wantErr: true},
+		{desc: "MaxOutputColumns", option: MaxOutputColumns(10), key: MaxOutputColumnsValue, want: 10},
+		{desc: "MaxOutputColumns zero", option: MaxOutputColumns(0), wantErr: true},
+		{desc: "ServerTimeout over the maximum", option: ServerTimeout(2 * time.Hour), wantErr: true},
+		{desc: "Custom servertimeout", option: CustomQueryOption(ServerTimeoutValue, 30*time.Minute), key: ServerTimeoutValue, want: "00:30:00"},
+		{desc: "Custom servertimeout over the maximum", option: CustomQueryOption(ServerTimeoutValue, "02:00:00"), wantErr: true},
+		{desc: "Custom servertimeout not a timespan", option: CustomQueryOption(ServerTimeoutValue, "ten minutes"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, test.option)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.False(t, errors.Retry(err))
+				return
+			}
+			require.NoError(t, err)
+			got, ok := opts.requestProperties.option(test.key)
+			require.True(t, ok)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+//go:build go1.23
+
+package kusto
+
+// queryseq.go implements QuerySeq(), which decodes the rows of a query into structs with a range-over-func loop.
+
+import (
+	"context"
+	"iter"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// QuerySeq runs query and returns its rows decoded into values of the struct type T, for use in a for range loop.
+// Columns are mapped to the fields as with QueryInto(). Errors inline within the rows, which the service sends when
+// part of a query failed, are yielded with a zero T and the iteration continues after them. Any other error, including
+// the failure of the query or of the decoding of a row, is yielded last. The query is stopped once the loop ends,
+// including with a break or a return.
+// Values that were yielded cannot be replaced, so unlike Query(), QuerySeq() does not ask for progressive results by
+// default. If they are enabled with an option and rows replace the ones already yielded, see table.Row.Replace, an
+// error is yielded last.
+// Example:
+//
+//	for node, err := range kusto.QuerySeq[Node](ctx, client, "database", kusto.NewStmt("Nodes")) {
+//		if err != nil {
+//			...
+//		}
+//		...
+//	}
+func QuerySeq[T any](ctx context.Context, client *Client, db string, query Stmt, options ...QueryOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if err := checkStructType[T](); err != nil {
+			yield(zero, err)
+			return
+		}
+
+		rows, err := client.query(ctx, db, query, []QueryOption{ResultsProgressiveDisable()}, options...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		yielded := false
+		// Rows() stops the RowIterator once the loop ends.
+		for row, err := range rows.Rows() {
+			if err != nil {
+				if !yield(zero, err) {
+					return
+				}
+				continue
+			}
+			if row.Replace && yielded {
+				yield(zero, errors.ES(errors.OpQuery, errors.KClientArgs, "the progressive results of the query replaced rows that QuerySeq() already yielded, "+
+					"use QueryInto() or Query() for progressive results").SetNoRetry())
+				return
+			}
+			v, err := decodeRow[T](row)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			yielded = true
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+//go:build go1.23
+
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuerySeq(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc        string
+		progressive bool
+		inlineErr   bool
+		breakAfter  int
+		want        []intoRecord
+		// wantErrs are the kinds of the errors yielded, in order.
+		wantErrs []errors.Kind
+	}{
+		{desc: "Non-progressive", want: intoWant},
+		{desc: "Progressive", progressive: true, want: intoWant},
+		{desc: "Partial error", progressive: true, inlineErr: true, want: intoWant, wantErrs: []errors.Kind{errors.KLimitsExceeded}},
+		{desc: "Break", breakAfter: 1, want: intoWant[:1]},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := intoClient(t, intoFixture(test.progressive, test.inlineErr))
+
+			var got []intoRecord
+			var gotErrs []errors.Kind
+			for v, err := range QuerySeq[intoRecord](context.Background(), client, "db", NewStmt("T")) {
+				if err != nil {
+					var kerr *errors.Error
+					require.True(t, goErrors.As(err, &kerr), "got %T: %v", err, err)
+					assert.Equal(t, intoRecord{}, v)
+					gotErrs = append(gotErrs, kerr.Kind)
+					continue
+				}
+				got = append(got, v)
+				if len(got) == test.breakAfter {
+					break
+				}
+			}
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.wantErrs, gotErrs)
+		})
+	}
+}
+
+func TestQuerySeqReplace(t *testing.T) {
+	t.Parallel()
+
+	// QuerySeq() does not ask for progressive results by default.
+	transport := &recordTransport{}
+	recorded := newTestClient(t, "https://into.kusto.windows.net", transport)
+	for range QuerySeq[intoRecord](context.Background(), recorded, "db", NewStmt("T")) {
+	}
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	_, progressive := sent[0].Properties.Options[resultsProgressiveEnabledValue]
+	assert.False(t, progressive)
+
+	// The rows already yielded cannot be replaced.
+	client := intoClient(t, intoReplaceFixture())
+	var got []intoRecord
+	var gotErr error
+	for v, err := range QuerySeq[intoRecord](context.Background(), client, "db", NewStmt("T"), CustomQueryOption(resultsProgressiveEnabledValue, true)) {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		got = append(got, v)
+	}
+	assert.Equal(t, intoWant[:1], got)
+	var kerr *errors.Error
+	require.True(t, goErrors.As(gotErr, &kerr), "got %T: %v", gotErr, gotErr)
+	assert.Equal(t, errors.KClientArgs, kerr.Kind)
+}
+
+func TestQuerySeqErrors(t *testing.T) {
+	t.Parallel()
+
+	client := intoClient(t, intoFixture(false, false))
+
+	collect := func(seq func(yield func(int, error) bool)) []error {
+		var errs []error
+		seq(func(_ int, err error) bool {
+			errs = append(errs, err)
+			return true
+		})
+		return errs
+	}
+
+	// T must be a struct.
+	errs := collect(QuerySeq[int](context.Background(), client, "db", NewStmt("T")))
+	require.Len(t, errs, 1)
+	var kerr *errors.Error
+	require.True(t, goErrors.As(errs[0], &kerr), "got %T: %v", errs[0], errs[0])
+	assert.Equal(t, errors.KClientArgs, kerr.Kind)
+
+	// A row that cannot be decoded ends the iteration.
+	type mismatch struct {
+		Bool string `kusto:"vb"`
+	}
+	count := 0
+	for _, err := range QuerySeq[mismatch](context.Background(), client, "db", NewStmt("T")) {
+		assert.Error(t, err)
+		count++
+	}
+	assert.Equal(t, 1, count)
+
+	// Breaking out of the loop stops the query, whose response is never completed.
+	r, w := io.Pipe()
+	defer w.Close()
+	client = newTestClient(t, "https://into.kusto.windows.net", &pipeTransport{r: r})
+	go func() {
+		_, _ = io.WriteString(w, "[\n"+`{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},`+"\n"+
+			`{"FrameType":"TableHeader","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",`+intoColumns+"},\n"+
+			`{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":1,"Rows":[`+intoRow1+"]},\n")
+	}()
+	for v, err := range QuerySeq[intoRecord](context.Background(), client, "db", NewStmt("T")) {
+		require.NoError(t, err)
+		assert.Equal(t, intoWant[0], v)
+		break
+	}
+}
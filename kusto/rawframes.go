@@ -0,0 +1,50 @@
+package kusto
+
+// rawframes.go gives access to the frames of a query response, see the kusto/frames package.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+)
+
+// DecodeFrames decodes r, a response body of the v2 query API such as one that was saved to a file, into frames
+// with the decoder used by Query(). See frames.Decoder for the contract of the returned channel.
+func DecodeFrames(ctx context.Context, r io.Reader) <-chan frames.Frame {
+	dec := &v2.Decoder{}
+	return dec.Decode(ctx, r, errors.OpQuery)
+}
+
+// NewRowIteratorFromFrames returns a RowIterator over the frames received on ch, which must follow the v2 protocol
+// and start with a frames.DataSetHeader. The frames can come from DecodeFrames() or from any frames.Decoder, which
+// allows reading results from another source than the service, such as an archived response.
+// If the first frame is a frames.Error, it is returned. Stop() must be called on the RowIterator. It does not stop the
+// source of the frames, so cancel the context passed to the frames.Decoder when the rows are not all read.
+func NewRowIteratorFromFrames(ctx context.Context, ch <-chan frames.Frame) (*RowIterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	first, ok := <-ch
+	if !ok {
+		cancel()
+		return nil, errors.ES(errors.OpQuery, errors.KInternal, "the frames ended before the DataSetHeader").SetNoRetry()
+	}
+	switch first.(type) {
+	case frames.DataSetHeader, frames.Error:
+	default:
+		cancel()
+		return nil, errors.ES(errors.OpQuery, errors.KInternal, "the first frame must be a DataSetHeader, was %s", frameName(first)).SetNoRetry()
+	}
+
+	return startRowIterator(ctx, cancel, execResp{frameCh: ch}, first, errors.OpQuery, nil)
+}
+
+// frameName returns the type of f without its package, for error messages.
+func frameName(f frames.Frame) string {
+	s := fmt.Sprintf("%T", f)
+	return s[strings.LastIndex(s, ".")+1:]
+}
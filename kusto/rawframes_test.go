@@ -0,0 +1,151 @@
+package kusto
+
+import (
+	"bytes"
+	"context"
+	goErrors "errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceDecoder is a frames.Decoder that ignores its input and sends fixed frames, as a third party source would.
+type sliceDecoder []frames.Frame
+
+func (s sliceDecoder) Decode(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame {
+	ch := make(chan frames.Frame)
+	go func() {
+		defer close(ch)
+		for _, f := range s {
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- f:
+			}
+		}
+	}()
+	return ch
+}
+
+func TestDecodeFrames(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "compressed.json"))
+	require.NoError(t, err)
+
+	var got []string
+	for f := range DecodeFrames(context.Background(), bytes.NewReader(body)) {
+		got = append(got, frameName(f))
+		if dt, ok := f.(frames.DataTable); ok {
+			assert.Equal(t, frames.PrimaryResult, dt.TableKind)
+			assert.Len(t, dt.KustoRows, 3)
+			assert.Equal(t, errors.OpQuery, dt.Op)
+			rows := dt.Rows()
+			require.Len(t, rows, 3)
+			assert.Equal(t, dt.Columns, rows[0].ColumnTypes)
+			assert.Equal(t, dt.KustoRows[0], rows[0].Values)
+		}
+	}
+	assert.Equal(t, []string{"DataSetHeader", "DataTable", "DataSetCompletion"}, got)
+
+	var last frames.Frame
+	for f := range DecodeFrames(context.Background(), bytes.NewReader(body[:len(body)/2])) {
+		last = f
+	}
+	assert.IsType(t, frames.Error{}, last)
+}
+
+func TestNewRowIteratorFromFrames(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "compressed.json"))
+	require.NoError(t, err)
+	columns := table.Columns{{Name: "Region", Type: "string"}}
+	progressive := sliceDecoder{
+		frames.DataSetHeader{Base: frames.Base{FrameType: frames.TypeDataSetHeader}, Version: "v2.0", IsProgressive: true},
+		frames.TableHeader{TableKind: frames.PrimaryResult, TableName: frames.PrimaryResult, Columns: columns},
+		frames.TableFragment{KustoRows: []value.Values{{value.NewString("west")}}},
+		frames.TableProgress{TableProgress: 50},
+		frames.TableFragment{KustoRows: []value.Values{{value.NewString("east")}}},
+		frames.TableCompletion{RowCount: 2},
+		frames.DataSetCompletion{},
+	}
+
+	tests := []struct {
+		desc    string
+		dec     frames.Decoder
+		want    []string
+		wantErr string
+	}{
+		{
+			desc: "SDK decoder",
+			dec:  decoderFunc(func(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame { return DecodeFrames(ctx, r) }),
+			want: []string{"west", "east", "north"},
+		},
+		{
+			desc: "Progressive custom decoder",
+			dec:  progressive,
+			want: []string{"west", "east"},
+		},
+		{
+			desc:    "Error frame",
+			dec:     sliceDecoder{frames.Error{Msg: "archive is corrupted"}},
+			wantErr: "archive is corrupted",
+		},
+		{
+			desc:    "First frame is not a DataSetHeader",
+			dec:     progressive[1:],
+			wantErr: "the first frame must be a DataSetHeader, was TableHeader",
+		},
+		{
+			desc:    "No frames",
+			dec:     sliceDecoder{},
+			wantErr: "the frames ended before the DataSetHeader",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			iter, err := NewRowIteratorFromFrames(ctx, test.dec.Decode(ctx, bytes.NewReader(body), errors.OpQuery))
+			if test.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+				var fErr frames.Error
+				if goErrors.As(err, &fErr) {
+					assert.Equal(t, test.wantErr, fErr.Msg)
+				}
+				return
+			}
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			var got []string
+			require.NoError(t, iter.Do(func(r *table.Row) error {
+				got = append(got, r.Values[0].String())
+				return nil
+			}))
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+// decoderFunc adapts a function to frames.Decoder.
+type decoderFunc func(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame
+
+func (d decoderFunc) Decode(ctx context.Context, r io.Reader, op errors.Op) <-chan frames.Frame {
+	return d(ctx, r, op)
+}
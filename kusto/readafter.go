@@ -0,0 +1,177 @@
+package kusto
+
+// readafter.go implements Client.QueryAfter(), which waits for ingested data to be visible before querying it.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/cenkalti/backoff/v4"
+)
+
+const (
+	// markerPollInitial is the first interval between two checks of QueryAfter().
+	markerPollInitial = 200 * time.Millisecond
+	// markerPollMax is the longest interval between two checks of QueryAfter().
+	markerPollMax = 5 * time.Second
+)
+
+// IngestMarker identifies data that was ingested, so that Client.QueryAfter() can wait for it to be visible.
+// It is returned by the Result of an ingestion, see ingest.Result.Marker().
+type IngestMarker struct {
+	// Database is the database the data was ingested into.
+	Database string
+	// Table is the table the data was ingested into.
+	Table string
+	// Tag is an ingest-by: extent tag of the data, such as "ingest-by:batch-42", or empty. When set, the data is
+	// visible once an extent with the tag can be queried.
+	Tag string
+	// IngestedAt is when the ingestion was started. When Tag is empty, the data is visible once the table has records
+	// whose ingestion_time() is not before IngestedAt. It relies on the clocks of the client and of the service
+	// being in sync.
+	IngestedAt time.Time
+}
+
+// validate returns an error if the marker can't be waited for.
+func (m IngestMarker) validate() error {
+	if m.Database == "" || m.Table == "" {
+		return errors.ES(errors.OpQuery, errors.KClientArgs, "an IngestMarker must have a Database and a Table").SetNoRetry()
+	}
+	if m.Tag == "" && m.IngestedAt.IsZero() {
+		return errors.ES(errors.OpQuery, errors.KClientArgs, "an IngestMarker must have a Tag or an IngestedAt time").SetNoRetry()
+	}
+	return nil
+}
+
+// MarkerTimeoutError is returned by Client.QueryAfter() when the data of the IngestMarker was not visible in time.
+type MarkerTimeoutError struct {
+	// Marker is the marker that was waited for.
+	Marker IngestMarker
+	// Waited is how long QueryAfter() waited.
+	Waited time.Duration
+	// Checks is the number of times the visibility of the data was checked.
+	Checks int
+}
+
+// Error implements error.
+func (m *MarkerTimeoutError) Error() string {
+	return fmt.Sprintf("Op(%s): the data ingested into %s.%s was not visible after %s and %d checks", errors.OpQuery, m.Marker.Database, m.Marker.Table, m.Waited, m.Checks)
+}
+
+var (
+	markerTagQuery = NewStmt("table(markerTable) | where extent_tags() has markerTag | take 1 | count").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{
+			"markerTable": ParamType{Type: types.String},
+			"markerTag":   ParamType{Type: types.String},
+		}),
+	)
+	markerTimeQuery = NewStmt("table(markerTable) | where ingestion_time() >= markerTime | take 1 | count").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{
+			"markerTable": ParamType{Type: types.String},
+			"markerTime":  ParamType{Type: types.DateTime},
+		}),
+	)
+)
+
+// QueryAfter waits for the data of marker to be visible, and then runs query on database db like Query() does.
+// This gives read-your-writes semantics to a query following an ingestion, such as a streaming ingestion, whose data
+// may otherwise not be seen yet. The visibility is checked with queries of strong consistency, at growing
+// intervals. If the data is not visible after maxWait, a *MarkerTimeoutError is returned and query is not run.
+// query is sent with the QueryConsistency() of the checks, unless options set another one.
+func (c *Client) QueryAfter(ctx context.Context, db string, query Stmt, marker IngestMarker, maxWait time.Duration, options ...QueryOption) (*RowIterator, error) {
+	if err := marker.validate(); err != nil {
+		return nil, err
+	}
+	if maxWait <= 0 {
+		return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "QueryAfter() maxWait must be more than 0, was %s", maxWait).SetNoRetry()
+	}
+
+	if err := c.waitForMarker(ctx, marker, maxWait); err != nil {
+		return nil, err
+	}
+
+	options = append([]QueryOption{QueryConsistency(strongConsistency)}, options...)
+	return c.Query(ctx, db, query, options...)
+}
+
+// strongConsistency is the QueryConsistency() value of queries that see all the data ingested so far.
+const strongConsistency = "strongconsistency"
+
+// waitForMarker returns once the data of marker is visible.
+func (c *Client) waitForMarker(ctx context.Context, marker IngestMarker, maxWait time.Duration) error {
+	probe := markerTimeQuery.MustParameters(NewParameters().Must(QueryValues{"markerTable": marker.Table, "markerTime": marker.IngestedAt}))
+	if marker.Tag != "" {
+		probe = markerTagQuery.MustParameters(NewParameters().Must(QueryValues{"markerTable": marker.Table, "markerTag": marker.Tag}))
+	}
+
+	start := nower()
+	waitCtx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	b := c.markerBackoff()
+	checks := 0
+	for {
+		checks++
+		visible, err := c.markerVisible(waitCtx, marker.Database, probe)
+		if err != nil && waitCtx.Err() == nil {
+			return err
+		}
+		if visible {
+			return nil
+		}
+
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			wait = markerPollMax
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-waitCtx.Done():
+			timer.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &MarkerTimeoutError{Marker: marker, Waited: nower().Sub(start), Checks: checks}
+		case <-timer.C:
+		}
+	}
+}
+
+// markerVisible runs the probe, which returns a count of 1 once the data is visible.
+func (c *Client) markerVisible(ctx context.Context, db string, probe Stmt) (bool, error) {
+	iter, err := c.Query(ctx, db, probe, QueryConsistency(strongConsistency))
+	if err != nil {
+		return false, err
+	}
+	defer iter.Stop()
+
+	visible := false
+	err = iter.Do(func(row *table.Row) error {
+		if len(row.Values) > 0 {
+			if count, ok := row.Values[0].(value.Long); ok && count.Valid && count.Value > 0 {
+				visible = true
+			}
+		}
+		return nil
+	})
+	return visible, err
+}
+
+// markerBackoff returns the intervals between two checks of QueryAfter().
+func (c *Client) markerBackoff() backoff.BackOff {
+	if c.newMarkerBackoff != nil {
+		return c.newMarkerBackoff()
+	}
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = markerPollInitial
+	b.MaxInterval = markerPollMax
+	// The wait is bounded by maxWait instead.
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return b
+}
@@ -0,0 +1,246 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// markerTransport is a fake http.RoundTripper for a table whose ingested data becomes visible on the visibleAt
+// check of QueryAfter(), or never if visibleAt is 0. If failChecks is set, the checks fail.
+type markerTransport struct {
+	visibleAt  int
+	failChecks bool
+
+	mu     sync.Mutex
+	checks []queryMsg
+	msgs   []queryMsg
+}
+
+func (m *markerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	columns := `[{"ColumnName":"x","ColumnType":"long"}]`
+	rows := `[1],[2]`
+	if strings.Contains(msg.CSL, "table(markerTable)") {
+		m.checks = append(m.checks, msg)
+		if m.failChecks {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Status:     "400 Bad Request",
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"Failed to resolve table"}}`)),
+			}, nil
+		}
+		count := 0
+		if m.visibleAt > 0 && len(m.checks) >= m.visibleAt {
+			count = 1
+		}
+		columns = `[{"ColumnName":"Count","ColumnType":"long"}]`
+		rows = `[` + strconv.Itoa(count) + `]`
+	} else {
+		m.msgs = append(m.msgs, msg)
+	}
+
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":` + columns + `,"Rows":[` + rows + `]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (m *markerTransport) sent() (checks []queryMsg, msgs []queryMsg) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]queryMsg(nil), m.checks...), append([]queryMsg(nil), m.msgs...)
+}
+
+func markerClient(t *testing.T, transport *markerTransport) *Client {
+	client := newTestClient(t, "https://marker.kusto.windows.net", transport)
+	client.newMarkerBackoff = func() backoff.BackOff { return backoff.NewConstantBackOff(time.Millisecond) }
+	return client
+}
+
+func TestQueryAfter(t *testing.T) {
+	t.Parallel()
+
+	ingestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		desc        string
+		marker      IngestMarker
+		visibleAt   int
+		options     []QueryOption
+		wantCheck   string
+		wantParams  map[string]string
+		consistency string
+	}{
+		{
+			desc:        "Tag visible on the third check",
+			marker:      IngestMarker{Database: "ingestdb", Table: "Events", Tag: "ingest-by:batch-1", IngestedAt: ingestedAt},
+			visibleAt:   3,
+			wantCheck:   markerTagQuery.String(),
+			wantParams:  map[string]string{"markerTable": "Events", "markerTag": "ingest-by:batch-1"},
+			consistency: strongConsistency,
+		},
+		{
+			desc:        "Time visible on the first check",
+			marker:      IngestMarker{Database: "ingestdb", Table: "Events", IngestedAt: ingestedAt},
+			visibleAt:   1,
+			wantCheck:   markerTimeQuery.String(),
+			wantParams:  map[string]string{"markerTable": "Events", "markerTime": "datetime(2024-01-02T03:04:05Z)"},
+			consistency: strongConsistency,
+		},
+		{
+			desc:        "The consistency of the options wins",
+			marker:      IngestMarker{Database: "ingestdb", Table: "Events", Tag: "ingest-by:batch-1"},
+			visibleAt:   2,
+			options:     []QueryOption{QueryConsistency("weakconsistency")},
+			wantCheck:   markerTagQuery.String(),
+			wantParams:  map[string]string{"markerTable": "Events", "markerTag": "ingest-by:batch-1"},
+			consistency: "weakconsistency",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &markerTransport{visibleAt: test.visibleAt}
+			client := markerClient(t, transport)
+
+			iter, err := client.QueryAfter(context.Background(), "db", NewStmt("Events | count"), test.marker, time.Minute, test.options...)
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			checks, msgs := transport.sent()
+			require.Len(t, checks, test.visibleAt)
+			for _, check := range checks {
+				assert.Equal(t, test.marker.Database, check.DB)
+				assert.Contains(t, check.CSL, test.wantCheck)
+				assert.Equal(t, test.wantParams, check.Properties.Parameters)
+				assert.Equal(t, strongConsistency, check.Properties.Options[QueryConsistencyValue])
+			}
+
+			require.Len(t, msgs, 1)
+			assert.Equal(t, "db", msgs[0].DB)
+			assert.Equal(t, "Events | count", msgs[0].CSL)
+			assert.Equal(t, test.consistency, msgs[0].Properties.Options[QueryConsistencyValue])
+		})
+	}
+}
+
+func TestQueryAfterErrors(t *testing.T) {
+	t.Parallel()
+
+	marker := IngestMarker{Database: "ingestdb", Table: "Events", Tag: "ingest-by:batch-1"}
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &markerTransport{visibleAt: 1}
+		client := markerClient(t, transport)
+
+		for _, m := range []IngestMarker{
+			{Table: "Events", Tag: "ingest-by:batch-1"},
+			{Database: "ingestdb", Tag: "ingest-by:batch-1"},
+			{Database: "ingestdb", Table: "Events"},
+		} {
+			_, err := client.QueryAfter(context.Background(), "db", NewStmt("Events"), m, time.Minute)
+			assert.Error(t, err, "marker %+v", m)
+		}
+		_, err := client.QueryAfter(context.Background(), "db", NewStmt("Events"), marker, 0)
+		assert.Error(t, err)
+
+		checks, msgs := transport.sent()
+		assert.Empty(t, checks)
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("Not visible in time", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &markerTransport{}
+		client := markerClient(t, transport)
+
+		_, err := client.QueryAfter(context.Background(), "db", NewStmt("Events"), marker, 50*time.Millisecond)
+		var timeoutErr *MarkerTimeoutError
+		require.True(t, goErrors.As(err, &timeoutErr), "got %T: %v", err, err)
+		assert.Equal(t, marker, timeoutErr.Marker)
+		assert.GreaterOrEqual(t, timeoutErr.Waited, 50*time.Millisecond)
+
+		checks, msgs := transport.sent()
+		assert.LessOrEqual(t, len(checks), timeoutErr.Checks)
+		assert.Greater(t, timeoutErr.Checks, 1)
+		assert.Empty(t, msgs, "the query must not run")
+	})
+
+	t.Run("Parent context canceled", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &markerTransport{}
+		client := markerClient(t, transport)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := client.QueryAfter(ctx, "db", NewStmt("Events"), marker, time.Minute)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		var timeoutErr *MarkerTimeoutError
+		assert.False(t, goErrors.As(err, &timeoutErr))
+
+		_, msgs := transport.sent()
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("Check fails", func(t *testing.T) {
+		t.Parallel()
+
+		transport := &markerTransport{visibleAt: 1, failChecks: true}
+		client := markerClient(t, transport)
+
+		_, err := client.QueryAfter(context.Background(), "db", NewStmt("Events"), marker, time.Minute)
+		assert.Error(t, err)
+
+		checks, msgs := transport.sent()
+		assert.Len(t, checks, 1)
+		assert.Empty(t, msgs)
+	})
+}
+
+func TestMarkerBackoff(t *testing.T) {
+	t.Parallel()
+
+	b := (&Client{}).markerBackoff()
+	prev := time.Duration(0)
+	for i := 0; i < 30; i++ {
+		wait := b.NextBackOff()
+		require.NotEqual(t, backoff.Stop, wait, "the backoff must not stop before maxWait")
+		assert.LessOrEqual(t, wait, markerPollMax+markerPollMax/2)
+		if i == 0 {
+			assert.LessOrEqual(t, wait, markerPollInitial+markerPollInitial/2)
+		}
+		prev = wait
+	}
+	assert.GreaterOrEqual(t, prev, markerPollMax/2, "the intervals grow to markerPollMax")
+}
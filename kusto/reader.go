@@ -9,23 +9,27 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
-	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
-	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
 )
 
 // send allows us to send a table on a channel and know when everything has been written.
 type send struct {
-	inColumns           table.Columns
-	inRows              []value.Values
-	inRowErrors         []errors.Error
+	inColumns   table.Columns
+	inTable     *primaryTable
+	inRows      []value.Values
+	inRowErrors []errors.Error
+	// inCompletionErrors is set when inRowErrors are the errors of the DataSetCompletion, see completionErrs.
+	inCompletionErrors  bool
 	inTableFragmentType string
-	inProgress          v2.TableProgress
-	inNonPrimary        v2.DataTable
-	inCompletion        v2.DataSetCompletion
+	inProgress          frames.TableProgress
+	inNonPrimary        frames.DataTable
+	inCompletion        frames.DataSetCompletion
 	inErr               error
 
 	wg *sync.WaitGroup
@@ -43,6 +47,11 @@ type Row struct {
 	Values  value.Values
 	Error   *errors.Error
 	Replace bool
+
+	// table is set instead of the other fields when a primary table starts.
+	table *primaryTable
+	// completion is set when Error is an error of the DataSetCompletion, see RowIterator.completionErrs.
+	completion bool
 }
 
 // RowIterator is used to iterate over the returned Row objects returned by Kusto.
@@ -67,17 +76,48 @@ type RowIterator struct {
 	rows chan Row
 
 	mu sync.Mutex
+	// stopOnce runs Stop() once.
+	stopOnce sync.Once
+	// clientClosed is the closed flag of the Client of the call, nil if there is none. See Client.Close().
+	clientClosed *atomic.Bool
 
 	// progressive indicates if we are receiving a progressive stream or not.
 	progressive bool
+	// primaryResultsOnly indicates that the PrimaryResultsOnly() option was used, so non-primary tables are never received.
+	primaryResultsOnly bool
+	// location is the location datetime values are converted to, set by the PreserveLocation() option.
+	location *time.Location
 	// progress provides a progress indicator if the frames are progressive.
-	progress v2.TableProgress
+	progress frames.TableProgress
 	// nonPrimary contains dataTables that are not the primary table.
-	nonPrimary map[frames.TableKind]v2.DataTable
+	nonPrimary map[frames.TableKind]frames.DataTable
+	// nonPrimaryTables contains all the non-primary dataTables, in the order they were received, see NonPrimary().
+	nonPrimaryTables []frames.DataTable
 	// dsCompletion is the completion frame for a non-progressive query.
-	dsCompletion v2.DataSetCompletion
+	dsCompletion frames.DataSetCompletion
+	// finished indicates that the whole response was received, see CompletionInformation().
+	finished bool
+	// warnings are the warnings found in the QueryCompletionInformation table, see Warnings().
+	warnings []Warning
+	// logger receives the warnings, see setLogger().
+	logger Logger
+	// requestProperties are the properties sent with the call, see RequestProperties().
+	requestProperties ResolvedProperties
 
 	columns table.Columns
+	// table is the primary table of the last row returned, see TableIndex().
+	table *primaryTable
+	// onTable is called when a primary table starts, see QueryDataset().
+	onTable func(t *primaryTable)
+	// stats counts what the RowIterator received and returned, see Stats().
+	stats iterCounters
+
+	// cursor tracks the value of the CursorColumn column, see CursorCurrent().
+	cursor cursorTracker
+
+	// completionErrs are the inline errors read that the service reported in the DataSetCompletion, after the rows.
+	// Next(), Do() and Scan() skip them, as they did before NextRowOrError() and DoOnRowOrError() returned them.
+	completionErrs map[*errors.Error]bool
 
 	// error holds an error that was encountered. Once this is set, all calls on Rowiterator will
 	// just return the error here.
@@ -85,9 +125,18 @@ type RowIterator struct {
 
 	// mock hold our MockRows data if it has been provided for tests.
 	mock *MockRows
+
+	// started indicates that rows were read, see Materialize().
+	started bool
+	// buffered holds the rows once Materialize() was called.
+	buffered *bufferedRows
+	// style is the way the rows are read, which cannot change once a row was read, see MixedIterationError.
+	style iterStyle
+	// scan holds the state of Scan(), Row() and Err().
+	scan scanState
 }
 
-func newRowIterator(ctx context.Context, cancel context.CancelFunc, execResp execResp, header v2.DataSetHeader, op errors.Op) (*RowIterator, chan struct{}) {
+func newRowIterator(ctx context.Context, cancel context.CancelFunc, execResp execResp, header frames.DataSetHeader, op errors.Op) (*RowIterator, chan struct{}) {
 	ri := &RowIterator{
 		RequestHeader:  execResp.reqHeader,
 		ResponseHeader: execResp.respHeader,
@@ -104,8 +153,10 @@ func newRowIterator(ctx context.Context, cancel context.CancelFunc, execResp exe
 		inErr:        make(chan send),
 
 		rows:       make(chan Row, 1000),
-		nonPrimary: make(map[frames.TableKind]v2.DataTable),
+		nonPrimary: make(map[frames.TableKind]frames.DataTable),
 	}
+	ri.stats.bytes = execResp.bytes
+	ri.stats.start = nower()
 	columnsReady := ri.start()
 	return ri, columnsReady
 }
@@ -129,9 +180,19 @@ func (r *RowIterator) start() chan struct{} {
 				closeDone()
 			case sent, ok := <-r.inRows:
 				if !ok {
+					r.stats.stop()
+					r.mu.Lock()
+					r.finished = true
+					r.mu.Unlock()
 					close(r.rows)
 					return
 				}
+				if sent.inTable != nil {
+					select {
+					case <-r.ctx.Done():
+					case r.rows <- Row{table: sent.inTable}:
+					}
+				}
 				if sent.inRows != nil {
 					for k, values := range sent.inRows {
 						select {
@@ -146,7 +207,7 @@ func (r *RowIterator) start() chan struct{} {
 						e := e // capture so we can send reference
 						select {
 						case <-r.ctx.Done():
-						case r.rows <- Row{Error: &e}:
+						case r.rows <- Row{Error: &e, completion: sent.inCompletionErrors}:
 						}
 					}
 				}
@@ -159,9 +220,14 @@ func (r *RowIterator) start() chan struct{} {
 			case sent := <-r.inNonPrimary:
 				r.mu.Lock()
 				r.nonPrimary[sent.inNonPrimary.TableKind] = sent.inNonPrimary
+				r.nonPrimaryTables = append(r.nonPrimaryTables, sent.inNonPrimary)
+				if sent.inNonPrimary.TableKind == frames.QueryCompletionInformation {
+					r.addWarnings(warningsFromCompletionInfo(sent.inNonPrimary))
+				}
 				sent.done()
 				r.mu.Unlock()
 			case sent := <-r.inCompletion:
+				r.stats.stop()
 				r.mu.Lock()
 				r.dsCompletion = sent.inCompletion
 				sent.done()
@@ -195,7 +261,9 @@ func (r *RowIterator) Mock(m *MockRows) error {
 // Deprecated: Use DoOnRowOrError() instead for more robust error handling. In a future version, this will be removed, and NextRowOrError will replace it.
 // Do calls f for every row returned by the query. If f returns a non-nil error, iteration stops.
 // This method will fail on errors inline within the rows, even though they could potentially be recovered and more data might be available.
+// Like Next(), it does not return the failures the service reports at the end of the results.
 // This behavior is to keep the interface compatible.
+// It cannot be mixed with Scan() or Rows() on the same RowIterator, see MixedIterationError.
 func (r *RowIterator) Do(f func(r *table.Row) error) error {
 	for {
 		row, err := r.Next()
@@ -212,8 +280,10 @@ func (r *RowIterator) Do(f func(r *table.Row) error) error {
 }
 
 // DoOnRowOrError calls f for every row returned by the query. If errors occur inline within the rows, they are passed to f.
-// Other errors will stop the iteration and be returned.
+// This includes the failures the service reports at the end of the results, such as a partial query failure, which are
+// passed to f after the rows that were received. Other errors will stop the iteration and be returned.
 // If f returns a non-nil error, iteration stops.
+// It cannot be mixed with Scan() or Rows() on the same RowIterator, see MixedIterationError.
 func (r *RowIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error) error {
 	for {
 		row, inlineErr, err := r.NextRowOrError()
@@ -230,25 +300,39 @@ func (r *RowIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error
 }
 
 // Stop is called to stop any further iteration. Always defer a Stop() call after
-// receiving a RowIterator.
+// receiving a RowIterator. It cancels the query: the response is no longer read and its body is closed, which tears
+// down the stream of a progressive query that has more frames to send.
+// Stop can be called more than once, and the rows cannot be read once it was called.
 func (r *RowIterator) Stop() {
-	r.cancel()
+	r.stopOnce.Do(func() {
+		r.stats.stop()
+		if r.cancel != nil {
+			r.cancel()
+		}
+	})
 }
 
 // Deprecated: Use NextRowOrError() instead for more robust error handling. In a future version, this will be removed, and NextRowOrError will replace it.
 // Next gets the next Row from the query. io.EOF is returned if there are no more entries in the output.
 // This method will fail on errors inline within the rows, even though they could potentially be recovered and more data might be available.
+// The failures the service reports at the end of the results, such as a partial query failure, are not returned, use
+// NextRowOrError() to get them.
 // Once Next() returns an error, all subsequent calls will return the same error.
 func (r *RowIterator) Next() (row *table.Row, finalError error) {
-	row, inlineErr, err := r.NextRowOrError()
-	if err != nil {
-		return nil, err
-	}
-	if inlineErr != nil {
-		r.setError(inlineErr)
-		return nil, inlineErr
+	for {
+		row, inlineErr, err := r.NextRowOrError()
+		if err != nil {
+			return nil, err
+		}
+		if inlineErr != nil {
+			if r.completionErrs[inlineErr] {
+				continue
+			}
+			r.setError(inlineErr)
+			return nil, inlineErr
+		}
+		return row, err
 	}
-	return row, err
 }
 
 // NextRowOrError gets the next Row or service-side error from the query.
@@ -256,39 +340,104 @@ func (r *RowIterator) Next() (row *table.Row, finalError error) {
 // Once finalError returns non-nil, all subsequent calls will return the same error.
 // finalError will be set to io.EOF is when frame parsing completed with success or partial success (data + errors).
 // if finalError is not io.EOF, reading the frame has resulted in a failure state (no data is expected).
+// It cannot be mixed with Scan() or Rows() on the same RowIterator, see MixedIterationError.
 func (r *RowIterator) NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
-	if err := r.getError(); err != nil {
+	if err := r.useStyle(styleNext); err != nil {
 		return nil, nil, err
 	}
+	return r.nextRowOrError()
+}
+
+// nextRowOrError implements NextRowOrError(), without recording the iteration style.
+func (r *RowIterator) nextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
+	for {
+		row, inlineErr, tbl, err := r.nextEntry()
+		if tbl == nil {
+			if row != nil {
+				r.stats.rows.Add(1)
+			}
+			return row, inlineErr, err
+		}
+		r.startTable(tbl)
+	}
+}
+
+// nextEntry returns the next row, inline error or start of a primary table. The query is cancelled when its Client is
+// closed, which fails with ClientClosedErr.
+func (r *RowIterator) nextEntry() (row *table.Row, inlineError *errors.Error, tbl *primaryTable, finalError error) {
+	row, inlineError, tbl, finalError = r.readEntry()
+	if finalError != nil && finalError != io.EOF && r.ctx.Err() != nil && r.clientClosed != nil && r.clientClosed.Load() {
+		finalError = ClientClosedErr
+	}
+	return row, inlineError, tbl, finalError
+}
+
+// readEntry implements nextEntry().
+func (r *RowIterator) readEntry() (row *table.Row, inlineError *errors.Error, tbl *primaryTable, finalError error) {
+	if err := r.getError(); err != nil {
+		return nil, nil, nil, err
+	}
+	if r.buffered != nil {
+		return r.buffered.next(r.ctx)
+	}
+	r.started = true
 
 	if r.mock != nil {
 		if r.ctx.Err() != nil {
-			return nil, nil, r.ctx.Err()
+			return nil, nil, nil, r.ctx.Err()
 		}
 		nextRow, err := r.mock.nextRow()
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
-		return nextRow, nil, nil
+		r.localize(nextRow.Values)
+		return nextRow, nil, nil, nil
 	}
 
+	// The rows already received are not returned once the RowIterator was stopped, which the select would pick at random.
+	if err := r.ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
 	select {
 	case <-r.ctx.Done():
-		return nil, nil, r.ctx.Err()
+		return nil, nil, nil, r.ctx.Err()
 	case kvs, ok := <-r.rows:
 		if !ok {
 			if err := r.getError(); err != nil {
-				return nil, nil, err
+				return nil, nil, nil, err
 			}
-			return nil, nil, io.EOF
+			return nil, nil, nil, io.EOF
+		}
+		if kvs.table != nil {
+			return nil, nil, kvs.table, nil
 		}
 		if kvs.Error != nil {
-			return nil, kvs.Error, nil
+			if kvs.completion {
+				if r.completionErrs == nil {
+					r.completionErrs = map[*errors.Error]bool{}
+				}
+				r.completionErrs[kvs.Error] = true
+			}
+			return nil, kvs.Error, nil, nil
+		}
+		columns := r.columns
+		if r.table != nil {
+			columns = r.table.columns
 		}
-		return &table.Row{ColumnTypes: r.columns, Values: kvs.Values, Op: r.op, Replace: kvs.Replace}, nil, nil
+		r.cursor.track(columns, kvs.Values)
+		r.localize(kvs.Values)
+		return &table.Row{ColumnTypes: columns, Values: kvs.Values, Op: r.op, Replace: kvs.Replace}, nil, nil, nil
 	}
 }
 
+// localize converts the datetime values in values to the location set by PreserveLocation(), if any.
+func (r *RowIterator) localize(values value.Values) {
+	if r.location == nil {
+		return
+	}
+	localizeValues(values, r.location)
+}
+
 func (r *RowIterator) getError() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -313,9 +462,17 @@ func (r *RowIterator) Progressive() bool {
 	return r.progressive
 }
 
+// NonPrimarySuppressedErr is returned when asking a RowIterator for a non-primary table when the query was made
+// with the PrimaryResultsOnly() option.
+var NonPrimarySuppressedErr = errors.ES(errors.OpQuery, errors.KClientArgs, "non-primary tables were suppressed by the PrimaryResultsOnly() option").SetNoRetry()
+
 // GetNonPrimary will return a non-primary dataTable if it exists from the last query. The non-primary table and common names are defined under the frames.TableKind enum.
 // Returns io.ErrUnexpectedEOF if not found. May not have all tables until RowIterator has reached io.EOF.
-func (r *RowIterator) GetNonPrimary(tableKind, tableName frames.TableKind) (v2.DataTable, error) {
+// Returns NonPrimarySuppressedErr if the query was made with the PrimaryResultsOnly() option.
+func (r *RowIterator) GetNonPrimary(tableKind, tableName frames.TableKind) (frames.DataTable, error) {
+	if r.primaryResultsOnly {
+		return frames.DataTable{}, NonPrimarySuppressedErr
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	for _, npTable := range r.nonPrimary {
@@ -323,18 +480,18 @@ func (r *RowIterator) GetNonPrimary(tableKind, tableName frames.TableKind) (v2.D
 			return npTable, nil
 		}
 	}
-	return v2.DataTable{}, io.ErrUnexpectedEOF
+	return frames.DataTable{}, io.ErrUnexpectedEOF
 }
 
 // GetExtendedProperties will return the extended properties' table from the iterator, if it exists.
 // Returns io.ErrUnexpectedEOF if not found. May not have all tables until RowIterator has reached io.EOF.
-func (r *RowIterator) GetExtendedProperties() (v2.DataTable, error) {
+func (r *RowIterator) GetExtendedProperties() (frames.DataTable, error) {
 	return r.GetNonPrimary(frames.QueryProperties, frames.ExtendedProperties)
 }
 
 // GetQueryCompletionInformation will return the query completion information table from the iterator, if it exists.
 // Returns io.ErrUnexpectedEOF if not found. May not have all tables until RowIterator has reached io.EOF.
-func (r *RowIterator) GetQueryCompletionInformation() (v2.DataTable, error) {
+func (r *RowIterator) GetQueryCompletionInformation() (frames.DataTable, error) {
 	return r.GetNonPrimary(frames.QueryCompletionInformation, frames.QueryCompletionInformation)
 }
 
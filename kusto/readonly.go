@@ -0,0 +1,68 @@
+package kusto
+
+// readonly.go implements WithReadOnlyClient(), which stops a Client from sending anything that could write.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// ReadOnlyError is returned when a Client created with WithReadOnlyClient() rejects a call before sending it.
+type ReadOnlyError struct {
+	// Op is the operation that was rejected.
+	Op errors.Op
+	// Reason describes why the call was rejected.
+	Reason string
+}
+
+// Error implements error.
+func (r *ReadOnlyError) Error() string {
+	return fmt.Sprintf("Op(%s): the client is read-only: %s", r.Op, r.Reason)
+}
+
+// WithReadOnlyClient makes the Client read-only. Every Mgmt() or MgmtToJson() call and every Query() or QueryToJson()
+// statement that starts with a period(.), which is a management command, are rejected with a *ReadOnlyError before
+// being sent.
+// In addition, every query is sent with the RequestReadonly() option, so that the service also refuses to write.
+func WithReadOnlyClient() Option {
+	return func(c *Client) {
+		c.readOnly = true
+	}
+}
+
+// readOnlyQuery returns the options of a query sent by a read-only client, or an error if the query is a command.
+func (c *Client) readOnlyQuery(query Stmt, options []QueryOption) ([]QueryOption, error) {
+	if !c.readOnly {
+		return options, nil
+	}
+	if isCommand(query.queryStr) {
+		return nil, &ReadOnlyError{Op: errors.OpQuery, Reason: "management commands cannot be sent"}
+	}
+	return append(options[:len(options):len(options)], RequestReadonly()), nil
+}
+
+// readOnlyMgmt returns an error if the client is read-only.
+func (c *Client) readOnlyMgmt() error {
+	if !c.readOnly {
+		return nil
+	}
+	return &ReadOnlyError{Op: errors.OpMgmt, Reason: "Mgmt() calls cannot be made"}
+}
+
+// isCommand returns true if query is a management command, that is if it starts with a period(.) once leading
+// whitespace and comments are skipped.
+func isCommand(query string) bool {
+	for {
+		query = strings.TrimSpace(query)
+		if !strings.HasPrefix(query, "//") {
+			return strings.HasPrefix(query, ".")
+		}
+		i := strings.IndexByte(query, '\n')
+		if i < 0 {
+			return false
+		}
+		query = query[i+1:]
+	}
+}
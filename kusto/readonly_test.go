@@ -0,0 +1,164 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordTransport is a fake http.RoundTripper that records the requests and answers with an empty result.
+type recordTransport struct {
+	mu   sync.Mutex
+	msgs []queryMsg
+}
+
+func (r *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") || strings.HasSuffix(req.URL.Path, "/auth/metadata") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.msgs = append(r.msgs, msg)
+	r.mu.Unlock()
+
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (r *recordTransport) sent() []queryMsg {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]queryMsg(nil), r.msgs...)
+}
+
+func TestReadOnlyClient(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		call     func(ctx context.Context, client *Client) error
+		wantOp   errors.Op
+		wantSent bool
+	}{
+		{
+			desc: "Query is sent as read-only",
+			call: func(ctx context.Context, client *Client) error {
+				iter, err := client.Query(ctx, "db", NewStmt("T | take 1"))
+				if err == nil {
+					iter.Stop()
+				}
+				return err
+			},
+			wantSent: true,
+		},
+		{
+			desc: "QueryToJson is sent as read-only",
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("T | take 1"))
+				return err
+			},
+			wantSent: true,
+		},
+		{
+			desc: "Command in Query is rejected",
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.Query(ctx, "db", NewStmt("  .drop table T"))
+				return err
+			},
+			wantOp: errors.OpQuery,
+		},
+		{
+			desc: "Command after comments is rejected",
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.QueryToJson(ctx, "db", NewStmt("// cleanup\n\n  // really\n.drop table T"))
+				return err
+			},
+			wantOp: errors.OpQuery,
+		},
+		{
+			desc: "Mgmt is rejected",
+			call: func(ctx context.Context, client *Client) error {
+				_, err := client.Mgmt(ctx, "db", NewStmt(".show version"))
+				return err
+			},
+			wantOp: errors.OpMgmt,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &recordTransport{}
+			client := newTestClient(t, "https://readonly.kusto.windows.net", transport)
+			WithReadOnlyClient()(client)
+
+			err := test.call(context.Background(), client)
+			if !test.wantSent {
+				var readOnlyErr *ReadOnlyError
+				require.True(t, goErrors.As(err, &readOnlyErr), "got %T: %v", err, err)
+				assert.Equal(t, test.wantOp, readOnlyErr.Op)
+				assert.Empty(t, transport.sent(), "a rejected call must not reach the service")
+				return
+			}
+
+			require.NoError(t, err)
+			sent := transport.sent()
+			require.Len(t, sent, 1)
+			assert.Equal(t, true, sent[0].Properties.Options[RequestReadonlyValue])
+		})
+	}
+}
+
+func TestReadonlyOptions(t *testing.T) {
+	t.Parallel()
+
+	opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, RequestReadonly(), RequestReadonlyHardline())
+	require.NoError(t, err)
+	assert.Equal(t, true, opts.requestProperties.options()[RequestReadonlyValue])
+	assert.Equal(t, true, opts.requestProperties.options()[RequestReadonlyHardlineValue])
+
+	// Without WithReadOnlyClient(), the client does not check the statements.
+	client := &Client{}
+	options, err := client.readOnlyQuery(NewStmt(".drop table T"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, options)
+}
+
+func TestIsCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: ".show tables", want: true},
+		{query: "\n\t .show tables", want: true},
+		{query: "// comment\n.show tables", want: true},
+		{query: "T | where x == '.'", want: false},
+		{query: "// .show tables", want: false},
+		{query: "// comment\nT", want: false},
+		{query: "", want: false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, isCommand(test.query), "query %q", test.query)
+	}
+}
@@ -0,0 +1,220 @@
+package kusto
+
+// render.go implements RowIterator.WriteTable(), which writes the rows as an aligned table for consoles.
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+const (
+	// defaultRenderMaxWidth is the default width of a column of WriteTable().
+	defaultRenderMaxWidth = 40
+	// renderTruncated marks a cell that was truncated.
+	renderTruncated = "..."
+)
+
+type renderOptions struct {
+	maxWidth      int
+	null          string
+	timeFormat    string
+	rowLimit      int
+	expandDynamic bool
+}
+
+// RenderOption is an optional argument to WriteTable().
+type RenderOption func(o *renderOptions)
+
+// RenderMaxColumnWidth sets the maximum width of a column, in characters. Longer cells are truncated and end with
+// "...". Defaults to 40, values below 4 are raised to 4.
+func RenderMaxColumnWidth(n int) RenderOption {
+	return func(o *renderOptions) {
+		o.maxWidth = n
+	}
+}
+
+// RenderNull sets the text written for null values. Defaults to "null".
+func RenderNull(s string) RenderOption {
+	return func(o *renderOptions) {
+		o.null = s
+	}
+}
+
+// RenderTimeFormat sets the layout, as used by time.Time.Format(), of datetime values. Defaults to time.RFC3339Nano.
+func RenderTimeFormat(layout string) RenderOption {
+	return func(o *renderOptions) {
+		o.timeFormat = layout
+	}
+}
+
+// RenderRowLimit sets the maximum number of rows written. Once it is reached, a line saying so is written and the
+// iteration is stopped. Defaults to 0, which writes all the rows.
+func RenderRowLimit(n int) RenderOption {
+	return func(o *renderOptions) {
+		o.rowLimit = n
+	}
+}
+
+// RenderExpandDynamic writes dynamic values as JSON. By default, objects and arrays that are wider than the column are
+// summarized, such as "{...3 keys}".
+func RenderExpandDynamic() RenderOption {
+	return func(o *renderOptions) {
+		o.expandDynamic = true
+	}
+}
+
+// WriteTable writes the rows of the iterator to w as a table with fixed width columns and a header, such as:
+//
+//	Name  Count  When
+//	----  -----  --------------------
+//	a         3  2022-01-02T03:04:05Z
+//
+// Numbers are aligned to the right. Newlines and tabs in values are escaped so that each row is on one line.
+// All the rows are read before anything is written, as the width of the columns depends on every row; use
+// RenderRowLimit() to bound the memory used. This method will fail on errors inline within the rows.
+func (r *RowIterator) WriteTable(w io.Writer, options ...RenderOption) error {
+	opts := renderOptions{maxWidth: defaultRenderMaxWidth, null: "null", timeFormat: time.RFC3339Nano}
+	for _, o := range options {
+		o(&opts)
+	}
+	if opts.maxWidth < utf8.RuneCountInString(renderTruncated)+1 {
+		opts.maxWidth = utf8.RuneCountInString(renderTruncated) + 1
+	}
+
+	var cells [][]string
+	limited := false
+	err := r.Do(func(row *table.Row) error {
+		if row.Replace {
+			cells = cells[:0]
+		}
+		if opts.rowLimit > 0 && len(cells) == opts.rowLimit {
+			limited = true
+			return errRenderLimit
+		}
+		line := make([]string, len(r.columns))
+		for i := range line {
+			if i < len(row.Values) {
+				line[i] = opts.cell(row.Values[i])
+			}
+		}
+		cells = append(cells, line)
+		return nil
+	})
+	switch {
+	case limited:
+		r.Stop()
+	case err != nil:
+		return err
+	}
+
+	widths := make([]int, len(r.columns))
+	header := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		header[i] = opts.truncate(col.Name)
+		widths[i] = utf8.RuneCountInString(header[i])
+	}
+	for _, line := range cells {
+		for i, cell := range line {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	b := &strings.Builder{}
+	writeLine := func(line []string, rightAlign func(i int) bool) {
+		for i, cell := range line {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			switch {
+			case rightAlign(i):
+				b.WriteString(pad + cell)
+			case i == len(line)-1:
+				// No trailing spaces at the end of a line.
+				b.WriteString(cell)
+			default:
+				b.WriteString(cell + pad)
+			}
+			if i < len(line)-1 {
+				b.WriteString("  ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	isNumber := func(i int) bool {
+		switch r.columns[i].Type {
+		case types.Int, types.Long, types.Real, types.Decimal:
+			return true
+		}
+		return false
+	}
+
+	writeLine(header, func(int) bool { return false })
+	dashes := make([]string, len(widths))
+	for i, width := range widths {
+		dashes[i] = strings.Repeat("-", width)
+	}
+	writeLine(dashes, func(int) bool { return false })
+	for _, line := range cells {
+		writeLine(line, isNumber)
+	}
+	if limited {
+		fmt.Fprintf(b, "(only the first %d rows are shown)\n", opts.rowLimit)
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.E(r.op, errors.KIO, err)
+	}
+	return nil
+}
+
+// errRenderLimit stops the iteration of WriteTable() once the row limit is reached.
+var errRenderLimit = fmt.Errorf("row limit reached")
+
+// cell returns the text of a value in a table.
+func (o renderOptions) cell(v value.Kusto) string {
+	if value.IsNull(v) {
+		return o.null
+	}
+
+	escape := strings.NewReplacer("\n", "\\n", "\r", "\\r", "\t", "\\t").Replace
+	switch v := v.(type) {
+	case value.DateTime:
+		return o.truncate(v.Value.Format(o.timeFormat))
+	case value.Real:
+		return o.truncate(strconv.FormatFloat(v.Value, 'g', -1, 64))
+	case value.Dynamic:
+		s := escape(v.String())
+		switch {
+		case o.expandDynamic:
+			return s
+		case utf8.RuneCountInString(s) > o.maxWidth:
+			return o.truncate(escape(v.Summary()))
+		}
+		return s
+	}
+	return o.truncate(escape(v.String()))
+}
+
+// truncate shortens s to the maximum width, ending it with renderTruncated if it was longer.
+func (o renderOptions) truncate(s string) string {
+	if utf8.RuneCountInString(s) <= o.maxWidth {
+		return s
+	}
+	keep := o.maxWidth - utf8.RuneCountInString(renderTruncated)
+	for i := range s {
+		if keep == 0 {
+			return s[:i] + renderTruncated
+		}
+		keep--
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package kusto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTable(t *testing.T) {
+	t.Parallel()
+
+	when := time.Date(2022, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	columns := table.Columns{
+		{Name: "Name", Type: "string"},
+		{Name: "Count", Type: "long"},
+		{Name: "Price", Type: "real"},
+		{Name: "When", Type: "datetime"},
+		{Name: "Props", Type: "dynamic"},
+	}
+	rows := []value.Values{
+		{value.NewString("short"), value.NewLong(3), value.NewReal(1.5), value.NewDateTime(when), value.NewDynamic([]byte(`{"a":1}`))},
+		{
+			value.NewString("a name that is much longer than the maximum width of a column"),
+			value.NewLong(-12345),
+			value.NullReal(),
+			value.NullDateTime(),
+			value.NewDynamic([]byte(`{"first":"a long value","second":"another long value","third":[1,2,3]}`)),
+		},
+		{value.NewString("two\nlines"), value.NullLong(), value.NewReal(0), value.NewDateTime(when.Add(time.Hour)), value.NewDynamic([]byte(`[1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20]`))},
+		{value.NullString(), value.NewLong(0), value.NewReal(-2.25), value.NewDateTime(when), value.NullDynamic()},
+	}
+
+	tests := []struct {
+		desc    string
+		options []RenderOption
+		golden  string
+	}{
+		{
+			desc:   "Defaults",
+			golden: "render_default.golden",
+		},
+		{
+			desc:    "Options",
+			options: []RenderOption{RenderMaxColumnWidth(16), RenderNull("<null>"), RenderTimeFormat("2006-01-02 15:04"), RenderRowLimit(3)},
+			golden:  "render_options.golden",
+		},
+		{
+			desc:    "Expanded dynamic",
+			options: []RenderOption{RenderExpandDynamic(), RenderMaxColumnWidth(10)},
+			golden:  "render_expanded.golden",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := spoolIterator(columns, func(send func(fr v2.TableFragment)) {
+				send(v2.TableFragment{KustoRows: rows})
+			})
+			defer iter.Stop()
+
+			got := &bytes.Buffer{}
+			require.NoError(t, iter.WriteTable(got, test.options...))
+
+			want, err := os.ReadFile(filepath.Join("testdata", test.golden))
+			require.NoError(t, err)
+			assert.Equal(t, string(want), got.String())
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package kusto
+
+// requestid.go implements the accessors of the IDs that correlate a call with the logs of the service.
+
+import (
+	"net/http"
+)
+
+const (
+	// clientRequestIDHeader is the header of the request that holds its client request ID, see ClientRequestID().
+	clientRequestIDHeader = "x-ms-client-request-id"
+	// activityIDHeader is the header of the response that holds the ID the service gave the request.
+	activityIDHeader = "x-ms-activity-id"
+)
+
+// RequestID returns the client request ID sent with the query, which is the one passed with ClientRequestID(), or
+// the one the client generated. It is the ClientActivityId of the query in .show queries, and is what the service
+// team needs to look into a query. It is empty for a RowIterator that was not returned by a Client, such as one with
+// MockRows.
+func (r *RowIterator) RequestID() string {
+	return r.RequestHeader.Get(clientRequestIDHeader)
+}
+
+// ActivityID returns the ID the service gave the query, from the x-ms-activity-id header of the response, or
+// "" if the service did not return one.
+func (r *RowIterator) ActivityID() string {
+	return r.ResponseHeader.Get(activityIDHeader)
+}
+
+// JsonResult is the response to Client.QueryToJsonResult().
+type JsonResult struct {
+	// JSON is the response of the service, as returned by QueryToJson().
+	JSON string
+	// RequestHeader is the http.Header sent in the request to the server.
+	RequestHeader http.Header
+	// ResponseHeader is the http.Header sent in the response from the server.
+	ResponseHeader http.Header
+}
+
+// RequestID returns the client request ID sent with the query, see RowIterator.RequestID().
+func (j JsonResult) RequestID() string {
+	return j.RequestHeader.Get(clientRequestIDHeader)
+}
+
+// ActivityID returns the ID the service gave the query, see RowIterator.ActivityID().
+func (j JsonResult) ActivityID() string {
+	return j.ResponseHeader.Get(activityIDHeader)
+}
@@ -0,0 +1,148 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// activityTransport is a fake http.RoundTripper whose responses have an x-ms-activity-id header derived from the
+// client request ID of the request.
+type activityTransport struct{}
+
+func (activityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	header := http.Header{}
+	header.Set("x-ms-activity-id", "activity-of-"+req.Header.Get("x-ms-client-request-id"))
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: header, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	type ids struct {
+		requestID, activityID string
+	}
+
+	tests := []struct {
+		desc    string
+		options []QueryOption
+		call    func(client *Client, options ...QueryOption) (ids, error)
+	}{
+		{
+			desc: "Query",
+			call: func(client *Client, options ...QueryOption) (ids, error) {
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"), options...)
+				if err != nil {
+					return ids{}, err
+				}
+				defer iter.Stop()
+				return ids{iter.RequestID(), iter.ActivityID()}, nil
+			},
+		},
+		{
+			desc:    "Query with ClientRequestID",
+			options: []QueryOption{ClientRequestID("my-request")},
+			call: func(client *Client, options ...QueryOption) (ids, error) {
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"), options...)
+				if err != nil {
+					return ids{}, err
+				}
+				defer iter.Stop()
+				return ids{iter.RequestID(), iter.ActivityID()}, nil
+			},
+		},
+		{
+			desc: "QueryToJsonResult",
+			call: func(client *Client, options ...QueryOption) (ids, error) {
+				result, err := client.QueryToJsonResult(context.Background(), "db", NewStmt("T"), options...)
+				if err != nil {
+					return ids{}, err
+				}
+				assert.True(t, strings.HasPrefix(result.JSON, "["))
+				return ids{result.RequestID(), result.ActivityID()}, nil
+			},
+		},
+		{
+			desc:    "QueryToJsonResult with ClientRequestID",
+			options: []QueryOption{ClientRequestID("my-request")},
+			call: func(client *Client, options ...QueryOption) (ids, error) {
+				result, err := client.QueryToJsonResult(context.Background(), "db", NewStmt("T"), options...)
+				if err != nil {
+					return ids{}, err
+				}
+				return ids{result.RequestID(), result.ActivityID()}, nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := newTestClient(t, "https://requestid.kusto.windows.net", activityTransport{})
+
+			got, err := test.call(client, test.options...)
+			require.NoError(t, err)
+
+			if len(test.options) > 0 {
+				assert.Equal(t, "my-request", got.requestID)
+			} else {
+				// The generated ID is surfaced.
+				require.True(t, strings.HasPrefix(got.requestID, "KGC.execute;"), got.requestID)
+				_, err := uuid.Parse(strings.TrimPrefix(got.requestID, "KGC.execute;"))
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, "activity-of-"+got.requestID, got.activityID)
+		})
+	}
+
+	iter := &RowIterator{}
+	assert.Empty(t, iter.RequestID())
+	assert.Empty(t, iter.ActivityID())
+}
+
+// failingTransport is a fake http.RoundTripper whose responses are a 400 with an x-ms-activity-id header.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("x-ms-activity-id", "activity-of-"+req.Header.Get("x-ms-client-request-id"))
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Status:     "400 Bad Request",
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"Syntax error"}}`)),
+	}, nil
+}
+
+func TestRequestIDInErrors(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, "https://requestid.kusto.windows.net", failingTransport{})
+
+	_, err := client.Query(context.Background(), "db", NewStmt("T"), ClientRequestID("my-request"))
+	require.Error(t, err)
+
+	var httpErr *errors.HttpError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, "my-request", httpErr.ClientRequestID())
+	assert.Equal(t, "activity-of-my-request", httpErr.ActivityID())
+	assert.Equal(t, http.StatusBadRequest, httpErr.KustoError.StatusCode())
+	assert.Contains(t, err.Error(), "ClientRequestID(my-request)")
+}
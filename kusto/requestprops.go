@@ -0,0 +1,294 @@
+package kusto
+
+// requestprops.go holds the typed request options and the encoding of the body of a query, which is on the hot path
+// of every call and so avoids reflection.
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+const resultsProgressiveEnabledValue = "results_progressive_enabled"
+
+// optBool is a bool option that can be unset.
+type optBool uint8
+
+const (
+	optUnset optBool = iota
+	optFalse
+	optTrue
+)
+
+func newOptBool(b bool) optBool {
+	if b {
+		return optTrue
+	}
+	return optFalse
+}
+
+// typedOptions holds the options set on most requests, which are encoded without going through the Options map.
+type typedOptions struct {
+	progressive      optBool
+	readOnly         optBool
+	noTruncation     optBool
+	noRequestTimeout optBool
+	// serverTimeout is the marshaled value.Timespan of the servertimeout option, empty if unset.
+	serverTimeout string
+}
+
+// boolField returns the field of a typed bool option, or nil if key is not one.
+func (t *typedOptions) boolField(key string) *optBool {
+	switch key {
+	case resultsProgressiveEnabledValue:
+		return &t.progressive
+	case RequestReadonlyValue:
+		return &t.readOnly
+	case NoTruncationValue:
+		return &t.noTruncation
+	case NoRequestTimeoutValue:
+		return &t.noRequestTimeout
+	}
+	return nil
+}
+
+// setOption sets the request option key to v. Common options are stored in typed fields, others in the Options map.
+// A time.Duration or value.Timespan is stored as its timespan literal, which is what the service accepts.
+func (r *requestProperties) setOption(key string, v interface{}) {
+	r.deleteOption(key)
+	switch d := v.(type) {
+	case time.Duration:
+		v = value.Timespan{Value: d, Valid: true}.Marshal()
+	case value.Timespan:
+		if !d.Valid {
+			return
+		}
+		v = d.Marshal()
+	}
+	if f := r.typed.boolField(key); f != nil {
+		if b, ok := v.(bool); ok {
+			*f = newOptBool(b)
+			return
+		}
+	}
+	if key == ServerTimeoutValue {
+		if s, ok := v.(string); ok && s != "" {
+			r.typed.serverTimeout = s
+			return
+		}
+	}
+	if r.Options == nil {
+		r.Options = map[string]interface{}{}
+	}
+	r.Options[key] = v
+}
+
+// option returns the value of the request option key, and false if it is not set.
+func (r *requestProperties) option(key string) (interface{}, bool) {
+	if f := r.typed.boolField(key); f != nil && *f != optUnset {
+		return *f == optTrue, true
+	}
+	if key == ServerTimeoutValue && r.typed.serverTimeout != "" {
+		return r.typed.serverTimeout, true
+	}
+	v, ok := r.Options[key]
+	return v, ok
+}
+
+// deleteOption unsets the request option key.
+func (r *requestProperties) deleteOption(key string) {
+	if f := r.typed.boolField(key); f != nil {
+		*f = optUnset
+	}
+	if key == ServerTimeoutValue {
+		r.typed.serverTimeout = ""
+	}
+	delete(r.Options, key)
+}
+
+// options returns all the request options in a new map.
+func (r *requestProperties) options() map[string]interface{} {
+	m := make(map[string]interface{}, len(r.Options)+5)
+	for k, v := range r.Options {
+		m[k] = v
+	}
+	for _, key := range []string{resultsProgressiveEnabledValue, RequestReadonlyValue, NoTruncationValue, NoRequestTimeoutValue, ServerTimeoutValue} {
+		if v, ok := r.option(key); ok {
+			m[key] = v
+		}
+	}
+	return m
+}
+
+// appendJSON appends the JSON encoding of the message to b. It is equivalent to encoding/json, apart from the order
+// of the options and the escaping of HTML characters, which are not escaped.
+func (m queryMsg) appendJSON(b []byte) ([]byte, error) {
+	b = append(b, `{"db":`...)
+	b = appendJSONString(b, m.DB)
+	b = append(b, `,"csl":`...)
+	b = appendJSONString(b, m.CSL)
+	b = append(b, `,"properties":`...)
+	b, err := m.Properties.appendJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// appendJSON appends the JSON encoding of the request properties to b.
+func (r *requestProperties) appendJSON(b []byte) ([]byte, error) {
+	b = append(b, `{"Options":{`...)
+	first := true
+	key := func(k string) {
+		if !first {
+			b = append(b, ',')
+		}
+		first = false
+		b = appendJSONString(b, k)
+		b = append(b, ':')
+	}
+
+	for _, o := range []struct {
+		key string
+		v   optBool
+	}{
+		{NoRequestTimeoutValue, r.typed.noRequestTimeout},
+		{NoTruncationValue, r.typed.noTruncation},
+		{RequestReadonlyValue, r.typed.readOnly},
+		{resultsProgressiveEnabledValue, r.typed.progressive},
+	} {
+		if o.v != optUnset {
+			key(o.key)
+			b = strconv.AppendBool(b, o.v == optTrue)
+		}
+	}
+	if r.typed.serverTimeout != "" {
+		key(ServerTimeoutValue)
+		b = appendJSONString(b, r.typed.serverTimeout)
+	}
+
+	if len(r.Options) > 0 {
+		keys := make([]string, 0, len(r.Options))
+		for k := range r.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key(k)
+			var err error
+			if b, err = appendJSONValue(b, r.Options[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	b = append(b, `},"Parameters":`...)
+
+	if r.Parameters == nil {
+		b = append(b, "null"...)
+	} else {
+		keys := make([]string, 0, len(r.Parameters))
+		for k := range r.Parameters {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONString(b, k)
+			b = append(b, ':')
+			b = appendJSONString(b, r.Parameters[k])
+		}
+		b = append(b, '}')
+	}
+
+	b = append(b, `,"Application":`...)
+	b = appendJSONString(b, r.Application)
+	b = append(b, `,"User":`...)
+	b = appendJSONString(b, r.User)
+	b = append(b, `,"ClientRequestID":`...)
+	b = appendJSONString(b, r.ClientRequestID)
+	return append(b, '}'), nil
+}
+
+// appendJSONValue appends the JSON encoding of v to b, using encoding/json for the types that are not common options.
+func appendJSONValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return appendJSONString(b, v), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case nil:
+		return append(b, "null"...), nil
+	case time.Duration:
+		return appendJSONString(b, value.Timespan{Value: v, Valid: true}.Marshal()), nil
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, j...), nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string to b. Invalid UTF-8 is replaced with U+FFFD, like encoding/json does.
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are escaped by encoding/json, as they are line terminators in JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
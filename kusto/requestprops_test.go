@@ -0,0 +1,258 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyQueryMsg is the message as it was encoded with encoding/json before the options were typed.
+type legacyQueryMsg struct {
+	DB         string `json:"db"`
+	CSL        string `json:"csl"`
+	Properties struct {
+		Options         map[string]interface{}
+		Parameters      map[string]string
+		Application     string
+		User            string
+		ClientRequestID string
+	} `json:"properties,omitempty"`
+}
+
+func legacyEncode(msg queryMsg) ([]byte, error) {
+	legacy := legacyQueryMsg{DB: msg.DB, CSL: msg.CSL}
+	legacy.Properties.Options = msg.Properties.options()
+	legacy.Properties.Parameters = msg.Properties.Parameters
+	legacy.Properties.Application = msg.Properties.Application
+	legacy.Properties.User = msg.Properties.User
+	legacy.Properties.ClientRequestID = msg.Properties.ClientRequestID
+	return json.Marshal(legacy)
+}
+
+func TestQueryMsgCompatibility(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 3, 26, 1, 30, 0, 123456789, time.UTC)
+	params := NewStmt("T | where Name == name and Count > count").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}, "count": ParamType{Type: types.Long}}),
+	).MustParameters(NewParameters().Must(QueryValues{"name": "a \"quoted\"\n<name>", "count": int64(3)}))
+
+	tests := []struct {
+		desc    string
+		ctx     context.Context
+		stmt    Stmt
+		options []QueryOption
+	}{
+		{
+			desc: "Defaults",
+			ctx:  context.Background(),
+			stmt: NewStmt("T"),
+		},
+		{
+			desc: "Progressive disabled",
+			ctx:  context.Background(),
+			stmt: NewStmt("T"),
+			options: []QueryOption{
+				ResultsProgressiveDisable(),
+			},
+		},
+		{
+			desc: "Common options",
+			ctx:  context.Background(),
+			stmt: NewStmt("T"),
+			options: []QueryOption{
+				NoRequestTimeout(), NoTruncation(), RequestReadonly(), Application("app"), User("user"), ClientRequestID("KGC.execute;1234"),
+			},
+		},
+		{
+			desc: "Server timeout",
+			ctx:  timeoutCtx(t),
+			stmt: NewStmt("T"),
+		},
+		{
+			desc: "Extra options",
+			ctx:  timeoutCtx(t),
+			stmt: NewStmt("T"),
+			options: []QueryOption{
+				NoTruncation(),
+				MaxMemoryConsumptionPerQueryPerNode(1 << 40),
+				QueryCursorScopedTables([]string{"a", "b"}),
+				QueryDataScope(DSHotCache),
+				QueryDateTimeScopeFrom(now),
+				QueryNow(now.In(time.FixedZone("UTC+2", 2*60*60))),
+				QueryResultsCacheMaxAge(90 * time.Minute),
+				QueryResultsProgressiveUpdatePeriod(5),
+				QueryTakeMaxRecords(10),
+				RequestDescription("tab\tand \u2028 line separator"),
+				CustomQueryOption("custom", map[string]interface{}{"a": []int{1, 2}, "b": nil}),
+				CustomQueryOption("float", 1.5),
+			},
+		},
+		{
+			desc: "Typed options set with other types",
+			ctx:  context.Background(),
+			stmt: NewStmt("T"),
+			options: []QueryOption{
+				CustomQueryOption(NoTruncationValue, "true"),
+				CustomQueryOption(ServerTimeoutValue, 42),
+				CustomQueryOption(RequestReadonlyValue, nil),
+			},
+		},
+		{
+			desc: "Parameters",
+			ctx:  context.Background(),
+			stmt: params,
+		},
+		{
+			desc: "Invalid UTF-8 and control characters",
+			ctx:  context.Background(),
+			stmt: NewStmt("T"),
+			options: []QueryOption{
+				Application("bad \xff\xfe utf8"),
+				RequestDescription("\x00\x01\x1f\x7f \\ / & < >"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := setQueryOptions(test.ctx, errors.OpQuery, test.stmt, nil, test.options...)
+			require.NoError(t, err)
+			msg := queryMsg{DB: "db\"name", CSL: test.stmt.String(), Properties: *opts.requestProperties}
+
+			got, err := msg.appendJSON(nil)
+			require.NoError(t, err)
+			want, err := legacyEncode(msg)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, string(want), string(got))
+		})
+	}
+}
+
+func timeoutCtx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestAppendJSONString(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		"",
+		"plain",
+		`"quotes" and \backslashes\`,
+		"\n\r\t\b\f\x00\x1f\x7f",
+		"<html> & 'quotes'",
+		"\u2028\u2029",
+		"bad \xff utf8 \xe2\x82",
+		"emoji 😀 and accents é",
+	}
+
+	for _, test := range tests {
+		got := appendJSONString(nil, test)
+		require.True(t, json.Valid(got), "%q encoded as invalid JSON %s", test, got)
+
+		want, err := json.Marshal(test)
+		require.NoError(t, err)
+		var gotS, wantS string
+		require.NoError(t, json.Unmarshal(got, &gotS))
+		require.NoError(t, json.Unmarshal(want, &wantS))
+		assert.Equal(t, wantS, gotS, "%q", test)
+	}
+}
+
+func TestRequestPropertiesOptions(t *testing.T) {
+	t.Parallel()
+
+	r := &requestProperties{}
+	r.setOption(NoTruncationValue, true)
+	r.setOption(ServerTimeoutValue, "00:01:00")
+	r.setOption(QueryTakeMaxRecordsValue, int64(3))
+	assert.Empty(t, r.Options[NoTruncationValue], "typed options are not stored in the map")
+	assert.Equal(t, map[string]interface{}{NoTruncationValue: true, ServerTimeoutValue: "00:01:00", QueryTakeMaxRecordsValue: int64(3)}, r.options())
+
+	// A typed option set with another type moves to the map, and back.
+	r.setOption(NoTruncationValue, "yes")
+	v, ok := r.option(NoTruncationValue)
+	assert.True(t, ok)
+	assert.Equal(t, "yes", v)
+	assert.Equal(t, optUnset, r.typed.noTruncation)
+	r.setOption(NoTruncationValue, false)
+	v, ok = r.option(NoTruncationValue)
+	assert.True(t, ok)
+	assert.Equal(t, false, v)
+	assert.NotContains(t, r.Options, NoTruncationValue)
+
+	r.deleteOption(NoTruncationValue)
+	r.deleteOption(ServerTimeoutValue)
+	r.deleteOption(QueryTakeMaxRecordsValue)
+	_, ok = r.option(NoTruncationValue)
+	assert.False(t, ok)
+	assert.Empty(t, r.options())
+}
+
+func TestRequestPropertiesTimespanOptions(t *testing.T) {
+	t.Parallel()
+
+	r := &requestProperties{}
+	r.setOption(ServerTimeoutValue, 90*time.Minute)
+	r.setOption(QueryResultsCacheMaxAgeValue, value.NewTimespan(-(26*time.Hour + 5*time.Millisecond)))
+	r.setOption("custom_span", value.NullTimespan())
+	assert.Equal(t, map[string]interface{}{
+		ServerTimeoutValue:           "01:30:00",
+		QueryResultsCacheMaxAgeValue: "-1.02:00:00.005",
+	}, r.options())
+
+	b, err := appendJSONValue(nil, 30*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, `"00:30:00"`, string(b))
+}
+
+/*
+BenchmarkQueryMsg/encoding/json         	  343250	      3467 ns/op	    1011 B/op	      18 allocs/op
+BenchmarkQueryMsg/appendJSON            	 3434275	       349 ns/op	       0 B/op	       0 allocs/op
+*/
+func BenchmarkQueryMsg(b *testing.B) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	stmt := NewStmt("T | where Name == name").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}),
+	).MustParameters(NewParameters().Must(QueryValues{"name": "a"}))
+	opts, err := setQueryOptions(ctx, errors.OpQuery, stmt, nil, NoTruncation(), RequestReadonly(), Application("app"), ClientRequestID("id"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := queryMsg{DB: "db", CSL: stmt.String(), Properties: *opts.requestProperties}
+
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := legacyEncode(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("appendJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, 1024)
+		for i := 0; i < b.N; i++ {
+			var err error
+			if buf, err = msg.appendJSON(buf[:0]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package kusto
+
+// resolve.go implements ResolveQueryOptions() and ResolveMgmtOptions(), which show the request properties a call
+// would send, and RowIterator.RequestProperties(), which shows the ones that were sent.
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// ResolvedProperties are the request properties sent with a call, once its options were applied.
+// They hold no credentials: the authorization of the call is sent in a header, which is not part of them.
+type ResolvedProperties struct {
+	// Options are the request options, such as "servertimeout" or "results_progressive_enabled", with the values
+	// that are sent.
+	Options map[string]interface{}
+	// Parameters are the query parameters of the Stmt, with their values as they are sent.
+	Parameters map[string]string
+	// Application is sent in the x-ms-app header, see Application(). It is empty if the client sends its default.
+	Application string
+	// User is sent in the x-ms-user header, see User(). It is empty if the client sends its default.
+	User string
+	// ClientRequestID is sent in the x-ms-client-request-id header, see ClientRequestID(). It is empty if the
+	// client generates it when the call is made.
+	ClientRequestID string
+	// ServerTimeout is the value of the servertimeout option, whether it was set by ServerTimeout() or derived from
+	// the deadline of the context. It is zero if the option is not set or is not a timespan.
+	ServerTimeout time.Duration
+}
+
+// ResolveQueryOptions returns the request properties that Client.Query() would send for query with the options,
+// without sending anything. The options carried by ctx, see ContextWithQueryOptions(), are applied, and the
+// servertimeout is derived from the deadline of ctx, or from the default deadline of Query() if it has none.
+// The options of the Client, such as WithReadOnlyClient(), WithTimeoutHeadroom() or WithStatementInterceptor(),
+// are not applied, as there is no Client: see RowIterator.RequestProperties() for the properties that were sent.
+func ResolveQueryOptions(ctx context.Context, query Stmt, options ...QueryOption) (ResolvedProperties, error) {
+	ctx, cancel, err := contextSetup(ctx, false)
+	if err != nil {
+		return ResolvedProperties{}, err
+	}
+	defer cancel()
+
+	opts, err := setQueryOptions(ctx, errors.OpQuery, query, nil, options...)
+	if err != nil {
+		return ResolvedProperties{}, err
+	}
+	return newResolvedProperties(opts.requestProperties), nil
+}
+
+// ResolveMgmtOptions returns the request properties that Client.Mgmt() would send for query with the options,
+// without sending anything. It is the Mgmt() counterpart of ResolveQueryOptions().
+func ResolveMgmtOptions(ctx context.Context, query Stmt, options ...MgmtOption) (ResolvedProperties, error) {
+	ctx, cancel, err := contextSetup(ctx, true)
+	if err != nil {
+		return ResolvedProperties{}, err
+	}
+	defer cancel()
+
+	opts, err := setMgmtOptions(ctx, errors.OpMgmt, query, options...)
+	if err != nil {
+		return ResolvedProperties{}, err
+	}
+	// Mgmt() calls are never progressive, see Client.getConn().
+	opts.requestProperties.deleteOption(resultsProgressiveEnabledValue)
+	return newResolvedProperties(opts.requestProperties), nil
+}
+
+// RequestProperties returns the request properties sent with the call that returned the RowIterator. They are zero
+// for a RowIterator that was not returned by a Client, such as one with MockRows.
+func (r *RowIterator) RequestProperties() ResolvedProperties {
+	return r.requestProperties.clone()
+}
+
+// newResolvedProperties returns a copy of props.
+func newResolvedProperties(props *requestProperties) ResolvedProperties {
+	resolved := ResolvedProperties{
+		Options:         props.options(),
+		Application:     props.Application,
+		User:            props.User,
+		ClientRequestID: props.ClientRequestID,
+	}
+	if props.Parameters != nil {
+		resolved.Parameters = make(map[string]string, len(props.Parameters))
+		for k, v := range props.Parameters {
+			resolved.Parameters[k] = v
+		}
+	}
+	if s, ok := resolved.Options[ServerTimeoutValue].(string); ok {
+		var ts value.Timespan
+		if err := ts.Unmarshal(s); err == nil {
+			resolved.ServerTimeout = ts.Value
+		}
+	}
+	return resolved
+}
+
+// clone returns a copy of r that shares none of its maps.
+func (r ResolvedProperties) clone() ResolvedProperties {
+	c := r
+	if r.Options != nil {
+		c.Options = make(map[string]interface{}, len(r.Options))
+		for k, v := range r.Options {
+			c.Options[k] = v
+		}
+	}
+	if r.Parameters != nil {
+		c.Parameters = make(map[string]string, len(r.Parameters))
+		for k, v := range r.Parameters {
+			c.Parameters[k] = v
+		}
+	}
+	return c
+}
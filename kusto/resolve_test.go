@@ -0,0 +1,164 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveQueryOptions(t *testing.T) {
+	t.Parallel()
+
+	stmt := NewStmt("T | where Name == name").MustDefinitions(
+		NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}),
+	).MustParameters(NewParameters().Must(QueryValues{"name": "a"}))
+
+	tests := []struct {
+		desc    string
+		ctx     func() (context.Context, context.CancelFunc)
+		stmt    Stmt
+		options []QueryOption
+		want    ResolvedProperties
+		// wantTimeout is compared to ServerTimeout to the second, and is also the servertimeout option.
+		wantTimeout time.Duration
+		err         bool
+	}{
+		{
+			desc:        "Defaults",
+			stmt:        NewStmt("T"),
+			want:        ResolvedProperties{Options: map[string]interface{}{resultsProgressiveEnabledValue: true}},
+			wantTimeout: 4*time.Minute - DefaultTimeoutHeadroom,
+		},
+		{
+			desc: "Options and parameters",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ContextWithQueryOptions(context.Background(), Application("ctx-app"), NoTruncation()), time.Minute)
+			},
+			stmt:    stmt,
+			options: []QueryOption{Application("app"), User("user"), ClientRequestID("id"), ResultsProgressiveDisable(), QueryTakeMaxRecords(3)},
+			want: ResolvedProperties{
+				Options: map[string]interface{}{
+					NoTruncationValue:        true,
+					QueryTakeMaxRecordsValue: int64(3),
+				},
+				Parameters:      map[string]string{"name": "a"},
+				Application:     "app",
+				User:            "user",
+				ClientRequestID: "id",
+			},
+			wantTimeout: time.Minute - DefaultTimeoutHeadroom,
+		},
+		{
+			desc:        "ServerTimeout",
+			stmt:        NewStmt("T"),
+			options:     []QueryOption{ServerTimeout(90 * time.Second)},
+			want:        ResolvedProperties{Options: map[string]interface{}{resultsProgressiveEnabledValue: true}},
+			wantTimeout: 90 * time.Second,
+		},
+		{
+			desc:    "Invalid option",
+			stmt:    NewStmt("T"),
+			options: []QueryOption{ServerTimeout(2 * time.Hour)},
+			err:     true,
+		},
+		{
+			desc: "Deadline too far",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 2*time.Hour)
+			},
+			stmt: NewStmt("T"),
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if test.ctx != nil {
+				var cancel context.CancelFunc
+				ctx, cancel = test.ctx()
+				defer cancel()
+			}
+
+			got, err := ResolveQueryOptions(ctx, test.stmt, test.options...)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.InDelta(t, test.wantTimeout, got.ServerTimeout, float64(2*time.Second))
+			assert.IsType(t, "", got.Options[ServerTimeoutValue])
+			delete(got.Options, ServerTimeoutValue)
+			got.ServerTimeout = 0
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestResolveMgmtOptions(t *testing.T) {
+	t.Parallel()
+
+	got, err := ResolveMgmtOptions(context.Background(), NewStmt(".show tables"), IngestionEndpoint())
+	require.NoError(t, err)
+	assert.InDelta(t, 10*time.Minute-DefaultTimeoutHeadroom, got.ServerTimeout, float64(2*time.Second))
+	_, progressive := got.Options[resultsProgressiveEnabledValue]
+	assert.False(t, progressive)
+	assert.Len(t, got.Options, 1)
+
+	stmt := NewStmt(".show table T").MustDefinitions(NewDefinitions().Must(ParamTypes{"T": ParamType{Type: types.String}})).
+		MustParameters(NewParameters().Must(QueryValues{"T": "Events"}))
+	got, err = ResolveMgmtOptions(context.Background(), stmt)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"T": "Events"}, got.Parameters)
+
+	// The ingestion endpoint does not run queries.
+	_, err = ResolveMgmtOptions(context.Background(), stmt, IngestionEndpoint())
+	assert.Error(t, err)
+}
+
+func TestRowIteratorRequestProperties(t *testing.T) {
+	t.Parallel()
+
+	const secret = "secret-token"
+	transport := &recordTransport{}
+	auth := Authorization{TokenProvider: &TokenProvider{customToken: secret, tokenScheme: "Bearer"}}
+	client := newAuthTestClient(t, "https://resolve.kusto.windows.net", auth, transport)
+	WithReadOnlyClient()(client)
+	WithTimeoutHeadroom(time.Minute)(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	iter, err := client.Query(ctx, "db", NewStmt("T"), NoTruncation())
+	require.NoError(t, err)
+	defer iter.Stop()
+
+	// The options of the Client are part of the properties that were sent.
+	got := iter.RequestProperties()
+	assert.Equal(t, true, got.Options[RequestReadonlyValue])
+	assert.Equal(t, true, got.Options[NoTruncationValue])
+	assert.InDelta(t, 9*time.Minute, got.ServerTimeout, float64(2*time.Second))
+
+	sent := transport.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, sent[0].Properties.options(), got.Options)
+
+	// The credentials are never part of them.
+	assert.NotContains(t, fmt.Sprintf("%#v", got), secret)
+	assert.True(t, strings.Contains(iter.RequestHeader.Get("Authorization"), secret), "the token was not sent")
+
+	// The properties returned are a copy.
+	got.Options[NoTruncationValue] = false
+	assert.Equal(t, true, iter.RequestProperties().Options[NoTruncationValue])
+
+	assert.Equal(t, ResolvedProperties{}, (&RowIterator{}).RequestProperties())
+}
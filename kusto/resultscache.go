@@ -0,0 +1,360 @@
+package kusto
+
+// resultscache.go implements WithClientResultsCache(), an in-memory cache of the results of the queries marked with
+// Cacheable(), for reference data that is queried over and over.
+
+import (
+	"container/list"
+	"context"
+	goErrors "errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+)
+
+// WithClientResultsCache keeps the results of the queries made with the Cacheable() option in memory for ttl, so that
+// identical queries are answered without a request to the service. Queries are identical when they have the same
+// database, statement, parameters and options. The cache holds up to maxBytes of results, as estimated from the
+// size of their values, evicting the least recently used results first. Results larger than maxBytes, or with
+// inline errors, are not cached.
+// When identical queries miss the cache concurrently, a single request is sent and the others wait for its results.
+// Management commands are never cached. See Client.ResultsCacheStats() for the hits and misses, and WithQueryCache()
+// for a cache of the responses in a store the application provides, such as one shared by several processes.
+func WithClientResultsCache(maxBytes int64, ttl time.Duration) Option {
+	return func(c *Client) {
+		if maxBytes <= 0 || ttl <= 0 {
+			return
+		}
+		c.resultsCache = newResultsCache(maxBytes, ttl)
+	}
+}
+
+// Cacheable allows the results of the query to be served from, and stored in, the cache set up by
+// WithClientResultsCache(). It has no effect on clients without a cache. The RowIterator of a cacheable query is
+// materialized, see RowIterator.Materialize(), so only use it for results that fit comfortably in memory.
+func Cacheable() QueryOption {
+	return func(q *queryOptions) error {
+		q.cacheable = true
+		return nil
+	}
+}
+
+// ResultsCacheStats reports the activity of the cache set up by WithClientResultsCache().
+type ResultsCacheStats struct {
+	// Hits is the number of queries answered from the cache, including the ones that waited for an identical query.
+	Hits int64
+	// Misses is the number of queries sent to the service to fill the cache.
+	Misses int64
+	// Evictions is the number of results removed from the cache to make room for others, or because they expired.
+	Evictions int64
+	// Entries is the number of results in the cache.
+	Entries int64
+	// Bytes is the estimated size of the results in the cache.
+	Bytes int64
+}
+
+// ResultsCacheStats returns the activity of the results cache of the client, which is zero if it has none.
+func (c *Client) ResultsCacheStats() ResultsCacheStats {
+	if c.resultsCache == nil {
+		return ResultsCacheStats{}
+	}
+	return c.resultsCache.stats()
+}
+
+// resultsCache holds the results of cacheable queries. mu guards everything but the counters.
+type resultsCache struct {
+	maxBytes int64
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the *cacheEntry, the most recently used first.
+	lru   *list.List
+	bytes int64
+	fills map[string]*cacheFill
+
+	hits, misses, evictions atomic.Int64
+}
+
+type cacheEntry struct {
+	key     string
+	result  *cachedResult
+	expires time.Time
+}
+
+// cacheFill is a query in flight whose results are awaited by identical queries.
+type cacheFill struct {
+	done   chan struct{}
+	result *cachedResult
+	err    error
+}
+
+// cachedResult is the materialized result of a query. It is never modified once created.
+type cachedResult struct {
+	columns     table.Columns
+	rows        []bufferedRow
+	count       int64
+	nonPrimary  map[frames.TableKind]frames.DataTable
+	allTables   []frames.DataTable
+	warnings    []Warning
+	progressive bool
+	respHeader  http.Header
+	inlineErrs  bool
+	size        int64
+}
+
+func newResultsCache(maxBytes int64, ttl time.Duration) *resultsCache {
+	return &resultsCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		fills:    map[string]*cacheFill{},
+	}
+}
+
+func (rc *resultsCache) stats() ResultsCacheStats {
+	rc.mu.Lock()
+	entries, bytes := int64(rc.lru.Len()), rc.bytes
+	rc.mu.Unlock()
+
+	return ResultsCacheStats{
+		Hits:      rc.hits.Load(),
+		Misses:    rc.misses.Load(),
+		Evictions: rc.evictions.Load(),
+		Entries:   entries,
+		Bytes:     bytes,
+	}
+}
+
+// query answers a cacheable query from the cache, from an identical query in flight, or by sending it with
+// client.runQuery() and caching its results.
+func (rc *resultsCache) query(ctx context.Context, cancel context.CancelFunc, client *Client, db string, query Stmt, opts *queryOptions) (*RowIterator, error) {
+	key, ok := dedupKey(db, query, opts)
+	if !ok || isCommand(query.String()) {
+		return client.runQuery(ctx, cancel, db, query, opts)
+	}
+
+	for {
+		rc.mu.Lock()
+		if result := rc.lookup(key); result != nil {
+			rc.mu.Unlock()
+			rc.hits.Add(1)
+			return result.iterator(ctx, cancel, opts), nil
+		}
+
+		if fill, ok := rc.fills[key]; ok {
+			rc.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				cancel()
+				return nil, ctx.Err()
+			case <-fill.done:
+			}
+			if fill.err == nil {
+				rc.hits.Add(1)
+				return fill.result.iterator(ctx, cancel, opts), nil
+			}
+			// The caller that sent the query gave up, which says nothing of the query: send it again.
+			if goErrors.Is(fill.err, context.Canceled) || goErrors.Is(fill.err, context.DeadlineExceeded) {
+				continue
+			}
+			cancel()
+			return nil, fill.err
+		}
+
+		fill := &cacheFill{done: make(chan struct{})}
+		rc.fills[key] = fill
+		rc.mu.Unlock()
+		rc.misses.Add(1)
+
+		iter, err := rc.fill(ctx, cancel, client, db, query, opts, fill)
+
+		rc.mu.Lock()
+		delete(rc.fills, key)
+		if err == nil && !fill.result.inlineErrs {
+			rc.add(key, fill.result)
+		}
+		rc.mu.Unlock()
+		close(fill.done)
+
+		return iter, err
+	}
+}
+
+// fill sends the query and materializes its results into fill.
+func (rc *resultsCache) fill(ctx context.Context, cancel context.CancelFunc, client *Client, db string, query Stmt, opts *queryOptions, fill *cacheFill) (*RowIterator, error) {
+	iter, err := client.runQuery(ctx, cancel, db, query, opts)
+	if err != nil {
+		fill.err = err
+		return nil, err
+	}
+	if err := iter.Materialize(); err != nil {
+		iter.Stop()
+		fill.err = err
+		return nil, err
+	}
+	fill.result = newCachedResult(iter)
+	return iter, nil
+}
+
+// lookup returns the unexpired result of key, or nil. It must be called with mu held.
+func (rc *resultsCache) lookup(key string) *cachedResult {
+	elem, ok := rc.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !rc.now().Before(entry.expires) {
+		rc.remove(elem)
+		return nil
+	}
+	rc.lru.MoveToFront(elem)
+	return entry.result
+}
+
+// add caches the result of key, evicting the least recently used results to make room. It must be called with mu
+// held.
+func (rc *resultsCache) add(key string, result *cachedResult) {
+	if result.size > rc.maxBytes {
+		return
+	}
+	if elem, ok := rc.entries[key]; ok {
+		rc.remove(elem)
+	}
+	for rc.bytes+result.size > rc.maxBytes {
+		rc.remove(rc.lru.Back())
+	}
+	rc.entries[key] = rc.lru.PushFront(&cacheEntry{key: key, result: result, expires: rc.now().Add(rc.ttl)})
+	rc.bytes += result.size
+}
+
+// remove evicts a result. It must be called with mu held.
+func (rc *resultsCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	rc.lru.Remove(elem)
+	delete(rc.entries, entry.key)
+	rc.bytes -= entry.result.size
+	rc.evictions.Add(1)
+}
+
+// newCachedResult copies the results of a materialized RowIterator. The datetime values are stored in UTC, as the
+// callers sharing the result may have different PreserveLocation() options.
+func newCachedResult(iter *RowIterator) *cachedResult {
+	iter.mu.Lock()
+	nonPrimary := make(map[frames.TableKind]frames.DataTable, len(iter.nonPrimary))
+	for k, v := range iter.nonPrimary {
+		nonPrimary[k] = v
+	}
+	allTables := append([]frames.DataTable(nil), iter.nonPrimaryTables...)
+	warnings := append([]Warning(nil), iter.warnings...)
+	iter.mu.Unlock()
+
+	result := &cachedResult{
+		columns:     iter.columns,
+		rows:        make([]bufferedRow, len(iter.buffered.rows)),
+		count:       iter.buffered.count,
+		nonPrimary:  nonPrimary,
+		allTables:   allTables,
+		warnings:    warnings,
+		progressive: iter.progressive,
+		respHeader:  iter.ResponseHeader.Clone(),
+	}
+	for i, b := range iter.buffered.rows {
+		if b.table != nil {
+			result.rows[i] = b
+			continue
+		}
+		if b.inlineErr != nil {
+			result.inlineErrs = true
+			result.rows[i] = b
+			continue
+		}
+		row := *b.row
+		row.Values = localizeValues(append(value.Values(nil), b.row.Values...), time.UTC)
+		result.rows[i] = bufferedRow{row: &row}
+		result.size += valuesSize(row.Values)
+	}
+	for _, dt := range nonPrimary {
+		for _, values := range dt.KustoRows {
+			result.size += valuesSize(values)
+		}
+	}
+	return result
+}
+
+// iterator returns a RowIterator replaying a copy of the result.
+func (c *cachedResult) iterator(ctx context.Context, cancel context.CancelFunc, opts *queryOptions) *RowIterator {
+	loc := opts.location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	b := &bufferedRows{rows: make([]bufferedRow, len(c.rows)), count: c.count}
+	for i, r := range c.rows {
+		if r.inlineErr != nil || r.table != nil {
+			b.rows[i] = r
+			continue
+		}
+		row := *r.row
+		row.Values = localizeValues(append(value.Values(nil), r.row.Values...), loc)
+		b.rows[i] = bufferedRow{row: &row}
+	}
+
+	return &RowIterator{
+		op:                 errors.OpQuery,
+		ctx:                ctx,
+		cancel:             cancel,
+		ResponseHeader:     c.respHeader.Clone(),
+		progressive:        c.progressive,
+		primaryResultsOnly: opts.primaryResultsOnly,
+		location:           opts.location,
+		requestProperties:  newResolvedProperties(opts.requestProperties),
+		nonPrimary:         c.nonPrimary,
+		nonPrimaryTables:   c.allTables,
+		warnings:           append([]Warning(nil), c.warnings...),
+		columns:            c.columns,
+		started:            true,
+		buffered:           b,
+	}
+}
+
+// localizeValues converts the datetime values in values to loc.
+func localizeValues(values value.Values, loc *time.Location) value.Values {
+	for i, v := range values {
+		if dt, ok := v.(value.DateTime); ok && dt.Valid {
+			dt.Value = dt.Value.In(loc)
+			values[i] = dt
+		}
+	}
+	return values
+}
+
+// valuesSize estimates the memory used by values.
+func valuesSize(values value.Values) int64 {
+	// The slice header, the interface of each value and the *table.Row pointing to it.
+	size := int64(24 + 16*len(values) + 64)
+	for _, v := range values {
+		switch v := v.(type) {
+		case value.String:
+			size += int64(24 + len(v.Value))
+		case value.Decimal:
+			size += int64(24 + len(v.Value))
+		case value.Dynamic:
+			size += int64(32 + len(v.Value))
+		case value.DateTime:
+			size += 32
+		default:
+			size += 24
+		}
+	}
+	return size
+}
@@ -0,0 +1,336 @@
+package kusto
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The estimated size of the result of dedupTestStream.
+const dedupTestStreamSize = 3 * (24 + 16 + 64 + 24)
+
+func newResultsCacheTestClient(t *testing.T, transport *dedupTransport, maxBytes int64, ttl time.Duration) *Client {
+	client := newTestClient(t, "https://cache.kusto.windows.net", transport)
+	WithClientResultsCache(maxBytes, ttl)(client)
+	return client
+}
+
+func cachedTestRows(t *testing.T, iter *RowIterator) []value.Values {
+	defer iter.Stop()
+
+	var got []value.Values
+	require.NoError(t, iter.Do(func(row *table.Row) error {
+		got = append(got, row.Values)
+		return nil
+	}))
+	return got
+}
+
+func TestResultsCache(t *testing.T) {
+	t.Parallel()
+
+	want := []value.Values{
+		{value.Long{Value: 1, Valid: true}},
+		{value.Long{Value: 2, Valid: true}},
+		{value.Long{Value: 3, Valid: true}},
+	}
+
+	withName := func(name string) Stmt {
+		return NewStmt("T | where Name == name").MustDefinitions(
+			NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}),
+		).MustParameters(NewParameters().Must(QueryValues{"name": name}))
+	}
+
+	tests := []struct {
+		desc          string
+		first, second Stmt
+		firstOptions  []QueryOption
+		secondOptions []QueryOption
+		secondDB      string
+		requests      int64
+		stats         ResultsCacheStats
+	}{
+		{
+			desc:          "Cached",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			firstOptions:  []QueryOption{Cacheable()},
+			secondOptions: []QueryOption{Cacheable()},
+			requests:      1,
+			stats:         ResultsCacheStats{Hits: 1, Misses: 1, Entries: 1, Bytes: dedupTestStreamSize},
+		},
+		{
+			desc:     "Not cacheable",
+			first:    NewStmt("T"),
+			second:   NewStmt("T"),
+			requests: 2,
+		},
+		{
+			desc:          "Only the second is cacheable",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			secondOptions: []QueryOption{Cacheable()},
+			requests:      2,
+			stats:         ResultsCacheStats{Misses: 1, Entries: 1, Bytes: dedupTestStreamSize},
+		},
+		{
+			desc:          "Different options",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			firstOptions:  []QueryOption{Cacheable()},
+			secondOptions: []QueryOption{Cacheable(), NoTruncation()},
+			requests:      2,
+			stats:         ResultsCacheStats{Misses: 2, Entries: 2, Bytes: 2 * dedupTestStreamSize},
+		},
+		{
+			desc:          "Same parameters",
+			first:         withName("a"),
+			second:        withName("a"),
+			firstOptions:  []QueryOption{Cacheable()},
+			secondOptions: []QueryOption{Cacheable()},
+			requests:      1,
+			stats:         ResultsCacheStats{Hits: 1, Misses: 1, Entries: 1, Bytes: dedupTestStreamSize},
+		},
+		{
+			desc:          "Different parameters",
+			first:         withName("a"),
+			second:        withName("b"),
+			firstOptions:  []QueryOption{Cacheable()},
+			secondOptions: []QueryOption{Cacheable()},
+			requests:      2,
+			stats:         ResultsCacheStats{Misses: 2, Entries: 2, Bytes: 2 * dedupTestStreamSize},
+		},
+		{
+			desc:          "Different databases",
+			first:         NewStmt("T"),
+			second:        NewStmt("T"),
+			firstOptions:  []QueryOption{Cacheable()},
+			secondOptions: []QueryOption{Cacheable()},
+			secondDB:      "other",
+			requests:      2,
+			stats:         ResultsCacheStats{Misses: 2, Entries: 2, Bytes: 2 * dedupTestStreamSize},
+		},
+		{
+			desc:          "Commands are never cached",
+			first:         NewStmt("// A command that Query() does not reject.\n.show tables"),
+			second:        NewStmt("// A command that Query() does not reject.\n.show tables"),
+			firstOptions:  []QueryOption{Cacheable()},
+			secondOptions: []QueryOption{Cacheable()},
+			requests:      2,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			release := make(chan struct{})
+			close(release)
+			transport := &dedupTransport{release: release}
+			client := newResultsCacheTestClient(t, transport, 1<<20, time.Minute)
+
+			secondDB := "db"
+			if test.secondDB != "" {
+				secondDB = test.secondDB
+			}
+
+			iter, err := client.Query(context.Background(), "db", test.first, test.firstOptions...)
+			require.NoError(t, err)
+			assert.Equal(t, want, cachedTestRows(t, iter))
+			iter, err = client.Query(context.Background(), secondDB, test.second, test.secondOptions...)
+			require.NoError(t, err)
+			assert.Equal(t, want, cachedTestRows(t, iter))
+
+			assert.Equal(t, test.requests, transport.requests.Load())
+			assert.Equal(t, test.stats, client.ResultsCacheStats())
+		})
+	}
+}
+
+func TestResultsCacheReplay(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "datetime.json"))
+	require.NoError(t, err)
+	client := newTestClient(t, "https://cache.kusto.windows.net", fixtureTransport{body: body})
+	WithClientResultsCache(1<<20, time.Minute)(client)
+
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	first, err := client.Query(context.Background(), "db", NewStmt("T"), Cacheable(), PreserveLocation(loc))
+	require.NoError(t, err)
+	firstRows := cachedTestRows(t, first)
+	require.Len(t, firstRows, 3)
+	assert.Equal(t, loc, firstRows[0][0].(value.DateTime).Value.Location())
+
+	// The hit has the location of its own caller, and is not affected by changes made by the first caller.
+	firstRows[0][1] = value.String{Value: "changed", Valid: true}
+	second, err := client.Query(context.Background(), "db", NewStmt("T"), Cacheable())
+	require.NoError(t, err)
+	var secondRows []value.Values
+	require.NoError(t, second.Do(func(row *table.Row) error {
+		secondRows = append(secondRows, row.Values)
+		return nil
+	}))
+	require.Len(t, secondRows, 3)
+	assert.Equal(t, time.UTC, secondRows[0][0].(value.DateTime).Value.Location())
+	assert.True(t, firstRows[0][0].(value.DateTime).Value.Equal(secondRows[0][0].(value.DateTime).Value))
+	assert.Equal(t, value.String{Value: "before", Valid: true}, secondRows[0][1])
+	assert.Equal(t, int64(1), client.ResultsCacheStats().Hits)
+
+	// A hit can be rewound like any materialized RowIterator.
+	require.NoError(t, second.Rewind())
+	assert.Equal(t, secondRows, cachedTestRows(t, second))
+}
+
+func TestResultsCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	close(release)
+	transport := &dedupTransport{release: release}
+	// Room for two results.
+	client := newResultsCacheTestClient(t, transport, 2*dedupTestStreamSize+10, time.Minute)
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	client.resultsCache.now = clock.Now
+
+	stmts := map[string]Stmt{"A": NewStmt("A"), "B": NewStmt("B"), "C": NewStmt("C")}
+	query := func(table string) {
+		iter, err := client.Query(context.Background(), "db", stmts[table], Cacheable())
+		require.NoError(t, err)
+		cachedTestRows(t, iter)
+	}
+
+	query("A")
+	query("B")
+	// A is the most recently used.
+	query("A")
+	query("C")
+	assert.Equal(t, ResultsCacheStats{Hits: 1, Misses: 3, Evictions: 1, Entries: 2, Bytes: 2 * dedupTestStreamSize}, client.ResultsCacheStats())
+	assert.Equal(t, int64(3), transport.requests.Load())
+
+	// B was evicted, A was not.
+	query("A")
+	assert.Equal(t, int64(3), transport.requests.Load())
+	query("B")
+	assert.Equal(t, int64(4), transport.requests.Load())
+
+	// Results expire after the TTL.
+	clock.Advance(time.Minute)
+	query("B")
+	assert.Equal(t, int64(5), transport.requests.Load())
+	assert.Equal(t, ResultsCacheStats{Hits: 2, Misses: 5, Evictions: 3, Entries: 2, Bytes: 2 * dedupTestStreamSize}, client.ResultsCacheStats())
+}
+
+func TestResultsCacheTooLarge(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	close(release)
+	transport := &dedupTransport{release: release}
+	client := newResultsCacheTestClient(t, transport, dedupTestStreamSize-1, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		iter, err := client.Query(context.Background(), "db", NewStmt("T"), Cacheable())
+		require.NoError(t, err)
+		assert.Len(t, cachedTestRows(t, iter), 3)
+	}
+	assert.Equal(t, int64(2), transport.requests.Load())
+	assert.Equal(t, ResultsCacheStats{Misses: 2}, client.ResultsCacheStats())
+}
+
+func TestResultsCacheDisabled(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][2]int64{{0, int64(time.Minute)}, {1 << 20, 0}, {-1, -1}} {
+		client := &Client{}
+		WithClientResultsCache(args[0], time.Duration(args[1]))(client)
+		assert.Nil(t, client.resultsCache, "WithClientResultsCache(%d, %d)", args[0], args[1])
+		assert.Equal(t, ResultsCacheStats{}, client.ResultsCacheStats())
+	}
+}
+
+func TestResultsCacheSingleFlight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		fail bool
+	}{
+		{desc: "Success"},
+		{desc: "Failure", fail: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			release := make(chan struct{})
+			transport := &dedupTransport{release: release, fail: test.fail}
+			client := newResultsCacheTestClient(t, transport, 1<<20, time.Minute)
+
+			const callers = 20
+			errs := make(chan error, callers)
+			wg := sync.WaitGroup{}
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					iter, err := client.Query(context.Background(), "db", NewStmt("T"), Cacheable())
+					if err != nil {
+						errs <- err
+						return
+					}
+					defer iter.Stop()
+					n := 0
+					if err := iter.Do(func(*table.Row) error { n++; return nil }); err != nil {
+						errs <- err
+						return
+					}
+					if n != 3 {
+						errs <- fmt.Errorf("got %d rows, want 3", n)
+					}
+				}()
+			}
+
+			// Wait for the request to be sent before letting it through, so that the other callers pile up.
+			require.Eventually(t, func() bool { return transport.requests.Load() == 1 }, 5*time.Second, time.Millisecond)
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			close(errs)
+
+			var failed int
+			for err := range errs {
+				if !test.fail {
+					t.Error(err)
+				}
+				failed++
+			}
+
+			assert.Equal(t, int64(1), transport.requests.Load())
+			stats := client.ResultsCacheStats()
+			assert.Equal(t, int64(1), stats.Misses)
+			if test.fail {
+				assert.Equal(t, callers, failed)
+				assert.Equal(t, ResultsCacheStats{Misses: 1}, stats)
+				return
+			}
+			assert.Equal(t, int64(callers-1), stats.Hits)
+			assert.Equal(t, int64(1), stats.Entries)
+		})
+	}
+}
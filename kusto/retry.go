@@ -0,0 +1,110 @@
+package kusto
+
+// retry.go implements WithRetryOptions(), which retries the requests that fail with a transient HTTP error.
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+const (
+	// DefaultRetryBaseDelay is the delay before the first retry, unless WithRetryOptions() sets another one.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay is the longest delay between two attempts, unless WithRetryOptions() sets another one.
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+// retryPolicy is how the requests of a conn are retried, see WithRetryOptions().
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+
+	// wait waits for d, or returns the error of ctx if it is done first. It is replaced in tests.
+	wait func(ctx context.Context, d time.Duration) error
+}
+
+// WithRetryOptions retries a request up to maxRetries times when it fails with a transient error: a response with
+// the status 429 (Too Many Requests), 502, 503 or 504, or a failure to reach the service that errors.Retry() reports
+// as retryable. The delay before a retry is the one the service asked for in the Retry-After or x-ms-retry-after-ms
+// header of the response, if any, otherwise it doubles from baseDelay up to maxDelay, with jitter. A request is not
+// retried if the delay would end after the deadline of the context of the call, in which case the last error is
+// returned; errors.RetryAfter() returns the delay the service asked for, if any.
+// A zero or negative baseDelay or maxDelay is replaced by DefaultRetryBaseDelay or DefaultRetryMaxDelay.
+// By default, requests are not retried. This applies to Query(), QueryToJson() and Mgmt() calls, except that a Mgmt()
+// call is only retried after a 429 or 503 response, which the service sends before running the command, as a command
+// such as .append is not safe to run twice.
+func WithRetryOptions(maxRetries int, baseDelay, maxDelay time.Duration) Option {
+	return func(c *Client) {
+		if maxRetries < 0 {
+			return
+		}
+		if baseDelay <= 0 {
+			baseDelay = DefaultRetryBaseDelay
+		}
+		if maxDelay <= 0 {
+			maxDelay = DefaultRetryMaxDelay
+		}
+		if maxDelay < baseDelay {
+			maxDelay = baseDelay
+		}
+		c.retry = &retryPolicy{maxRetries: maxRetries, baseDelay: baseDelay, maxDelay: maxDelay}
+	}
+}
+
+// retryableStatus reports if a response with status to a request of execType is worth retrying.
+func retryableStatus(execType int, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		// The command may have run, see WithRetryOptions().
+		return execType != execMgmt
+	}
+	return false
+}
+
+// retryAfter returns the delay before retry number attempt, which starts at 1, of a request that failed with err,
+// which is the time the service asked to wait, if any, see errors.Error.RetryAfter().
+func (p *retryPolicy) retryAfter(attempt int, err *errors.Error) time.Duration {
+	if d, ok := err.RetryAfter(); ok {
+		return d
+	}
+
+	d := p.maxDelay
+	if shift := attempt - 1; shift < 62 && p.baseDelay<<shift > 0 && p.baseDelay<<shift < p.maxDelay {
+		d = p.baseDelay << shift
+	}
+	// Half of the delay is random, so that the clients throttled at the same time do not retry at the same time.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// shouldRetry reports if retry number attempt, which starts at 1, is to be made after waiting for delay.
+func (p *retryPolicy) shouldRetry(ctx context.Context, attempt int, delay time.Duration) bool {
+	if p == nil || attempt > p.maxRetries || ctx.Err() != nil {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && nower().Add(delay).After(deadline) {
+		return false
+	}
+	return true
+}
+
+// sleep waits for d, or returns the error of ctx if it is done first.
+func (p *retryPolicy) sleep(ctx context.Context, d time.Duration) error {
+	if p.wait != nil {
+		return p.wait(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,344 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// retryResponse is a response of a retryTransport. A zero status makes the transport fail.
+type retryResponse struct {
+	status       int
+	retryAfter   string
+	retryAfterMs string
+	permanent    bool
+}
+
+// retryTransport is a fake http.RoundTripper that answers with responses in turn, then with 200.
+type retryTransport struct {
+	responses []retryResponse
+
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (r *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") || strings.HasSuffix(req.URL.Path, "/auth/metadata") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	n := len(r.bodies)
+	r.bodies = append(r.bodies, string(body))
+	r.mu.Unlock()
+
+	resp := retryResponse{status: http.StatusOK}
+	if n < len(r.responses) {
+		resp = r.responses[n]
+	}
+	if resp.status == 0 {
+		return nil, fmt.Errorf("connection reset by peer")
+	}
+
+	header := http.Header{}
+	if resp.retryAfter != "" {
+		header.Set("Retry-After", resp.retryAfter)
+	}
+	if resp.retryAfterMs != "" {
+		header.Set("x-ms-retry-after-ms", resp.retryAfterMs)
+	}
+	if resp.status != http.StatusOK {
+		msg := `{"error":{"code":"ServiceUnavailable","message":"Try again later","@permanent":false}}`
+		if resp.permanent {
+			msg = `{"error":{"code":"ServiceUnavailable","message":"Never going to work","@permanent":true}}`
+		}
+		return &http.Response{StatusCode: resp.status, Status: http.StatusText(resp.status), Header: header, Body: io.NopCloser(strings.NewReader(msg))}, nil
+	}
+
+	frames := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	if strings.HasSuffix(req.URL.Path, "/v1/rest/mgmt") {
+		frames = `{"Tables":[{"TableName":"Table_0","Columns":[{"ColumnName":"x","DataType":"Int64"}],"Rows":[]}]}`
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: header, Body: io.NopCloser(strings.NewReader(frames))}, nil
+}
+
+func (r *retryTransport) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	const base = 10 * time.Millisecond
+
+	query := func(ctx context.Context, client *Client) error {
+		iter, err := client.Query(ctx, "db", NewStmt("T"))
+		if err != nil {
+			return err
+		}
+		iter.Stop()
+		return nil
+	}
+	mgmt := func(ctx context.Context, client *Client) error {
+		iter, err := client.Mgmt(ctx, "db", NewStmt(".show tables"))
+		if err != nil {
+			return err
+		}
+		iter.Stop()
+		return nil
+	}
+
+	tests := []struct {
+		desc       string
+		noPolicy   bool
+		responses  []retryResponse
+		call       func(ctx context.Context, client *Client) error
+		deadline   time.Duration
+		wantSent   int
+		wantStatus int
+		// wantRetryAfter is the delay the service asked for in the returned error, if any.
+		wantRetryAfter time.Duration
+		// wantWaits are the ranges of the delays waited for.
+		wantWaits [][2]time.Duration
+	}{
+		{
+			desc:      "Success",
+			call:      query,
+			wantSent:  1,
+			wantWaits: nil,
+		},
+		{
+			desc:      "Retried until success with backoff",
+			responses: []retryResponse{{status: 503}, {status: 502}, {status: 504}},
+			call:      query,
+			wantSent:  4,
+			wantWaits: [][2]time.Duration{{base / 2, base}, {base, 2 * base}, {2 * base, 4 * base}},
+		},
+		{
+			desc:      "Retry-After is honored",
+			responses: []retryResponse{{status: 429, retryAfter: "2"}},
+			call:      query,
+			wantSent:  2,
+			wantWaits: [][2]time.Duration{{2 * time.Second, 2 * time.Second}},
+		},
+		{
+			desc:      "x-ms-retry-after-ms is honored",
+			responses: []retryResponse{{status: 429, retryAfter: "2", retryAfterMs: "1500"}},
+			call:      query,
+			wantSent:  2,
+			wantWaits: [][2]time.Duration{{1500 * time.Millisecond, 1500 * time.Millisecond}},
+		},
+		{
+			desc:      "Failure to connect",
+			responses: []retryResponse{{status: 0}},
+			call:      query,
+			wantSent:  2,
+			wantWaits: [][2]time.Duration{{base / 2, base}},
+		},
+		{
+			desc:       "Too many retries",
+			responses:  []retryResponse{{status: 503}, {status: 503}, {status: 503}, {status: 503}, {status: 503}},
+			call:       query,
+			wantSent:   4,
+			wantStatus: 503,
+			wantWaits:  [][2]time.Duration{{base / 2, base}, {base, 2 * base}, {2 * base, 4 * base}},
+		},
+		{
+			desc:       "Not transient",
+			responses:  []retryResponse{{status: 400}},
+			call:       query,
+			wantSent:   1,
+			wantStatus: 400,
+		},
+		{
+			desc:       "Permanent",
+			responses:  []retryResponse{{status: 503, permanent: true}},
+			call:       query,
+			wantSent:   1,
+			wantStatus: 503,
+		},
+		{
+			desc:           "Retry-After past the deadline",
+			responses:      []retryResponse{{status: 429, retryAfter: "60"}},
+			call:           query,
+			deadline:       10 * time.Second,
+			wantSent:       1,
+			wantStatus:     429,
+			wantRetryAfter: time.Minute,
+		},
+		{
+			desc:           "Retry-After after the last retry",
+			responses:      []retryResponse{{status: 429, retryAfterMs: "10"}, {status: 429, retryAfterMs: "20"}, {status: 429, retryAfterMs: "30"}, {status: 429, retryAfterMs: "40"}},
+			call:           query,
+			wantSent:       4,
+			wantStatus:     429,
+			wantRetryAfter: 40 * time.Millisecond,
+			wantWaits:      [][2]time.Duration{{10 * time.Millisecond, 10 * time.Millisecond}, {20 * time.Millisecond, 20 * time.Millisecond}, {30 * time.Millisecond, 30 * time.Millisecond}},
+		},
+		{
+			desc:       "No policy",
+			noPolicy:   true,
+			responses:  []retryResponse{{status: 503}},
+			call:       query,
+			wantSent:   1,
+			wantStatus: 503,
+		},
+		{
+			desc:      "Mgmt is retried after a 503",
+			responses: []retryResponse{{status: 503}},
+			call:      mgmt,
+			wantSent:  2,
+			wantWaits: [][2]time.Duration{{base / 2, base}},
+		},
+		{
+			desc:       "Mgmt is not retried after a 502",
+			responses:  []retryResponse{{status: 502}},
+			call:       mgmt,
+			wantSent:   1,
+			wantStatus: 502,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &retryTransport{responses: test.responses}
+			client := &Client{endpoint: "https://retry.kusto.windows.net", http: &http.Client{Transport: transport}}
+			if !test.noPolicy {
+				WithRetryOptions(3, base, time.Second)(client)
+			}
+			conn, err := newConn("https://retry.kusto.windows.net", Authorization{}, client.http, NewClientDetails("", ""))
+			require.NoError(t, err)
+			conn.retry = client.retry
+			client.conn = conn
+
+			var mu sync.Mutex
+			var waits []time.Duration
+			if conn.retry != nil {
+				conn.retry.wait = func(ctx context.Context, d time.Duration) error {
+					mu.Lock()
+					defer mu.Unlock()
+					waits = append(waits, d)
+					return nil
+				}
+			}
+
+			ctx := context.Background()
+			if test.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.deadline)
+				defer cancel()
+			}
+
+			err = test.call(ctx, client)
+			if test.wantStatus != 0 {
+				var httpErr *errors.HttpError
+				require.True(t, goErrors.As(err, &httpErr), "got %T: %v", err, err)
+				assert.Equal(t, test.wantStatus, httpErr.StatusCode)
+				d, ok := errors.RetryAfter(err)
+				assert.Equal(t, test.wantRetryAfter != 0, ok)
+				assert.Equal(t, test.wantRetryAfter, d)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// Every attempt sends the same body.
+			sent := transport.sent()
+			require.Len(t, sent, test.wantSent)
+			for _, body := range sent[1:] {
+				assert.Equal(t, sent[0], body)
+			}
+			assert.NotEmpty(t, sent[0])
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, waits, len(test.wantWaits))
+			for i, want := range test.wantWaits {
+				assert.GreaterOrEqual(t, waits[i], want[0], "wait %d", i)
+				assert.LessOrEqual(t, waits[i], want[1], "wait %d", i)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	t.Parallel()
+
+	transport := &retryTransport{responses: []retryResponse{{status: 503}, {status: 503}}}
+	client := &Client{endpoint: "https://retry.kusto.windows.net", http: &http.Client{Transport: transport}}
+	WithRetryOptions(3, time.Hour, time.Hour)(client)
+	conn, err := newConn("https://retry.kusto.windows.net", Authorization{}, client.http, NewClientDetails("", ""))
+	require.NoError(t, err)
+	conn.retry = client.retry
+	client.conn = conn
+
+	// The context is canceled while waiting to retry.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = client.Query(ctx, "db", NewStmt("T"))
+	var httpErr *errors.HttpError
+	require.True(t, goErrors.As(err, &httpErr), "got %T: %v", err, err)
+	assert.Len(t, transport.sent(), 1)
+}
+
+func TestWithRetryOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc       string
+		maxRetries int
+		base, max  time.Duration
+		want       *retryPolicy
+	}{
+		{desc: "Values", maxRetries: 2, base: time.Second, max: time.Minute, want: &retryPolicy{maxRetries: 2, baseDelay: time.Second, maxDelay: time.Minute}},
+		{desc: "Defaults", maxRetries: 2, want: &retryPolicy{maxRetries: 2, baseDelay: DefaultRetryBaseDelay, maxDelay: DefaultRetryMaxDelay}},
+		{desc: "Max below base", maxRetries: 1, base: time.Minute, max: time.Second, want: &retryPolicy{maxRetries: 1, baseDelay: time.Minute, maxDelay: time.Minute}},
+		{desc: "Negative retries", maxRetries: -1},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			client := &Client{}
+			WithRetryOptions(test.maxRetries, test.base, test.max)(client)
+			assert.Equal(t, test.want, client.retry)
+		})
+	}
+
+	// The policy is used by the connections of a new Client.
+	client, err := New(NewConnectionStringBuilder("https://retry.kusto.windows.net"), WithRetryOptions(2, time.Second, time.Minute))
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Same(t, client.retry, client.conn.(*conn).retry)
+
+	// The delay never exceeds the maximum, however many attempts were made.
+	p := &retryPolicy{maxRetries: 100, baseDelay: time.Second, maxDelay: 10 * time.Second}
+	for attempt := 1; attempt <= 100; attempt++ {
+		d := p.retryAfter(attempt, nil)
+		assert.Greater(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, 10*time.Second)
+	}
+}
@@ -0,0 +1,126 @@
+package kusto
+
+// rewind.go implements RowIterator.Materialize(), which holds the rows of a query in memory so that they can be read
+// more than once with Rewind().
+
+import (
+	"context"
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// NotMaterializedErr is returned by RowIterator.Rewind() when the rows of the RowIterator were not held in memory by
+// Materialize() or QueryBuffered().
+var NotMaterializedErr = errors.ES(errors.OpQuery, errors.KClientArgs, "Rewind() requires a RowIterator from QueryBuffered() or Materialize()").SetNoRetry()
+
+// bufferedRow is a row, an inline error or the start of a primary table held by a materialized RowIterator.
+type bufferedRow struct {
+	row       *table.Row
+	inlineErr *errors.Error
+	table     *primaryTable
+}
+
+// bufferedRows are the rows of a materialized RowIterator and the position of the next one to read.
+type bufferedRows struct {
+	rows  []bufferedRow
+	count int64
+	pos   int
+}
+
+// next returns the next row, inline error or start of a primary table, or io.EOF once all of them were read.
+func (b *bufferedRows) next(ctx context.Context) (*table.Row, *errors.Error, *primaryTable, error) {
+	if ctx.Err() != nil {
+		return nil, nil, nil, ctx.Err()
+	}
+	if b.pos >= len(b.rows) {
+		return nil, nil, nil, io.EOF
+	}
+	r := b.rows[b.pos]
+	b.pos++
+	switch {
+	case r.table != nil:
+		return nil, nil, r.table, nil
+	case r.inlineErr != nil:
+		return nil, r.inlineErr, nil, nil
+	}
+	return r.row, nil, nil, nil
+}
+
+// QueryBuffered is like Query(), but reads every row of the result into memory before returning, see
+// RowIterator.Materialize(). The returned RowIterator can be rewound with Rewind() to read the rows again.
+// Only use this when the result fits comfortably in memory, otherwise see RowIterator.Spool().
+func (c *Client) QueryBuffered(ctx context.Context, db string, query Stmt, options ...QueryOption) (*RowIterator, error) {
+	iter, err := c.Query(ctx, db, query, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := iter.Materialize(); err != nil {
+		iter.Stop()
+		return nil, err
+	}
+	return iter, nil
+}
+
+// Materialize reads every remaining row and inline error of the query into memory, after which the RowIterator
+// replays them and can be rewound with Rewind(). It must be called before reading any row. If the query fails,
+// the error is returned and the RowIterator keeps returning it. Calling Materialize() again does nothing.
+func (r *RowIterator) Materialize() error {
+	if r.buffered != nil {
+		return nil
+	}
+	if r.started {
+		return errors.ES(r.op, errors.KClientArgs, "Materialize() must be called before reading rows from the RowIterator").SetNoRetry()
+	}
+
+	b := &bufferedRows{}
+	for {
+		row, inlineErr, tbl, err := r.nextEntry()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if tbl != nil {
+			// The rows are read with the columns of their table, which the replay starts again.
+			r.table = tbl
+		} else if inlineErr == nil {
+			b.count++
+		}
+		b.rows = append(b.rows, bufferedRow{row: row, inlineErr: inlineErr, table: tbl})
+	}
+	r.table = nil
+	r.buffered = b
+	return nil
+}
+
+// Rewind starts reading the rows again from the first row of the first table. Returns NotMaterializedErr if the
+// RowIterator was not created by QueryBuffered() or had Materialize() called, as streamed rows cannot be read twice.
+// Rewind() cannot be called after Stop().
+func (r *RowIterator) Rewind() error {
+	if r.buffered == nil {
+		return NotMaterializedErr
+	}
+	if r.ctx.Err() != nil {
+		return errors.ES(r.op, errors.KClientArgs, "cannot Rewind() a RowIterator after Stop()").SetNoRetry()
+	}
+	r.buffered.pos = 0
+	r.table = nil
+	// An inline error returned by Next() on the previous pass is returned again when it is reached.
+	r.setError(nil)
+	// Every pass can read the rows in its own style.
+	r.style = styleNone
+	r.scan = scanState{}
+	return nil
+}
+
+// RowCount returns the number of rows of a materialized RowIterator, not counting inline errors, or -1 if the
+// RowIterator was not materialized.
+func (r *RowIterator) RowCount() int64 {
+	if r.buffered == nil {
+		return -1
+	}
+	return r.buffered.count
+}
@@ -0,0 +1,171 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewind(t *testing.T) {
+	t.Parallel()
+
+	columns := table.Columns{{Name: "Region", Type: "string"}, {Name: "Count", Type: "long"}}
+	rows := []value.Values{
+		{value.NewString("west"), value.NewLong(1)},
+		{value.NewString("east"), value.NewLong(3)},
+		{value.NewString("north"), value.NewLong(6)},
+	}
+	inlineErr := errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Some error")
+	newIter := func() *RowIterator {
+		return spoolIterator(columns, func(send func(fr v2.TableFragment)) {
+			send(v2.TableFragment{KustoRows: rows[:2]})
+			send(v2.TableFragment{KustoRows: rows[2:], RowErrors: []errors.Error{*inlineErr}})
+		})
+	}
+	// readAll reads the rows left in iter, with "error" standing for an inline error.
+	readAll := func(t *testing.T, iter *RowIterator) []string {
+		var got []string
+		require.NoError(t, iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+			if e != nil {
+				got = append(got, "error")
+				return nil
+			}
+			got = append(got, r.Values[0].String())
+			return nil
+		}))
+		return got
+	}
+	all := []string{"west", "east", "north", "error"}
+
+	tests := []struct {
+		desc string
+		test func(t *testing.T, iter *RowIterator)
+	}{
+		{
+			desc: "Rewind after complete iteration",
+			test: func(t *testing.T, iter *RowIterator) {
+				require.NoError(t, iter.Materialize())
+				assert.Equal(t, int64(3), iter.RowCount())
+				assert.Equal(t, all, readAll(t, iter))
+				require.NoError(t, iter.Rewind())
+				assert.Equal(t, all, readAll(t, iter))
+			},
+		},
+		{
+			desc: "Rewind after partial iteration",
+			test: func(t *testing.T, iter *RowIterator) {
+				require.NoError(t, iter.Materialize())
+				row, err := iter.Next()
+				require.NoError(t, err)
+				assert.Equal(t, "west", row.Values[0].String())
+				require.NoError(t, iter.Rewind())
+				assert.Equal(t, all, readAll(t, iter))
+			},
+		},
+		{
+			desc: "Rewind clears an inline error returned by Next",
+			test: func(t *testing.T, iter *RowIterator) {
+				require.NoError(t, iter.Materialize())
+				err := iter.Do(func(*table.Row) error { return nil })
+				assert.Equal(t, inlineErr, err)
+				require.NoError(t, iter.Rewind())
+				assert.Equal(t, all, readAll(t, iter))
+			},
+		},
+		{
+			desc: "Materialize twice",
+			test: func(t *testing.T, iter *RowIterator) {
+				require.NoError(t, iter.Materialize())
+				require.NoError(t, iter.Materialize())
+				assert.Equal(t, all, readAll(t, iter))
+			},
+		},
+		{
+			desc: "Streaming iterator",
+			test: func(t *testing.T, iter *RowIterator) {
+				assert.Equal(t, NotMaterializedErr, iter.Rewind())
+				assert.Equal(t, int64(-1), iter.RowCount())
+				assert.Equal(t, all, readAll(t, iter))
+				assert.Equal(t, NotMaterializedErr, iter.Rewind())
+			},
+		},
+		{
+			desc: "Materialize after reading",
+			test: func(t *testing.T, iter *RowIterator) {
+				_, err := iter.Next()
+				require.NoError(t, err)
+				assert.Error(t, iter.Materialize())
+				assert.Equal(t, NotMaterializedErr, iter.Rewind())
+			},
+		},
+		{
+			desc: "Stop",
+			test: func(t *testing.T, iter *RowIterator) {
+				require.NoError(t, iter.Materialize())
+				_, err := iter.Next()
+				require.NoError(t, err)
+				iter.Stop()
+				_, err = iter.Next()
+				assert.Equal(t, context.Canceled, err)
+				assert.Error(t, iter.Rewind())
+			},
+		},
+		{
+			desc: "Materialize after Stop",
+			test: func(t *testing.T, iter *RowIterator) {
+				iter.Stop()
+				assert.Equal(t, context.Canceled, iter.Materialize())
+				_, err := iter.Next()
+				assert.Error(t, err)
+				assert.NotEqual(t, io.EOF, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := newIter()
+			defer iter.Stop()
+			test.test(t, iter)
+		})
+	}
+}
+
+func TestQueryBuffered(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "compressed.json"))
+	require.NoError(t, err)
+	client := newTestClient(t, "https://buffered.kusto.windows.net", fixtureTransport{body: body})
+
+	iter, err := client.QueryBuffered(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	defer iter.Stop()
+	assert.Equal(t, int64(3), iter.RowCount())
+
+	// Two passes: the total first, then the share of each row.
+	var total int64
+	require.NoError(t, iter.Do(func(r *table.Row) error {
+		total += r.Values[1].(value.Long).Value
+		return nil
+	}))
+	require.NoError(t, iter.Rewind())
+	var shares []float64
+	require.NoError(t, iter.Do(func(r *table.Row) error {
+		shares = append(shares, float64(r.Values[1].(value.Long).Value)/float64(total))
+		return nil
+	}))
+	assert.Equal(t, []float64{1.0 / 6, 2.0 / 6, 3.0 / 6}, shares)
+}
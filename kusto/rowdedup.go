@@ -0,0 +1,172 @@
+package kusto
+
+// rowdedup.go implements DedupRows(), which drops duplicate rows from a RowIterator.
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// DedupOverflowError is returned by a DedupIterator when more distinct keys were seen than it may hold.
+type DedupOverflowError struct {
+	// MaxKeys is the number of distinct keys the DedupIterator could hold.
+	MaxKeys int
+}
+
+// Error implements error.
+func (d *DedupOverflowError) Error() string {
+	return fmt.Sprintf("more than %d distinct keys were seen while removing duplicate rows", d.MaxKeys)
+}
+
+// DedupIterator wraps a RowIterator and drops the rows whose key was already seen.
+// It provides the same methods to read rows as RowIterator.
+type DedupIterator struct {
+	iter    *RowIterator
+	indexes []int
+	maxKeys int
+	seen    map[string]struct{}
+	buf     []byte
+	err     error
+}
+
+// DedupRows returns a DedupIterator over iter that drops the rows whose values in keyColumns are the same as the ones
+// of a previous row. If no key columns are given, all the columns are used.
+// Null values are equal to each other and different from any other value, including empty strings.
+// At most maxKeys distinct keys are held; once more are seen, a *DedupOverflowError is returned.
+// When a progressive query replaces its results (table.Row.Replace), the keys seen so far are forgotten.
+func DedupRows(iter *RowIterator, maxKeys int, keyColumns ...string) (*DedupIterator, error) {
+	if maxKeys <= 0 {
+		return nil, errors.ES(iter.op, errors.KClientArgs, "DedupRows() maxKeys must be positive, was %d", maxKeys).SetNoRetry()
+	}
+
+	var indexes []int
+	if len(keyColumns) == 0 {
+		for i := range iter.columns {
+			indexes = append(indexes, i)
+		}
+	}
+	for _, name := range keyColumns {
+		index := -1
+		for i, col := range iter.columns {
+			if col.Name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, errors.ES(iter.op, errors.KClientArgs, "DedupRows() key column %q is not in the result", name).SetNoRetry()
+		}
+		indexes = append(indexes, index)
+	}
+
+	return &DedupIterator{iter: iter, indexes: indexes, maxKeys: maxKeys, seen: map[string]struct{}{}}, nil
+}
+
+// Stop is called to stop any further iteration.
+func (d *DedupIterator) Stop() {
+	d.iter.Stop()
+}
+
+// Do calls f for every row that is not a duplicate. If f returns a non-nil error,
+// iteration stops. This method will fail on errors inline within the rows.
+func (d *DedupIterator) Do(f func(r *table.Row) error) error {
+	for {
+		row, err := d.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row); err != nil {
+			return err
+		}
+	}
+}
+
+// DoOnRowOrError calls f for every row that is not a duplicate or inline error. If f returns a non-nil error,
+// iteration stops.
+func (d *DedupIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error) error {
+	for {
+		row, inlineErr, err := d.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row, inlineErr); err != nil {
+			return err
+		}
+	}
+}
+
+// Next gets the next Row that is not a duplicate. io.EOF is returned if there are no more rows.
+// This method will fail on errors inline within the rows.
+func (d *DedupIterator) Next() (row *table.Row, finalError error) {
+	row, inlineErr, err := d.NextRowOrError()
+	if err != nil {
+		return nil, err
+	}
+	if inlineErr != nil {
+		d.err = inlineErr
+		return nil, inlineErr
+	}
+	return row, nil
+}
+
+// NextRowOrError gets the next Row that is not a duplicate or service-side error.
+// Once finalError returns non-nil, all subsequent calls will return the same error.
+func (d *DedupIterator) NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+
+	for {
+		row, inlineErr, err := d.iter.NextRowOrError()
+		if err != nil {
+			d.err = err
+			return nil, nil, err
+		}
+		if inlineErr != nil {
+			return nil, inlineErr, nil
+		}
+
+		if row.Replace {
+			d.seen = map[string]struct{}{}
+		}
+
+		d.buf = d.key(d.buf[:0], row.Values)
+		if _, ok := d.seen[string(d.buf)]; ok {
+			continue
+		}
+		if len(d.seen) >= d.maxKeys {
+			d.err = &DedupOverflowError{MaxKeys: d.maxKeys}
+			return nil, nil, d.err
+		}
+		d.seen[string(d.buf)] = struct{}{}
+		return row, nil, nil
+	}
+}
+
+// key appends the key of a row to b. Each value is a null marker or a length prefixed string, so that no two
+// different keys have the same encoding.
+func (d *DedupIterator) key(b []byte, values value.Values) []byte {
+	for _, i := range d.indexes {
+		if i >= len(values) || value.IsNull(values[i]) {
+			b = append(b, 'n')
+			continue
+		}
+		s := values[i].String()
+		b = append(b, 'v')
+		b = strconv.AppendInt(b, int64(len(s)), 10)
+		b = append(b, ':')
+		b = append(b, s...)
+	}
+	return b
+}
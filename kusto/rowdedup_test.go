@@ -0,0 +1,205 @@
+package kusto
+
+import (
+	goErrors "errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dedupColumns = table.Columns{
+	{Name: "ID", Type: "long"},
+	{Name: "Name", Type: "string"},
+}
+
+func dedupRow(id int64, name string, nameValid bool) value.Values {
+	return value.Values{value.Long{Value: id, Valid: true}, value.String{Value: name, Valid: nameValid}}
+}
+
+func TestDedupRows(t *testing.T) {
+	t.Parallel()
+
+	fragments := []v2.TableFragment{
+		{
+			KustoRows: []value.Values{
+				dedupRow(1, "a", true),
+				dedupRow(1, "a", true),
+				dedupRow(2, "a", true),
+				dedupRow(3, "", true),
+				dedupRow(3, "", false),
+				dedupRow(4, "", false),
+				dedupRow(4, "", false),
+			},
+			RowErrors: []errors.Error{*errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Some error")},
+		},
+		{
+			KustoRows: []value.Values{
+				dedupRow(1, "a", true),
+				dedupRow(1, "a", true),
+			},
+			TableFragmentType: "DataReplace",
+		},
+	}
+
+	tests := []struct {
+		desc       string
+		keyColumns []string
+		maxKeys    int
+		want       []value.Values
+		wantErr    error
+	}{
+		{
+			desc: "All columns",
+			want: []value.Values{
+				dedupRow(1, "a", true),
+				dedupRow(2, "a", true),
+				dedupRow(3, "", true),
+				dedupRow(3, "", false),
+				dedupRow(4, "", false),
+				dedupRow(1, "a", true),
+			},
+		},
+		{
+			desc:       "Key column",
+			keyColumns: []string{"Name"},
+			want: []value.Values{
+				dedupRow(1, "a", true),
+				dedupRow(3, "", true),
+				dedupRow(3, "", false),
+				dedupRow(1, "a", true),
+			},
+		},
+		{
+			desc:    "Overflow",
+			maxKeys: 2,
+			want: []value.Values{
+				dedupRow(1, "a", true),
+				dedupRow(2, "a", true),
+			},
+			wantErr: &DedupOverflowError{MaxKeys: 2},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := spoolIterator(dedupColumns, func(send func(fr v2.TableFragment)) {
+				for _, fr := range fragments {
+					send(fr)
+				}
+			})
+			defer iter.Stop()
+
+			maxKeys := test.maxKeys
+			if maxKeys == 0 {
+				maxKeys = 100
+			}
+			dedup, err := DedupRows(iter, maxKeys, test.keyColumns...)
+			require.NoError(t, err)
+
+			var got []value.Values
+			inlineErrs := 0
+			err = dedup.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+				if e != nil {
+					inlineErrs++
+					return nil
+				}
+				got = append(got, r.Values)
+				return nil
+			})
+			assert.Equal(t, test.wantErr, err)
+			assert.Equal(t, test.want, got)
+
+			if test.wantErr != nil {
+				var overflow *DedupOverflowError
+				require.True(t, goErrors.As(err, &overflow))
+				return
+			}
+			assert.Equal(t, 1, inlineErrs)
+		})
+	}
+}
+
+func TestDedupRowsArgs(t *testing.T) {
+	t.Parallel()
+
+	iter := spoolIterator(dedupColumns, func(send func(fr v2.TableFragment)) {})
+	defer iter.Stop()
+
+	_, err := DedupRows(iter, 10, "Missing")
+	assert.Error(t, err)
+	_, err = DedupRows(iter, 0)
+	assert.Error(t, err)
+}
+
+/*
+BenchmarkDedupRows/Iterate         	       1	 807196455 ns/op	200694768 B/op	 6003576 allocs/op
+BenchmarkDedupRows/DedupRows       	       1	1229385507 ns/op	268469968 B/op	 7507471 allocs/op
+*/
+func BenchmarkDedupRows(b *testing.B) {
+	const rows = 1000000
+
+	newIter := func() *RowIterator {
+		return spoolIterator(dedupColumns, func(send func(fr v2.TableFragment)) {
+			for i := 0; i < rows; i += 1000 {
+				fragment := make([]value.Values, 1000)
+				for j := range fragment {
+					// Every key is seen twice.
+					id := int64(i+j) / 2
+					fragment[j] = dedupRow(id, fmt.Sprintf("name%d", id), true)
+				}
+				send(v2.TableFragment{KustoRows: fragment})
+			}
+		})
+	}
+
+	b.Run("Iterate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			iter := newIter()
+			for {
+				if _, err := iter.Next(); err != nil {
+					if err != io.EOF {
+						b.Fatal(err)
+					}
+					break
+				}
+			}
+			iter.Stop()
+		}
+	})
+
+	b.Run("DedupRows", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			iter := newIter()
+			dedup, err := DedupRows(iter, rows, "ID")
+			if err != nil {
+				b.Fatal(err)
+			}
+			n := 0
+			for {
+				if _, err := dedup.Next(); err != nil {
+					if err != io.EOF {
+						b.Fatal(err)
+					}
+					break
+				}
+				n++
+			}
+			if n != rows/2 {
+				b.Fatalf("got %d rows, want %d", n, rows/2)
+			}
+			dedup.Stop()
+		}
+	})
+}
@@ -0,0 +1,65 @@
+//go:build go1.23
+
+package kusto
+
+// rows.go implements RowIterator.Rows(), which reads the rows with a range-over-func loop.
+
+import (
+	"io"
+	"iter"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// Rows returns the rows of the query as a sequence, for use in a for range loop. Errors inline within the rows are
+// yielded with a nil row and the iteration continues after them. Any other error is yielded last. Once the loop ends,
+// including with a break or a return, the RowIterator is stopped, unless it was materialized.
+// Rows() cannot be mixed with Next(), NextRowOrError(), Do() or DoOnRowOrError(), see MixedIterationError.
+// Example:
+//
+//	for row, err := range iter.Rows() {
+//		if err != nil {
+//			...
+//		}
+//		...
+//	}
+func (r *RowIterator) Rows() iter.Seq2[*table.Row, error] {
+	return func(yield func(*table.Row, error) bool) {
+		// The rows are read in the other style, so the RowIterator is not stopped.
+		if err := r.useStyle(styleScan); err != nil {
+			yield(nil, err)
+			return
+		}
+		defer func() {
+			// A materialized RowIterator keeps its rows for Rewind().
+			if r.buffered == nil {
+				r.Stop()
+			}
+		}()
+
+		for {
+			// Rows already received are not returned once the RowIterator was stopped.
+			if err := r.ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			row, inlineErr, err := r.nextRowOrError()
+			switch {
+			case err == io.EOF:
+				return
+			case err != nil:
+				yield(nil, err)
+				return
+			case inlineErr != nil:
+				if !yield(nil, inlineErr) {
+					return
+				}
+				continue
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
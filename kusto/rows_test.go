@@ -0,0 +1,130 @@
+//go:build go1.23
+
+package kusto
+
+import (
+	goErrors "errors"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRows(t *testing.T) {
+	t.Parallel()
+
+	finalErr := goErrors.New("final error")
+
+	tests := []struct {
+		desc    string
+		iter    func(t *testing.T) *RowIterator
+		breakAt int64
+		want    []int64
+		// wantErrs are the errors yielded, in order.
+		wantErrs []string
+	}{
+		{
+			desc: "All rows",
+			iter: func(t *testing.T) *RowIterator {
+				return pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(3, "c", true))
+			},
+			want: []int64{1, 2, 3},
+		},
+		{
+			desc: "Break",
+			iter: func(t *testing.T) *RowIterator {
+				return pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(3, "c", true))
+			},
+			breakAt: 2,
+			want:    []int64{1, 2},
+		},
+		{
+			desc: "Final error",
+			iter: func(t *testing.T) *RowIterator {
+				iter := pipelineIterator(t, dedupRow(1, "a", true))
+				require.NoError(t, iter.mock.Error(finalErr))
+				return iter
+			},
+			want:     []int64{1},
+			wantErrs: []string{finalErr.Error()},
+		},
+		{
+			desc:     "Inline error",
+			iter:     func(t *testing.T) *RowIterator { return inlineErrorIterator() },
+			want:     []int64{1, 2, 3},
+			wantErrs: []string{"Kind(KLimitsExceeded): Some error"},
+		},
+		{
+			desc:    "Break on a streaming query",
+			iter:    func(t *testing.T) *RowIterator { return inlineErrorIterator() },
+			breakAt: 1,
+			want:    []int64{1},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := test.iter(t)
+
+			var got []int64
+			var gotErrs []string
+			for row, err := range iter.Rows() {
+				if err != nil {
+					gotErrs = append(gotErrs, err.Error())
+					continue
+				}
+				id := row.Values[0].(value.Long).Value
+				got = append(got, id)
+				if id == test.breakAt {
+					break
+				}
+			}
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.wantErrs, gotErrs)
+
+			// The RowIterator is stopped once the loop ends, without a call to Stop().
+			assert.Error(t, iter.ctx.Err())
+		})
+	}
+}
+
+func TestRowsMixed(t *testing.T) {
+	t.Parallel()
+
+	iter := pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true))
+	defer iter.Stop()
+	_, err := iter.Next()
+	require.NoError(t, err)
+
+	var errs []error
+	for row, err := range iter.Rows() {
+		assert.Nil(t, row)
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	var mixed *MixedIterationError
+	require.True(t, goErrors.As(errs[0], &mixed))
+	assert.Equal(t, errors.OpQuery, mixed.Op)
+	assert.NoError(t, iter.ctx.Err())
+
+	// A materialized RowIterator is not stopped, so it can be rewound.
+	iter = pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true))
+	defer iter.Stop()
+	require.NoError(t, iter.Materialize())
+	for range iter.Rows() {
+		break
+	}
+	assert.NoError(t, iter.ctx.Err())
+	require.NoError(t, iter.Rewind())
+	count := 0
+	for _, err := range iter.Rows() {
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 2, count)
+}
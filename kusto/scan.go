@@ -0,0 +1,136 @@
+package kusto
+
+// scan.go implements RowIterator.Scan(), Row() and Err(), which read the rows in a for loop instead of with a callback.
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+)
+
+// iterStyle is the way the rows of a RowIterator are read.
+type iterStyle int
+
+const (
+	styleNone iterStyle = iota
+	// styleNext is used by Next(), NextRowOrError(), Do() and DoOnRowOrError().
+	styleNext
+	// styleScan is used by Scan() and Rows().
+	styleScan
+)
+
+// String implements fmt.Stringer.
+func (s iterStyle) String() string {
+	switch s {
+	case styleNext:
+		return "Next(), NextRowOrError(), Do() or DoOnRowOrError()"
+	case styleScan:
+		return "Scan() or Rows()"
+	}
+	return "nothing"
+}
+
+// MixedIterationError is returned when the rows of a RowIterator are read with Scan() or Rows() after being read
+// with Next(), NextRowOrError(), Do() or DoOnRowOrError(), or the other way around. The two styles track the end
+// of the rows differently, so they cannot be mixed on the same RowIterator.
+type MixedIterationError struct {
+	// Op is the operation of the RowIterator.
+	Op errors.Op
+	// Used are the methods the rows were read with.
+	Used string
+	// Called are the methods of the other style, one of which was called.
+	Called string
+}
+
+// Error implements error.
+func (m *MixedIterationError) Error() string {
+	return fmt.Sprintf("Op(%s): the rows of the RowIterator are read with %s, so %s cannot be used", m.Op, m.Used, m.Called)
+}
+
+// useStyle records that the rows are read in style, and returns an error if they were already read in another one.
+func (r *RowIterator) useStyle(style iterStyle) error {
+	if r.style != styleNone && r.style != style {
+		return &MixedIterationError{Op: r.op, Used: r.style.String(), Called: style.String()}
+	}
+	r.style = style
+	return nil
+}
+
+// scanState is the state of Scan(), Row() and Err().
+type scanState struct {
+	row  *table.Row
+	err  error
+	done bool
+}
+
+// Scan reads the next row of the query, which is then returned by Row(). It returns false once there are no more
+// rows or an error occurred, after which Err() returns the error, if any. An error inline within the rows ends the
+// iteration like Next() does: use NextRowOrError() to read past them. Once Scan() returns false, the RowIterator is
+// stopped, unless it was materialized. Breaking out of the loop does not stop it, so always defer a Stop() call.
+// Scan() cannot be mixed with Next(), NextRowOrError(), Do() or DoOnRowOrError(), see MixedIterationError.
+// Example:
+//
+//	defer iter.Stop()
+//	for iter.Scan() {
+//		row := iter.Row()
+//		...
+//	}
+//	if err := iter.Err(); err != nil {
+//		...
+//	}
+func (r *RowIterator) Scan() bool {
+	if r.scan.done {
+		return false
+	}
+	if err := r.useStyle(styleScan); err != nil {
+		// The rows are read in the other style, so the RowIterator is not stopped.
+		r.scan = scanState{err: err, done: true}
+		return false
+	}
+
+	// Rows already received are not returned once the RowIterator was stopped.
+	if err := r.ctx.Err(); err != nil {
+		r.endScan(err)
+		return false
+	}
+
+	row, inlineErr, err := r.nextRowOrError()
+	for err == nil && inlineErr != nil && r.completionErrs[inlineErr] {
+		row, inlineErr, err = r.nextRowOrError()
+	}
+	switch {
+	case err == io.EOF:
+		r.endScan(nil)
+		return false
+	case err != nil:
+		r.endScan(err)
+		return false
+	case inlineErr != nil:
+		r.setError(inlineErr)
+		r.endScan(inlineErr)
+		return false
+	}
+	r.scan.row = row
+	return true
+}
+
+// Row returns the row read by the last call to Scan() that returned true, or nil.
+func (r *RowIterator) Row() *table.Row {
+	return r.scan.row
+}
+
+// Err returns the error that ended Scan(), or nil if all the rows were read or Scan() is not done.
+func (r *RowIterator) Err() error {
+	return r.scan.err
+}
+
+// endScan ends the iteration of Scan() with err, which is nil if all the rows were read.
+func (r *RowIterator) endScan(err error) {
+	r.scan = scanState{err: err, done: true}
+	// A materialized RowIterator keeps its rows for Rewind().
+	if r.buffered == nil {
+		r.Stop()
+	}
+}
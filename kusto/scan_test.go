@@ -0,0 +1,310 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"io"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inlineErrorIterator returns a progressive RowIterator with the rows 1 and 2, an inline error and the row 3.
+func inlineErrorIterator() *RowIterator {
+	return spoolIterator(dedupColumns, func(send func(fr v2.TableFragment)) {
+		send(v2.TableFragment{KustoRows: []value.Values{dedupRow(1, "a", true), dedupRow(2, "b", true)}})
+		send(v2.TableFragment{RowErrors: []errors.Error{*errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Some error")}})
+		send(v2.TableFragment{KustoRows: []value.Values{dedupRow(3, "c", true)}})
+	})
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	finalErr := goErrors.New("final error")
+
+	tests := []struct {
+		desc     string
+		iter     func(t *testing.T) *RowIterator
+		want     []int64
+		wantErr  func(t *testing.T, err error)
+		buffered bool
+	}{
+		{
+			desc: "All rows",
+			iter: func(t *testing.T) *RowIterator {
+				return pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true), dedupRow(3, "c", true))
+			},
+			want: []int64{1, 2, 3},
+		},
+		{
+			desc: "Final error",
+			iter: func(t *testing.T) *RowIterator {
+				iter := pipelineIterator(t, dedupRow(1, "a", true))
+				require.NoError(t, iter.mock.Error(finalErr))
+				return iter
+			},
+			want: []int64{1},
+			wantErr: func(t *testing.T, err error) {
+				assert.Equal(t, finalErr, err)
+			},
+		},
+		{
+			desc: "Inline error",
+			iter: func(t *testing.T) *RowIterator { return inlineErrorIterator() },
+			want: []int64{1, 2},
+			wantErr: func(t *testing.T, err error) {
+				var e *errors.Error
+				require.True(t, goErrors.As(err, &e))
+				assert.Equal(t, errors.KLimitsExceeded, e.Kind)
+			},
+		},
+		{
+			desc: "Materialized",
+			iter: func(t *testing.T) *RowIterator {
+				iter := pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true))
+				require.NoError(t, iter.Materialize())
+				return iter
+			},
+			want:     []int64{1, 2},
+			buffered: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := test.iter(t)
+			defer iter.Stop()
+			assert.Nil(t, iter.Row())
+
+			var got []int64
+			for iter.Scan() {
+				got = append(got, iter.Row().Values[0].(value.Long).Value)
+			}
+			assert.Equal(t, test.want, got)
+			if test.wantErr != nil {
+				test.wantErr(t, iter.Err())
+			} else {
+				assert.NoError(t, iter.Err())
+			}
+
+			// The iteration is over, and the RowIterator was stopped unless its rows are in memory.
+			assert.False(t, iter.Scan())
+			assert.Nil(t, iter.Row())
+			if test.buffered {
+				assert.NoError(t, iter.ctx.Err())
+				require.NoError(t, iter.Rewind())
+				assert.True(t, iter.Scan())
+				return
+			}
+			assert.Error(t, iter.ctx.Err())
+		})
+	}
+}
+
+func TestScanBreak(t *testing.T) {
+	t.Parallel()
+
+	iter := inlineErrorIterator()
+	for iter.Scan() {
+		break
+	}
+	assert.Equal(t, int64(1), iter.Row().Values[0].(value.Long).Value)
+	assert.NoError(t, iter.Err())
+	iter.Stop()
+
+	// Once stopped, the RowIterator is done.
+	assert.False(t, iter.Scan())
+	assert.Equal(t, "context canceled", iter.Err().Error())
+}
+
+func TestMixedIteration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		// first reads the first row, then second reads the next one, which must fail.
+		first, second func(iter *RowIterator) error
+		wantUsed      string
+	}{
+		{
+			desc: "Scan after Next",
+			first: func(iter *RowIterator) error {
+				_, err := iter.Next()
+				return err
+			},
+			second: func(iter *RowIterator) error {
+				if iter.Scan() {
+					return nil
+				}
+				return iter.Err()
+			},
+			wantUsed: styleNext.String(),
+		},
+		{
+			desc: "Next after Scan",
+			first: func(iter *RowIterator) error {
+				iter.Scan()
+				return iter.Err()
+			},
+			second: func(iter *RowIterator) error {
+				_, err := iter.Next()
+				return err
+			},
+			wantUsed: styleScan.String(),
+		},
+		{
+			desc: "DoOnRowOrError after Scan",
+			first: func(iter *RowIterator) error {
+				iter.Scan()
+				return iter.Err()
+			},
+			second: func(iter *RowIterator) error {
+				return iter.DoOnRowOrError(func(row *table.Row, e *errors.Error) error { return nil })
+			},
+			wantUsed: styleScan.String(),
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter := pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true))
+			defer iter.Stop()
+
+			require.NoError(t, test.first(iter))
+			err := test.second(iter)
+			var mixed *MixedIterationError
+			require.True(t, goErrors.As(err, &mixed), "got %v", err)
+			assert.Equal(t, test.wantUsed, mixed.Used)
+			assert.Equal(t, errors.OpQuery, mixed.Op)
+
+			// The RowIterator is not stopped, so the rows can still be read in the style they were read in.
+			assert.NoError(t, iter.ctx.Err())
+		})
+	}
+
+	// Every pass over a materialized RowIterator can use its own style.
+	iter := pipelineIterator(t, dedupRow(1, "a", true), dedupRow(2, "b", true))
+	defer iter.Stop()
+	require.NoError(t, iter.Materialize())
+	for iter.Scan() {
+	}
+	require.NoError(t, iter.Err())
+	require.NoError(t, iter.Rewind())
+	count := 0
+	for {
+		_, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		count++
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestCompletionErrors(t *testing.T) {
+	t.Parallel()
+
+	body := `[{"FrameType":"DataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":[{"ColumnName":"x","ColumnType":"long"}],"Rows":[[1],[2]]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":true,"Cancelled":false,` +
+		`"OneApiErrors":[{"error":{"code":"LimitsExceeded","message":"Query execution has exceeded the allowed limits"}}]}]`
+	client := newTestClient(t, "https://completion.kusto.windows.net", fixtureTransport{body: []byte(body)})
+
+	tests := []struct {
+		desc string
+		// read returns the rows and inline errors of iter.
+		read           func(iter *RowIterator) ([]int64, []*errors.Error, error)
+		wantInlineErrs int
+	}{
+		{
+			desc: "Next() skips them",
+			read: func(iter *RowIterator) ([]int64, []*errors.Error, error) {
+				var got []int64
+				for {
+					row, err := iter.Next()
+					if err == io.EOF {
+						return got, nil, nil
+					}
+					if err != nil {
+						return got, nil, err
+					}
+					got = append(got, row.Values[0].(value.Long).Value)
+				}
+			},
+		},
+		{
+			desc: "Do() skips them",
+			read: func(iter *RowIterator) ([]int64, []*errors.Error, error) {
+				var got []int64
+				err := iter.Do(func(row *table.Row) error {
+					got = append(got, row.Values[0].(value.Long).Value)
+					return nil
+				})
+				return got, nil, err
+			},
+		},
+		{
+			desc: "Scan() skips them",
+			read: func(iter *RowIterator) ([]int64, []*errors.Error, error) {
+				var got []int64
+				for iter.Scan() {
+					got = append(got, iter.Row().Values[0].(value.Long).Value)
+				}
+				return got, nil, iter.Err()
+			},
+		},
+		{
+			desc: "DoOnRowOrError() returns them after the rows",
+			read: func(iter *RowIterator) ([]int64, []*errors.Error, error) {
+				var (
+					got  []int64
+					errs []*errors.Error
+				)
+				err := iter.DoOnRowOrError(func(row *table.Row, e *errors.Error) error {
+					if e != nil {
+						errs = append(errs, e)
+						return nil
+					}
+					assert.Empty(t, errs, "a row after the errors of the DataSetCompletion")
+					got = append(got, row.Values[0].(value.Long).Value)
+					return nil
+				})
+				return got, errs, err
+			},
+			wantInlineErrs: 1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+			require.NoError(t, err)
+			defer iter.Stop()
+
+			got, inlineErrs, err := test.read(iter)
+			require.NoError(t, err)
+			assert.Equal(t, []int64{1, 2}, got)
+			require.Len(t, inlineErrs, test.wantInlineErrs)
+			for _, e := range inlineErrs {
+				assert.Equal(t, errors.KLimitsExceeded, e.Kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package kusto
+
+// show_queries.go holds typed wrappers around the .show queries and .show running queries commands.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+)
+
+// defaultQueryTextLength is the default length QueryInfo.Text is truncated to, see QueriesFilter.MaxTextLength.
+const defaultQueryTextLength = 1024
+
+// defaultDB is the database used for commands that are not scoped to a database.
+const defaultDB = "NetDefaultDB"
+
+// QueriesFilter selects the queries returned by ShowQueries() and ShowRunningQueries().
+type QueriesFilter struct {
+	// Since only returns the queries that started less than Since ago. This is applied by the service.
+	// If 0, all the queries are returned.
+	Since time.Duration
+	// User only returns the queries made by this user, if set.
+	User string
+	// Application only returns the queries made by this application, if set.
+	Application string
+	// State only returns the queries in this state, such as "Completed", "Failed" or "InProgress", if set.
+	State string
+	// Limit is the maximum number of queries returned. If 0, all the queries are returned.
+	Limit int
+	// MaxTextLength is the number of characters QueryInfo.Text is truncated to. If 0, it defaults to 1024.
+	// If negative, the text is not truncated.
+	MaxTextLength int
+}
+
+// QueryInfo describes a query, as reported by the .show queries command.
+type QueryInfo struct {
+	// ClientActivityID is the client request ID of the query.
+	ClientActivityID string
+	// Text is the text of the query, truncated to QueriesFilter.MaxTextLength.
+	Text string
+	// TextTruncated indicates that Text was truncated.
+	TextTruncated bool
+	// Database is the database the query ran against.
+	Database string
+	// StartedOn is when the query started.
+	StartedOn time.Time
+	// LastUpdatedOn is when the state of the query was last updated.
+	LastUpdatedOn time.Time
+	// Duration is how long the query ran for.
+	Duration time.Duration
+	// State is the state of the query, such as "Completed", "Failed" or "InProgress".
+	State string
+	// FailureReason is the reason the query failed, if it did.
+	FailureReason string
+	// User is the user that made the query.
+	User string
+	// Application is the application that made the query.
+	Application string
+	// Principal is the identity the query was authenticated as.
+	Principal string
+	// CacheStatistics describes how the query used the cache.
+	CacheStatistics CacheStatistics
+	// ResourcesUtilization describes the resources used by the query.
+	ResourcesUtilization ResourcesUtilization
+}
+
+// CacheStatistics describes how a query used the cache.
+type CacheStatistics struct {
+	Memory CacheHits
+	Disk   CacheHits
+	Shards ShardsCacheStatistics
+}
+
+// CacheHits counts the hits and misses of a cache.
+type CacheHits struct {
+	Hits   int64
+	Misses int64
+}
+
+// ShardsCacheStatistics describes how a query used the shards cache.
+type ShardsCacheStatistics struct {
+	Hot         ShardCacheBytes
+	Cold        ShardCacheBytes
+	BypassBytes int64
+}
+
+// ShardCacheBytes counts the bytes read from the shards cache.
+type ShardCacheBytes struct {
+	HitBytes      int64
+	MissBytes     int64
+	RetrieveBytes int64
+}
+
+// ResourcesUtilization describes the resources used by a query.
+type ResourcesUtilization struct {
+	// TotalCPU is the CPU time used by the query across all nodes.
+	TotalCPU time.Duration
+	// MemoryPeak is the peak memory used by the query, in bytes.
+	MemoryPeak int64
+	// ScannedExtentsStatistics describes the extents scanned by the query.
+	ScannedExtentsStatistics ScannedExtentsStatistics
+}
+
+// ScannedExtentsStatistics describes the extents scanned by a query.
+type ScannedExtentsStatistics struct {
+	MinDataScannedTime  time.Time
+	MaxDataScannedTime  time.Time
+	ScannedExtentsCount int64
+	TotalExtentsCount   int64
+	ScannedRowsCount    int64
+	TotalRowsCount      int64
+}
+
+// UnmarshalJSON implements json.Unmarshaler, as the service reports TotalCpu as a timespan string.
+func (r *ResourcesUtilization) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		TotalCpu                 string
+		MemoryPeak               int64
+		ScannedExtentsStatistics ScannedExtentsStatistics
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if raw.TotalCpu != "" {
+		var ts value.Timespan
+		if err := ts.Unmarshal(raw.TotalCpu); err != nil {
+			return err
+		}
+		r.TotalCPU = ts.Value
+	}
+	r.MemoryPeak = raw.MemoryPeak
+	r.ScannedExtentsStatistics = raw.ScannedExtentsStatistics
+	return nil
+}
+
+// showQueriesRow is a row of .show queries. Columns that the service adds later are ignored.
+type showQueriesRow struct {
+	ClientActivityId         string
+	Text                     string
+	Database                 string
+	StartedOn                time.Time
+	LastUpdatedOn            time.Time
+	Duration                 time.Duration
+	State                    string
+	FailureReason            string
+	User                     string
+	Application              string
+	Principal                string
+	TotalCpu                 time.Duration
+	MemoryPeak               int64
+	CacheStatistics          value.Dynamic
+	ScannedExtentsStatistics value.Dynamic
+	ResourcesUtilization     value.Dynamic
+}
+
+// ShowQueries returns the queries that ran on the database db, using the .show queries command.
+func (c *Client) ShowQueries(ctx context.Context, db string, filter QueriesFilter) ([]QueryInfo, error) {
+	return c.showQueries(ctx, db, NewStmt(".show queries", UnsafeStmt(unsafe.Stmt{Add: true, SuppressWarning: true})), filter)
+}
+
+// ShowRunningQueries returns the queries currently running on the cluster, using the .show running queries command.
+func (c *Client) ShowRunningQueries(ctx context.Context, filter QueriesFilter) ([]QueryInfo, error) {
+	return c.showQueries(ctx, defaultDB, NewStmt(".show running queries", UnsafeStmt(unsafe.Stmt{Add: true, SuppressWarning: true})), filter)
+}
+
+func (c *Client) showQueries(ctx context.Context, db string, query Stmt, filter QueriesFilter) ([]QueryInfo, error) {
+	if filter.Since < 0 {
+		return nil, errors.ES(errors.OpMgmt, errors.KClientArgs, "QueriesFilter.Since cannot be negative, was %v", filter.Since).SetNoRetry()
+	}
+	if filter.Since > 0 {
+		// The timespan is formatted by us, so this cannot be used for an injection.
+		query = query.UnsafeAdd(" | where StartedOn > ago(time(" + value.Timespan{Value: filter.Since, Valid: true}.Marshal() + "))")
+	}
+
+	iter, err := c.Mgmt(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Stop()
+
+	var infos []QueryInfo
+	err = iter.Do(func(row *table.Row) error {
+		info, err := toQueryInfo(row, filter.MaxTextLength)
+		if err != nil {
+			return err
+		}
+		if filter.match(info) {
+			infos = append(infos, info)
+		}
+		if filter.Limit > 0 && len(infos) >= filter.Limit {
+			return errQueriesLimit
+		}
+		return nil
+	})
+	if err != nil && err != errQueriesLimit {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// errQueriesLimit stops the iteration of showQueries() once QueriesFilter.Limit queries were found.
+var errQueriesLimit = fmt.Errorf("queries limit reached")
+
+func (f QueriesFilter) match(info QueryInfo) bool {
+	switch {
+	case f.User != "" && f.User != info.User:
+		return false
+	case f.Application != "" && f.Application != info.Application:
+		return false
+	case f.State != "" && f.State != info.State:
+		return false
+	}
+	return true
+}
+
+// toQueryInfo converts a row of .show queries into a QueryInfo.
+func toQueryInfo(row *table.Row, maxTextLength int) (QueryInfo, error) {
+	var r showQueriesRow
+	if err := row.ToStruct(&r); err != nil {
+		return QueryInfo{}, err
+	}
+
+	info := QueryInfo{
+		ClientActivityID: r.ClientActivityId,
+		Text:             r.Text,
+		Database:         r.Database,
+		StartedOn:        r.StartedOn,
+		LastUpdatedOn:    r.LastUpdatedOn,
+		Duration:         r.Duration,
+		State:            r.State,
+		FailureReason:    r.FailureReason,
+		User:             r.User,
+		Application:      r.Application,
+		Principal:        r.Principal,
+		ResourcesUtilization: ResourcesUtilization{
+			TotalCPU:   r.TotalCpu,
+			MemoryPeak: r.MemoryPeak,
+		},
+	}
+
+	if maxTextLength == 0 {
+		maxTextLength = defaultQueryTextLength
+	}
+	if text := []rune(info.Text); maxTextLength > 0 && len(text) > maxTextLength {
+		info.Text = string(text[:maxTextLength])
+		info.TextTruncated = true
+	}
+
+	if err := unmarshalDynamic(row.Op, "CacheStatistics", r.CacheStatistics, &info.CacheStatistics); err != nil {
+		return QueryInfo{}, err
+	}
+	if err := unmarshalDynamic(row.Op, "ScannedExtentsStatistics", r.ScannedExtentsStatistics, &info.ResourcesUtilization.ScannedExtentsStatistics); err != nil {
+		return QueryInfo{}, err
+	}
+	// Newer versions of the service report the resources in a single column, which takes precedence.
+	if err := unmarshalDynamic(row.Op, "ResourcesUtilization", r.ResourcesUtilization, &info.ResourcesUtilization); err != nil {
+		return QueryInfo{}, err
+	}
+	return info, nil
+}
+
+// unmarshalDynamic unmarshals a dynamic column into v, leaving v untouched if the column is missing or null.
+func unmarshalDynamic(op errors.Op, column string, d value.Dynamic, v interface{}) error {
+	if !d.Valid || len(d.Value) == 0 || string(d.Value) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(d.Value, v); err != nil {
+		return errors.ES(op, errors.KInternal, "could not decode column %s: %s", column, err)
+	}
+	return nil
+}
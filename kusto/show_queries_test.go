@@ -0,0 +1,163 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mgmtTransport is a fake http.RoundTripper that answers management commands with a fixed body.
+type mgmtTransport struct {
+	body []byte
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func (m *mgmtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v1/rest/mgmt") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.commands = append(m.commands, msg.CSL)
+	m.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(string(m.body))),
+	}, nil
+}
+
+func TestShowQueries(t *testing.T) {
+	t.Parallel()
+
+	fixture, err := os.ReadFile("testdata/show_queries.json")
+	require.NoError(t, err)
+
+	completed := QueryInfo{
+		ClientActivityID: "KGC.execute;3bbd6b1c-2c6a-4cfa-9a2f-6a2c8a6e1f10",
+		Text:             "StormEvents | where State == 'TEXAS' | summarize count() by EventType",
+		Database:         "Samples",
+		StartedOn:        time.Date(2023, 1, 10, 10, 15, 30, 123456700, time.UTC),
+		LastUpdatedOn:    time.Date(2023, 1, 10, 10, 15, 31, 234567800, time.UTC),
+		Duration:         1111111100 * time.Nanosecond,
+		State:            "Completed",
+		FailureReason:    "[none]",
+		User:             "AAD app id=9d6c1b5e-5c3e-4b5a-8f6e-1234567890ab",
+		Application:      "Kusto.Explorer",
+		Principal:        "aadapp=9d6c1b5e-5c3e-4b5a-8f6e-1234567890ab;72f988bf-86f1-41af-91ab-2d7cd011db47",
+		CacheStatistics: CacheStatistics{
+			Memory: CacheHits{Hits: 40, Misses: 2},
+			Disk:   CacheHits{Hits: 2, Misses: 1},
+			Shards: ShardsCacheStatistics{Hot: ShardCacheBytes{HitBytes: 1024}},
+		},
+		ResourcesUtilization: ResourcesUtilization{
+			TotalCPU:   31250 * time.Microsecond,
+			MemoryPeak: 2097152,
+			ScannedExtentsStatistics: ScannedExtentsStatistics{
+				MinDataScannedTime:  time.Date(2007, 1, 1, 0, 0, 0, 0, time.UTC),
+				MaxDataScannedTime:  time.Date(2007, 12, 31, 23, 53, 0, 0, time.UTC),
+				ScannedExtentsCount: 1,
+				TotalExtentsCount:   3,
+				ScannedRowsCount:    4701,
+				TotalRowsCount:      59066,
+			},
+		},
+	}
+	failed := QueryInfo{
+		ClientActivityID: "KGC.execute;7a0f4f1e-9e1a-4b9e-8a3c-2f2f2f2f2f2f",
+		Text:             "StormEvents | take 10",
+		Database:         "Samples",
+		StartedOn:        time.Date(2023, 1, 10, 10, 16, 0, 0, time.UTC),
+		LastUpdatedOn:    time.Date(2023, 1, 10, 10, 16, 0, 500000000, time.UTC),
+		Duration:         500 * time.Millisecond,
+		State:            "Failed",
+		FailureReason:    "Query execution has exceeded the allowed limits",
+		User:             "user@contoso.com",
+		Application:      "MyApp",
+		Principal:        "aaduser=00000000-0000-0000-0000-000000000001;72f988bf-86f1-41af-91ab-2d7cd011db47",
+	}
+	truncated := completed
+	truncated.Text = "StormEvents"
+	truncated.TextTruncated = true
+
+	tests := []struct {
+		desc        string
+		running     bool
+		filter      QueriesFilter
+		wantCommand string
+		want        []QueryInfo
+	}{
+		{
+			desc:        "All",
+			wantCommand: `.show queries`,
+			want:        []QueryInfo{completed, failed},
+		},
+		{
+			desc:        "Since",
+			filter:      QueriesFilter{Since: 5 * time.Minute},
+			wantCommand: `.show queries | where StartedOn > ago(time(00:05:00))`,
+			want:        []QueryInfo{completed, failed},
+		},
+		{
+			desc:        "State",
+			filter:      QueriesFilter{State: "Failed"},
+			wantCommand: `.show queries`,
+			want:        []QueryInfo{failed},
+		},
+		{
+			desc:        "Application and limit",
+			filter:      QueriesFilter{Application: "Kusto.Explorer", Limit: 1, MaxTextLength: len("StormEvents")},
+			wantCommand: `.show queries`,
+			want:        []QueryInfo{truncated},
+		},
+		{
+			desc:        "Running",
+			running:     true,
+			filter:      QueriesFilter{User: "user@contoso.com"},
+			wantCommand: `.show running queries`,
+			want:        []QueryInfo{failed},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &mgmtTransport{body: fixture}
+			client := newTestClient(t, "https://show.kusto.windows.net", transport)
+
+			var got []QueryInfo
+			if test.running {
+				got, err = client.ShowRunningQueries(context.Background(), test.filter)
+			} else {
+				got, err = client.ShowQueries(context.Background(), "Samples", test.filter)
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+
+			require.Len(t, transport.commands, 1)
+			assert.Equal(t, test.wantCommand, transport.commands[0])
+		})
+	}
+
+	_, err = (&Client{}).ShowQueries(context.Background(), "Samples", QueriesFilter{Since: -time.Minute})
+	assert.Error(t, err)
+}
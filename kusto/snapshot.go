@@ -0,0 +1,92 @@
+package kusto
+
+// snapshot.go implements Client.SnapshotQuery(), which reads several statements at the same database cursor.
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// SnapshotWarningCode is the Code of the Warning added by SnapshotQuery() to the RowIterator of a statement that
+// does not call cursor_before_or_at().
+const SnapshotWarningCode = "SnapshotNotApplied"
+
+// SnapshotQuery runs every statement in stmts on database db as of the same point in time, and returns their
+// RowIterators by the same names. The current cursor of the database is read once, and every statement is sent with
+// it as the default argument of cursor_before_or_at(), see QueryCursorBeforeOrAtDefault(), so that the records
+// ingested after it are left out of all the results.
+//
+// A statement must call cursor_before_or_at() without arguments on each table it reads, such as
+// "Facts | where cursor_before_or_at()", as the client cannot tell which tables have the IngestionTime policy that
+// cursors require, nor where in the statement the filter is valid. A statement that does not call it is sent
+// unchanged, and its RowIterator has a Warning with the SnapshotWarningCode code.
+// The cursor is in the QueryCursorBeforeOrAtDefaultValue option of RowIterator.RequestProperties().
+//
+// If a statement fails, the RowIterators already returned are stopped and the error is returned. Otherwise every
+// RowIterator must be stopped.
+func (c *Client) SnapshotQuery(ctx context.Context, db string, stmts map[string]Stmt, options ...QueryOption) (map[string]*RowIterator, error) {
+	if len(stmts) == 0 {
+		return nil, errors.ES(errors.OpQuery, errors.KClientArgs, "SnapshotQuery() requires at least one statement").SetNoRetry()
+	}
+
+	cursor, err := c.CursorCurrent(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(stmts))
+	for name := range stmts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The cursor is set last, so that it wins over a QueryCursorBeforeOrAtDefault() in options.
+	options = append(options[:len(options):len(options)], QueryCursorBeforeOrAtDefault(cursor))
+
+	iters := make(map[string]*RowIterator, len(stmts))
+	for _, name := range names {
+		stmt := stmts[name]
+		iter, err := c.Query(ctx, db, stmt, options...)
+		if err != nil {
+			for _, iter := range iters {
+				iter.Stop()
+			}
+			return nil, snapshotErr(name, err)
+		}
+		if !strings.Contains(stmt.String(), "cursor_before_or_at(") {
+			iter.mu.Lock()
+			iter.addWarnings([]Warning{{
+				Timestamp: nower(),
+				Code:      SnapshotWarningCode,
+				Message:   fmt.Sprintf("statement %q does not call cursor_before_or_at(), so its results are not limited to cursor %s", name, cursor),
+			}})
+			iter.mu.Unlock()
+		}
+		iters[name] = iter
+	}
+	return iters, nil
+}
+
+// snapshotErr wraps the error of the statement name, keeping its Kind and whether it can be retried.
+func snapshotErr(name string, err error) error {
+	kind := errors.KOther
+	var kErr *errors.Error
+	var httpErr *errors.HttpError
+	switch {
+	case goErrors.As(err, &httpErr):
+		kind = httpErr.Kind
+	case goErrors.As(err, &kErr):
+		kind = kErr.Kind
+	}
+
+	wrapped := errors.E(errors.OpQuery, kind, fmt.Errorf("statement %q of the snapshot failed: %w", name, err))
+	if !errors.Retry(err) {
+		wrapped.SetNoRetry()
+	}
+	return wrapped
+}
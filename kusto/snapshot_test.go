@@ -0,0 +1,175 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotTransport is a fake http.RoundTripper for a database whose current cursor is cursor. Statements on the
+// Broken table fail.
+type snapshotTransport struct {
+	cursor string
+
+	mu   sync.Mutex
+	msgs []queryMsg
+}
+
+func (s *snapshotTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/v2/rest/query") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	var msg queryMsg
+	if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.msgs = append(s.msgs, msg)
+	s.mu.Unlock()
+
+	if strings.HasPrefix(msg.CSL, "Broken") {
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Status:     "400 Bad Request",
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"Failed to resolve table 'Broken'"}}`)),
+		}, nil
+	}
+
+	columns := `[{"ColumnName":"x","ColumnType":"long"}]`
+	rows := `[1],[2]`
+	if strings.Contains(msg.CSL, "cursor_current()") {
+		columns = `[{"ColumnName":"kusto_cursor","ColumnType":"string"}]`
+		rows = `["` + s.cursor + `"]`
+	}
+	body := `[{"FrameType":"dataSetHeader","IsProgressive":false,"Version":"v2.0"},` +
+		`{"FrameType":"DataTable","TableId":1,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+		`"Columns":` + columns + `,"Rows":[` + rows + `]},` +
+		`{"FrameType":"DataSetCompletion","HasErrors":false,"Cancelled":false}]`
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func (s *snapshotTransport) sent() []queryMsg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]queryMsg(nil), s.msgs...)
+}
+
+func TestSnapshotQuery(t *testing.T) {
+	t.Parallel()
+
+	transport := &snapshotTransport{cursor: "638400000000000000"}
+	client := newTestClient(t, "https://snapshot.kusto.windows.net", transport)
+
+	stmts := map[string]Stmt{
+		"facts":   NewStmt("Facts | where cursor_before_or_at()"),
+		"dims":    NewStmt("Dims | where cursor_before_or_at()"),
+		"mapping": NewStmt("Mapping"),
+	}
+	// The cursor of the snapshot wins over the one in the options.
+	iters, err := client.SnapshotQuery(context.Background(), "db", stmts, QueryCursorBeforeOrAtDefault("1"), NoTruncation())
+	require.NoError(t, err)
+	require.Len(t, iters, 3)
+
+	// The cursor is read once, and every statement is sent unchanged with the same cursor.
+	sent := transport.sent()
+	require.Len(t, sent, 4)
+	assert.Equal(t, "print kusto_cursor = cursor_current()", sent[0].CSL)
+	var csls []string
+	for _, msg := range sent[1:] {
+		csls = append(csls, msg.CSL)
+		assert.Equal(t, transport.cursor, msg.Properties.Options[QueryCursorBeforeOrAtDefaultValue], "statement %q", msg.CSL)
+		assert.Equal(t, true, msg.Properties.Options[NoTruncationValue])
+	}
+	assert.ElementsMatch(t, []string{stmts["facts"].String(), stmts["dims"].String(), stmts["mapping"].String()}, csls)
+
+	for name, iter := range iters {
+		assert.Equal(t, transport.cursor, iter.RequestProperties().Options[QueryCursorBeforeOrAtDefaultValue])
+
+		count := 0
+		require.NoError(t, iter.Do(func(*table.Row) error {
+			count++
+			return nil
+		}))
+		assert.Equal(t, 2, count)
+		iter.Stop()
+
+		// The statement that does not use the cursor is warned about.
+		warnings := iter.Warnings()
+		if name != "mapping" {
+			assert.Empty(t, warnings, name)
+			continue
+		}
+		require.Len(t, warnings, 1)
+		assert.Equal(t, SnapshotWarningCode, warnings[0].Code)
+		assert.Contains(t, warnings[0].Message, transport.cursor)
+	}
+}
+
+func TestSnapshotQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	transport := &snapshotTransport{cursor: "42"}
+	client := newTestClient(t, "https://snapshot.kusto.windows.net", transport)
+
+	_, err := client.SnapshotQuery(context.Background(), "db", nil)
+	assert.Error(t, err)
+	assert.Empty(t, transport.sent())
+
+	// "a" is sent before "b", which fails.
+	_, err = client.SnapshotQuery(context.Background(), "db", map[string]Stmt{
+		"a": NewStmt("A | where cursor_before_or_at()"),
+		"b": NewStmt("Broken | where cursor_before_or_at()"),
+	})
+	var httpErr *errors.HttpError
+	require.True(t, goErrors.As(err, &httpErr), "got %T: %s", err, err)
+	assert.Contains(t, err.Error(), `"b"`)
+	assert.Len(t, transport.sent(), 3)
+	// The error keeps the Kind of the failure of the statement, and whether it can be retried.
+	var kErr *errors.Error
+	require.True(t, goErrors.As(err, &kErr), "got %T: %s", err, err)
+	assert.Equal(t, errors.KHTTPError, kErr.Kind)
+	assert.Equal(t, errors.Retry(httpErr), errors.Retry(err))
+
+	tests := []struct {
+		desc      string
+		err       error
+		wantKind  errors.Kind
+		wantRetry bool
+	}{
+		{
+			desc:      "Retryable",
+			err:       errors.ES(errors.OpQuery, errors.KTimeout, "timeout"),
+			wantKind:  errors.KTimeout,
+			wantRetry: true,
+		},
+		{
+			desc:     "Not retryable",
+			err:      errors.ES(errors.OpQuery, errors.KTimeout, "timeout").SetNoRetry(),
+			wantKind: errors.KTimeout,
+		},
+		{
+			desc:     "Not an *errors.Error",
+			err:      goErrors.New("failed"),
+			wantKind: errors.KOther,
+		},
+	}
+	for _, test := range tests {
+		err := snapshotErr("b", test.err)
+		require.True(t, goErrors.As(err, &kErr), "%s: got %T: %s", test.desc, err, err)
+		assert.Equal(t, test.wantKind, kErr.Kind, test.desc)
+		assert.Equal(t, test.wantRetry, errors.Retry(err), test.desc)
+		assert.ErrorIs(t, err, test.err, test.desc)
+	}
+}
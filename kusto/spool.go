@@ -0,0 +1,517 @@
+package kusto
+
+// spool.go implements spooling the results of a RowIterator to a temporary file, so that large results can be fully
+// received without holding them in memory.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/google/uuid"
+)
+
+// Record types in a spool file.
+const (
+	spoolRow         byte = 0
+	spoolReplaceRow  byte = 1
+	spoolInlineError byte = 2
+)
+
+// SpooledIterator replays rows that RowIterator.Spool() wrote to a temporary file.
+// It provides the same methods to read rows as RowIterator, and can be rewound to read the rows again.
+// Close() must be called to remove the temporary file. SpooledIterator is not safe for concurrent use.
+type SpooledIterator struct {
+	// RequestHeader is the http.Header sent in the request to the server.
+	RequestHeader http.Header
+	// ResponseHeader is the http.header sent in the response from the server.
+	ResponseHeader http.Header
+
+	op      errors.Op
+	columns table.Columns
+	file    *os.File
+	// removed is true if the file was unlinked when created, which is only possible on some platforms.
+	removed bool
+	reader  *bufio.Reader
+	buf     []byte
+	rows    int
+	// rowCount is the number of rows in the spool, without the inline errors.
+	rowCount int64
+	closed   bool
+	closeMu  sync.Mutex
+}
+
+// Spool reads every row of the query into a temporary file in dir, then releases the connection to the server.
+// If dir is empty, the default directory for temporary files is used.
+// The returned SpooledIterator replays the rows from the file, which is removed when it is closed.
+// If the process exits before the SpooledIterator is closed, the file is removed where the platform allows
+// removing open files, otherwise it is left behind.
+// The RowIterator must not have been read from before calling Spool() and cannot be used afterwards.
+// If the query fails, the error is returned and no file is left behind.
+func (r *RowIterator) Spool(dir string) (*SpooledIterator, error) {
+	defer r.Stop()
+
+	f, err := os.CreateTemp(dir, "kusto-spool-*")
+	if err != nil {
+		return nil, errors.E(r.op, errors.KLocalFileSystem, fmt.Errorf("could not create the spool file: %w", err))
+	}
+
+	s := &SpooledIterator{
+		RequestHeader:  r.RequestHeader,
+		ResponseHeader: r.ResponseHeader,
+		op:             r.op,
+		file:           f,
+	}
+	// Unlinking the file while it is open makes sure it doesn't outlive the process. This fails on Windows, in which
+	// case the finalizer is the best effort.
+	s.removed = os.Remove(f.Name()) == nil
+	runtime.SetFinalizer(s, (*SpooledIterator).Close)
+
+	if err := s.write(r); err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.columns = r.columns
+
+	if err := s.Rewind(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// write drains r into the spool file.
+func (s *SpooledIterator) write(r *RowIterator) error {
+	w := bufio.NewWriterSize(s.file, 64*1024)
+	enc := spoolEncoder{w: w}
+
+	for {
+		row, inlineErr, err := r.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		if inlineErr != nil {
+			enc.byte(spoolInlineError)
+			enc.uvarint(uint64(inlineErr.Kind))
+			msg := ""
+			if inlineErr.Err != nil {
+				msg = inlineErr.Err.Error()
+			}
+			enc.bytes([]byte(msg))
+		} else {
+			if row.Replace {
+				enc.byte(spoolReplaceRow)
+			} else {
+				enc.byte(spoolRow)
+			}
+			enc.uvarint(uint64(len(row.Values)))
+			for _, v := range row.Values {
+				enc.value(v)
+			}
+			s.rowCount++
+		}
+		if enc.err != nil {
+			return errors.E(s.op, errors.KLocalFileSystem, fmt.Errorf("could not write to the spool file: %w", enc.err))
+		}
+		s.rows++
+	}
+
+	if err := w.Flush(); err != nil {
+		return errors.E(s.op, errors.KLocalFileSystem, fmt.Errorf("could not write to the spool file: %w", err))
+	}
+	return nil
+}
+
+// Len returns the number of rows and inline errors in the spool.
+func (s *SpooledIterator) Len() int {
+	return s.rows
+}
+
+// RowCount returns the number of rows in the spool, not counting inline errors.
+func (s *SpooledIterator) RowCount() int64 {
+	return s.rowCount
+}
+
+// Columns returns the columns of the rows.
+func (s *SpooledIterator) Columns() table.Columns {
+	return s.columns
+}
+
+// Rewind starts reading the rows from the beginning again.
+func (s *SpooledIterator) Rewind() error {
+	if s.closed {
+		return errors.ES(s.op, errors.KClientArgs, "cannot Rewind() a SpooledIterator after Close()")
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return errors.E(s.op, errors.KLocalFileSystem, fmt.Errorf("could not rewind the spool file: %w", err))
+	}
+	if s.reader == nil {
+		s.reader = bufio.NewReaderSize(s.file, 64*1024)
+	} else {
+		s.reader.Reset(s.file)
+	}
+	return nil
+}
+
+// Do calls f for every row returned by the query. If f returns a non-nil error,
+// iteration stops. This method will fail on errors inline within the rows.
+func (s *SpooledIterator) Do(f func(r *table.Row) error) error {
+	for {
+		row, err := s.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row); err != nil {
+			return err
+		}
+	}
+}
+
+// DoOnRowOrError calls f for every row or inline error returned by the query. If f returns a non-nil error,
+// iteration stops.
+func (s *SpooledIterator) DoOnRowOrError(f func(r *table.Row, e *errors.Error) error) error {
+	for {
+		row, inlineErr, err := s.NextRowOrError()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := f(row, inlineErr); err != nil {
+			return err
+		}
+	}
+}
+
+// Next gets the next Row. io.EOF is returned if there are no more rows.
+// This method will fail on errors inline within the rows.
+func (s *SpooledIterator) Next() (row *table.Row, finalError error) {
+	row, inlineErr, err := s.NextRowOrError()
+	if err != nil {
+		return nil, err
+	}
+	if inlineErr != nil {
+		return nil, inlineErr
+	}
+	return row, nil
+}
+
+// NextRowOrError gets the next Row or service-side error. io.EOF is returned if there are no more rows.
+func (s *SpooledIterator) NextRowOrError() (row *table.Row, inlineError *errors.Error, finalError error) {
+	if s.closed {
+		return nil, nil, errors.ES(s.op, errors.KClientArgs, "cannot read from a SpooledIterator after Close()")
+	}
+
+	dec := spoolDecoder{r: s.reader, buf: s.buf}
+	defer func() { s.buf = dec.buf }()
+
+	kind, err := s.reader.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, errors.E(s.op, errors.KLocalFileSystem, fmt.Errorf("could not read the spool file: %w", err))
+	}
+
+	switch kind {
+	case spoolInlineError:
+		errKind := errors.Kind(dec.uvarint())
+		msg := string(dec.bytes())
+		if dec.err != nil {
+			return nil, nil, s.readErr(dec.err)
+		}
+		return nil, errors.ES(s.op, errKind, "%s", msg), nil
+	case spoolRow, spoolReplaceRow:
+		n := dec.uvarint()
+		values := make(value.Values, 0, n)
+		for i := uint64(0); i < n && dec.err == nil; i++ {
+			values = append(values, dec.value())
+		}
+		if dec.err != nil {
+			return nil, nil, s.readErr(dec.err)
+		}
+		return &table.Row{ColumnTypes: s.columns, Values: values, Op: s.op, Replace: kind == spoolReplaceRow}, nil, nil
+	}
+	return nil, nil, s.readErr(fmt.Errorf("unknown record type %d", kind))
+}
+
+func (s *SpooledIterator) readErr(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.E(s.op, errors.KLocalFileSystem, fmt.Errorf("could not read the spool file: %w", err))
+}
+
+// Close closes and removes the spool file. It is safe to call Close more than once.
+func (s *SpooledIterator) Close() error {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	runtime.SetFinalizer(s, nil)
+
+	err := s.file.Close()
+	if !s.removed {
+		if rmErr := os.Remove(s.file.Name()); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	if err != nil {
+		return errors.E(s.op, errors.KLocalFileSystem, fmt.Errorf("could not remove the spool file: %w", err))
+	}
+	return nil
+}
+
+// Value tags in a spool file. The tag of a null value has the spoolNull bit set.
+const (
+	spoolBool byte = iota + 1
+	spoolInt
+	spoolLong
+	spoolReal
+	spoolDecimal
+	spoolString
+	spoolDynamic
+	spoolDateTime
+	spoolTimespan
+	spoolGUID
+
+	spoolNull byte = 0x80
+)
+
+// spoolEncoder writes values, keeping the first error that occurred.
+type spoolEncoder struct {
+	w       *bufio.Writer
+	scratch [binary.MaxVarintLen64]byte
+	err     error
+}
+
+func (e *spoolEncoder) byte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+func (e *spoolEncoder) uvarint(u uint64) {
+	if e.err == nil {
+		n := binary.PutUvarint(e.scratch[:], u)
+		_, e.err = e.w.Write(e.scratch[:n])
+	}
+}
+
+func (e *spoolEncoder) varint(i int64) {
+	if e.err == nil {
+		n := binary.PutVarint(e.scratch[:], i)
+		_, e.err = e.w.Write(e.scratch[:n])
+	}
+}
+
+func (e *spoolEncoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *spoolEncoder) tag(t byte, valid bool) bool {
+	if !valid {
+		e.byte(t | spoolNull)
+		return false
+	}
+	e.byte(t)
+	return true
+}
+
+func (e *spoolEncoder) value(v value.Kusto) {
+	switch v := v.(type) {
+	case value.Bool:
+		if e.tag(spoolBool, v.Valid) {
+			if v.Value {
+				e.byte(1)
+			} else {
+				e.byte(0)
+			}
+		}
+	case value.Int:
+		if e.tag(spoolInt, v.Valid) {
+			e.varint(int64(v.Value))
+		}
+	case value.Long:
+		if e.tag(spoolLong, v.Valid) {
+			e.varint(v.Value)
+		}
+	case value.Real:
+		if e.tag(spoolReal, v.Valid) {
+			e.uvarint(math.Float64bits(v.Value))
+		}
+	case value.Decimal:
+		if e.tag(spoolDecimal, v.Valid) {
+			e.bytes([]byte(v.Value))
+		}
+	case value.String:
+		if e.tag(spoolString, v.Valid) {
+			e.bytes([]byte(v.Value))
+		}
+	case value.Dynamic:
+		if e.tag(spoolDynamic, v.Valid) {
+			e.bytes(v.Value)
+		}
+	case value.DateTime:
+		if e.tag(spoolDateTime, v.Valid) {
+			b, err := v.Value.MarshalBinary()
+			if err != nil && e.err == nil {
+				e.err = err
+			}
+			e.bytes(b)
+		}
+	case value.Timespan:
+		if e.tag(spoolTimespan, v.Valid) {
+			e.varint(int64(v.Value))
+		}
+	case value.GUID:
+		if e.tag(spoolGUID, v.Valid) {
+			e.bytes(v.Value[:])
+		}
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("cannot spool a value of type %T", v)
+		}
+	}
+}
+
+// spoolDecoder reads values, keeping the first error that occurred.
+type spoolDecoder struct {
+	r   *bufio.Reader
+	buf []byte
+	err error
+}
+
+func (d *spoolDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *spoolDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var u uint64
+	u, d.err = binary.ReadUvarint(d.r)
+	return u
+}
+
+func (d *spoolDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var i int64
+	i, d.err = binary.ReadVarint(d.r)
+	return i
+}
+
+// bytes reads a length prefixed byte slice. The slice is only valid until the next call.
+func (d *spoolDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if uint64(cap(d.buf)) < n {
+		d.buf = make([]byte, n)
+	}
+	b := d.buf[:n]
+	_, d.err = io.ReadFull(d.r, b)
+	return b
+}
+
+func (d *spoolDecoder) value() value.Kusto {
+	t := d.byte()
+	valid := t&spoolNull == 0
+	t &^= spoolNull
+
+	switch t {
+	case spoolBool:
+		if !valid {
+			return value.Bool{}
+		}
+		return value.Bool{Value: d.byte() == 1, Valid: true}
+	case spoolInt:
+		if !valid {
+			return value.Int{}
+		}
+		return value.Int{Value: int32(d.varint()), Valid: true}
+	case spoolLong:
+		if !valid {
+			return value.Long{}
+		}
+		return value.Long{Value: d.varint(), Valid: true}
+	case spoolReal:
+		if !valid {
+			return value.Real{}
+		}
+		return value.Real{Value: math.Float64frombits(d.uvarint()), Valid: true}
+	case spoolDecimal:
+		if !valid {
+			return value.Decimal{}
+		}
+		return value.Decimal{Value: string(d.bytes()), Valid: true}
+	case spoolString:
+		if !valid {
+			return value.String{}
+		}
+		return value.String{Value: string(d.bytes()), Valid: true}
+	case spoolDynamic:
+		if !valid {
+			return value.Dynamic{}
+		}
+		return value.Dynamic{Value: append([]byte(nil), d.bytes()...), Valid: true}
+	case spoolDateTime:
+		if !valid {
+			return value.DateTime{}
+		}
+		var t time.Time
+		if b := d.bytes(); d.err == nil {
+			d.err = t.UnmarshalBinary(b)
+		}
+		return value.DateTime{Value: t, Valid: true}
+	case spoolTimespan:
+		if !valid {
+			return value.Timespan{}
+		}
+		return value.Timespan{Value: time.Duration(d.varint()), Valid: true}
+	case spoolGUID:
+		if !valid {
+			return value.GUID{}
+		}
+		var g uuid.UUID
+		copy(g[:], d.bytes())
+		return value.GUID{Value: g, Valid: true}
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("unknown value type %d", t)
+	}
+	return nil
+}
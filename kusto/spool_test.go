@@ -0,0 +1,207 @@
+package kusto
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spoolIterator returns a RowIterator over a progressive stream that generates the fragments as they are read.
+func spoolIterator(columns table.Columns, fragments func(send func(fr v2.TableFragment))) *RowIterator {
+	toSM := make(chan frames.Frame)
+	ready := make(chan struct{})
+	go func() {
+		defer close(toSM)
+		toSM <- v2.TableHeader{Base: v2.Base{FrameType: frames.TypeTableHeader}, TableKind: frames.PrimaryResult, Columns: columns}
+		// The RowIterator may take rows before the columns, and fragments larger than its buffer would then block
+		// until the rows are read, which never happens before the columns are ready.
+		<-ready
+		fragments(func(fr v2.TableFragment) { toSM <- fr })
+		toSM <- v2.TableCompletion{}
+		toSM <- v2.DataSetCompletion{}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iter, columnsReady := newRowIterator(ctx, cancel, execResp{}, v2.DataSetHeader{IsProgressive: true}, errors.OpQuery)
+	go runSM(&progressiveSM{op: errors.OpQuery, iter: iter, in: toSM, ctx: ctx, wg: &sync.WaitGroup{}})
+	<-columnsReady
+	close(ready)
+	return iter
+}
+
+func TestSpoolValues(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+	columns := table.Columns{
+		{Name: "Bool", Type: "bool"},
+		{Name: "Int", Type: "int"},
+		{Name: "Long", Type: "long"},
+		{Name: "Real", Type: "real"},
+		{Name: "Decimal", Type: "decimal"},
+		{Name: "String", Type: "string"},
+		{Name: "Dynamic", Type: "dynamic"},
+		{Name: "DateTime", Type: "datetime"},
+		{Name: "Timespan", Type: "timespan"},
+		{Name: "GUID", Type: "guid"},
+	}
+	rows := []value.Values{
+		{
+			value.Bool{Value: true, Valid: true},
+			value.Int{Value: -2, Valid: true},
+			value.Long{Value: 1 << 40, Valid: true},
+			value.Real{Value: 3.14, Valid: true},
+			value.Decimal{Value: "1.5", Valid: true},
+			value.String{Value: "hello", Valid: true},
+			value.Dynamic{Value: []byte(`{"a":1}`), Valid: true},
+			value.DateTime{Value: now, Valid: true},
+			value.Timespan{Value: time.Hour, Valid: true},
+			value.GUID{Value: uuid.MustParse("011e7e1b-3c8f-4e91-a04b-0fa5f7be6100"), Valid: true},
+		},
+		{
+			value.Bool{}, value.Int{}, value.Long{}, value.Real{}, value.Decimal{}, value.String{}, value.Dynamic{},
+			value.DateTime{}, value.Timespan{}, value.GUID{},
+		},
+	}
+
+	iter := spoolIterator(columns, func(send func(fr v2.TableFragment)) {
+		send(v2.TableFragment{KustoRows: rows[:1], RowErrors: []errors.Error{*errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Some error")}})
+		send(v2.TableFragment{KustoRows: rows[1:], TableFragmentType: "DataReplace"})
+	})
+
+	dir := t.TempDir()
+	spooled, err := iter.Spool(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, spooled.Len())
+	assert.Equal(t, int64(2), spooled.RowCount())
+	assert.Equal(t, columns, spooled.Columns())
+
+	for i := 0; i < 2; i++ {
+		var got []value.Values
+		var replace []bool
+		var inlineErrs []*errors.Error
+		err = spooled.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+			if e != nil {
+				inlineErrs = append(inlineErrs, e)
+				return nil
+			}
+			got = append(got, r.Values)
+			replace = append(replace, r.Replace)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, rows, got)
+		assert.Equal(t, []bool{false, true}, replace)
+		require.Len(t, inlineErrs, 1)
+		assert.Equal(t, errors.KLimitsExceeded, inlineErrs[0].Kind)
+		assert.Equal(t, "Some error", inlineErrs[0].Err.Error())
+
+		require.NoError(t, spooled.Rewind())
+	}
+
+	require.NoError(t, spooled.Close())
+	require.NoError(t, spooled.Close())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, _, err = spooled.NextRowOrError()
+	assert.Error(t, err)
+}
+
+func TestSpoolMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spools a million rows")
+	}
+
+	const (
+		rows         = 1000000
+		fragmentSize = 1000
+		ceiling      = 64 * 1024 * 1024
+	)
+
+	columns := table.Columns{
+		{Name: "ID", Type: "long"},
+		{Name: "Name", Type: "string"},
+		{Name: "Timestamp", Type: "datetime"},
+	}
+	now := time.Now().UTC()
+
+	runtime.GC()
+	var peak atomic.Uint64
+	stop := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		var m runtime.MemStats
+		for {
+			runtime.ReadMemStats(&m)
+			if m.HeapAlloc > peak.Load() {
+				peak.Store(m.HeapAlloc)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	iter := spoolIterator(columns, func(send func(fr v2.TableFragment)) {
+		for i := 0; i < rows; i += fragmentSize {
+			fragment := make([]value.Values, fragmentSize)
+			for j := range fragment {
+				fragment[j] = value.Values{
+					value.Long{Value: int64(i + j), Valid: true},
+					value.String{Value: "a row with a name long enough to matter", Valid: true},
+					value.DateTime{Value: now, Valid: true},
+				}
+			}
+			send(v2.TableFragment{KustoRows: fragment})
+		}
+	})
+
+	spooled, err := iter.Spool(t.TempDir())
+	require.NoError(t, err)
+	defer spooled.Close()
+
+	type rec struct {
+		ID        int64
+		Name      string
+		Timestamp time.Time
+	}
+	n := 0
+	for {
+		row, err := spooled.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		var r rec
+		require.NoError(t, row.ToStruct(&r))
+		if r.ID != int64(n) {
+			require.Equal(t, int64(n), r.ID)
+		}
+		n++
+	}
+	close(stop)
+	<-sampled
+
+	assert.Equal(t, rows, n)
+	assert.Less(t, peak.Load(), uint64(ceiling))
+}
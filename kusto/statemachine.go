@@ -9,7 +9,7 @@ import (
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
-	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
 	v1 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v1"
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
 )
@@ -22,11 +22,16 @@ type stateMachine interface {
 	// start starts the stateMachine and returns either the next state to run, an error, or nil, nil.
 	start() (stateFn, error)
 	rowIter() *RowIterator
+	// frames returns the frames the stateMachine reads.
+	frames() <-chan frames.Frame
 }
 
 // runSM runs a stateMachine to its conclusion.
 func runSM(sm stateMachine) {
 	defer close(sm.rowIter().inRows)
+	// Nothing reads the frames once the stateMachine is done, such as when the RowIterator was stopped. They are
+	// drained until the decoder, which stops reading the response once the query context is done, closes the channel.
+	defer func() { go drainFrames(sm.frames()) }()
 
 	var fn = sm.start
 	var err error
@@ -42,14 +47,22 @@ func runSM(sm stateMachine) {
 	}
 }
 
+// drainFrames reads ch until it is closed.
+func drainFrames(ch <-chan frames.Frame) {
+	for range ch {
+	}
+}
+
 // nonProgressiveSM implements a stateMachine that processes Kusto data that is not non-streaming.
 type nonProgressiveSM struct {
 	op            errors.Op
 	iter          *RowIterator
-	in            chan frames.Frame
+	in            <-chan frames.Frame
 	columnSetOnce sync.Once
 	ctx           context.Context
 	hasCompletion bool
+	// tables is the number of primary tables received.
+	tables int
 
 	wg *sync.WaitGroup // Used to know when everything has finished
 }
@@ -62,6 +75,10 @@ func (d *nonProgressiveSM) rowIter() *RowIterator {
 	return d.iter
 }
 
+func (d *nonProgressiveSM) frames() <-chan frames.Frame {
+	return d.in
+}
+
 func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 	// These are two separate select cases since we always want to check for context cancellation first, otherwise order is not guaranteed.
 
@@ -87,6 +104,7 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 		if d.hasCompletion {
 			return nil, errors.ES(d.op, errors.KInternal, "saw a DataSetCompletion frame, then received a %T frame", fr)
 		}
+		d.iter.stats.frames.Add(1)
 
 		switch table := fr.(type) {
 		case v2.DataTable:
@@ -98,10 +116,13 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 					d.iter.inColumns <- send{inColumns: table.Columns, wg: d.wg}
 				})
 
+				tbl := &primaryTable{index: d.tables, columns: table.Columns}
+				d.tables++
+
 				select {
 				case <-d.ctx.Done():
 					return nil, d.ctx.Err()
-				case d.iter.inRows <- send{inRows: table.KustoRows, inRowErrors: table.RowErrors, wg: d.wg}:
+				case d.iter.inRows <- send{inTable: tbl, inRows: table.KustoRows, inRowErrors: table.RowErrors, wg: d.wg}:
 				}
 			default:
 				select {
@@ -113,6 +134,16 @@ func (d *nonProgressiveSM) process() (sf stateFn, err error) {
 		case frames.Error:
 			return nil, table
 		case v2.DataSetCompletion:
+			// Errors after some results were sent are returned inline, after the rows.
+			if errs := v2.CompletionErrors(table, d.op); len(errs) > 0 {
+				d.wg.Add(1)
+				select {
+				case <-d.ctx.Done():
+					return nil, d.ctx.Err()
+				case d.iter.inRows <- send{inRowErrors: errs, inCompletionErrors: true, wg: d.wg}:
+				}
+			}
+
 			d.wg.Add(1)
 
 			select {
@@ -158,13 +189,17 @@ Either:
 type progressiveSM struct {
 	op            errors.Op
 	iter          *RowIterator
-	in            chan frames.Frame
+	in            <-chan frames.Frame
 	columnSetOnce sync.Once
 	ctx           context.Context
 
 	currentHeader *v2.TableHeader
 	currentFrame  frames.Frame
 	nonPrimary    *v2.DataTable
+	// tables is the number of primary tables received.
+	tables int
+	// notifier receives the progress of the tables, see WithProgressCallback(). It is nil if there is no callback.
+	notifier *progressNotifier
 
 	wg *sync.WaitGroup
 }
@@ -177,6 +212,10 @@ func (p *progressiveSM) rowIter() *RowIterator {
 	return p.iter
 }
 
+func (p *progressiveSM) frames() <-chan frames.Frame {
+	return p.in
+}
+
 func (p *progressiveSM) nextFrame() (stateFn, error) {
 	// These are two separate select cases since we always want to check for context cancellation first, otherwise order is not guaranteed.
 
@@ -193,6 +232,7 @@ func (p *progressiveSM) nextFrame() (stateFn, error) {
 		if !ok {
 			return nil, errors.ES(p.op, errors.KInternal, "received a table stream that did not finish before our input channel, this is usually a return size or time limit")
 		}
+		p.iter.stats.frames.Add(1)
 
 		p.currentFrame = fr
 		switch table := fr.(type) {
@@ -237,6 +277,16 @@ func (p *progressiveSM) dataTable() (stateFn, error) {
 }
 
 func (p *progressiveSM) dataSetCompletion() (stateFn, error) {
+	// Errors after some results were sent are returned inline, after the rows.
+	if errs := v2.CompletionErrors(p.currentFrame.(v2.DataSetCompletion), p.op); len(errs) > 0 {
+		p.wg.Add(1)
+		select {
+		case <-p.ctx.Done():
+			return nil, p.ctx.Err()
+		case p.iter.inRows <- send{inRowErrors: errs, inCompletionErrors: true, wg: p.wg}:
+		}
+	}
+
 	p.wg.Add(1)
 
 	select {
@@ -265,6 +315,16 @@ func (p *progressiveSM) tableHeader() (stateFn, error) {
 			p.wg.Add(1)
 			p.iter.inColumns <- send{inColumns: table.Columns, wg: p.wg}
 		})
+
+		// The start of the table is sent on its own, so that a table without fragments is seen.
+		tbl := &primaryTable{index: p.tables, columns: table.Columns}
+		p.tables++
+		p.wg.Add(1)
+		select {
+		case <-p.ctx.Done():
+			return nil, p.ctx.Err()
+		case p.iter.inRows <- send{inTable: tbl, wg: p.wg}:
+		}
 	} else {
 		p.nonPrimary = &v2.DataTable{
 			Base:      v2.Base{FrameType: frames.TypeDataTable},
@@ -293,7 +353,9 @@ func (p *progressiveSM) fragment() (stateFn, error) {
 		case p.iter.inRows <- send{inRows: table.KustoRows, inRowErrors: table.RowErrors, inTableFragmentType: table.TableFragmentType, wg: p.wg}:
 		}
 	} else {
-		p.nonPrimary.Rows = append(p.nonPrimary.Rows, p.currentFrame.(v2.TableFragment).Rows...)
+		fragment := p.currentFrame.(v2.TableFragment)
+		p.nonPrimary.KustoRows = append(p.nonPrimary.KustoRows, fragment.KustoRows...)
+		p.nonPrimary.RowErrors = append(p.nonPrimary.RowErrors, fragment.RowErrors...)
 	}
 	return p.nextFrame, nil
 }
@@ -302,8 +364,10 @@ func (p *progressiveSM) progress() (stateFn, error) {
 	if p.currentHeader == nil {
 		return nil, errors.ES(p.op, errors.KInternal, "received a TableProgress without a tableHeader")
 	}
+	progress := p.currentFrame.(v2.TableProgress)
+	p.notifier.notify(progressUpdate{tableOrdinal: int64(progress.TableID), percent: progress.TableProgress})
 	p.wg.Add(1)
-	p.iter.inProgress <- send{inProgress: p.currentFrame.(v2.TableProgress), wg: p.wg}
+	p.iter.inProgress <- send{inProgress: progress, wg: p.wg}
 	return p.nextFrame, nil
 }
 
@@ -317,6 +381,7 @@ func (p *progressiveSM) completion() (stateFn, error) {
 		p.wg.Add(1)
 		p.iter.inNonPrimary <- send{inNonPrimary: *p.nonPrimary, wg: p.wg}
 	}
+	p.notifier.notify(progressUpdate{tableOrdinal: int64(p.currentHeader.TableID), percent: 100, complete: true})
 	p.nonPrimary = nil
 	p.currentHeader = nil
 	p.currentFrame = nil
@@ -328,12 +393,14 @@ func (p *progressiveSM) completion() (stateFn, error) {
 type v1SM struct {
 	op            errors.Op
 	iter          *RowIterator
-	in            chan frames.Frame
+	in            <-chan frames.Frame
 	columnSetOnce sync.Once
 	ctx           context.Context
 
 	currentTable v1.DataTable
 	tables       []v1.DataTable
+	// primaryTables is the number of primary tables sent.
+	primaryTables int
 
 	receivedDT bool
 
@@ -356,6 +423,10 @@ func (p *v1SM) rowIter() *RowIterator {
 	return p.iter
 }
 
+func (p *v1SM) frames() <-chan frames.Frame {
+	return p.in
+}
+
 func (p *v1SM) nextFrame() (stateFn, error) {
 	// These are two separate select cases since we always want to check for context cancellation first, otherwise order is not guaranteed.
 
@@ -383,6 +454,7 @@ func (p *v1SM) nextFrame() (stateFn, error) {
 		}
 		switch tbl := fr.(type) {
 		case v1.DataTable:
+			p.iter.stats.frames.Add(1)
 			p.tables = append(p.tables, tbl)
 			return p.nextFrame, nil
 		case frames.Error:
@@ -408,44 +480,103 @@ func (p *v1SM) tableOfContents() (stateFn, error) {
 		return nil, err
 	}
 
-	current := TableOfContents{}
+	contents := make([]TableOfContents, 0, len(tableOfContents.KustoRows))
 	for _, kustoRow := range tableOfContents.KustoRows {
+		current := TableOfContents{}
 		row := table.Row{ColumnTypes: columns, Values: kustoRow, Op: p.op}
 		err := row.ToStruct(&current)
 		if err != nil {
 			return nil, err
 		}
+		if current.Ordinal < 0 || current.Ordinal >= int64(len(p.tables)) {
+			return nil, errors.ES(p.op, errors.KInternal, "the table of contents refers to table %d, but there are %d tables", current.Ordinal, len(p.tables))
+		}
+		contents = append(contents, current)
+	}
+
+	// The query properties are sent first, so that they are available as soon as the rows are, like in v2.
+	for _, current := range contents {
+		if frames.TableKind(current.Kind) == frames.QueryProperties {
+			if _, err := p.nonPrimary(current); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-		kind := frames.TableKind(current.Kind)
-		if kind == frames.QueryResult {
+	for _, current := range contents {
+		if frames.TableKind(current.Kind) == frames.QueryResult {
 			p.currentTable = p.tables[current.Ordinal]
 			if _, err := p.dataTable(); err != nil {
 				return nil, err
 			}
 		}
 	}
+
+	// The other tables, such as the query status, are sent in their order, followed by the table of contents.
+	for _, current := range contents {
+		switch frames.TableKind(current.Kind) {
+		case frames.QueryProperties, frames.QueryResult:
+		default:
+			if _, err := p.nonPrimary(current); err != nil {
+				return nil, err
+			}
+		}
+	}
+	toc := TableOfContents{Ordinal: int64(len(p.tables) - 1), Kind: string(frames.TableOfContents), Name: string(frames.TableOfContents)}
+	if _, err := p.nonPrimary(toc); err != nil {
+		return nil, err
+	}
+	return p.done, nil
+}
+
+// nonPrimary sends the table described by current to the RowIterator as a non-primary table.
+func (p *v1SM) nonPrimary(current TableOfContents) (stateFn, error) {
+	tbl := p.tables[current.Ordinal]
+	cols, err := tbl.DataTypes.ToColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	p.wg.Add(1)
+	select {
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	case p.iter.inNonPrimary <- send{
+		inNonPrimary: frames.DataTable{
+			TableID:   int(current.Ordinal),
+			TableKind: frames.TableKind(current.Kind),
+			TableName: frames.TableKind(current.Name),
+			Columns:   cols,
+			KustoRows: tbl.KustoRows,
+			RowErrors: tbl.RowErrors,
+			Op:        p.op,
+		},
+		wg: p.wg,
+	}:
+	}
 	return p.done, nil
 }
 
 func (p *v1SM) dataTable() (stateFn, error) {
-	var err error
 	currentTable := p.currentTable
 
+	cols, err := currentTable.DataTypes.ToColumns()
+	if err != nil {
+		return nil, err
+	}
 	p.columnSetOnce.Do(func() {
-		var cols table.Columns
-		cols, err = currentTable.DataTypes.ToColumns()
-		if err != nil {
-			return
-		}
 		p.wg.Add(1)
 		p.iter.inColumns <- send{inColumns: cols, wg: p.wg}
 	})
 
+	tbl := &primaryTable{index: p.primaryTables, columns: cols}
+	p.primaryTables++
+
 	p.wg.Add(1)
 	select {
 	case <-p.ctx.Done():
 		return nil, p.ctx.Err()
-	case p.iter.inRows <- send{inRows: currentTable.KustoRows, inRowErrors: currentTable.RowErrors, wg: p.wg}:
+	case p.iter.inRows <- send{inTable: tbl, inRows: currentTable.KustoRows, inRowErrors: currentTable.RowErrors, wg: p.wg}:
 		p.receivedDT = true
 	}
 
@@ -11,7 +11,7 @@ import (
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/Azure/azure-kusto-go/kusto/data/table"
 	"github.com/Azure/azure-kusto-go/kusto/data/value"
-	"github.com/Azure/azure-kusto-go/kusto/internal/frames"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
 	v1 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v1"
 	v2 "github.com/Azure/azure-kusto-go/kusto/internal/frames/v2"
 	"github.com/google/uuid"
@@ -133,6 +133,8 @@ func TestNonProgressive(t *testing.T) {
 		wantWithoutInlineErrors table.Rows
 		nonPrimary              map[frames.TableKind]v2.DataTable
 		inlineErrors            []*errors.Error
+		// completionErrors is set when the inlineErrors are those of the DataSetCompletion, which Do() skips.
+		completionErrors bool
 	}{
 		{
 			desc:   "No completion frame error",
@@ -161,7 +163,7 @@ func TestNonProgressive(t *testing.T) {
 				v2.DataSetCompletion{},
 				v2.DataTable{TableKind: frames.PrimaryResult},
 			},
-			err: errors.ES(errors.OpUnknown, errors.KInternal, "saw a DataSetCompletion frame, then received a v2.DataTable frame"),
+			err: errors.ES(errors.OpUnknown, errors.KInternal, "saw a DataSetCompletion frame, then received a frames.DataTable frame"),
 		},
 		{
 			desc: "The expected frame set",
@@ -473,6 +475,46 @@ func TestNonProgressive(t *testing.T) {
 				errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Some other error"),
 			},
 		},
+		{
+			desc: "Partial failure in the DataSetCompletion",
+			stream: []frames.Frame{
+				v2.DataTable{
+					Base:      v2.Base{FrameType: frames.TypeDataTable},
+					TableKind: frames.PrimaryResult,
+					TableName: frames.PrimaryResult,
+					Columns: table.Columns{
+						{Name: "Name", Type: "string"},
+					},
+					KustoRows: []value.Values{
+						{value.String{Value: "Doak", Valid: true}},
+					},
+				},
+				v2.DataSetCompletion{
+					HasErrors: true,
+					OneAPIErrors: []string{
+						`{"error":{"code":"LimitsExceeded","message":"Query execution has exceeded the allowed limits"}}`,
+						`not a OneApiError`,
+					},
+				},
+			},
+			want: table.Rows{
+				&table.Row{
+					ColumnTypes: table.Columns{
+						{Name: "Name", Type: "string"},
+					},
+					Values: value.Values{
+						value.String{Value: "Doak", Valid: true},
+					},
+					Op: errors.OpQuery,
+				},
+			},
+			inlineErrors: []*errors.Error{
+				errors.ES(errors.OpUnknown, errors.KLimitsExceeded, "Query execution has exceeded the allowed limits;See https://docs.microsoft."+
+					"com/en-us/azure/kusto/concepts/querylimits"),
+				errors.ES(errors.OpUnknown, errors.KInternal, "not a OneApiError"),
+			},
+			completionErrors: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -509,7 +551,7 @@ func TestNonProgressive(t *testing.T) {
 				got, err := iterateRows(iter)
 
 				testErr := test.err
-				if testErr == nil && test.inlineErrors != nil && len(test.inlineErrors) > 0 {
+				if testErr == nil && test.inlineErrors != nil && len(test.inlineErrors) > 0 && !test.completionErrors {
 					testErr = test.inlineErrors[0]
 				}
 
@@ -579,7 +621,7 @@ func TestProgressive(t *testing.T) {
 				v2.DataSetCompletion{},
 				v2.DataTable{TableKind: frames.QueryProperties},
 			},
-			err: errors.ES(errors.OpUnknown, errors.KInternal, "received a dataSetCompletion frame and then a v2.DataTable frame"),
+			err: errors.ES(errors.OpUnknown, errors.KInternal, "received a dataSetCompletion frame and then a frames.DataTable frame"),
 		},
 		{
 			desc: "TableFragment with no TableHeader",
@@ -0,0 +1,266 @@
+package kusto
+
+// stmt_builder.go implements the builder methods of Stmt, which add escaped names, literals and query parameters
+// declared with the type of their Go value. The kql package exports them as kql.Builder.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/google/uuid"
+)
+
+// AddParam adds the query parameter name to the Stmt, declared with the type of v, and passes v as its value.
+// The type of v is the one of the value.Kusto v is converted into, see AddLiteral(). Example:
+//
+//	stmt := NewStmt("Users | where Name == ").AddParam("name", "bob")
+//	// declare query_parameters(name:string);
+//	// Users | where Name == name
+//
+// The declaration and value of name are kept by later calls to WithDefinitions() and WithParameters(). An error,
+// such as a name that is already declared, is kept by the Stmt, see Err().
+func (s Stmt) AddParam(name stringConstant, v interface{}) Stmt {
+	if s.err != nil {
+		return s
+	}
+
+	key := name.String()
+	if !validParamName(key) {
+		return s.withErr(fmt.Errorf("AddParam(): name %q is not a valid parameter name", key))
+	}
+	if _, ok := s.defs.m[key]; ok {
+		return s.withErr(fmt.Errorf("AddParam(): parameter %q is already defined in the Stmt", key))
+	}
+
+	k, err := kustoValue(v)
+	if err != nil {
+		return s.withErr(fmt.Errorf("AddParam(%s): %w", key, err))
+	}
+	paramType, param, err := paramValue(k)
+	if err != nil {
+		return s.withErr(fmt.Errorf("AddParam(%s): %w", key, err))
+	}
+
+	defs := s.defs.clone()
+	defs.m[key] = paramType
+	params := s.params.clone()
+	params.m[key] = param
+	params, err = params.validate(defs)
+	if err != nil {
+		return s.withErr(fmt.Errorf("AddParam(%s): %w", key, err))
+	}
+
+	s.addedTypes = s.addedTypes.clone()
+	s.addedTypes[key] = paramType
+	s.addedValues = s.addedValues.clone()
+	s.addedValues[key] = param
+	s.defs = defs
+	s.params = params
+	s.queryStr += key
+	return s
+}
+
+// AddLiteral adds v to the Stmt as a literal of its type, such as `long(1)` or `"text"`. v is a value.Kusto, or is
+// converted into one: bool, int8, int16, int32 and uint16 into an int, int, int64, uint32, uint and uint64 into a
+// long, float32 and float64 into a real, string into a string, time.Time into a datetime, time.Duration into a
+// timespan, uuid.UUID into a guid, *big.Float and *big.Int into a decimal, and maps, slices, arrays, structs and
+// pointers to them into a dynamic holding their JSON. An error is kept by the Stmt, see Err().
+func (s Stmt) AddLiteral(v interface{}) Stmt {
+	if s.err != nil {
+		return s
+	}
+	k, err := kustoValue(v)
+	if err != nil {
+		return s.withErr(fmt.Errorf("AddLiteral(): %w", err))
+	}
+	literal, err := table.Literal(k)
+	if err != nil {
+		return s.withErr(fmt.Errorf("AddLiteral(): %w", err))
+	}
+	s.queryStr += literal
+	return s
+}
+
+// AddTable adds the name of a table to the Stmt, quoted as ['name'] if it is not a plain identifier. name can come
+// from untrusted input.
+func (s Stmt) AddTable(name string) Stmt {
+	return s.addIdentifier("AddTable", name)
+}
+
+// AddColumn adds the name of a column to the Stmt, quoted as ['name'] if it is not a plain identifier. name can come
+// from untrusted input.
+func (s Stmt) AddColumn(name string) Stmt {
+	return s.addIdentifier("AddColumn", name)
+}
+
+// AddDatabase adds a reference to the database name to the Stmt, as database("name"), which is followed by the
+// table of the database, such as in `database("db").Events`. name can come from untrusted input.
+func (s Stmt) AddDatabase(name string) Stmt {
+	if s.err != nil {
+		return s
+	}
+	if strings.TrimSpace(name) == "" {
+		return s.withErr(fmt.Errorf("AddDatabase(): name cannot be empty"))
+	}
+	s.queryStr += "database(" + table.QuoteString(name) + ")"
+	return s
+}
+
+func (s Stmt) addIdentifier(method string, name string) Stmt {
+	if s.err != nil {
+		return s
+	}
+	if strings.TrimSpace(name) == "" {
+		return s.withErr(fmt.Errorf("%s(): name cannot be empty", method))
+	}
+	s.queryStr += table.QuoteIdentifier(name)
+	return s
+}
+
+// Err returns the first error of a call to AddParam(), AddLiteral(), AddTable(), AddColumn() or AddDatabase() on the
+// Stmt, after which these methods do nothing. Query() and Mgmt() return the error for such a Stmt.
+func (s Stmt) Err() error {
+	return s.err
+}
+
+// Parameters returns the values of the query parameters of the Stmt, as they are sent in the Parameters of the
+// request properties.
+func (s Stmt) Parameters() (map[string]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.params.toParameters(s.defs)
+}
+
+func (s Stmt) withErr(err error) Stmt {
+	s.err = err
+	return s
+}
+
+// stmtParameters is Stmt.Parameters() for the call op.
+func stmtParameters(op errors.Op, query Stmt) (map[string]string, error) {
+	if query.err != nil {
+		return nil, errors.ES(op, errors.KClientArgs, "the Stmt could not be built: %s", query.err).SetNoRetry()
+	}
+	params, err := query.params.toParameters(query.defs)
+	if err != nil {
+		return nil, errors.ES(op, errors.KClientArgs, "QueryValues in the the Stmt were incorrect: %s", err).SetNoRetry()
+	}
+	return params, nil
+}
+
+// kustoValue returns v as a value.Kusto, see AddLiteral().
+func kustoValue(v interface{}) (value.Kusto, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("the value cannot be nil")
+	case value.Kusto:
+		return v, nil
+	case bool:
+		return value.Bool{Value: v, Valid: true}, nil
+	case int8:
+		return value.Int{Value: int32(v), Valid: true}, nil
+	case int16:
+		return value.Int{Value: int32(v), Valid: true}, nil
+	case int32:
+		return value.Int{Value: v, Valid: true}, nil
+	case uint8:
+		return value.Int{Value: int32(v), Valid: true}, nil
+	case uint16:
+		return value.Int{Value: int32(v), Valid: true}, nil
+	case int:
+		return value.Long{Value: int64(v), Valid: true}, nil
+	case int64:
+		return value.Long{Value: v, Valid: true}, nil
+	case uint32:
+		return value.Long{Value: int64(v), Valid: true}, nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return nil, fmt.Errorf("uint %d does not fit in a long", v)
+		}
+		return value.Long{Value: int64(v), Valid: true}, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("uint64 %d does not fit in a long", v)
+		}
+		return value.Long{Value: int64(v), Valid: true}, nil
+	case float32:
+		return value.Real{Value: float64(v), Valid: true}, nil
+	case float64:
+		return value.Real{Value: v, Valid: true}, nil
+	case string:
+		return value.String{Value: v, Valid: true}, nil
+	case time.Time:
+		return value.DateTime{Value: v, Valid: true}, nil
+	case time.Duration:
+		return value.Timespan{Value: v, Valid: true}, nil
+	case uuid.UUID:
+		return value.GUID{Value: v, Valid: true}, nil
+	case *big.Float, *big.Int:
+		d, err := decimalString(v)
+		if err != nil {
+			return nil, err
+		}
+		return value.Decimal{Value: d, Valid: true}, nil
+	}
+
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%T could not be marshalled into a dynamic value: %w", v, err)
+		}
+		return value.Dynamic{Value: b, Valid: true}, nil
+	}
+	return nil, fmt.Errorf("type %T is not supported", v)
+}
+
+// paramValue returns the declaration of a query parameter holding k, and k as the Go value Parameters expects for
+// that type.
+func paramValue(k value.Kusto) (ParamType, interface{}, error) {
+	var (
+		t     types.Column
+		v     interface{}
+		valid bool
+	)
+	switch k := k.(type) {
+	case value.Bool:
+		t, v, valid = types.Bool, k.Value, k.Valid
+	case value.DateTime:
+		t, v, valid = types.DateTime, k.Value, k.Valid
+	case value.Decimal:
+		t, v, valid = types.Decimal, k, k.Valid
+	case value.Dynamic:
+		if k.Valid && !json.Valid(k.Value) {
+			return ParamType{}, nil, fmt.Errorf("dynamic value is not valid JSON")
+		}
+		t, v, valid = types.Dynamic, json.RawMessage(k.Value), k.Valid
+	case value.GUID:
+		t, v, valid = types.GUID, k.Value, k.Valid
+	case value.Int:
+		t, v, valid = types.Int, k.Value, k.Valid
+	case value.Long:
+		t, v, valid = types.Long, k.Value, k.Valid
+	case value.Real:
+		t, v, valid = types.Real, k.Value, k.Valid
+	case value.String:
+		t, v, valid = types.String, k.Value, k.Valid
+	case value.Timespan:
+		t, v, valid = types.Timespan, k.Value, k.Valid
+	default:
+		return ParamType{}, nil, fmt.Errorf("type %T is not supported", k)
+	}
+	if !valid {
+		return ParamType{}, nil, fmt.Errorf("a query parameter cannot hold a null %s value", t)
+	}
+	return ParamType{Type: t}, v, nil
+}
@@ -0,0 +1,185 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddParam(t *testing.T) {
+	t.Parallel()
+
+	u := uuid.MustParse("6f3b3e2c-1a9d-4b5e-8f2a-0c1d2e3f4a5b")
+	when := time.Date(2023, 5, 1, 4, 14, 55, 0, time.UTC)
+
+	tests := []struct {
+		desc      string
+		v         interface{}
+		err       bool
+		wantDecl  string
+		wantValue string
+	}{
+		{desc: "Error: nil", v: nil, err: true},
+		{desc: "Error: unsupported type", v: make(chan int), err: true},
+		{desc: "Error: uint64 overflow", v: uint64(math.MaxUint64), err: true},
+		{desc: "Error: null value", v: value.Long{}, err: true},
+		{desc: "Error: bad dynamic", v: value.Dynamic{Value: []byte("{"), Valid: true}, err: true},
+		{desc: "Success: bool", v: true, wantDecl: "p:bool", wantValue: "bool(true)"},
+		{desc: "Success: int32", v: int32(-3), wantDecl: "p:int", wantValue: "int(-3)"},
+		{desc: "Success: uint16", v: uint16(3), wantDecl: "p:int", wantValue: "int(3)"},
+		{desc: "Success: int", v: 42, wantDecl: "p:long", wantValue: "long(42)"},
+		{desc: "Success: uint64", v: uint64(7), wantDecl: "p:long", wantValue: "long(7)"},
+		{desc: "Success: float64", v: 1.5, wantDecl: "p:real", wantValue: "real(1.5)"},
+		{desc: "Success: real nan", v: math.NaN(), wantDecl: "p:real", wantValue: "real(nan)"},
+		{desc: "Success: string", v: `a"b`, wantDecl: "p:string", wantValue: `a"b`},
+		{desc: "Success: time.Time", v: when, wantDecl: "p:datetime", wantValue: "datetime(2023-05-01T04:14:55Z)"},
+		{desc: "Success: time.Duration", v: 90 * time.Second, wantDecl: "p:timespan", wantValue: "time(00:01:30.0000000)"},
+		{desc: "Success: uuid.UUID", v: u, wantDecl: "p:guid", wantValue: "guid(" + u.String() + ")"},
+		{desc: "Success: *big.Int", v: big.NewInt(12), wantDecl: "p:decimal", wantValue: "decimal(12)"},
+		{desc: "Success: map", v: map[string]int{"a": 1}, wantDecl: "p:dynamic", wantValue: `dynamic({"a":1})`},
+		{desc: "Success: slice", v: []string{"a", "b"}, wantDecl: "p:dynamic", wantValue: `dynamic(["a","b"])`},
+		{desc: "Success: value.Kusto", v: value.Long{Value: 9, Valid: true}, wantDecl: "p:long", wantValue: "long(9)"},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			stmt := NewStmt("T | where x == ").AddParam("p", test.v)
+			if test.err {
+				assert.Error(t, stmt.Err())
+				_, err := stmt.Parameters()
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, stmt.Err())
+
+			assert.Equal(t, "declare query_parameters("+test.wantDecl+");\nT | where x == p", stmt.String())
+			params, err := stmt.Parameters()
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"p": test.wantValue}, params)
+		})
+	}
+}
+
+func TestAddParamWithDefinitions(t *testing.T) {
+	t.Parallel()
+
+	stmt := NewStmt("T | where Name == ").AddParam("name", "bob").Add(" and Id == id")
+	require.NoError(t, stmt.Err())
+
+	// A name can only be declared once.
+	assert.Error(t, stmt.AddParam("name", "alice").Err())
+
+	// The parameter is kept when the other definitions and values are set.
+	stmt, err := stmt.WithDefinitions(NewDefinitions().Must(ParamTypes{"id": ParamType{Type: types.Long}}))
+	require.NoError(t, err)
+	stmt, err = stmt.WithParameters(NewParameters().Must(QueryValues{"id": int64(1)}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "declare query_parameters(id:long, name:string);\nT | where Name == name and Id == id", stmt.String())
+	j, err := stmt.ValuesJSON()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"long(1)","name":"bob"}`, j)
+
+	_, err = stmt.WithDefinitions(NewDefinitions().Must(ParamTypes{"name": ParamType{Type: types.String}}))
+	assert.Error(t, err)
+	_, err = stmt.WithParameters(NewParameters().Must(QueryValues{"name": "a"}))
+	assert.Error(t, err)
+}
+
+func TestStmtBuilder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		stmt    Stmt
+		err     bool
+		wantStr string
+	}{
+		{
+			desc:    "Success: plain names",
+			stmt:    NewStmt("").AddDatabase("db").Add(".").AddTable("Events").Add(" | project ").AddColumn("Name"),
+			wantStr: `database("db").Events | project Name`,
+		},
+		{
+			desc:    "Success: names are escaped",
+			stmt:    NewStmt("").AddDatabase(`my "db"`).Add(".").AddTable("my table").Add(" | project ").AddColumn("where"),
+			wantStr: `database("my \"db\"").['my table'] | project ['where']`,
+		},
+		{
+			desc:    "Success: injection attempt stays in the name",
+			stmt:    NewStmt("").AddTable("T'] | take 1 //"),
+			wantStr: `['T\'] | take 1 //']`,
+		},
+		{
+			desc: "Success: literals",
+			stmt: NewStmt("print ").AddLiteral(true).Add(", ").AddLiteral(int32(1)).Add(", ").AddLiteral(2).
+				Add(", ").AddLiteral(2.5).Add(", ").AddLiteral(`a"b`).Add(", ").AddLiteral(time.Minute).
+				Add(", ").AddLiteral([]int{1, 2}).Add(", ").AddLiteral(value.String{}),
+			wantStr: `print bool(true), int(1), long(2), real(2.5), "a\"b", time(00:01:00.0000000), dynamic([1,2]), ""`,
+		},
+		{
+			desc: "Error: empty table",
+			stmt: NewStmt("").AddTable(" "),
+			err:  true,
+		},
+		{
+			desc: "Error: empty database",
+			stmt: NewStmt("").AddDatabase(""),
+			err:  true,
+		},
+		{
+			desc: "Error: bad literal",
+			stmt: NewStmt("print ").AddLiteral(value.Decimal{Value: "1) | drop", Valid: true}),
+			err:  true,
+		},
+		{
+			desc: "Error: the first error is kept",
+			stmt: NewStmt("").AddColumn("").AddTable("T").AddParam("p", 1),
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			if test.err {
+				assert.Error(t, test.stmt.Err())
+				return
+			}
+			require.NoError(t, test.stmt.Err())
+			assert.Equal(t, test.wantStr, test.stmt.String())
+		})
+	}
+}
+
+func TestStmtBuilderQueryOptions(t *testing.T) {
+	t.Parallel()
+
+	stmt := NewStmt("").AddTable("T").Add(" | where Name == ").AddParam("name", "bob")
+	opts, err := setQueryOptions(context.Background(), errors.OpQuery, stmt, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "bob"}, opts.requestProperties.Parameters)
+
+	_, err = setQueryOptions(context.Background(), errors.OpQuery, stmt.AddTable(""), nil)
+	var kerr *errors.Error
+	require.True(t, goErrors.As(err, &kerr), "got %T: %v", err, err)
+	assert.Equal(t, errors.KClientArgs, kerr.Kind)
+
+	_, err = setMgmtOptions(context.Background(), errors.OpMgmt, stmt.AddTable(""))
+	require.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+// endlessTransport is a fake http.RoundTripper that answers with a progressive stream whose TableFragments never
+// end, as the service sends until the stream is torn down.
+type endlessTransport struct {
+	// stallAfter, if set, is the number of TableFragments after which the stream stalls, as a service that has
+	// nothing to send yet.
+	stallAfter int
+	// closed is closed once the body was closed.
+	closed chan struct{}
+	// stopped is closed once the stream stopped being written.
+	stopped chan struct{}
+}
+
+// endlessBody is the body of the response of an endlessTransport.
+type endlessBody struct {
+	*io.PipeReader
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (e *endlessBody) Close() error {
+	e.once.Do(func() { close(e.closed) })
+	return e.PipeReader.Close()
+}
+
+func (e *endlessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.Contains(req.URL.Path, "/rest/") || strings.HasSuffix(req.URL.Path, "/auth/metadata") {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer close(e.stopped)
+
+		header := `[{"FrameType":"DataSetHeader","IsProgressive":true,"Version":"v2.0"},` +
+			`{"FrameType":"TableHeader","TableId":0,"TableKind":"PrimaryResult","TableName":"PrimaryResult",` +
+			`"Columns":[{"ColumnName":"x","ColumnType":"long"},{"ColumnName":"s","ColumnType":"string"}]}`
+		if _, err := io.WriteString(pw, header); err != nil {
+			return
+		}
+		for i := 0; ; i++ {
+			if e.stallAfter > 0 && i == e.stallAfter {
+				<-e.closed
+				return
+			}
+			fragment := fmt.Sprintf(
+				`,{"FrameType":"TableFragment","TableFragmentType":"DataAppend","TableId":0,"Rows":[[%d,"%s"],[%d,"%s"]]}`,
+				2*i, strings.Repeat("a", 100), 2*i+1, strings.Repeat("b", 100),
+			)
+			if _, err := io.WriteString(pw, fragment); err != nil {
+				return
+			}
+		}
+	}()
+	body := &endlessBody{PipeReader: pr, closed: e.closed}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Body: body}, nil
+}
+
+func TestStopClosesBody(t *testing.T) {
+	// Not parallel, so that the goroutines of other tests are not taken for leaks.
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	tests := []struct {
+		desc       string
+		stallAfter int
+		// stop ends the iteration after a few rows.
+		stop func(iter *RowIterator, cancel context.CancelFunc)
+	}{
+		{
+			desc: "Stop",
+			stop: func(iter *RowIterator, cancel context.CancelFunc) { iter.Stop() },
+		},
+		{
+			desc: "Context canceled",
+			stop: func(iter *RowIterator, cancel context.CancelFunc) {
+				cancel()
+				iter.Stop()
+			},
+		},
+		{
+			desc:       "Stop while the stream stalls",
+			stallAfter: 100,
+			stop:       func(iter *RowIterator, cancel context.CancelFunc) { iter.Stop() },
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			transport := &endlessTransport{stallAfter: test.stallAfter, closed: make(chan struct{}), stopped: make(chan struct{})}
+			client := newTestClient(t, "https://stop.kusto.windows.net", transport)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			iter, err := client.Query(ctx, "db", NewStmt("T"))
+			require.NoError(t, err)
+
+			errEnough := goErrors.New("enough rows")
+			rows := 0
+			err = iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+				if rows++; rows == 100 {
+					return errEnough
+				}
+				return nil
+			})
+			require.ErrorIs(t, err, errEnough)
+			// The rows the caller does not read fill the buffers, until the decoder blocks sending a frame.
+			time.Sleep(100 * time.Millisecond)
+
+			test.stop(iter, cancel)
+
+			select {
+			case <-transport.closed:
+			case <-time.After(5 * time.Second):
+				assert.Fail(t, "the response body was not closed once the iteration stopped")
+			}
+		})
+	}
+}
+
+func TestStopTwiceThenRead(t *testing.T) {
+	t.Parallel()
+
+	// A RowIterator that was not started can be stopped.
+	(&RowIterator{}).Stop()
+
+	for i := 0; i < 20; i++ {
+		transport := &endlessTransport{closed: make(chan struct{}), stopped: make(chan struct{})}
+		client := newTestClient(t, "https://stop.kusto.windows.net", transport)
+
+		iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+		require.NoError(t, err)
+		iter.Stop()
+		iter.Stop()
+
+		// The rows that were already received are not returned.
+		rows := 0
+		err = iter.Do(func(r *table.Row) error {
+			rows++
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, rows)
+	}
+}
@@ -0,0 +1,59 @@
+package testserver_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/testserver"
+)
+
+func ExampleServer() {
+	srv := testserver.New()
+	defer srv.Close()
+
+	// The first request is throttled, the client retries it.
+	throttled := testserver.Throttled(10 * time.Millisecond)
+	throttled.Times = 1
+	srv.OnQuery("systemNodes | count", throttled)
+	srv.OnQuery("systemNodes | count", testserver.Response{
+		Tables: []testserver.Table{
+			{
+				Columns: table.Columns{{Name: "Count", Type: types.Long}},
+				Rows:    []value.Values{{value.Long{Value: 3, Valid: true}}},
+			},
+		},
+	})
+
+	client, err := kusto.New(
+		kusto.NewConnectionStringBuilder(srv.URL()).WithBearerToken("token"),
+		kusto.WithRetryOptions(1, time.Millisecond, time.Second),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+
+	iter, err := client.Query(context.Background(), "database", kusto.NewStmt("systemNodes | count"))
+	if err != nil {
+		panic(err)
+	}
+	defer iter.Stop()
+
+	err = iter.Do(func(row *table.Row) error {
+		fmt.Println(row.Values[0])
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(len(srv.Requests()))
+
+	// Output:
+	// 3
+	// 2
+}
@@ -0,0 +1,323 @@
+package testserver
+
+// response.go writes the Responses of a Server in the v1 and v2 framing of the Kusto REST protocol.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	internalframes "github.com/Azure/azure-kusto-go/kusto/internal/frames"
+)
+
+// The request properties that select the framing of a query.
+const (
+	progressiveOption = "results_progressive_enabled"
+	fragmentedOption  = "results_v2_fragment_primary_tables"
+)
+
+// Table is a table of a Response.
+type Table struct {
+	// Kind is the kind of the table, frames.PrimaryResult if empty. It is only sent in the v2 framing of queries, the
+	// tables of a management command are all results.
+	Kind frames.TableKind
+	// Name is the name of the table, which is its Kind for a query and "Table_<index>" for a command if empty.
+	Name string
+	// Columns are the columns of the table.
+	Columns table.Columns
+	// Rows are the rows of the table, whose values must match the types of the Columns.
+	Rows []value.Values
+}
+
+// ServiceError is the error the service sends in the body of a response that failed.
+type ServiceError struct {
+	// Code is the code of the error, such as "LimitsExceeded".
+	Code string
+	// Message is the message of the error.
+	Message string
+	// Permanent is set if the request would fail again.
+	Permanent bool
+}
+
+// Response is what a Server sends for a request.
+type Response struct {
+	// Tables are the tables of a successful response, in order.
+	Tables []Table
+	// FragmentRows is the number of rows of each TableFragment of the primary tables of a progressive or fragmented
+	// query, each followed by a TableProgress if it is progressive. 0 sends each table in a single fragment.
+	FragmentRows int
+	// FrameDelay is waited before each frame after the first, as a query that takes time to produce its results. The
+	// tables of a management command are its frames.
+	FrameDelay time.Duration
+	// ResetAfterFrames, if set, drops the connection once that many frames were sent, as a connection reset in the
+	// middle of the stream. The response has a 200 status, the client fails while reading its body.
+	ResetAfterFrames int
+
+	// Status is the HTTP status of the response, 200 if 0. Any other status is an error response, whose body is Error.
+	Status int
+	// Error is the body of an error response. Its Code and Message default to the text of the Status.
+	Error ServiceError
+	// Header is added to the header of the response, such as a Retry-After header.
+	Header http.Header
+
+	// Times is the number of requests the Response answers, after which its handler no longer matches, such as to
+	// throttle only the first request. 0 answers every request.
+	Times int
+}
+
+// Throttled returns the Response of a throttled request, a 429 status asking to retry after retryAfter, in the
+// x-ms-retry-after-ms header and in the Retry-After header, which is in seconds. Set its Times to throttle only the
+// first requests.
+func Throttled(retryAfter time.Duration) Response {
+	seconds := (retryAfter + time.Second - 1) / time.Second
+	return Response{
+		Status: http.StatusTooManyRequests,
+		Error:  ServiceError{Code: "TooManyRequests", Message: "the request was throttled by the test server"},
+		Header: http.Header{
+			"Retry-After":         []string{strconv.FormatInt(int64(seconds), 10)},
+			"X-Ms-Retry-After-Ms": []string{strconv.FormatInt(retryAfter.Milliseconds(), 10)},
+		},
+	}
+}
+
+// serve writes the Response to the request req received as r.
+func (resp Response) serve(w http.ResponseWriter, req *http.Request, r Request) {
+	for k, v := range resp.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	if resp.Status != 0 && resp.Status != http.StatusOK {
+		writeError(w, resp.Status, resp.Error)
+		return
+	}
+
+	var (
+		parts          []interface{}
+		prefix, suffix string
+	)
+	if r.Mgmt {
+		parts, prefix, suffix = resp.v1Tables(), `{"Tables":[`, "]}"
+	} else {
+		progressive := r.Options[progressiveOption] == true
+		fragmented := !progressive && r.Options[fragmentedOption] == true
+		parts, prefix, suffix = resp.v2Frames(progressive, fragmented), "[", "]"
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	_, _ = w.Write([]byte(prefix))
+	for i, part := range parts {
+		if i > 0 {
+			if !wait(req, resp.FrameDelay) {
+				return
+			}
+			_, _ = w.Write([]byte(",\n"))
+		}
+		b, err := json.Marshal(part)
+		if err != nil {
+			// The frames are built from the values of the Response, which always marshal.
+			panic(fmt.Sprintf("testserver: could not marshal a frame: %s", err))
+		}
+		if _, err := w.Write(b); err != nil {
+			return
+		}
+		flush()
+		if resp.ResetAfterFrames > 0 && i+1 == resp.ResetAfterFrames {
+			// The connection is closed without ending the body, which the client sees as an unexpected EOF.
+			panic(http.ErrAbortHandler)
+		}
+	}
+	_, _ = w.Write([]byte(suffix))
+}
+
+// writeError writes an error response with the status and the body of a OneApiError.
+func writeError(w http.ResponseWriter, status int, e ServiceError) {
+	text := http.StatusText(status)
+	if e.Code == "" {
+		e.Code = strings.ReplaceAll(text, " ", "")
+	}
+	if e.Message == "" {
+		e.Message = text
+	}
+
+	body := map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":       e.Code,
+			"message":    e.Message,
+			"@type":      "Kusto.TestServer." + e.Code,
+			"@message":   e.Message,
+			"@permanent": e.Permanent,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+type jsonColumn struct {
+	ColumnName string
+	ColumnType string
+}
+
+type dataSetHeader struct {
+	FrameType     string
+	IsProgressive bool
+	IsFragmented  bool
+	Version       string
+}
+
+type dataTable struct {
+	FrameType string
+	TableId   int
+	TableKind frames.TableKind
+	TableName string
+	Columns   []jsonColumn
+	Rows      [][]interface{}
+}
+
+type tableHeader struct {
+	FrameType string
+	TableId   int
+	TableKind frames.TableKind
+	TableName string
+	Columns   []jsonColumn
+}
+
+type tableFragment struct {
+	FrameType         string
+	TableId           int
+	FieldCount        int
+	TableFragmentType string
+	Rows              [][]interface{}
+}
+
+type tableProgress struct {
+	FrameType     string
+	TableId       int
+	TableProgress float64
+}
+
+type tableCompletion struct {
+	FrameType string
+	TableId   int
+	RowCount  int
+}
+
+type dataSetCompletion struct {
+	FrameType string
+	HasErrors bool
+	Cancelled bool
+}
+
+type v1Table struct {
+	TableName string
+	Columns   []jsonColumn
+	Rows      [][]interface{}
+}
+
+// v2Frames returns the frames of the v2 framing of the Response. The primary tables are sent in TableHeader,
+// TableFragment and TableCompletion frames if the response is progressive or fragmented, and as a DataTable otherwise.
+func (resp Response) v2Frames(progressive, fragmented bool) []interface{} {
+	out := []interface{}{dataSetHeader{FrameType: frames.TypeDataSetHeader, IsProgressive: progressive, IsFragmented: fragmented, Version: "v2.0"}}
+	for i, t := range resp.Tables {
+		kind := t.Kind
+		if kind == "" {
+			kind = frames.PrimaryResult
+		}
+		name := t.Name
+		if name == "" {
+			name = string(kind)
+		}
+		columns := jsonColumns(t.Columns)
+
+		if kind != frames.PrimaryResult || !(progressive || fragmented) {
+			out = append(out, dataTable{FrameType: frames.TypeDataTable, TableId: i, TableKind: kind, TableName: name, Columns: columns, Rows: jsonRows(t.Rows)})
+			continue
+		}
+
+		out = append(out, tableHeader{FrameType: frames.TypeTableHeader, TableId: i, TableKind: kind, TableName: name, Columns: columns})
+		size := resp.FragmentRows
+		if size <= 0 {
+			size = len(t.Rows)
+		}
+		for start := 0; start < len(t.Rows); start += size {
+			end := start + size
+			if end > len(t.Rows) {
+				end = len(t.Rows)
+			}
+			out = append(out, tableFragment{
+				FrameType:         frames.TypeTableFragment,
+				TableId:           i,
+				FieldCount:        len(columns),
+				TableFragmentType: "DataAppend",
+				Rows:              jsonRows(t.Rows[start:end]),
+			})
+			if progressive {
+				out = append(out, tableProgress{FrameType: frames.TypeTableProgress, TableId: i, TableProgress: 100 * float64(end) / float64(len(t.Rows))})
+			}
+		}
+		out = append(out, tableCompletion{FrameType: frames.TypeTableCompletion, TableId: i, RowCount: len(t.Rows)})
+	}
+	return append(out, dataSetCompletion{FrameType: frames.TypeDataSetCompletion})
+}
+
+// v1Tables returns the tables of the v1 framing of the Response, followed by their table of contents if there is more
+// than one.
+func (resp Response) v1Tables() []interface{} {
+	out := make([]interface{}, 0, len(resp.Tables)+1)
+	var contents [][]interface{}
+	for i, t := range resp.Tables {
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("Table_%d", i)
+		}
+		out = append(out, v1Table{TableName: name, Columns: jsonColumns(t.Columns), Rows: jsonRows(t.Rows)})
+		contents = append(contents, []interface{}{i, string(frames.QueryResult), name, fmt.Sprintf("%08d-0000-0000-0000-000000000000", i), ""})
+	}
+	if len(resp.Tables) > 1 {
+		out = append(out, v1Table{
+			TableName: fmt.Sprintf("Table_%d", len(resp.Tables)),
+			Columns: []jsonColumn{
+				{ColumnName: "Ordinal", ColumnType: "long"},
+				{ColumnName: "Kind", ColumnType: "string"},
+				{ColumnName: "Name", ColumnType: "string"},
+				{ColumnName: "Id", ColumnType: "string"},
+				{ColumnName: "PrettyName", ColumnType: "string"},
+			},
+			Rows: contents,
+		})
+	}
+	return out
+}
+
+func jsonColumns(columns table.Columns) []jsonColumn {
+	out := make([]jsonColumn, len(columns))
+	for i, col := range columns {
+		out[i] = jsonColumn{ColumnName: col.Name, ColumnType: string(col.Type)}
+	}
+	return out
+}
+
+func jsonRows(rows []value.Values) [][]interface{} {
+	out := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = internalframes.JSONValue(v)
+		}
+		out[i] = values
+	}
+	return out
+}
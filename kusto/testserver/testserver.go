@@ -0,0 +1,255 @@
+/*
+Package testserver provides an in-process HTTP server that speaks enough of the Kusto REST protocol to run a
+*kusto.Client end to end without a cluster, for integration tests.
+
+The Server answers the queries sent to /v2/rest/query and the management commands sent to /v1/rest/mgmt with the
+Response of the first handler that matches them, and serves the metadata of the public cloud at
+/v1/rest/auth/metadata. Like the service, it sends the progressive framing of the v2 protocol to the queries that ask
+for it, as Query() does by default, and the non-progressive or fragmented framing to the others, such as the ones of
+QueryToJson(). The requests it receives are recorded for assertions, see Server.Requests().
+
+Faults are injected with the fields of a Response: Status and Header for an error response, such as the 429 of
+Throttled(), ResetAfterFrames to drop the connection in the middle of the stream, and Times to fail only the first
+requests. WithLatency() and Response.FrameDelay slow the responses down.
+
+The Server listens on the plain http loopback address, which a Client accepts as it does for the emulator:
+
+	srv := testserver.New()
+	defer srv.Close()
+	srv.OnQuery("Events | count", testserver.Response{Tables: []testserver.Table{{Columns: columns, Rows: rows}}})
+
+	client, err := kusto.New(kusto.NewConnectionStringBuilder(srv.URL()).WithBearerToken("token"))
+
+Unlike the fake package, which replaces the *kusto.Client, the requests go through the whole client: its options,
+retries, decoders and state machines.
+*/
+package testserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The paths the Server answers.
+const (
+	queryPath    = "/v2/rest/query"
+	mgmtPath     = "/v1/rest/mgmt"
+	metadataPath = "/v1/rest/auth/metadata"
+)
+
+// metadata is the metadata of the public cloud, served at metadataPath.
+const metadata = `{"AzureAD":{"LoginEndpoint":"https://login.microsoftonline.com","LoginMfaRequired":false,` +
+	`"KustoClientAppId":"db662dc1-0cfe-4e1c-a843-19a68e65be58","KustoClientRedirectUri":"https://microsoft/kustoclient",` +
+	`"KustoServiceResourceId":"https://kusto.kusto.windows.net",` +
+	`"FirstPartyAuthorityUrl":"https://login.microsoftonline.com/f8cdef31-a31e-4b4a-93e4-5f571e91255a"}}`
+
+// Request is a query or management command received by a Server.
+type Request struct {
+	// Mgmt is set for a management command, sent to /v1/rest/mgmt, unset for a query, sent to /v2/rest/query.
+	Mgmt bool
+	// DB is the database of the request.
+	DB string
+	// CSL is the text of the query or command, which starts with the declare query_parameters statement if the Stmt
+	// has definitions.
+	CSL string
+	// Options are the options of the request properties, such as "servertimeout".
+	Options map[string]interface{}
+	// Parameters are the values of the query parameters of the request properties.
+	Parameters map[string]string
+	// Header is the header of the request, such as its x-ms-client-request-id and Authorization.
+	Header http.Header
+}
+
+// Option is an optional argument of New().
+type Option func(s *Server)
+
+// WithLatency delays every response of the Server by d before its headers are sent, as the network and the service
+// would.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+type handler struct {
+	match func(r Request) bool
+	resp  Response
+	// left is the number of requests the handler still answers, or -1 if it answers all of them.
+	left int
+}
+
+// Server is an in-process Kusto endpoint. Each request gets the Response of the first handler that matches it, in
+// the order they were added, or a 400 error if none does. It is safe for concurrent use.
+type Server struct {
+	srv     *httptest.Server
+	latency time.Duration
+
+	mu       sync.Mutex
+	handlers []*handler
+	requests []Request
+}
+
+// New starts a Server, which must be closed with Close().
+func New(options ...Option) *Server {
+	s := &Server{}
+	for _, o := range options {
+		o(s)
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the endpoint of the Server, such as "http://127.0.0.1:41234", to pass to
+// kusto.NewConnectionStringBuilder().
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close drops the connections of the Server, which ends the responses being sent, and stops it.
+func (s *Server) Close() {
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// OnRequest makes the requests for which match returns true get resp.
+func (s *Server) OnRequest(match func(r Request) bool, resp Response) {
+	left := -1
+	if resp.Times > 0 {
+		left = resp.Times
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, &handler{match: match, resp: resp, left: left})
+}
+
+// OnQuery makes the queries whose text is csl get resp, whatever their database.
+func (s *Server) OnQuery(csl string, resp Response) {
+	s.OnRequest(func(r Request) bool { return !r.Mgmt && r.CSL == csl }, resp)
+}
+
+// OnMgmt makes the management commands whose text is command get resp, whatever their database.
+func (s *Server) OnMgmt(command string, resp Response) {
+	s.OnRequest(func(r Request) bool { return r.Mgmt && r.CSL == command }, resp)
+}
+
+// Requests returns the queries and management commands received so far, in order, including the ones that no
+// handler matched.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// respond records r and returns the Response of the first handler that matches it, and false if none does.
+func (s *Server) respond(r Request) (Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r)
+	for _, h := range s.handlers {
+		if h.left == 0 || !h.match(r) {
+			continue
+		}
+		if h.left > 0 {
+			h.left--
+		}
+		return h.resp, true
+	}
+	return Response{}, false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	var mgmt bool
+	switch req.URL.Path {
+	case metadataPath:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = io.WriteString(w, metadata)
+		return
+	case queryPath:
+	case mgmtPath:
+		mgmt = true
+	default:
+		writeError(w, http.StatusNotFound, ServiceError{Code: "NotFound", Message: fmt.Sprintf("the path %s is not served", req.URL.Path)})
+		return
+	}
+	if req.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ServiceError{Code: "MethodNotAllowed", Message: fmt.Sprintf("%s is not supported", req.Method)})
+		return
+	}
+
+	r, err := decodeRequest(req, mgmt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ServiceError{Code: "BadRequest_InvalidBody", Message: err.Error(), Permanent: true})
+		return
+	}
+	resp, ok := s.respond(r)
+
+	if !wait(req, s.latency) {
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusBadRequest, ServiceError{
+			Code:      "General_BadRequest",
+			Message:   fmt.Sprintf("the test server has no response for %q on database %q", r.CSL, r.DB),
+			Permanent: true,
+		})
+		return
+	}
+	resp.serve(w, req, r)
+}
+
+// decodeRequest returns the Request of the body of req.
+func decodeRequest(req *http.Request, mgmt bool) (Request, error) {
+	var body io.Reader = req.Body
+	if strings.EqualFold(req.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return Request{}, fmt.Errorf("the gzip body could not be read: %w", err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var msg struct {
+		DB         string `json:"db"`
+		CSL        string `json:"csl"`
+		Properties struct {
+			Options    map[string]interface{}
+			Parameters map[string]string
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(body).Decode(&msg); err != nil {
+		return Request{}, fmt.Errorf("the body is not a valid request: %w", err)
+	}
+	return Request{
+		Mgmt:       mgmt,
+		DB:         msg.DB,
+		CSL:        msg.CSL,
+		Options:    msg.Properties.Options,
+		Parameters: msg.Properties.Parameters,
+		Header:     req.Header.Clone(),
+	}, nil
+}
+
+// wait waits for d, and returns false if the request was cancelled first.
+func wait(req *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-req.Context().Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,272 @@
+package testserver_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto"
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/Azure/azure-kusto-go/kusto/data/types"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+	"github.com/Azure/azure-kusto-go/kusto/testserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testColumns = table.Columns{{Name: "Name", Type: types.String}, {Name: "Count", Type: types.Long}}
+
+var testRows = []value.Values{
+	{value.String{Value: "a", Valid: true}, value.Long{Value: 1, Valid: true}},
+	{value.String{Value: "b", Valid: true}, value.Long{}},
+	{value.String{Value: "c", Valid: true}, value.Long{Value: 3, Valid: true}},
+}
+
+// newClient returns a Client of srv.
+func newClient(t *testing.T, srv *testserver.Server, options ...kusto.Option) *kusto.Client {
+	client, err := kusto.New(kusto.NewConnectionStringBuilder(srv.URL()).WithBearerToken("token"), options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// readRows returns the name of the rows of iter, which must have the testColumns.
+func readRows(iter *kusto.RowIterator) ([]string, error) {
+	defer iter.Stop()
+
+	var names []string
+	err := iter.DoOnRowOrError(func(r *table.Row, e *errors.Error) error {
+		if e != nil {
+			return e
+		}
+		names = append(names, r.Values[0].String())
+		return nil
+	})
+	return names, err
+}
+
+func TestQueryFramings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		options []kusto.QueryOption
+		// option is the request property of the framing.
+		option string
+	}{
+		{desc: "Progressive", option: "results_progressive_enabled"},
+		{desc: "Non-progressive", options: []kusto.QueryOption{kusto.ResultsProgressiveDisable()}},
+		{desc: "Fragmented", options: []kusto.QueryOption{kusto.ResultsV2FragmentedStreaming()}, option: "results_v2_fragment_primary_tables"},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := testserver.New()
+			defer srv.Close()
+			srv.OnQuery("Events", testserver.Response{
+				Tables: []testserver.Table{
+					{Columns: testColumns, Rows: testRows},
+					{Kind: frames.QueryCompletionInformation, Columns: table.Columns{{Name: "EventType", Type: types.Int}}},
+				},
+				FragmentRows: 1,
+			})
+			client := newClient(t, srv)
+
+			iter, err := client.Query(context.Background(), "db", kusto.NewStmt("Events"), test.options...)
+			require.NoError(t, err)
+			names, err := readRows(iter)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a", "b", "c"}, names)
+			_, err = iter.GetQueryCompletionInformation()
+			assert.NoError(t, err)
+
+			requests := srv.Requests()
+			require.Len(t, requests, 1)
+			assert.False(t, requests[0].Mgmt)
+			assert.Equal(t, "db", requests[0].DB)
+			assert.Equal(t, "Events", requests[0].CSL)
+			assert.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+			if test.option != "" {
+				assert.Equal(t, true, requests[0].Options[test.option])
+			}
+		})
+	}
+}
+
+func TestQueryProgress(t *testing.T) {
+	t.Parallel()
+
+	srv := testserver.New()
+	defer srv.Close()
+	srv.OnQuery("Events", testserver.Response{Tables: []testserver.Table{{Columns: testColumns, Rows: testRows}}, FragmentRows: 2})
+	client := newClient(t, srv)
+
+	var (
+		mu       sync.Mutex
+		progress []float64
+	)
+	iter, err := client.Query(context.Background(), "db", kusto.NewStmt("Events"), kusto.WithProgressCallback(func(_ int64, percent float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, percent)
+	}))
+	require.NoError(t, err)
+	_, err = readRows(iter)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(progress) > 0 && progress[len(progress)-1] == 100
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestQueryToJsonAndParameters(t *testing.T) {
+	t.Parallel()
+
+	srv := testserver.New()
+	defer srv.Close()
+	stmt := kusto.NewStmt("Events | where Name == ").AddParam("name", "a")
+	srv.OnQuery(stmt.String(), testserver.Response{Tables: []testserver.Table{{Columns: testColumns, Rows: testRows[:1]}}})
+	client := newClient(t, srv, kusto.WithRequestCompression(1))
+
+	got, err := client.QueryToJson(context.Background(), "db", stmt)
+	require.NoError(t, err)
+	assert.Contains(t, got, `"FrameType":"DataTable"`)
+	assert.Contains(t, got, `["a",1]`)
+
+	requests := srv.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, map[string]string{"name": "a"}, requests[0].Parameters)
+}
+
+func TestMgmt(t *testing.T) {
+	t.Parallel()
+
+	srv := testserver.New()
+	defer srv.Close()
+	srv.OnMgmt(".show tables", testserver.Response{Tables: []testserver.Table{{Columns: testColumns, Rows: testRows}}})
+	srv.OnMgmt(".show tables details", testserver.Response{Tables: []testserver.Table{
+		{Columns: testColumns, Rows: testRows[:1]},
+		{Columns: testColumns, Rows: testRows[1:]},
+	}})
+	client := newClient(t, srv)
+
+	iter, err := client.Mgmt(context.Background(), "db", kusto.NewStmt(".show tables"))
+	require.NoError(t, err)
+	names, err := readRows(iter)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	// The tables of a command with a table of contents are all results.
+	iter, err = client.Mgmt(context.Background(), "db", kusto.NewStmt(".show tables details"))
+	require.NoError(t, err)
+	names, err = readRows(iter)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	requests := srv.Requests()
+	require.Len(t, requests, 2)
+	assert.True(t, requests[0].Mgmt)
+	assert.Equal(t, ".show tables", requests[0].CSL)
+}
+
+func TestFaults(t *testing.T) {
+	t.Parallel()
+
+	srv := testserver.New()
+	defer srv.Close()
+	ok := testserver.Response{Tables: []testserver.Table{{Columns: testColumns, Rows: testRows}}, FragmentRows: 1}
+
+	throttled := testserver.Throttled(10 * time.Millisecond)
+	throttled.Times = 1
+	srv.OnQuery("Throttled", throttled)
+	srv.OnQuery("Throttled", ok)
+
+	reset := ok
+	reset.ResetAfterFrames = 3
+	srv.OnQuery("Reset", reset)
+
+	srv.OnQuery("Denied", testserver.Response{Status: http.StatusForbidden, Error: testserver.ServiceError{Code: "Forbidden", Permanent: true}})
+
+	t.Run("Throttled once, then retried", func(t *testing.T) {
+		client := newClient(t, srv, kusto.WithRetryOptions(1, time.Millisecond, time.Second))
+		iter, err := client.Query(context.Background(), "db", kusto.NewStmt("Throttled"))
+		require.NoError(t, err)
+		names, err := readRows(iter)
+		require.NoError(t, err)
+		assert.Len(t, names, 3)
+	})
+
+	t.Run("Throttled without retries", func(t *testing.T) {
+		client := newClient(t, srv)
+		throttled.Times = 0
+		srv.OnRequest(func(r testserver.Request) bool { return r.CSL == "Always throttled" }, throttled)
+		_, err := client.Query(context.Background(), "db", kusto.NewStmt("Always throttled"))
+		require.Error(t, err)
+		var httpErr *errors.HttpError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusTooManyRequests, httpErr.StatusCode)
+		d, ok := errors.RetryAfter(err)
+		require.True(t, ok)
+		assert.Equal(t, 10*time.Millisecond, d)
+	})
+
+	t.Run("Connection reset in the middle of the stream", func(t *testing.T) {
+		client := newClient(t, srv)
+		iter, err := client.Query(context.Background(), "db", kusto.NewStmt("Reset"))
+		require.NoError(t, err)
+		_, err = readRows(iter)
+		require.Error(t, err)
+	})
+
+	t.Run("Error status", func(t *testing.T) {
+		client := newClient(t, srv)
+		_, err := client.Query(context.Background(), "db", kusto.NewStmt("Denied"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Forbidden")
+	})
+
+	t.Run("No response", func(t *testing.T) {
+		client := newClient(t, srv)
+		_, err := client.Query(context.Background(), "db", kusto.NewStmt("Unknown"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no response")
+	})
+}
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+
+	const latency = 50 * time.Millisecond
+	srv := testserver.New(testserver.WithLatency(latency))
+	defer srv.Close()
+	srv.OnQuery("Slow", testserver.Response{
+		Tables:       []testserver.Table{{Columns: testColumns, Rows: testRows}},
+		FragmentRows: 1,
+		FrameDelay:   time.Millisecond,
+	})
+	client := newClient(t, srv)
+
+	start := time.Now()
+	iter, err := client.Query(context.Background(), "db", kusto.NewStmt("Slow"))
+	require.NoError(t, err)
+	_, err = readRows(iter)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), latency)
+
+	// A deadline shorter than the latency fails the call.
+	ctx, cancel := context.WithTimeout(context.Background(), latency/5)
+	defer cancel()
+	_, err = client.Query(ctx, "db", kusto.NewStmt("Slow"))
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "deadline") || strings.Contains(err.Error(), "canceled"), err.Error())
+}
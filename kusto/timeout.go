@@ -0,0 +1,106 @@
+package kusto
+
+// timeout.go derives the servertimeout request property from the deadline of the context.
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+)
+
+// DefaultTimeoutHeadroom is the time left between the servertimeout and the deadline of the context, unless
+// WithTimeoutHeadroom() is used.
+const DefaultTimeoutHeadroom = 5 * time.Second
+
+const (
+	// minServerTimeout is the smallest servertimeout derived from the deadline of the context.
+	minServerTimeout = time.Second
+	// maxServerTimeout is the largest servertimeout the service accepts.
+	maxServerTimeout = time.Hour
+)
+
+// WithTimeoutHeadroom sets how long before the deadline of the context the server is asked to time out.
+// When the context of a call has a deadline, the servertimeout request property is set to the time left before the
+// deadline minus the headroom, but never less than one second. This gives the server time to report its timeout
+// before the client gives up on the call, which would otherwise fail with an error that does not say which side timed
+// out. The default is DefaultTimeoutHeadroom, and zero sets the servertimeout to the deadline itself.
+// The ServerTimeout() option sets the servertimeout of a query regardless of the deadline.
+func WithTimeoutHeadroom(d time.Duration) Option {
+	return func(c *Client) {
+		if d >= 0 {
+			c.timeoutHeadroom = &d
+		}
+	}
+}
+
+// queryTimeoutHeadroom returns options preceded by the timeout headroom of the client, if it was set.
+func (c *Client) queryTimeoutHeadroom(options []QueryOption) []QueryOption {
+	if c.timeoutHeadroom == nil {
+		return options
+	}
+	d := *c.timeoutHeadroom
+	headroom := func(q *queryOptions) error {
+		q.timeoutHeadroom = d
+		return nil
+	}
+	return append([]QueryOption{headroom}, options...)
+}
+
+// mgmtTimeoutHeadroom returns options preceded by the timeout headroom of the client, if it was set.
+func (c *Client) mgmtTimeoutHeadroom(options []MgmtOption) []MgmtOption {
+	if c.timeoutHeadroom == nil {
+		return options
+	}
+	d := *c.timeoutHeadroom
+	headroom := func(m *mgmtOptions) error {
+		m.timeoutHeadroom = d
+		return nil
+	}
+	return append([]MgmtOption{headroom}, options...)
+}
+
+// setServerTimeout sets the servertimeout from the deadline of ctx, leaving headroom, unless it was set by an option.
+func setServerTimeout(ctx context.Context, props *requestProperties, headroom time.Duration) {
+	if _, ok := props.option(ServerTimeoutValue); ok {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	props.setOption(ServerTimeoutValue, value.Timespan{Valid: true, Value: serverTimeout(deadline.Sub(nower()), headroom)}.Marshal())
+}
+
+// serverTimeout returns the servertimeout for a call with remaining time before its deadline.
+func serverTimeout(remaining, headroom time.Duration) time.Duration {
+	d := remaining - headroom
+	if d < minServerTimeout {
+		return minServerTimeout
+	}
+	return d
+}
+
+// validateServerTimeout returns an error if the servertimeout set by an option, such as CustomQueryOption(), is a
+// string that is not a timespan the service accepts, which it would otherwise reject or ignore. Values of other types
+// are sent as they are.
+func validateServerTimeout(op errors.Op, props *requestProperties) error {
+	v, ok := props.option(ServerTimeoutValue)
+	if !ok {
+		return nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	var t value.Timespan
+	if err := t.Unmarshal(s); err != nil {
+		return errors.ES(op, errors.KClientArgs, "the %s option must be a timespan, such as \"00:10:00\", but was %q: %s", ServerTimeoutValue, s, err).SetNoRetry()
+	}
+	if t.Value <= 0 || t.Value > maxServerTimeout {
+		return errors.ES(op, errors.KClientArgs, "the %s option was set to %s, but must be more than 0 and can't be more than %v, the maximum of the service",
+			ServerTimeoutValue, s, maxServerTimeout).SetNoRetry()
+	}
+	return nil
+}
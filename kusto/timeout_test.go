@@ -0,0 +1,182 @@
+package kusto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		timeout  time.Duration
+		headroom *time.Duration
+		mgmt     bool
+		options  []QueryOption
+		// ctxOptions are set with ContextWithQueryOptions().
+		ctxOptions []QueryOption
+		// want is the servertimeout that is sent, which is compared to the second when exact is false.
+		want  string
+		exact bool
+	}{
+		{
+			// Query() sets a deadline of 4 minutes when the context has none.
+			desc: "Default deadline",
+			want: "00:03:55",
+		},
+		{
+			desc:    "Default headroom",
+			timeout: time.Minute,
+			want:    "00:00:55",
+		},
+		{
+			desc:     "No headroom",
+			timeout:  time.Minute,
+			headroom: durationPtr(0),
+			want:     "00:01:00",
+		},
+		{
+			desc:     "Headroom",
+			timeout:  10 * time.Minute,
+			headroom: durationPtr(time.Minute),
+			want:     "00:09:00",
+		},
+		{
+			desc:    "Floor",
+			timeout: 3 * time.Second,
+			want:    "00:00:01",
+			exact:   true,
+		},
+		{
+			desc:    "ServerTimeout wins over the deadline",
+			timeout: time.Minute,
+			options: []QueryOption{ServerTimeout(2*time.Minute + 30*time.Second)},
+			want:    "00:02:30",
+			exact:   true,
+		},
+		{
+			desc:    "ServerTimeout without a deadline",
+			options: []QueryOption{ServerTimeout(90 * time.Second)},
+			want:    "00:01:30",
+			exact:   true,
+		},
+		{
+			desc:    "CustomQueryOption wins over the deadline",
+			timeout: time.Minute,
+			options: []QueryOption{CustomQueryOption(ServerTimeoutValue, "00:00:10")},
+			want:    "00:00:10",
+			exact:   true,
+		},
+		{
+			desc:       "ServerTimeout from the context",
+			timeout:    time.Minute,
+			ctxOptions: []QueryOption{ServerTimeout(20 * time.Second)},
+			want:       "00:00:20",
+			exact:      true,
+		},
+		{
+			desc:     "Mgmt headroom",
+			timeout:  10 * time.Minute,
+			headroom: durationPtr(time.Minute),
+			mgmt:     true,
+			want:     "00:09:00",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &recordTransport{}
+			client := newTestClient(t, "https://timeout.kusto.windows.net", transport)
+			if test.headroom != nil {
+				WithTimeoutHeadroom(*test.headroom)(client)
+			}
+
+			ctx := context.Background()
+			if test.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+			if test.ctxOptions != nil {
+				ctx = ContextWithQueryOptions(ctx, test.ctxOptions...)
+			}
+
+			if test.mgmt {
+				iter, err := client.Mgmt(ctx, "db", NewStmt(".show tables"))
+				if err == nil {
+					iter.Stop()
+				}
+			} else {
+				iter, err := client.Query(ctx, "db", NewStmt("T"), test.options...)
+				require.NoError(t, err)
+				iter.Stop()
+			}
+
+			sent := transport.sent()
+			require.Len(t, sent, 1)
+			got, ok := sent[0].Properties.option(ServerTimeoutValue)
+			require.True(t, ok)
+			if test.exact {
+				assert.Equal(t, test.want, got)
+				return
+			}
+
+			// The time spent before the request is sent is not known, so it is left out.
+			var gotTimespan, wantTimespan value.Timespan
+			require.NoError(t, gotTimespan.Unmarshal(got))
+			require.NoError(t, wantTimespan.Unmarshal(test.want))
+			assert.InDelta(t, wantTimespan.Value, gotTimespan.Value, float64(2*time.Second), "got %s, want %s", got, test.want)
+		})
+	}
+}
+
+func TestServerTimeoutArgs(t *testing.T) {
+	t.Parallel()
+
+	for _, d := range []time.Duration{0, -time.Second, time.Hour + time.Second} {
+		_, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, ServerTimeout(d))
+		assert.Error(t, err, "ServerTimeout(%s)", d)
+	}
+
+	opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, ServerTimeout(time.Hour))
+	require.NoError(t, err)
+	got, _ := opts.requestProperties.option(ServerTimeoutValue)
+	assert.Equal(t, "01:00:00", got)
+}
+
+func TestServerTimeoutHeadroom(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		remaining, headroom, want time.Duration
+	}{
+		{remaining: time.Minute, headroom: 5 * time.Second, want: 55 * time.Second},
+		{remaining: time.Minute, headroom: 0, want: time.Minute},
+		{remaining: 5500 * time.Millisecond, headroom: 5 * time.Second, want: time.Second},
+		{remaining: 6500 * time.Millisecond, headroom: 5 * time.Second, want: 1500 * time.Millisecond},
+		{remaining: -time.Second, headroom: 5 * time.Second, want: time.Second},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, serverTimeout(test.remaining, test.headroom), "serverTimeout(%s, %s)", test.remaining, test.headroom)
+	}
+
+	// Negative headrooms are ignored.
+	client := &Client{}
+	WithTimeoutHeadroom(-time.Second)(client)
+	assert.Nil(t, client.timeoutHeadroom)
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
@@ -3,7 +3,10 @@ package kusto
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -51,6 +54,238 @@ func structToKustoValues(cols table.Columns, p interface{}) (value.Values, error
 	return row, nil
 }
 
+// StructToKustoValues is the reverse of table.Row.ToStruct(): it returns the columns and the values of a row for v,
+// which must be a struct or a pointer to a struct. Every exported field is a column, named by the `kusto` tag of the
+// field or else by its name, in the order of the fields. Fields with the `kusto:"-"` tag are skipped and the fields of
+// embedded structs are promoted as in Go. The types of the columns follow the Go types of the fields:
+//
+//	bool				bool
+//	int32, int16, int8, uint16, uint8	int
+//	int64, int, uint64, uint32, uint	long
+//	float64, float32			real
+//	string				string
+//	time.Time			datetime
+//	time.Duration			timespan
+//	uuid.UUID			guid
+//	*big.Float			decimal
+//	map, slice, array, struct	dynamic
+//	value.Kusto types		their type
+//
+// Nil pointers, maps and slices are null values.
+func StructToKustoValues(v interface{}) (table.Columns, []value.Kusto, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil, fmt.Errorf("StructToKustoValues() received a nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("StructToKustoValues() requires a struct or a pointer to a struct, was %T", v)
+	}
+
+	var (
+		cols     table.Columns
+		fields   [][]int
+		skip     [][]int
+		colNames = map[string]string{}
+	)
+	for _, sf := range reflect.VisibleFields(rv.Type()) {
+		if hasIndexPrefix(sf.Index, skip) {
+			continue
+		}
+		tag := strings.TrimSpace(sf.Tag.Get("kusto"))
+		if tag == "-" {
+			skip = append(skip, sf.Index)
+			continue
+		}
+		if sf.Anonymous {
+			et := sf.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				if tag == "" {
+					// Its fields are promoted, and listed after it.
+					continue
+				}
+				skip = append(skip, sf.Index)
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := sf.Name
+		if tag != "" {
+			name = tag
+		}
+		if other, ok := colNames[name]; ok {
+			return nil, nil, fmt.Errorf("column %s is set by both fields %s and %s", name, other, sf.Name)
+		}
+		colNames[name] = sf.Name
+
+		ct, err := goColumnType(sf.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		cols = append(cols, table.Column{Name: name, Type: ct})
+		fields = append(fields, sf.Index)
+	}
+
+	row, err := defaultRow(cols)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, index := range fields {
+		fv, err := rv.FieldByIndexErr(index)
+		if err != nil {
+			// A field of a nil embedded pointer is null.
+			continue
+		}
+		kv, err := goKustoValue(fv, cols[i].Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %s could not be encoded from field %s: %w", cols[i].Name, colNames[cols[i].Name], err)
+		}
+		if kv != nil {
+			row[i] = kv
+		}
+	}
+
+	return cols, row, nil
+}
+
+// hasIndexPrefix returns true if one of prefixes is a prefix of index.
+func hasIndexPrefix(index []int, prefixes [][]int) bool {
+	for _, p := range prefixes {
+		if len(p) <= len(index) && reflect.DeepEqual(p, index[:len(p)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// goColumnType returns the type of the column for a field of Go type t.
+func goColumnType(t reflect.Type) (types.Column, error) {
+	if t == reflect.TypeOf(new(big.Float)) {
+		return types.Decimal, nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(value.Bool{}):
+		return types.Bool, nil
+	case reflect.TypeOf(value.DateTime{}), reflect.TypeOf(time.Time{}):
+		return types.DateTime, nil
+	case reflect.TypeOf(value.Dynamic{}):
+		return types.Dynamic, nil
+	case reflect.TypeOf(value.GUID{}), reflect.TypeOf(uuid.UUID{}):
+		return types.GUID, nil
+	case reflect.TypeOf(value.Int{}):
+		return types.Int, nil
+	case reflect.TypeOf(value.Long{}):
+		return types.Long, nil
+	case reflect.TypeOf(value.Real{}):
+		return types.Real, nil
+	case reflect.TypeOf(value.String{}):
+		return types.String, nil
+	case reflect.TypeOf(value.Timespan{}), reflect.TypeOf(time.Duration(0)):
+		return types.Timespan, nil
+	case reflect.TypeOf(value.Decimal{}):
+		return types.Decimal, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return types.Bool, nil
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint16, reflect.Uint8:
+		return types.Int, nil
+	case reflect.Int64, reflect.Int, reflect.Uint64, reflect.Uint32, reflect.Uint:
+		return types.Long, nil
+	case reflect.Float64, reflect.Float32:
+		return types.Real, nil
+	case reflect.String:
+		return types.String, nil
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Interface:
+		return types.Dynamic, nil
+	}
+	return "", fmt.Errorf("type %s has no Kusto column type", t)
+}
+
+// goKustoValue returns the value of a column of type ct for the field v, or nil if it is null.
+func goKustoValue(v reflect.Value, ct types.Column) (value.Kusto, error) {
+	if ct == types.Decimal && v.Type() == reflect.TypeOf(new(big.Float)) {
+		return convertDecimal(v)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	switch ct {
+	case types.Bool:
+		if v.Kind() == reflect.Bool {
+			return value.Bool{Value: v.Bool(), Valid: true}, nil
+		}
+		return convertBool(v)
+	case types.DateTime:
+		return convertDateTime(v)
+	case types.Dynamic:
+		if d, ok := v.Interface().(value.Dynamic); ok {
+			return d, nil
+		}
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return value.Dynamic{Value: b, Valid: true}, nil
+	case types.GUID:
+		return convertGUID(v)
+	case types.Int:
+		switch v.Kind() {
+		case reflect.Int32, reflect.Int16, reflect.Int8:
+			return value.Int{Value: int32(v.Int()), Valid: true}, nil
+		case reflect.Uint16, reflect.Uint8:
+			return value.Int{Value: int32(v.Uint()), Valid: true}, nil
+		}
+		return convertInt(v)
+	case types.Long:
+		switch v.Kind() {
+		case reflect.Int64, reflect.Int:
+			return value.Long{Value: v.Int(), Valid: true}, nil
+		case reflect.Uint64, reflect.Uint32, reflect.Uint:
+			if v.Uint() > math.MaxInt64 {
+				return nil, fmt.Errorf("%d overflows a long", v.Uint())
+			}
+			return value.Long{Value: int64(v.Uint()), Valid: true}, nil
+		}
+		return convertLong(v)
+	case types.Real:
+		switch v.Kind() {
+		case reflect.Float64, reflect.Float32:
+			return value.Real{Value: v.Float(), Valid: true}, nil
+		}
+		return convertReal(v)
+	case types.String:
+		if v.Kind() == reflect.String {
+			return value.String{Value: v.String(), Valid: true}, nil
+		}
+		return convertString(v)
+	case types.Timespan:
+		return convertTimespan(v)
+	case types.Decimal:
+		return convertDecimal(v)
+	}
+	return nil, fmt.Errorf("column type %s is not supported", ct)
+}
+
 // fieldConvert will attempt to take the value held in v and convert it to the appropriate types.KustoValue
 // that is described in colData in the correct location in row.
 func fieldConvert(colData columnData, v reflect.Value, row value.Values) error {
@@ -421,6 +656,14 @@ func convertString(v reflect.Value) (value.String, error) {
 func convertDecimal(v reflect.Value) (value.Decimal, error) {
 	t := v.Type()
 
+	// Was a *big.Float, so format it with all its digits.
+	if t == reflect.TypeOf(new(big.Float)) {
+		if v.IsNil() {
+			return value.Decimal{}, nil
+		}
+		return value.NewDecimalFromFloat(v.Interface().(*big.Float)), nil
+	}
+
 	// If it is a pointer, dereference it.
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -437,5 +680,10 @@ func convertDecimal(v reflect.Value) (value.Decimal, error) {
 		return value.Decimal{Value: v.Interface().(string), Valid: true}, nil
 	}
 
-	return value.Decimal{}, fmt.Errorf("value was expected to be either a types.Decimal, *string or string, was %T", v.Interface())
+	// Was a float64, so format it with all its digits.
+	if t == reflect.TypeOf(float64(0)) {
+		return value.Decimal{Value: strconv.FormatFloat(v.Float(), 'f', -1, 64), Valid: true}, nil
+	}
+
+	return value.Decimal{}, fmt.Errorf("value was expected to be either a types.Decimal, string, float64, *big.Float or a pointer to them, was %T", v.Interface())
 }
@@ -2,6 +2,7 @@ package kusto
 
 import (
 	"encoding/json"
+	"math/big"
 	"reflect"
 	"testing"
 	"time"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/kylelemons/godebug/pretty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStructToKustoValues(t *testing.T) {
@@ -567,6 +570,9 @@ func TestConvertDecimal(t *testing.T) {
 		{value: val, want: value.Decimal{Value: "1.3333333333", Valid: true}},
 		{value: ptr, want: value.Decimal{Value: "1.3333333333", Valid: true}},
 		{value: ty, want: value.Decimal{Value: "1.3333333333", Valid: true}},
+		{value: 1.25, want: value.Decimal{Value: "1.25", Valid: true}},
+		{value: bigDecimal(t, "1234567890123456789012345678.901234"), want: value.Decimal{Value: "1234567890123456789012345678.901234", Valid: true}},
+		{value: (*big.Float)(nil), want: value.Decimal{}},
 	}
 	for _, test := range tests {
 		got, err := convertDecimal(reflect.ValueOf(test.value))
@@ -618,3 +624,147 @@ func mustMarshal(i interface{}) []byte {
 	}
 	return b
 }
+
+func bigDecimal(t *testing.T, s string) *big.Float {
+	f, _, err := big.ParseFloat(s, 10, value.DecimalPrec, big.ToNearestEven)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+type encodeBase struct {
+	ID int64 `kusto:"Id"`
+}
+
+func TestStructToKustoValuesInferColumns(t *testing.T) {
+	t.Parallel()
+
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	id := uuid.MustParse("6f6b7c0e-6d5a-4c3e-9c2b-1a2b3c4d5e6f")
+	name := "node"
+
+	type record struct {
+		encodeBase
+		Name     *string
+		Missing  *string
+		Count    int32
+		Total    uint
+		Ratio    float32
+		Seen     time.Time `kusto:"LastSeen"`
+		Duration time.Duration
+		Node     uuid.UUID
+		Amount   *big.Float
+		Tags     []string
+		Props    map[string]int
+		NoProps  map[string]int
+		Level    value.Long
+		Skipped  string `kusto:"-"`
+		internal string
+	}
+
+	tests := []struct {
+		desc     string
+		v        interface{}
+		wantCols table.Columns
+		want     []value.Kusto
+		err      bool
+	}{
+		{
+			desc: "All types",
+			v: &record{
+				encodeBase: encodeBase{ID: 7},
+				Name:       &name,
+				Count:      3,
+				Total:      4,
+				Ratio:      0.5,
+				Seen:       seen,
+				Duration:   time.Minute,
+				Node:       id,
+				Amount:     bigDecimal(t, "1.25"),
+				Tags:       []string{"a"},
+				Props:      map[string]int{"b": 1},
+				Level:      value.Long{Value: 9, Valid: true},
+				Skipped:    "skipped",
+				internal:   "internal",
+			},
+			wantCols: table.Columns{
+				{Name: "Id", Type: types.Long},
+				{Name: "Name", Type: types.String},
+				{Name: "Missing", Type: types.String},
+				{Name: "Count", Type: types.Int},
+				{Name: "Total", Type: types.Long},
+				{Name: "Ratio", Type: types.Real},
+				{Name: "LastSeen", Type: types.DateTime},
+				{Name: "Duration", Type: types.Timespan},
+				{Name: "Node", Type: types.GUID},
+				{Name: "Amount", Type: types.Decimal},
+				{Name: "Tags", Type: types.Dynamic},
+				{Name: "Props", Type: types.Dynamic},
+				{Name: "NoProps", Type: types.Dynamic},
+				{Name: "Level", Type: types.Long},
+			},
+			want: []value.Kusto{
+				value.Long{Value: 7, Valid: true},
+				value.String{Value: "node", Valid: true},
+				value.String{},
+				value.Int{Value: 3, Valid: true},
+				value.Long{Value: 4, Valid: true},
+				value.Real{Value: 0.5, Valid: true},
+				value.DateTime{Value: seen, Valid: true},
+				value.Timespan{Value: time.Minute, Valid: true},
+				value.GUID{Value: id, Valid: true},
+				value.Decimal{Value: "1.25", Valid: true},
+				value.Dynamic{Value: []byte(`["a"]`), Valid: true},
+				value.Dynamic{Value: []byte(`{"b":1}`), Valid: true},
+				value.Dynamic{},
+				value.Long{Value: 9, Valid: true},
+			},
+		},
+		{
+			desc: "Nil embedded pointer is null",
+			v: struct {
+				*encodeBase
+				Name string
+			}{Name: "node"},
+			wantCols: table.Columns{{Name: "Id", Type: types.Long}, {Name: "Name", Type: types.String}},
+			want:     []value.Kusto{value.Long{}, value.String{Value: "node", Valid: true}},
+		},
+		{
+			desc: "Duplicate column",
+			v: struct {
+				A string `kusto:"B"`
+				B string
+			}{},
+			err: true,
+		},
+		{
+			desc: "Unsupported type",
+			v: struct {
+				C chan int
+			}{},
+			err: true,
+		},
+		{
+			desc: "Not a struct",
+			v:    &name,
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cols, got, err := StructToKustoValues(test.v)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCols, cols)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
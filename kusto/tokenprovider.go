@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-kusto-go/kusto/utils"
 
@@ -20,6 +22,32 @@ type TokenProvider struct {
 	initOnce    utils.OnceWithInit[*tokenWrapperResult] //To ensure tokenprovider will be initialized only once while aquiring token
 	scopes      []string                                //Contains scopes of the auth token
 	http        atomic.Value                            //Contains the http client to be used for token provider
+	refreshSkew time.Duration                           //Set by WithTokenRefreshSkew(), 0 for DefaultTokenRefreshSkew
+
+	cacheMu     sync.Mutex         //Guards token, refreshing, stopRefresh and closed
+	token       azcore.AccessToken //The cached token, zero until the first one is acquired
+	refreshing  bool               //Set while a proactive refresh of token runs in the background
+	stopRefresh func()             //Cancels the proactive refresh while refreshing is set
+	closed      bool               //Set by Close(), after which no proactive refresh is started
+	acquireMu   sync.Mutex         //Makes the calls waiting for a token share a single acquisition
+}
+
+// DefaultTokenRefreshSkew is how long before its expiry a cached token is refreshed, see WithTokenRefreshSkew().
+const DefaultTokenRefreshSkew = 2 * time.Minute
+
+// tokenRefreshTimeout bounds a proactive refresh, which runs in the background without the context of a call.
+const tokenRefreshTimeout = time.Minute
+
+// WithTokenRefreshSkew sets how long before its expiry the token of the Client is refreshed, DefaultTokenRefreshSkew by
+// default. The token is cached and shared by all calls until then. Once within skew of its expiry, the calls keep using
+// it while a new one is acquired in the background, so that they never wait for a token except for the first one or
+// after the token expired. Values <= 0 are ignored.
+func WithTokenRefreshSkew(skew time.Duration) Option {
+	return func(c *Client) {
+		if skew > 0 && c.auth.TokenProvider != nil {
+			c.auth.TokenProvider.refreshSkew = skew
+		}
+	}
 }
 
 // tokenProvider need to be received as reference, to reflect updations to the structs
@@ -35,8 +63,17 @@ func (tkp *TokenProvider) AcquireToken(ctx context.Context) (string, string, err
 		}
 	}
 
+	if cred, ok := tkp.tokenCred.(*onBehalfOfCredential); ok {
+		// The tokens are per user, cached by the credential.
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: tkp.scopes})
+		if err != nil {
+			return "", "", err
+		}
+		return token.Token, tkp.tokenScheme, nil
+	}
+
 	if tkp.tokenCred != nil {
-		token, err := tkp.tokenCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: tkp.scopes})
+		token, err := tkp.cachedToken(ctx)
 		if err != nil {
 			return "", "", err
 		}
@@ -46,10 +83,107 @@ func (tkp *TokenProvider) AcquireToken(ctx context.Context) (string, string, err
 	return "", "", fmt.Errorf("Error: No token info present in token provider")
 }
 
+// cachedToken returns the cached token of tkp.tokenCred, acquiring it if there is none or it expired, and refreshing it
+// in the background if it expires within the refresh skew.
+func (tkp *TokenProvider) cachedToken(ctx context.Context) (azcore.AccessToken, error) {
+	skew := tkp.refreshSkew
+	if skew <= 0 {
+		skew = DefaultTokenRefreshSkew
+	}
+
+	tkp.cacheMu.Lock()
+	token := tkp.token
+	now := nower()
+	if token.Token != "" && now.Before(token.ExpiresOn) {
+		if !now.Before(token.ExpiresOn.Add(-skew)) && !tkp.refreshing && !tkp.closed {
+			ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+			tkp.refreshing = true
+			tkp.stopRefresh = cancel
+			go tkp.refresh(ctx, cancel)
+		}
+		tkp.cacheMu.Unlock()
+		return token, nil
+	}
+	tkp.cacheMu.Unlock()
+
+	tkp.acquireMu.Lock()
+	defer tkp.acquireMu.Unlock()
+
+	// Another call may have acquired the token while this one waited.
+	tkp.cacheMu.Lock()
+	token = tkp.token
+	tkp.cacheMu.Unlock()
+	if token.Token != "" && nower().Before(token.ExpiresOn) {
+		return token, nil
+	}
+
+	token, err := tkp.tokenCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: tkp.scopes})
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	tkp.storeToken(token)
+	return token, nil
+}
+
+// refresh acquires a new token in the background, keeping the cached one if it fails, in which case the next call
+// tries again. cancel cancels ctx, which bounds the refresh.
+func (tkp *TokenProvider) refresh(ctx context.Context, cancel context.CancelFunc) {
+	defer cancel()
+
+	token, err := tkp.tokenCred.GetToken(ctx, policy.TokenRequestOptions{Scopes: tkp.scopes})
+
+	tkp.cacheMu.Lock()
+	tkp.refreshing = false
+	tkp.stopRefresh = nil
+	tkp.cacheMu.Unlock()
+	if err == nil {
+		tkp.storeToken(token)
+	}
+}
+
+// storeToken caches token, unless a token expiring later is cached already.
+func (tkp *TokenProvider) storeToken(token azcore.AccessToken) {
+	tkp.cacheMu.Lock()
+	defer tkp.cacheMu.Unlock()
+	if token.ExpiresOn.Before(tkp.token.ExpiresOn) {
+		return
+	}
+	tkp.token = token
+}
+
+// Close stops the proactive refresh of the token in the background, cancelling the one that runs, if any. The cached
+// token is still used until it expires, after which a call acquires a new one. Close is called by Client.Close(), and
+// can be called more than once.
+func (tkp *TokenProvider) Close() {
+	tkp.cacheMu.Lock()
+	defer tkp.cacheMu.Unlock()
+	tkp.closed = true
+	if tkp.stopRefresh != nil {
+		tkp.stopRefresh()
+	}
+}
+
 func (tkp *TokenProvider) AuthorizationRequired() bool {
 	return !(tkp.initOnce == nil && tkp.tokenCred == nil && isEmpty(tkp.customToken))
 }
 
+// tokenCallbackCredential is the azcore.TokenCredential of WithTokenCallback(). Its tokens have no expiry, so that they
+// are not cached by the TokenProvider.
+type tokenCallbackCredential func(ctx context.Context, resource string) (string, error)
+
+// GetToken implements azcore.TokenCredential, passing the resource of the first scope to the callback.
+func (f tokenCallbackCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	resource := ""
+	if len(opts.Scopes) > 0 {
+		resource = strings.TrimSuffix(opts.Scopes[0], "/.default")
+	}
+	token, err := f(ctx, resource)
+	if err != nil {
+		return azcore.AccessToken{}, fmt.Errorf("error: the token callback failed: %w", err)
+	}
+	return azcore.AccessToken{Token: token}, nil
+}
+
 type tokenWrapperResult struct {
 	credential azcore.TokenCredential
 	scopes     []string
@@ -73,6 +207,11 @@ func (tkp *TokenProvider) SetHttp(http *http.Client) {
 	tkp.http.Store(http)
 }
 
+// setHttpIfUnset sets the http.Client the tokens are acquired with, unless one was set already.
+func (tkp *TokenProvider) setHttpIfUnset(http *http.Client) {
+	tkp.http.CompareAndSwap(nil, http)
+}
+
 func tokenWrapper(kcsb *ConnectionStringBuilder, http func() *http.Client, f func(*CloudInfo, *azcore.ClientOptions, string) (azcore.TokenCredential, error)) (*tokenWrapperResult,
 	error) {
 	ci, cliOpts, appClientId, err := getCommonCloudInfo(kcsb, http)
@@ -85,15 +224,9 @@ func tokenWrapper(kcsb *ConnectionStringBuilder, http func() *http.Client, f fun
 		return nil, err
 	}
 
-	resourceURI := ci.KustoServiceResourceID
-	if ci.LoginMfaRequired {
-		resourceURI = strings.Replace(resourceURI, ".kusto.", ".kustomfa.", 1)
-	}
-	scopes := []string{fmt.Sprintf("%s/.default", resourceURI)}
-
 	return &tokenWrapperResult{
 		credential: credential,
-		scopes:     scopes,
+		scopes:     ci.Scopes(),
 	}, nil
 }
 
@@ -2,10 +2,18 @@ package kusto
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAcquireTokenErr(t *testing.T) {
@@ -15,18 +23,18 @@ func TestAcquireTokenErr(t *testing.T) {
 	tests := []struct {
 		name    string
 		wantErr string
-		tkp     TokenProvider
+		tkp     *TokenProvider
 	}{
 		{
 			name: "test_acquiretoken_cred",
-			tkp: TokenProvider{
+			tkp: &TokenProvider{
 				tokenCred: NewMockClient().auth.TokenProvider.tokenCred,
 			},
 			wantErr: "",
 		},
 		{
 			name: "test_acquiretoken_invalid_datasource",
-			tkp: TokenProvider{
+			tkp: &TokenProvider{
 				tokenCred: NewMockClient().auth.TokenProvider.tokenCred,
 			},
 		},
@@ -43,3 +51,232 @@ func TestAcquireTokenErr(t *testing.T) {
 	}
 
 }
+
+// countingCredential is a fake azcore.TokenCredential that counts its calls, and returns tokens expiring after
+// lifetime. Each call waits for delay, so that concurrent calls overlap.
+type countingCredential struct {
+	calls    atomic.Int32
+	lifetime time.Duration
+	delay    time.Duration
+}
+
+func (c *countingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	n := c.calls.Add(1)
+	time.Sleep(c.delay)
+	return azcore.AccessToken{Token: fmt.Sprintf("token-%d", n), ExpiresOn: time.Now().Add(c.lifetime)}, nil
+}
+
+func TestTokenCache(t *testing.T) {
+	t.Parallel()
+
+	cred := &countingCredential{lifetime: time.Hour, delay: 10 * time.Millisecond}
+	tkp := &TokenProvider{tokenCred: cred, tokenScheme: BEARER_TYPE}
+	client := newAuthTestClient(t, "https://token.kusto.windows.net", Authorization{TokenProvider: tkp}, &captureTransport{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+			if assert.NoError(t, err) {
+				iter.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, cred.calls.Load())
+
+	token, scheme, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, BEARER_TYPE, scheme)
+	assert.EqualValues(t, 1, cred.calls.Load())
+}
+
+func TestTokenCacheRefresh(t *testing.T) {
+	t.Parallel()
+
+	// The tokens expire within the skew, so that each one is refreshed in the background once it is used.
+	cred := &countingCredential{lifetime: time.Minute, delay: 10 * time.Millisecond}
+	tkp := &TokenProvider{tokenCred: cred, tokenScheme: BEARER_TYPE}
+
+	token, _, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// The calls get the cached token without waiting, while a single refresh runs.
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, _, err := tkp.AcquireToken(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+
+	require.Eventually(t, func() bool {
+		token, _, err := tkp.AcquireToken(context.Background())
+		return err == nil && token == "token-2"
+	}, time.Second, time.Millisecond)
+	assert.LessOrEqual(t, cred.calls.Load(), int32(3))
+
+	// With a skew below the lifetime of the tokens, they are not refreshed.
+	cred = &countingCredential{lifetime: time.Minute}
+	tkp = &TokenProvider{tokenCred: cred, tokenScheme: BEARER_TYPE}
+	WithTokenRefreshSkew(time.Second)(&Client{auth: Authorization{TokenProvider: tkp}})
+	for i := 0; i < 10; i++ {
+		_, _, err := tkp.AcquireToken(context.Background())
+		require.NoError(t, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 1, cred.calls.Load())
+}
+
+// blockingCredential is a fake azcore.TokenCredential whose first token expires within the refresh skew, and whose
+// later calls block until their context is done, which is sent on done.
+type blockingCredential struct {
+	calls atomic.Int32
+	done  chan error
+}
+
+func (c *blockingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	if c.calls.Add(1) == 1 {
+		return azcore.AccessToken{Token: "token-1", ExpiresOn: time.Now().Add(time.Minute)}, nil
+	}
+	<-ctx.Done()
+	c.done <- ctx.Err()
+	return azcore.AccessToken{}, ctx.Err()
+}
+
+func TestTokenProviderClose(t *testing.T) {
+	t.Parallel()
+
+	cred := &blockingCredential{done: make(chan error, 1)}
+	tkp := &TokenProvider{tokenCred: cred, tokenScheme: BEARER_TYPE}
+
+	// The first token is acquired, the second call starts a refresh, which blocks.
+	for i := 0; i < 2; i++ {
+		token, _, err := tkp.AcquireToken(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", token)
+	}
+	require.Eventually(t, func() bool { return cred.calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	tkp.Close()
+	tkp.Close()
+	select {
+	case err := <-cred.done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Close() did not cancel the refresh")
+	}
+
+	// The cached token is still used, without starting a refresh.
+	token, _, err := tkp.AcquireToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 2, cred.calls.Load())
+}
+
+func TestTokenCallback(t *testing.T) {
+	t.Parallel()
+
+	const endpoint = "https://token-callback.kusto.windows.net"
+	query := func(client *Client) error {
+		iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+		if err != nil {
+			return err
+		}
+		iter.Stop()
+		return nil
+	}
+
+	var mu sync.Mutex
+	var resources []string
+	fail := false
+	callback := func(ctx context.Context, resource string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return "", fmt.Errorf("broker unavailable")
+		}
+		resources = append(resources, resource)
+		return fmt.Sprintf("broker-token-%d", len(resources)), nil
+	}
+
+	transport := &oboTransport{}
+	client, err := New(NewConnectionStringBuilder(endpoint).WithTokenCallback(callback), WithHttpClient(&http.Client{Transport: transport}))
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The callback is called for every request, with the resource of the cluster.
+	require.NoError(t, query(client))
+	require.NoError(t, query(client))
+	assert.Equal(t, []string{endpoint, endpoint}, resources)
+	sent := transport.sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "Bearer broker-token-1", sent[0].Header.Get("Authorization"))
+	assert.Equal(t, "Bearer broker-token-2", sent[1].Header.Get("Authorization"))
+
+	mu.Lock()
+	fail = true
+	mu.Unlock()
+	err = query(client)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broker unavailable")
+	assert.Len(t, transport.sent(), 2)
+
+	assert.Panics(t, func() { NewConnectionStringBuilder(endpoint).WithTokenCallback(nil) })
+}
+
+func TestStaticToken(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		auth     Authorization
+		wantAuth string
+	}{
+		{
+			desc:     "Bearer token",
+			auth:     Authorization{TokenProvider: mustTokenProvider(t, NewConnectionStringBuilder("https://static.kusto.windows.net").WithBearerToken("static"))},
+			wantAuth: "Bearer static",
+		},
+		{
+			desc: "No token provider",
+			auth: Authorization{},
+		},
+		{
+			desc: "No authentication",
+			auth: Authorization{TokenProvider: mustTokenProvider(t, NewConnectionStringBuilder("https://static.kusto.windows.net"))},
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			transport := &captureTransport{}
+			client := newAuthTestClient(t, "https://static.kusto.windows.net", test.auth, transport)
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+			require.NoError(t, err)
+			iter.Stop()
+			sent := transport.sent()
+			require.Len(t, sent, 1)
+			assert.Equal(t, test.wantAuth, sent[0].Header.Get("Authorization"))
+		})
+	}
+}
+
+func mustTokenProvider(t *testing.T, kcsb *ConnectionStringBuilder) *TokenProvider {
+	tkp, err := kcsb.newTokenProvider()
+	require.NoError(t, err)
+	return tkp
+}
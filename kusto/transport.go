@@ -0,0 +1,87 @@
+package kusto
+
+// transport.go holds the http.Transport of the http.Client made by New(), and WithRoundTripper(), which wraps it.
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// DefaultMaxIdleConnsPerHost is the number of idle connections to the cluster that the http.Client made by New()
+// keeps, so that concurrent queries reuse their connections instead of opening new ones.
+const DefaultMaxIdleConnsPerHost = 64
+
+// newDefaultTransport returns the http.Transport of the http.Client made by New() when WithHttpClient() is not used.
+// It keeps the settings of http.DefaultTransport, such as the proxy from the environment and the timeouts, requires
+// TLS 1.2 or later, and keeps more idle connections to the cluster.
+// If http.DefaultTransport was replaced by something other than an *http.Transport, the settings of a new one are used.
+func newDefaultTransport() *http.Transport {
+	var t *http.Transport
+	if base, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = base.Clone()
+	} else {
+		t = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	}
+	t.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	t.ForceAttemptHTTP2 = true
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.MinVersion = tls.VersionTLS12
+	return t
+}
+
+// WithRoundTripper wraps the transport of the http.Client made by New() with wrap, which receives the default
+// transport and returns the http.RoundTripper to use, for example to trace the requests:
+//
+//	client, err := kusto.New(kcsb, kusto.WithRoundTripper(func(base http.RoundTripper) http.RoundTripper {
+//		return otelhttp.NewTransport(base)
+//	}))
+//
+// Unlike WithHttpClient(), this keeps the defaults of the client. When used several times, the first wrap receives the
+// default transport and each next one receives the http.RoundTripper returned by the previous one.
+// It cannot be used with WithHttpClient(), as the transport of that client is the caller's to set: New() returns an
+// error if both are used.
+func WithRoundTripper(wrap func(base http.RoundTripper) http.RoundTripper) Option {
+	return func(c *Client) {
+		if wrap != nil {
+			c.wrapTransport = append(c.wrapTransport, wrap)
+		}
+	}
+}
+
+// newHTTPClient returns the http.Client of a Client that was not given one with WithHttpClient().
+func (c *Client) newHTTPClient() *http.Client {
+	var rt http.RoundTripper = newDefaultTransport()
+	for _, wrap := range c.wrapTransport {
+		rt = wrap(rt)
+	}
+	return &http.Client{Transport: rt}
+}
+
+// setHTTPClient sets the http.Client of c, once the options were applied.
+func (c *Client) setHTTPClient() error {
+	if c.http != nil {
+		if len(c.wrapTransport) > 0 {
+			return errors.ES(errors.OpServConn, errors.KClientArgs, "WithRoundTripper() cannot be used with WithHttpClient(), "+
+				"set the transport of the http.Client instead").SetNoRetry()
+		}
+		return nil
+	}
+	c.http = c.newHTTPClient()
+	return nil
+}
@@ -0,0 +1,102 @@
+package kusto
+
+import (
+	"context"
+	"crypto/tls"
+	goErrors "errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedTransport is a fake http.RoundTripper that records its name in the header of the requests, then sends them
+// with next.
+type namedTransport struct {
+	name string
+	next http.RoundTripper
+}
+
+func (n namedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Add("x-test-transport", n.name)
+	return n.next.RoundTrip(req)
+}
+
+func TestDefaultTransport(t *testing.T) {
+	t.Parallel()
+
+	client, err := New(NewConnectionStringBuilder("https://transport.kusto.windows.net"))
+	require.NoError(t, err)
+	defer client.Close()
+
+	transport, ok := client.HttpClient().Transport.(*http.Transport)
+	require.True(t, ok, "got %T", client.HttpClient().Transport)
+	assert.Equal(t, DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.True(t, transport.ForceAttemptHTTP2)
+	assert.NotNil(t, transport.Proxy)
+	require.NotNil(t, transport.TLSClientConfig)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+
+	// The default transport is not shared with http.DefaultTransport.
+	assert.NotSame(t, http.DefaultTransport, transport)
+	assert.Zero(t, http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost)
+}
+
+// TestDefaultTransportReplaced is not parallel, as it replaces http.DefaultTransport.
+func TestDefaultTransportReplaced(t *testing.T) {
+	defaultTransport := http.DefaultTransport
+	defer func() { http.DefaultTransport = defaultTransport }()
+	http.DefaultTransport = namedTransport{name: "replaced", next: defaultTransport}
+
+	transport := newDefaultTransport()
+	assert.Equal(t, DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.NotNil(t, transport.Proxy)
+	assert.NotNil(t, transport.DialContext)
+	require.NotNil(t, transport.TLSClientConfig)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+}
+
+func TestWithRoundTripper(t *testing.T) {
+	t.Parallel()
+
+	capture := &captureTransport{}
+	var bases []http.RoundTripper
+	wrap := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(base http.RoundTripper) http.RoundTripper {
+			bases = append(bases, base)
+			// The requests are captured instead of being sent by the default transport.
+			if _, ok := base.(*http.Transport); ok {
+				base = capture
+			}
+			return namedTransport{name: name, next: base}
+		}
+	}
+
+	client, err := New(NewConnectionStringBuilder("https://transport.kusto.windows.net"), WithRoundTripper(wrap("outer")),
+		WithRoundTripper(nil), WithRoundTripper(wrap("inner")))
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The first wrap receives the default transport, the next one the transport returned by the first.
+	require.Len(t, bases, 2)
+	transport, ok := bases[0].(*http.Transport)
+	require.True(t, ok, "got %T", bases[0])
+	assert.Equal(t, DefaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, namedTransport{name: "outer", next: capture}, bases[1])
+
+	iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+	require.NoError(t, err)
+	iter.Stop()
+	sent := capture.sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, []string{"inner", "outer"}, sent[0].Header.Values("x-test-transport"))
+
+	// The transport of a client passed with WithHttpClient() is the caller's to set.
+	_, err = New(NewConnectionStringBuilder("https://transport.kusto.windows.net"), WithHttpClient(&http.Client{}), WithRoundTripper(wrap("other")))
+	var kerr *errors.Error
+	require.True(t, goErrors.As(err, &kerr), "got %T: %v", err, err)
+	assert.Equal(t, errors.KClientArgs, kerr.Kind)
+}
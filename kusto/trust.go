@@ -0,0 +1,74 @@
+package kusto
+
+// trust.go holds the per-client settings of the trusted endpoint validation, which checks the endpoint before a token is
+// sent to it, see https://aka.ms/kustotrustedendpoints.
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	truestedEndpoints "github.com/Azure/azure-kusto-go/kusto/trusted_endpoints"
+)
+
+// UntrustedEndpointError is returned by the calls of a Client whose endpoint is not trusted, so that no token is sent to
+// it. Trust the hosts of private clusters with truestedEndpoints.Instance.AddTrustedHosts(), or with
+// WithTrustedEndpointPolicy() for a single Client.
+type UntrustedEndpointError struct {
+	// Endpoint is the endpoint of the Client.
+	Endpoint string
+	// LoginEndpoint is the login endpoint of the cloud of Endpoint, from its metadata, "" if a policy of
+	// WithTrustedEndpointPolicy() rejected it.
+	LoginEndpoint string
+	// Err is the error of the validation.
+	Err error
+}
+
+// Error implements error.
+func (e *UntrustedEndpointError) Error() string {
+	return fmt.Sprintf("endpoint %s is not trusted: %s", e.Endpoint, e.Err)
+}
+
+// Unwrap returns the error of the validation.
+func (e *UntrustedEndpointError) Unwrap() error {
+	return e.Err
+}
+
+// WithTrustedEndpointPolicy validates the endpoint of the Client with policy instead of the trusted endpoints of
+// truestedEndpoints.Instance: policy receives the host name of the endpoint, without its port, and returns whether it is
+// trusted. This is meant for clusters behind private endpoints with custom DNS, such as "kusto.contoso.internal".
+// A nil policy is ignored.
+func WithTrustedEndpointPolicy(policy func(host string) bool) Option {
+	return func(c *Client) {
+		if policy != nil {
+			c.trustedEndpointPolicy = policy
+		}
+	}
+}
+
+// SkipEndpointValidation trusts the endpoint of the Client without validating it. Only use it for endpoints known to be
+// trusted, as the tokens of the Client are sent to them.
+func SkipEndpointValidation() Option {
+	return WithTrustedEndpointPolicy(func(string) bool { return true })
+}
+
+// checkTrustedEndpoint returns an *UntrustedEndpointError if endpoint is not trusted by policy, or by the trusted endpoints
+// of loginEndpoint if policy is nil.
+func checkTrustedEndpoint(endpoint string, loginEndpoint string, policy func(host string) bool) error {
+	if policy == nil {
+		if err := truestedEndpoints.Instance.ValidateTrustedEndpoint(endpoint, loginEndpoint); err != nil {
+			return &UntrustedEndpointError{Endpoint: endpoint, LoginEndpoint: loginEndpoint, Err: err}
+		}
+		return nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return &UntrustedEndpointError{Endpoint: endpoint, Err: err}
+	}
+	if !policy(u.Hostname()) {
+		return &UntrustedEndpointError{Endpoint: endpoint,
+			Err: errors.ES(errors.OpServConn, errors.KClientArgs, "the host %s was rejected by the trusted endpoint policy", u.Hostname()).SetNoRetry()}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package kusto
+
+import (
+	"context"
+	goErrors "errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedEndpointPolicy(t *testing.T) {
+	t.Parallel()
+
+	const private = "https://mycluster.kusto.contoso.internal:443"
+	tests := []struct {
+		desc      string
+		endpoint  string
+		noToken   bool
+		options   []Option
+		wantHosts []string
+		wantErr   bool
+	}{
+		{desc: "Trusted endpoint", endpoint: "https://trust.kusto.windows.net"},
+		{desc: "Private endpoint", endpoint: private, wantErr: true},
+		{desc: "Private endpoint without token", endpoint: private, noToken: true},
+		{
+			desc:      "Policy",
+			endpoint:  private,
+			options:   []Option{WithTrustedEndpointPolicy(func(host string) bool { return host == "mycluster.kusto.contoso.internal" })},
+			wantHosts: []string{"mycluster.kusto.contoso.internal"},
+		},
+		{
+			desc:      "Policy rejecting",
+			endpoint:  "https://trust.kusto.windows.net",
+			options:   []Option{WithTrustedEndpointPolicy(func(host string) bool { return false })},
+			wantHosts: []string{"trust.kusto.windows.net"},
+			wantErr:   true,
+		},
+		{desc: "Skip", endpoint: private, options: []Option{SkipEndpointValidation()}},
+		{desc: "Nil policy", endpoint: private, options: []Option{WithTrustedEndpointPolicy(nil)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var hosts []string
+			options := test.options
+			if test.wantHosts != nil {
+				// The policy is recorded, which is called once as the endpoint is trusted afterwards.
+				policy := test.options[0]
+				options = []Option{func(c *Client) {
+					policy(c)
+					p := c.trustedEndpointPolicy
+					c.trustedEndpointPolicy = func(host string) bool {
+						hosts = append(hosts, host)
+						return p(host)
+					}
+				}}
+			}
+
+			transport := &captureTransport{}
+			kcsb := NewConnectionStringBuilder(test.endpoint)
+			if !test.noToken {
+				kcsb.WithBearerToken("token")
+			}
+			client, err := New(kcsb, append(options, WithHttpClient(&http.Client{Transport: transport}))...)
+			require.NoError(t, err)
+			defer client.Close()
+
+			for i := 0; i < 2; i++ {
+				iter, err := client.Query(context.Background(), "db", NewStmt("T"))
+				if test.wantErr {
+					var untrusted *UntrustedEndpointError
+					require.True(t, goErrors.As(err, &untrusted), "got %T: %v", err, err)
+					assert.Equal(t, test.endpoint, untrusted.Endpoint)
+					assert.Contains(t, err.Error(), "is not trusted")
+					continue
+				}
+				require.NoError(t, err)
+				iter.Stop()
+			}
+
+			if test.wantErr {
+				// No token was sent to the endpoint.
+				assert.Empty(t, transport.sent())
+			} else {
+				assert.Len(t, transport.sent(), 2)
+			}
+			if test.wantHosts != nil && !test.wantErr {
+				assert.Equal(t, test.wantHosts, hosts)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/Azure/azure-kusto-go/kusto/data/errors"
 	"github.com/samber/lo"
@@ -57,11 +58,15 @@ func createInstance() *TrustedEndpoints {
 
 // SetOverridePolicy Set a policy to override all other trusted rules
 func (trusted *TrustedEndpoints) SetOverridePolicy(matcher func(string) bool) {
+	trusted.mu.Lock()
+	defer trusted.mu.Unlock()
 	trusted.overrideMatcher = matcher
 }
 
 type TrustedEndpoints struct {
-	matchers          map[string]*FastSuffixMatcher
+	matchers map[string]*FastSuffixMatcher
+	// mu guards the rules added after the creation, which are set while endpoints are validated.
+	mu                sync.RWMutex
 	additionalMatcher *FastSuffixMatcher
 	overrideMatcher   func(string) bool
 }
@@ -71,6 +76,12 @@ type MatchRule struct {
 	exact  bool
 }
 
+// NewMatchRule returns a rule that trusts the host suffix, such as ".kusto.contoso.internal", or only the host suffix
+// itself if exact is set, such as "mycluster.kusto.contoso.internal". Hosts are compared without their case.
+func NewMatchRule(suffix string, exact bool) MatchRule {
+	return MatchRule{suffix: strings.ToLower(suffix), exact: exact}
+}
+
 type FastSuffixMatcher struct {
 	suffixLength int
 	rules        map[string][]MatchRule
@@ -160,22 +171,41 @@ func createFastSuffixMatcherFromExisting(rules []MatchRule, existing *FastSuffix
 	return newFastSuffixMatcher(rules)
 }
 
-// AddTrustedHosts Add or set a list of trusted endpoints rules
-func (trusted *TrustedEndpoints) AddTrustedHosts(rules []MatchRule, replace bool) error {
-	if rules == nil || len(rules) == 0 {
-		if replace {
-			trusted.additionalMatcher = nil
+// AddTrustedHosts trusts the hosts, in addition to the well known endpoints, for example for clusters behind private
+// endpoints with custom DNS. If exact is set, each host is trusted as is, such as "mycluster.kusto.contoso.internal",
+// otherwise as a suffix of the trusted hosts, such as ".kusto.contoso.internal". It is safe to call while endpoints are
+// validated.
+func (trusted *TrustedEndpoints) AddTrustedHosts(hosts []string, exact bool) error {
+	rules := make([]MatchRule, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			return errors.ES(errors.OpUnknown, errors.KClientArgs, "a trusted host cannot be empty").SetNoRetry()
 		}
-		return nil
+		rules = append(rules, NewMatchRule(host, exact))
 	}
+	return trusted.AddTrustedRules(rules, false)
+}
+
+// AddTrustedRules Add or set a list of trusted endpoints rules, see NewMatchRule(). If replace is set, the rules
+// replace the ones added before.
+func (trusted *TrustedEndpoints) AddTrustedRules(rules []MatchRule, replace bool) error {
+	trusted.mu.Lock()
+	defer trusted.mu.Unlock()
 
 	if replace {
 		trusted.additionalMatcher = nil
 	}
+	if len(rules) == 0 {
+		return nil
+	}
 
 	matcher, err := createFastSuffixMatcherFromExisting(rules, trusted.additionalMatcher)
+	if err != nil {
+		return err
+	}
 	trusted.additionalMatcher = matcher
-	return err
+	return nil
 }
 
 // ValidateTrustedEndpoint Validates the endpoint uri trusted
@@ -217,8 +247,11 @@ func (trusted *TrustedEndpoints) validateHostnameIsTrusted(host string, loginEnd
 		return nil
 	}
 
+	trusted.mu.RLock()
+	override, additional := trusted.overrideMatcher, trusted.additionalMatcher
+	trusted.mu.RUnlock()
+
 	// Either check the override matcher OR the matcher:
-	override := trusted.overrideMatcher
 	if override != nil && override(host) {
 		return nil
 	} else {
@@ -228,8 +261,7 @@ func (trusted *TrustedEndpoints) validateHostnameIsTrusted(host string, loginEnd
 		}
 	}
 
-	matcher := trusted.additionalMatcher
-	if matcher != nil && matcher.isMatch(host) {
+	if additional != nil && additional.isMatch(host) {
 		return nil
 	}
 
@@ -3,6 +3,7 @@ package truestedEndpoints
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -224,11 +225,11 @@ func TestWellTrustedEndpoints_EndpointsOverride(t *testing.T) {
 }
 
 func TestWellTrustedEndpoints_AdditionalWebsites(t *testing.T) {
-	Instance.AddTrustedHosts([]MatchRule{{suffix: ".someotherdomain1.net", exact: false}}, true)
+	Instance.AddTrustedRules([]MatchRule{{suffix: ".someotherdomain1.net", exact: false}}, true)
 
 	// 2nd call - to validate that addition works
-	Instance.AddTrustedHosts([]MatchRule{{suffix: "www.someotherdomain2.net", exact: true}}, false)
-	Instance.AddTrustedHosts([]MatchRule{{suffix: "www.someotherdomain3.net", exact: true}}, false)
+	Instance.AddTrustedRules([]MatchRule{{suffix: "www.someotherdomain2.net", exact: true}}, false)
+	Instance.AddTrustedRules([]MatchRule{{suffix: "www.someotherdomain3.net", exact: true}}, false)
 
 	for _, clusterName := range []string{"https://some.someotherdomain1.net", "https://www.someotherdomain2.net"} {
 		err := checkEndpoint(clusterName, defaultPublicLoginUrl, false)
@@ -239,10 +240,42 @@ func TestWellTrustedEndpoints_AdditionalWebsites(t *testing.T) {
 	require.NoError(t, err)
 
 	// Reset additional hosts
-	Instance.AddTrustedHosts(nil, true)
+	Instance.AddTrustedRules(nil, true)
 	// Validate that hosts are not allowed anymore
 	for _, clusterName := range []string{"https://some.someotherdomain1.net", "https://www.someotherdomain2.net"} {
 		err := checkEndpoint(clusterName, defaultPublicLoginUrl, true)
 		require.NoError(t, err)
 	}
 }
+
+func TestWellTrustedEndpoints_AddTrustedHosts(t *testing.T) {
+	defer Instance.AddTrustedRules(nil, true)
+
+	require.NoError(t, Instance.AddTrustedHosts([]string{".Kusto.Contoso.Internal"}, false))
+	require.NoError(t, Instance.AddTrustedHosts([]string{"mycluster.private.net"}, true))
+	require.Error(t, Instance.AddTrustedHosts([]string{" "}, false))
+
+	for _, clusterName := range []string{"https://mycluster.kusto.contoso.internal", "https://MyCluster.Private.Net"} {
+		require.NoError(t, checkEndpoint(clusterName, defaultPublicLoginUrl, false))
+	}
+	for _, clusterName := range []string{"https://kusto.contoso.internal.evil.com", "https://other.mycluster.private.net"} {
+		require.NoError(t, checkEndpoint(clusterName, defaultPublicLoginUrl, true))
+	}
+
+	// The rules can be added while endpoints are validated.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, Instance.AddTrustedHosts([]string{fmt.Sprintf(".concurrent%d.net", i)}, false))
+		}()
+		go func() {
+			defer wg.Done()
+			require.NoError(t, checkEndpoint("https://mycluster.kusto.contoso.internal", defaultPublicLoginUrl, false))
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, checkEndpoint("https://cluster.concurrent9.net", defaultPublicLoginUrl, false))
+}
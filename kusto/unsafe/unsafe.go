@@ -10,3 +10,15 @@ type Stmt struct {
 	// SuppressWarning allows the Unsafe warning log message to be suppressed for this Stmt.
 	SuppressWarning bool
 }
+
+// Policy sets the guardrails of a Stmt built from text known only at runtime with kusto.NewUnsafeStmt().
+type Policy struct {
+	// Reviewer, if set, is called with the text before it is accepted by kusto.NewUnsafeStmt() or added by
+	// Stmt.UnsafeAdd(). An error rejects the text.
+	Reviewer func(query string) error
+	// RequireReviewer rejects the text when Reviewer is not set, so that no statement is accepted unreviewed.
+	RequireReviewer bool
+	// SuppressWarning allows the Unsafe warning log message to be suppressed for this Stmt, including the warning
+	// sent to the Logger of the client when the Stmt is run.
+	SuppressWarning bool
+}
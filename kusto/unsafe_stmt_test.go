@@ -0,0 +1,129 @@
+package kusto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/unsafe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUnsafeStmt(t *testing.T) {
+	t.Parallel()
+
+	noDrop := func(query string) error {
+		if strings.Contains(query, ".drop") {
+			return fmt.Errorf("query drops an entity")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		desc    string
+		text    string
+		policy  unsafe.Policy
+		add     string
+		err     bool
+		addErr  bool
+		wantStr string
+	}{
+		{
+			desc:    "Success: no Reviewer",
+			text:    "T | take 1",
+			policy:  unsafe.Policy{SuppressWarning: true},
+			wantStr: "T | take 1",
+		},
+		{
+			desc:   "Error: Reviewer required",
+			text:   "T | take 1",
+			policy: unsafe.Policy{RequireReviewer: true, SuppressWarning: true},
+			err:    true,
+		},
+		{
+			desc:   "Error: text rejected",
+			text:   ".drop table T",
+			policy: unsafe.Policy{Reviewer: noDrop, RequireReviewer: true, SuppressWarning: true},
+			err:    true,
+		},
+		{
+			desc:    "Success: text and added text reviewed",
+			text:    "T",
+			policy:  unsafe.Policy{Reviewer: noDrop, RequireReviewer: true, SuppressWarning: true},
+			add:     " | take 1",
+			wantStr: "T | take 1",
+		},
+		{
+			desc:   "Error: added text rejected",
+			text:   "T",
+			policy: unsafe.Policy{Reviewer: noDrop, SuppressWarning: true},
+			add:    "; .drop table T",
+			addErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			stmt, err := NewUnsafeStmt(test.text, test.policy)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if test.add != "" {
+				stmt = stmt.UnsafeAdd(test.add)
+			}
+			if test.addErr {
+				assert.Error(t, stmt.Err())
+				return
+			}
+			require.NoError(t, stmt.Err())
+			assert.Equal(t, test.wantStr, stmt.String())
+		})
+	}
+}
+
+func TestUnsafeAddLiteral(t *testing.T) {
+	t.Parallel()
+
+	// No unsafe.Stmt is needed, as the value cannot end the literal.
+	stmt := NewStmt(".show tables | where TableName == ").UnsafeAddLiteral(`T" | take 1 //`)
+	assert.Equal(t, `.show tables | where TableName == "T\" | take 1 //"`, stmt.String())
+}
+
+func TestWarnUnsafe(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		msgs []string
+	)
+	client := &Client{logger: func(level LogLevel, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		msgs = append(msgs, level.String()+" "+msg)
+	}}
+
+	safe := NewStmt("T | take 1")
+	unsafeStmt, err := NewUnsafeStmt("T | take 2", unsafe.Policy{})
+	require.NoError(t, err)
+	suppressed, err := NewUnsafeStmt("T | take 3", unsafe.Policy{SuppressWarning: true})
+	require.NoError(t, err)
+	enabledOnly := NewStmt("T | take 4", UnsafeStmt(unsafe.Stmt{Add: true, SuppressWarning: true}))
+
+	for _, stmt := range []Stmt{safe, unsafeStmt, suppressed, enabledOnly, safe.UnsafeAddLiteral("x")} {
+		client.warnUnsafe(CallQuery, stmt)
+	}
+	client.warnUnsafe(CallMgmt, NewStmt(".show ", UnsafeStmt(unsafe.Stmt{Add: true})).UnsafeAdd("tables"))
+
+	assert.Equal(t, []string{
+		"WARN Query() is running a Stmt built with unsafe text: T | take 2",
+		"WARN Mgmt() is running a Stmt built with unsafe text: .show tables",
+	}, msgs)
+}
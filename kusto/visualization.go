@@ -0,0 +1,231 @@
+package kusto
+
+// visualization.go implements RowIterator.Visualization(), which decodes the properties of a query's render operator.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+)
+
+// visualizationKey is the key of the visualization row in the @ExtendedProperties table.
+const visualizationKey = "Visualization"
+
+// Visualization holds the properties of the render operator of a query, such as `| render timechart with (title="x")`.
+// See https://learn.microsoft.com/en-us/azure/data-explorer/kusto/query/renderoperator for the meaning of each field.
+type Visualization struct {
+	// Visualization is the kind of chart, such as "timechart", "piechart" or "table".
+	Visualization string
+	// Title is the title of the chart.
+	Title string
+	// XColumn is the column used for the x-axis.
+	XColumn string
+	// Series are the columns whose values define the series of the chart.
+	Series []string
+	// YColumns are the columns used for the y-axis.
+	YColumns []string
+	// AnomalyColumns are the columns that hold anomalies, for anomalychart.
+	AnomalyColumns []string
+	// XTitle is the title of the x-axis.
+	XTitle string
+	// YTitle is the title of the y-axis.
+	YTitle string
+	// XAxis is the scale of the x-axis, "linear" or "log".
+	XAxis string
+	// YAxis is the scale of the y-axis, "linear" or "log".
+	YAxis string
+	// Legend is "visible" or "hidden".
+	Legend string
+	// YSplit is how multiple y-axes are displayed: "none", "axes" or "panels".
+	YSplit string
+	// Accumulate indicates if the values of each measure are added to the ones of the previous ones.
+	Accumulate bool
+	// IsQuerySorted indicates if the rows are already sorted by the query.
+	IsQuerySorted bool
+	// Kind is the variant of the chart, such as "default", "stacked", "stacked100", "unstacked" or "map".
+	Kind string
+	// Ymin is the minimum value of the y-axis, NaN if not set.
+	Ymin float64
+	// Ymax is the maximum value of the y-axis, NaN if not set.
+	Ymax float64
+	// Xmin is the minimum value of the x-axis as a JSON value, nil if not set. It is a number or a datetime string.
+	Xmin interface{}
+	// Xmax is the maximum value of the x-axis as a JSON value, nil if not set. It is a number or a datetime string.
+	Xmax interface{}
+	// Raw holds the properties that are not one of the fields above, as sent by the service.
+	Raw map[string]json.RawMessage
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Null properties leave their field at the zero value, columns are
+// accepted as a comma separated string or as an array, and unknown properties are kept in Raw.
+func (v *Visualization) UnmarshalJSON(b []byte) error {
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(b, &props); err != nil {
+		return err
+	}
+
+	*v = Visualization{Ymin: math.NaN(), Ymax: math.NaN()}
+	strs := map[string]*string{
+		"Visualization": &v.Visualization,
+		"Title":         &v.Title,
+		"XColumn":       &v.XColumn,
+		"XTitle":        &v.XTitle,
+		"YTitle":        &v.YTitle,
+		"XAxis":         &v.XAxis,
+		"YAxis":         &v.YAxis,
+		"Legend":        &v.Legend,
+		"YSplit":        &v.YSplit,
+		"Kind":          &v.Kind,
+	}
+	lists := map[string]*[]string{
+		"Series":         &v.Series,
+		"YColumns":       &v.YColumns,
+		"AnomalyColumns": &v.AnomalyColumns,
+	}
+	bools := map[string]*bool{
+		"Accumulate":    &v.Accumulate,
+		"IsQuerySorted": &v.IsQuerySorted,
+	}
+	floats := map[string]*float64{
+		"Ymin": &v.Ymin,
+		"Ymax": &v.Ymax,
+	}
+	anys := map[string]*interface{}{
+		"Xmin": &v.Xmin,
+		"Xmax": &v.Xmax,
+	}
+
+	for name, raw := range props {
+		if bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+
+		var err error
+		switch {
+		case strs[name] != nil:
+			err = json.Unmarshal(raw, strs[name])
+		case lists[name] != nil:
+			*lists[name], err = visualizationColumns(raw)
+		case bools[name] != nil:
+			err = json.Unmarshal(raw, bools[name])
+		case floats[name] != nil:
+			*floats[name], err = visualizationFloat(raw)
+		case anys[name] != nil:
+			err = json.Unmarshal(raw, anys[name])
+		default:
+			if v.Raw == nil {
+				v.Raw = map[string]json.RawMessage{}
+			}
+			v.Raw[name] = raw
+		}
+		if err != nil {
+			return fmt.Errorf("visualization property %s could not be decoded: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// visualizationColumns decodes a list of columns, which is a comma separated string or an array.
+func visualizationColumns(raw json.RawMessage) ([]string, error) {
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	for _, col := range strings.Split(s, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			list = append(list, col)
+		}
+	}
+	return list, nil
+}
+
+// visualizationFloat decodes a number that the service may send as a string, such as "NaN".
+func visualizationFloat(raw json.RawMessage) (float64, error) {
+	var f float64
+	if err := json.Unmarshal(raw, &f); err == nil {
+		return f, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Visualization returns the properties of the render operator of the query, or nil if the query had no render
+// operator. The properties are sent in the @ExtendedProperties table before the primary results, so they are
+// available as soon as the first row is. Both the v2 table of queries, made of Key and Value columns, and the v1 table
+// of management commands, made of a Value column holding the properties, are supported. Returns
+// NonPrimarySuppressedErr if the query was made with the PrimaryResultsOnly() option.
+func (r *RowIterator) Visualization() (*Visualization, error) {
+	props, err := r.GetExtendedProperties()
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keyIndex, valueIndex := -1, -1
+	for i, col := range props.Columns {
+		switch col.Name {
+		case "Key":
+			keyIndex = i
+		case "Value":
+			valueIndex = i
+		}
+	}
+	if valueIndex < 0 {
+		return nil, errors.ES(r.op, errors.KInternal, "the @ExtendedProperties table did not have a Value column")
+	}
+
+	for _, row := range props.KustoRows {
+		if valueIndex >= len(row) {
+			continue
+		}
+		if keyIndex >= 0 && (keyIndex >= len(row) || row[keyIndex].String() != visualizationKey) {
+			continue
+		}
+
+		raw := []byte(row[valueIndex].String())
+		// The properties may be sent as a JSON string holding the JSON object.
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			raw = []byte(s)
+		}
+
+		// Without a Key column, every row holds a JSON object of properties and the visualization is the one with a
+		// Visualization property.
+		if keyIndex < 0 {
+			var probe map[string]json.RawMessage
+			if json.Unmarshal(raw, &probe) != nil {
+				continue
+			}
+			if _, ok := probe[visualizationKey]; !ok {
+				continue
+			}
+		}
+
+		v := &Visualization{}
+		if err := json.Unmarshal(raw, v); err != nil {
+			return nil, errors.E(r.op, errors.KInternal, err)
+		}
+		if v.Visualization == "" {
+			return nil, nil
+		}
+		return v, nil
+	}
+	return nil, nil
+}
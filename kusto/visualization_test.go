@@ -0,0 +1,142 @@
+package kusto
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisualization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		fixture string
+		mgmt    bool
+		options []QueryOption
+		want    *Visualization
+		wantErr error
+	}{
+		{
+			desc:    "timechart",
+			fixture: "visualization_timechart.json",
+			want: &Visualization{
+				Visualization: "timechart",
+				Title:         "Requests per hour",
+				XColumn:       "Timestamp",
+				Series:        []string{"Region", "Service"},
+				YColumns:      []string{"Count"},
+				XTitle:        "Time",
+				YTitle:        "Requests",
+				XAxis:         "linear",
+				YAxis:         "log",
+				Legend:        "visible",
+				YSplit:        "none",
+				IsQuerySorted: true,
+				Kind:          "default",
+				Ymin:          0,
+				Ymax:          math.NaN(),
+				Xmin:          "2022-01-01T00:00:00Z",
+				Raw:           map[string]json.RawMessage{"SomeFutureProperty": json.RawMessage(`{"a":1}`)},
+			},
+		},
+		{
+			desc:    "piechart",
+			fixture: "visualization_piechart.json",
+			want: &Visualization{
+				Visualization: "piechart",
+				Title:         "Share",
+				Legend:        "hidden",
+				Accumulate:    true,
+				Kind:          "map",
+				Ymin:          math.NaN(),
+				Ymax:          math.NaN(),
+			},
+		},
+		{
+			desc:    "table",
+			fixture: "visualization_table.json",
+			want: &Visualization{
+				Visualization: "table",
+				Ymin:          math.NaN(),
+				Ymax:          math.NaN(),
+			},
+		},
+		{
+			desc:    "v1 timechart",
+			fixture: "visualization_v1_timechart.json",
+			mgmt:    true,
+			want: &Visualization{
+				Visualization: "timechart",
+				Title:         "Requests per hour",
+				XColumn:       "Timestamp",
+				YColumns:      []string{"Count"},
+				Legend:        "hidden",
+				Kind:          "stacked",
+				Ymin:          math.NaN(),
+				Ymax:          math.NaN(),
+			},
+		},
+		{
+			desc:    "No render operator",
+			fixture: "visualization_none.json",
+		},
+		{
+			desc:    "PrimaryResultsOnly",
+			fixture: "visualization_timechart.json",
+			options: []QueryOption{PrimaryResultsOnly()},
+			wantErr: NonPrimarySuppressedErr,
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := os.ReadFile(filepath.Join("testdata", test.fixture))
+			require.NoError(t, err)
+
+			client := newTestClient(t, "https://render.kusto.windows.net", fixtureTransport{body: body})
+
+			var iter *RowIterator
+			if test.mgmt {
+				iter, err = client.Mgmt(context.Background(), "db", NewStmt("T | render timechart"))
+			} else {
+				iter, err = client.Query(context.Background(), "db", NewStmt("T | render timechart"), test.options...)
+			}
+			require.NoError(t, err)
+			defer iter.Stop()
+			require.NoError(t, iter.Do(func(*table.Row) error { return nil }))
+
+			got, err := iter.Visualization()
+			if test.wantErr != nil {
+				assert.Equal(t, test.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+
+			// NaN is not equal to itself, so the limits are compared apart.
+			assert.Equal(t, math.IsNaN(test.want.Ymin), math.IsNaN(got.Ymin))
+			assert.Equal(t, math.IsNaN(test.want.Ymax), math.IsNaN(got.Ymax))
+			want, gotCopy := *test.want, *got
+			want.Ymin, want.Ymax, gotCopy.Ymin, gotCopy.Ymax = 0, 0, 0, 0
+			if !math.IsNaN(got.Ymin) {
+				assert.Equal(t, test.want.Ymin, got.Ymin)
+			}
+			assert.Equal(t, want, gotCopy)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package kusto
+
+// warnings.go surfaces the warnings the service attaches to the results of a query, such as the use of a deprecated
+// function, which are reported in the QueryCompletionInformation table.
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/value"
+	"github.com/Azure/azure-kusto-go/kusto/frames"
+)
+
+// ErrorReportingPlacement is where the service reports the errors and warnings of a query, see
+// ResultsErrorReportingPlacement().
+type ErrorReportingPlacement string
+
+const (
+	// ErrorReportingInData reports them in the data, in place of rows. This is the default of the service.
+	ErrorReportingInData ErrorReportingPlacement = "in_data"
+	// ErrorReportingEndOfTable reports them after the last row of the table they occurred in.
+	ErrorReportingEndOfTable ErrorReportingPlacement = "end_of_table"
+	// ErrorReportingEndOfDataset reports them at the end of the response, after all the tables.
+	ErrorReportingEndOfDataset ErrorReportingPlacement = "end_of_dataset"
+)
+
+// ResultsErrorReportingPlacement sets where the service reports the errors and warnings of the query. Engines that
+// support it also report their warnings, which are then available from RowIterator.Warnings(). Older engines ignore
+// the option.
+func ResultsErrorReportingPlacement(p ErrorReportingPlacement) QueryOption {
+	return func(q *queryOptions) error {
+		switch p {
+		case ErrorReportingInData, ErrorReportingEndOfTable, ErrorReportingEndOfDataset:
+		default:
+			return errors.ES(errors.OpQuery, errors.KClientArgs, "ResultsErrorReportingPlacement(%q) is not a valid placement", string(p)).SetNoRetry()
+		}
+		q.requestProperties.setOption(ResultsErrorReportingPlacementValue, string(p))
+		return nil
+	}
+}
+
+// Warning is a warning the service attached to the results of a query.
+type Warning struct {
+	// Timestamp is when the warning was raised.
+	Timestamp time.Time
+	// Code identifies the kind of the warning, such as "QueryInfo".
+	Code string
+	// Message describes the warning.
+	Message string
+	// Payload is the warning as sent by the service, which may hold more details than Message.
+	Payload string
+}
+
+// warningLevel is the value of the Level column for warnings in the QueryCompletionInformation table.
+const warningLevel = 3
+
+// Warnings returns the warnings the service attached to the results. They are reported at the end of the
+// response, so the list is only complete once the RowIterator has reached io.EOF. Warnings are also passed to the
+// Logger set with WithLogger() as they arrive. No warnings are received with the PrimaryResultsOnly() option.
+func (r *RowIterator) Warnings() []Warning {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Warning(nil), r.warnings...)
+}
+
+// addWarnings records warnings and logs them. It must be called with r.mu held.
+func (r *RowIterator) addWarnings(warnings []Warning) {
+	r.warnings = append(r.warnings, warnings...)
+	for _, w := range warnings {
+		r.logger.log(LogWarn, "query warning %s: %s", w.Code, w.Message)
+	}
+}
+
+// setLogger sets the Logger of the warnings, and logs the ones that were already received.
+func (r *RowIterator) setLogger(l Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger = l
+	for _, w := range r.warnings {
+		r.logger.log(LogWarn, "query warning %s: %s", w.Code, w.Message)
+	}
+}
+
+// warningsFromCompletionInfo returns the warnings in a QueryCompletionInformation table. Rows and columns that do
+// not have the expected shape are ignored, so that an unknown format does not fail the query.
+func warningsFromCompletionInfo(dt frames.DataTable) []Warning {
+	col := map[string]int{}
+	for i, c := range dt.Columns {
+		col[c.Name] = i
+	}
+	get := func(row value.Values, name string) value.Kusto {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return nil
+	}
+	str := func(row value.Values, name string) string {
+		if s, ok := get(row, name).(value.String); ok && s.Valid {
+			return s.Value
+		}
+		return ""
+	}
+
+	var warnings []Warning
+	for _, row := range dt.KustoRows {
+		isWarning := false
+		if name := str(row, "LevelName"); name != "" {
+			isWarning = strings.EqualFold(name, "Warning")
+		} else if level, ok := get(row, "Level").(value.Int); ok && level.Valid {
+			isWarning = level.Value == warningLevel
+		}
+		if !isWarning {
+			continue
+		}
+
+		w := Warning{Code: str(row, "EventTypeName"), Payload: str(row, "Payload")}
+		if ts, ok := get(row, "Timestamp").(value.DateTime); ok && ts.Valid {
+			w.Timestamp = ts.Value
+		}
+		w.Message = warningMessage(w.Payload)
+		if w.Message == "" && w.Code == "" {
+			continue
+		}
+		warnings = append(warnings, w)
+	}
+	return warnings
+}
+
+// warningMessage returns the text of a warning payload, which is usually a JSON object with a "Text" or "Message"
+// field. Other payloads are returned as is.
+func warningMessage(payload string) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+		return strings.TrimSpace(payload)
+	}
+	for _, k := range []string{"Text", "Message", "message"} {
+		if s, ok := fields[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return strings.TrimSpace(payload)
+}
@@ -0,0 +1,119 @@
+package kusto
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-kusto-go/kusto/data/errors"
+	"github.com/Azure/azure-kusto-go/kusto/data/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarnings(t *testing.T) {
+	t.Parallel()
+
+	deprecated := Warning{
+		Timestamp: time.Date(2023, 11, 28, 11, 13, 43, 251477900, time.UTC),
+		Code:      "QueryInfo",
+		Message:   "The function 'todynamic' is deprecated and may be removed in a future version. Use 'parse_json' instead.",
+		Payload:   `{"Count":1,"Text":"The function 'todynamic' is deprecated and may be removed in a future version. Use 'parse_json' instead."}`,
+	}
+	crossCluster := Warning{
+		Timestamp: time.Date(2023, 11, 28, 11, 13, 43, 252477900, time.UTC),
+		Code:      "CrossClusterQuery",
+		Message:   "Query implicitly references cluster 'https://other.kusto.windows.net'.",
+		Payload:   "Query implicitly references cluster 'https://other.kusto.windows.net'.",
+	}
+
+	tests := []struct {
+		desc    string
+		fixture string
+		options []QueryOption
+		want    []Warning
+	}{
+		{
+			desc:    "Non-progressive",
+			fixture: "warnings.json",
+			want:    []Warning{deprecated, crossCluster},
+		},
+		{
+			desc:    "Progressive",
+			fixture: "warnings_progressive.json",
+			options: []QueryOption{ResultsErrorReportingPlacement(ErrorReportingEndOfDataset)},
+			want:    []Warning{deprecated, crossCluster},
+		},
+		{
+			desc:    "PrimaryResultsOnly drops the warnings",
+			fixture: "warnings.json",
+			options: []QueryOption{PrimaryResultsOnly()},
+		},
+		{
+			desc:    "Unknown completion information shape",
+			fixture: "warnings_unknown.json",
+		},
+		{
+			desc:    "No completion information",
+			fixture: "datetime.json",
+		},
+	}
+
+	for _, test := range tests {
+		test := test // Capture
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := os.ReadFile(filepath.Join("testdata", test.fixture))
+			require.NoError(t, err)
+
+			var mu sync.Mutex
+			var logged []string
+			logger := func(level LogLevel, msg string) {
+				mu.Lock()
+				defer mu.Unlock()
+				logged = append(logged, level.String()+" "+msg)
+			}
+
+			client := newTestClient(t, "https://warnings.kusto.windows.net", fixtureTransport{body: body})
+			WithLogger(logger)(client)
+
+			iter, err := client.Query(context.Background(), "db", NewStmt("T"), test.options...)
+			require.NoError(t, err)
+			defer iter.Stop()
+			rows := 0
+			require.NoError(t, iter.Do(func(*table.Row) error {
+				rows++
+				return nil
+			}))
+			assert.NotZero(t, rows)
+
+			got := iter.Warnings()
+			assert.Equal(t, test.want, got)
+
+			var wantLogged []string
+			for _, w := range test.want {
+				wantLogged = append(wantLogged, "WARN query warning "+w.Code+": "+w.Message)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, wantLogged, logged)
+		})
+	}
+}
+
+func TestResultsErrorReportingPlacement(t *testing.T) {
+	t.Parallel()
+
+	opts, err := setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, ResultsErrorReportingPlacement(ErrorReportingEndOfTable))
+	require.NoError(t, err)
+	v, ok := opts.requestProperties.option(ResultsErrorReportingPlacementValue)
+	assert.True(t, ok)
+	assert.Equal(t, "end_of_table", v)
+
+	_, err = setQueryOptions(context.Background(), errors.OpQuery, NewStmt("T"), nil, ResultsErrorReportingPlacement("in_the_middle"))
+	assert.Error(t, err)
+}